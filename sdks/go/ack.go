@@ -0,0 +1,145 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Frame types the router uses to accept or reject a frame, echoing its
+// stream ID and sequence number
+const (
+	FrameTypeAck  = "ack"
+	FrameTypeNack = "nack"
+)
+
+// NackError is returned when the router rejects a frame with a nack. It
+// matches ErrNacked with errors.Is.
+type NackError struct {
+	StreamID string
+	MsgSeq   int
+	Code     string
+	Reason   string
+}
+
+func (e *NackError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%v: %s (%s)", ErrNacked, e.Reason, e.Code)
+	}
+	return fmt.Sprintf("%v: %s", ErrNacked, e.Reason)
+}
+
+// Is reports whether target is ErrNacked
+func (e *NackError) Is(target error) bool {
+	return target == ErrNacked
+}
+
+// BuildAckFrame builds an ack accepting the frame msgSeq of streamID
+func (fb *FrameBuilder) BuildAckFrame(streamID string, msgSeq int, meta ...Meta) Frame {
+	return Frame{
+		Type:      FrameTypeAck,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		Meta:      withMeta(Meta{}, meta),
+		Payload:   map[string]interface{}{},
+	}
+}
+
+// BuildNackFrame builds a nack rejecting the frame msgSeq of streamID
+func (fb *FrameBuilder) BuildNackFrame(streamID string, msgSeq int, code, reason string, meta ...Meta) Frame {
+	return Frame{
+		Type:      FrameTypeNack,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		Meta:      withMeta(Meta{}, meta),
+		Payload: map[string]interface{}{
+			"code":   code,
+			"reason": reason,
+		},
+	}
+}
+
+// dispatchAck hands an ack or nack frame to the waiter for the frame it
+// acknowledges
+func (c *ATPClient) dispatchAck(frame *Frame) time.Duration {
+	result := pendingResult{frame: frame}
+	if frame.Type == FrameTypeNack {
+		result.err = &NackError{
+			StreamID: frame.StreamID,
+			MsgSeq:   frame.MsgSeq,
+			Code:     getString(frame.Payload, "code", ""),
+			Reason:   getString(frame.Payload, "reason", "rejected"),
+		}
+	}
+	return c.dispatchResult(frame, result)
+}
+
+// SendReliable sends a custom frame and waits until the router accepts it
+// with an ack (or any response on the frame's stream and sequence number).
+// The frame must have a stream ID. A nack fails with a *NackError and an
+// error frame with an *ATPError.
+func (c *ATPClient) SendReliable(ctx context.Context, frame Frame) error {
+	if c.closed() {
+		return ErrClientClosed
+	}
+	if frame.StreamID == "" {
+		return errors.New("reliable frames need a stream ID")
+	}
+	if !c.IsConnected() {
+		if err := c.Connect(); err != nil {
+			return fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+	return c.sendAcknowledged(ctx, frame, requestOptions{}, frame.Type+" frame")
+}
+
+// sendAcknowledged sends frame and waits for the router to acknowledge it.
+// With the FireAndForget option it returns once the frame is sent, and a
+// missing or negative acknowledgment is only logged.
+func (c *ATPClient) sendAcknowledged(ctx context.Context, frame Frame, options requestOptions, what string) error {
+	if options.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+		defer cancel()
+	}
+
+	pending := c.expectResponse(frame)
+	send := c.sendRequest
+	if options.fireAndForget && options.waitForWrite {
+		send = c.sendRequestWritten
+	}
+	if err := send(ctx, frame); err != nil {
+		c.discardPending(pending, false)
+		return fmt.Errorf("failed to send %s: %w", what, err)
+	}
+
+	if options.fireAndForget {
+		go func() {
+			if err := c.waitForAck(context.Background(), pending); err != nil {
+				c.config.Logger.Printf("Warning: No acknowledgment received for %s%s: %v", what, logCorrelation(frame.Meta.CorrelationID), err)
+			}
+		}()
+		return nil
+	}
+	if err := c.waitForAck(ctx, pending); err != nil {
+		return fmt.Errorf("%s not acknowledged: %w", what, err)
+	}
+	return nil
+}
+
+// waitForAck waits for the acknowledgment registered with expectResponse
+func (c *ATPClient) waitForAck(ctx context.Context, pending *pendingResponse) error {
+	response, err := c.waitForResponse(ctx, pending)
+	if err != nil {
+		return err
+	}
+	if response.Type == FrameTypeError {
+		return parseErrorFrame(response)
+	}
+	return nil
+}
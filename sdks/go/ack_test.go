@@ -0,0 +1,86 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// nackRouter acks every frame except those of rejected types, which it nacks
+func nackRouter(t *testing.T, rejected string) *testRouter {
+	fb := NewFrameBuilder("", "")
+	return newTestRouter(t, func(f Frame) []Frame {
+		switch f.Type {
+		case "heartbeat":
+			return nil
+		case rejected:
+			return []Frame{fb.BuildNackFrame(f.StreamID, f.MsgSeq, "invalid_payload", "unknown adapter")}
+		}
+		return []Frame{fb.BuildAckFrame(f.StreamID, f.MsgSeq)}
+	})
+}
+
+func TestReportHealthAcked(t *testing.T) {
+	router := nackRouter(t, "")
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	if err := client.ReportHealth(context.Background(), HealthStatus{AdapterID: "a", Status: "healthy"}); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+}
+
+func TestAdvertiseCapabilitiesNacked(t *testing.T) {
+	router := nackRouter(t, "adapter.capability")
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	err := client.AdvertiseCapabilities(context.Background(), CapabilityAdvertisement{AdapterID: "a"})
+	var nack *NackError
+	if !errors.As(err, &nack) || !errors.Is(err, ErrNacked) {
+		t.Fatalf("Expected a *NackError, got %v", err)
+	}
+	if nack.Code != "invalid_payload" || nack.Reason != "unknown adapter" || nack.StreamID == "" {
+		t.Errorf("Unexpected nack: %+v", nack)
+	}
+}
+
+func TestFireAndForgetLogsNack(t *testing.T) {
+	router := nackRouter(t, "adapter.health")
+	logger := &recordingLogger{}
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Logger: logger})
+	defer client.Close()
+
+	if err := client.ReportHealth(context.Background(), HealthStatus{AdapterID: "a", Status: "healthy"}, FireAndForget()); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, line := range logger.Lines() {
+			if strings.Contains(line, "health report") && strings.Contains(line, "unknown adapter") {
+				return
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	t.Fatalf("Expected the nack to be logged, got %q", logger.Lines())
+}
+
+func TestSendReliable(t *testing.T) {
+	router := nackRouter(t, "custom.rejected")
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	if err := client.SendReliable(context.Background(), Frame{Type: "custom.accepted", StreamID: "s1", MsgSeq: 1}); err != nil {
+		t.Fatalf("SendReliable failed: %v", err)
+	}
+	err := client.SendReliable(context.Background(), Frame{Type: "custom.rejected", StreamID: "s1", MsgSeq: 2})
+	if !errors.Is(err, ErrNacked) {
+		t.Errorf("Expected ErrNacked, got %v", err)
+	}
+	if err := client.SendReliable(context.Background(), Frame{Type: "custom.accepted"}); err == nil {
+		t.Error("Expected a frame without a stream ID to be refused")
+	}
+}
@@ -0,0 +1,551 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CompletionHandler serves a completion request received from the router
+type CompletionHandler func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error)
+
+// AdapterServerConfig configures an AdapterServer
+type AdapterServerConfig struct {
+	// Workers bounds the number of handlers running concurrently
+	// (default: 8).
+	Workers int
+	// QueueSize bounds the number of requests waiting for a worker
+	// (default: 64). Requests arriving while the queue is full are answered
+	// with an ADAPTER_OVERLOADED error frame.
+	QueueSize int
+	// Chunking controls how streaming handlers' output is coalesced into
+	// response frames.
+	Chunking ChunkingConfig
+	// AdapterID is the adapter whose capabilities Shutdown withdraws, and
+	// whose requests are answered with ADAPTER_PAUSED while it is paused
+	// with PauseAdapter. When empty, Shutdown only drains the server.
+	AdapterID string
+	// WindowPolicy is how requests beyond the Window.MaxParallel the router
+	// set on them are handled (default: WindowQueue). The window covers the
+	// session, so every request the server is handling counts against it.
+	WindowPolicy WindowPolicy
+}
+
+// WindowPolicy is how an AdapterServer handles requests arriving while as
+// many as their Window.MaxParallel are already being handled
+type WindowPolicy int
+
+const (
+	// WindowQueue holds such requests until a handler finishes, within
+	// AdapterServerConfig.QueueSize. Requests whose TTL runs out while held
+	// are answered with a WINDOW_EXCEEDED error frame.
+	WindowQueue WindowPolicy = iota
+	// WindowReject answers such requests with a WINDOW_EXCEEDED error frame
+	// at once.
+	WindowReject
+)
+
+// AdapterServer serves completion_request frames arriving on a client's
+// connection with a CompletionHandler and sends back the matching
+// completion_response or error frames.
+type AdapterServer struct {
+	client        *ATPClient
+	handler       CompletionHandler
+	streamHandler StreamingCompletionHandler
+	config        AdapterServerConfig
+	builder       *FrameBuilder
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	jobs   chan *adapterRequest
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	requestsMu sync.Mutex
+	requests   map[*adapterRequest]struct{} // accepted and not yet served
+
+	window    adapterWindow
+	inFlight  atomic.Int64
+	served    atomic.Uint64
+	failed    atomic.Uint64
+	latencies latencyWindow
+}
+
+// AdapterServerStats is a snapshot of an AdapterServer's load and recent
+// handler performance
+type AdapterServerStats struct {
+	// InFlight is the number of handlers currently running
+	InFlight int64
+	// Queued is the number of requests waiting for a worker or, under
+	// WindowQueue, for a slot in the router's window
+	Queued int
+	// WindowWaiting is the part of Queued waiting for a window slot
+	WindowWaiting int
+	// Served and Failed count completed requests; Failed counts those
+	// answered with an error frame
+	Served uint64
+	Failed uint64
+	// P50Latency, P95Latency and P99Latency are handler latency percentiles
+	// over the LatencySamples most recent requests
+	P50Latency     time.Duration
+	P95Latency     time.Duration
+	P99Latency     time.Duration
+	LatencySamples int
+}
+
+// HandleCompletions registers handler to serve incoming completion requests
+// and starts its worker pool. Only one handler may be registered per client
+// at a time; Close the returned server to unregister it. Serving needs a
+// WebSocket connection, so it fails with ErrNotSupportedByTransport over
+// the HTTP transport.
+func (c *ATPClient) HandleCompletions(handler CompletionHandler, config AdapterServerConfig) (*AdapterServer, error) {
+	return c.startAdapterServer(&AdapterServer{handler: handler}, config)
+}
+
+// HandleStreamingCompletions is like HandleCompletions, but the handler
+// streams its output through a Responder which coalesces it into chunked
+// response frames.
+func (c *ATPClient) HandleStreamingCompletions(handler StreamingCompletionHandler, config AdapterServerConfig) (*AdapterServer, error) {
+	return c.startAdapterServer(&AdapterServer{streamHandler: handler}, config)
+}
+
+// startAdapterServer registers s on the client and starts its workers
+func (c *ATPClient) startAdapterServer(s *AdapterServer, config AdapterServerConfig) (*AdapterServer, error) {
+	if c.closed() {
+		return nil, ErrClientClosed
+	}
+	if c.usingHTTP() {
+		return nil, ErrNotSupportedByTransport
+	}
+	if config.Workers <= 0 {
+		config.Workers = 8
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 64
+	}
+
+	s.client = c
+	s.config = config
+	s.builder = c.newFrameBuilder(c.config.TenantID)
+	s.ctx, s.cancel = context.WithCancel(c.ctx)
+	s.jobs = make(chan *adapterRequest, config.QueueSize)
+	s.requests = make(map[*adapterRequest]struct{})
+
+	c.handlerMutex.Lock()
+	if c.adapterServer != nil {
+		c.handlerMutex.Unlock()
+		s.cancel()
+		return nil, ErrHandlerRegistered
+	}
+	c.adapterServer = s
+	c.handlerMutex.Unlock()
+
+	for i := 0; i < config.Workers; i++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s, nil
+}
+
+// Close stops accepting requests, cancels running handlers and waits for
+// the workers to exit. It does not close the client.
+func (s *AdapterServer) Close() error {
+	s.stopAccepting()
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+// shutdownFlushTimeout bounds how long Shutdown waits for the error frames
+// answering abandoned requests to be written
+const shutdownFlushTimeout = 5 * time.Second
+
+// Shutdown gracefully stops the server and disconnects the client, e.g.
+// when the process is asked to terminate. It withdraws
+// AdapterServerConfig.AdapterID's capabilities so the router stops routing
+// requests here, stops accepting requests, then waits for queued and
+// running ones to be answered and their responses written. When ctx is
+// done first, the requests left are answered with ADAPTER_UNAVAILABLE
+// error frames, their handlers are cancelled and anything they send later
+// is dropped, and ctx's error is returned. The client is disconnected
+// either way; Close it, or Connect it again. A failed withdrawal is logged
+// and does not stop the shutdown.
+func (s *AdapterServer) Shutdown(ctx context.Context) error {
+	if s.config.AdapterID != "" && s.client.IsConnected() {
+		if err := s.client.WithdrawCapabilities(ctx, s.config.AdapterID); err != nil {
+			s.client.config.Logger.Printf("Warning: Failed to withdraw adapter %s: %v", s.config.AdapterID, err)
+		}
+	}
+	s.stopAccepting()
+
+	drained := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		s.abandonRequests()
+	}
+	s.cancel()
+	if disconnectErr := s.client.Disconnect(); err == nil {
+		err = disconnectErr
+	}
+	return err
+}
+
+// abandonRequests answers the requests not served yet with error frames,
+// waiting a while for them to be written
+func (s *AdapterServer) abandonRequests() {
+	s.requestsMu.Lock()
+	requests := make([]*adapterRequest, 0, len(s.requests))
+	for req := range s.requests {
+		requests = append(requests, req)
+	}
+	s.requestsMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+	defer cancel()
+	for _, req := range requests {
+		reply := s.builder.BuildErrorFrame(req.frame.StreamID, req.frame.MsgSeq, ErrorCodeAdapterUnavailable,
+			"adapter shut down before the request completed", Meta{Trace: req.frame.Meta.Trace})
+		if err := req.answer(ctx, s.client, reply, true); err != nil && !errors.Is(err, ErrAdapterServerClosed) {
+			s.client.reportAsyncError(fmt.Errorf("failed to send error frame: %w", err))
+		}
+	}
+}
+
+// stopAccepting closes the job queue and unregisters the server, so that
+// further requests are answered with ADAPTER_UNAVAILABLE
+func (s *AdapterServer) stopAccepting() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.jobs)
+	s.mu.Unlock()
+
+	s.client.handlerMutex.Lock()
+	if s.client.adapterServer == s {
+		s.client.adapterServer = nil
+	}
+	s.client.handlerMutex.Unlock()
+}
+
+// Stats returns a snapshot of the server's load and handler latencies
+func (s *AdapterServer) Stats() AdapterServerStats {
+	waiting := s.window.waitingCount()
+	stats := AdapterServerStats{
+		InFlight:      s.inFlight.Load(),
+		Queued:        len(s.jobs) + waiting,
+		WindowWaiting: waiting,
+		Served:        s.served.Load(),
+		Failed:        s.failed.Load(),
+	}
+	stats.P50Latency, stats.P95Latency, stats.P99Latency, stats.LatencySamples = s.latencies.percentiles()
+	return stats
+}
+
+// enqueue hands a request frame to the worker pool without blocking the
+// read loop
+func (s *AdapterServer) enqueue(frame Frame) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		s.sendError(frame, ErrorCodeAdapterUnavailable, ErrAdapterServerClosed.Error())
+		return
+	}
+	limit := 0
+	if s.config.WindowPolicy == WindowReject {
+		limit = frame.Window.MaxParallel
+	}
+	if s.config.AdapterID != "" {
+		if reason, paused := s.client.paused.get(s.config.AdapterID); paused {
+			message := "adapter is paused"
+			if reason != "" {
+				message += ": " + reason
+			}
+			s.sendError(frame, ErrorCodeAdapterPaused, message)
+			return
+		}
+	}
+	if !s.window.admit(limit) {
+		s.sendError(frame, ErrorCodeWindowExceeded, fmt.Sprintf("window of %d parallel requests exceeded", limit))
+		return
+	}
+	req := &adapterRequest{frame: frame}
+	s.track(req, true)
+	select {
+	case s.jobs <- req:
+	default:
+		s.track(req, false)
+		s.window.done()
+		s.sendError(frame, ErrorCodeAdapterOverloaded, "adapter request queue is full")
+	}
+}
+
+// worker serves queued requests until the server is closed
+func (s *AdapterServer) worker() {
+	defer s.wg.Done()
+	for req := range s.jobs {
+		s.serve(req)
+		s.window.done()
+		s.track(req, false)
+	}
+}
+
+// track adds req to the requests Shutdown answers if it runs out of time,
+// or removes it
+func (s *AdapterServer) track(req *adapterRequest, add bool) {
+	s.requestsMu.Lock()
+	defer s.requestsMu.Unlock()
+	if add {
+		s.requests[req] = struct{}{}
+	} else {
+		delete(s.requests, req)
+	}
+}
+
+// serve decodes one request, runs the handler and sends the reply
+func (s *AdapterServer) serve(req *adapterRequest) {
+	frame := req.frame
+	if s.ctx.Err() != nil {
+		s.reply(req, ErrorCodeAdapterUnavailable, ErrAdapterServerClosed.Error())
+		return
+	}
+	request, err := DecodePayload[CompletionRequest](frame)
+	if err != nil {
+		s.reply(req, ErrorCodeInvalidRequest, fmt.Sprintf("invalid completion request: %v", err))
+		return
+	}
+
+	ctx := s.ctx
+	if frame.TTL > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(frame.TTL)*time.Second)
+		defer cancel()
+	}
+
+	if !s.window.acquire(ctx, frame.Window.MaxParallel) {
+		if s.ctx.Err() != nil {
+			s.reply(req, ErrorCodeAdapterUnavailable, ErrAdapterServerClosed.Error())
+		} else {
+			s.reply(req, ErrorCodeWindowExceeded, "request expired waiting for a window slot")
+		}
+		return
+	}
+	defer s.window.release()
+
+	s.inFlight.Add(1)
+	clock := s.client.config.Clock
+	start := clock.Now()
+	var failed bool
+	defer func() {
+		s.latencies.observe(clock.Now().Sub(start))
+		s.inFlight.Add(-1)
+		s.served.Add(1)
+		if failed {
+			s.failed.Add(1)
+		}
+	}()
+
+	if s.streamHandler != nil {
+		failed = !s.serveStreaming(ctx, req, request)
+		return
+	}
+
+	response, err := s.invoke(ctx, request, frame.Meta)
+	if err != nil {
+		failed = true
+		s.sendHandlerError(req, err)
+		return
+	}
+
+	reply := s.builder.BuildCompletionResponseFrame(frame.StreamID, frame.MsgSeq, response, Meta{Trace: frame.Meta.Trace})
+	if err := req.answer(context.Background(), s.client, reply, true); err != nil && !errors.Is(err, ErrAdapterServerClosed) {
+		s.client.reportAsyncError(fmt.Errorf("failed to send completion response: %w", err))
+	}
+}
+
+// serveStreaming runs the streaming handler with a Responder. It reports
+// whether the handler succeeded.
+func (s *AdapterServer) serveStreaming(ctx context.Context, req *adapterRequest, request CompletionRequest) bool {
+	send := func(chunk Frame) error {
+		return req.answer(context.Background(), s.client, chunk, slices.Contains(chunk.Flags, FlagLast))
+	}
+	responder := newResponder(req.frame, s.builder, s.client.config.Clock, s.config.Chunking, s.client.config.DebugChecks,
+		send, s.client.counters.outboundQueued.Load)
+
+	err := s.invokeStreaming(ctx, request, req.frame.Meta, responder)
+	if responder.isFinished() {
+		return err == nil
+	}
+	if err != nil {
+		s.sendHandlerError(req, err)
+		return false
+	}
+	if err := responder.Finish(CompletionResponse{}); err != nil {
+		s.client.reportAsyncError(fmt.Errorf("failed to finish streamed response: %w", err))
+	}
+	return true
+}
+
+// invoke runs the handler, converting a panic into an *ATPError
+func (s *AdapterServer) invoke(ctx context.Context, request CompletionRequest, meta Meta) (response CompletionResponse, err error) {
+	defer s.recoverHandler(&err)
+	return s.handler(ctx, request, meta)
+}
+
+// invokeStreaming runs the streaming handler, converting a panic into an
+// *ATPError
+func (s *AdapterServer) invokeStreaming(ctx context.Context, request CompletionRequest, meta Meta, r *Responder) (err error) {
+	defer s.recoverHandler(&err)
+	return s.streamHandler(ctx, request, meta, r)
+}
+
+// recoverHandler converts a handler panic into an ADAPTER_PANIC error
+func (s *AdapterServer) recoverHandler(err *error) {
+	if r := recover(); r != nil {
+		s.client.config.Logger.Printf("Warning: completion handler panicked: %v\n%s", r, debug.Stack())
+		*err = &ATPError{Code: ErrorCodeAdapterPanic, Message: fmt.Sprintf("handler panicked: %v", r)}
+	}
+}
+
+// sendHandlerError answers the request with the handler's error, keeping
+// the code of an *ATPError
+func (s *AdapterServer) sendHandlerError(req *adapterRequest, err error) {
+	code, message := ErrorCodeAdapterError, err.Error()
+	var atpErr *ATPError
+	if errors.As(err, &atpErr) {
+		code, message = atpErr.Code, atpErr.Message
+	}
+	s.reply(req, code, message)
+}
+
+// reply answers an accepted request with an error frame
+func (s *AdapterServer) reply(req *adapterRequest, code, message string) {
+	reply := s.builder.BuildErrorFrame(req.frame.StreamID, req.frame.MsgSeq, code, message, Meta{Trace: req.frame.Meta.Trace})
+	if err := req.answer(context.Background(), s.client, reply, true); err != nil && !errors.Is(err, ErrAdapterServerClosed) {
+		s.client.reportAsyncError(fmt.Errorf("failed to send error frame: %w", err))
+	}
+}
+
+// sendError answers a request frame that was not accepted with an error
+// frame
+func (s *AdapterServer) sendError(frame Frame, code, message string) {
+	reply := s.builder.BuildErrorFrame(frame.StreamID, frame.MsgSeq, code, message, Meta{Trace: frame.Meta.Trace})
+	if err := s.client.sendFrame(reply); err != nil {
+		s.client.reportAsyncError(fmt.Errorf("failed to send error frame: %w", err))
+	}
+}
+
+// adapterWindow enforces the Window.MaxParallel of incoming requests
+type adapterWindow struct {
+	mu      sync.Mutex
+	pending int           // requests accepted and not yet answered
+	running int           // handlers holding a slot
+	waiting int           // requests waiting for a slot
+	freed   chan struct{} // closed and replaced when a slot is released
+}
+
+// admit accepts a request unless limit requests are already pending. A
+// limit of zero or less accepts every request.
+func (w *adapterWindow) admit(limit int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if limit > 0 && w.pending >= limit {
+		return false
+	}
+	w.pending++
+	return true
+}
+
+// done marks an admitted request answered
+func (w *adapterWindow) done() {
+	w.mu.Lock()
+	w.pending--
+	w.mu.Unlock()
+}
+
+// acquire waits until fewer than limit handlers hold a slot, then takes
+// one. It reports false if ctx is done first.
+func (w *adapterWindow) acquire(ctx context.Context, limit int) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for limit > 0 && w.running >= limit {
+		if w.freed == nil {
+			w.freed = make(chan struct{})
+		}
+		freed := w.freed
+		w.waiting++
+		w.mu.Unlock()
+		select {
+		case <-freed:
+		case <-ctx.Done():
+		}
+		w.mu.Lock()
+		w.waiting--
+		if ctx.Err() != nil {
+			return false
+		}
+	}
+	w.running++
+	return true
+}
+
+// release gives back a slot taken by acquire
+func (w *adapterWindow) release() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running--
+	if w.freed != nil {
+		close(w.freed)
+		w.freed = nil
+	}
+}
+
+// waitingCount returns the number of requests waiting for a slot
+func (w *adapterWindow) waitingCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.waiting
+}
+
+// adapterRequest is a request accepted by an AdapterServer. Its final reply
+// is sent once: either by its handler or, when Shutdown runs out of time,
+// as an error frame by Shutdown.
+type adapterRequest struct {
+	frame Frame
+
+	mu       sync.Mutex
+	answered bool
+}
+
+// answer sends a reply to the request, unless its final reply was already
+// sent, in which case it fails with ErrAdapterServerClosed. A final reply
+// is waited for until the connection's writer has written it.
+func (r *adapterRequest) answer(ctx context.Context, client *ATPClient, reply Frame, final bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.answered {
+		return ErrAdapterServerClosed
+	}
+	if !final {
+		return client.sendFrame(reply)
+	}
+	r.answered = true
+	return client.sendFrameWritten(ctx, reply)
+}
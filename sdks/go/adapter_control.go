@@ -0,0 +1,110 @@
+package atpsdk
+
+import (
+	"context"
+	"sync"
+)
+
+// FrameTypeAdapterControl pauses or resumes the routing of requests to an
+// adapter, whose "action" payload field is AdapterActionPause or
+// AdapterActionResume
+const FrameTypeAdapterControl = "adapter.control"
+
+// Actions of an adapter.control frame
+const (
+	AdapterActionPause  = "pause"
+	AdapterActionResume = "resume"
+)
+
+// ErrorCodeAdapterPaused answers the requests an AdapterServer receives
+// while its adapter is paused
+const ErrorCodeAdapterPaused = "ADAPTER_PAUSED"
+
+// HealthStatusPaused is the status health loops report for a paused adapter
+const HealthStatusPaused = "paused"
+
+// pausedAdapters is the set of adapters paused with PauseAdapter
+type pausedAdapters struct {
+	mu      sync.RWMutex
+	reasons map[string]string // by adapter ID
+}
+
+func (p *pausedAdapters) set(adapterID, reason string, paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !paused {
+		delete(p.reasons, adapterID)
+		return
+	}
+	if p.reasons == nil {
+		p.reasons = make(map[string]string)
+	}
+	p.reasons[adapterID] = reason
+}
+
+func (p *pausedAdapters) get(adapterID string) (string, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	reason, ok := p.reasons[adapterID]
+	return reason, ok
+}
+
+// PauseAdapter asks the router to stop routing requests to adapterID, for
+// maintenance, while keeping the session alive, and waits for the router to
+// acknowledge it. From the call on, an AdapterServer whose AdapterID is
+// adapterID answers the requests still reaching it with ADAPTER_PAUSED
+// error frames, and health loops report adapterID as "paused". The adapter
+// is paused until ResumeAdapter, or until the call fails.
+func (c *ATPClient) PauseAdapter(ctx context.Context, adapterID, reason string, opts ...RequestOption) error {
+	previous, wasPaused := c.paused.get(adapterID)
+	c.paused.set(adapterID, reason, true)
+	if err := c.sendAdapterControl(ctx, adapterID, AdapterActionPause, reason, opts); err != nil {
+		c.paused.set(adapterID, previous, wasPaused)
+		return err
+	}
+	return nil
+}
+
+// ResumeAdapter asks the router to route requests to adapterID again after
+// PauseAdapter, and waits for the router to acknowledge it. The adapter
+// serves requests again from the call on, and stays paused if it fails.
+func (c *ATPClient) ResumeAdapter(ctx context.Context, adapterID string, opts ...RequestOption) error {
+	reason, wasPaused := c.paused.get(adapterID)
+	c.paused.set(adapterID, "", false)
+	if err := c.sendAdapterControl(ctx, adapterID, AdapterActionResume, "", opts); err != nil {
+		c.paused.set(adapterID, reason, wasPaused)
+		return err
+	}
+	return nil
+}
+
+// IsAdapterPaused reports whether adapterID is paused with PauseAdapter
+func (c *ATPClient) IsAdapterPaused(adapterID string) bool {
+	_, paused := c.paused.get(adapterID)
+	return paused
+}
+
+// sendAdapterControl sends an adapter.control frame and waits for its ack
+func (c *ATPClient) sendAdapterControl(ctx context.Context, adapterID, action, reason string, opts []RequestOption) error {
+	if c.closed() {
+		return ErrClientClosed
+	}
+	options := c.requestOptions(opts)
+	if options.err != nil {
+		return options.err
+	}
+	correlationID := c.correlate(&options)
+	if options.tenant != "" {
+		ctx = ContextWithTenant(ctx, options.tenant)
+	}
+
+	builder := c.builderFor(ctx)
+	streamID := c.newStreamID("capability")
+	frame := builder.BuildAdapterControlFrame(streamID, adapterID, action, reason, options.meta...)
+	if options.qos != "" {
+		frame.QoS = options.qos
+	}
+	defer builder.ReleaseStream(streamID)
+
+	return correlateError(c.sendAdapterFrame(ctx, frame, options, "adapter "+action), correlationID)
+}
@@ -0,0 +1,101 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPauseAndResumeAdapter(t *testing.T) {
+	frames := make(chan Frame, 100)
+	fb := NewFrameBuilder("", "")
+	router := newTestRouter(t, func(f Frame) []Frame {
+		switch f.Type {
+		case FrameTypeHeartbeat:
+			return nil
+		case FrameTypeAdapterControl, FrameTypeHealth:
+			frames <- f
+			return []Frame{fb.BuildAckFrame(f.StreamID, f.MsgSeq)}
+		}
+		frames <- f
+		return nil
+	})
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Clock: clock, HeartbeatJitter: -1})
+	defer client.Close()
+	ctx := context.Background()
+
+	if err := client.PauseAdapter(ctx, "edge-1", "maintenance"); err != nil {
+		t.Fatalf("PauseAdapter failed: %v", err)
+	}
+	if pause := waitReply(t, frames); pause.Type != FrameTypeAdapterControl || pause.Payload["adapter_id"] != "edge-1" ||
+		pause.Payload["action"] != AdapterActionPause || pause.Payload["reason"] != "maintenance" {
+		t.Errorf("Unexpected pause frame %+v", pause)
+	}
+	if !client.IsAdapterPaused("edge-1") || client.IsAdapterPaused("edge-2") {
+		t.Error("Expected only edge-1 paused")
+	}
+
+	// Stray requests are rejected while paused
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		return CompletionResponse{Text: "served"}, nil
+	}, AdapterServerConfig{AdapterID: "edge-1"})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	defer server.Close()
+	if err := router.Send(completionRequestFrame("s1", 1, "hi")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	reply := waitReply(t, frames)
+	_, err = client.parseCompletionResponse(&reply)
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeAdapterPaused || atpErr.Message != "adapter is paused: maintenance" {
+		t.Errorf("Expected ADAPTER_PAUSED, got %v", err)
+	}
+
+	// Health loops report the pause
+	waiters := clock.Waiters()
+	loop, err := client.StartHealthLoop(ctx, "edge-1", 10*time.Second, func() HealthStatus {
+		return HealthStatus{Status: "healthy"}
+	})
+	if err != nil {
+		t.Fatalf("StartHealthLoop failed: %v", err)
+	}
+	defer loop.Stop()
+	waitForWaiters(t, clock, waiters+1)
+	clock.Advance(10 * time.Second)
+	if health := waitReply(t, frames); health.Payload["status"] != HealthStatusPaused {
+		t.Errorf("Expected a paused health report, got %v", health.Payload["status"])
+	}
+
+	if err := client.ResumeAdapter(ctx, "edge-1"); err != nil {
+		t.Fatalf("ResumeAdapter failed: %v", err)
+	}
+	if resume := waitReply(t, frames); resume.Payload["action"] != AdapterActionResume || resume.Payload["reason"] != nil {
+		t.Errorf("Unexpected resume frame %+v", resume)
+	}
+	if client.IsAdapterPaused("edge-1") {
+		t.Error("Expected edge-1 resumed")
+	}
+	if err := router.Send(completionRequestFrame("s2", 1, "hi")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if reply := waitReply(t, frames); reply.Type != FrameTypeCompletionResponse {
+		t.Errorf("Expected the request served after resuming, got %s", reply.Type)
+	}
+}
+
+func TestPauseAdapterRejected(t *testing.T) {
+	router := nackRouter(t, FrameTypeAdapterControl)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	if err := client.PauseAdapter(context.Background(), "edge-1", ""); !errors.Is(err, ErrNacked) {
+		t.Errorf("Expected a nack, got %v", err)
+	}
+	if client.IsAdapterPaused("edge-1") {
+		t.Error("Expected a rejected pause to leave the adapter running")
+	}
+}
@@ -0,0 +1,448 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// adapterTestRouter returns a router that forwards every frame the client
+// sends to the returned channel
+func adapterTestRouter(t *testing.T) (*testRouter, chan Frame) {
+	replies := make(chan Frame, 100)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "heartbeat" {
+			replies <- f
+		}
+		return nil
+	})
+	return router, replies
+}
+
+func connectedAdapterClient(t *testing.T, router *testRouter) *ATPClient {
+	t.Helper()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Logger: &recordingLogger{}})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	router.WaitForConnections(t, 1)
+	return client
+}
+
+func completionRequestFrame(streamID string, msgSeq int, prompt string) Frame {
+	frame := NewFrameBuilder("router", "tenant").BuildCompletionFrame(streamID, CompletionRequest{Prompt: prompt, MaxTokens: 10})
+	frame.MsgSeq = msgSeq
+	return frame
+}
+
+func waitReply(t *testing.T, replies chan Frame) Frame {
+	t.Helper()
+	select {
+	case f := <-replies:
+		return f
+	case <-time.After(2 * time.Second):
+		t.Fatal("No reply from the adapter")
+		return Frame{}
+	}
+}
+
+func TestHandleCompletionsResponds(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	client := connectedAdapterClient(t, router)
+
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("Expected handler context to carry the frame TTL deadline")
+		}
+		return CompletionResponse{Text: "echo: " + req.Prompt, ModelUsed: "echo-1", TokensOut: 3, Finished: true}, nil
+	}, AdapterServerConfig{})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	defer server.Close()
+
+	if err := router.Send(completionRequestFrame("stream-1", 7, "hello")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	reply := waitReply(t, replies)
+	if reply.Type != "completion_response" {
+		t.Fatalf("Expected completion_response, got %s: %v", reply.Type, reply.Payload)
+	}
+	if reply.StreamID != "stream-1" || reply.MsgSeq != 7 {
+		t.Errorf("Expected reply for stream-1:7, got %s:%d", reply.StreamID, reply.MsgSeq)
+	}
+	response, err := client.parseCompletionResponse(&reply)
+	if err != nil {
+		t.Fatalf("Reply did not parse: %v", err)
+	}
+	if response.Text != "echo: hello" || response.ModelUsed != "echo-1" || response.TokensOut != 3 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+}
+
+func TestHandleCompletionsErrorAndPanic(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	client := connectedAdapterClient(t, router)
+
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		switch req.Prompt {
+		case "panic":
+			panic("boom")
+		case "typed":
+			return CompletionResponse{}, &ATPError{Code: "MODEL_NOT_SERVED", Message: "no such model"}
+		default:
+			return CompletionResponse{}, errors.New("handler failed")
+		}
+	}, AdapterServerConfig{Workers: 1})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	defer server.Close()
+
+	cases := []struct {
+		prompt string
+		code   string
+	}{
+		{"panic", ErrorCodeAdapterPanic},
+		{"typed", "MODEL_NOT_SERVED"},
+		{"plain", ErrorCodeAdapterError},
+	}
+	for i, tc := range cases {
+		if err := router.Send(completionRequestFrame(tc.prompt, i+1, tc.prompt)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		reply := waitReply(t, replies)
+		if reply.Type != "error" || reply.StreamID != tc.prompt || reply.MsgSeq != i+1 {
+			t.Fatalf("Expected error frame for %s:%d, got %s %s:%d", tc.prompt, i+1, reply.Type, reply.StreamID, reply.MsgSeq)
+		}
+		_, err := client.parseCompletionResponse(&reply)
+		var atpErr *ATPError
+		if !errors.As(err, &atpErr) || atpErr.Code != tc.code {
+			t.Errorf("%s: expected code %s, got %v", tc.prompt, tc.code, err)
+		}
+	}
+
+	// The worker survives the panic and keeps serving
+	if err := router.Send(completionRequestFrame("after", 9, "plain")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if reply := waitReply(t, replies); reply.StreamID != "after" {
+		t.Errorf("Expected worker to keep serving after a panic, got %s", reply.StreamID)
+	}
+}
+
+func TestHandleCompletionsBoundedWorkers(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	client := connectedAdapterClient(t, router)
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		running.Add(-1)
+		return CompletionResponse{Text: req.Prompt}, nil
+	}, AdapterServerConfig{Workers: 2, QueueSize: 10})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	defer server.Close()
+
+	const requests = 6
+	for i := 0; i < requests; i++ {
+		if err := router.Send(completionRequestFrame(fmt.Sprintf("s%d", i), 1, "p")); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < requests; i++ {
+		if reply := waitReply(t, replies); reply.Type != "completion_response" {
+			t.Errorf("Expected completion_response, got %s", reply.Type)
+		}
+	}
+	if p := peak.Load(); p != 2 {
+		t.Errorf("Expected at most 2 concurrent handlers, peak was %d", p)
+	}
+}
+
+func TestHandleCompletionsQueueFull(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	client := connectedAdapterClient(t, router)
+
+	release := make(chan struct{})
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		<-release
+		return CompletionResponse{}, nil
+	}, AdapterServerConfig{Workers: 1, QueueSize: 1})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	defer server.Close()
+	defer close(release)
+
+	// One running, one queued, the third is rejected
+	for i := 0; i < 3; i++ {
+		if err := router.Send(completionRequestFrame(fmt.Sprintf("s%d", i), 1, "p")); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	reply := waitReply(t, replies)
+	_, err = client.parseCompletionResponse(&reply)
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeAdapterOverloaded || reply.StreamID != "s2" {
+		t.Errorf("Expected ADAPTER_OVERLOADED for s2, got %s: %v", reply.StreamID, err)
+	}
+}
+
+func TestHandleCompletionsSingleRegistration(t *testing.T) {
+	client := NewATPClient(SDKConfig{})
+	handler := func(context.Context, CompletionRequest, Meta) (CompletionResponse, error) {
+		return CompletionResponse{}, nil
+	}
+
+	server, err := client.HandleCompletions(handler, AdapterServerConfig{})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	if _, err := client.HandleCompletions(handler, AdapterServerConfig{}); !errors.Is(err, ErrHandlerRegistered) {
+		t.Errorf("Expected ErrHandlerRegistered, got %v", err)
+	}
+
+	server.Close()
+	second, err := client.HandleCompletions(handler, AdapterServerConfig{})
+	if err != nil {
+		t.Fatalf("Expected registration after Close to succeed, got %v", err)
+	}
+	second.Close()
+}
+
+func TestAdapterServerShutdown(t *testing.T) {
+	// The router acks the withdrawal and records the frames it receives
+	frames := make(chan Frame, 100)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type == "heartbeat" {
+			return nil
+		}
+		frames <- f
+		if f.Type == "adapter.capability.withdraw" {
+			return []Frame{NewFrameBuilder("", "").BuildAckFrame(f.StreamID, f.MsgSeq)}
+		}
+		return nil
+	})
+	client := connectedAdapterClient(t, router)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		close(started)
+		<-release
+		return CompletionResponse{Text: "done"}, ctx.Err()
+	}, AdapterServerConfig{AdapterID: "edge-1"})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	if err := router.Send(completionRequestFrame("s1", 1, "hi")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- server.Shutdown(context.Background()) }()
+
+	// The adapter is withdrawn before the running request finishes
+	withdraw := waitReply(t, frames)
+	if withdraw.Type != "adapter.capability.withdraw" || withdraw.Payload["adapter_id"] != "edge-1" {
+		t.Fatalf("Expected the withdrawal first, got %+v", withdraw)
+	}
+	close(release)
+	if reply := waitReply(t, frames); reply.Type != "completion_response" || reply.Payload["text"] != "done" {
+		t.Errorf("Expected the running request to be answered, got %+v", reply)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown failed: %v", err)
+	}
+	if client.IsConnected() {
+		t.Error("Expected Shutdown to disconnect the client")
+	}
+}
+
+func TestAdapterServerShutdownTimeout(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	client := connectedAdapterClient(t, router)
+
+	// The handler ignores cancellation, so only the timeout ends the drain
+	release := make(chan struct{})
+	defer close(release)
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		<-release
+		return CompletionResponse{Text: "late"}, nil
+	}, AdapterServerConfig{Workers: 1})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	for _, streamID := range []string{"s1", "s2"} {
+		if err := router.Send(completionRequestFrame(streamID, 1, "hi")); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	for server.Stats().InFlight == 0 || server.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the shutdown to time out, got %v", err)
+	}
+
+	// Both the running and the queued request are answered before the
+	// client disconnects
+	answered := map[string]bool{}
+	for range 2 {
+		reply := waitReply(t, replies)
+		_, err := client.parseCompletionResponse(&reply)
+		var atpErr *ATPError
+		if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeAdapterUnavailable {
+			t.Errorf("Expected ADAPTER_UNAVAILABLE, got %s: %v", reply.StreamID, err)
+		}
+		answered[reply.StreamID] = true
+	}
+	if !answered["s1"] || !answered["s2"] {
+		t.Errorf("Expected s1 and s2 answered, got %v", answered)
+	}
+	if client.IsConnected() {
+		t.Error("Expected Shutdown to disconnect the client")
+	}
+}
+
+func TestHandleCompletionsHonorsWindow(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	client := connectedAdapterClient(t, router)
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		running.Add(-1)
+		return CompletionResponse{Text: req.Prompt}, nil
+	}, AdapterServerConfig{Workers: 8})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	defer server.Close()
+
+	const requests = 5
+	for i := 0; i < requests; i++ {
+		frame := completionRequestFrame(fmt.Sprintf("s%d", i), 1, "p")
+		frame.Window.MaxParallel = 2
+		if err := router.Send(frame); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for server.Stats().WindowWaiting != requests-2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := server.Stats(); stats.InFlight != 2 || stats.Queued != requests-2 {
+		t.Errorf("Expected 2 in flight and %d queued, got %+v", requests-2, stats)
+	}
+	close(release)
+
+	for i := 0; i < requests; i++ {
+		if reply := waitReply(t, replies); reply.Type != "completion_response" {
+			t.Errorf("Expected completion_response, got %s", reply.Type)
+		}
+	}
+	if p := peak.Load(); p != 2 {
+		t.Errorf("Expected at most 2 concurrent handlers, peak was %d", p)
+	}
+}
+
+func TestHandleCompletionsWindowReject(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	client := connectedAdapterClient(t, router)
+
+	release := make(chan struct{})
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		<-release
+		return CompletionResponse{}, nil
+	}, AdapterServerConfig{WindowPolicy: WindowReject})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	defer server.Close()
+	defer close(release)
+
+	for i := 0; i < 2; i++ {
+		frame := completionRequestFrame(fmt.Sprintf("s%d", i), 1, "p")
+		frame.Window.MaxParallel = 1
+		if err := router.Send(frame); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	reply := waitReply(t, replies)
+	_, err = client.parseCompletionResponse(&reply)
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeWindowExceeded || reply.StreamID != "s1" {
+		t.Errorf("Expected WINDOW_EXCEEDED for s1, got %s: %v", reply.StreamID, err)
+	}
+}
+
+func TestHandleCompletionsWindowExpiry(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	client := connectedAdapterClient(t, router)
+
+	release := make(chan struct{})
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		<-release
+		return CompletionResponse{}, nil
+	}, AdapterServerConfig{})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	defer server.Close()
+	defer close(release)
+
+	// The second request's TTL runs out while the first holds the window
+	for i, ttl := range []int{0, 1} {
+		frame := completionRequestFrame(fmt.Sprintf("s%d", i), 1, "p")
+		frame.Window.MaxParallel = 1
+		frame.TTL = ttl
+		if err := router.Send(frame); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		for server.Stats().InFlight == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	reply := waitReply(t, replies)
+	_, err = client.parseCompletionResponse(&reply)
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeWindowExceeded || reply.StreamID != "s1" {
+		t.Errorf("Expected WINDOW_EXCEEDED for s1, got %s: %v", reply.StreamID, err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package atpmetrics exports ATP SDK metrics to Prometheus. Sink implements
+// atpsdk.MetricsSink:
+//
+//	sink, err := atpmetrics.New(prometheus.DefaultRegisterer)
+//	if err != nil {
+//		return err
+//	}
+//	client := atpsdk.NewATPClient(atpsdk.SDKConfig{Metrics: sink})
+//
+// The metric names are stable:
+//
+//	atp_frames_sent_total{frame_type}                      counter
+//	atp_frames_received_total{frame_type}                  counter
+//	atp_request_duration_seconds{frame_type,outcome}       histogram
+//	atp_reconnects_total                                   counter
+//	atp_pending_requests                                   gauge
+//	atp_cost_usd_total                                     counter
+//	atp_subscription_dropped_frames_total{frame_type}      counter
+//	atp_health_report_failures_total{adapter_id}           counter
+//	atp_frame_decode_failures_total{reason}                counter
+//
+// See the matching atpsdk.Metric constants for their meaning. Metrics the
+// SDK reports under other names are ignored.
+package atpmetrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+)
+
+// DurationBuckets are the buckets of atp_request_duration_seconds, from 5ms
+// to a minute
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Sink is an atpsdk.MetricsSink recording into Prometheus collectors. It is
+// safe for concurrent use.
+type Sink struct {
+	counters   map[string]labeled[*prometheus.CounterVec]
+	gauges     map[string]labeled[*prometheus.GaugeVec]
+	histograms map[string]labeled[*prometheus.HistogramVec]
+}
+
+// labeled is a collector with the label names it is partitioned by, in
+// order
+type labeled[V any] struct {
+	vec    V
+	labels []string
+}
+
+// New returns a Sink whose collectors are registered with registerer. It
+// fails when one of them is already registered, e.g. by another Sink on
+// the same registerer.
+func New(registerer prometheus.Registerer) (*Sink, error) {
+	s := &Sink{
+		counters:   make(map[string]labeled[*prometheus.CounterVec]),
+		gauges:     make(map[string]labeled[*prometheus.GaugeVec]),
+		histograms: make(map[string]labeled[*prometheus.HistogramVec]),
+	}
+	counter := func(name, help string, labels ...string) {
+		s.counters[name] = labeled[*prometheus.CounterVec]{
+			vec:    prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels),
+			labels: labels,
+		}
+	}
+	counter(atpsdk.MetricFramesSent, "Frames written to the router.", "frame_type")
+	counter(atpsdk.MetricFramesReceived, "Frames read from the router.", "frame_type")
+	counter(atpsdk.MetricReconnects, "Connections established after the first.")
+	counter(atpsdk.MetricCostUSD, "Cost reported in completion responses, in USD.")
+	counter(atpsdk.MetricSubscriptionDrops, "Frames dropped because a subscriber's buffer was full.", "frame_type")
+	counter(atpsdk.MetricHealthReportFailures, "Health reports that could not be sent.", "adapter_id")
+	counter(atpsdk.MetricDecodeFailures, "Received messages dropped because they could not be decoded.", "reason")
+
+	labels := []string{}
+	s.gauges[atpsdk.MetricPendingRequests] = labeled[*prometheus.GaugeVec]{
+		vec: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: atpsdk.MetricPendingRequests,
+			Help: "Requests waiting for a response.",
+		}, labels),
+		labels: labels,
+	}
+
+	labels = []string{"frame_type", "outcome"}
+	s.histograms[atpsdk.MetricRequestDuration] = labeled[*prometheus.HistogramVec]{
+		vec: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    atpsdk.MetricRequestDuration,
+			Help:    "Seconds from sending a request to its outcome.",
+			Buckets: DurationBuckets,
+		}, labels),
+		labels: labels,
+	}
+
+	for _, c := range s.counters {
+		if err := registerer.Register(c.vec); err != nil {
+			return nil, err
+		}
+	}
+	for _, g := range s.gauges {
+		if err := registerer.Register(g.vec); err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range s.histograms {
+		if err := registerer.Register(h.vec); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// IncCounter adds delta to the named counter
+func (s *Sink) IncCounter(name string, delta float64, labels map[string]string) {
+	if c, ok := s.counters[name]; ok && delta >= 0 {
+		c.vec.WithLabelValues(labelValues(c.labels, labels)...).Add(delta)
+	}
+}
+
+// SetGauge sets the named gauge to value
+func (s *Sink) SetGauge(name string, value float64, labels map[string]string) {
+	if g, ok := s.gauges[name]; ok {
+		g.vec.WithLabelValues(labelValues(g.labels, labels)...).Set(value)
+	}
+}
+
+// ObserveHistogram records value in the named histogram
+func (s *Sink) ObserveHistogram(name string, value float64, labels map[string]string) {
+	if h, ok := s.histograms[name]; ok {
+		h.vec.WithLabelValues(labelValues(h.labels, labels)...).Observe(value)
+	}
+}
+
+// labelValues returns the values of names in labels, in order. Missing
+// labels are empty and labels not in names are dropped, so the values
+// always match the collector's partitioning.
+func labelValues(names []string, labels map[string]string) []string {
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = labels[name]
+	}
+	return values
+}
+
+var _ atpsdk.MetricsSink = (*Sink)(nil)
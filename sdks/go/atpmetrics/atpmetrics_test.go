@@ -0,0 +1,85 @@
+package atpmetrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+	"github.com/atp-project/atp-go-sdk/atpsdktest"
+)
+
+func TestSinkRecordsClientMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	sink, err := New(registry)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	router := atpsdktest.NewMockRouter(t)
+	router.OnType("completion_request", func(f atpsdk.Frame) []atpsdk.Frame {
+		return []atpsdk.Frame{atpsdktest.Reply(f, "completion_response", map[string]interface{}{
+			"text":     "ok",
+			"cost_usd": 0.25,
+		})}
+	})
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{WSURL: router.URL(), Metrics: sink})
+	defer client.Close()
+
+	for range 2 {
+		if _, err := client.Complete(context.Background(), atpsdk.CompletionRequest{Prompt: "hi"}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+	}
+
+	if got := testutil.ToFloat64(sink.counters[atpsdk.MetricFramesSent].vec.WithLabelValues("completion_request")); got != 2 {
+		t.Errorf("Expected 2 completion requests sent, got %v", got)
+	}
+	if got := testutil.ToFloat64(sink.counters[atpsdk.MetricFramesReceived].vec.WithLabelValues("completion_response")); got != 2 {
+		t.Errorf("Expected 2 completion responses received, got %v", got)
+	}
+	if got := testutil.ToFloat64(sink.counters[atpsdk.MetricCostUSD].vec.WithLabelValues()); got != 0.5 {
+		t.Errorf("Expected a cost of 0.5 USD, got %v", got)
+	}
+	if got := testutil.ToFloat64(sink.gauges[atpsdk.MetricPendingRequests].vec.WithLabelValues()); got != 0 {
+		t.Errorf("Expected no pending requests, got %v", got)
+	}
+	// Only the ok outcome was observed
+	if n := testutil.CollectAndCount(registry, atpsdk.MetricRequestDuration); n != 1 {
+		t.Errorf("Expected one request duration series, got %d", n)
+	}
+	histograms := sink.histograms[atpsdk.MetricRequestDuration].vec
+	if !histograms.DeleteLabelValues("completion_request", "ok") {
+		t.Error("Expected the duration of the ok requests to be observed")
+	}
+}
+
+func TestSinkIgnoresUnknownMetricsAndLabels(t *testing.T) {
+	sink, err := New(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	sink.IncCounter("custom_total", 1, nil)
+	sink.IncCounter(atpsdk.MetricReconnects, 1, map[string]string{"unexpected": "label"})
+	sink.IncCounter(atpsdk.MetricFramesSent, 1, nil)
+
+	if got := testutil.ToFloat64(sink.counters[atpsdk.MetricReconnects].vec.WithLabelValues()); got != 1 {
+		t.Errorf("Expected 1 reconnect, got %v", got)
+	}
+	if got := testutil.ToFloat64(sink.counters[atpsdk.MetricFramesSent].vec.WithLabelValues("")); got != 1 {
+		t.Errorf("Expected a frame without type to be counted, got %v", got)
+	}
+}
+
+func TestNewFailsOnDuplicateRegistration(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	if _, err := New(registry); err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, err := New(registry); err == nil {
+		t.Error("Expected a second Sink on the same registerer to fail")
+	}
+}
@@ -0,0 +1,391 @@
+// Package atpsdktest provides a scriptable in-process ATP Router for testing
+// code built on the SDK:
+//
+//	router := atpsdktest.NewMockRouter(t)
+//	router.OnType("completion_request", func(f atpsdk.Frame) []atpsdk.Frame {
+//		return []atpsdk.Frame{atpsdktest.Reply(f, "completion_response", map[string]interface{}{"text": "hi"})}
+//	})
+//
+//	client := atpsdk.NewATPClient(atpsdk.SDKConfig{WSURL: router.URL()})
+//	defer client.Close()
+//	...
+//	router.ExpectFrames(t, atpsdk.Frame{Type: "completion_request"})
+package atpsdktest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+)
+
+// DefaultTimeout is how long the wait helpers of a MockRouter wait when its
+// Timeout is unset
+const DefaultTimeout = 2 * time.Second
+
+// ErrNoConnections is returned by Send when no client is connected
+var ErrNoConnections = errors.New("atpsdktest: no open connections")
+
+// Handler returns the frames the router sends back for a frame it received
+type Handler func(frame atpsdk.Frame) []atpsdk.Frame
+
+// MockRouter is a WebSocket server standing in for the ATP Router. It
+// records every frame it receives and answers them with the handlers
+// registered with OnType; frames without a handler get no reply. It
+// answers session.hello with an empty session.welcome until OnType replaces
+// that handler.
+type MockRouter struct {
+	// Timeout bounds WaitForConnections and ExpectFrames. Defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+
+	server *httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	delays   map[string]time.Duration
+	received []atpsdk.Frame
+	conns    []*mockConn
+	accepted int
+	changed  chan struct{}
+}
+
+// mockConn serializes writes to one accepted connection
+type mockConn struct {
+	conn *websocket.Conn
+	wmu  sync.Mutex
+}
+
+func (c *mockConn) write(frame atpsdk.Frame) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.conn.WriteJSON(frame)
+}
+
+// NewMockRouter starts a mock router that is shut down when the test ends
+func NewMockRouter(t testing.TB) *MockRouter {
+	t.Helper()
+
+	m := &MockRouter{
+		handlers: map[string]Handler{atpsdk.FrameTypeSessionHello: welcome},
+		delays:   make(map[string]time.Duration),
+		changed:  make(chan struct{}),
+	}
+	upgrader := websocket.Upgrader{}
+	m.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		mc := &mockConn{conn: conn}
+		m.mu.Lock()
+		m.conns = append(m.conns, mc)
+		m.accepted++
+		m.notifyLocked()
+		m.mu.Unlock()
+		defer m.remove(mc)
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var frame atpsdk.Frame
+			if err := json.Unmarshal(data, &frame); err != nil {
+				continue
+			}
+			m.handle(mc, frame)
+		}
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+// URL returns the ws:// URL to use as SDKConfig.WSURL
+func (m *MockRouter) URL() string {
+	return "ws" + strings.TrimPrefix(m.server.URL, "http")
+}
+
+// OnType registers the handler answering frames of frameType, replacing
+// any earlier one. A nil handler removes it.
+func (m *MockRouter) OnType(frameType string, handler Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if handler == nil {
+		delete(m.handlers, frameType)
+		return
+	}
+	m.handlers[frameType] = handler
+}
+
+// Delay holds back the replies to frames of frameType by d. Frames arriving
+// meanwhile are still handled, so delayed replies may overtake each other.
+// A zero d removes the delay.
+func (m *MockRouter) Delay(frameType string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if d <= 0 {
+		delete(m.delays, frameType)
+		return
+	}
+	m.delays[frameType] = d
+}
+
+// Send pushes an unsolicited frame to every open connection
+func (m *MockRouter) Send(frame atpsdk.Frame) error {
+	m.mu.Lock()
+	conns := append([]*mockConn(nil), m.conns...)
+	m.mu.Unlock()
+
+	if len(conns) == 0 {
+		return ErrNoConnections
+	}
+	for _, mc := range conns {
+		if err := mc.write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropConnections closes every open connection, as a router restart would.
+// Clients may reconnect afterwards.
+func (m *MockRouter) DropConnections() {
+	m.mu.Lock()
+	conns := m.conns
+	m.conns = nil
+	m.notifyLocked()
+	m.mu.Unlock()
+
+	for _, mc := range conns {
+		_ = mc.conn.Close()
+	}
+}
+
+// Connections returns the number of open connections
+func (m *MockRouter) Connections() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.conns)
+}
+
+// Received returns every frame received so far, heartbeats included, in
+// arrival order
+func (m *MockRouter) Received() []atpsdk.Frame {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]atpsdk.Frame(nil), m.received...)
+}
+
+// ReceivedOfType returns the frames of frameType received so far
+func (m *MockRouter) ReceivedOfType(frameType string) []atpsdk.Frame {
+	var frames []atpsdk.Frame
+	for _, frame := range m.Received() {
+		if frame.Type == frameType {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+// WaitForConnections waits until at least n connections have been accepted
+// in total, and fails the test if that does not happen within Timeout
+func (m *MockRouter) WaitForConnections(t testing.TB, n int) {
+	t.Helper()
+	ok := m.waitFor(func() bool { return m.accepted >= n })
+	if !ok {
+		t.Fatalf("atpsdktest: router did not accept %d connection(s)", n)
+	}
+}
+
+// ExpectFrames waits until the router has received frames matching want, in
+// order, and fails the test if that does not happen within Timeout. Other
+// frames, such as heartbeats, may arrive in between.
+//
+// A received frame matches a wanted one when their types are equal and
+// every other field set in the wanted frame is equal too. Payload keys
+// and the Meta.Trace fields are compared one by one, so only the ones that
+// matter need to be given.
+func (m *MockRouter) ExpectFrames(t testing.TB, want ...atpsdk.Frame) {
+	t.Helper()
+	ok := m.waitFor(func() bool { return matchInOrder(m.received, want) == len(want) })
+	if ok {
+		return
+	}
+
+	received := m.Received()
+	matched := matchInOrder(received, want)
+	var types []string
+	for _, frame := range received {
+		types = append(types, frame.Type)
+	}
+	got, _ := json.Marshal(want[matched])
+	t.Fatalf("atpsdktest: expected frame %d was not received: %s\nreceived types: %s",
+		matched, got, strings.Join(types, ", "))
+}
+
+// Close drops all connections and shuts the server down
+func (m *MockRouter) Close() {
+	m.DropConnections()
+	m.server.Close()
+}
+
+// Reply builds a frame answering request on its stream
+func Reply(request atpsdk.Frame, frameType string, payload map[string]interface{}) atpsdk.Frame {
+	return atpsdk.Frame{
+		Type:      frameType,
+		StreamID:  request.StreamID,
+		MsgSeq:    request.MsgSeq,
+		Timestamp: atpsdk.FrameTimeOf(time.Now()),
+		Payload:   payload,
+	}
+}
+
+// welcome accepts a session handshake
+func welcome(hello atpsdk.Frame) []atpsdk.Frame {
+	return []atpsdk.Frame{Reply(hello, atpsdk.FrameTypeSessionWelcome, map[string]interface{}{})}
+}
+
+// handle records frame and sends the replies of its handler
+func (m *MockRouter) handle(mc *mockConn, frame atpsdk.Frame) {
+	m.mu.Lock()
+	m.received = append(m.received, frame)
+	m.notifyLocked()
+	handler, delay := m.handlers[frame.Type], m.delays[frame.Type]
+	m.mu.Unlock()
+
+	if handler == nil {
+		return
+	}
+	replies := handler(frame)
+	send := func() {
+		for _, reply := range replies {
+			if err := mc.write(reply); err != nil {
+				return
+			}
+		}
+	}
+	if delay > 0 {
+		time.AfterFunc(delay, send)
+		return
+	}
+	send()
+}
+
+// remove forgets a connection once its read loop ends
+func (m *MockRouter) remove(mc *mockConn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, open := range m.conns {
+		if open == mc {
+			m.conns = append(m.conns[:i], m.conns[i+1:]...)
+			m.notifyLocked()
+			break
+		}
+	}
+}
+
+// notifyLocked wakes the wait helpers. The caller holds mu.
+func (m *MockRouter) notifyLocked() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// waitFor waits until cond, evaluated under mu, holds or Timeout passes
+func (m *MockRouter) waitFor(cond func() bool) bool {
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		m.mu.Lock()
+		ok, changed := cond(), m.changed
+		m.mu.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+// matchInOrder returns how many frames of want appear in received, in order
+func matchInOrder(received, want []atpsdk.Frame) int {
+	matched := 0
+	for _, frame := range received {
+		if matched == len(want) {
+			break
+		}
+		if frameMatches(frame, want[matched]) {
+			matched++
+		}
+	}
+	return matched
+}
+
+// frameMatches reports whether got has every field set in want
+func frameMatches(got, want atpsdk.Frame) bool {
+	if got.Type != want.Type ||
+		(want.StreamID != "" && got.StreamID != want.StreamID) ||
+		(want.MsgSeq != 0 && got.MsgSeq != want.MsgSeq) ||
+		(want.Meta.TaskType != "" && got.Meta.TaskType != want.Meta.TaskType) {
+		return false
+	}
+	if !subset(got.Payload, want.Payload) {
+		return false
+	}
+	return traceMatches(got.Meta.Trace, want.Meta.Trace)
+}
+
+// traceMatches reports whether got has every field set in want
+func traceMatches(got, want *atpsdk.TraceContext) bool {
+	if want == nil {
+		return true
+	}
+	if got == nil {
+		return false
+	}
+	return (want.TraceParent == "" || got.TraceParent == want.TraceParent) &&
+		(want.TraceState == "" || got.TraceState == want.TraceState)
+}
+
+// subset reports whether every key of want has an equal value in got.
+// Values are compared in their JSON form, as received frames hold them.
+func subset(got, want map[string]interface{}) bool {
+	for key, value := range want {
+		actual, ok := got[key]
+		if !ok || !reflect.DeepEqual(actual, normalize(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize converts value to what decoding its JSON form yields, so that
+// for example an int compares equal to a received float64
+func normalize(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
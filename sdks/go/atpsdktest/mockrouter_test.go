@@ -0,0 +1,157 @@
+package atpsdktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+)
+
+func echoCompletions(router *MockRouter) {
+	router.OnType("completion_request", func(f atpsdk.Frame) []atpsdk.Frame {
+		return []atpsdk.Frame{Reply(f, "completion_response", map[string]interface{}{
+			"text":       "echo: " + f.Payload["prompt"].(string),
+			"model_used": "mock-model",
+			"tokens_in":  2,
+		})}
+	})
+}
+
+func TestMockRouterScriptedResponses(t *testing.T) {
+	router := NewMockRouter(t)
+	echoCompletions(router)
+
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), atpsdk.CompletionRequest{Prompt: "hi", MaxTokens: 16})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.Text != "echo: hi" || response.ModelUsed != "mock-model" || response.TokensIn != 2 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	router.ExpectFrames(t, atpsdk.Frame{Type: "completion_request", Payload: map[string]interface{}{"prompt": "hi", "max_tokens": 16}})
+	if got := router.ReceivedOfType("completion_request"); len(got) != 1 || got[0].StreamID == "" {
+		t.Errorf("Expected one recorded completion request, got %+v", got)
+	}
+}
+
+func TestMockRouterWelcomesSessions(t *testing.T) {
+	router := NewMockRouter(t)
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{WSURL: router.URL(), SessionHandshake: true})
+	defer client.Close()
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if client.SessionWelcome() == nil {
+		t.Error("Expected the session to be welcomed")
+	}
+	router.ExpectFrames(t, atpsdk.Frame{Type: atpsdk.FrameTypeSessionHello})
+}
+
+func TestMockRouterDelay(t *testing.T) {
+	router := NewMockRouter(t)
+	echoCompletions(router)
+	router.Delay("completion_request", 500*time.Millisecond)
+
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	_, err := client.Complete(context.Background(), atpsdk.CompletionRequest{Prompt: "slow"}, atpsdk.WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the delayed reply to time out, got %v", err)
+	}
+
+	router.Delay("completion_request", 0)
+	if _, err := client.Complete(context.Background(), atpsdk.CompletionRequest{Prompt: "fast"}); err != nil {
+		t.Fatalf("Complete failed after removing the delay: %v", err)
+	}
+}
+
+func TestMockRouterUnsolicitedFramesAndDrops(t *testing.T) {
+	router := NewMockRouter(t)
+	if err := router.Send(atpsdk.Frame{Type: "custom.event"}); !errors.Is(err, ErrNoConnections) {
+		t.Errorf("Expected ErrNoConnections before any client connected, got %v", err)
+	}
+
+	disconnected := make(chan error, 1)
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{
+		WSURL:        router.URL(),
+		OnDisconnect: func(err error) { disconnected <- err },
+	})
+	defer client.Close()
+	events, unsubscribe := client.Subscribe("custom.event")
+	defer unsubscribe()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	router.WaitForConnections(t, 1)
+
+	if err := router.Send(atpsdk.Frame{Type: "custom.event", Payload: map[string]interface{}{"n": 1}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case f := <-events:
+		if f.Payload["n"] != 1.0 {
+			t.Errorf("Unexpected event: %+v", f)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Unsolicited frame not delivered")
+	}
+
+	router.DropConnections()
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Client did not notice the dropped connection")
+	}
+	if router.Connections() != 0 {
+		t.Errorf("Expected no open connections, got %d", router.Connections())
+	}
+}
+
+// fatalRecorder captures the failure of a helper under test
+type fatalRecorder struct {
+	testing.TB
+	failure string
+}
+
+func (r *fatalRecorder) Helper() {}
+
+func (r *fatalRecorder) Fatalf(format string, args ...interface{}) {
+	r.failure = fmt.Sprintf(format, args...)
+}
+
+func TestExpectFramesReportsMissingFrame(t *testing.T) {
+	router := NewMockRouter(t)
+	router.Timeout = 100 * time.Millisecond
+	echoCompletions(router)
+
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	if _, err := client.Complete(context.Background(), atpsdk.CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	recorder := &fatalRecorder{TB: t}
+	router.ExpectFrames(recorder,
+		atpsdk.Frame{Type: "completion_request"},
+		atpsdk.Frame{Type: "adapter.health"},
+	)
+	if !strings.Contains(recorder.failure, "expected frame 1") || !strings.Contains(recorder.failure, "adapter.health") {
+		t.Errorf("Unexpected failure message: %q", recorder.failure)
+	}
+
+	recorder.failure = ""
+	router.ExpectFrames(recorder, atpsdk.Frame{Type: "completion_request", Payload: map[string]interface{}{"prompt": "bye"}})
+	if recorder.failure == "" {
+		t.Error("Expected a payload mismatch to fail")
+	}
+}
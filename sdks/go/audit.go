@@ -0,0 +1,190 @@
+package atpsdk
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// AuditDirection indicates whether an audited frame was sent or received
+type AuditDirection string
+
+const (
+	AuditOutbound AuditDirection = "outbound"
+	AuditInbound  AuditDirection = "inbound"
+)
+
+// Audit rule names used as keys in AuditSampler.Counts
+const (
+	AuditRuleErrorFrame       = "error_frame"
+	AuditRuleFlaggedTenant    = "flagged_tenant"
+	AuditRuleFlaggedRequest   = "flagged_request"
+	AuditRuleLatencyThreshold = "latency_threshold"
+	AuditRuleBaseRate         = "base_rate"
+)
+
+// AuditRecord is a single frame handed to an AuditSink
+type AuditRecord struct {
+	Direction AuditDirection
+	Time      time.Time
+	TenantID  string
+	// RequestID groups all frames belonging to one request. The client uses
+	// the frame's stream ID.
+	RequestID string
+	// Latency is the time since the request was sent, for inbound frames
+	// that answer a pending request. Zero otherwise.
+	Latency time.Duration
+	Frame   Frame
+	// Rule is the sampling rule that caused the record to be captured. It is
+	// empty when the sink is used without an AuditSampler.
+	Rule string
+}
+
+// AuditSink receives audited frames
+type AuditSink interface {
+	Record(record AuditRecord)
+}
+
+// AuditSinkFunc adapts a function to the AuditSink interface
+type AuditSinkFunc func(record AuditRecord)
+
+// Record calls f(record)
+func (f AuditSinkFunc) Record(record AuditRecord) {
+	f(record)
+}
+
+// AuditSamplingConfig configures an AuditSampler
+type AuditSamplingConfig struct {
+	// DefaultRate is the base sample rate (0..1) for frame types without an
+	// entry in Rates.
+	DefaultRate float64
+	// Rates overrides the base sample rate per frame type.
+	Rates map[string]float64
+	// CaptureErrors captures every error frame regardless of rate.
+	CaptureErrors bool
+	// CaptureTenants captures every frame for the listed tenants.
+	CaptureTenants []string
+	// CaptureRequestIDs captures every frame for the listed request IDs.
+	CaptureRequestIDs []string
+	// LatencyThreshold captures every frame whose latency exceeds it. Zero
+	// disables the rule.
+	LatencyThreshold time.Duration
+}
+
+// AuditRuleCounts holds the per-rule capture statistics of an AuditSampler
+type AuditRuleCounts struct {
+	Captured   uint64 `json:"captured"`
+	SampledOut uint64 `json:"sampled_out"`
+}
+
+// AuditSampler is an AuditSink that forwards a sample of records to another
+// sink. Always-capture rules are checked first; remaining records are
+// sampled by a hash of their request ID so that every frame of a sampled
+// request is captured together.
+type AuditSampler struct {
+	sink     AuditSink
+	config   AuditSamplingConfig
+	tenants  map[string]bool
+	requests map[string]bool
+
+	mu     sync.Mutex
+	counts map[string]*AuditRuleCounts
+}
+
+// NewAuditSampler creates a sampler forwarding captured records to sink
+func NewAuditSampler(sink AuditSink, config AuditSamplingConfig) *AuditSampler {
+	s := &AuditSampler{
+		sink:     sink,
+		config:   config,
+		tenants:  make(map[string]bool, len(config.CaptureTenants)),
+		requests: make(map[string]bool, len(config.CaptureRequestIDs)),
+		counts:   make(map[string]*AuditRuleCounts),
+	}
+	for _, tenant := range config.CaptureTenants {
+		s.tenants[tenant] = true
+	}
+	for _, requestID := range config.CaptureRequestIDs {
+		s.requests[requestID] = true
+	}
+	return s
+}
+
+// Record applies the sampling rules and forwards the record if captured
+func (s *AuditSampler) Record(record AuditRecord) {
+	rule, captured := s.decide(record)
+
+	s.mu.Lock()
+	counts, ok := s.counts[rule]
+	if !ok {
+		counts = &AuditRuleCounts{}
+		s.counts[rule] = counts
+	}
+	if captured {
+		counts.Captured++
+	} else {
+		counts.SampledOut++
+	}
+	s.mu.Unlock()
+
+	if captured {
+		record.Rule = rule
+		s.sink.Record(record)
+	}
+}
+
+// decide returns the rule that applies to record and whether it is captured
+func (s *AuditSampler) decide(record AuditRecord) (string, bool) {
+	switch {
+	case s.config.CaptureErrors && record.Frame.Type == FrameTypeError:
+		return AuditRuleErrorFrame, true
+	case s.tenants[record.TenantID]:
+		return AuditRuleFlaggedTenant, true
+	case s.requests[record.RequestID]:
+		return AuditRuleFlaggedRequest, true
+	case s.config.LatencyThreshold > 0 && record.Latency > s.config.LatencyThreshold:
+		return AuditRuleLatencyThreshold, true
+	}
+
+	rate, ok := s.config.Rates[record.Frame.Type]
+	if !ok {
+		rate = s.config.DefaultRate
+	}
+	rule := AuditRuleBaseRate + ":" + record.Frame.Type
+	return rule, sampleKey(record.RequestID, rate)
+}
+
+// Counts returns a snapshot of captured and sampled-out counts per rule.
+// Base-rate rules are keyed "base_rate:<frame type>".
+func (s *AuditSampler) Counts() map[string]AuditRuleCounts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string]AuditRuleCounts, len(s.counts))
+	for rule, counts := range s.counts {
+		out[rule] = *counts
+	}
+	return out
+}
+
+// sampleKey deterministically maps key into [0, 1) and compares it to rate,
+// so the same key is always either sampled or not for a given rate.
+func sampleKey(key string, rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	if rate >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+
+	// FNV alone distributes similar keys poorly in the high bits, so mix
+	// the hash with the splitmix64 finalizer before scaling it.
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return float64(x>>11)/float64(1<<53) < rate
+}
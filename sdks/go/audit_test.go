@@ -0,0 +1,213 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+type collectingSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (s *collectingSink) Record(record AuditRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+}
+
+func (s *collectingSink) Records() []AuditRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AuditRecord(nil), s.records...)
+}
+
+func TestAuditSamplerMixedTraffic(t *testing.T) {
+	sink := &collectingSink{}
+	sampler := NewAuditSampler(sink, AuditSamplingConfig{
+		DefaultRate:       0.01,
+		CaptureErrors:     true,
+		CaptureTenants:    []string{"flagged-tenant"},
+		CaptureRequestIDs: []string{"req-watch"},
+		LatencyThreshold:  time.Second,
+	})
+
+	const numRequests = 5000
+	framesPerRequest := []string{"completion_request", "completion_response"}
+	sent := 0
+
+	for i := 0; i < numRequests; i++ {
+		tenant := "tenant-a"
+		if i%50 == 0 {
+			tenant = "flagged-tenant"
+		}
+		requestID := fmt.Sprintf("req-%d", i)
+		for _, frameType := range framesPerRequest {
+			var latency time.Duration
+			if frameType == "completion_response" && i%97 == 0 {
+				latency = 2 * time.Second
+			}
+			sampler.Record(AuditRecord{
+				TenantID:  tenant,
+				RequestID: requestID,
+				Latency:   latency,
+				Frame:     Frame{Type: frameType, StreamID: requestID},
+			})
+			sent++
+		}
+		if i%200 == 0 {
+			sampler.Record(AuditRecord{
+				TenantID:  tenant,
+				RequestID: requestID,
+				Frame:     Frame{Type: "error", StreamID: requestID},
+			})
+			sent++
+		}
+	}
+	sampler.Record(AuditRecord{TenantID: "tenant-a", RequestID: "req-watch", Frame: Frame{Type: "completion_request"}})
+	sent++
+
+	// Every captured record must be explained by exactly the rule it carries
+	captured := sink.Records()
+	baseSampled := make(map[string]map[string]bool)
+	for _, rec := range captured {
+		switch rec.Rule {
+		case AuditRuleErrorFrame:
+			if rec.Frame.Type != "error" {
+				t.Errorf("Non-error frame captured under error rule: %+v", rec)
+			}
+		case AuditRuleFlaggedTenant:
+			if rec.TenantID != "flagged-tenant" {
+				t.Errorf("Unflagged tenant captured under tenant rule: %+v", rec)
+			}
+		case AuditRuleFlaggedRequest:
+			if rec.RequestID != "req-watch" {
+				t.Errorf("Unflagged request captured under request rule: %+v", rec)
+			}
+		case AuditRuleLatencyThreshold:
+			if rec.Latency <= time.Second {
+				t.Errorf("Fast frame captured under latency rule: %+v", rec)
+			}
+		default:
+			if rec.TenantID == "flagged-tenant" || rec.Frame.Type == "error" {
+				t.Errorf("Always-capture frame attributed to base rate: %+v", rec)
+			}
+			if baseSampled[rec.RequestID] == nil {
+				baseSampled[rec.RequestID] = make(map[string]bool)
+			}
+			baseSampled[rec.RequestID][rec.Frame.Type] = true
+		}
+	}
+
+	// Every frame for the flagged tenant and every error frame is captured
+	flaggedTenantFrames, errorFrames := 0, 0
+	for _, rec := range captured {
+		if rec.Rule == AuditRuleFlaggedTenant {
+			flaggedTenantFrames++
+		}
+		if rec.Rule == AuditRuleErrorFrame {
+			errorFrames++
+		}
+	}
+	if flaggedTenantFrames != (numRequests/50)*len(framesPerRequest) {
+		t.Errorf("Expected %d flagged tenant frames, got %d", (numRequests/50)*len(framesPerRequest), flaggedTenantFrames)
+	}
+	if errorFrames != numRequests/200 {
+		t.Errorf("Expected %d error frames, got %d", numRequests/200, errorFrames)
+	}
+
+	// A sampled request is captured as a whole sequence, unless one of its
+	// frames was captured by the latency rule instead
+	for requestID, types := range baseSampled {
+		if len(types) != len(framesPerRequest) {
+			var n int
+			fmt.Sscanf(requestID, "req-%d", &n)
+			if n%97 != 0 {
+				t.Errorf("Request %s only partially sampled: %v", requestID, types)
+			}
+		}
+	}
+
+	// The base rate lands in the right neighbourhood of 1%
+	if len(baseSampled) < 10 || len(baseSampled) > 120 {
+		t.Errorf("Expected roughly 1%% of requests sampled, got %d of %d", len(baseSampled), numRequests)
+	}
+
+	// Counts reconstruct the total volume
+	var total uint64
+	for _, counts := range sampler.Counts() {
+		total += counts.Captured + counts.SampledOut
+	}
+	if total != uint64(sent) {
+		t.Errorf("Expected counts to cover %d frames, got %d", sent, total)
+	}
+	if sampler.Counts()[AuditRuleBaseRate+":completion_request"].SampledOut == 0 {
+		t.Error("Expected sampled-out count for completion_request base rate")
+	}
+}
+
+func TestSampleKeyDeterministic(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if sampleKey(key, 0.5) != sampleKey(key, 0.5) {
+			t.Fatalf("sampleKey not deterministic for %s", key)
+		}
+	}
+	if sampleKey("anything", 0) {
+		t.Error("Rate 0 should never sample")
+	}
+	if !sampleKey("anything", 1) {
+		t.Error("Rate 1 should always sample")
+	}
+}
+
+func TestClientAuditsFrames(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		return []Frame{{
+			Type:     "completion_response",
+			StreamID: f.StreamID,
+			MsgSeq:   f.MsgSeq,
+			Payload:  map[string]interface{}{"text": "ok"},
+		}}
+	})
+
+	sink := &collectingSink{}
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), TenantID: "audit-tenant", AuditSink: sink, Clock: clock})
+	defer client.Disconnect()
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	// The inbound record is produced after the response is dispatched
+	deadline := time.Now().Add(time.Second)
+	for len(sink.Records()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	records := sink.Records()
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 audit records, got %d", len(records))
+	}
+	if records[0].Direction != AuditOutbound || records[0].TenantID != "audit-tenant" {
+		t.Errorf("Unexpected outbound record: %+v", records[0])
+	}
+	if records[1].Direction != AuditInbound || records[1].RequestID != records[0].RequestID {
+		t.Errorf("Unexpected inbound record: %+v", records[1])
+	}
+	for _, record := range records {
+		if !record.Time.Equal(clock.Now()) {
+			t.Errorf("Expected records timed by the client's clock, got %v", record.Time)
+		}
+	}
+	if at := client.Stats().Connection.ConnectedAt; !at.Equal(clock.Now()) {
+		t.Errorf("Expected ConnectedAt from the client's clock, got %v", at)
+	}
+}
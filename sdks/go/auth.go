@@ -0,0 +1,174 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Credentials are what the client authenticates to the router with
+type Credentials struct {
+	APIKey      string
+	BearerToken string
+}
+
+// Frame types of the in-band reauthentication exchange
+const (
+	// FrameTypeAuthExpiring is sent by the router shortly before the
+	// credential of a connection expires
+	FrameTypeAuthExpiring = "auth.expiring"
+	// FrameTypeReauth carries new credentials over an established
+	// connection. The router acknowledges it with a completion_response or
+	// rejects it with an error frame.
+	FrameTypeReauth = "reauth"
+)
+
+// apiKeyState holds the API key the client authenticates with. It lives
+// outside SDKConfig so that rotating the key never races with the request
+// paths reading the configuration.
+type apiKeyState struct {
+	current atomic.Pointer[string]
+	mu      sync.Mutex // serializes reauthentications
+}
+
+// apiKey returns the API key new connections and HTTP requests use
+func (c *ATPClient) apiKey() string {
+	if key := c.auth.current.Load(); key != nil {
+		return *key
+	}
+	return ""
+}
+
+// SetAPIKey replaces the API key the client authenticates with. New
+// connections and HTTP requests use it right away. An established
+// connection is reauthenticated in the background with a reauth frame, and
+// redialled only if the router rejects it; failures are reported through
+// OnAsyncError. Pooled connections pick up the new key when they are
+// redialled.
+func (c *ATPClient) SetAPIKey(key string) {
+	c.auth.current.Store(&key)
+	c.reauthenticateAsync()
+}
+
+// fetchAPIKey asks SDKConfig.APIKeyProvider for the current key and stores
+// it
+func (c *ATPClient) fetchAPIKey() error {
+	ctx, cancel := context.WithTimeout(c.ctx, c.config.DefaultTimeout)
+	defer cancel()
+
+	key, err := c.config.APIKeyProvider(ctx)
+	if err != nil {
+		return fmt.Errorf("API key provider failed: %w", err)
+	}
+	c.auth.current.Store(&key)
+	return nil
+}
+
+// refreshCredentials answers an auth.expiring frame: it fetches a new
+// token or key and reauthenticates the connection with it
+func (c *ATPClient) refreshCredentials() {
+	if c.config.TokenSource != nil {
+		if _, err := c.refreshToken(); err != nil {
+			c.reportAsyncError(err)
+			return
+		}
+		c.reauthenticateAsync()
+		return
+	}
+	if c.config.APIKeyProvider == nil {
+		c.config.Logger.Printf("Warning: Router reports the API key is expiring, but no APIKeyProvider is configured")
+		return
+	}
+	if err := c.fetchAPIKey(); err != nil {
+		c.reportAsyncError(err)
+		return
+	}
+	c.reauthenticateAsync()
+}
+
+// reauthenticateAsync reauthenticates the established connection in the
+// background, as the acknowledgment arrives through the read loop. Over
+// HTTP every request carries the current credentials, so there is nothing
+// to do.
+func (c *ATPClient) reauthenticateAsync() {
+	if !c.IsConnected() || c.httpTransport.Load() {
+		return
+	}
+	go func() {
+		if err := c.reauthenticate(); err != nil {
+			c.reportAsyncError(err)
+		}
+	}()
+}
+
+// reauthenticate sends the current credentials over the established
+// connection. If the router rejects them, the connection is replaced by one
+// dialled with them.
+func (c *ATPClient) reauthenticate() error {
+	c.auth.mu.Lock()
+	defer c.auth.mu.Unlock()
+
+	credentials, err := c.credentials()
+	if err != nil {
+		return err
+	}
+	err = c.sendReauth(credentials)
+	if err == nil || c.closed() {
+		return nil
+	}
+	var rejection *ATPError
+	if !errors.As(err, &rejection) {
+		return fmt.Errorf("reauthentication failed: %w", err)
+	}
+
+	c.config.Logger.Printf("Warning: Router rejected the new credentials, reconnecting: %v", err)
+	c.connMutex.RLock()
+	conn := c.conn
+	c.connMutex.RUnlock()
+	if conn == nil {
+		return nil
+	}
+	c.connectionLost(conn, fmt.Errorf("reauthentication rejected: %w", err))
+	if err := c.Connect(); err != nil {
+		return fmt.Errorf("reconnect after rejected reauthentication failed: %w", err)
+	}
+	return nil
+}
+
+// credentials returns the credentials the client currently authenticates
+// with
+func (c *ATPClient) credentials() (Credentials, error) {
+	credentials := Credentials{APIKey: c.apiKey()}
+	if c.config.TokenSource != nil {
+		token, err := c.bearerToken()
+		if err != nil {
+			return Credentials{}, err
+		}
+		credentials.BearerToken = token
+	}
+	return credentials, nil
+}
+
+// sendReauth sends a reauth frame carrying credentials over the primary
+// connection and waits for the router to acknowledge it. A rejection is
+// returned as an *ATPError.
+func (c *ATPClient) sendReauth(credentials Credentials) error {
+	streamID := c.newStreamID("reauth")
+	frame := c.builder.BuildReauthFrame(streamID, credentials)
+
+	pending := c.expectResponse(frame)
+	if err := c.sendPrimaryFrame(frame); err != nil {
+		c.discardPending(pending, false)
+		return fmt.Errorf("failed to send reauth frame: %w", err)
+	}
+	response, err := c.waitForResponse(c.ctx, pending)
+	if err != nil {
+		return err
+	}
+	if response.Type == FrameTypeError {
+		return parseErrorFrame(response)
+	}
+	return nil
+}
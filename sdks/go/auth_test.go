@@ -0,0 +1,184 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// reauthRouter answers completion requests, and acknowledges reauth frames
+// unless reject is set. It returns the API keys presented in reauth frames.
+func reauthRouter(t *testing.T, reject bool) (*testRouter, func() []string) {
+	var mu sync.Mutex
+	var keys []string
+
+	router := newTestRouter(t, func(f Frame) []Frame {
+		switch f.Type {
+		case FrameTypeReauth:
+			mu.Lock()
+			keys = append(keys, getString(f.Payload, "api_key", ""))
+			mu.Unlock()
+			if reject {
+				return []Frame{NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, "UNAUTHORIZED", "invalid API key")}
+			}
+			return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"status": "ack"}}}
+		case "completion_request":
+			return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}}
+		}
+		return nil
+	})
+	return router, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), keys...)
+	}
+}
+
+// waitForReauths waits until the router has received n reauth frames
+func waitForReauths(t *testing.T, keys func() []string, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := keys(); len(got) >= n {
+			return got
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Router received %d reauth frame(s), expected %d", len(keys()), n)
+	return nil
+}
+
+func TestSetAPIKeyReauthenticates(t *testing.T) {
+	router, keys := reauthRouter(t, false)
+	var audited sync.Map
+	client := NewATPClient(SDKConfig{
+		WSURL:  router.URL(),
+		APIKey: "key-1",
+		AuditSink: AuditSinkFunc(func(record AuditRecord) {
+			if record.Frame.Type == FrameTypeReauth {
+				audited.Store(record.Frame.Payload["api_key"], true)
+			}
+		}),
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	client.SetAPIKey("key-2")
+	if got := waitForReauths(t, keys, 1); got[0] != "key-2" {
+		t.Errorf("Expected the reauth frame to carry key-2, got %v", got)
+	}
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed after reauthentication: %v", err)
+	}
+
+	if router.DialQuery(0).Get("api_key") != "key-1" {
+		t.Errorf("Expected the connection to be dialled with key-1, got %v", router.DialQuery(0))
+	}
+	if stats := client.Stats(); stats.Connection.ConnectCount != 1 || router.ActiveConnections() != 1 {
+		t.Errorf("Accepted reauthentication must keep the connection, got %d connect(s)", stats.Connection.ConnectCount)
+	}
+	if _, leaked := audited.Load("key-2"); leaked {
+		t.Error("The API key reached the audit sink")
+	}
+}
+
+func TestRejectedReauthReconnects(t *testing.T) {
+	router, keys := reauthRouter(t, true)
+	var connects atomic.Int32
+	client := NewATPClient(SDKConfig{
+		WSURL:     router.URL(),
+		APIKey:    "key-1",
+		Logger:    &recordingLogger{},
+		OnConnect: func(string) { connects.Add(1) },
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	client.SetAPIKey("key-2")
+	waitForReauths(t, keys, 1)
+	router.WaitForConnections(t, 2)
+	if got := router.DialQuery(1).Get("api_key"); got != "key-2" {
+		t.Errorf("Expected the new connection to be dialled with key-2, got %q", got)
+	}
+	waitForCount(t, &connects, 2)
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed after reconnecting: %v", err)
+	}
+}
+
+func TestAPIKeyProvider(t *testing.T) {
+	router, keys := reauthRouter(t, false)
+	var calls atomic.Int32
+	client := NewATPClient(SDKConfig{
+		WSURL:  router.URL(),
+		APIKey: "static",
+		APIKeyProvider: func(ctx context.Context) (string, error) {
+			return fmt.Sprintf("key-%d", calls.Add(1)), nil
+		},
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if got := router.DialQuery(0).Get("api_key"); got != "key-1" {
+		t.Errorf("Expected the provider's key at dial time, got %q", got)
+	}
+
+	if err := router.Send(Frame{Type: FrameTypeAuthExpiring}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got := waitForReauths(t, keys, 1); got[0] != "key-2" {
+		t.Errorf("Expected a reauth with the refreshed key, got %v", got)
+	}
+	if client.Stats().Connection.ConnectCount != 1 {
+		t.Error("Refreshing the key must not reconnect")
+	}
+}
+
+func TestAPIKeyProviderFailure(t *testing.T) {
+	router, _ := reauthRouter(t, false)
+	client := NewATPClient(SDKConfig{
+		WSURL: router.URL(),
+		APIKeyProvider: func(ctx context.Context) (string, error) {
+			return "", errors.New("vault unavailable")
+		},
+	})
+	defer client.Close()
+	if err := client.Connect(); !errors.Is(err, ErrConnectionFailed) {
+		t.Fatalf("Expected ErrConnectionFailed, got %v", err)
+	}
+}
+
+func TestSetAPIKeyConcurrentWithRequests(t *testing.T) {
+	router, keys := reauthRouter(t, false)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), APIKey: "key-0"})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 5; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client.SetAPIKey(fmt.Sprintf("key-%d", i))
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+				t.Errorf("Complete failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	waitForReauths(t, keys, 5)
+}
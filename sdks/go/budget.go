@@ -0,0 +1,162 @@
+package atpsdk
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default budget settings applied when BudgetConfig fields are unset
+var defaultBudgetThresholds = []float64{0.5, 0.8, 0.95}
+
+const defaultBurnRateWindow = 5 * time.Minute
+
+// BudgetConfig configures a spend budget for the client's session
+type BudgetConfig struct {
+	// LimitUSD is the session budget. Zero disables budget tracking. Once
+	// it is spent, Complete fails with ErrBudgetExceeded.
+	LimitUSD float64
+	// Thresholds are the fractions of LimitUSD at which OnBudgetThreshold
+	// fires (default: 0.5, 0.8, 0.95)
+	Thresholds []float64
+	// BurnRateWindow is the sliding window over which the spend rate used
+	// for exhaustion projections is measured (default: 5 minutes)
+	BurnRateWindow time.Duration
+	// ReportInHealth includes the budget state in health frames
+	ReportInHealth bool
+}
+
+// BudgetState is the current spend against the budget
+type BudgetState struct {
+	LimitUSD     float64 `json:"limit_usd"`
+	SpentUSD     float64 `json:"spent_usd"`
+	RemainingUSD float64 `json:"remaining_usd"`
+	// BurnRateUSDPerSecond is the spend rate over the burn rate window, or
+	// zero if too little spend has been observed to measure it
+	BurnRateUSDPerSecond float64 `json:"burn_rate_usd_per_second"`
+	// ProjectedExhaustion is when the budget runs out at the current burn
+	// rate, or the zero time if the rate is unknown
+	ProjectedExhaustion time.Time `json:"projected_exhaustion,omitempty"`
+}
+
+// BudgetThresholdEvent is passed to OnBudgetThreshold when spend crosses a
+// configured threshold
+type BudgetThresholdEvent struct {
+	// Threshold is the crossed fraction of the budget, e.g. 0.8
+	Threshold float64
+	BudgetState
+}
+
+// spendSample is one observed cost
+type spendSample struct {
+	at   time.Time
+	cost float64
+}
+
+// budgetTracker accumulates spend and detects threshold crossings
+type budgetTracker struct {
+	mu      sync.Mutex
+	spent   float64
+	samples []spendSample
+	fired   map[float64]bool
+}
+
+// budgetEnabled reports whether a budget is configured
+func (c *ATPClient) budgetEnabled() bool {
+	return c.config.Budget.LimitUSD > 0
+}
+
+// budgetExceeded reports whether the budget has been spent
+func (c *ATPClient) budgetExceeded() bool {
+	if !c.budgetEnabled() {
+		return false
+	}
+	c.budget.mu.Lock()
+	defer c.budget.mu.Unlock()
+	return c.budget.spent >= c.config.Budget.LimitUSD
+}
+
+// BudgetState returns the current spend against the configured budget. It
+// returns the zero value when no budget is configured.
+func (c *ATPClient) BudgetState() BudgetState {
+	if !c.budgetEnabled() {
+		return BudgetState{}
+	}
+	c.budget.mu.Lock()
+	defer c.budget.mu.Unlock()
+	return c.budgetStateLocked(c.config.Clock.Now())
+}
+
+// recordSpend adds cost to the session spend and fires OnBudgetThreshold
+// once for every threshold crossed for the first time
+func (c *ATPClient) recordSpend(cost float64) {
+	if !c.budgetEnabled() || cost <= 0 {
+		return
+	}
+	now := c.config.Clock.Now()
+
+	c.budget.mu.Lock()
+	c.budget.spent += cost
+	c.budget.samples = append(c.budget.samples, spendSample{at: now, cost: cost})
+	c.pruneSpendSamplesLocked(now)
+
+	var crossed []float64
+	fraction := c.budget.spent / c.config.Budget.LimitUSD
+	for _, threshold := range c.config.Budget.Thresholds {
+		if fraction >= threshold && !c.budget.fired[threshold] {
+			if c.budget.fired == nil {
+				c.budget.fired = make(map[float64]bool)
+			}
+			c.budget.fired[threshold] = true
+			crossed = append(crossed, threshold)
+		}
+	}
+	state := c.budgetStateLocked(now)
+	c.budget.mu.Unlock()
+
+	if c.config.OnBudgetThreshold == nil {
+		return
+	}
+	for _, threshold := range crossed {
+		c.config.OnBudgetThreshold(BudgetThresholdEvent{Threshold: threshold, BudgetState: state})
+	}
+}
+
+// pruneSpendSamplesLocked drops samples older than the burn rate window
+func (c *ATPClient) pruneSpendSamplesLocked(now time.Time) {
+	cutoff := now.Add(-c.config.Budget.BurnRateWindow)
+	i := sort.Search(len(c.budget.samples), func(i int) bool {
+		return !c.budget.samples[i].at.Before(cutoff)
+	})
+	c.budget.samples = c.budget.samples[i:]
+}
+
+// budgetStateLocked computes the budget state. The burn rate is the spend
+// observed after the oldest sample in the window divided by the time since
+// that sample, so it needs at least two samples.
+func (c *ATPClient) budgetStateLocked(now time.Time) BudgetState {
+	c.pruneSpendSamplesLocked(now)
+
+	limit := c.config.Budget.LimitUSD
+	state := BudgetState{
+		LimitUSD:     limit,
+		SpentUSD:     c.budget.spent,
+		RemainingUSD: max(limit-c.budget.spent, 0),
+	}
+
+	if len(c.budget.samples) >= 2 {
+		oldest := c.budget.samples[0]
+		if elapsed := now.Sub(oldest.at); elapsed > 0 {
+			var cost float64
+			for _, sample := range c.budget.samples[1:] {
+				cost += sample.cost
+			}
+			state.BurnRateUSDPerSecond = cost / elapsed.Seconds()
+		}
+	}
+	if state.BurnRateUSDPerSecond > 0 {
+		seconds := state.RemainingUSD / state.BurnRateUSDPerSecond
+		state.ProjectedExhaustion = now.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	return state
+}
@@ -0,0 +1,139 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// costRouter answers every completion request with the given cost, and
+// captures health frames
+func costRouter(t *testing.T, cost float64, health chan<- Frame) *testRouter {
+	return newTestRouter(t, func(f Frame) []Frame {
+		switch f.Type {
+		case "completion_request":
+			return []Frame{{
+				Type:     "completion_response",
+				StreamID: f.StreamID,
+				MsgSeq:   f.MsgSeq,
+				Payload:  map[string]interface{}{"text": "ok", "cost_usd": cost},
+			}}
+		case "adapter.health":
+			health <- f
+			return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq}}
+		}
+		return nil
+	})
+}
+
+func TestBudgetThresholds(t *testing.T) {
+	router := costRouter(t, 1.0, nil)
+	clock := newFakeClock()
+
+	var events []BudgetThresholdEvent
+	client := NewATPClient(SDKConfig{
+		WSURL:             router.URL(),
+		Clock:             clock,
+		Budget:            BudgetConfig{LimitUSD: 10, BurnRateWindow: time.Minute},
+		OnBudgetThreshold: func(e BudgetThresholdEvent) { events = append(events, e) },
+	})
+	defer client.Close()
+
+	// $1 every 10 seconds
+	eventsAt := make(map[float64]int)
+	for i := 1; i <= 10; i++ {
+		if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+			t.Fatalf("Complete %d failed: %v", i, err)
+		}
+		for len(eventsAt) < len(events) {
+			eventsAt[events[len(eventsAt)].Threshold] = i
+		}
+		clock.Advance(10 * time.Second)
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("Expected one event per threshold, got %+v", events)
+	}
+	if eventsAt[0.5] != 5 || eventsAt[0.8] != 8 || eventsAt[0.95] != 10 {
+		t.Errorf("Thresholds fired at the wrong spend: %v", eventsAt)
+	}
+
+	half := events[0]
+	if half.SpentUSD != 5 || half.RemainingUSD != 5 || half.LimitUSD != 10 {
+		t.Errorf("Unexpected state at 50%%: %+v", half)
+	}
+	// Spend in the window: $1 per 10s
+	if math.Abs(half.BurnRateUSDPerSecond-0.1) > 1e-9 {
+		t.Errorf("Expected a burn rate of $0.1/s, got %v", half.BurnRateUSDPerSecond)
+	}
+	start := time.Unix(1700000000, 0)
+	if want := start.Add(40*time.Second + 50*time.Second); !half.ProjectedExhaustion.Equal(want) {
+		t.Errorf("Expected exhaustion at %v, got %v", want, half.ProjectedExhaustion)
+	}
+	if final := events[2]; final.RemainingUSD != 0 || !final.ProjectedExhaustion.Equal(start.Add(90*time.Second)) {
+		t.Errorf("Unexpected state at 95%%: %+v", final)
+	}
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("Expected ErrBudgetExceeded, got %v", err)
+	}
+	if len(events) != 3 {
+		t.Errorf("Thresholds must not fire twice, got %d events", len(events))
+	}
+}
+
+func TestBudgetBurnRateWindow(t *testing.T) {
+	router := costRouter(t, 0.5, nil)
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Clock: clock, Budget: BudgetConfig{LimitUSD: 100, BurnRateWindow: time.Minute}})
+	defer client.Close()
+
+	complete := func() {
+		if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+	}
+
+	complete()
+	if state := client.BudgetState(); state.BurnRateUSDPerSecond != 0 || !state.ProjectedExhaustion.IsZero() {
+		t.Errorf("A single sample should not produce a rate: %+v", state)
+	}
+
+	// A burst long ago falls out of the window
+	clock.Advance(time.Second)
+	complete()
+	clock.Advance(10 * time.Minute)
+	complete()
+	clock.Advance(30 * time.Second)
+	complete()
+
+	state := client.BudgetState()
+	if state.SpentUSD != 2 {
+		t.Errorf("Expected $2 spent, got %v", state.SpentUSD)
+	}
+	if math.Abs(state.BurnRateUSDPerSecond-0.5/30) > 1e-9 {
+		t.Errorf("Expected the rate of the recent window only, got %v", state.BurnRateUSDPerSecond)
+	}
+}
+
+func TestBudgetInHealthFrames(t *testing.T) {
+	health := make(chan Frame, 1)
+	router := costRouter(t, 2.5, health)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Budget: BudgetConfig{LimitUSD: 10, ReportInHealth: true}})
+	defer client.Close()
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if err := client.ReportHealth(context.Background(), HealthStatus{AdapterID: "a1", Status: "healthy"}); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+
+	f := <-health
+	budget, ok := f.Payload["budget"].(map[string]interface{})
+	if !ok || budget["spent_usd"] != 2.5 || budget["remaining_usd"] != 7.5 || budget["limit_usd"] != 10.0 {
+		t.Errorf("Unexpected budget in health frame: %v", f.Payload["budget"])
+	}
+}
@@ -0,0 +1,212 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+// CanonicalizeFrame returns the canonical JSON form of frame, the form
+// frames built by the ATP SDKs of every language are compared in. Two
+// frames are equivalent when their canonical forms are byte for byte
+// equal, so the form may also be signed or hashed. The rules, which other
+// SDKs implement identically, are:
+//
+//  1. The signature, "sig", is removed.
+//  2. Object members whose value is null are removed, at every level.
+//     Nulls inside arrays are kept.
+//  3. Outside "payload", members whose value is "", 0, false or [] are
+//     removed too, as are objects left empty by these rules, so a field
+//     that is unset and one that is omitted compare equal. Payload values
+//     are kept as they are, as zero may be meaningful there.
+//  4. Object keys are sorted by Unicode code point.
+//  5. Numbers that are integers within the int64 range are written as
+//     integers, whatever their form (1, 1.0 and 1e0 are all 1). Other
+//     numbers are written in the shortest form that round-trips a float64,
+//     as ECMAScript's Number.prototype.toString does; -0 is written 0.
+//  6. Strings escape only the quote, the backslash and the control
+//     characters below U+0020: \b, \f, \n, \r and \t by those escapes, the
+//     others as \u00XX with lowercase hex digits. Everything else, such as
+//     < or é, is written as UTF-8.
+//  7. There is no whitespace between tokens.
+//
+// CanonicalFrameBytes, which frame signatures are computed over, predates
+// these rules and is kept unchanged so that existing peers still verify.
+func CanonicalizeFrame(frame Frame) ([]byte, error) {
+	data, err := marshalFrame(&frame)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal frame: %w", err)
+	}
+	return canonicalizeFrameJSON(data)
+}
+
+// canonicalizeFrameJSON applies the rules of CanonicalizeFrame to a frame
+// serialized by any SDK
+func canonicalizeFrameJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var frame map[string]interface{}
+	if err := decoder.Decode(&frame); err != nil {
+		return nil, fmt.Errorf("failed to canonicalize frame: %w", err)
+	}
+	delete(frame, "sig")
+	for key, value := range frame {
+		if key == "payload" {
+			continue
+		}
+		if value = pruneEmpty(value); value == nil {
+			delete(frame, key)
+		} else {
+			frame[key] = value
+		}
+	}
+	return appendCanonical(nil, frame)
+}
+
+// pruneEmpty returns value with the members rule 3 of CanonicalizeFrame
+// removes removed, or nil when value itself is removed
+func pruneEmpty(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+	case bool:
+		if !v {
+			return nil
+		}
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == 0 {
+			return nil
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+	case map[string]interface{}:
+		for key, member := range v {
+			if member = pruneEmpty(member); member == nil {
+				delete(v, key)
+			} else {
+				v[key] = member
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	}
+	return value
+}
+
+// appendCanonical appends the canonical encoding of a decoded JSON value
+func appendCanonical(dst []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(dst, "null"...), nil
+	case bool:
+		return strconv.AppendBool(dst, v), nil
+	case json.Number:
+		return appendCanonicalNumber(dst, v)
+	case string:
+		return appendCanonicalString(dst, v), nil
+	case []interface{}:
+		dst = append(dst, '[')
+		for i, item := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			var err error
+			if dst, err = appendCanonical(dst, item); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, ']'), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key, member := range v {
+			if member != nil {
+				keys = append(keys, key)
+			}
+		}
+		// Byte order of UTF-8 is code point order
+		slices.Sort(keys)
+		dst = append(dst, '{')
+		for i, key := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendCanonicalString(dst, key)
+			dst = append(dst, ':')
+			var err error
+			if dst, err = appendCanonical(dst, v[key]); err != nil {
+				return nil, err
+			}
+		}
+		return append(dst, '}'), nil
+	}
+	return nil, fmt.Errorf("failed to canonicalize %T value", value)
+}
+
+// appendCanonicalNumber appends n as rule 5 of CanonicalizeFrame writes it
+func appendCanonicalNumber(dst []byte, n json.Number) ([]byte, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return strconv.AppendInt(dst, i, 10), nil
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize number %s: %w", n, err)
+	}
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return strconv.AppendInt(dst, int64(f), 10), nil
+	}
+	// ECMAScript switches to exponents below 1e-6 and from 1e21
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+	if format == 'e' {
+		// Go pads negative exponents to two digits: e-07 for e-7
+		if end := len(dst); end >= 4 && dst[end-4] == 'e' && dst[end-3] == '-' && dst[end-2] == '0' {
+			dst[end-2] = dst[end-1]
+			dst = dst[:end-1]
+		}
+	}
+	return dst, nil
+}
+
+// appendCanonicalString appends s as rule 6 of CanonicalizeFrame writes it
+func appendCanonicalString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '"' || r == '\\':
+			dst = append(dst, '\\', byte(r))
+		case r == '\b':
+			dst = append(dst, `\b`...)
+		case r == '\f':
+			dst = append(dst, `\f`...)
+		case r == '\n':
+			dst = append(dst, `\n`...)
+		case r == '\r':
+			dst = append(dst, `\r`...)
+		case r == '\t':
+			dst = append(dst, `\t`...)
+		case r < 0x20:
+			dst = append(dst, '\\', 'u', '0', '0', hex[r>>4], hex[r&0xf])
+		case r == utf8.RuneError && size == 1:
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+		default:
+			dst = append(dst, s[i:i+size]...)
+		}
+		i += size
+	}
+	return append(dst, '"')
+}
@@ -0,0 +1,253 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// CapabilityDelta is an incremental change to an adapter's advertised
+// capabilities, sent with UpdateCapabilities. Nil scalar fields are left
+// unchanged.
+type CapabilityDelta struct {
+	AddModels          []string `json:"add_models,omitempty"`
+	RemoveModels       []string `json:"remove_models,omitempty"`
+	AddCapabilities    []string `json:"add_capabilities,omitempty"`
+	RemoveCapabilities []string `json:"remove_capabilities,omitempty"`
+	AddLanguages       []string `json:"add_supported_languages,omitempty"`
+	RemoveLanguages    []string `json:"remove_supported_languages,omitempty"`
+
+	AdapterType        *string `json:"adapter_type,omitempty"`
+	MaxTokens          *int    `json:"max_tokens,omitempty"`
+	CostPerTokenMicros *int    `json:"cost_per_token_micros,omitempty"`
+	HealthEndpoint     *string `json:"health_endpoint,omitempty"`
+	Version            *string `json:"version,omitempty"`
+	// Metadata replaces the adapter's metadata when not nil
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// IsEmpty reports whether d changes nothing
+func (d CapabilityDelta) IsEmpty() bool {
+	return len(d.AddModels) == 0 && len(d.RemoveModels) == 0 &&
+		len(d.AddCapabilities) == 0 && len(d.RemoveCapabilities) == 0 &&
+		len(d.AddLanguages) == 0 && len(d.RemoveLanguages) == 0 &&
+		d.AdapterType == nil && d.MaxTokens == nil && d.CostPerTokenMicros == nil &&
+		d.HealthEndpoint == nil && d.Version == nil && d.Metadata == nil
+}
+
+// apply returns capability changed by d
+func (d CapabilityDelta) apply(capability CapabilityAdvertisement) CapabilityAdvertisement {
+	capability.Models = applyListDelta(capability.Models, d.AddModels, d.RemoveModels)
+	capability.Capabilities = applyListDelta(capability.Capabilities, d.AddCapabilities, d.RemoveCapabilities)
+	capability.SupportedLanguages = applyListDelta(capability.SupportedLanguages, d.AddLanguages, d.RemoveLanguages)
+	if d.AdapterType != nil {
+		capability.AdapterType = *d.AdapterType
+	}
+	if d.MaxTokens != nil {
+		capability.MaxTokens = d.MaxTokens
+	}
+	if d.CostPerTokenMicros != nil {
+		capability.CostPerTokenMicros = d.CostPerTokenMicros
+	}
+	if d.HealthEndpoint != nil {
+		capability.HealthEndpoint = d.HealthEndpoint
+	}
+	if d.Version != nil {
+		capability.Version = d.Version
+	}
+	if d.Metadata != nil {
+		capability.Metadata = d.Metadata
+	}
+	return capability
+}
+
+// applyListDelta returns list without removed and with added appended
+func applyListDelta(list, added, removed []string) []string {
+	var result []string
+	for _, item := range list {
+		if !slices.Contains(removed, item) {
+			result = append(result, item)
+		}
+	}
+	for _, item := range added {
+		if !slices.Contains(result, item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// diffCapabilities returns the delta turning previous into next. It reports
+// false when next cannot be expressed as a delta, because it changes the
+// adapter ID or unsets a field.
+func diffCapabilities(previous, next CapabilityAdvertisement) (CapabilityDelta, bool) {
+	if previous.AdapterID != next.AdapterID {
+		return CapabilityDelta{}, false
+	}
+
+	var delta CapabilityDelta
+	delta.AddModels, delta.RemoveModels = diffList(previous.Models, next.Models)
+	delta.AddCapabilities, delta.RemoveCapabilities = diffList(previous.Capabilities, next.Capabilities)
+	delta.AddLanguages, delta.RemoveLanguages = diffList(previous.SupportedLanguages, next.SupportedLanguages)
+	if previous.AdapterType != next.AdapterType {
+		delta.AdapterType = &next.AdapterType
+	}
+
+	ok := true
+	diffScalar(previous.MaxTokens, next.MaxTokens, &delta.MaxTokens, &ok)
+	diffScalar(previous.CostPerTokenMicros, next.CostPerTokenMicros, &delta.CostPerTokenMicros, &ok)
+	diffScalar(previous.HealthEndpoint, next.HealthEndpoint, &delta.HealthEndpoint, &ok)
+	diffScalar(previous.Version, next.Version, &delta.Version, &ok)
+	if !reflect.DeepEqual(previous.Metadata, next.Metadata) {
+		if next.Metadata == nil {
+			ok = false
+		}
+		delta.Metadata = next.Metadata
+	}
+	return delta, ok
+}
+
+// diffList returns the items of next missing from previous, and those of
+// previous missing from next
+func diffList(previous, next []string) (added, removed []string) {
+	for _, item := range next {
+		if !slices.Contains(previous, item) {
+			added = append(added, item)
+		}
+	}
+	for _, item := range previous {
+		if !slices.Contains(next, item) {
+			removed = append(removed, item)
+		}
+	}
+	return added, removed
+}
+
+// diffScalar sets *changed to next when it differs from previous, clearing
+// *ok when next unsets the field
+func diffScalar[T comparable](previous, next *T, changed **T, ok *bool) {
+	switch {
+	case next == nil && previous != nil:
+		*ok = false
+	case next != nil && (previous == nil || *previous != *next):
+		*changed = next
+	}
+}
+
+// advertisedCapabilities remembers the capabilities last acknowledged by the
+// router for each adapter, so that AdvertiseCapabilities can send only what
+// changed
+type advertisedCapabilities struct {
+	mu        sync.Mutex
+	byAdapter map[advertisedKey]advertisedState
+}
+
+// advertisedKey identifies an adapter of a tenant
+type advertisedKey struct {
+	tenantID  string
+	adapterID string
+}
+
+// advertisedState is an adapter's acknowledged capabilities
+type advertisedState struct {
+	capability CapabilityAdvertisement
+	connection uint64 // connect count the router acknowledged them on
+}
+
+// lastAdvertised returns the capabilities acknowledged for adapterID on the
+// current connection. A router reached through a new connection may have
+// lost them.
+func (c *ATPClient) lastAdvertised(ctx context.Context, adapterID string) (CapabilityAdvertisement, bool) {
+	if !c.IsConnected() {
+		return CapabilityAdvertisement{}, false
+	}
+	c.advertised.mu.Lock()
+	defer c.advertised.mu.Unlock()
+	state, ok := c.advertised.byAdapter[advertisedKey{c.tenantOf(ctx), adapterID}]
+	if !ok || state.connection != c.counters.connects.Load() {
+		return CapabilityAdvertisement{}, false
+	}
+	return state.capability, true
+}
+
+// recordAdvertised remembers capability as acknowledged by the router
+func (c *ATPClient) recordAdvertised(ctx context.Context, capability CapabilityAdvertisement) {
+	c.advertised.mu.Lock()
+	defer c.advertised.mu.Unlock()
+	if c.advertised.byAdapter == nil {
+		c.advertised.byAdapter = make(map[advertisedKey]advertisedState)
+	}
+	c.advertised.byAdapter[advertisedKey{c.tenantOf(ctx), capability.AdapterID}] = advertisedState{
+		capability: capability,
+		connection: c.counters.connects.Load(),
+	}
+}
+
+// forgetAdvertised drops the capabilities remembered for adapterID
+func (c *ATPClient) forgetAdvertised(ctx context.Context, adapterID string) {
+	c.advertised.mu.Lock()
+	defer c.advertised.mu.Unlock()
+	delete(c.advertised.byAdapter, advertisedKey{c.tenantOf(ctx), adapterID})
+}
+
+// UpdateCapabilities sends an incremental change to adapterID's advertised
+// capabilities and waits for the router to acknowledge it. A rejected
+// update fails with a *NackError. See FireAndForget to skip the wait.
+func (c *ATPClient) UpdateCapabilities(ctx context.Context, adapterID string, delta CapabilityDelta, opts ...RequestOption) error {
+	if c.closed() {
+		return ErrClientClosed
+	}
+	options := c.requestOptions(opts)
+	if options.err != nil {
+		return options.err
+	}
+	correlationID := c.correlate(&options)
+	if options.tenant != "" {
+		ctx = ContextWithTenant(ctx, options.tenant)
+	}
+
+	previous, known := c.lastAdvertised(ctx, adapterID)
+	builder := c.builderFor(ctx)
+	streamID := c.newStreamID("capability")
+	frame := builder.BuildCapabilityUpdateFrame(streamID, adapterID, delta, options.meta...)
+	if options.qos != "" {
+		frame.QoS = options.qos
+	}
+	defer builder.ReleaseStream(streamID)
+
+	err := c.sendAdapterFrame(ctx, frame, options, "capability update")
+	switch {
+	case err != nil || options.fireAndForget || !known:
+		// The router's view of the adapter is unknown
+		c.forgetAdvertised(ctx, adapterID)
+	default:
+		c.recordAdvertised(ctx, delta.apply(previous))
+	}
+	return correlateError(err, correlationID)
+}
+
+// advertiseDelta sends the change from the capabilities last acknowledged
+// for capability's adapter as an update. It reports false, without sending
+// anything, when there is no such advertisement or no expressible change,
+// and when the router rejects the update so a full advertisement must
+// follow.
+func (c *ATPClient) advertiseDelta(ctx context.Context, capability CapabilityAdvertisement, opts []RequestOption) (bool, error) {
+	previous, ok := c.lastAdvertised(ctx, capability.AdapterID)
+	if !ok {
+		return false, nil
+	}
+	delta, ok := diffCapabilities(previous, capability)
+	if !ok || delta.IsEmpty() {
+		return false, nil
+	}
+
+	err := c.UpdateCapabilities(ctx, capability.AdapterID, delta, opts...)
+	var atpErr *ATPError
+	if errors.Is(err, ErrNacked) || errors.As(err, &atpErr) {
+		c.config.Logger.Printf("Warning: Capability update rejected, advertising in full: %v", err)
+		return false, nil
+	}
+	return true, err
+}
@@ -0,0 +1,92 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// capabilityRouter acks capability frames, nacking updates while
+// rejectUpdates is set, and forwards them to the returned channel
+func capabilityRouter(t *testing.T, rejectUpdates *atomic.Bool) (*testRouter, chan Frame) {
+	frames := make(chan Frame, 100)
+	fb := NewFrameBuilder("", "")
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type == "heartbeat" {
+			return nil
+		}
+		frames <- f
+		if f.Type == "adapter.capability.update" && rejectUpdates.Load() {
+			return []Frame{fb.BuildNackFrame(f.StreamID, f.MsgSeq, "unknown_adapter", "no such adapter")}
+		}
+		return []Frame{fb.BuildAckFrame(f.StreamID, f.MsgSeq)}
+	})
+	return router, frames
+}
+
+func TestAdvertiseCapabilitiesSendsDelta(t *testing.T) {
+	var rejectUpdates atomic.Bool
+	router, frames := capabilityRouter(t, &rejectUpdates)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Logger: &recordingLogger{}})
+	defer client.Close()
+	ctx := context.Background()
+
+	advertise := func(capability CapabilityAdvertisement, want string) Frame {
+		t.Helper()
+		if err := client.AdvertiseCapabilities(ctx, capability); err != nil {
+			t.Fatalf("AdvertiseCapabilities failed: %v", err)
+		}
+		f := waitReply(t, frames)
+		if f.Type != want {
+			t.Fatalf("Expected a %s frame, got %s", want, f.Type)
+		}
+		return f
+	}
+
+	version := "1.1"
+	capability := CapabilityAdvertisement{AdapterID: "edge-1", Models: []string{"m1", "m2"}, Capabilities: []string{"chat"}}
+	advertise(capability, "adapter.capability")
+
+	// Only the changes are sent once the router knows the adapter
+	capability.Models = []string{"m2", "m3"}
+	capability.Version = &version
+	update := advertise(capability, "adapter.capability.update")
+	got := fmt.Sprintf("%v %v %v", update.Payload["add_models"], update.Payload["remove_models"], update.Payload["version"])
+	if got != "[m3] [m1] 1.1" || update.Payload["add_capabilities"] != nil {
+		t.Errorf("Unexpected update payload %v", update.Payload)
+	}
+
+	// An unchanged advertisement refreshes it in full
+	advertise(capability, "adapter.capability")
+
+	// Explicit updates build on the advertised state
+	if err := client.UpdateCapabilities(ctx, "edge-1", CapabilityDelta{AddModels: []string{"m4"}}); err != nil {
+		t.Fatalf("UpdateCapabilities failed: %v", err)
+	}
+	waitReply(t, frames)
+	capability.Models = []string{"m2", "m3", "m4"}
+	advertise(capability, "adapter.capability")
+
+	// A rejected update falls back to a full advertisement
+	rejectUpdates.Store(true)
+	capability.Models = []string{"m2"}
+	advertise(capability, "adapter.capability.update")
+	if f := waitReply(t, frames); f.Type != "adapter.capability" || fmt.Sprint(f.Payload["models"]) != "[m2]" {
+		t.Errorf("Expected a full advertisement after the nack, got %+v", f)
+	}
+	rejectUpdates.Store(false)
+
+	// Unsetting a field cannot be sent as a delta
+	capability.Version = nil
+	advertise(capability, "adapter.capability")
+
+	// Nor can changes after a reconnect, which the router may not know of
+	router.DropConnections()
+	for client.IsConnected() {
+		time.Sleep(time.Millisecond)
+	}
+	capability.Models = []string{"m5"}
+	advertise(capability, "adapter.capability")
+}
@@ -0,0 +1,176 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/atp-project/atp-go-sdk/tokencount"
+)
+
+// ChunkStrategy splits a prompt too long for one request, for WithChunking.
+// Complete sends the chunks as sequential requests on one stream. Each
+// request after the first carries the text returned for the previous chunk,
+// a blank line, then its chunk. The texts returned are concatenated into one
+// CompletionResponse, with the token counts and costs summed.
+type ChunkStrategy interface {
+	// Split returns the chunks of prompt, in order. A prompt that fits in
+	// one request is returned as a single chunk.
+	Split(prompt string) []string
+}
+
+// ChunkStrategyFunc adapts a function to the ChunkStrategy interface
+type ChunkStrategyFunc func(prompt string) []string
+
+// Split calls f(prompt)
+func (f ChunkStrategyFunc) Split(prompt string) []string {
+	return f(prompt)
+}
+
+// SentenceChunker is a ChunkStrategy packing a prompt into chunks of at most
+// MaxTokens. It splits on paragraph boundaries, splitting paragraphs too
+// long for a chunk on sentence boundaries and sentences on whitespace. The
+// chunks concatenate back to the prompt.
+type SentenceChunker struct {
+	// MaxTokens is the size of a chunk, typically the max_tokens the
+	// target adapter advertises. Zero disables chunking.
+	MaxTokens int
+	// CountTokens counts the tokens of a text (default: EstimateTokens)
+	CountTokens func(text string) int
+}
+
+var (
+	paragraphBoundary = regexp.MustCompile(`\n[ \t]*\n\s*`)
+	sentenceBoundary  = regexp.MustCompile(`[.!?]+["')\]]*\s+`)
+	wordBoundary      = regexp.MustCompile(`\s+`)
+)
+
+// EstimateTokens estimates the tokens of text at four bytes per token. See
+// the tokencount package for per-model estimates and exact tokenizers.
+func EstimateTokens(text string) int {
+	return tokencount.EstimateTokens(text, "")
+}
+
+// Split packs prompt into chunks of at most MaxTokens. A word longer than
+// MaxTokens makes a chunk of its own.
+func (s SentenceChunker) Split(prompt string) []string {
+	if s.MaxTokens <= 0 || s.count(prompt) <= s.MaxTokens {
+		return []string{prompt}
+	}
+
+	var chunks []string
+	var current string
+	for _, segment := range s.segments(prompt) {
+		if current != "" && s.count(current+segment) > s.MaxTokens {
+			chunks = append(chunks, current)
+			current = ""
+		}
+		current += segment
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// segments splits text at the coarsest boundaries that fit each piece in
+// MaxTokens: paragraphs, then sentences, then words
+func (s SentenceChunker) segments(text string) []string {
+	var segments []string
+	for _, paragraph := range splitAfter(text, paragraphBoundary) {
+		if s.count(paragraph) <= s.MaxTokens {
+			segments = append(segments, paragraph)
+			continue
+		}
+		for _, sentence := range splitAfter(paragraph, sentenceBoundary) {
+			if s.count(sentence) <= s.MaxTokens {
+				segments = append(segments, sentence)
+				continue
+			}
+			segments = append(segments, splitAfter(sentence, wordBoundary)...)
+		}
+	}
+	return segments
+}
+
+func (s SentenceChunker) count(text string) int {
+	if s.CountTokens != nil {
+		return s.CountTokens(text)
+	}
+	return EstimateTokens(text)
+}
+
+// splitAfter splits text after each match of boundary
+func splitAfter(text string, boundary *regexp.Regexp) []string {
+	var pieces []string
+	start := 0
+	for _, match := range boundary.FindAllStringIndex(text, -1) {
+		if match[1] > start {
+			pieces = append(pieces, text[start:match[1]])
+			start = match[1]
+		}
+	}
+	if start < len(text) {
+		pieces = append(pieces, text[start:])
+	}
+	return pieces
+}
+
+// ChunkError is returned when a chunk of a chunked request fails
+type ChunkError struct {
+	Index  int // of the failed chunk, from 0
+	Chunks int // in the request
+	Err    error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d of %d failed: %v", e.Index+1, e.Chunks, e.Err)
+}
+
+// Unwrap returns the chunk's error
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// completeChunked sends the chunks of request's prompt as sequential
+// requests on one stream, threading each response into the next request,
+// and combines the responses
+func (c *ATPClient) completeChunked(ctx context.Context, request CompletionRequest, chunks []string, chain []string) (*CompletionResponse, error) {
+	streamID := c.newStreamID("completion")
+	defer c.builderFor(ctx).ReleaseStream(streamID)
+
+	combined := &CompletionResponse{Replayed: true, PreferenceHonored: true}
+	var text strings.Builder
+	previous := ""
+	for i, chunk := range chunks {
+		attempt := request
+		attempt.Prompt = chunk
+		if i > 0 {
+			attempt.Prompt = previous + "\n\n" + chunk
+		}
+		if request.IdempotencyKey != "" {
+			attempt.IdempotencyKey = fmt.Sprintf("%s/chunk-%d", request.IdempotencyKey, i)
+		}
+
+		response, err := c.completeRequest(ctx, streamID, attempt, chain)
+		if err != nil {
+			return nil, &ChunkError{Index: i, Chunks: len(chunks), Err: err}
+		}
+		text.WriteString(response.Text)
+		previous = response.Text
+
+		combined.ModelUsed = response.ModelUsed
+		combined.TokensIn += response.TokensIn
+		combined.TokensOut += response.TokensOut
+		combined.CostUSD += response.CostUSD
+		combined.QualityScore = response.QualityScore
+		combined.FallbackDepth = max(combined.FallbackDepth, response.FallbackDepth)
+		combined.FallbackErrors = append(combined.FallbackErrors, response.FallbackErrors...)
+		combined.Replayed = combined.Replayed && response.Replayed
+		combined.PreferenceHonored = combined.PreferenceHonored && response.PreferenceHonored
+	}
+	combined.Text = text.String()
+	combined.Finished = true
+	return combined, nil
+}
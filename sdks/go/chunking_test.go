@@ -0,0 +1,114 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSentenceChunkerSplit(t *testing.T) {
+	prompt := "First paragraph. It is short.\n\n" +
+		"The second paragraph is too long for one chunk. It splits into sentences! Does it?\n\n" +
+		"Last."
+	chunker := SentenceChunker{MaxTokens: 50, CountTokens: func(s string) int { return len(s) }}
+
+	chunks := chunker.Split(prompt)
+	want := []string{
+		"First paragraph. It is short.\n\n",
+		"The second paragraph is too long for one chunk. ",
+		"It splits into sentences! Does it?\n\nLast.",
+	}
+	if fmt.Sprintf("%q", chunks) != fmt.Sprintf("%q", want) {
+		t.Errorf("Unexpected chunks:\n%q\nwant:\n%q", chunks, want)
+	}
+	if strings.Join(chunks, "") != prompt {
+		t.Error("Chunks do not concatenate back to the prompt")
+	}
+
+	// A prompt that fits, or a disabled chunker, gives one chunk
+	if chunks := chunker.Split("short"); len(chunks) != 1 {
+		t.Errorf("Expected one chunk, got %q", chunks)
+	}
+	if chunks := (SentenceChunker{}).Split(prompt); len(chunks) != 1 {
+		t.Errorf("Expected one chunk without MaxTokens, got %q", chunks)
+	}
+}
+
+// chunkRouter answers each completion request with "out<msg_seq>", failing
+// the request with msg_seq failAt, and records the requests
+func chunkRouter(t *testing.T, failAt int) (*testRouter, func() []Frame) {
+	var mu sync.Mutex
+	var requests []Frame
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		mu.Lock()
+		requests = append(requests, f)
+		mu.Unlock()
+		if f.MsgSeq == failAt {
+			return []Frame{NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeAdapterUnavailable, "adapter overloaded")}
+		}
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{
+			"text": fmt.Sprintf("out%d", f.MsgSeq), "model_used": "m", "tokens_in": 10, "tokens_out": 5, "cost_usd": 0.25,
+		}}}
+	})
+	return router, func() []Frame {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Frame(nil), requests...)
+	}
+}
+
+func threeChunks(string) []string {
+	return []string{"one", "two", "three"}
+}
+
+func TestChunkedCompletion(t *testing.T) {
+	router, requests := chunkRouter(t, 0)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "one two three"}, WithChunking(ChunkStrategyFunc(threeChunks)))
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.Text != "out1out2out3" || response.TokensIn != 30 || response.TokensOut != 15 || response.CostUSD != 0.75 {
+		t.Errorf("Unexpected combined response %+v", response)
+	}
+
+	// The chunks go out in order on one stream, each after the previous
+	// chunk's output
+	got := requests()
+	wantPrompts := []string{"one", "out1\n\ntwo", "out2\n\nthree"}
+	if len(got) != len(wantPrompts) {
+		t.Fatalf("Expected %d requests, got %d", len(wantPrompts), len(got))
+	}
+	for i, f := range got {
+		if f.StreamID != got[0].StreamID || f.MsgSeq != i+1 || f.Payload["prompt"] != wantPrompts[i] {
+			t.Errorf("Unexpected request %d: %+v", i, f)
+		}
+	}
+}
+
+func TestChunkedCompletionError(t *testing.T) {
+	router, requests := chunkRouter(t, 2)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "one two three"}, WithChunking(ChunkStrategyFunc(threeChunks)))
+	var chunkErr *ChunkError
+	if !errors.As(err, &chunkErr) || chunkErr.Index != 1 || chunkErr.Chunks != 3 {
+		t.Fatalf("Expected the second chunk to fail, got %v", err)
+	}
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeAdapterUnavailable {
+		t.Errorf("Expected the router error as the cause, got %v", err)
+	}
+	if n := len(requests()); n != 2 {
+		t.Errorf("Expected no request after the failed chunk, got %d requests", n)
+	}
+}
@@ -3,143 +3,583 @@ package atpsdk
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/url"
+	"net/http"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
+// SDKVersion is the version of this SDK reported to the router
+const SDKVersion = "0.1.0"
+
 // SDKConfig holds configuration for the ATP SDK
 type SDKConfig struct {
-	BaseURL         string
-	WSURL           string
-	APIKey          string
-	TenantID        string
-	SessionID       string
-	DefaultTimeout  time.Duration
-	MaxRetries      int
-	RetryDelay      time.Duration
+	BaseURL           string
+	WSURL             string
+	APIKey            string
+	TenantID          string
+	SessionID         string
+	DefaultTimeout    time.Duration
+	MaxRetries        int
+	RetryDelay        time.Duration
 	HeartbeatInterval time.Duration
+	// HeartbeatJitter spreads the heartbeats, capability and health loop
+	// ticks and reconnect delays of clients started together: each wait is
+	// drawn from its nominal duration ± this fraction of it (default: 0.1,
+	// capped at 1). A negative value disables jitter.
+	HeartbeatJitter float64
+	// DefaultQoS is the QoS class of completion requests that set none
+	// (default: QoSGold)
+	DefaultQoS QoS
+	// DefaultMeta is merged under the Meta of every request, as if given
+	// first with WithMeta, e.g. to tag a subsystem's requests with a task
+	// type. Its EnvironmentID is ignored.
+	DefaultMeta Meta
+
+	// WSURLs lists the URLs of equivalent routers, replacing WSURL when
+	// set. Connecting tries them in order, or in an order shuffled once
+	// per client with ShuffleWSURLs, until one answers; after a lost
+	// connection, reconnecting starts with the next one. A URL failing
+	// EndpointFailureThreshold times in a row (default: 2) is skipped for
+	// EndpointCooldown (default: 30s), unless every URL is.
+	WSURLs                   []string
+	ShuffleWSURLs            bool
+	EndpointFailureThreshold int
+	EndpointCooldown         time.Duration
+
+	// HeartbeatMissThreshold, when positive, is how many heartbeats in a
+	// row the router may leave without a heartbeat_ack before the
+	// connection is considered dead: it is closed and OnDisconnect fires
+	// with an error matching ErrHeartbeatTimeout. Zero disables the check,
+	// for routers that do not acknowledge heartbeats.
+	HeartbeatMissThreshold int
+	// WriteTimeout and ReadTimeout, when positive, bound each write to and
+	// each read from the WebSocket connection. A write to a router that
+	// stopped reading fails with ErrWriteTimeout, and a connection silent
+	// for ReadTimeout with ErrReadTimeout; either drops the connection
+	// like any other failure. ReadTimeout should exceed HeartbeatInterval
+	// when the router acknowledges heartbeats.
+	WriteTimeout time.Duration
+	ReadTimeout  time.Duration
+	// DialTimeout bounds opening the TCP connection to the router, and
+	// HandshakeTimeout the WebSocket handshake that follows; both default
+	// to 10s. Connect then fails with an error matching ErrDialTimeout.
+	DialTimeout      time.Duration
+	HandshakeTimeout time.Duration
+	// SessionHandshake makes each connection start with a session.hello
+	// frame describing the client, and Connect wait for the router's
+	// session.welcome, whose values override the config's defaults (see
+	// SessionWelcome). A router rejecting the session fails Connect with an
+	// error matching ErrSessionRejected, and one not answering within
+	// SessionHandshakeTimeout (default: 10s) with ErrSessionHandshakeTimeout.
+	SessionHandshake        bool
+	SessionHandshakeTimeout time.Duration
+	// MaxFrameBytes bounds the size of encoded frames (default: 16 MiB).
+	// A larger incoming frame closes the connection with an error matching
+	// ErrFrameTooLarge, and a larger outgoing one is refused with a
+	// *FrameTooLargeError. The limit of a router's session welcome replaces
+	// it for outgoing frames. A negative value removes the limit.
+	MaxFrameBytes int
+	// AutoReconnect makes the client redial the router in the background
+	// whenever the connection is lost, up to MaxRetries times RetryDelay
+	// apart. Explicit Disconnect and Close never reconnect.
+	AutoReconnect bool
+	// HeartbeatStats adds the client's pending request count, frames sent
+	// and received since the previous heartbeat, reconnect count and SDK
+	// version to the heartbeats of the primary connection (see
+	// HeartbeatStats). Pooled connections send plain heartbeats.
+	HeartbeatStats bool
+
+	// PoolSize, when greater than 1, makes the client keep that many
+	// WebSocket connections to the router. Each stream is pinned to one of
+	// them by consistent hashing on its stream ID, and every connection has
+	// its own heartbeat and is redialled on its own when it fails.
+	PoolSize int
+	// PoolMaxAge, when positive, makes the client replace each pooled
+	// connection besides the primary once it is that old. The requests in
+	// flight on it are first resumed on the connections their streams move
+	// to, see FrameTypeStreamResume.
+	PoolMaxAge time.Duration
+
+	// OutboundQueueSize is the number of frames each connection queues for
+	// its writer goroutine (default: 1024). OverflowPolicy decides what
+	// sending does when the queue is full (default: OverflowBlock).
+	OutboundQueueSize int
+	OverflowPolicy    OverflowPolicy
+
+	// Transport selects how frames reach the router: TransportWebSocket
+	// (the default), TransportHTTP, TransportAuto, or the name of a
+	// transport registered with RegisterTransport. Over HTTP, request
+	// frames are POSTed to BaseURL + HTTPFramesPath (default "/v1/frames"),
+	// sent with HTTPClient (default: a client without timeout, so request
+	// contexts govern).
+	Transport      string
+	HTTPFramesPath string
+	HTTPClient     *http.Client
+	// TransportAddress is the router address dialled by transports
+	// registered with RegisterTransport, such as the gRPC transport.
+	// Defaults to WSURL.
+	TransportAddress string
+	// Codec encodes the frames sent over WebSocket as binary messages and
+	// decodes the binary messages received (default: none, frames are sent
+	// as JSON text). Text messages are decoded as JSON either way.
+	Codec FrameCodec
+
+	// APIKeyProvider, when set, is asked for the API key every time a
+	// connection is dialled and whenever the router announces that the
+	// current key is expiring. It replaces APIKey, which then only serves
+	// until the first call. See also ATPClient.SetAPIKey.
+	APIKeyProvider func(ctx context.Context) (string, error)
+	// TokenSource, when set, supplies bearer tokens sent with every dial
+	// and HTTP request. Tokens are refreshed shortly before they expire and
+	// presented to the router in-band, without reconnecting. A request
+	// failing with a TOKEN_EXPIRED error is retried once after a forced
+	// refresh.
+	TokenSource TokenSource
+
+	// TLSConfig is the base TLS configuration for wss://, https:// and
+	// grpcs:// connections. The client identity and CA options below are
+	// applied to a copy of it.
+	TLSConfig *tls.Config
+	// ClientCertFile and ClientKeyFile hold the PEM client certificate and
+	// key presented to routers that require mutual TLS, and CAFile the PEM
+	// CA certificates the router's certificate is verified against. The
+	// files are reloaded when they change, so rotated certificates are used
+	// from the next connection on. A certificate that cannot be loaded makes
+	// Connect fail with ErrInvalidTLSConfig.
+	ClientCertFile string
+	ClientKeyFile  string
+	CAFile         string
+	// ClientCertPEM, ClientKeyPEM and CAPEM are in-memory alternatives to
+	// the files above
+	ClientCertPEM []byte
+	ClientKeyPEM  []byte
+	CAPEM         []byte
+
+	// OnConnect is invoked after a connection has been established.
+	OnConnect func(sessionID string)
+	// OnDisconnect is invoked when the connection is closed. err is nil for
+	// an explicit Disconnect and non-nil when the connection failed.
+	OnDisconnect func(err error)
+	// OnAsyncError is invoked for errors raised by background goroutines
+	// that have no caller to return them to (e.g. failed heartbeats).
+	OnAsyncError func(err error)
+	// OnTenantSuspended is invoked when the router suspends the client's
+	// tenant with a tenant.suspend control frame.
+	OnTenantSuspended func(suspension *TenantSuspendedError)
+	// OnTenantResumed is invoked when a suspension is lifted, either by a
+	// tenant.resume frame or because it expired.
+	OnTenantResumed func(tenantID string)
+	// OnQuotaWarning is invoked from the read loop when the router warns
+	// that the tenant's quota is running low. It must not block.
+	OnQuotaWarning func(warning QuotaWarning)
+	// OnOrphanResponse is invoked from the read loop when a response
+	// arrives for a request that was already abandoned, e.g. after a
+	// timeout. It must not block.
+	OnOrphanResponse func(orphan OrphanResponse)
+	// OnBudgetThreshold is invoked once per session for each budget
+	// threshold the accumulated spend crosses (see Budget).
+	OnBudgetThreshold func(event BudgetThresholdEvent)
+	// CancelOnTimeout sends a cancel frame for requests abandoned because
+	// of a timeout or a cancelled context, so the router can stop working
+	// on them.
+	CancelOnTimeout bool
+	// TokenCallbackTimeout bounds each call of a WithTokenCallback
+	// callback, which holds up the connection's read loop (default: 1s)
+	TokenCallbackTimeout time.Duration
+	// KeepExpiredFrames delivers incoming frames whose TTL has run out. By
+	// default they are dropped, e.g. responses from a router replaying a
+	// stale queue, and counted in Stats().Frames.Expired.
+	KeepExpiredFrames bool
+	// OnExpiredFrame is invoked from the read loop, with the frame's age,
+	// for each incoming frame dropped because its TTL had run out. It must
+	// not block.
+	OnExpiredFrame func(frame Frame, age time.Duration)
+	// MaxRequestTTL bounds the TTL of requests made with a context
+	// deadline, which is otherwise the time left before it (default: 5m)
+	MaxRequestTTL time.Duration
+	// StrictFrameTypes rejects sending frames of, and subscribing to, types
+	// missing from DefaultFrameTypes, catching typos in frame types early.
+	StrictFrameTypes bool
+	// StrictDecoding drops incoming frames with fields Frame does not have,
+	// or without a type or timestamp, as DeserializeFrameStrict rejects
+	// them, exposing protocol drift in the router. They are logged and
+	// counted in Stats().Frames.StrictFailures.
+	StrictDecoding bool
+
+	// AuditSink, when set, receives every frame sent and received. Wrap it
+	// in an AuditSampler to reduce volume.
+	AuditSink AuditSink
+	// Recorder, when set, receives every frame written to or read from the
+	// router, exactly as it crossed the wire, with the connection it went
+	// over. See FileRecorder and ReplayRecording.
+	Recorder FrameRecorder
+	// OnRawSend is invoked with every message just before it is written to
+	// the router, and OnRawReceive with every message just after it is
+	// read, before it is decoded. msgType is websocket.TextMessage or
+	// websocket.BinaryMessage; HTTP requests and responses are text. The
+	// hooks run on the read and write loops, so they must not block, and
+	// must not retain or modify data unless CopyRawData is set.
+	OnRawSend    func(msgType int, data []byte)
+	OnRawReceive func(msgType int, data []byte)
+	// CopyRawData hands OnRawSend and OnRawReceive copies of the messages,
+	// which they may keep
+	CopyRawData bool
+
+	// SendInterceptors run in order on every outgoing frame before it is
+	// serialized. An error aborts the send.
+	SendInterceptors []func(*Frame) error
+	// ReceiveInterceptors run in order on every incoming frame before it is
+	// dispatched. An error drops the frame with a logged warning.
+	ReceiveInterceptors []func(*Frame) error
+
+	// Logger receives SDK warnings (default: standard output)
+	Logger Logger
+
+	// ModelFallbacks registers client-wide model fallback chains keyed by
+	// the task type of the request's Meta, "completion" for requests that
+	// set none. WithModelFallbacks overrides them per request.
+	ModelFallbacks map[string][]string
+
+	// Introspection controls the answers to introspect.request frames sent
+	// by router-side tooling.
+	Introspection IntrospectionConfig
+
+	// PayloadSchemas maps frame types to JSON Schema documents. Outgoing
+	// and incoming payloads of those types are validated, and frames that
+	// fail are rejected with a *SchemaValidationError. DefaultPayloadSchemas
+	// returns schemas for the frames FrameBuilder produces.
+	PayloadSchemas map[string]string
+
+	// SigningKey enables frame signing: every outgoing frame carries an
+	// HMAC-SHA256 signature under this key, and incoming frames must carry
+	// a valid one
+	SigningKey []byte
+	// VerificationKeys are additional keys accepted for incoming
+	// signatures, e.g. the previous key during a rotation. Setting them
+	// without a SigningKey verifies incoming frames without signing.
+	VerificationKeys [][]byte
+	// OnSignatureFailure is called for each incoming frame dropped because
+	// its signature is missing or invalid
+	OnSignatureFailure func(frame Frame, err error)
+
+	// EncryptionKey enables end-to-end payload encryption with AES-256-GCM.
+	// It must be 32 bytes long.
+	EncryptionKey []byte
+	// PayloadCipher replaces the AES-256-GCM cipher built from
+	// EncryptionKey
+	PayloadCipher PayloadCipher
+	// EncryptionExclude lists the frame types sent in the clear when
+	// encryption is enabled (default: heartbeat and adapter.health)
+	EncryptionExclude []string
+
+	// Budget configures a session spend budget with threshold alerts
+	Budget BudgetConfig
+
+	// Replay configures replaying unanswered requests after reconnecting
+	// (default: disabled)
+	Replay ReplayConfig
+	// Outbox configures a durable outbox for fire-and-forget capability and
+	// health frames sent while disconnected (default: disabled)
+	Outbox OutboxConfig
+	// ModelCacheTTL is how long ListModels reuses the models it discovered
+	// (default: 0, every call asks the router)
+	ModelCacheTTL time.Duration
+
+	// Clock is the time source (default: the system clock)
+	Clock Clock
+	// IDGenerator mints the session ID when SessionID is empty, stream IDs
+	// and idempotency keys (default: UUIDGenerator)
+	IDGenerator IDGenerator
+
+	// Metrics receives SDK metrics (default: discarded)
+	Metrics MetricsSink
+	// SubscriptionBuffer is the channel capacity of each Subscribe
+	// subscriber (default: 64)
+	SubscriptionBuffer int
+
+	// DebugChecks enables misuse detection, such as panicking when a
+	// single-goroutine type is shared across goroutines. It can also be
+	// enabled with the ATP_SDK_DEBUG_CHECKS environment variable.
+	DebugChecks bool
 }
 
 // Frame represents an ATP protocol frame
 type Frame struct {
 	Type      string                 `json:"type"`
-	Timestamp int64                  `json:"ts"`
+	Version   string                 `json:"version,omitempty"`
+	Timestamp FrameTime              `json:"ts"` // see Time and SetTime
 	StreamID  string                 `json:"stream_id,omitempty"`
 	MsgSeq    int                    `json:"msg_seq,omitempty"`
 	FragSeq   int                    `json:"frag_seq,omitempty"`
 	Flags     []string               `json:"flags,omitempty"`
-	QoS       string                 `json:"qos,omitempty"`
+	QoS       QoS                    `json:"qos,omitempty"`
 	TTL       int                    `json:"ttl,omitempty"`
-	Window    Window                 `json:"window,omitempty"`
-	Meta      Meta                   `json:"meta,omitempty"`
+	Window    Window                 `json:"window,omitzero"`
+	Meta      Meta                   `json:"meta,omitzero"`
 	Payload   map[string]interface{} `json:"payload"`
+	// RawPayload is the undecoded payload of a frame read with
+	// DeserializeFrameRaw. It is sent as is while Payload is nil.
+	RawPayload json.RawMessage `json:"-"`
+	// Signature is the HMAC-SHA256 of the frame when signing is enabled
+	Signature string `json:"sig,omitempty"`
 }
 
 // Window represents flow control window information
 type Window struct {
-	MaxParallel  int `json:"max_parallel"`
-	MaxTokens    int `json:"max_tokens"`
-	MaxUSD       int `json:"max_usd_micros"`
+	MaxParallel int `json:"max_parallel"`
+	MaxTokens   int `json:"max_tokens"`
+	MaxUSD      int `json:"max_usd_micros"`
 }
 
 // Meta contains metadata for the frame
 type Meta struct {
-	TaskType       string            `json:"task_type,omitempty"`
-	Languages      []string          `json:"languages,omitempty"`
-	Risk           string            `json:"risk,omitempty"`
-	DataScope      []string          `json:"data_scope,omitempty"`
-	Trace          interface{}       `json:"trace,omitempty"`
-	ToolPermissions []string         `json:"tool_permissions,omitempty"`
-	EnvironmentID  string            `json:"environment_id,omitempty"`
-	SecurityGroups []string          `json:"security_groups,omitempty"`
+	TaskType        string        `json:"task_type,omitempty"`
+	Languages       []string      `json:"languages,omitempty"`
+	Risk            string        `json:"risk,omitempty"`
+	DataScope       []string      `json:"data_scope,omitempty"`
+	Trace           *TraceContext `json:"trace,omitempty"`
+	ToolPermissions []string      `json:"tool_permissions,omitempty"`
+	EnvironmentID   string        `json:"environment_id,omitempty"`
+	SecurityGroups  []string      `json:"security_groups,omitempty"`
+	IdempotencyKey  string        `json:"idempotency_key,omitempty"`
+	// CorrelationID identifies the request the frame belongs to, see
+	// WithCorrelationID
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// PreferredModel, PreferredAdapterID and ExcludeAdapters mirror the
+	// routing preferences of a completion request
+	PreferredModel     string   `json:"preferred_model,omitempty"`
+	PreferredAdapterID string   `json:"preferred_adapter_id,omitempty"`
+	ExcludeAdapters    []string `json:"exclude_adapters,omitempty"`
 }
 
 // CompletionRequest represents a completion request
 type CompletionRequest struct {
-	Prompt      string  `json:"prompt"`
-	MaxTokens   int     `json:"max_tokens,omitempty"`
-	Temperature float64 `json:"temperature,omitempty"`
-	TopP        float64 `json:"top_p,omitempty"`
+	Prompt      string   `json:"prompt"`
+	Model       string   `json:"model,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Temperature float64  `json:"temperature,omitempty"`
+	TopP        float64  `json:"top_p,omitempty"`
 	Stop        []string `json:"stop,omitempty"`
+	// QoS is the request's QoS class, SDKConfig.DefaultQoS when empty. Gold
+	// requests are sent and opened ahead of silver and bronze ones.
+	QoS QoS `json:"qos,omitempty"`
+	// IdempotencyKey lets the router recognise a retried request and serve
+	// it from its dedupe cache instead of running it again. Complete fills
+	// it in from the IDGenerator when it is empty and MaxRetries is
+	// positive.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	// PreferredModel and PreferredAdapterID hint which model and adapter
+	// should serve the request, and ExcludeAdapters which adapters should
+	// not. They are sent in the payload and in Meta; the router's policy
+	// may override them.
+	PreferredModel     string   `json:"preferred_model,omitempty"`
+	PreferredAdapterID string   `json:"preferred_adapter_id,omitempty"`
+	ExcludeAdapters    []string `json:"exclude_adapters,omitempty"`
 }
 
 // CompletionResponse represents a completion response
 type CompletionResponse struct {
-	Text        string  `json:"text"`
-	ModelUsed   string  `json:"model_used"`
-	TokensIn    int     `json:"tokens_in"`
-	TokensOut   int     `json:"tokens_out"`
-	CostUSD     float64 `json:"cost_usd"`
+	Text         string  `json:"text"`
+	ModelUsed    string  `json:"model_used"`
+	TokensIn     int     `json:"tokens_in"`
+	TokensOut    int     `json:"tokens_out"`
+	CostUSD      float64 `json:"cost_usd"`
 	QualityScore float64 `json:"quality_score"`
-	Finished    bool    `json:"finished"`
+	Finished     bool    `json:"finished"`
+	// AdapterID identifies the adapter that served the response, when the
+	// router reports it
+	AdapterID string `json:"adapter_id,omitempty"`
+
+	// FallbackDepth is the index in the model fallback chain of the model
+	// that served the request, or in the WithFallbacks preferences of the
+	// preference that did (0 when the first choice succeeded).
+	FallbackDepth int `json:"fallback_depth,omitempty"`
+	// FallbackErrors holds the errors of the models or preferences tried
+	// before it.
+	FallbackErrors []error `json:"-"`
+
+	// QualityAttempts holds every attempt of a request made with
+	// WithMinQuality, this response's included, in order.
+	QualityAttempts []QualityAttempt `json:"-"`
+
+	// Replayed is set when the router served the response from its dedupe
+	// cache, for a request with an IdempotencyKey it had already answered.
+	Replayed bool `json:"replayed,omitempty"`
+
+	// PreferenceHonored is set when the request had a PreferredModel and
+	// ModelUsed is that model
+	PreferenceHonored bool `json:"-"`
+
+	// TraceParent is the W3C traceparent of the response frame, empty
+	// when it carries none
+	TraceParent string `json:"-"`
+
+	// Timings reports the delays the client added to the request, such as
+	// moving it to another pool connection
+	Timings Timings `json:"-"`
 }
 
 // CapabilityAdvertisement represents an adapter's capability advertisement
 type CapabilityAdvertisement struct {
-	AdapterID          string            `json:"adapter_id"`
-	AdapterType        string            `json:"adapter_type"`
-	Capabilities       []string          `json:"capabilities"`
-	Models             []string          `json:"models"`
-	MaxTokens          *int              `json:"max_tokens,omitempty"`
-	SupportedLanguages []string          `json:"supported_languages,omitempty"`
-	CostPerTokenMicros *int              `json:"cost_per_token_micros,omitempty"`
-	HealthEndpoint     *string           `json:"health_endpoint,omitempty"`
-	Version            *string           `json:"version,omitempty"`
+	AdapterID          string                 `json:"adapter_id"`
+	AdapterType        string                 `json:"adapter_type"`
+	Capabilities       []string               `json:"capabilities"`
+	Models             []string               `json:"models"`
+	MaxTokens          *int                   `json:"max_tokens,omitempty"`
+	SupportedLanguages []string               `json:"supported_languages,omitempty"`
+	CostPerTokenMicros *int                   `json:"cost_per_token_micros,omitempty"`
+	HealthEndpoint     *string                `json:"health_endpoint,omitempty"`
+	Version            *string                `json:"version,omitempty"`
 	Metadata           map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // HealthStatus represents an adapter's health status and telemetry
 type HealthStatus struct {
-	AdapterID          string                 `json:"adapter_id"`
-	Status             string                 `json:"status"`
-	P95LatencyMS       *float64               `json:"p95_latency_ms,omitempty"`
-	P50LatencyMS       *float64               `json:"p50_latency_ms,omitempty"`
-	P99LatencyMS       *float64               `json:"p99_latency_ms,omitempty"`
-	RequestsPerSecond  *float64               `json:"requests_per_second,omitempty"`
-	ErrorRate          *float64               `json:"error_rate,omitempty"`
-	QueueDepth         *int                   `json:"queue_depth,omitempty"`
-	MemoryUsageMB      *float64               `json:"memory_usage_mb,omitempty"`
-	CPUUsagePercent    *float64               `json:"cpu_usage_percent,omitempty"`
-	UptimeSeconds      *int                   `json:"uptime_seconds,omitempty"`
-	Version            *string                `json:"version,omitempty"`
-	LastHealthCheck    *float64               `json:"last_health_check,omitempty"`
-	Metadata           map[string]interface{} `json:"metadata,omitempty"`
+	AdapterID         string                 `json:"adapter_id"`
+	Status            string                 `json:"status"`
+	P95LatencyMS      *float64               `json:"p95_latency_ms,omitempty"`
+	P50LatencyMS      *float64               `json:"p50_latency_ms,omitempty"`
+	P99LatencyMS      *float64               `json:"p99_latency_ms,omitempty"`
+	RequestsPerSecond *float64               `json:"requests_per_second,omitempty"`
+	ErrorRate         *float64               `json:"error_rate,omitempty"`
+	QueueDepth        *int                   `json:"queue_depth,omitempty"`
+	MemoryUsageMB     *float64               `json:"memory_usage_mb,omitempty"`
+	CPUUsagePercent   *float64               `json:"cpu_usage_percent,omitempty"`
+	UptimeSeconds     *int                   `json:"uptime_seconds,omitempty"`
+	Version           *string                `json:"version,omitempty"`
+	LastHealthCheck   *float64               `json:"last_health_check,omitempty"`
+	Metadata          map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // ATPClient is the main client for interacting with ATP Router
 type ATPClient struct {
-	config     SDKConfig
-	conn       *websocket.Conn
-	connMutex  sync.RWMutex
-	connected  bool
-	responseHandlers map[string]chan *Frame
+	*clientCore // shared with the clones of the client
+	config      SDKConfig
+	usage       usageCounters
+	root        *ATPClient  // the client cloned, nil unless a clone
+	released    atomic.Bool // set when a clone is closed
+}
+
+// clientCore is the connection and the state tied to it, shared by a
+// client and its clones
+type clientCore struct {
+	conn             Transport
+	connMutex        sync.RWMutex
+	out              *outboundQueue // of conn, drained by its writeLoop
+	pumps            sync.WaitGroup // read, write and heartbeat loops of every connection
+	builder          *FrameBuilder
+	heartbeat        *heartbeatMonitor // of the primary connection
+	connected        bool
+	responseHandlers map[string]*pendingResponse
+	abandoned        map[string]abandonedRequest // guarded by handlerMutex
+	abandonedOrder   []string
+	streams          map[string]*Stream // open streams, guarded by handlerMutex
+	replay           replayBuffer
+	outboxMutex      sync.Mutex // serializes outbox flushes
 	handlerMutex     sync.RWMutex
-	ctx             context.Context
-	cancel          context.CancelFunc
+	adapterServer    *AdapterServer
+	subscriptions    map[string][]*subscription
+	subMutex         sync.RWMutex
+	connects         connectWatchers
+	suspension       tenantSuspension
+	topics           topicRegistry
+	advertised       advertisedCapabilities
+	paused           pausedAdapters
+	models           modelCache
+	budget           budgetTracker
+	pool             *connPool // nil unless PoolSize > 1
+	endpoints        *endpointSet
+	introspection    introspectionLimiter
+	httpTransport    atomic.Bool                    // requests go over HTTP; set once on connect
+	routerVersion    atomic.Pointer[string]         // protocol version of the router's frames
+	welcome          atomic.Pointer[SessionWelcome] // answer to the latest session handshake
+	connSeq          atomic.Uint64                  // connections opened, numbering them for the Recorder
+	schemas          map[string]*payloadSchema      // read-only after NewATPClient
+	schemaErrors     map[string]error
+	encryptionErr    error // set when EncryptionKey is unusable
+	configErr        error // the problems SDKConfig.Validate found
+	auth             apiKeyState
+	tls              *tlsIdentity // nil unless a TLS option is set
+	tlsErr           error        // set when the TLS identity cannot be loaded
+	tokens           tokenState
+	flow             flowControl
+	counters         clientCounters
+	ctx              context.Context // cancelled by Close only
+	cancel           context.CancelFunc
+	epochMutex       sync.Mutex
+	epochCtx         context.Context // current connection epoch, see epoch
+	epochCancel      context.CancelFunc
 }
 
-// NewATPClient creates a new ATP client with the given configuration
+// NewATPClient creates a new ATP client with the given configuration. An
+// invalid configuration, as reported by SDKConfig.Validate, is logged, and
+// makes Connect fail.
 func NewATPClient(config SDKConfig) *ATPClient {
+	configErr := config.Validate()
 	if config.BaseURL == "" {
 		config.BaseURL = "http://localhost:8000"
 	}
+	if config.WSURL == "" && len(config.WSURLs) > 0 {
+		config.WSURL = config.WSURLs[0]
+	}
 	if config.WSURL == "" {
 		config.WSURL = "ws://localhost:8000"
 	}
+	if len(config.WSURLs) == 0 {
+		config.WSURLs = []string{config.WSURL}
+	}
+	if config.EndpointFailureThreshold <= 0 {
+		config.EndpointFailureThreshold = defaultEndpointFailureThreshold
+	}
+	if config.EndpointCooldown <= 0 {
+		config.EndpointCooldown = defaultEndpointCooldown
+	}
 	if config.TenantID == "" {
 		config.TenantID = "default"
 	}
+	if config.IDGenerator == nil {
+		config.IDGenerator = UUIDGenerator{}
+	}
 	if config.SessionID == "" {
-		config.SessionID = fmt.Sprintf("session_%d_%d", time.Now().Unix(), time.Now().Nanosecond())
+		config.SessionID = "session_" + config.IDGenerator.NewID()
 	}
 	if config.DefaultTimeout == 0 {
 		config.DefaultTimeout = 30 * time.Second
 	}
+	if config.DefaultQoS == "" {
+		config.DefaultQoS = QoSGold
+	}
+	if config.MaxRequestTTL <= 0 {
+		config.MaxRequestTTL = defaultMaxRequestTTL
+	}
+	if config.Replay.MaxAge <= 0 {
+		config.Replay.MaxAge = config.DefaultTimeout
+	}
+	if config.Outbox.MaxAge <= 0 {
+		config.Outbox.MaxAge = defaultOutboxMaxAge
+	}
+	if config.Transport == "" {
+		config.Transport = TransportWebSocket
+	}
+	if config.OutboundQueueSize <= 0 {
+		config.OutboundQueueSize = defaultOutboundQueueSize
+	}
+	if config.OverflowPolicy == "" {
+		config.OverflowPolicy = OverflowBlock
+	}
+	if config.HTTPFramesPath == "" {
+		config.HTTPFramesPath = defaultHTTPFramesPath
+	}
+	defaultHTTPClient := config.HTTPClient == nil
+	if defaultHTTPClient {
+		config.HTTPClient = &http.Client{}
+	}
 	if config.MaxRetries == 0 {
 		config.MaxRetries = 3
 	}
@@ -149,78 +589,299 @@ func NewATPClient(config SDKConfig) *ATPClient {
 	if config.HeartbeatInterval == 0 {
 		config.HeartbeatInterval = 30 * time.Second
 	}
+	if config.HeartbeatJitter == 0 {
+		config.HeartbeatJitter = defaultHeartbeatJitter
+	}
+	config.HeartbeatJitter = min(config.HeartbeatJitter, 1)
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = defaultDialTimeout
+	}
+	if config.HandshakeTimeout <= 0 {
+		config.HandshakeTimeout = defaultDialTimeout
+	}
+	if config.SessionHandshakeTimeout <= 0 {
+		config.SessionHandshakeTimeout = defaultSessionHandshakeTimeout
+	}
+	if config.MaxFrameBytes == 0 {
+		config.MaxFrameBytes = defaultMaxFrameBytes
+	}
+	if config.Logger == nil {
+		config.Logger = stdoutLogger{}
+	}
+	if config.Clock == nil {
+		config.Clock = realClock{}
+	}
+	if config.Budget.Thresholds == nil {
+		config.Budget.Thresholds = defaultBudgetThresholds
+	}
+	config.Budget.Thresholds = slices.Sorted(slices.Values(config.Budget.Thresholds))
+	if config.Budget.BurnRateWindow == 0 {
+		config.Budget.BurnRateWindow = defaultBurnRateWindow
+	}
+	if config.Metrics == nil {
+		config.Metrics = noopMetrics{}
+	}
+	if config.Introspection.MinInterval == 0 {
+		config.Introspection.MinInterval = defaultIntrospectionInterval
+	}
+	if config.SubscriptionBuffer == 0 {
+		config.SubscriptionBuffer = defaultSubscriptionBuffer
+	}
+	if config.TokenCallbackTimeout <= 0 {
+		config.TokenCallbackTimeout = defaultTokenCallbackTimeout
+	}
+	if debugChecksFromEnv() {
+		config.DebugChecks = true
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-
-	return &ATPClient{
-		config:          config,
-		responseHandlers: make(map[string]chan *Frame),
-		ctx:             ctx,
-		cancel:          cancel,
+	builder := NewFrameBuilder(config.SessionID, config.TenantID)
+	builder.SetClock(config.Clock)
+
+	client := &ATPClient{config: config, clientCore: &clientCore{
+		builder:          builder,
+		heartbeat:        newHeartbeatMonitor(builder, "heartbeat_"+config.SessionID),
+		responseHandlers: make(map[string]*pendingResponse),
+		subscriptions:    make(map[string][]*subscription),
+		endpoints:        newEndpointSet(config.WSURLs, config.ShuffleWSURLs),
+		counters:         clientCounters{clock: config.Clock},
+		ctx:              ctx,
+		cancel:           cancel,
+	}}
+	client.auth.current.Store(&config.APIKey)
+	if configErr != nil {
+		client.configErr = configErr
+		config.Logger.Printf("Warning: %v", configErr)
+	}
+	if config.PoolSize > 1 {
+		client.pool = newConnPool(config.PoolSize)
+		for _, m := range client.pool.members {
+			m.heartbeat = newHeartbeatMonitor(builder, fmt.Sprintf("heartbeat_%s_%d", config.SessionID, m.index))
+		}
+	}
+	client.compilePayloadSchemas()
+	client.setupEncryption()
+	client.setupTLS()
+	if defaultHTTPClient && tlsConfigured(&config) {
+		client.config.HTTPClient.Transport = client.httpTLSTransport()
 	}
+	return client
 }
 
-// Connect establishes a WebSocket connection to the ATP Router
+// Connect establishes a WebSocket connection to the ATP Router. It fails
+// with the error of SDKConfig.Validate when the configuration is invalid.
 func (c *ATPClient) Connect() error {
+	if c.root != nil {
+		if c.released.Load() {
+			return ErrClientClosed
+		}
+		return c.root.Connect()
+	}
+	if c.configErr != nil {
+		return c.configErr
+	}
+	connected, err := c.connect()
+	if err != nil {
+		return err
+	}
+	if connected {
+		if c.config.OnConnect != nil {
+			c.config.OnConnect(c.config.SessionID)
+		}
+		c.notifyConnected()
+		c.replayUnanswered()
+		go c.flushOutbox()
+	}
+	return nil
+}
+
+// Close disconnects the client and releases it; unlike Disconnect, it is
+// final. Every method called on a closed client returns ErrClientClosed.
+// It returns once the goroutines reading, writing and heartbeating every
+// connection have exited, so it must not be called from a callback
+// handling an incoming frame, such as a ReceiveInterceptor; OnDisconnect
+// may call it.
+func (c *ATPClient) Close() error {
+	if c.root != nil {
+		c.released.Store(true)
+		return nil
+	}
+	err := c.Disconnect()
+	c.cancel()
+	c.closeSubscriptions()
+	c.closeStreams()
+	c.pumps.Wait()
+	return err
+}
+
+// startPump runs pump, one of the loops serving a connection, in a
+// goroutine that Close waits for. Pumps are started with the connection
+// lock or a pool member's lock held, after checking that the client is
+// not closed, so none starts once Close waits.
+func (c *ATPClient) startPump(pump func()) {
+	c.pumps.Add(1)
+	go func() {
+		defer c.pumps.Done()
+		pump()
+	}()
+}
+
+// closed reports whether the client, or the client it is a clone of, has
+// been closed
+func (c *ATPClient) closed() bool {
+	return c.ctx.Err() != nil || c.released.Load()
+}
+
+// epoch returns the context of the current connection epoch: the span
+// from a Connect to the next Disconnect, automatic reconnects included.
+// Goroutines that only make sense while the client is meant to be
+// connected stop when it is cancelled. It is derived from the client's
+// context, and the first call after a Disconnect starts a new epoch.
+func (c *ATPClient) epoch() context.Context {
+	c.epochMutex.Lock()
+	defer c.epochMutex.Unlock()
+	if c.epochCtx == nil || c.epochCtx.Err() != nil {
+		c.epochCtx, c.epochCancel = context.WithCancel(c.ctx)
+	}
+	return c.epochCtx
+}
+
+// endEpoch cancels the current connection epoch
+func (c *ATPClient) endEpoch() {
+	c.epochMutex.Lock()
+	defer c.epochMutex.Unlock()
+	if c.epochCancel != nil {
+		c.epochCancel()
+	}
+}
+
+// connect dials the router under the connection lock. It reports whether a
+// new connection was established so Connect can fire OnConnect after the
+// lock has been released.
+func (c *ATPClient) connect() (bool, error) {
 	c.connMutex.Lock()
 	defer c.connMutex.Unlock()
 
+	if c.closed() {
+		return false, ErrClientClosed
+	}
 	if c.connected {
-		return nil
+		return false, nil
 	}
-
-	// Parse WebSocket URL
-	wsURL, err := url.Parse(c.config.WSURL)
-	if err != nil {
-		return fmt.Errorf("invalid WebSocket URL: %w", err)
+	if c.tlsErr != nil {
+		return false, c.tlsErr
 	}
 
-	// Add query parameters
-	query := wsURL.Query()
-	query.Set("session_id", c.config.SessionID)
-	query.Set("tenant_id", c.config.TenantID)
-	if c.config.APIKey != "" {
-		query.Set("api_key", c.config.APIKey)
+	if c.config.Transport == TransportHTTP {
+		c.connectHTTP()
+		return true, nil
 	}
-	wsURL.RawQuery = query.Encode()
 
-	// Connect to WebSocket
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL.String(), nil)
+	conn, err := c.dialFailover()
 	if err != nil {
-		return fmt.Errorf("failed to connect to WebSocket: %w", err)
+		if c.config.Transport != TransportAuto || errors.Is(err, ErrSessionRejected) {
+			return false, err
+		}
+		c.config.Logger.Printf("Warning: Falling back to the HTTP transport: %v", err)
+		c.connectHTTP()
+		return true, nil
 	}
 
 	c.conn = conn
+	c.out = c.newConnQueue()
 	c.connected = true
+	c.heartbeat.reset()
+	c.recordConnect()
 
-	// Start message handling goroutine
-	go c.handleMessages()
+	// The connection's pumps own conn until it is torn down
+	out, epoch := c.out, c.epoch()
+	c.startPump(func() { c.handleMessages(epoch, conn) })
+	c.startPump(func() { c.writeLoop(conn, out, func(err error) { c.connectionLost(conn, err) }) })
+	c.startPump(func() { c.sendHeartbeats(epoch, conn) })
 
-	// Start heartbeat goroutine
-	go c.sendHeartbeats()
+	c.connectPoolMembers()
 
-	return nil
+	return true, nil
 }
 
-// Disconnect closes the WebSocket connection
+// Disconnect closes the WebSocket connection, and every pooled connection.
+// It stops automatic reconnection and the loops tied to the connection,
+// and fails the requests waiting for a response with ErrNotConnected. The
+// client can be connected again with Connect.
 func (c *ATPClient) Disconnect() error {
+	if c.root != nil {
+		if c.released.Load() {
+			return ErrClientClosed
+		}
+		return c.root.Disconnect()
+	}
+	disconnected, err := c.disconnect()
+	if disconnected && c.config.OnDisconnect != nil {
+		c.config.OnDisconnect(nil)
+	}
+	return err
+}
+
+// disconnect tears down the connection under the connection lock and
+// reports whether there was a connection to tear down.
+func (c *ATPClient) disconnect() (bool, error) {
 	c.connMutex.Lock()
 	defer c.connMutex.Unlock()
 
+	c.closePoolMembers()
+	c.endEpoch()
 	if !c.connected {
-		return nil
+		return false, nil
 	}
 
-	c.cancel() // Cancel context to stop goroutines
 	c.connected = false
+	c.counters.recordDisconnect(nil)
 
 	if c.conn != nil {
+		c.closeQueues(c.out)
 		err := c.conn.Close()
 		c.conn = nil
-		return err
+		return true, err
 	}
 
-	return nil
+	return true, nil
+}
+
+// connectionLost marks the connection as failed, the one place a lost
+// connection changes the client's state, and fires OnDisconnect. It is a
+// no-op if the client was disconnected explicitly in the meantime. The
+// callbacks and reconnection run in their own goroutine, so the pump
+// reporting the loss exits right away.
+func (c *ATPClient) connectionLost(conn Transport, cause error) {
+	c.connMutex.Lock()
+	if !c.connected || c.conn != conn {
+		c.connMutex.Unlock()
+		return
+	}
+	c.connected = false
+	c.conn = nil
+	c.out.close()
+	c.counters.recordDisconnect(cause)
+	_ = conn.Close()
+	epoch := c.epoch()
+	c.connMutex.Unlock()
+	c.endpointLost()
+
+	go func() {
+		if c.config.OnDisconnect != nil {
+			c.config.OnDisconnect(cause)
+		}
+		c.failUnreplayable()
+		c.reconnect(epoch)
+	}()
+}
+
+// reportAsyncError forwards an error from a background goroutine to the
+// OnAsyncError callback, if one is configured.
+func (c *ATPClient) reportAsyncError(err error) {
+	if c.config.OnAsyncError != nil {
+		c.config.OnAsyncError(err)
+	}
 }
 
 // IsConnected returns whether the client is connected
@@ -230,218 +891,570 @@ func (c *ATPClient) IsConnected() bool {
 	return c.connected
 }
 
-// Complete sends a completion request and waits for response
-func (c *ATPClient) Complete(ctx context.Context, request CompletionRequest) (*CompletionResponse, error) {
+// Complete sends a completion request and waits for response. Errors of
+// the request are *RequestError values carrying its correlation ID.
+func (c *ATPClient) Complete(ctx context.Context, request CompletionRequest, opts ...RequestOption) (_ *CompletionResponse, err error) {
+	if c.closed() {
+		return nil, ErrClientClosed
+	}
+	options := c.requestOptions(opts)
+	if options.err != nil {
+		return nil, options.err
+	}
+	correlationID := c.correlate(&options)
+	defer func() { err = correlateError(err, correlationID) }()
+	if err := c.applyQoS(&request, options); err != nil {
+		return nil, err
+	}
+	if options.tenant != "" {
+		ctx = ContextWithTenant(ctx, options.tenant)
+	}
+	ctx = contextWithMeta(ctx, ensureTrace(options.meta))
+	ctx = contextWithTokenCallback(ctx, options.tokenCallback)
+	ctx = contextWithHedging(ctx, options.hedging)
+	// Suspensions only concern the client's own tenant
+	if c.tenantOf(ctx) == c.config.TenantID {
+		if suspension := c.tenantSuspended(); suspension != nil {
+			return nil, suspension
+		}
+	}
+	if c.budgetExceeded() {
+		return nil, ErrBudgetExceeded
+	}
+	if _, ok := ctx.Deadline(); options.timeout <= 0 && options.ttl > 0 && !ok {
+		// The TTL bounds the wait as well
+		options.timeout = options.ttl
+	}
+	if options.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+		defer cancel()
+	}
+	if request.IdempotencyKey == "" && c.config.MaxRetries > 0 {
+		request.IdempotencyKey = c.config.IDGenerator.NewID()
+	}
+
+	chain := options.modelFallbacks
+	if len(chain) == 0 {
+		taskType := withMeta(Meta{}, options.meta).TaskType
+		if taskType == "" {
+			taskType = "completion"
+		}
+		chain = c.config.ModelFallbacks[taskType]
+	}
+	if options.chunking != nil {
+		if chunks := options.chunking.Split(request.Prompt); len(chunks) > 1 {
+			return c.completeChunked(ctx, request, chunks, chain)
+		}
+	}
+
+	if options.minQuality != nil {
+		return c.completeWithQuality(ctx, request, chain, options.fallbacks, *options.minQuality)
+	}
+	return c.completeRouted(ctx, request, chain, options.fallbacks)
+}
+
+// completeRequest completes request on streamID, or on a stream of its own
+// when streamID is empty, trying the models of chain in turn if any
+func (c *ATPClient) completeRequest(ctx context.Context, streamID string, request CompletionRequest, chain []string) (*CompletionResponse, error) {
+	if len(chain) > 0 {
+		return c.completeWithFallbacks(ctx, streamID, request, chain)
+	}
+	return c.complete(ctx, streamID, request)
+}
+
+// complete performs a completion exchange, retried up to MaxRetries times
+// while the router reports a transient failure
+func (c *ATPClient) complete(ctx context.Context, streamID string, request CompletionRequest) (*CompletionResponse, error) {
+	if policy := hedgingFor(ctx, streamID); policy != nil {
+		return c.completeHedged(ctx, request, *policy)
+	}
+	for attempt := 1; ; attempt++ {
+		response, err := c.completeAuthenticated(ctx, streamID, request)
+		if err == nil || !isRetryable(err) || attempt > c.config.MaxRetries {
+			return response, err
+		}
+		if !c.awaitRetry(ctx, err) {
+			return nil, err
+		}
+	}
+}
+
+// completeAuthenticated performs a single completion exchange, retried once
+// after a forced token refresh if the router reports the bearer token as
+// expired
+func (c *ATPClient) completeAuthenticated(ctx context.Context, streamID string, request CompletionRequest) (*CompletionResponse, error) {
+	response, err := c.completeOnce(ctx, streamID, request)
+	if !c.tokenExpired(err) {
+		return response, err
+	}
+	if refreshErr := c.refreshExpiredToken(); refreshErr != nil {
+		c.config.Logger.Printf("Warning: Failed to refresh the expired token%s: %v", logCorrelation(metaCorrelationID(ctx)), refreshErr)
+		return nil, err
+	}
+	return c.completeOnce(ctx, streamID, request)
+}
+
+// completeOnce sends one completion request on streamID, or on a new
+// stream when streamID is empty, and waits for its response
+func (c *ATPClient) completeOnce(ctx context.Context, streamID string, request CompletionRequest) (*CompletionResponse, error) {
 	if !c.IsConnected() {
 		if err := c.Connect(); err != nil {
 			return nil, fmt.Errorf("failed to connect: %w", err)
 		}
 	}
 
-	streamID := fmt.Sprintf("completion_%d_%d", time.Now().Unix(), time.Now().Nanosecond())
+	builder := c.builderFor(ctx)
+	if streamID == "" {
+		streamID = c.newStreamID("completion")
+		defer builder.ReleaseStream(streamID)
+	}
+	frame := builder.BuildCompletionFrame(streamID, request, metaFromContext(ctx)...)
+	hedge := hedgeAttemptOf(ctx)
+	if hedge != nil && hedge.duplicate {
+		frame.Flags = append(frame.Flags, FlagHedge)
+	}
 
-	// Create frame builder if not exists
-	frameBuilder := NewFrameBuilder(c.config.SessionID, c.config.TenantID)
-	frame := frameBuilder.BuildCompletionFrame(streamID, request)
+	// Wait for room in the router's flow-control window
+	if err := c.acquireWindow(ctx, streamID, framePriority(frame.QoS)); err != nil {
+		return nil, err
+	}
+	var usage *CompletionResponse
+	defer func() { c.releaseWindow(streamID, usage) }()
 
 	// Send frame
-	if err := c.sendFrame(frame); err != nil {
+	chunks := c.newChunkCollector(ctx)
+	pending := c.expectChunkedResponse(frame, chunks)
+	if err := c.sendRequest(ctx, frame); err != nil {
+		c.discardPending(pending, false)
 		return nil, fmt.Errorf("failed to send frame: %w", err)
 	}
 
 	// Wait for response
-	responseFrame, err := c.waitForResponse(ctx, streamID, frame.MsgSeq)
+	responseFrame, err := c.waitForResponse(ctx, pending)
+	if errors.Is(err, ErrTokenCallback) {
+		c.cancelRequest(ctx, pending, err.Error())
+		return nil, err
+	}
+	if err != nil && hedge != nil && hedge.lost.Load() && !c.config.CancelOnTimeout {
+		c.cancelRequest(ctx, pending, "hedged request lost")
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get response: %w", err)
 	}
 
 	// Parse response
-	return c.parseCompletionResponse(responseFrame)
+	response, err := c.parseCompletionResponse(responseFrame)
+	if err != nil {
+		return nil, err
+	}
+	if chunks != nil {
+		response.Text = chunks.text.String() + response.Text
+	}
+	response.Timings = pending.timings()
+	response.PreferenceHonored = request.PreferredModel != "" && response.ModelUsed == request.PreferredModel
+	usage = response
+	if hedge == nil {
+		// completeHedged records the attempts it keeps
+		c.recordCompletion(response, nil)
+	}
+	return response, nil
+}
+
+// recordCompletion records the usage and cost of response, labelling the
+// cost metric with labels
+func (c *ATPClient) recordCompletion(response *CompletionResponse, labels map[string]string) {
+	c.usage.recordUsage(response)
+	if response.CostUSD > 0 {
+		c.config.Metrics.IncCounter(MetricCostUSD, response.CostUSD, labels)
+	}
+	c.recordSpend(response.CostUSD)
 }
 
 // AdvertiseCapabilities sends a capability advertisement to the ATP Router
-func (c *ATPClient) AdvertiseCapabilities(ctx context.Context, capability CapabilityAdvertisement) error {
-	if !c.IsConnected() {
-		if err := c.Connect(); err != nil {
-			return fmt.Errorf("failed to connect: %w", err)
+// and waits for the router to acknowledge it. A rejected advertisement
+// fails with a *NackError. See FireAndForget to skip the wait.
+//
+// When the router acknowledged an earlier advertisement of the adapter on
+// the current connection, only the changes are sent, as by
+// UpdateCapabilities. An unchanged advertisement is sent in full, which
+// refreshes it on the router.
+func (c *ATPClient) AdvertiseCapabilities(ctx context.Context, capability CapabilityAdvertisement, opts ...RequestOption) error {
+	if c.closed() {
+		return ErrClientClosed
+	}
+	options := c.requestOptions(opts)
+	if options.err != nil {
+		return options.err
+	}
+	correlationID := c.correlate(&options)
+	if options.tenant != "" {
+		ctx = ContextWithTenant(ctx, options.tenant)
+	}
+	if !options.fireAndForget {
+		deltaOpts := append(opts[:len(opts):len(opts)], WithCorrelationID(correlationID))
+		if sent, err := c.advertiseDelta(ctx, capability, deltaOpts); sent {
+			return err
 		}
 	}
 
-	streamID := fmt.Sprintf("capability_%d_%d", time.Now().Unix(), time.Now().Nanosecond())
+	builder := c.builderFor(ctx)
+	streamID := c.newStreamID("capability")
+	frame := builder.BuildCapabilityFrame(streamID, capability, options.meta...)
+	if options.qos != "" {
+		frame.QoS = options.qos
+	}
+	defer builder.ReleaseStream(streamID)
 
-	// Create frame builder if not exists
-	frameBuilder := NewFrameBuilder(c.config.SessionID, c.config.TenantID)
-	frame := frameBuilder.BuildCapabilityFrame(streamID, capability)
+	err := c.sendAdapterFrame(ctx, frame, options, "capability advertisement")
+	if err != nil || options.fireAndForget {
+		c.forgetAdvertised(ctx, capability.AdapterID)
+	} else {
+		c.recordAdvertised(ctx, capability)
+	}
+	return correlateError(err, correlationID)
+}
 
-	// Send frame
-	if err := c.sendFrame(frame); err != nil {
-		return fmt.Errorf("failed to send capability frame: %w", err)
+// WithdrawCapabilities tells the ATP Router that the adapter is going away,
+// so that it stops routing requests to it, and waits for the router to
+// acknowledge it. Stop any CapabilityLoop of the adapter first, or it will
+// advertise the adapter again.
+func (c *ATPClient) WithdrawCapabilities(ctx context.Context, adapterID string, opts ...RequestOption) error {
+	if c.closed() {
+		return ErrClientClosed
+	}
+	options := c.requestOptions(opts)
+	if options.err != nil {
+		return options.err
+	}
+	correlationID := c.correlate(&options)
+	if options.tenant != "" {
+		ctx = ContextWithTenant(ctx, options.tenant)
 	}
 
-	// Wait for acknowledgment (optional - could be fire-and-forget)
-	_, err := c.waitForResponse(ctx, streamID, frame.MsgSeq)
-	if err != nil {
-		// Log warning but don't fail - capability advertisement is often fire-and-forget
-		fmt.Printf("Warning: No acknowledgment received for capability advertisement: %v\n", err)
+	builder := c.builderFor(ctx)
+	streamID := c.newStreamID("capability")
+	frame := builder.BuildCapabilityWithdrawFrame(streamID, adapterID, options.meta...)
+	if options.qos != "" {
+		frame.QoS = options.qos
 	}
+	defer builder.ReleaseStream(streamID)
+	c.forgetAdvertised(ctx, adapterID)
 
-	return nil
+	return correlateError(c.sendAdapterFrame(ctx, frame, options, "capability withdrawal"), correlationID)
 }
 
-// ReportHealth sends a health status update to the ATP Router
-func (c *ATPClient) ReportHealth(ctx context.Context, health HealthStatus) error {
-	if !c.IsConnected() {
-		if err := c.Connect(); err != nil {
-			return fmt.Errorf("failed to connect: %w", err)
-		}
+// ReportHealth sends a health status update to the ATP Router and waits for
+// the router to acknowledge it. A rejected report fails with a *NackError.
+// See FireAndForget to skip the wait.
+func (c *ATPClient) ReportHealth(ctx context.Context, health HealthStatus, opts ...RequestOption) error {
+	if c.closed() {
+		return ErrClientClosed
+	}
+	options := c.requestOptions(opts)
+	if options.err != nil {
+		return options.err
+	}
+	correlationID := c.correlate(&options)
+	if options.tenant != "" {
+		ctx = ContextWithTenant(ctx, options.tenant)
+	}
+
+	builder := c.builderFor(ctx)
+	streamID := c.newStreamID("health")
+	frame := builder.BuildHealthFrame(streamID, health, options.meta...)
+	if options.qos != "" {
+		frame.QoS = options.qos
+	}
+	defer builder.ReleaseStream(streamID)
+	if c.config.Budget.ReportInHealth && c.budgetEnabled() {
+		frame.Payload["budget"] = c.BudgetState()
 	}
 
-	streamID := fmt.Sprintf("health_%d_%d", time.Now().Unix(), time.Now().Nanosecond())
+	return correlateError(c.sendAdapterFrame(ctx, frame, options, "health report"), correlationID)
+}
 
-	// Create frame builder if not exists
-	frameBuilder := NewFrameBuilder(c.config.SessionID, c.config.TenantID)
-	frame := frameBuilder.BuildHealthFrame(streamID, health)
+// sendFrame queues a frame for the WebSocket connection
+func (c *ATPClient) sendFrame(frame Frame) error {
+	return c.sendFrameOn(context.Background(), frame, frame.StreamID, nil)
+}
 
-	// Send frame
-	if err := c.sendFrame(frame); err != nil {
-		return fmt.Errorf("failed to send health frame: %w", err)
-	}
+// sendFrameContext is sendFrame giving up when ctx is done while waiting for
+// space in the outbound queue
+func (c *ATPClient) sendFrameContext(ctx context.Context, frame Frame) error {
+	return c.sendFrameOn(ctx, frame, frame.StreamID, nil)
+}
 
-	// Wait for acknowledgment (optional - could be fire-and-forget)
-	_, err := c.waitForResponse(ctx, streamID, frame.MsgSeq)
-	if err != nil {
-		// Log warning but don't fail - health reports are often fire-and-forget
-		fmt.Printf("Warning: No acknowledgment received for health report: %v\n", err)
+// sendFrameWritten is sendFrameContext returning once the connection's
+// writer has written the frame, with the write's error
+func (c *ATPClient) sendFrameWritten(ctx context.Context, frame Frame) error {
+	written := make(chan error, 1)
+	if err := c.sendFrameOn(ctx, frame, frame.StreamID, written); err != nil {
+		return err
+	}
+	select {
+	case err := <-written:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the frame to be written: %w", ctx.Err())
 	}
+}
 
-	return nil
+// sendPrimaryFrame queues a frame for the primary connection, even when its
+// stream would be pinned to a pool connection
+func (c *ATPClient) sendPrimaryFrame(frame Frame) error {
+	return c.sendFrameOn(context.Background(), frame, "", nil)
 }
 
-// sendFrame sends a frame over the WebSocket connection
-func (c *ATPClient) sendFrame(frame Frame) error {
+// sendFrameOn queues a frame for the connection the stream route is pinned
+// to, usually the frame's own, or for the primary connection when route is
+// empty. It returns once the frame is queued; write failures tear the
+// connection down and are reported to written when it is not nil.
+func (c *ATPClient) sendFrameOn(ctx context.Context, frame Frame, route string, written chan<- error) error {
 	c.connMutex.RLock()
-	defer c.connMutex.RUnlock()
-
+	if c.closed() {
+		c.connMutex.RUnlock()
+		return ErrClientClosed
+	}
+	if c.httpTransport.Load() {
+		c.connMutex.RUnlock()
+		return ErrNotSupportedByTransport
+	}
 	if !c.connected || c.conn == nil {
-		return fmt.Errorf("not connected")
+		c.connMutex.RUnlock()
+		return ErrNotConnected
 	}
 
-	data, err := json.Marshal(frame)
-	if err != nil {
-		return fmt.Errorf("failed to marshal frame: %w", err)
+	if err := c.prepareOutgoing(&frame); err != nil {
+		c.connMutex.RUnlock()
+		return err
 	}
 
-	return c.conn.WriteMessage(websocket.TextMessage, data)
-}
-
-// waitForResponse waits for a response frame with the given stream ID and message sequence
-func (c *ATPClient) waitForResponse(ctx context.Context, streamID string, msgSeq int) (*Frame, error) {
-	requestID := fmt.Sprintf("%s:%d", streamID, msgSeq)
+	data, binary, err := c.encodeFrame(&frame)
+	if err != nil {
+		c.connMutex.RUnlock()
+		return err
+	}
 
-	// Create response channel
-	responseChan := make(chan *Frame, 1)
+	queue := c.out
+	if member := c.poolMemberFor(route); member != nil {
+		queue = member.queue()
+	}
+	// Waiting for queue space must not hold up Disconnect
+	c.connMutex.RUnlock()
 
-	c.handlerMutex.Lock()
-	c.responseHandlers[requestID] = responseChan
-	c.handlerMutex.Unlock()
+	if queue == nil {
+		return ErrNotConnected
+	}
+	if err := queue.push(ctx, outboundFrame{data: data, binary: binary, heartbeat: frame.Type == FrameTypeHeartbeat, frameType: frame.Type, priority: framePriority(frame.QoS), written: written}); err != nil {
+		return err
+	}
 
-	defer func() {
-		c.handlerMutex.Lock()
-		delete(c.responseHandlers, requestID)
-		c.handlerMutex.Unlock()
-	}()
+	c.audit(AuditOutbound, frame, 0)
+	return nil
+}
 
-	// Wait for response with timeout
-	select {
-	case response := <-responseChan:
-		return response, nil
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-time.After(c.config.DefaultTimeout):
-		return nil, fmt.Errorf("request timeout")
+// audit hands a frame to the configured AuditSink, if any
+func (c *ATPClient) audit(direction AuditDirection, frame Frame, latency time.Duration) {
+	if c.config.AuditSink == nil {
+		return
+	}
+	tenantID := frame.Meta.EnvironmentID
+	if tenantID == "" {
+		tenantID = c.config.TenantID
+	}
+	if frame.Type == FrameTypeReauth {
+		// Keep credentials out of audit trails
+		redacted := make(map[string]interface{}, len(frame.Payload))
+		for key := range frame.Payload {
+			redacted[key] = "[REDACTED]"
+		}
+		frame.Payload = redacted
 	}
+	c.config.AuditSink.Record(AuditRecord{
+		Direction: direction,
+		Time:      c.config.Clock.Now(),
+		TenantID:  tenantID,
+		RequestID: frame.StreamID,
+		Latency:   latency,
+		Frame:     frame,
+	})
 }
 
 // parseCompletionResponse parses a completion response frame
 func (c *ATPClient) parseCompletionResponse(frame *Frame) (*CompletionResponse, error) {
-	if frame.Type == "error" {
-		if payload, ok := frame.Payload["error"].(map[string]interface{}); ok {
-			if msg, ok := payload["message"].(string); ok {
-				return nil, fmt.Errorf("ATP Router error: %s", msg)
-			}
-		}
-		return nil, fmt.Errorf("ATP Router error: unknown error")
+	if frame.Type == FrameTypeError {
+		return nil, parseErrorFrame(frame)
 	}
 
-	payload := frame.Payload
-	response := &CompletionResponse{
-		Text:         getString(payload, "text", ""),
-		ModelUsed:    getString(payload, "model_used", "unknown"),
-		TokensIn:     getInt(payload, "tokens_in", 0),
-		TokensOut:    getInt(payload, "tokens_out", 0),
-		CostUSD:      getFloat64(payload, "cost_usd", 0),
-		QualityScore: getFloat64(payload, "quality_score", 0),
-		Finished:     true,
+	response, err := DecodePayload[CompletionResponse](*frame)
+	if err != nil {
+		return nil, err
 	}
-
-	return response, nil
+	if response.ModelUsed == "" {
+		response.ModelUsed = "unknown"
+	}
+	response.Finished = true
+	response.TraceParent = frame.Meta.Trace.traceParent()
+	return &response, nil
 }
 
-// handleMessages handles incoming WebSocket messages
-func (c *ATPClient) handleMessages() {
+// handleMessages reads the frames of conn, the primary connection, until
+// it fails or epoch ends. It owns conn for its lifetime: a read error is
+// reported to connectionLost, which tears conn down.
+func (c *ATPClient) handleMessages(epoch context.Context, conn Transport) {
 	for {
 		select {
-		case <-c.ctx.Done():
+		case <-epoch.Done():
 			return
 		default:
-			if !c.IsConnected() {
-				time.Sleep(time.Second)
-				continue
-			}
-
-			_, data, err := c.conn.ReadMessage()
+			data, binary, err := receiveMessage(conn)
 			if err != nil {
-				// Connection error - could implement reconnection logic here
-				continue
+				select {
+				case <-epoch.Done():
+					// Read failed because Disconnect closed the connection
+				default:
+					c.connectionLost(conn, fmt.Errorf("read failed: %w", err))
+				}
+				return
 			}
 
-			var frame Frame
-			if err := json.Unmarshal(data, &frame); err != nil {
-				// Invalid frame - could emit error event
-				continue
-			}
+			c.handleIncoming(data, binary)
+		}
+	}
+}
 
-			// Handle response frames
-			if frame.Type == "completion_response" || frame.Type == "error" {
-				requestID := fmt.Sprintf("%s:%d", frame.StreamID, frame.MsgSeq)
-				c.handlerMutex.RLock()
-				if handler, exists := c.responseHandlers[requestID]; exists {
-					select {
-					case handler <- &frame:
-					default:
-						// Channel full, skip
-					}
-				}
-				c.handlerMutex.RUnlock()
-			}
+// handleIncoming processes one message read from any of the client's
+// connections. Binary messages are decoded with SDKConfig.Codec.
+func (c *ATPClient) handleIncoming(data []byte, binary bool) {
+	c.counters.framesReceived.Add(1)
+
+	var frame Frame
+	data, err := c.decodeIncoming(data, binary, &frame)
+	if errors.Is(err, ErrCodecRequired) {
+		c.config.Logger.Printf("Warning: dropping incoming frame: %v", err)
+		c.reportAsyncError(err)
+		return
+	}
+	if errors.Is(err, ErrStrictDecoding) {
+		c.counters.strictFailures.Add(1)
+		c.config.Metrics.IncCounter(MetricDecodeFailures, 1, map[string]string{"reason": "strict"})
+		c.config.Logger.Printf("Warning: dropping incoming frame: %v", err)
+		return
+	}
+	if err != nil {
+		c.counters.malformedFrames.Add(1)
+		c.config.Metrics.IncCounter(MetricDecodeFailures, 1, map[string]string{"reason": "parse"})
+		if binary {
+			c.config.Logger.Printf("Warning: dropping incoming frame: %v", err)
 		}
+		// Invalid frame - could emit error event
+		return
+	}
+	c.counters.receivedByType.add(frame.Type)
+	c.config.Metrics.IncCounter(MetricFramesReceived, 1, map[string]string{"frame_type": frame.Type})
+	if err := c.verifyIncoming(data, &frame); err != nil {
+		c.rejectUnsigned(&frame, err)
+		return
 	}
+	if err := c.decryptIncoming(&frame); err != nil {
+		c.rejectIncoming(&frame, err)
+		return
+	}
+	normalizeVersion(&frame)
+	c.observeProtocolVersion(&frame)
+	c.checkTimestamp(AuditInbound, &frame)
+	if c.dropExpired(&frame) {
+		return
+	}
+
+	if err := runInterceptors(c.config.ReceiveInterceptors, &frame); err != nil {
+		c.config.Logger.Printf("Warning: dropping incoming frame: %v", err)
+		return
+	}
+	if err := c.validatePayload(AuditInbound, &frame); err != nil {
+		c.rejectIncoming(&frame, err)
+		return
+	}
+
+	// Handle response frames
+	var latency time.Duration
+	if isResponse(frame.Type) {
+		latency = c.dispatchResponse(&frame)
+	}
+	if frame.Type == FrameTypeError && frame.StreamID == "" {
+		c.reportVersionMismatch(&frame)
+	}
+	if frame.Type == FrameTypeAck || frame.Type == FrameTypeNack {
+		latency = c.dispatchAck(&frame)
+	}
+
+	if frame.Type == FrameTypeTenantSuspend || frame.Type == FrameTypeTenantResume {
+		c.handleTenantControl(&frame)
+	}
+
+	if frame.Type == FrameTypeIntrospectRequest {
+		c.handleIntrospectRequest(&frame)
+	}
+
+	if frame.Type == FrameTypeAuthExpiring {
+		go c.refreshCredentials()
+	}
+
+	if frame.Type == FrameTypeQuotaWarning {
+		c.handleQuotaWarning(&frame)
+	}
+
+	if frame.Type == FrameTypeHeartbeatAck {
+		c.handleHeartbeatAck(&frame)
+	}
+
+	if frame.Type == FrameTypeWindowUpdate {
+		c.handleWindowUpdate(&frame)
+	}
+
+	if frame.Type == FrameTypeCompletionRequest {
+		c.handlerMutex.RLock()
+		server := c.adapterServer
+		c.handlerMutex.RUnlock()
+		if server != nil {
+			server.enqueue(frame)
+		}
+	}
+
+	c.dispatchStream(&frame)
+	c.dispatchSubscribers(&frame)
+	c.audit(AuditInbound, frame, latency)
 }
 
-// sendHeartbeats sends periodic heartbeat messages
-func (c *ATPClient) sendHeartbeats() {
-	ticker := time.NewTicker(c.config.HeartbeatInterval)
+// sendHeartbeats sends periodic heartbeat messages over conn until it is
+// replaced or epoch ends, and tears it down when the router stops
+// acknowledging them
+func (c *ATPClient) sendHeartbeats(epoch context.Context, conn Transport) {
+	ticker := c.newTicker(c.heartbeatInterval())
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-c.ctx.Done():
+		case <-epoch.Done():
 			return
-		case <-ticker.C:
-			if c.IsConnected() {
-				frameBuilder := NewFrameBuilder(c.config.SessionID, c.config.TenantID)
-				heartbeat := frameBuilder.BuildHeartbeatFrame()
-				_ = c.sendFrame(heartbeat) // Ignore errors for heartbeat
+		case <-ticker.C():
+			c.connMutex.RLock()
+			current, connected := c.conn == conn, c.connected
+			c.connMutex.RUnlock()
+			if !current {
+				return
+			}
+			if !connected {
+				continue
+			}
+			err := c.sendHeartbeat()
+			if errors.Is(err, ErrHeartbeatTimeout) {
+				c.connectionLost(conn, err)
+				return
+			}
+			if err != nil {
+				c.reportAsyncError(fmt.Errorf("heartbeat failed: %w", err))
 			}
 		}
 	}
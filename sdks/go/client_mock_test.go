@@ -0,0 +1,109 @@
+package atpsdk_test
+
+import (
+	"context"
+	"testing"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+	"github.com/atp-project/atp-go-sdk/atpsdktest"
+)
+
+// ackRouter acknowledges capability and health frames
+func ackRouter(t *testing.T) *atpsdktest.MockRouter {
+	router := atpsdktest.NewMockRouter(t)
+	ack := func(f atpsdk.Frame) []atpsdk.Frame {
+		return []atpsdk.Frame{atpsdktest.Reply(f, atpsdk.FrameTypeAck, nil)}
+	}
+	router.OnType("adapter.capability", ack)
+	router.OnType("adapter.health", ack)
+	return router
+}
+
+func TestAdvertiseCapabilitiesClient(t *testing.T) {
+	router := ackRouter(t)
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{WSURL: router.URL(), TenantID: "test-tenant"})
+	defer client.Close()
+
+	maxTokens := 4096
+	capability := atpsdk.CapabilityAdvertisement{
+		AdapterID:    "test-adapter-1",
+		AdapterType:  "ollama",
+		Capabilities: []string{"text-generation"},
+		Models:       []string{"llama2:7b"},
+		MaxTokens:    &maxTokens,
+	}
+	if err := client.AdvertiseCapabilities(context.Background(), capability); err != nil {
+		t.Fatalf("AdvertiseCapabilities failed: %v", err)
+	}
+
+	router.ExpectFrames(t, atpsdk.Frame{
+		Type: "adapter.capability",
+		Payload: map[string]interface{}{
+			"adapter_id":   "test-adapter-1",
+			"adapter_type": "ollama",
+			"capabilities": []string{"text-generation"},
+			"models":       []string{"llama2:7b"},
+			"max_tokens":   4096,
+		},
+	})
+}
+
+func TestReportHealthClient(t *testing.T) {
+	router := ackRouter(t)
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{WSURL: router.URL(), TenantID: "test-tenant"})
+	defer client.Close()
+
+	p95, errorRate := 150.0, 0.01
+	health := atpsdk.HealthStatus{
+		AdapterID:    "test-adapter-1",
+		Status:       "healthy",
+		P95LatencyMS: &p95,
+		ErrorRate:    &errorRate,
+	}
+	if err := client.ReportHealth(context.Background(), health); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+
+	router.ExpectFrames(t, atpsdk.Frame{
+		Type: "adapter.health",
+		Payload: map[string]interface{}{
+			"adapter_id":     "test-adapter-1",
+			"status":         "healthy",
+			"p95_latency_ms": 150.0,
+			"error_rate":     0.01,
+		},
+	})
+}
+
+func TestReconnectAfterDisconnect(t *testing.T) {
+	router := atpsdktest.NewMockRouter(t)
+	router.OnType("completion_request", func(f atpsdk.Frame) []atpsdk.Frame {
+		return []atpsdk.Frame{atpsdktest.Reply(f, "completion_response", map[string]interface{}{"text": "again"})}
+	})
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+	if client.IsConnected() {
+		t.Fatal("Expected the client to be disconnected")
+	}
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect after Disconnect failed: %v", err)
+	}
+	response, err := client.Complete(context.Background(), atpsdk.CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Complete on the second connection failed: %v", err)
+	}
+	if response.Text != "again" {
+		t.Errorf("Expected the second connection's response, got %q", response.Text)
+	}
+	if got := client.Stats().Connection.ConnectCount; got != 2 {
+		t.Errorf("Expected 2 connects, got %d", got)
+	}
+}
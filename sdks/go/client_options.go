@@ -0,0 +1,179 @@
+package atpsdk
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ClientOption configures the client built by NewClient
+type ClientOption func(*SDKConfig) error
+
+// NewClient creates a client from opts, applied in order over
+// NewATPClient's defaults. Unlike NewATPClient, which substitutes defaults
+// for unusable values, it fails with an error matching ErrInvalidConfig
+// when an option is given one.
+func NewClient(opts ...ClientOption) (*ATPClient, error) {
+	var config SDKConfig
+	for _, opt := range opts {
+		if err := opt(&config); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return NewATPClient(config), nil
+}
+
+// invalidConfig returns the error of an option given an unusable value
+func invalidConfig(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidConfig, fmt.Sprintf(format, args...))
+}
+
+// WithConfig starts from config, for the settings that have no option of
+// their own. Options after it override its fields.
+func WithConfig(config SDKConfig) ClientOption {
+	return func(c *SDKConfig) error {
+		*c = config
+		return nil
+	}
+}
+
+// WithBaseURL sets the router's HTTP URL, which must be an absolute http or
+// https URL
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *SDKConfig) error {
+		if err := checkURL(baseURL, "http", "https"); err != nil {
+			return invalidConfig("base URL: %v", err)
+		}
+		c.BaseURL = baseURL
+		return nil
+	}
+}
+
+// WithWSURL sets the router's WebSocket URL, which must be an absolute ws
+// or wss URL. Several make a failover list, as SDKConfig.WSURLs.
+func WithWSURL(wsURLs ...string) ClientOption {
+	return func(c *SDKConfig) error {
+		if len(wsURLs) == 0 {
+			return invalidConfig("no WebSocket URL")
+		}
+		for _, wsURL := range wsURLs {
+			if err := checkURL(wsURL, "ws", "wss"); err != nil {
+				return invalidConfig("WebSocket URL: %v", err)
+			}
+		}
+		c.WSURL, c.WSURLs = wsURLs[0], wsURLs
+		return nil
+	}
+}
+
+// checkURL fails unless raw is an absolute URL with one of schemes
+func checkURL(raw string, schemes ...string) error {
+	if raw == "" {
+		return errors.New("empty URL")
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	for _, scheme := range schemes {
+		if parsed.Scheme == scheme && parsed.Host != "" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not an absolute %s URL", raw, schemes[0])
+}
+
+// WithAPIKey sets the API key the client authenticates with
+func WithAPIKey(apiKey string) ClientOption {
+	return func(c *SDKConfig) error {
+		if apiKey == "" {
+			return invalidConfig("empty API key")
+		}
+		c.APIKey = apiKey
+		return nil
+	}
+}
+
+// WithTenantID sets the client's tenant
+func WithTenantID(tenantID string) ClientOption {
+	return func(c *SDKConfig) error {
+		if tenantID == "" {
+			return invalidConfig("empty tenant ID")
+		}
+		c.TenantID = tenantID
+		return nil
+	}
+}
+
+// WithDefaultTimeout sets SDKConfig.DefaultTimeout, which must be positive
+func WithDefaultTimeout(timeout time.Duration) ClientOption {
+	return func(c *SDKConfig) error {
+		if timeout <= 0 {
+			return invalidConfig("default timeout %v is not positive", timeout)
+		}
+		c.DefaultTimeout = timeout
+		return nil
+	}
+}
+
+// WithDefaultQoS sets SDKConfig.DefaultQoS, which must be a known QoS
+// class
+func WithDefaultQoS(qos QoS) ClientOption {
+	return func(c *SDKConfig) error {
+		if !qos.Valid() {
+			return invalidConfig("unknown QoS class %q", qos)
+		}
+		c.DefaultQoS = qos
+		return nil
+	}
+}
+
+// WithDefaultMeta sets SDKConfig.DefaultMeta
+func WithDefaultMeta(meta Meta) ClientOption {
+	return func(c *SDKConfig) error {
+		c.DefaultMeta = meta
+		return nil
+	}
+}
+
+// WithLogger sets the logger receiving the client's warnings
+func WithLogger(logger Logger) ClientOption {
+	return func(c *SDKConfig) error {
+		if logger == nil {
+			return invalidConfig("nil logger")
+		}
+		c.Logger = logger
+		return nil
+	}
+}
+
+// WithMetrics sets the sink receiving the client's metrics
+func WithMetrics(metrics MetricsSink) ClientOption {
+	return func(c *SDKConfig) error {
+		if metrics == nil {
+			return invalidConfig("nil metrics sink")
+		}
+		c.Metrics = metrics
+		return nil
+	}
+}
+
+// WithTransport selects the transport by name: TransportWebSocket,
+// TransportHTTP, TransportAuto or one registered with RegisterTransport
+func WithTransport(name string) ClientOption {
+	return func(c *SDKConfig) error {
+		switch name {
+		case TransportWebSocket, TransportHTTP, TransportAuto:
+		default:
+			if _, ok := registeredTransport(name); !ok {
+				return invalidConfig("unknown transport %q", name)
+			}
+		}
+		c.Transport = name
+		return nil
+	}
+}
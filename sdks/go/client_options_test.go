@@ -0,0 +1,74 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewClient(t *testing.T) {
+	router := completionRouter(t, nil)
+	logger := &recordingLogger{}
+	client, err := NewClient(
+		WithWSURL(router.URL()),
+		WithBaseURL("https://router.example.com"),
+		WithAPIKey("key"),
+		WithTenantID("acme"),
+		WithDefaultTimeout(2*time.Second),
+		WithLogger(logger),
+		WithTransport(TransportWebSocket),
+	)
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+
+	config := client.config
+	if config.WSURL != router.URL() || config.BaseURL != "https://router.example.com" || config.APIKey != "key" ||
+		config.TenantID != "acme" || config.DefaultTimeout != 2*time.Second || config.Logger != logger {
+		t.Errorf("Expected the options applied, got %+v", config)
+	}
+	if config.HeartbeatInterval == 0 || config.Metrics == nil {
+		t.Error("Expected defaults for the settings without options")
+	}
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Errorf("Complete failed: %v", err)
+	}
+}
+
+func TestNewClientRejectsInvalidOptions(t *testing.T) {
+	tests := map[string]ClientOption{
+		"empty base URL":    WithBaseURL(""),
+		"relative base URL": WithBaseURL("/router"),
+		"ws base URL":       WithBaseURL("ws://router"),
+		"no WebSocket URL":  WithWSURL(),
+		"http WebSocket":    WithWSURL("ws://a", "http://b"),
+		"empty API key":     WithAPIKey(""),
+		"empty tenant":      WithTenantID(""),
+		"negative timeout":  WithDefaultTimeout(-time.Second),
+		"zero timeout":      WithDefaultTimeout(0),
+		"nil logger":        WithLogger(nil),
+		"nil metrics":       WithMetrics(nil),
+		"unknown transport": WithTransport("carrier-pigeon"),
+	}
+	for name, opt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewClient(WithWSURL("ws://localhost:8000"), opt)
+			if !errors.Is(err, ErrInvalidConfig) || client != nil {
+				t.Errorf("Expected ErrInvalidConfig, got %v", err)
+			}
+		})
+	}
+}
+
+func TestNewClientWithConfig(t *testing.T) {
+	client, err := NewClient(WithConfig(SDKConfig{TenantID: "base", MaxRetries: 3}), WithTenantID("override"))
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	defer client.Close()
+	if client.config.TenantID != "override" || client.config.MaxRetries != 3 {
+		t.Errorf("Expected the options over the config, got %+v", client.config)
+	}
+}
@@ -2,10 +2,15 @@ package atpsdk
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"go.uber.org/goleak"
 )
 
 func TestNewATPClient(t *testing.T) {
@@ -188,6 +193,41 @@ func TestCompletionRequestParsing(t *testing.T) {
 	}
 }
 
+func TestRoutingPreferences(t *testing.T) {
+	// The router serves the preferred model unless it is "busy"
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		if f.Payload["preferred_model"] != f.Meta.PreferredModel || f.Meta.PreferredAdapterID != "edge-1" ||
+			fmt.Sprint(f.Payload["exclude_adapters"]) != "[edge-2]" || fmt.Sprint(f.Meta.ExcludeAdapters) != "[edge-2]" {
+			t.Errorf("Preferences not carried in payload and meta: %v %+v", f.Payload, f.Meta)
+		}
+		model := f.Meta.PreferredModel
+		if model == "busy" {
+			model = "other"
+		}
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok", "model_used": model}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	for model, honored := range map[string]bool{"llama": true, "busy": false} {
+		response, err := client.Complete(context.Background(), CompletionRequest{
+			Prompt:             "hi",
+			PreferredModel:     model,
+			PreferredAdapterID: "edge-1",
+			ExcludeAdapters:    []string{"edge-2"},
+		})
+		if err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		if response.PreferenceHonored != honored {
+			t.Errorf("Expected PreferenceHonored %v for %s, got %+v", honored, model, response)
+		}
+	}
+}
+
 func BenchmarkFrameBuilding(b *testing.B) {
 	fb := NewFrameBuilder("bench-session", "bench-tenant")
 	request := CompletionRequest{
@@ -216,6 +256,21 @@ func BenchmarkFrameSerialization(b *testing.B) {
 	}
 }
 
+func BenchmarkAppendFrame(b *testing.B) {
+	fb := NewFrameBuilder("bench-session", "bench-tenant")
+	request := CompletionRequest{
+		Prompt:    "Benchmark prompt",
+		MaxTokens: 100,
+	}
+	frame := fb.BuildCompletionFrame("bench-stream", request)
+	var dst []byte
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dst, _ = AppendFrame(dst[:0], frame)
+	}
+}
+
 func TestCapabilityAdvertisement(t *testing.T) {
 	capability := CapabilityAdvertisement{
 		AdapterID:          "test-adapter-1",
@@ -350,43 +405,6 @@ func TestCapabilityFrameSerialization(t *testing.T) {
 	}
 }
 
-func TestAdvertiseCapabilitiesClient(t *testing.T) {
-	config := SDKConfig{
-		BaseURL:  "http://localhost:8000",
-		WSURL:    "ws://localhost:8000",
-		TenantID: "test-tenant",
-	}
-
-	client := NewATPClient(config)
-
-	capability := CapabilityAdvertisement{
-		AdapterID:    "test-adapter-1",
-		AdapterType:  "ollama",
-		Capabilities: []string{"text-generation"},
-		Models:       []string{"llama2:7b"},
-	}
-
-	// Test that AdvertiseCapabilities doesn't panic when not connected
-	// (In a real scenario, this would attempt to connect)
-	defer func() {
-		if r := recover(); r != nil {
-			t.Errorf("AdvertiseCapabilities panicked: %v", r)
-		}
-	}()
-
-	// Note: We can't easily test the full AdvertiseCapabilities flow without
-	// a mock WebSocket server, but we can test that the method exists and
-	// the frame building works correctly
-	ctx := context.Background()
-
-	// This will fail to connect, but shouldn't panic
-	err := client.AdvertiseCapabilities(ctx, capability)
-	// We expect an error since there's no server running
-	if err == nil {
-		t.Log("AdvertiseCapabilities completed without error (unexpected in test environment)")
-	}
-}
-
 func TestConcurrentCapabilityAdvertisement(t *testing.T) {
 	config := SDKConfig{
 		BaseURL:  "http://localhost:8000",
@@ -481,17 +499,17 @@ func BenchmarkCapabilityFrameSerialization(b *testing.B) {
 
 func TestHealthStatus(t *testing.T) {
 	health := HealthStatus{
-		AdapterID:        "test-adapter-1",
-		Status:           "healthy",
-		P95LatencyMS:     floatPtr(150.5),
-		P50LatencyMS:     floatPtr(95.2),
-		ErrorRate:        floatPtr(0.02),
+		AdapterID:         "test-adapter-1",
+		Status:            "healthy",
+		P95LatencyMS:      floatPtr(150.5),
+		P50LatencyMS:      floatPtr(95.2),
+		ErrorRate:         floatPtr(0.02),
 		RequestsPerSecond: floatPtr(10.5),
-		QueueDepth:       intPtr(3),
-		MemoryUsageMB:    floatPtr(512.8),
-		CPUUsagePercent:  floatPtr(45.2),
-		UptimeSeconds:    intPtr(3600),
-		Version:          stringPtr("1.0.0"),
+		QueueDepth:        intPtr(3),
+		MemoryUsageMB:     floatPtr(512.8),
+		CPUUsagePercent:   floatPtr(45.2),
+		UptimeSeconds:     intPtr(3600),
+		Version:           stringPtr("1.0.0"),
 		Metadata: map[string]interface{}{
 			"region": "us-west-2",
 		},
@@ -607,56 +625,19 @@ func TestHealthFrameSerialization(t *testing.T) {
 	}
 }
 
-func TestReportHealthClient(t *testing.T) {
-	config := SDKConfig{
-		BaseURL:  "http://localhost:8000",
-		WSURL:    "ws://localhost:8000",
-		TenantID: "test-tenant",
-	}
-
-	client := NewATPClient(config)
-
-	health := HealthStatus{
-		AdapterID:    "test-adapter-1",
-		Status:       "healthy",
-		P95LatencyMS: floatPtr(150.0),
-		ErrorRate:    floatPtr(0.01),
-	}
-
-	// Test that ReportHealth doesn't panic when not connected
-	// (In a real scenario, this would attempt to connect)
-	defer func() {
-		if r := recover(); r != nil {
-			t.Errorf("ReportHealth panicked: %v", r)
-		}
-	}()
-
-	// Note: We can't easily test the full ReportHealth flow without
-	// a mock WebSocket server, but we can test that the method exists and
-	// the frame building works correctly
-	ctx := context.Background()
-
-	// This will fail to connect, but shouldn't panic
-	err := client.ReportHealth(ctx, health)
-	// We expect an error since there's no server running
-	if err == nil {
-		t.Log("ReportHealth completed without error (unexpected in test environment)")
-	}
-}
-
 func BenchmarkHealthFrameBuilding(b *testing.B) {
 	fb := NewFrameBuilder("bench-session", "bench-tenant")
 
 	health := HealthStatus{
-		AdapterID:        "bench-adapter",
-		Status:           "healthy",
-		P95LatencyMS:     floatPtr(100.0),
-		P50LatencyMS:     floatPtr(50.0),
-		ErrorRate:        floatPtr(0.02),
+		AdapterID:         "bench-adapter",
+		Status:            "healthy",
+		P95LatencyMS:      floatPtr(100.0),
+		P50LatencyMS:      floatPtr(50.0),
+		ErrorRate:         floatPtr(0.02),
 		RequestsPerSecond: floatPtr(20.0),
-		MemoryUsageMB:    floatPtr(1024.0),
-		CPUUsagePercent:  floatPtr(60.0),
-		Version:          stringPtr("1.0.0"),
+		MemoryUsageMB:     floatPtr(1024.0),
+		CPUUsagePercent:   floatPtr(60.0),
+		Version:           stringPtr("1.0.0"),
 	}
 
 	b.ResetTimer()
@@ -684,6 +665,215 @@ func BenchmarkHealthFrameSerialization(b *testing.B) {
 	}
 }
 
+func TestLifecycleCallbacks(t *testing.T) {
+	router := newTestRouter(t, nil)
+
+	connected := make(chan string, 1)
+	disconnected := make(chan error, 2)
+
+	var client *ATPClient
+	client = NewATPClient(SDKConfig{
+		WSURL:     router.URL(),
+		SessionID: "lifecycle-session",
+		OnConnect: func(sessionID string) {
+			// Calling back into the client must not deadlock
+			_ = client.IsConnected()
+			connected <- sessionID
+		},
+		OnDisconnect: func(err error) {
+			_ = client.IsConnected()
+			disconnected <- err
+		},
+	})
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	select {
+	case sessionID := <-connected:
+		if sessionID != "lifecycle-session" {
+			t.Errorf("Expected session ID 'lifecycle-session', got '%s'", sessionID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnConnect was not invoked")
+	}
+
+	router.DropConnections()
+
+	select {
+	case err := <-disconnected:
+		if err == nil {
+			t.Error("Expected OnDisconnect to receive the read error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnDisconnect was not invoked after the connection dropped")
+	}
+
+	if client.IsConnected() {
+		t.Error("Client should report disconnected after a read failure")
+	}
+
+	// An explicit Disconnect of an already-dropped connection is a no-op
+	if err := client.Disconnect(); err != nil {
+		t.Errorf("Disconnect returned error: %v", err)
+	}
+	select {
+	case err := <-disconnected:
+		t.Errorf("Unexpected second OnDisconnect: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestOnDisconnectFromCallback(t *testing.T) {
+	router := newTestRouter(t, nil)
+
+	done := make(chan error, 1)
+	disconnected := make(chan error, 1)
+
+	var client *ATPClient
+	client = NewATPClient(SDKConfig{
+		WSURL: router.URL(),
+		OnConnect: func(string) {
+			// Disconnect from inside the callback must not deadlock
+			done <- client.Disconnect()
+		},
+		OnDisconnect: func(err error) {
+			disconnected <- err
+		},
+	})
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Disconnect from OnConnect returned error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Disconnect from OnConnect deadlocked")
+	}
+
+	select {
+	case err := <-disconnected:
+		if err != nil {
+			t.Errorf("Expected nil error for explicit Disconnect, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnDisconnect was not invoked for explicit Disconnect")
+	}
+}
+
+// pumpGoroutines counts the goroutines running a connection's read, write
+// or heartbeat loop
+func pumpGoroutines() int {
+	buf := make([]byte, 1<<20)
+	stacks := string(buf[:runtime.Stack(buf, true)])
+	n := 0
+	for _, pump := range []string{"handleMessages", "writeLoop", "sendHeartbeats", "readPoolMember", "poolMemberHeartbeats"} {
+		n += strings.Count(stacks, ".(*ATPClient)."+pump+"(")
+	}
+	return n
+}
+
+func TestCloseStopsPumps(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	pumps := pumpGoroutines()
+
+	router := newTestRouter(t, nil)
+	defer router.Close()
+	reconnected := make(chan struct{}, 1)
+	client := NewATPClient(SDKConfig{
+		WSURL:             router.URL(),
+		PoolSize:          2,
+		HeartbeatInterval: 10 * time.Millisecond,
+		AutoReconnect:     true,
+		RetryDelay:        10 * time.Millisecond,
+		OnConnect: func(string) {
+			select {
+			case reconnected <- struct{}{}:
+			default:
+			}
+		},
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	<-reconnected
+
+	// Pumps of lost connections exit too
+	router.DropConnections()
+	select {
+	case <-reconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("The client did not reconnect")
+	}
+
+	if err := client.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	// Close returns once the pumps are gone
+	if n := pumpGoroutines(); n > pumps {
+		t.Errorf("%d pump goroutine(s) still running after Close", n-pumps)
+	}
+}
+
+func TestCloseFromOnDisconnect(t *testing.T) {
+	router := newTestRouter(t, nil)
+
+	closed := make(chan error, 1)
+	var client *ATPClient
+	client = NewATPClient(SDKConfig{
+		WSURL: router.URL(),
+		OnDisconnect: func(err error) {
+			if err != nil {
+				closed <- client.Close()
+			}
+		},
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	router.DropConnections()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close from OnDisconnect returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close from OnDisconnect deadlocked")
+	}
+}
+
+func TestOnAsyncErrorFromHeartbeat(t *testing.T) {
+	client := NewATPClient(SDKConfig{HeartbeatInterval: 10 * time.Millisecond})
+
+	asyncErrs := make(chan error, 1)
+	client.config.OnAsyncError = func(err error) {
+		select {
+		case asyncErrs <- err:
+		default:
+		}
+	}
+
+	// Simulate a connection whose socket is gone so heartbeat sends fail
+	client.connected = true
+	go client.sendHeartbeats(client.epoch(), nil)
+	defer client.cancel()
+
+	select {
+	case err := <-asyncErrs:
+		if err == nil {
+			t.Error("Expected a non-nil heartbeat error")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnAsyncError was not invoked for a failed heartbeat")
+	}
+}
+
 // Helper functions for creating pointers to primitive types
 func intPtr(i int) *int {
 	return &i
@@ -696,3 +886,73 @@ func floatPtr(f float64) *float64 {
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestRequestTimeoutMatrix(t *testing.T) {
+	const (
+		defaultTimeout = 100 * time.Millisecond
+		long           = 300 * time.Millisecond
+		short          = 50 * time.Millisecond
+	)
+
+	tests := []struct {
+		name        string
+		ctxDeadline time.Duration
+		option      time.Duration
+		want        time.Duration
+		wantErr     error
+	}{
+		{name: "neither", want: defaultTimeout},
+		{name: "context deadline only", ctxDeadline: long, want: long, wantErr: context.DeadlineExceeded},
+		{name: "option only", option: long, want: long, wantErr: context.DeadlineExceeded},
+		{name: "both, option earlier", ctxDeadline: long, option: short, want: short, wantErr: context.DeadlineExceeded},
+		{name: "both, context earlier", ctxDeadline: short, option: long, want: short, wantErr: context.DeadlineExceeded},
+	}
+
+	// The router never answers, so every request ends at its deadline
+	router := newTestRouter(t, nil)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), DefaultTimeout: defaultTimeout})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.ctxDeadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxDeadline)
+				defer cancel()
+			}
+			var opts []RequestOption
+			if tt.option > 0 {
+				opts = append(opts, WithTimeout(tt.option))
+			}
+
+			start := time.Now()
+			_, err := client.Complete(ctx, CompletionRequest{Prompt: "hi"}, opts...)
+			elapsed := time.Since(start)
+
+			if err == nil {
+				t.Fatal("Expected a timeout error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+			if elapsed < tt.want || elapsed > tt.want+150*time.Millisecond {
+				t.Errorf("Expected the request to end after %v, took %v", tt.want, elapsed)
+			}
+		})
+	}
+}
+
+func TestWithTimeoutRejectsNonPositive(t *testing.T) {
+	client := NewATPClient(SDKConfig{WSURL: "ws://127.0.0.1:1"})
+	defer client.Close()
+
+	for _, d := range []time.Duration{0, -time.Second} {
+		if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithTimeout(d)); !errors.Is(err, ErrInvalidTimeout) {
+			t.Errorf("WithTimeout(%v): expected ErrInvalidTimeout, got %v", d, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package atpsdk
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Clock abstracts time so that timing-dependent behavior can be tested
+// deterministically. The default is the system clock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the subset of *time.Ticker used by the SDK
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock is the Clock backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+// realTicker adapts *time.Ticker to the Ticker interface
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// jitter returns d moved by a random amount of up to fraction of d either
+// way. A fraction of zero or less leaves d unchanged.
+func jitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + fraction*(2*rand.Float64()-1)))
+}
+
+// jitteredTicker is a Ticker whose ticks are interval ± fraction apart,
+// each delay drawn anew. Like time.Ticker, it drops ticks for a slow
+// receiver.
+type jitteredTicker struct {
+	c    chan time.Time
+	stop chan struct{}
+}
+
+// newJitteredTicker starts a jitteredTicker on clock
+func newJitteredTicker(clock Clock, interval time.Duration, fraction float64) *jitteredTicker {
+	t := &jitteredTicker{c: make(chan time.Time, 1), stop: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case <-t.stop:
+				return
+			case now := <-clock.After(jitter(interval, fraction)):
+				select {
+				case t.c <- now:
+				default:
+				}
+			}
+		}
+	}()
+	return t
+}
+
+func (t *jitteredTicker) C() <-chan time.Time { return t.c }
+func (t *jitteredTicker) Stop()               { close(t.stop) }
+
+// newTicker returns a Ticker for a periodic loop of the client, jittered by
+// HeartbeatJitter so that clients started together spread their traffic
+func (c *ATPClient) newTicker(interval time.Duration) Ticker {
+	if c.config.HeartbeatJitter <= 0 {
+		return c.config.Clock.NewTicker(interval)
+	}
+	return newJitteredTicker(c.config.Clock, interval, c.config.HeartbeatJitter)
+}
+
+// observeRouterTime records the skew between the router's clock and the
+// client's from the timestamp, in Unix milliseconds, of a frame the router
+// just sent, such as a heartbeat_ack or session.welcome. A zero timestamp
+// is ignored.
+func (c *ATPClient) observeRouterTime(timestamp FrameTime) {
+	if timestamp == 0 {
+		return
+	}
+	skew := timestamp.Time().Sub(c.config.Clock.Now())
+	c.counters.clockSkew.Store(int64(skew))
+}
+
+// ClockSkew returns how far the router's clock is ahead of the client's, as
+// last measured from the timestamp of a session.welcome or heartbeat_ack,
+// or zero before any.
+// The measure includes the ack's transit time, so it is only meaningful
+// beyond the connection's latency. Incoming frames' TTL is checked on the
+// router's clock, the client's corrected by this skew.
+func (c *ATPClient) ClockSkew() time.Duration {
+	return time.Duration(c.counters.clockSkew.Load())
+}
@@ -0,0 +1,77 @@
+package atpsdk
+
+import "strings"
+
+// Clone returns a client sharing c's connection, response dispatch and
+// heartbeats, with its own defaults, e.g. for a subsystem of a process
+// keeping one connection to the router. overrides apply over c's settings,
+// and may change the clone's DefaultQoS, DefaultMeta, DefaultTimeout,
+// MaxRequestTTL, TokenCallbackTimeout, Logger and Metrics. The other
+// settings belong to the shared connection: an override changing them is
+// logged as a warning and ignored, as is an override failing with an error.
+//
+// The usage reported by Stats is kept for each clone apart. Connect and
+// Disconnect on a clone act on the shared connection. Closing a clone
+// leaves the connection open, while closing the client that was cloned
+// closes its clones as well: their methods then return ErrClientClosed.
+// Subscriptions, streams and handlers opened through a clone belong to the
+// shared connection.
+func (c *ATPClient) Clone(overrides ...ClientOption) *ATPClient {
+	config := c.config
+	for _, override := range overrides {
+		if err := override(&config); err != nil {
+			c.config.Logger.Printf("Warning: Ignoring an override of the clone: %v", err)
+		}
+	}
+	if ignored := connectionOverrides(c.config, config); len(ignored) > 0 {
+		c.config.Logger.Printf("Warning: Ignoring the overrides of %s, shared by the clone", strings.Join(ignored, ", "))
+	}
+
+	clone := &ATPClient{clientCore: c.clientCore, config: c.config, root: c}
+	if c.root != nil {
+		clone.root = c.root
+	}
+	clone.released.Store(c.released.Load())
+	cloned := &clone.config
+	cloneSetting(&cloned.DefaultQoS, config.DefaultQoS)
+	cloneSetting(&cloned.DefaultTimeout, config.DefaultTimeout)
+	cloneSetting(&cloned.MaxRequestTTL, config.MaxRequestTTL)
+	cloneSetting(&cloned.TokenCallbackTimeout, config.TokenCallbackTimeout)
+	cloneSetting(&cloned.Logger, config.Logger)
+	cloneSetting(&cloned.Metrics, config.Metrics)
+	if !config.DefaultMeta.IsZero() {
+		cloned.DefaultMeta = config.DefaultMeta
+	}
+	return clone
+}
+
+// cloneSetting sets *setting to override unless override is zero, which
+// keeps the setting of the client cloned
+func cloneSetting[T comparable](setting *T, override T) {
+	var zero T
+	if override != zero {
+		*setting = override
+	}
+}
+
+// connectionOverrides returns the names of the connection settings of
+// config that overrides set to another value
+func connectionOverrides(config, overridden SDKConfig) []string {
+	var ignored []string
+	for _, setting := range []struct {
+		name        string
+		value, over string
+	}{
+		{"BaseURL", config.BaseURL, overridden.BaseURL},
+		{"WSURL", config.WSURL, overridden.WSURL},
+		{"APIKey", config.APIKey, overridden.APIKey},
+		{"TenantID", config.TenantID, overridden.TenantID},
+		{"SessionID", config.SessionID, overridden.SessionID},
+		{"Transport", config.Transport, overridden.Transport},
+	} {
+		if setting.over != "" && setting.over != setting.value {
+			ignored = append(ignored, setting.name)
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,110 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cloneRouter answers completion requests, handing them to the test
+func cloneRouter(t *testing.T) (*testRouter, chan Frame) {
+	requests := make(chan Frame, 8)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeCompletionRequest {
+			return nil
+		}
+		requests <- f
+		return []Frame{NewFrameBuilder("", "").BuildCompletionResponseFrame(f.StreamID, f.MsgSeq, CompletionResponse{Text: "ok", TokensIn: 3})}
+	})
+	return router, requests
+}
+
+func TestCloneSharesConnection(t *testing.T) {
+	router, requests := cloneRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	logger := &recordingLogger{}
+	clone := client.Clone(WithDefaultQoS(QoSBronze), WithDefaultMeta(Meta{TaskType: "qa"}), WithLogger(logger))
+
+	if _, err := clone.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete on the clone failed: %v", err)
+	}
+	request := receiveFrame(t, requests)
+	if request.QoS != QoSBronze || request.Meta.TaskType != "qa" {
+		t.Errorf("Expected the clone's defaults, got QoS %q and %+v", request.QoS, request.Meta)
+	}
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete on the client failed: %v", err)
+	}
+	request = receiveFrame(t, requests)
+	if request.QoS != QoSGold || request.Meta.TaskType == "qa" {
+		t.Errorf("Expected the client's defaults, got QoS %q and %+v", request.QoS, request.Meta)
+	}
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete on the client failed: %v", err)
+	}
+	receiveFrame(t, requests)
+
+	if n := router.ActiveConnections(); n != 1 {
+		t.Errorf("Expected one shared connection, got %d", n)
+	}
+	if !clone.IsConnected() {
+		t.Error("Expected the clone connected through the client")
+	}
+	if usage := clone.Stats().Usage; usage.Requests != 1 || usage.TokensIn != 3 {
+		t.Errorf("Expected the clone's own usage, got %+v", usage)
+	}
+	if usage := client.Stats().Usage; usage.Requests != 2 {
+		t.Errorf("Expected the client's own usage, got %+v", usage)
+	}
+}
+
+func TestCloseClone(t *testing.T) {
+	router, requests := cloneRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	clone := client.Clone()
+	other := clone.Clone()
+
+	if err := clone.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := clone.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := clone.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed from the closed clone, got %v", err)
+	}
+	if !client.IsConnected() {
+		t.Fatal("Expected closing the clone to leave the connection open")
+	}
+	if _, err := other.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete on another clone failed: %v", err)
+	}
+	receiveFrame(t, requests)
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := other.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed once the client is closed, got %v", err)
+	}
+}
+
+func TestCloneIgnoresConnectionOverrides(t *testing.T) {
+	logger := &recordingLogger{}
+	client := NewATPClient(SDKConfig{WSURL: "ws://router.example.com", TenantID: "acme", Logger: logger})
+	defer client.Close()
+
+	clone := client.Clone(WithTenantID("other"), WithDefaultQoS("platinum"), WithDefaultTimeout(5*time.Second))
+	if clone.config.TenantID != "acme" || clone.config.DefaultQoS != QoSGold || clone.config.DefaultTimeout != 5*time.Second {
+		t.Errorf("Unexpected clone config %+v", clone.config)
+	}
+	lines := logger.Lines()
+	if len(lines) != 2 || !strings.Contains(lines[0], "platinum") || !strings.Contains(lines[1], "TenantID") {
+		t.Errorf("Expected the ignored overrides logged, got %q", lines)
+	}
+}
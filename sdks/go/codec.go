@@ -0,0 +1,154 @@
+package atpsdk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FrameCodec encodes frames carried in binary WebSocket messages, such as
+// compressed or MessagePack frames. Text messages are always JSON.
+// Implementations must be safe for concurrent use.
+type FrameCodec interface {
+	// Marshal encodes frame
+	Marshal(frame *Frame) ([]byte, error)
+	// Unmarshal decodes data into frame
+	Unmarshal(data []byte, frame *Frame) error
+}
+
+// GzipCodec is a FrameCodec sending frames as gzip-compressed JSON
+type GzipCodec struct {
+	// Level is the compression level (default: gzip.DefaultCompression)
+	Level int
+}
+
+// Marshal encodes frame as gzip-compressed JSON
+func (g GzipCodec) Marshal(frame *Frame) ([]byte, error) {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return nil, err
+	}
+	level := g.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes gzip-compressed JSON into frame
+func (GzipCodec) Unmarshal(data []byte, frame *Frame) error {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(plain, frame)
+}
+
+// MessageTransport is implemented by transports that carry binary messages
+// besides JSON text ones, like the WebSocket transport. The client uses it
+// when SDKConfig.Codec is set.
+type MessageTransport interface {
+	Transport
+	// SendBinary delivers one codec-encoded frame as a binary message.
+	SendBinary(frame []byte) error
+	// ReceiveMessage is Receive, also reporting whether the message was
+	// binary.
+	ReceiveMessage() (data []byte, binary bool, err error)
+}
+
+// receiveMessage reads the next message of conn, reporting whether it was
+// binary
+func receiveMessage(conn Transport) ([]byte, bool, error) {
+	if mt, ok := conn.(MessageTransport); ok {
+		return mt.ReceiveMessage()
+	}
+	data, err := conn.Receive()
+	return data, false, err
+}
+
+// sendMessage writes data to conn as a binary or a text message
+func sendMessage(conn Transport, data []byte, binary bool) error {
+	if !binary {
+		return conn.Send(data)
+	}
+	mt, ok := conn.(MessageTransport)
+	if !ok {
+		return errors.New("transport does not support binary messages")
+	}
+	return mt.SendBinary(data)
+}
+
+// usesCodec reports whether frames are sent as binary messages encoded with
+// SDKConfig.Codec. Only the WebSocket transport carries binary messages.
+func (c *ATPClient) usesCodec() bool {
+	if c.config.Codec == nil {
+		return false
+	}
+	switch c.config.Transport {
+	case TransportWebSocket, TransportAuto:
+		return true
+	}
+	return false
+}
+
+// encodeFrame serializes an outgoing frame, reporting whether it must be
+// sent as a binary message. Frames over the size limit are refused.
+func (c *ATPClient) encodeFrame(frame *Frame) ([]byte, bool, error) {
+	if c.usesCodec() {
+		data, err := c.config.Codec.Marshal(frame)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to encode frame: %w", err)
+		}
+		return data, true, c.checkFrameSize(frame, data)
+	}
+	data, err := marshalFrame(frame)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal frame: %w", err)
+	}
+	return data, false, c.checkFrameSize(frame, data)
+}
+
+// decodeIncoming parses a received message into frame. A binary message is
+// decoded with SDKConfig.Codec and returned re-encoded as JSON, the form
+// signatures are verified over.
+func (c *ATPClient) decodeIncoming(data []byte, binary bool, frame *Frame) ([]byte, error) {
+	if !binary {
+		if c.config.StrictDecoding {
+			return data, decodeFrameStrict(data, frame)
+		}
+		return data, json.Unmarshal(data, frame)
+	}
+	if c.config.Codec == nil {
+		return nil, ErrCodecRequired
+	}
+	if err := c.config.Codec.Unmarshal(data, frame); err != nil {
+		return nil, fmt.Errorf("failed to decode binary frame: %w", err)
+	}
+	if c.config.StrictDecoding {
+		// Codecs have no unknown fields to report
+		if err := checkRequiredFields(frame); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(frame)
+}
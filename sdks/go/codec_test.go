@@ -0,0 +1,85 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func completionRouter(t *testing.T, codec FrameCodec) *testRouter {
+	return newCodecTestRouter(t, codec, func(_ int, f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": f.Payload["prompt"]}}}
+	})
+}
+
+func TestCodecBinaryFrames(t *testing.T) {
+	router := completionRouter(t, GzipCodec{})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Codec: GzipCodec{}})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "compressed"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.Text != "compressed" {
+		t.Errorf("Unexpected response %+v", response)
+	}
+	if router.BinaryMessages() == 0 {
+		t.Error("Expected the request sent as a binary message")
+	}
+
+	// Text messages are still decoded as JSON
+	frames, unsubscribe := client.Subscribe("event")
+	defer unsubscribe()
+	if err := router.SendRaw(websocket.TextMessage, []byte(`{"type":"event","stream_id":"s","msg_seq":1,"payload":{}}`)); err != nil {
+		t.Fatalf("SendRaw failed: %v", err)
+	}
+	select {
+	case f := <-frames:
+		if f.StreamID != "s" {
+			t.Errorf("Unexpected frame %+v", f)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Text frame not delivered")
+	}
+}
+
+func TestCodecRequiredForBinaryFrames(t *testing.T) {
+	router := completionRouter(t, nil)
+	asyncErrors := make(chan error, 1)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), OnAsyncError: func(err error) { asyncErrors <- err }})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	data, err := GzipCodec{}.Marshal(&Frame{Type: "event", StreamID: "s"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if err := router.SendRaw(websocket.BinaryMessage, data); err != nil {
+		t.Fatalf("SendRaw failed: %v", err)
+	}
+	select {
+	case err := <-asyncErrors:
+		if !errors.Is(err, ErrCodecRequired) {
+			t.Errorf("Expected ErrCodecRequired, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Binary frame without a codec was not reported")
+	}
+
+	// Without a codec, frames are sent as text
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "plain"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if n := router.BinaryMessages(); n != 0 {
+		t.Errorf("Expected no binary messages, got %d", n)
+	}
+}
@@ -0,0 +1,78 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// PartialOutputError fails a CompleteTo call that had already written part
+// of the response when it failed. It matches ErrPartialOutput with
+// errors.Is, and the underlying failure through Unwrap.
+type PartialOutputError struct {
+	// Written is the number of bytes written before the failure
+	Written int64
+	Err     error
+}
+
+func (e *PartialOutputError) Error() string {
+	return fmt.Sprintf("%v after %d bytes: %v", ErrPartialOutput, e.Written, e.Err)
+}
+
+// Is reports whether target is ErrPartialOutput
+func (e *PartialOutputError) Is(target error) bool {
+	return target == ErrPartialOutput
+}
+
+// Unwrap returns the underlying failure
+func (e *PartialOutputError) Unwrap() error {
+	return e.Err
+}
+
+// CompleteTo is Complete writing the text of the response to w as it is
+// generated, chunk by chunk, and returning the response once it ends. A w
+// with a Flush method, such as an http.ResponseWriter or a bufio.Writer, is
+// flushed after each chunk, or at most every WithFlushInterval. Failing to
+// write or flush cancels the request, like a failing WithTokenCallback
+// callback, which CompleteTo replaces. A failure after some of the text
+// was written is a *PartialOutputError.
+func (c *ATPClient) CompleteTo(ctx context.Context, w io.Writer, request CompletionRequest, opts ...RequestOption) (*CompletionResponse, error) {
+	interval := newRequestOptions(opts).flushInterval
+	var written atomic.Int64
+	var lastFlush time.Time
+	write := func(chunk CompletionChunk) error {
+		n, err := io.WriteString(w, chunk.Text)
+		written.Add(int64(n))
+		if err != nil {
+			return err
+		}
+		if now := c.config.Clock.Now(); chunk.Final || now.Sub(lastFlush) >= interval {
+			lastFlush = now
+			return flushWriter(w)
+		}
+		return nil
+	}
+
+	response, err := c.Complete(ctx, request, append(opts, WithTokenCallback(write))...)
+	if err != nil {
+		if n := written.Load(); n > 0 {
+			return nil, &PartialOutputError{Written: n, Err: err}
+		}
+		return nil, err
+	}
+	return response, nil
+}
+
+// flushWriter flushes w if it buffers its output
+func flushWriter(w io.Writer) error {
+	switch f := w.(type) {
+	case http.Flusher:
+		f.Flush()
+	case interface{ Flush() error }:
+		return f.Flush()
+	}
+	return nil
+}
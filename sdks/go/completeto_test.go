@@ -0,0 +1,92 @@
+package atpsdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flushRecorder is a writer counting its flushes, failing writes after
+// failAfter of them when set
+type flushRecorder struct {
+	buf       bytes.Buffer
+	writes    int
+	failAfter int
+	flushes   int
+}
+
+func (w *flushRecorder) Write(p []byte) (int, error) {
+	if w.failAfter > 0 && w.writes >= w.failAfter {
+		return 0, errors.New("broken pipe")
+	}
+	w.writes++
+	return w.buf.Write(p)
+}
+
+func (w *flushRecorder) String() string {
+	return w.buf.String()
+}
+
+func (w *flushRecorder) Flush() {
+	w.flushes++
+}
+
+func TestCompleteTo(t *testing.T) {
+	router, _ := streamingRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	w := &flushRecorder{}
+	response, err := client.CompleteTo(context.Background(), w, CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("CompleteTo failed: %v", err)
+	}
+	if w.String() != "Hello, world!" || response.Text != "Hello, world!" {
+		t.Errorf("Expected the whole text written and returned, got %q and %q", w.String(), response.Text)
+	}
+	if w.flushes != 3 {
+		t.Errorf("Expected a flush per chunk, got %d", w.flushes)
+	}
+
+	// With an interval, only the end is flushed once the first chunk was
+	w = &flushRecorder{}
+	if _, err := client.CompleteTo(context.Background(), w, CompletionRequest{Prompt: "hi"}, WithFlushInterval(time.Hour)); err != nil {
+		t.Fatalf("CompleteTo failed: %v", err)
+	}
+	if w.flushes != 2 {
+		t.Errorf("Expected the first and last chunks flushed, got %d flushes", w.flushes)
+	}
+}
+
+func TestCompleteToWriteError(t *testing.T) {
+	router, cancels := streamingRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Logger: &recordingLogger{}})
+	defer client.Close()
+
+	w := &flushRecorder{failAfter: 1}
+	_, err := client.CompleteTo(context.Background(), w, CompletionRequest{Prompt: "hi"})
+	var partial *PartialOutputError
+	if !errors.As(err, &partial) || partial.Written != 5 || !errors.Is(err, ErrTokenCallback) {
+		t.Fatalf("Expected a partial output error after 5 bytes, got %v", err)
+	}
+	select {
+	case <-cancels:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a cancel frame")
+	}
+
+	// Nothing written is a plain failure
+	_, err = client.CompleteTo(context.Background(), &failingWriter{}, CompletionRequest{Prompt: "hi"})
+	if !errors.Is(err, ErrTokenCallback) || errors.Is(err, ErrPartialOutput) {
+		t.Errorf("Expected a write failure without partial output, got %v", err)
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
@@ -0,0 +1,143 @@
+package atpsdk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envSetting is an SDKConfig field read by ConfigFromEnv from the variable
+// named prefix_name
+type envSetting struct {
+	name  string
+	parse func(config *SDKConfig, value string) error
+}
+
+// envSettings are the variables ConfigFromEnv reads
+var envSettings = []envSetting{
+	{"BASE_URL", envString(func(c *SDKConfig) *string { return &c.BaseURL })},
+	{"WS_URL", func(c *SDKConfig, value string) error {
+		c.WSURLs = strings.Split(value, ",")
+		for i := range c.WSURLs {
+			c.WSURLs[i] = strings.TrimSpace(c.WSURLs[i])
+		}
+		c.WSURL = c.WSURLs[0]
+		return nil
+	}},
+	{"API_KEY", envString(func(c *SDKConfig) *string { return &c.APIKey })},
+	{"TENANT_ID", envString(func(c *SDKConfig) *string { return &c.TenantID })},
+	{"SESSION_ID", envString(func(c *SDKConfig) *string { return &c.SessionID })},
+	{"TRANSPORT", envString(func(c *SDKConfig) *string { return &c.Transport })},
+	{"DEFAULT_QOS", func(c *SDKConfig, value string) error {
+		c.DefaultQoS = QoS(value)
+		return validateQoS(c.DefaultQoS)
+	}},
+	{"TIMEOUT", envDuration(func(c *SDKConfig) *time.Duration { return &c.DefaultTimeout })},
+	{"RETRY_DELAY", envDuration(func(c *SDKConfig) *time.Duration { return &c.RetryDelay })},
+	{"HEARTBEAT_INTERVAL", envDuration(func(c *SDKConfig) *time.Duration { return &c.HeartbeatInterval })},
+	{"WRITE_TIMEOUT", envDuration(func(c *SDKConfig) *time.Duration { return &c.WriteTimeout })},
+	{"READ_TIMEOUT", envDuration(func(c *SDKConfig) *time.Duration { return &c.ReadTimeout })},
+	{"DIAL_TIMEOUT", envDuration(func(c *SDKConfig) *time.Duration { return &c.DialTimeout })},
+	{"MAX_RETRIES", envInt(func(c *SDKConfig) *int { return &c.MaxRetries })},
+	{"POOL_SIZE", envInt(func(c *SDKConfig) *int { return &c.PoolSize })},
+	{"AUTO_RECONNECT", envBool(func(c *SDKConfig) *bool { return &c.AutoReconnect })},
+	{"SESSION_HANDSHAKE", envBool(func(c *SDKConfig) *bool { return &c.SessionHandshake })},
+	{"CANCEL_ON_TIMEOUT", envBool(func(c *SDKConfig) *bool { return &c.CancelOnTimeout })},
+	{"STRICT_FRAME_TYPES", envBool(func(c *SDKConfig) *bool { return &c.StrictFrameTypes })},
+	{"STRICT_DECODING", envBool(func(c *SDKConfig) *bool { return &c.StrictDecoding })},
+}
+
+func envString(field func(*SDKConfig) *string) func(*SDKConfig, string) error {
+	return func(c *SDKConfig, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func envDuration(field func(*SDKConfig) *time.Duration) func(*SDKConfig, string) error {
+	return func(c *SDKConfig, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("not a duration such as 30s or 1m30s")
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func envInt(field func(*SDKConfig) *int) func(*SDKConfig, string) error {
+	return func(c *SDKConfig, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func envBool(field func(*SDKConfig) *bool) func(*SDKConfig, string) error {
+	return func(c *SDKConfig, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("not a boolean such as true or 0")
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+// ConfigFromEnv reads a configuration from the environment variables named
+// prefix followed by an underscore and BASE_URL, WS_URL (a comma-separated
+// failover list), API_KEY, TENANT_ID, SESSION_ID, TRANSPORT, DEFAULT_QOS,
+// TIMEOUT (DefaultTimeout), RETRY_DELAY, HEARTBEAT_INTERVAL, WRITE_TIMEOUT,
+// READ_TIMEOUT, DIAL_TIMEOUT, MAX_RETRIES, POOL_SIZE, AUTO_RECONNECT,
+// SESSION_HANDSHAKE, CANCEL_ON_TIMEOUT, STRICT_FRAME_TYPES or
+// STRICT_DECODING; the prefix defaults to ATP. Durations are written as
+// for time.ParseDuration and booleans as for strconv.ParseBool. Unset and
+// empty variables leave their field zero. The error names every variable
+// that could not be parsed, with its value, and matches ErrInvalidConfig.
+// See MergeConfig to combine the result with a configuration in code.
+func ConfigFromEnv(prefix string) (SDKConfig, error) {
+	if prefix == "" {
+		prefix = "ATP"
+	}
+	var config SDKConfig
+	var errs []error
+	for _, setting := range envSettings {
+		name := prefix + "_" + setting.name
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if err := setting.parse(&config, value); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s=%q: %v", ErrInvalidConfig, name, value, err))
+		}
+	}
+	return config, errors.Join(errs...)
+}
+
+// MergeConfig returns config with each of its zero fields taken from
+// fallback, so that settings made explicitly in code win over those read by
+// ConfigFromEnv. WSURL and WSURLs are taken together, from config when it
+// sets either:
+//
+//	env, err := atpsdk.ConfigFromEnv("ATP")
+//	client := atpsdk.NewATPClient(atpsdk.MergeConfig(config, env))
+func MergeConfig(config, fallback SDKConfig) SDKConfig {
+	if config.WSURL != "" || len(config.WSURLs) > 0 {
+		fallback.WSURL, fallback.WSURLs = config.WSURL, config.WSURLs
+	}
+	merged := reflect.ValueOf(&config).Elem()
+	from := reflect.ValueOf(fallback)
+	for i := range merged.NumField() {
+		if field := merged.Field(i); field.IsZero() {
+			field.Set(from.Field(i))
+		}
+	}
+	return config
+}
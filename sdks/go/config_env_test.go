@@ -0,0 +1,66 @@
+package atpsdk
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("ATP_BASE_URL", "https://router.example.com")
+	t.Setenv("ATP_WS_URL", "wss://a.example.com, wss://b.example.com")
+	t.Setenv("ATP_API_KEY", "key")
+	t.Setenv("ATP_TENANT_ID", "acme")
+	t.Setenv("ATP_TIMEOUT", "45s")
+	t.Setenv("ATP_HEARTBEAT_INTERVAL", "1m")
+	t.Setenv("ATP_MAX_RETRIES", "5")
+	t.Setenv("ATP_AUTO_RECONNECT", "true")
+	t.Setenv("ATP_CANCEL_ON_TIMEOUT", "")
+
+	config, err := ConfigFromEnv("")
+	if err != nil {
+		t.Fatalf("ConfigFromEnv failed: %v", err)
+	}
+	if config.BaseURL != "https://router.example.com" || config.APIKey != "key" || config.TenantID != "acme" ||
+		config.DefaultTimeout != 45*time.Second || config.HeartbeatInterval != time.Minute || config.MaxRetries != 5 ||
+		!config.AutoReconnect || config.CancelOnTimeout {
+		t.Errorf("Unexpected config %+v", config)
+	}
+	if config.WSURL != "wss://a.example.com" || !slices.Equal(config.WSURLs, []string{"wss://a.example.com", "wss://b.example.com"}) {
+		t.Errorf("Expected the failover list, got %q and %q", config.WSURL, config.WSURLs)
+	}
+
+	t.Setenv("SVC_TENANT_ID", "other")
+	if config, _ := ConfigFromEnv("SVC"); config.TenantID != "other" || config.APIKey != "" {
+		t.Errorf("Expected only the prefixed variables read, got %+v", config)
+	}
+}
+
+func TestConfigFromEnvErrors(t *testing.T) {
+	t.Setenv("ATP_TIMEOUT", "30")
+	t.Setenv("ATP_AUTO_RECONNECT", "sometimes")
+	t.Setenv("ATP_DEFAULT_QOS", "platinum")
+
+	_, err := ConfigFromEnv("ATP")
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Expected ErrInvalidConfig, got %v", err)
+	}
+	for _, want := range []string{`ATP_TIMEOUT="30"`, `ATP_AUTO_RECONNECT="sometimes"`, `ATP_DEFAULT_QOS="platinum"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to name %s, got %v", want, err)
+		}
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	env := SDKConfig{TenantID: "env", APIKey: "env-key", WSURL: "wss://env", WSURLs: []string{"wss://env"}, MaxRetries: 5}
+	merged := MergeConfig(SDKConfig{TenantID: "code", WSURL: "ws://code"}, env)
+	if merged.TenantID != "code" || merged.APIKey != "env-key" || merged.MaxRetries != 5 {
+		t.Errorf("Expected code settings over env ones, got %+v", merged)
+	}
+	if merged.WSURL != "ws://code" || merged.WSURLs != nil {
+		t.Errorf("Expected the WebSocket URLs from code only, got %q and %q", merged.WSURL, merged.WSURLs)
+	}
+}
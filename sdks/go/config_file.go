@@ -0,0 +1,219 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configDuration is a duration written as a string such as "30s" in a
+// configuration file
+type configDuration time.Duration
+
+func (d *configDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations are strings such as \"30s\", got %s", data)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = configDuration(parsed)
+	return nil
+}
+
+func (d configDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// configFile is the layout of the files read by LoadConfig
+type configFile struct {
+	BaseURL    string   `json:"base_url,omitempty"`
+	WSURL      string   `json:"ws_url,omitempty"`
+	WSURLs     []string `json:"ws_urls,omitempty"`
+	APIKey     string   `json:"api_key,omitempty"`
+	TenantID   string   `json:"tenant_id,omitempty"`
+	SessionID  string   `json:"session_id,omitempty"`
+	Transport  string   `json:"transport,omitempty"`
+	DefaultQoS QoS      `json:"default_qos,omitempty"`
+
+	AutoReconnect    bool `json:"auto_reconnect,omitempty"`
+	SessionHandshake bool `json:"session_handshake,omitempty"`
+	CancelOnTimeout  bool `json:"cancel_on_timeout,omitempty"`
+	StrictFrameTypes bool `json:"strict_frame_types,omitempty"`
+	StrictDecoding   bool `json:"strict_decoding,omitempty"`
+
+	Timeouts struct {
+		Default   configDuration `json:"default,omitempty"`
+		Write     configDuration `json:"write,omitempty"`
+		Read      configDuration `json:"read,omitempty"`
+		Dial      configDuration `json:"dial,omitempty"`
+		Handshake configDuration `json:"handshake,omitempty"`
+	} `json:"timeouts"`
+	Retry struct {
+		MaxRetries int            `json:"max_retries,omitempty"`
+		Delay      configDuration `json:"delay,omitempty"`
+	} `json:"retry"`
+	Heartbeat struct {
+		Interval      configDuration `json:"interval,omitempty"`
+		Jitter        float64        `json:"jitter,omitempty"`
+		MissThreshold int            `json:"miss_threshold,omitempty"`
+	} `json:"heartbeat"`
+	TLS struct {
+		ClientCertFile string `json:"client_cert_file,omitempty"`
+		ClientKeyFile  string `json:"client_key_file,omitempty"`
+		CAFile         string `json:"ca_file,omitempty"`
+	} `json:"tls"`
+	Queue struct {
+		Size           int            `json:"size,omitempty"`
+		OverflowPolicy OverflowPolicy `json:"overflow_policy,omitempty"`
+	} `json:"queue"`
+	Budget struct {
+		LimitUSD       float64        `json:"limit_usd,omitempty"`
+		Thresholds     []float64      `json:"thresholds,omitempty"`
+		BurnRateWindow configDuration `json:"burn_rate_window,omitempty"`
+		ReportInHealth bool           `json:"report_in_health,omitempty"`
+	} `json:"budget"`
+}
+
+// config returns the SDKConfig the file describes
+func (f *configFile) config() SDKConfig {
+	return SDKConfig{
+		BaseURL:    f.BaseURL,
+		WSURL:      f.WSURL,
+		WSURLs:     f.WSURLs,
+		APIKey:     f.APIKey,
+		TenantID:   f.TenantID,
+		SessionID:  f.SessionID,
+		Transport:  f.Transport,
+		DefaultQoS: f.DefaultQoS,
+
+		AutoReconnect:    f.AutoReconnect,
+		SessionHandshake: f.SessionHandshake,
+		CancelOnTimeout:  f.CancelOnTimeout,
+		StrictFrameTypes: f.StrictFrameTypes,
+		StrictDecoding:   f.StrictDecoding,
+
+		DefaultTimeout:   time.Duration(f.Timeouts.Default),
+		WriteTimeout:     time.Duration(f.Timeouts.Write),
+		ReadTimeout:      time.Duration(f.Timeouts.Read),
+		DialTimeout:      time.Duration(f.Timeouts.Dial),
+		HandshakeTimeout: time.Duration(f.Timeouts.Handshake),
+
+		MaxRetries: f.Retry.MaxRetries,
+		RetryDelay: time.Duration(f.Retry.Delay),
+
+		HeartbeatInterval:      time.Duration(f.Heartbeat.Interval),
+		HeartbeatJitter:        f.Heartbeat.Jitter,
+		HeartbeatMissThreshold: f.Heartbeat.MissThreshold,
+
+		ClientCertFile: f.TLS.ClientCertFile,
+		ClientKeyFile:  f.TLS.ClientKeyFile,
+		CAFile:         f.TLS.CAFile,
+
+		OutboundQueueSize: f.Queue.Size,
+		OverflowPolicy:    f.Queue.OverflowPolicy,
+
+		Budget: BudgetConfig{
+			LimitUSD:       f.Budget.LimitUSD,
+			Thresholds:     f.Budget.Thresholds,
+			BurnRateWindow: time.Duration(f.Budget.BurnRateWindow),
+			ReportInHealth: f.Budget.ReportInHealth,
+		},
+	}
+}
+
+// LoadConfig reads a configuration from the JSON or YAML file at path,
+// told apart by the .json, .yaml or .yml extension, or else by whether the
+// content starts with a brace. Besides the top-level settings (base_url,
+// ws_url, ws_urls, api_key, tenant_id, session_id, transport, default_qos
+// and the auto_reconnect, session_handshake, cancel_on_timeout,
+// strict_frame_types and strict_decoding flags), settings are grouped in
+// sections:
+//
+//	timeouts:  default, write, read, dial, handshake
+//	retry:     max_retries, delay
+//	heartbeat: interval, jitter, miss_threshold
+//	tls:       client_cert_file, client_key_file, ca_file
+//	queue:     size, overflow_policy
+//	budget:    limit_usd, thresholds, burn_rate_window, report_in_health
+//
+// Durations are strings such as "30s". Unknown keys are reported to
+// logger as warnings, unless it is nil, and otherwise ignored. Missing
+// settings are left zero, for NewATPClient's defaults. A file that cannot
+// be parsed fails with an error matching ErrInvalidConfig.
+func LoadConfig(path string, logger Logger) (SDKConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SDKConfig{}, fmt.Errorf("failed to read config: %w", err)
+	}
+	if isYAMLConfig(path, data) {
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return SDKConfig{}, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return SDKConfig{}, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+		}
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return SDKConfig{}, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+	if logger != nil {
+		for _, key := range unknownConfigKeys(doc, reflect.TypeFor[configFile](), "") {
+			logger.Printf("Warning: %s: unknown key %q", path, key)
+		}
+	}
+	var file configFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return SDKConfig{}, fmt.Errorf("%w: %s: %v", ErrInvalidConfig, path, err)
+	}
+	return file.config(), nil
+}
+
+// isYAMLConfig reports whether the configuration file at path, holding
+// data, is YAML rather than JSON
+func isYAMLConfig(path string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return false
+	case ".yaml", ".yml":
+		return true
+	}
+	return !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// unknownConfigKeys returns the keys of doc, and of its sections, that
+// have no field in t, prefixed with prefix and sorted
+func unknownConfigKeys(doc map[string]interface{}, t reflect.Type, prefix string) []string {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = t.Field(i).Type
+	}
+	var unknown []string
+	for key, value := range doc {
+		field, ok := fields[key]
+		switch {
+		case !ok:
+			unknown = append(unknown, prefix+key)
+		case field.Kind() == reflect.Struct:
+			if section, ok := value.(map[string]interface{}); ok {
+				unknown = append(unknown, unknownConfigKeys(section, field, prefix+key+".")...)
+			}
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
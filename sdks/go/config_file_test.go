@@ -0,0 +1,144 @@
+package atpsdk
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes content to name in a temporary directory
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigRoundTrip(t *testing.T) {
+	const content = `{
+		"base_url": "https://router.example.com",
+		"ws_urls": ["wss://a.example.com", "wss://b.example.com"],
+		"tenant_id": "acme",
+		"default_qos": "gold",
+		"auto_reconnect": true,
+		"timeouts": {"default": "45s", "dial": "5s"},
+		"retry": {"max_retries": 5, "delay": "250ms"},
+		"heartbeat": {"interval": "1m", "miss_threshold": 3},
+		"tls": {"ca_file": "/etc/atp/ca.pem"},
+		"queue": {"overflow_policy": "error"},
+		"budget": {"limit_usd": 12.5, "thresholds": [0.5, 0.9], "burn_rate_window": "1h"}
+	}`
+	config, err := LoadConfig(writeConfigFile(t, "atp.json", content), nil)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	want := SDKConfig{
+		BaseURL:                "https://router.example.com",
+		WSURLs:                 []string{"wss://a.example.com", "wss://b.example.com"},
+		TenantID:               "acme",
+		DefaultQoS:             QoSGold,
+		AutoReconnect:          true,
+		DefaultTimeout:         45 * time.Second,
+		DialTimeout:            5 * time.Second,
+		MaxRetries:             5,
+		RetryDelay:             250 * time.Millisecond,
+		HeartbeatInterval:      time.Minute,
+		HeartbeatMissThreshold: 3,
+		CAFile:                 "/etc/atp/ca.pem",
+		OverflowPolicy:         OverflowError,
+		Budget: BudgetConfig{
+			LimitUSD:       12.5,
+			Thresholds:     []float64{0.5, 0.9},
+			BurnRateWindow: time.Hour,
+		},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Expected %+v, got %+v", want, config)
+	}
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	const content = `
+base_url: https://router.example.com
+ws_urls: [wss://a.example.com, wss://b.example.com]
+tenant_id: acme
+auto_reconnect: true
+timeouts:
+  default: 45s
+retry:
+  max_retries: 5
+  delay: 250ms
+heartbeat:
+  interval: 1m
+  jitter: 0.1
+tls:
+  ca_file: /etc/atp/ca.pem
+`
+	// Without a known extension, YAML is told from JSON by its content
+	for _, name := range []string{"atp.yaml", "atp.yml", "atp.conf"} {
+		config, err := LoadConfig(writeConfigFile(t, name, content), nil)
+		if err != nil {
+			t.Fatalf("LoadConfig(%s) failed: %v", name, err)
+		}
+		if config.BaseURL != "https://router.example.com" || config.TenantID != "acme" || !config.AutoReconnect ||
+			config.DefaultTimeout != 45*time.Second || config.MaxRetries != 5 || config.RetryDelay != 250*time.Millisecond ||
+			config.HeartbeatInterval != time.Minute || config.HeartbeatJitter != 0.1 || config.CAFile != "/etc/atp/ca.pem" {
+			t.Errorf("%s: unexpected config %+v", name, config)
+		}
+		if !slices.Equal(config.WSURLs, []string{"wss://a.example.com", "wss://b.example.com"}) {
+			t.Errorf("%s: expected the failover list, got %q", name, config.WSURLs)
+		}
+	}
+
+	config, err := LoadConfig(writeConfigFile(t, "atp.conf", `{"tenant_id": "acme"}`), nil)
+	if err != nil || config.TenantID != "acme" {
+		t.Errorf("Expected JSON sniffed from the content, got %+v, %v", config, err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"atp.yaml": "timeouts:\n  default: soon\n",
+		"atp.json": `{"retry": {"delay": 30}}`,
+		"bad.json": `{"tenant_id": `,
+	} {
+		_, err := LoadConfig(writeConfigFile(t, name, content), nil)
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: expected ErrInvalidConfig, got %v", name, err)
+		}
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json"), nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing file reported, got %v", err)
+	}
+}
+
+func TestUnknownConfigKeys(t *testing.T) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"tenant_id": "acme", "tenant": "x", "retry": {"max_retries": 1, "backoff": "1s"}, "rate_limit": {}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	unknown := unknownConfigKeys(doc, reflect.TypeFor[configFile](), "")
+	if want := []string{"rate_limit", "retry.backoff", "tenant"}; !slices.Equal(unknown, want) {
+		t.Errorf("Expected unknown keys %q, got %q", want, unknown)
+	}
+
+	// LoadConfig reports them to the given logger
+	logger := &recordingLogger{}
+	path := writeConfigFile(t, "atp.yaml", "tenant_id: acme\nretry:\n  backoff: 1s\n")
+	if _, err := LoadConfig(path, logger); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if lines := logger.Lines(); len(lines) != 1 || !strings.Contains(lines[0], `unknown key "retry.backoff"`) {
+		t.Errorf("Expected a warning for retry.backoff, got %q", lines)
+	}
+}
@@ -0,0 +1,117 @@
+package atpsdk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConfigError is one problem found by SDKConfig.Validate. It matches
+// ErrInvalidConfig with errors.Is.
+type ConfigError struct {
+	// Field is the SDKConfig field at fault, e.g. "WSURLs[1]"
+	Field   string
+	Problem string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%v: %s: %s", ErrInvalidConfig, e.Field, e.Problem)
+}
+
+// Is reports whether target is ErrInvalidConfig
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// Validate checks the configuration for settings that cannot work: URLs of
+// the wrong scheme, negative durations, heartbeats that would time out
+// their own connection and options that exclude each other. Zero values
+// are valid, standing for the defaults. It returns every problem found,
+// each a *ConfigError, joined with errors.Join, or nil. The TLS identity
+// and encryption key are checked when the client is created.
+func (c SDKConfig) Validate() error {
+	var errs []error
+	problem := func(field, format string, args ...interface{}) {
+		errs = append(errs, &ConfigError{Field: field, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	if c.BaseURL != "" {
+		if err := checkURL(c.BaseURL, "http", "https"); err != nil {
+			problem("BaseURL", "%v", err)
+		}
+	}
+	switch c.Transport {
+	case "", TransportWebSocket, TransportAuto:
+		// Other transports may take any address in WSURL
+		if c.WSURL != "" {
+			if err := checkURL(c.WSURL, "ws", "wss"); err != nil {
+				problem("WSURL", "%v", err)
+			}
+		}
+		for i, wsURL := range c.WSURLs {
+			if err := checkURL(wsURL, "ws", "wss"); err != nil {
+				problem(fmt.Sprintf("WSURLs[%d]", i), "%v", err)
+			}
+		}
+	case TransportHTTP:
+		if c.PoolSize > 1 {
+			problem("PoolSize", "connection pools need a WebSocket transport, not %q", c.Transport)
+		}
+	default:
+		if _, ok := registeredTransport(c.Transport); !ok {
+			problem("Transport", "unknown transport %q", c.Transport)
+		}
+	}
+	if c.APIKey != strings.TrimSpace(c.APIKey) {
+		problem("APIKey", "has leading or trailing whitespace")
+	}
+
+	durations := []struct {
+		field string
+		value time.Duration
+	}{
+		{"DefaultTimeout", c.DefaultTimeout},
+		{"RetryDelay", c.RetryDelay},
+		{"HeartbeatInterval", c.HeartbeatInterval},
+		{"EndpointCooldown", c.EndpointCooldown},
+		{"WriteTimeout", c.WriteTimeout},
+		{"ReadTimeout", c.ReadTimeout},
+		{"DialTimeout", c.DialTimeout},
+		{"PoolMaxAge", c.PoolMaxAge},
+		{"HandshakeTimeout", c.HandshakeTimeout},
+		{"SessionHandshakeTimeout", c.SessionHandshakeTimeout},
+		{"TokenCallbackTimeout", c.TokenCallbackTimeout},
+		{"MaxRequestTTL", c.MaxRequestTTL},
+		{"ModelCacheTTL", c.ModelCacheTTL},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			problem(d.field, "negative duration %v", d.value)
+		}
+	}
+	if c.HeartbeatInterval > 0 && c.WriteTimeout > c.HeartbeatInterval {
+		problem("HeartbeatInterval", "%v is shorter than WriteTimeout %v, so a slow write holds up the next heartbeat", c.HeartbeatInterval, c.WriteTimeout)
+	}
+	if c.HeartbeatInterval > 0 && c.ReadTimeout > 0 && c.ReadTimeout <= c.HeartbeatInterval {
+		problem("ReadTimeout", "%v is not longer than HeartbeatInterval %v, so idle connections time out", c.ReadTimeout, c.HeartbeatInterval)
+	}
+
+	if c.DefaultQoS != "" {
+		if err := validateQoS(c.DefaultQoS); err != nil {
+			problem("DefaultQoS", "%v", err)
+		}
+	}
+	switch c.OverflowPolicy {
+	case "", OverflowBlock, OverflowError, OverflowDropOldestHeartbeats:
+	default:
+		problem("OverflowPolicy", "unknown policy %q", c.OverflowPolicy)
+	}
+	if len(c.EncryptionKey) > 0 && c.PayloadCipher != nil {
+		problem("EncryptionKey", "set along with PayloadCipher, which replaces it")
+	}
+	if c.KeepExpiredFrames && c.OnExpiredFrame != nil {
+		problem("OnExpiredFrame", "never called with KeepExpiredFrames")
+	}
+	return errors.Join(errs...)
+}
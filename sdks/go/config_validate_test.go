@@ -0,0 +1,73 @@
+package atpsdk
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	if err := (SDKConfig{}).Validate(); err != nil {
+		t.Errorf("Expected the zero config to be valid, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		config SDKConfig
+		field  string
+	}{
+		{"ws base URL", SDKConfig{BaseURL: "ws://router"}, "BaseURL"},
+		{"http WebSocket URL", SDKConfig{WSURL: "http://router"}, "WSURL"},
+		{"failover URL", SDKConfig{WSURLs: []string{"ws://a", "router:8000"}}, "WSURLs[1]"},
+		{"negative timeout", SDKConfig{DefaultTimeout: -time.Second}, "DefaultTimeout"},
+		{"heartbeat under write timeout", SDKConfig{HeartbeatInterval: time.Second, WriteTimeout: 5 * time.Second}, "HeartbeatInterval"},
+		{"read timeout under heartbeat", SDKConfig{HeartbeatInterval: 10 * time.Second, ReadTimeout: 10 * time.Second}, "ReadTimeout"},
+		{"API key whitespace", SDKConfig{APIKey: "key\n"}, "APIKey"},
+		{"unknown transport", SDKConfig{Transport: "smoke-signals"}, "Transport"},
+		{"pool over HTTP", SDKConfig{Transport: TransportHTTP, PoolSize: 2}, "PoolSize"},
+		{"unknown QoS", SDKConfig{DefaultQoS: "platinum"}, "DefaultQoS"},
+		{"unknown overflow policy", SDKConfig{OverflowPolicy: "shrug"}, "OverflowPolicy"},
+		{"key and cipher", SDKConfig{EncryptionKey: testEncryptionKey, PayloadCipher: mustCipher(t)}, "EncryptionKey"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) || configErr.Field != tt.field || !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("Expected a problem with %s, got %v", tt.field, err)
+			}
+		})
+	}
+
+	// Every problem is reported
+	err := SDKConfig{BaseURL: "ws://router", WSURL: "http://router", RetryDelay: -1}.Validate()
+	if problems := err.(interface{ Unwrap() []error }).Unwrap(); len(problems) != 3 {
+		t.Errorf("Expected 3 problems, got %v", problems)
+	}
+}
+
+func mustCipher(t *testing.T) PayloadCipher {
+	cipher, err := NewAESGCMCipher(testEncryptionKey)
+	if err != nil {
+		t.Fatalf("NewAESGCMCipher failed: %v", err)
+	}
+	return cipher
+}
+
+func TestInvalidConfigRefusesConnect(t *testing.T) {
+	logger := &recordingLogger{}
+	client := NewATPClient(SDKConfig{WSURL: "http://localhost:8000", Logger: logger})
+	defer client.Close()
+
+	if len(logger.Lines()) != 1 {
+		t.Errorf("Expected NewATPClient to log the problem, got %v", logger.Lines())
+	}
+	var configErr *ConfigError
+	if err := client.Connect(); !errors.As(err, &configErr) || configErr.Field != "WSURL" {
+		t.Errorf("Expected Connect to fail naming WSURL, got %v", err)
+	}
+
+	if _, err := NewClient(WithConfig(SDKConfig{ReadTimeout: -time.Second})); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected NewClient to validate the config, got %v", err)
+	}
+}
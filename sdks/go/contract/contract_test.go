@@ -0,0 +1,188 @@
+package contract
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+	"github.com/atp-project/atp-go-sdk/atpsdktest"
+)
+
+var update = flag.Bool("update", false, "re-record the scenarios in testdata/scenarios")
+
+const scenarioDir = "testdata/scenarios"
+
+func TestRecordedScenarios(t *testing.T) {
+	if *update {
+		recordScenarios(t)
+	}
+
+	allowlist, err := LoadAllowlist("testdata/allowlist.json")
+	if err != nil {
+		t.Fatalf("LoadAllowlist failed: %v", err)
+	}
+	report, err := Run(context.Background(), scenarioDir, Options{Allowlist: allowlist})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(report.Scenarios) < 4 {
+		t.Errorf("Expected the shipped scenarios to be replayed, got %v", report.Scenarios)
+	}
+	if err := report.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDetectsDivergences(t *testing.T) {
+	scenario, err := LoadScenario(scenarioDir + "/completion_basic.json")
+	if err != nil {
+		t.Fatalf("LoadScenario failed: %v", err)
+	}
+
+	// Pretend the recorded client sent a different prompt and parsed a
+	// different text
+	step := &scenario.Steps[0]
+	step.Exchanges[0].Sent.Payload["prompt"] = "old prompt"
+	step.Result.Text = "old text"
+
+	report := RunScenarios(context.Background(), []Scenario{scenario}, Options{})
+	keys := make(map[string]Divergence)
+	for _, d := range report.Divergences {
+		keys[d.Key()] = d
+	}
+	if d, ok := keys["completion_basic/0/sent_frame/payload.prompt"]; !ok || d.Expected != `"old prompt"` {
+		t.Errorf("Expected a sent frame divergence, got %v", report.Divergences)
+	}
+	if _, ok := keys["completion_basic/0/result/text"]; !ok {
+		t.Errorf("Expected a result divergence, got %v", report.Divergences)
+	}
+	if report.Err() == nil {
+		t.Error("Expected unexplained divergences")
+	}
+}
+
+func TestDetectsNewErrorsAndMissingFrames(t *testing.T) {
+	scenario, err := LoadScenario(scenarioDir + "/completion_basic.json")
+	if err != nil {
+		t.Fatalf("LoadScenario failed: %v", err)
+	}
+	// A recording where the router never answered and a second frame was
+	// sent that the current client no longer sends
+	step := &scenario.Steps[0]
+	step.Exchanges = append(step.Exchanges, step.Exchanges[0])
+	step.Exchanges[0].Replies = nil
+
+	report := RunScenarios(context.Background(), []Scenario{scenario}, Options{StepTimeout: 200 * time.Millisecond})
+	var kinds []string
+	for _, d := range report.Unexplained() {
+		kinds = append(kinds, string(d.Kind))
+	}
+	joined := strings.Join(kinds, ",")
+	if !strings.Contains(joined, string(DivergenceMissingFrame)) || !strings.Contains(joined, string(DivergenceError)) {
+		t.Errorf("Expected missing frame and error divergences, got %v", report.Divergences)
+	}
+}
+
+func TestAllowlistExplainsIntentionalChanges(t *testing.T) {
+	addVersion := func(config *atpsdk.SDKConfig) {
+		config.SendInterceptors = append(config.SendInterceptors, atpsdk.ClientVersionInterceptor("9.9.9"))
+	}
+
+	report, err := Run(context.Background(), scenarioDir, Options{Configure: addVersion})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(report.Unexplained()) == 0 {
+		t.Fatal("Expected the new client_version field to diverge")
+	}
+	for _, d := range report.Unexplained() {
+		if d.Kind != DivergenceSentFrame || d.Path != "payload.client_version" {
+			t.Errorf("Unexpected divergence %v", d)
+		}
+	}
+
+	report, err = Run(context.Background(), scenarioDir, Options{
+		Configure: addVersion,
+		Allowlist: []AllowRule{{Pattern: "*/*/sent_frame/payload.client_version", Reason: "client version is now advertised"}},
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if err := report.Err(); err != nil {
+		t.Error(err)
+	}
+	if len(report.Divergences) == 0 || report.Divergences[0].AllowedBy != "client version is now advertised" {
+		t.Errorf("Expected allowed divergences to be reported, got %v", report.Divergences)
+	}
+}
+
+// recordScenarios records the shipped scenarios against a scripted router
+func recordScenarios(t *testing.T) {
+	router := scriptedRouter(t)
+
+	record := func(name string, calls func(context.Context, *Recorder, *atpsdk.ATPClient)) {
+		recorder := NewRecorder(name)
+		client := atpsdk.NewATPClient(recorder.Configure(atpsdk.SDKConfig{WSURL: router, TenantID: "contract-tenant"}))
+		defer client.Close()
+
+		calls(context.Background(), recorder, client)
+		if err := SaveScenario(scenarioDir, recorder.Scenario()); err != nil {
+			t.Fatalf("SaveScenario failed: %v", err)
+		}
+	}
+
+	record("completion_basic", func(ctx context.Context, r *Recorder, c *atpsdk.ATPClient) {
+		_, _ = r.Complete(ctx, c, atpsdk.CompletionRequest{Prompt: "Say hello", MaxTokens: 16, Temperature: 0.2})
+	})
+	record("completion_error", func(ctx context.Context, r *Recorder, c *atpsdk.ATPClient) {
+		_, _ = r.Complete(ctx, c, atpsdk.CompletionRequest{Prompt: "Say hello", Model: "retired-model"})
+		_, _ = r.Complete(ctx, c, atpsdk.CompletionRequest{Prompt: "", Model: "small-model"})
+	})
+	record("model_fallback", func(ctx context.Context, r *Recorder, c *atpsdk.ATPClient) {
+		_, _ = r.Complete(ctx, c, atpsdk.CompletionRequest{Prompt: "Summarize", Model: "large-model"}, "small-model")
+	})
+	record("adapter_registration", func(ctx context.Context, r *Recorder, c *atpsdk.ATPClient) {
+		_ = r.AdvertiseCapabilities(ctx, c, atpsdk.CapabilityAdvertisement{
+			AdapterID:    "adapter-1",
+			AdapterType:  "ollama",
+			Capabilities: []string{"text-generation"},
+			Models:       []string{"small-model"},
+		})
+		_ = r.ReportHealth(ctx, c, atpsdk.HealthStatus{AdapterID: "adapter-1", Status: "healthy"})
+	})
+}
+
+// scriptedRouter serves canned router behavior for recording
+func scriptedRouter(t *testing.T) string {
+	router := atpsdktest.NewMockRouter(t)
+	router.OnType("completion_request", func(f atpsdk.Frame) []atpsdk.Frame {
+		routerError := func(code, message string) []atpsdk.Frame {
+			return []atpsdk.Frame{atpsdktest.Reply(f, "error", map[string]interface{}{
+				"error": map[string]interface{}{"code": code, "message": message},
+			})}
+		}
+		switch {
+		case f.Payload["model"] == "retired-model" || f.Payload["model"] == "large-model":
+			return routerError(atpsdk.ErrorCodeModelNotServed, "model is not served")
+		case f.Payload["prompt"] == "":
+			return routerError(atpsdk.ErrorCodeInvalidRequest, "prompt is required")
+		}
+		model, _ := f.Payload["model"].(string)
+		if model == "" {
+			model = "default-model"
+		}
+		return []atpsdk.Frame{atpsdktest.Reply(f, "completion_response", map[string]interface{}{
+			"text": "Hello!", "model_used": model, "tokens_in": 3, "tokens_out": 2,
+			"cost_usd": 0.0001, "quality_score": 0.9, "finished": true,
+		})}
+	})
+	ack := func(f atpsdk.Frame) []atpsdk.Frame {
+		return []atpsdk.Frame{atpsdktest.Reply(f, "completion_response", map[string]interface{}{"status": "ack"})}
+	}
+	router.OnType("adapter.capability", ack)
+	router.OnType("adapter.health", ack)
+	return router.URL()
+}
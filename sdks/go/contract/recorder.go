@@ -0,0 +1,136 @@
+package contract
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+)
+
+// Recorder records client calls into a Scenario. Configure the client with
+// Configure, then make the calls to record through the Recorder's methods.
+type Recorder struct {
+	mu       sync.Mutex
+	scenario Scenario
+	current  *Step
+}
+
+// NewRecorder creates a recorder for a scenario called name
+func NewRecorder(name string) *Recorder {
+	return &Recorder{scenario: Scenario{Name: name}}
+}
+
+// Configure returns config with the recorder's interceptors installed. The
+// send interceptor runs last so it captures frames as sent; the receive
+// interceptor runs first so it captures frames as received.
+func (r *Recorder) Configure(config atpsdk.SDKConfig) atpsdk.SDKConfig {
+	r.mu.Lock()
+	r.scenario.TenantID = config.TenantID
+	r.mu.Unlock()
+
+	config.SendInterceptors = append(append([]func(*atpsdk.Frame) error(nil), config.SendInterceptors...), r.captureSent)
+	config.ReceiveInterceptors = append([]func(*atpsdk.Frame) error{r.captureReceived}, config.ReceiveInterceptors...)
+	return config
+}
+
+// Complete records client.Complete
+func (r *Recorder) Complete(ctx context.Context, client *atpsdk.ATPClient, request atpsdk.CompletionRequest, fallbacks ...string) (*atpsdk.CompletionResponse, error) {
+	r.begin(Step{Call: CallComplete, Request: &request, Fallbacks: fallbacks})
+
+	var opts []atpsdk.RequestOption
+	if len(fallbacks) > 0 {
+		opts = append(opts, atpsdk.WithModelFallbacks(fallbacks...))
+	}
+	response, err := client.Complete(ctx, request, opts...)
+	r.end(response, err)
+	return response, err
+}
+
+// AdvertiseCapabilities records client.AdvertiseCapabilities
+func (r *Recorder) AdvertiseCapabilities(ctx context.Context, client *atpsdk.ATPClient, capability atpsdk.CapabilityAdvertisement) error {
+	r.begin(Step{Call: CallAdvertiseCapabilities, Capability: &capability})
+	err := client.AdvertiseCapabilities(ctx, capability)
+	r.end(nil, err)
+	return err
+}
+
+// ReportHealth records client.ReportHealth
+func (r *Recorder) ReportHealth(ctx context.Context, client *atpsdk.ATPClient, health atpsdk.HealthStatus) error {
+	r.begin(Step{Call: CallReportHealth, Health: &health})
+	err := client.ReportHealth(ctx, health)
+	r.end(nil, err)
+	return err
+}
+
+// Scenario returns the steps recorded so far
+func (r *Recorder) Scenario() Scenario {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scenario := r.scenario
+	scenario.Steps = append([]Step(nil), r.scenario.Steps...)
+	return scenario
+}
+
+func (r *Recorder) begin(step Step) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current = &step
+}
+
+func (r *Recorder) end(response *atpsdk.CompletionResponse, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.current.Result = response
+	if err != nil {
+		r.current.Error = errorText(err)
+	}
+	r.scenario.Steps = append(r.scenario.Steps, *r.current)
+	r.current = nil
+}
+
+func (r *Recorder) captureSent(frame *atpsdk.Frame) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current != nil && frame.Type != "heartbeat" {
+		r.current.Exchanges = append(r.current.Exchanges, Exchange{Sent: copyFrame(*frame)})
+	}
+	return nil
+}
+
+// captureReceived attaches a reply to the exchange it answers. Receive
+// interceptors run before the reply is dispatched, so the reply is always
+// captured before the call it completes returns.
+func (r *Recorder) captureReceived(frame *atpsdk.Frame) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.current == nil {
+		return nil
+	}
+	for i := range r.current.Exchanges {
+		sent := &r.current.Exchanges[i]
+		if sent.Sent.StreamID == frame.StreamID && sent.Sent.MsgSeq == frame.MsgSeq {
+			sent.Replies = append(sent.Replies, copyFrame(*frame))
+			break
+		}
+	}
+	return nil
+}
+
+// copyFrame deep-copies a frame through its JSON encoding, normalizing
+// payload values to the types they have after a round trip
+func copyFrame(frame atpsdk.Frame) atpsdk.Frame {
+	var out atpsdk.Frame
+	data, err := json.Marshal(frame)
+	if err == nil {
+		err = json.Unmarshal(data, &out)
+	}
+	if err != nil {
+		return frame
+	}
+	return out
+}
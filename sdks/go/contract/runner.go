@@ -0,0 +1,483 @@
+package contract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+)
+
+// Options configures Run
+type Options struct {
+	// Allowlist explains intentional divergences
+	Allowlist []AllowRule
+	// Configure adjusts the configuration of the client under test, e.g. to
+	// install the interceptors used in production
+	Configure func(*atpsdk.SDKConfig)
+	// StepTimeout bounds each replayed call (default: 5s)
+	StepTimeout time.Duration
+}
+
+// AllowRule marks divergences whose Key matches Pattern (path.Match
+// syntax, e.g. "*/*/sent_frame/payload.client_version") as intentional
+type AllowRule struct {
+	Pattern string `json:"pattern"`
+	Reason  string `json:"reason"`
+}
+
+// LoadAllowlist reads a JSON array of AllowRules
+func LoadAllowlist(file string) ([]AllowRule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var rules []AllowRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid allowlist %s: %w", file, err)
+	}
+	for _, rule := range rules {
+		if _, err := path.Match(rule.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid allowlist pattern %q: %w", rule.Pattern, err)
+		}
+	}
+	return rules, nil
+}
+
+// DivergenceKind classifies a Divergence
+type DivergenceKind string
+
+const (
+	// DivergenceSentFrame is a sent frame that differs from the recording
+	DivergenceSentFrame DivergenceKind = "sent_frame"
+	// DivergenceMissingFrame is a recorded frame the client no longer sends
+	DivergenceMissingFrame DivergenceKind = "missing_frame"
+	// DivergenceUnexpectedFrame is a frame sent beyond those recorded
+	DivergenceUnexpectedFrame DivergenceKind = "unexpected_frame"
+	// DivergenceResult is a parsed result that differs from the recording
+	DivergenceResult DivergenceKind = "result"
+	// DivergenceError is an error that differs from the recording,
+	// including a new error where the recorded call succeeded
+	DivergenceError DivergenceKind = "error"
+)
+
+// Divergence is a difference between the recorded and the replayed
+// behavior of one step
+type Divergence struct {
+	Scenario string
+	Step     int
+	Kind     DivergenceKind
+	// Path locates the differing field, e.g. "payload.model" for a frame or
+	// "text" for a result
+	Path     string
+	Expected string
+	Actual   string
+	// AllowedBy is the reason of the allowlist rule explaining the
+	// divergence, or empty if it is unexplained
+	AllowedBy string
+}
+
+// Key identifies the divergence for allowlist matching as
+// "<scenario>/<step>/<kind>/<path>"
+func (d Divergence) Key() string {
+	return fmt.Sprintf("%s/%d/%s/%s", d.Scenario, d.Step, d.Kind, d.Path)
+}
+
+func (d Divergence) String() string {
+	return fmt.Sprintf("%s: expected %s, got %s", d.Key(), d.Expected, d.Actual)
+}
+
+// Report lists the divergences found by Run
+type Report struct {
+	Scenarios   []string
+	Divergences []Divergence
+}
+
+// Unexplained returns the divergences not covered by the allowlist
+func (r *Report) Unexplained() []Divergence {
+	var out []Divergence
+	for _, d := range r.Divergences {
+		if d.AllowedBy == "" {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Err returns an error listing the unexplained divergences, or nil
+func (r *Report) Err() error {
+	unexplained := r.Unexplained()
+	if len(unexplained) == 0 {
+		return nil
+	}
+	lines := make([]string, len(unexplained))
+	for i, d := range unexplained {
+		lines[i] = d.String()
+	}
+	return fmt.Errorf("%d unexplained contract divergence(s):\n%s", len(unexplained), strings.Join(lines, "\n"))
+}
+
+// String summarizes the report
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d scenario(s), %d divergence(s), %d unexplained\n",
+		len(r.Scenarios), len(r.Divergences), len(r.Unexplained()))
+	for _, d := range r.Divergences {
+		if d.AllowedBy != "" {
+			fmt.Fprintf(&b, "  allowed  %s (%s)\n", d, d.AllowedBy)
+		} else {
+			fmt.Fprintf(&b, "  DIVERGED %s\n", d)
+		}
+	}
+	return b.String()
+}
+
+// Run replays every scenario in dir against the current client code
+func Run(ctx context.Context, dir string, opts Options) (*Report, error) {
+	scenarios, err := LoadScenarios(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(scenarios) == 0 {
+		return nil, fmt.Errorf("no scenarios found in %s", dir)
+	}
+	return RunScenarios(ctx, scenarios, opts), nil
+}
+
+// RunScenarios replays scenarios against the current client code
+func RunScenarios(ctx context.Context, scenarios []Scenario, opts Options) *Report {
+	if opts.StepTimeout <= 0 {
+		opts.StepTimeout = 5 * time.Second
+	}
+
+	report := &Report{}
+	for _, scenario := range scenarios {
+		report.Scenarios = append(report.Scenarios, scenario.Name)
+		for _, d := range replayScenario(ctx, scenario, opts) {
+			for _, rule := range opts.Allowlist {
+				if ok, _ := path.Match(rule.Pattern, d.Key()); ok {
+					d.AllowedBy = rule.Reason
+					break
+				}
+			}
+			report.Divergences = append(report.Divergences, d)
+		}
+	}
+	return report
+}
+
+// replayScenario runs one scenario against a router replaying its
+// recorded replies
+func replayScenario(ctx context.Context, scenario Scenario, opts Options) []Divergence {
+	router := newReplayRouter(scenario.Name)
+	defer router.Close()
+
+	config := atpsdk.SDKConfig{
+		WSURL:             router.URL(),
+		TenantID:          scenario.TenantID,
+		SessionID:         "contract-replay",
+		DefaultTimeout:    opts.StepTimeout,
+		HeartbeatInterval: time.Hour,
+		Logger:            discardLogger{},
+	}
+	if opts.Configure != nil {
+		opts.Configure(&config)
+	}
+	client := atpsdk.NewATPClient(config)
+	defer client.Close()
+
+	var divergences []Divergence
+	for i, step := range scenario.Steps {
+		router.begin(i, step.Exchanges)
+		result, err := runStep(ctx, client, step, opts.StepTimeout)
+		divergences = append(divergences, router.end()...)
+
+		diverged := func(kind DivergenceKind, path, expected, actual string) {
+			divergences = append(divergences, Divergence{
+				Scenario: scenario.Name, Step: i, Kind: kind, Path: path, Expected: expected, Actual: actual,
+			})
+		}
+		actualErr := ""
+		if err != nil {
+			actualErr = errorText(err)
+		}
+		if actualErr != step.Error {
+			diverged(DivergenceError, "error", describe(step.Error), describe(actualErr))
+		}
+		for _, d := range diffValues(step.Result, result) {
+			diverged(DivergenceResult, d.path, d.expected, d.actual)
+		}
+	}
+	return divergences
+}
+
+// runStep performs the step's call
+func runStep(ctx context.Context, client *atpsdk.ATPClient, step Step, timeout time.Duration) (*atpsdk.CompletionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch step.Call {
+	case CallComplete:
+		if step.Request == nil {
+			return nil, errors.New("complete step has no request")
+		}
+		var opts []atpsdk.RequestOption
+		if len(step.Fallbacks) > 0 {
+			opts = append(opts, atpsdk.WithModelFallbacks(step.Fallbacks...))
+		}
+		return client.Complete(ctx, *step.Request, opts...)
+	case CallAdvertiseCapabilities:
+		if step.Capability == nil {
+			return nil, errors.New("advertise_capabilities step has no capability")
+		}
+		return nil, client.AdvertiseCapabilities(ctx, *step.Capability)
+	case CallReportHealth:
+		if step.Health == nil {
+			return nil, errors.New("report_health step has no health status")
+		}
+		return nil, client.ReportHealth(ctx, *step.Health)
+	default:
+		return nil, fmt.Errorf("unknown call %q", step.Call)
+	}
+}
+
+// replayRouter is a WebSocket server that checks each frame the client
+// sends against the recording and answers with the recorded replies
+type replayRouter struct {
+	server   *httptest.Server
+	scenario string
+
+	mu          sync.Mutex
+	step        int
+	expected    []Exchange
+	next        int
+	divergences []Divergence
+}
+
+func newReplayRouter(scenario string) *replayRouter {
+	r := &replayRouter{scenario: scenario}
+	upgrader := websocket.Upgrader{}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			var frame atpsdk.Frame
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+			for _, reply := range r.handle(frame) {
+				if err := conn.WriteJSON(reply); err != nil {
+					return
+				}
+			}
+		}
+	}))
+	return r
+}
+
+func (r *replayRouter) URL() string {
+	return "ws" + strings.TrimPrefix(r.server.URL, "http")
+}
+
+func (r *replayRouter) Close() {
+	r.server.CloseClientConnections()
+	r.server.Close()
+}
+
+// begin starts expecting the exchanges of step
+func (r *replayRouter) begin(step int, exchanges []Exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.step, r.expected, r.next, r.divergences = step, exchanges, 0, nil
+}
+
+// end returns the divergences of the current step, including recorded
+// frames that were never sent
+func (r *replayRouter) end() []Divergence {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, missing := range r.expected[r.next:] {
+		r.diverged(DivergenceMissingFrame, missing.Sent.Type, describeFrame(missing.Sent), "<not sent>")
+	}
+	r.expected, r.next = nil, 0
+	return r.divergences
+}
+
+// handle compares a sent frame to the next recorded one and returns the
+// recorded replies, addressed to the live frame
+func (r *replayRouter) handle(frame atpsdk.Frame) []atpsdk.Frame {
+	if frame.Type == "heartbeat" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.next >= len(r.expected) {
+		r.diverged(DivergenceUnexpectedFrame, frame.Type, "<not sent>", describeFrame(frame))
+		return nil
+	}
+	exchange := r.expected[r.next]
+	r.next++
+
+	for _, d := range diffValues(comparableFrame(exchange.Sent), comparableFrame(frame)) {
+		r.diverged(DivergenceSentFrame, d.path, d.expected, d.actual)
+	}
+
+	replies := make([]atpsdk.Frame, len(exchange.Replies))
+	for i, reply := range exchange.Replies {
+		reply.StreamID = frame.StreamID
+		reply.MsgSeq = frame.MsgSeq
+		replies[i] = reply
+	}
+	return replies
+}
+
+func (r *replayRouter) diverged(kind DivergenceKind, path, expected, actual string) {
+	r.divergences = append(r.divergences, Divergence{
+		Scenario: r.scenario, Step: r.step, Kind: kind, Path: path, Expected: expected, Actual: actual,
+	})
+}
+
+// volatileFramePaths are frame fields expected to differ between runs
+var volatileFramePaths = []string{"ts", "stream_id", "msg_seq", "meta.idempotency_key", "meta.trace", "meta.correlation_id", "payload.idempotency_key", "payload.correlation_id", "payload.last_health_check"}
+
+// comparableFrame returns frame as generic JSON without volatile fields
+func comparableFrame(frame atpsdk.Frame) interface{} {
+	var doc map[string]interface{}
+	data, _ := json.Marshal(frame)
+	_ = json.Unmarshal(data, &doc)
+
+	for _, volatile := range volatileFramePaths {
+		parent, key := doc, volatile
+		if i := strings.LastIndex(volatile, "."); i >= 0 {
+			parent, _ = doc[volatile[:i]].(map[string]interface{})
+			key = volatile[i+1:]
+		}
+		delete(parent, key)
+	}
+	// A Meta of volatile fields only is omitted from recorded frames
+	if meta, ok := doc["meta"].(map[string]interface{}); ok && len(meta) == 0 {
+		delete(doc, "meta")
+	}
+	return doc
+}
+
+type valueDiff struct {
+	path, expected, actual string
+}
+
+// diffValues compares the JSON encodings of expected and actual leaf by
+// leaf
+func diffValues(expected, actual interface{}) []valueDiff {
+	want, got := flatten(expected), flatten(actual)
+
+	paths := make([]string, 0, len(want)+len(got))
+	for p := range want {
+		paths = append(paths, p)
+	}
+	for p := range got {
+		if _, ok := want[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var diffs []valueDiff
+	for _, p := range paths {
+		w, inWant := want[p]
+		g, inGot := got[p]
+		if inWant && inGot && w == g {
+			continue
+		}
+		if !inWant {
+			w = "<absent>"
+		}
+		if !inGot {
+			g = "<absent>"
+		}
+		diffs = append(diffs, valueDiff{path: p, expected: w, actual: g})
+	}
+	return diffs
+}
+
+// flatten maps every leaf of v's JSON encoding to its path, e.g.
+// "payload.stop[0]". Empty objects and arrays are leaves.
+func flatten(v interface{}) map[string]string {
+	var doc interface{}
+	data, _ := json.Marshal(v)
+	_ = json.Unmarshal(data, &doc)
+
+	out := make(map[string]string)
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if len(v) > 0 {
+				for key, child := range v {
+					if prefix != "" {
+						key = prefix + "." + key
+					}
+					walk(key, child)
+				}
+				return
+			}
+		case []interface{}:
+			if len(v) > 0 {
+				for i, child := range v {
+					walk(fmt.Sprintf("%s[%d]", prefix, i), child)
+				}
+				return
+			}
+		}
+		leaf, _ := json.Marshal(v)
+		out[prefix] = string(leaf)
+	}
+	if doc != nil {
+		walk("", doc)
+	}
+	return out
+}
+
+// errorText returns the message of err without the request's correlation
+// ID, which differs between runs
+func errorText(err error) string {
+	var requestErr *atpsdk.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.Err.Error()
+	}
+	return err.Error()
+}
+
+func describe(err string) string {
+	if err == "" {
+		return "<no error>"
+	}
+	return err
+}
+
+func describeFrame(frame atpsdk.Frame) string {
+	data, _ := json.Marshal(comparableFrame(frame))
+	return string(data)
+}
+
+// discardLogger drops client warnings during replay
+type discardLogger struct{}
+
+func (discardLogger) Printf(string, ...interface{}) {}
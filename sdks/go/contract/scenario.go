@@ -0,0 +1,94 @@
+// Package contract verifies that the SDK still behaves as it did against
+// recorded router traffic. A Recorder captures the frames exchanged for
+// each client call into a Scenario; Run replays a directory of scenarios
+// against the current client code and reports every divergence.
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+)
+
+// Calls supported in a Step
+const (
+	CallComplete              = "complete"
+	CallAdvertiseCapabilities = "advertise_capabilities"
+	CallReportHealth          = "report_health"
+)
+
+// Scenario is a recorded sequence of client calls
+type Scenario struct {
+	Name     string `json:"name"`
+	TenantID string `json:"tenant_id,omitempty"`
+	Steps    []Step `json:"steps"`
+}
+
+// Step is one client call, the frames it exchanged with the router and its
+// parsed outcome
+type Step struct {
+	Call       string                          `json:"call"`
+	Request    *atpsdk.CompletionRequest       `json:"request,omitempty"`
+	Fallbacks  []string                        `json:"fallbacks,omitempty"`
+	Capability *atpsdk.CapabilityAdvertisement `json:"capability,omitempty"`
+	Health     *atpsdk.HealthStatus            `json:"health,omitempty"`
+
+	Exchanges []Exchange                 `json:"exchanges"`
+	Result    *atpsdk.CompletionResponse `json:"result,omitempty"`
+	Error     string                     `json:"error,omitempty"`
+}
+
+// Exchange is a frame sent by the client and the router's replies to it
+type Exchange struct {
+	Sent    atpsdk.Frame   `json:"sent"`
+	Replies []atpsdk.Frame `json:"replies,omitempty"`
+}
+
+// LoadScenario reads a scenario file
+func LoadScenario(path string) (Scenario, error) {
+	var scenario Scenario
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return scenario, err
+	}
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return scenario, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	if scenario.Name == "" {
+		scenario.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return scenario, nil
+}
+
+// LoadScenarios reads every *.json scenario in dir, ordered by file name
+func LoadScenarios(dir string) ([]Scenario, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	scenarios := make([]Scenario, 0, len(paths))
+	for _, path := range paths {
+		scenario, err := LoadScenario(path)
+		if err != nil {
+			return nil, err
+		}
+		scenarios = append(scenarios, scenario)
+	}
+	return scenarios, nil
+}
+
+// SaveScenario writes scenario to dir as <name>.json
+func SaveScenario(dir string, scenario Scenario) error {
+	data, err := json.MarshalIndent(scenario, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, scenario.Name+".json"), append(data, '\n'), 0o644)
+}
@@ -0,0 +1,93 @@
+package atpsdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// controlFrameTemplate is a preserialized control frame. Only the
+// timestamp and sequence number change between sends, so they are patched
+// into the bytes instead of building and marshaling a Frame every time.
+type controlFrameTemplate struct {
+	prefix []byte // everything up to and including `"ts":`
+	middle []byte // everything between the timestamp and the sequence number
+	suffix []byte // everything after the sequence number
+}
+
+// newControlFrameTemplate serializes frame with placeholder timestamp and
+// sequence number and splits it around them
+func newControlFrameTemplate(frame Frame) controlFrameTemplate {
+	frame.Timestamp = 0
+	frame.MsgSeq = 1
+	data, err := json.Marshal(frame)
+	if err != nil {
+		panic(fmt.Sprintf("atpsdk: cannot serialize %s control frame: %v", frame.Type, err))
+	}
+	ts := bytes.Index(data, []byte(`"ts":0`))
+	seq := bytes.Index(data, []byte(`"msg_seq":1`))
+	if ts < 0 || seq < ts {
+		panic(fmt.Sprintf("atpsdk: %s control frame has no timestamp or sequence number", frame.Type))
+	}
+	ts += len(`"ts":`)
+	seq += len(`"msg_seq":`)
+	return controlFrameTemplate{prefix: data[:ts], middle: data[ts+1 : seq], suffix: data[seq+1:]}
+}
+
+// appendTo appends the frame with timestamp ts (unix milliseconds) and
+// sequence number seq, which must be positive, to buf
+func (t *controlFrameTemplate) appendTo(buf []byte, ts, seq int64) []byte {
+	buf = append(buf, t.prefix...)
+	buf = strconv.AppendInt(buf, ts, 10)
+	buf = append(buf, t.middle...)
+	buf = strconv.AppendInt(buf, seq, 10)
+	return append(buf, t.suffix...)
+}
+
+// sendHeartbeat sends the next heartbeat frame of the primary connection,
+// from the preserialized template when heartbeatTemplateUsable allows it and
+// no stats are reported. It fails with ErrHeartbeatTimeout once
+// HeartbeatMissThreshold heartbeats in a row went unacknowledged.
+func (c *ATPClient) sendHeartbeat() error {
+	seq, missed := c.heartbeat.next()
+	if err := c.heartbeatsFailed(missed); err != nil {
+		return err
+	}
+	if c.config.HeartbeatStats {
+		frame := c.builder.BuildHeartbeatFrameWithStats(c.heartbeatStats())
+		return c.sendPrimaryFrame(c.heartbeat.stamp(frame, seq))
+	}
+	if !c.heartbeatTemplateUsable() {
+		return c.sendPrimaryFrame(c.heartbeat.stamp(c.builder.BuildHeartbeatFrame(), seq))
+	}
+	return c.sendControlFrame(&c.heartbeat.template, seq)
+}
+
+// heartbeatTemplateUsable reports whether heartbeats may skip the Frame
+// based send path. Interceptors, audit sinks, payload schemas, encryption,
+// signing and codecs operate on Frame values, so the template is only used
+// when none applies.
+func (c *ATPClient) heartbeatTemplateUsable() bool {
+	return len(c.config.SendInterceptors) == 0 && c.config.AuditSink == nil &&
+		!c.hasPayloadSchema(FrameTypeHeartbeat) && !c.encrypts(FrameTypeHeartbeat) && !c.signingEnabled() && !c.usesCodec()
+}
+
+// sendControlFrame queues a heartbeat control frame template for the
+// primary connection. The writer stamps it with the time it is written.
+func (c *ATPClient) sendControlFrame(template *controlFrameTemplate, seq int64) error {
+	c.connMutex.RLock()
+	if c.closed() {
+		c.connMutex.RUnlock()
+		return ErrClientClosed
+	}
+	if !c.connected || c.conn == nil {
+		c.connMutex.RUnlock()
+		return ErrNotConnected
+	}
+	queue := c.out
+	c.connMutex.RUnlock()
+
+	return queue.push(context.Background(), outboundFrame{template: template, seq: seq, heartbeat: true})
+}
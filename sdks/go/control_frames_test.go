@@ -0,0 +1,100 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestControlFrameTemplateMatchesMarshal(t *testing.T) {
+	builder := NewFrameBuilder("session", "tenant")
+	frame := builder.BuildHeartbeatFrame()
+	frame.StreamID = "heartbeat_session"
+	template := newControlFrameTemplate(frame)
+
+	for i, ts := range []int64{0, 7, 1700000000123, -1} {
+		seq := int64(i*1000 + 1)
+		frame.Timestamp = FrameTime(ts)
+		frame.MsgSeq = int(seq)
+		want, err := json.Marshal(frame)
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		got := template.appendTo(nil, ts, seq)
+		if !bytes.Equal(got, want) {
+			t.Errorf("Template output differs for ts=%d:\n got %s\nwant %s", ts, got, want)
+		}
+		var decoded Frame
+		if err := json.Unmarshal(got, &decoded); err != nil || decoded.Timestamp != FrameTime(ts) || int64(decoded.MsgSeq) != seq || decoded.Type != "heartbeat" {
+			t.Errorf("Template output is not a valid heartbeat: %s (%v)", got, err)
+		}
+	}
+}
+
+func TestHeartbeatAllocations(t *testing.T) {
+	var heartbeats atomic.Int32
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type == "heartbeat" && f.Timestamp > 0 {
+			heartbeats.Add(1)
+		}
+		return nil
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), HeartbeatInterval: time.Hour})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	const runs = 200
+	allocs := testing.AllocsPerRun(runs, func() {
+		if err := client.sendHeartbeat(); err != nil {
+			t.Fatalf("sendHeartbeat failed: %v", err)
+		}
+	})
+	// The websocket library allocates one message writer per client write
+	if allocs > 1 {
+		t.Errorf("Expected at most 1 allocation per heartbeat, got %v", allocs)
+	}
+
+	// AllocsPerRun performs one extra warm-up run
+	deadline := time.Now().Add(2 * time.Second)
+	for heartbeats.Load() < runs+1 && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+	if got := heartbeats.Load(); got != runs+1 {
+		t.Errorf("Expected the router to decode %d heartbeats, got %d", runs+1, got)
+	}
+}
+
+func TestHeartbeatRunsInterceptors(t *testing.T) {
+	received := make(chan Frame, 1)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type == "heartbeat" {
+			received <- f
+		}
+		return nil
+	})
+	client := NewATPClient(SDKConfig{
+		WSURL:             router.URL(),
+		HeartbeatInterval: time.Hour,
+		SendInterceptors:  []func(*Frame) error{ClientVersionInterceptor("1.2.3")},
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	if err := client.sendHeartbeat(); err != nil {
+		t.Fatalf("sendHeartbeat failed: %v", err)
+	}
+	select {
+	case f := <-received:
+		if f.Payload["client_version"] != "1.2.3" {
+			t.Errorf("Expected interceptors to run on heartbeats, got %v", f.Payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Heartbeat not received")
+	}
+}
@@ -0,0 +1,77 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RequestError wraps the error of a request with the request's correlation
+// ID. It matches whatever the wrapped error matches.
+type RequestError struct {
+	CorrelationID string
+	Err           error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%v (correlation_id %s)", e.Err, e.CorrelationID)
+}
+
+// Unwrap returns the request's error
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// CorrelationIDOf returns the correlation ID of the request err was
+// returned for, or "" when err does not come from a request
+func CorrelationIDOf(err error) string {
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.CorrelationID
+	}
+	return ""
+}
+
+// correlate returns the correlation ID of a request, the one set with
+// WithCorrelationID or a new one, and adds it to the Meta of its frames
+func (c *ATPClient) correlate(options *requestOptions) string {
+	id := options.correlationID
+	if id == "" {
+		id = c.config.IDGenerator.NewID()
+	}
+	options.meta = append(options.meta, Meta{CorrelationID: id})
+	return id
+}
+
+// correlateError wraps the error of the request with correlation ID id in
+// a *RequestError, unless it is wrapped already
+func correlateError(err error, id string) error {
+	if err == nil || CorrelationIDOf(err) == id {
+		return err
+	}
+	return &RequestError{CorrelationID: id, Err: err}
+}
+
+// logCorrelation formats correlation ID id for a log line, or returns ""
+// when there is none
+func logCorrelation(id string) string {
+	if id == "" {
+		return ""
+	}
+	return " (correlation_id " + id + ")"
+}
+
+// metaCorrelationID returns the correlation ID in the Meta of a completion
+// request's context
+func metaCorrelationID(ctx context.Context) string {
+	return withMeta(Meta{}, metaFromContext(ctx)).CorrelationID
+}
+
+// correlated returns the request frame with its Meta.CorrelationID, if
+// any, copied into its payload
+func correlated(frame Frame) Frame {
+	if frame.Meta.CorrelationID != "" {
+		frame.Payload["correlation_id"] = frame.Meta.CorrelationID
+	}
+	return frame
+}
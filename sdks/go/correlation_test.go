@@ -0,0 +1,55 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithCorrelationID(t *testing.T) {
+	requests := make(chan Frame, 1)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		requests <- f
+		return []Frame{{Type: "error", StreamID: f.StreamID, MsgSeq: f.MsgSeq,
+			Payload: map[string]interface{}{"error": map[string]interface{}{"code": ErrorCodeInvalidRequest, "message": "bad prompt"}}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithCorrelationID("req-42"))
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeInvalidRequest {
+		t.Fatalf("Expected the router's error, got %v", err)
+	}
+	var requestErr *RequestError
+	if !errors.As(err, &requestErr) || requestErr.CorrelationID != "req-42" || CorrelationIDOf(err) != "req-42" {
+		t.Errorf("Expected the error to carry the correlation ID, got %v", err)
+	}
+
+	request := <-requests
+	if request.Meta.CorrelationID != "req-42" || request.Payload["correlation_id"] != "req-42" {
+		t.Errorf("Expected the correlation ID in the meta and payload, got %+v and %v", request.Meta, request.Payload)
+	}
+}
+
+func TestCorrelationIDGenerated(t *testing.T) {
+	router := nackRouter(t, "adapter.health")
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), IDGenerator: sequentialIDs()})
+	defer client.Close()
+
+	err := client.ReportHealth(context.Background(), HealthStatus{AdapterID: "a", Status: "healthy"})
+	if !errors.Is(err, ErrNacked) {
+		t.Fatalf("Expected a nack, got %v", err)
+	}
+	if id := CorrelationIDOf(err); id != "id-2" {
+		t.Errorf("Expected the generated correlation ID, got %q", id)
+	}
+
+	frame := NewFrameBuilder("s1", "acme").BuildHealthFrame("stream-1", HealthStatus{})
+	if _, ok := frame.Payload["correlation_id"]; ok || frame.Meta.CorrelationID != "" {
+		t.Errorf("Expected no correlation ID without one in the meta, got %+v", frame)
+	}
+}
@@ -0,0 +1,64 @@
+package atpsdk
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// DebugChecksEnv enables debug-mode misuse checks when set to a true value
+// (e.g. "1" or "true"), in addition to SDKConfig.DebugChecks.
+const DebugChecksEnv = "ATP_SDK_DEBUG_CHECKS"
+
+// debugChecksFromEnv reports whether DebugChecksEnv enables debug checks
+func debugChecksFromEnv() bool {
+	enabled, err := strconv.ParseBool(os.Getenv(DebugChecksEnv))
+	return err == nil && enabled
+}
+
+// ownerGuard detects use of a single-goroutine type from more than one
+// goroutine. The first checked call claims ownership; calls from any other
+// goroutine panic with a message naming the type and method. When disabled
+// a check is a single branch.
+type ownerGuard struct {
+	enabled  bool
+	typeName string
+	owner    atomic.Uint64
+}
+
+// newOwnerGuard creates a guard for a value of the named type
+func newOwnerGuard(enabled bool, typeName string) ownerGuard {
+	return ownerGuard{enabled: enabled, typeName: typeName}
+}
+
+// check panics if method is called from a goroutine other than the owner
+func (g *ownerGuard) check(method string) {
+	if !g.enabled {
+		return
+	}
+	id := goroutineID()
+	if g.owner.CompareAndSwap(0, id) {
+		return
+	}
+	if owner := g.owner.Load(); owner != id {
+		panic(fmt.Sprintf(
+			"atpsdk: %s.%s called from goroutine %d, but this %s is owned by goroutine %d; %s is not safe for concurrent use",
+			g.typeName, method, id, g.typeName, owner, g.typeName))
+	}
+}
+
+// goroutineID parses the current goroutine's ID from its stack header. It is
+// only used in debug mode.
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	field := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(field, ' '); i >= 0 {
+		field = field[:i]
+	}
+	id, _ := strconv.ParseUint(string(field), 10, 64)
+	return id
+}
@@ -0,0 +1,94 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOwnerGuardDisabled(t *testing.T) {
+	guard := newOwnerGuard(false, "Stream")
+	guard.check("Send")
+
+	done := make(chan interface{})
+	go func() {
+		defer func() { done <- recover() }()
+		guard.check("Recv")
+	}()
+	if r := <-done; r != nil {
+		t.Errorf("Disabled guard should never panic, got %v", r)
+	}
+}
+
+func TestOwnerGuardDetectsSharing(t *testing.T) {
+	guard := newOwnerGuard(true, "Stream")
+	guard.check("Send")
+	guard.check("Send") // same goroutine is fine
+
+	done := make(chan interface{})
+	go func() {
+		defer func() { done <- recover() }()
+		guard.check("Recv")
+	}()
+
+	r := <-done
+	msg, ok := r.(string)
+	if !ok {
+		t.Fatalf("Expected a panic with a message, got %v", r)
+	}
+	for _, want := range []string{"Stream.Recv", "owned by goroutine", "not safe for concurrent use"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("Panic message %q does not mention %q", msg, want)
+		}
+	}
+}
+
+func TestDebugChecksFromEnv(t *testing.T) {
+	t.Setenv(DebugChecksEnv, "true")
+	client := NewATPClient(SDKConfig{})
+	if !client.config.DebugChecks {
+		t.Error("Expected DebugChecks to be enabled from the environment")
+	}
+
+	t.Setenv(DebugChecksEnv, "")
+	client = NewATPClient(SDKConfig{})
+	if client.config.DebugChecks {
+		t.Error("Expected DebugChecks to be disabled by default")
+	}
+}
+
+func TestClosedClientReturnsSentinel(t *testing.T) {
+	router := newTestRouter(t, nil)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Complete(ctx, CompletionRequest{Prompt: "hi"}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Complete: expected ErrClientClosed, got %v", err)
+	}
+	if err := client.AdvertiseCapabilities(ctx, CapabilityAdvertisement{AdapterID: "a"}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("AdvertiseCapabilities: expected ErrClientClosed, got %v", err)
+	}
+	if err := client.ReportHealth(ctx, HealthStatus{AdapterID: "a"}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("ReportHealth: expected ErrClientClosed, got %v", err)
+	}
+	if err := client.Connect(); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Connect: expected ErrClientClosed, got %v", err)
+	}
+	if err := client.sendFrame(Frame{Type: "heartbeat"}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("sendFrame: expected ErrClientClosed, got %v", err)
+	}
+}
+
+func BenchmarkOwnerGuardDisabled(b *testing.B) {
+	guard := newOwnerGuard(false, "Stream")
+	for i := 0; i < b.N; i++ {
+		guard.check("Send")
+	}
+}
@@ -0,0 +1,69 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Frame types of the adapter discovery exchange
+const (
+	FrameTypeDiscoveryRequest  = "discovery.request"
+	FrameTypeDiscoveryResponse = "discovery.response"
+)
+
+// DiscoveryFilter selects the adapters returned by DiscoverAdapters. Empty
+// fields match every adapter.
+type DiscoveryFilter struct {
+	// Capability is a capability the adapter must advertise
+	Capability string
+	// ModelPrefix is a prefix of one of the adapter's models
+	ModelPrefix string
+	// Language is a language the adapter must support
+	Language string
+}
+
+// discoveryPage is the payload of a discovery.response frame
+type discoveryPage struct {
+	Adapters []CapabilityAdvertisement `json:"adapters"`
+}
+
+// DiscoverAdapters asks the router for the adapters currently registered
+// that match filter. The router may answer with several discovery.response
+// frames on the request's stream: pages flagged FRAG, the last one also
+// LAST. No match gives an empty, non-nil slice. When the answer is not
+// complete before ctx's deadline, or DefaultTimeout when ctx has none, the
+// call fails with an error matching ErrDiscoveryTimeout and
+// context.DeadlineExceeded. Discovery needs a WebSocket connection, so it
+// fails with ErrNotSupportedByTransport over the HTTP transport.
+func (c *ATPClient) DiscoverAdapters(ctx context.Context, filter DiscoveryFilter) ([]CapabilityAdvertisement, error) {
+	payload := map[string]interface{}{}
+	if filter.Capability != "" {
+		payload["capability"] = filter.Capability
+	}
+	if filter.ModelPrefix != "" {
+		payload["model_prefix"] = filter.ModelPrefix
+	}
+	if filter.Language != "" {
+		payload["language"] = filter.Language
+	}
+
+	adapters := []CapabilityAdvertisement{}
+	pages := 0
+	err := c.queryPages(ctx, FrameTypeDiscoveryRequest, payload, FrameTypeDiscoveryResponse, func(frame *Frame) error {
+		page, err := DecodePayload[discoveryPage](*frame)
+		if err != nil {
+			return err
+		}
+		adapters = append(adapters, page.Adapters...)
+		pages++
+		return nil
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w after %d page(s): %w", ErrDiscoveryTimeout, pages, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return adapters, nil
+}
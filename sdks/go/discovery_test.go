@@ -0,0 +1,79 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// discoveryRouter answers discovery requests for the "chat" capability with
+// two pages of adapters, requests for "none" with no adapters, and ignores
+// the others
+func discoveryRouter(t *testing.T) *testRouter {
+	page := func(f Frame, fragSeq int, flags []string, ids ...string) Frame {
+		var adapters []interface{}
+		for _, id := range ids {
+			adapters = append(adapters, map[string]interface{}{"adapter_id": id, "models": []string{id + "-model"}})
+		}
+		return Frame{Type: FrameTypeDiscoveryResponse, StreamID: f.StreamID, MsgSeq: f.MsgSeq, FragSeq: fragSeq, Flags: flags,
+			Payload: map[string]interface{}{"adapters": adapters}}
+	}
+	return newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeDiscoveryRequest {
+			return nil
+		}
+		switch f.Payload["capability"] {
+		case "chat":
+			if f.Payload["model_prefix"] != "llama" {
+				return []Frame{NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeInvalidRequest, "missing model prefix")}
+			}
+			return []Frame{
+				page(f, 0, []string{FlagFragment}, "a1", "a2"),
+				page(f, 1, []string{FlagFragment, FlagLast}, "a3"),
+			}
+		case "none":
+			return []Frame{page(f, 0, nil)}
+		}
+		return nil
+	})
+}
+
+func TestDiscoverAdapters(t *testing.T) {
+	router := discoveryRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	ctx := context.Background()
+
+	adapters, err := client.DiscoverAdapters(ctx, DiscoveryFilter{Capability: "chat", ModelPrefix: "llama"})
+	if err != nil {
+		t.Fatalf("DiscoverAdapters failed: %v", err)
+	}
+	var got []string
+	for _, adapter := range adapters {
+		got = append(got, adapter.AdapterID+":"+fmt.Sprint(adapter.Models))
+	}
+	if fmt.Sprint(got) != "[a1:[a1-model] a2:[a2-model] a3:[a3-model]]" {
+		t.Errorf("Expected the adapters of both pages, got %v", got)
+	}
+
+	// No match is an empty result, not an error
+	adapters, err = client.DiscoverAdapters(ctx, DiscoveryFilter{Capability: "none"})
+	if err != nil || adapters == nil || len(adapters) != 0 {
+		t.Errorf("Expected an empty result, got %v, %v", adapters, err)
+	}
+
+	var atpErr *ATPError
+	if _, err := client.DiscoverAdapters(ctx, DiscoveryFilter{Capability: "chat"}); !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeInvalidRequest {
+		t.Errorf("Expected the router's error, got %v", err)
+	}
+
+	// An unanswered request times out
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = client.DiscoverAdapters(timeoutCtx, DiscoveryFilter{Capability: "unanswered"})
+	if !errors.Is(err, ErrDiscoveryTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected ErrDiscoveryTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package atpsdk
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// AlgorithmAES256GCM identifies payloads encrypted by NewAESGCMCipher
+const AlgorithmAES256GCM = "aes256-gcm"
+
+// FlagEncrypted marks a frame whose payload is an encryption envelope
+const FlagEncrypted = "enc"
+
+// defaultEncryptionExclusions are the control frames sent in the clear when
+// SDKConfig.EncryptionExclude is nil
+var defaultEncryptionExclusions = []string{FrameTypeHeartbeat, FrameTypeHealth}
+
+// EncryptedPayload is a serialized payload encrypted by a PayloadCipher
+type EncryptedPayload struct {
+	Algorithm  string
+	Ciphertext []byte
+	Nonce      []byte
+}
+
+// PayloadCipher encrypts and decrypts serialized frame payloads. It must be
+// safe for concurrent use.
+type PayloadCipher interface {
+	// Encrypt encrypts the JSON-encoded payload
+	Encrypt(plaintext []byte) (EncryptedPayload, error)
+	// Decrypt returns the JSON-encoded payload of an envelope produced by a
+	// peer using the same algorithm and key
+	Decrypt(payload EncryptedPayload) ([]byte, error)
+}
+
+// DecryptionError is reported for an incoming encrypted frame that could not
+// be decrypted. It matches ErrDecryptionFailed with errors.Is.
+type DecryptionError struct {
+	FrameType string
+	StreamID  string
+	Err       error
+}
+
+func (e *DecryptionError) Error() string {
+	return fmt.Sprintf("%v: %s frame on stream %q: %v", ErrDecryptionFailed, e.FrameType, e.StreamID, e.Err)
+}
+
+// Is reports whether target is ErrDecryptionFailed
+func (e *DecryptionError) Is(target error) bool {
+	return target == ErrDecryptionFailed
+}
+
+func (e *DecryptionError) Unwrap() error {
+	return e.Err
+}
+
+// aesGCMCipher is the AES-256-GCM PayloadCipher
+type aesGCMCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMCipher returns a PayloadCipher using AES-256-GCM with a random
+// nonce per payload. key must be 32 bytes long.
+func NewAESGCMCipher(key []byte) (PayloadCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("AES-256-GCM needs a 32-byte key, got %d bytes", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGCMCipher{aead: aead}, nil
+}
+
+func (c *aesGCMCipher) Encrypt(plaintext []byte) (EncryptedPayload, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return EncryptedPayload{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return EncryptedPayload{
+		Algorithm:  AlgorithmAES256GCM,
+		Ciphertext: c.aead.Seal(nil, nonce, plaintext, nil),
+		Nonce:      nonce,
+	}, nil
+}
+
+func (c *aesGCMCipher) Decrypt(payload EncryptedPayload) ([]byte, error) {
+	if payload.Algorithm != AlgorithmAES256GCM {
+		return nil, fmt.Errorf("unsupported algorithm %q", payload.Algorithm)
+	}
+	if len(payload.Nonce) != c.aead.NonceSize() {
+		return nil, fmt.Errorf("nonce must be %d bytes, got %d", c.aead.NonceSize(), len(payload.Nonce))
+	}
+	return c.aead.Open(nil, payload.Nonce, payload.Ciphertext, nil)
+}
+
+// setupEncryption builds the payload cipher from SDKConfig.EncryptionKey
+// unless one was configured. An unusable key is logged and makes every
+// frame that should be encrypted fail to send, so payloads never go out in
+// the clear by mistake.
+func (c *ATPClient) setupEncryption() {
+	if c.config.EncryptionExclude == nil {
+		c.config.EncryptionExclude = defaultEncryptionExclusions
+	}
+	if c.config.PayloadCipher != nil || len(c.config.EncryptionKey) == 0 {
+		return
+	}
+	payloadCipher, err := NewAESGCMCipher(c.config.EncryptionKey)
+	if err != nil {
+		c.encryptionErr = fmt.Errorf("invalid encryption key: %w", err)
+		c.config.Logger.Printf("Warning: %v", c.encryptionErr)
+		return
+	}
+	c.config.PayloadCipher = payloadCipher
+}
+
+// encrypts reports whether outgoing frames of frameType are encrypted
+func (c *ATPClient) encrypts(frameType string) bool {
+	if c.config.PayloadCipher == nil && c.encryptionErr == nil {
+		return false
+	}
+	return !slices.Contains(c.config.EncryptionExclude, frameType)
+}
+
+// encryptOutgoing replaces the payload of frame with an encryption
+// envelope and flags the frame as encrypted
+func (c *ATPClient) encryptOutgoing(frame *Frame) error {
+	if !c.encrypts(frame.Type) {
+		return nil
+	}
+	if c.encryptionErr != nil {
+		return c.encryptionErr
+	}
+
+	plaintext, err := json.Marshal(frame.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	encrypted, err := c.config.PayloadCipher.Encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s payload: %w", frame.Type, err)
+	}
+	frame.Payload = map[string]interface{}{
+		"enc":   base64.StdEncoding.EncodeToString(encrypted.Ciphertext),
+		"alg":   encrypted.Algorithm,
+		"nonce": base64.StdEncoding.EncodeToString(encrypted.Nonce),
+	}
+	frame.Flags = append(slices.Clip(frame.Flags), FlagEncrypted)
+	return nil
+}
+
+// decryptIncoming restores the payload of a frame flagged as encrypted.
+// Frames without the flag are left alone.
+func (c *ATPClient) decryptIncoming(frame *Frame) error {
+	i := slices.Index(frame.Flags, FlagEncrypted)
+	if i < 0 {
+		return nil
+	}
+	fail := func(err error) error {
+		return &DecryptionError{FrameType: frame.Type, StreamID: frame.StreamID, Err: err}
+	}
+	if c.config.PayloadCipher == nil {
+		return fail(fmt.Errorf("no payload cipher configured"))
+	}
+
+	algorithm, _ := frame.Payload["alg"].(string)
+	encoded, _ := frame.Payload["enc"].(string)
+	encodedNonce, _ := frame.Payload["nonce"].(string)
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || encoded == "" {
+		return fail(fmt.Errorf("malformed ciphertext"))
+	}
+	nonce, err := base64.StdEncoding.DecodeString(encodedNonce)
+	if err != nil {
+		return fail(fmt.Errorf("malformed nonce"))
+	}
+
+	plaintext, err := c.config.PayloadCipher.Decrypt(EncryptedPayload{Algorithm: algorithm, Ciphertext: ciphertext, Nonce: nonce})
+	if err != nil {
+		return fail(err)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return fail(fmt.Errorf("decrypted payload is not a JSON object: %w", err))
+	}
+	frame.Payload = payload
+	frame.Flags = slices.Delete(slices.Clone(frame.Flags), i, i+1)
+	return nil
+}
@@ -0,0 +1,206 @@
+package atpsdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	testEncryptionKey  = bytes.Repeat([]byte{7}, 32)
+	otherEncryptionKey = bytes.Repeat([]byte{9}, 32)
+)
+
+// encryptingRouter records the frames it receives and answers completion
+// requests with reply. When reply is nil it decrypts the request as a router
+// sharing key would and echoes the prompt encrypted. Health reports are
+// acknowledged in the clear.
+func encryptingRouter(t *testing.T, key []byte, reply func(request Frame) Frame) (*testRouter, func() []Frame) {
+	peer := NewATPClient(SDKConfig{EncryptionKey: key})
+	var mu sync.Mutex
+	var received []Frame
+
+	router := newTestRouter(t, func(f Frame) []Frame {
+		mu.Lock()
+		received = append(received, *cloneFrame(&f))
+		mu.Unlock()
+		if f.Type == "adapter.health" {
+			return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"status": "ack"}}}
+		}
+		if f.Type != "completion_request" {
+			return nil
+		}
+		if reply != nil {
+			return []Frame{reply(f)}
+		}
+
+		if err := peer.decryptIncoming(&f); err != nil {
+			t.Errorf("Router cannot decrypt the request: %v", err)
+			return nil
+		}
+		response := Frame{
+			Type:     "completion_response",
+			StreamID: f.StreamID,
+			MsgSeq:   f.MsgSeq,
+			Payload:  map[string]interface{}{"text": "echo: " + f.Payload["prompt"].(string), "tokens_in": 4},
+		}
+		if err := peer.encryptOutgoing(&response); err != nil {
+			t.Errorf("Router cannot encrypt the response: %v", err)
+		}
+		return []Frame{response}
+	})
+	return router, func() []Frame {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Frame(nil), received...)
+	}
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	router, received := encryptingRouter(t, testEncryptionKey, nil)
+	responses := make(chan *Frame, 1)
+	client := NewATPClient(SDKConfig{
+		WSURL:         router.URL(),
+		EncryptionKey: testEncryptionKey,
+		ReceiveInterceptors: []func(*Frame) error{func(f *Frame) error {
+			if f.Type == "completion_response" {
+				responses <- f
+			}
+			return nil
+		}},
+	})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "patient notes"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.Text != "echo: patient notes" || response.TokensIn != 4 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if f := <-responses; slices.Contains(f.Flags, FlagEncrypted) || f.Payload["text"] != "echo: patient notes" {
+		t.Errorf("Receive interceptors must see the decrypted frame, got %+v", f)
+	}
+
+	// The plaintext acknowledgment is accepted alongside encrypted frames
+	if err := client.ReportHealth(context.Background(), HealthStatus{AdapterID: "a", Status: "healthy"}); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+
+	frames := received()
+	if len(frames) < 2 {
+		t.Fatalf("Expected the request and health frames, got %+v", frames)
+	}
+	request, health := frames[0], frames[1]
+	if !slices.Contains(request.Flags, FlagEncrypted) || request.Payload["alg"] != AlgorithmAES256GCM || request.Payload["prompt"] != nil {
+		t.Errorf("Request was not encrypted: %+v", request)
+	}
+	if slices.Contains(health.Flags, FlagEncrypted) || health.Payload["status"] != "healthy" {
+		t.Errorf("Health frames are excluded from encryption by default, got %+v", health)
+	}
+	if client.Introspect().Features["encryption"] != true {
+		t.Error("Introspection does not report encryption")
+	}
+}
+
+func TestEncryptionDecryptionFailures(t *testing.T) {
+	encryptedWith := func(key []byte, plaintext string) func(Frame) Frame {
+		payloadCipher, _ := NewAESGCMCipher(key)
+		return func(request Frame) Frame {
+			encrypted, _ := payloadCipher.Encrypt([]byte(plaintext))
+			return Frame{
+				Type:     "completion_response",
+				StreamID: request.StreamID,
+				MsgSeq:   request.MsgSeq,
+				Flags:    []string{FlagEncrypted},
+				Payload: map[string]interface{}{
+					"enc":   base64.StdEncoding.EncodeToString(encrypted.Ciphertext),
+					"alg":   encrypted.Algorithm,
+					"nonce": base64.StdEncoding.EncodeToString(encrypted.Nonce),
+				},
+			}
+		}
+	}
+	envelope := func(enc, alg, nonce string) func(Frame) Frame {
+		return func(request Frame) Frame {
+			return Frame{
+				Type:     "completion_response",
+				StreamID: request.StreamID,
+				MsgSeq:   request.MsgSeq,
+				Flags:    []string{FlagEncrypted},
+				Payload:  map[string]interface{}{"enc": enc, "alg": alg, "nonce": nonce},
+			}
+		}
+	}
+	nonce := "AAAAAAAAAAAAAAAA"
+
+	tests := []struct {
+		name    string
+		reply   func(Frame) Frame
+		message string
+	}{
+		{"wrong key", encryptedWith(otherEncryptionKey, `{"text": "hi"}`), "message authentication failed"},
+		{"not an object", encryptedWith(testEncryptionKey, `["hi"]`), "not a JSON object"},
+		{"corrupted ciphertext", envelope("AAAAAAAAAAAAAAAAAAAAAAAA", AlgorithmAES256GCM, nonce), "message authentication failed"},
+		{"malformed ciphertext", envelope("%%%", AlgorithmAES256GCM, nonce), "malformed ciphertext"},
+		{"unknown algorithm", envelope("AAAA", "rot13", nonce), `unsupported algorithm "rot13"`},
+		{"short nonce", envelope("AAAA", AlgorithmAES256GCM, "AAAA"), "nonce must be 12 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := encryptingRouter(t, testEncryptionKey, tt.reply)
+			client := NewATPClient(SDKConfig{WSURL: router.URL(), EncryptionKey: testEncryptionKey, Logger: &recordingLogger{}})
+			defer client.Close()
+
+			response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+			var decryptErr *DecryptionError
+			if !errors.Is(err, ErrDecryptionFailed) || !errors.As(err, &decryptErr) {
+				t.Fatalf("Expected a *DecryptionError, got response %+v, error %v", response, err)
+			}
+			if decryptErr.FrameType != "completion_response" || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Unexpected error detail: %v", err)
+			}
+		})
+	}
+}
+
+func TestEncryptionConfiguration(t *testing.T) {
+	logger := &recordingLogger{}
+	client := NewATPClient(SDKConfig{EncryptionKey: []byte("too short"), Logger: logger})
+	if len(logger.Lines()) != 1 || !strings.Contains(logger.Lines()[0], "needs a 32-byte key") {
+		t.Errorf("Expected a warning for the bad key, got %v", logger.Lines())
+	}
+	frame := NewFrameBuilder("s", "t").BuildCompletionFrame("stream", CompletionRequest{Prompt: "secret"})
+	if err := client.encryptOutgoing(&frame); err == nil || frame.Payload["prompt"] != "secret" {
+		t.Errorf("A bad key must stop frames from being sent, got %v", err)
+	}
+
+	client = NewATPClient(SDKConfig{EncryptionKey: testEncryptionKey})
+	if !client.heartbeatTemplateUsable() {
+		t.Error("Heartbeats are excluded from encryption by default")
+	}
+	client = NewATPClient(SDKConfig{EncryptionKey: testEncryptionKey, EncryptionExclude: []string{}})
+	if client.heartbeatTemplateUsable() {
+		t.Error("Encrypted heartbeats must use the Frame based send path")
+	}
+}
+
+func TestEncryptionSignsCiphertext(t *testing.T) {
+	signingKey := []byte("signing-key")
+	client := NewATPClient(SDKConfig{EncryptionKey: testEncryptionKey, SigningKey: signingKey})
+	frame := NewFrameBuilder("s", "t").BuildCompletionFrame("stream", CompletionRequest{Prompt: "secret"})
+	if err := client.prepareOutgoing(&frame); err != nil {
+		t.Fatalf("prepareOutgoing failed: %v", err)
+	}
+
+	// Routers can verify the frame without the encryption key
+	expected, _ := SignFrame(frame, signingKey)
+	if frame.Signature != expected || frame.Payload["enc"] == nil {
+		t.Errorf("Expected the signature to cover the encrypted payload, got %+v", frame)
+	}
+}
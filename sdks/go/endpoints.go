@@ -0,0 +1,136 @@
+package atpsdk
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Defaults of the endpoint quarantine
+const (
+	defaultEndpointFailureThreshold = 2
+	defaultEndpointCooldown         = 30 * time.Second
+)
+
+// endpointSet holds the router URLs of SDKConfig.WSURLs and their failure
+// state. Connecting tries them in order from the active one, skipping those
+// in quarantine.
+type endpointSet struct {
+	mu        sync.Mutex
+	urls      []string
+	active    int
+	failures  []int       // consecutive failures of each URL
+	coolUntil []time.Time // end of each URL's quarantine
+}
+
+// newEndpointSet returns the set of urls, in random order when shuffle is
+// set
+func newEndpointSet(urls []string, shuffle bool) *endpointSet {
+	urls = append([]string(nil), urls...)
+	if shuffle {
+		rand.Shuffle(len(urls), func(i, j int) { urls[i], urls[j] = urls[j], urls[i] })
+	}
+	return &endpointSet{
+		urls:      urls,
+		failures:  make([]int, len(urls)),
+		coolUntil: make([]time.Time, len(urls)),
+	}
+}
+
+// current returns the active URL
+func (e *endpointSet) current() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.urls[e.active]
+}
+
+// candidates returns the indexes of the URLs to try at now, in order: the
+// active URL and those after it, wrapping around, without the quarantined
+// ones. When every URL is quarantined, they are all tried.
+func (e *endpointSet) candidates(now time.Time) []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var available, quarantined []int
+	for n := range e.urls {
+		i := (e.active + n) % len(e.urls)
+		if now.Before(e.coolUntil[i]) {
+			quarantined = append(quarantined, i)
+		} else {
+			available = append(available, i)
+		}
+	}
+	if len(available) == 0 {
+		return quarantined
+	}
+	return available
+}
+
+// succeeded makes URL i the active one and clears its failures
+func (e *endpointSet) succeeded(i int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.active = i
+	e.failures[i] = 0
+	e.coolUntil[i] = time.Time{}
+}
+
+// failed records a failure of URL i at now, quarantining it until
+// now+cooldown after threshold failures in a row. A failure of the active
+// URL makes the next one active, so that reconnecting starts there.
+func (e *endpointSet) failed(i int, now time.Time, threshold int, cooldown time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failedLocked(i, now, threshold, cooldown)
+}
+
+// failedLocked is failed with mu held
+func (e *endpointSet) failedLocked(i int, now time.Time, threshold int, cooldown time.Duration) {
+	e.failures[i]++
+	if e.failures[i] >= threshold {
+		e.failures[i] = 0
+		e.coolUntil[i] = now.Add(cooldown)
+	}
+	if i == e.active {
+		e.active = (i + 1) % len(e.urls)
+	}
+}
+
+// dialFailover dials the router URLs in turn, from the active one and
+// skipping quarantined ones, until one answers. Registered transports dial
+// SDKConfig.TransportAddress only.
+func (c *ATPClient) dialFailover() (Transport, error) {
+	switch c.config.Transport {
+	case TransportWebSocket, TransportAuto:
+	default:
+		return c.dialRouter()
+	}
+
+	var errs []error
+	for _, i := range c.endpoints.candidates(c.config.Clock.Now()) {
+		conn, err := c.dialURL(c.endpoints.urls[i])
+		if err == nil {
+			c.endpoints.succeeded(i)
+			return conn, nil
+		}
+		c.endpoints.failed(i, c.config.Clock.Now(), c.config.EndpointFailureThreshold, c.config.EndpointCooldown)
+		errs = append(errs, err)
+		if c.closed() {
+			break
+		}
+	}
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, fmt.Errorf("all %d router URLs failed: %w", len(errs), errors.Join(errs...))
+}
+
+// endpointLost records the failure of the active URL after its connection
+// was lost, so that reconnecting starts with the next one
+func (c *ATPClient) endpointLost() {
+	c.endpoints.mu.Lock()
+	defer c.endpoints.mu.Unlock()
+	c.endpoints.failedLocked(c.endpoints.active, c.config.Clock.Now(), c.config.EndpointFailureThreshold, c.config.EndpointCooldown)
+}
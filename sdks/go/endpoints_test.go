@@ -0,0 +1,99 @@
+package atpsdk
+
+import (
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+// deadURL returns a WebSocket URL nothing listens on
+func deadURL(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return "ws://" + address
+}
+
+func TestConnectFailsOver(t *testing.T) {
+	router := newTestRouter(t, nil)
+	dead := deadURL(t)
+	client := NewATPClient(SDKConfig{WSURLs: []string{dead, router.URL()}})
+	defer client.Close()
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if url := client.Stats().Endpoint.URL; url != router.URL() {
+		t.Errorf("Expected the live router to be active, got %s", url)
+	}
+}
+
+func TestReconnectRotatesURLs(t *testing.T) {
+	first := newTestRouter(t, nil)
+	second := newTestRouter(t, nil)
+	connected := make(chan struct{}, 2)
+	client := NewATPClient(SDKConfig{
+		WSURLs:        []string{first.URL(), second.URL()},
+		AutoReconnect: true,
+		RetryDelay:    10 * time.Millisecond,
+		OnConnect:     func(string) { connected <- struct{}{} },
+	})
+	defer client.Close()
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	<-connected
+
+	first.DropConnections()
+	select {
+	case <-connected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("The client did not reconnect")
+	}
+	if url := client.Stats().Endpoint.URL; url != second.URL() {
+		t.Errorf("Expected the reconnect to move to the second router, got %s", url)
+	}
+	second.WaitForConnections(t, 1)
+}
+
+func TestEndpointQuarantine(t *testing.T) {
+	endpoints := newEndpointSet([]string{"ws://a", "ws://b", "ws://c"}, false)
+	now := time.Unix(1000, 0)
+	const threshold, cooldown = 2, 30 * time.Second
+
+	// One failure moves on without quarantining
+	endpoints.failed(0, now, threshold, cooldown)
+	if got := endpoints.candidates(now); !slices.Equal(got, []int{1, 2, 0}) {
+		t.Errorf("Expected to start with the next URL, got %v", got)
+	}
+
+	// A second one in a row quarantines the URL for the cooldown
+	endpoints.failed(0, now, threshold, cooldown)
+	if got := endpoints.candidates(now); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("Expected the quarantined URL to be skipped, got %v", got)
+	}
+	if got := endpoints.candidates(now.Add(cooldown)); !slices.Equal(got, []int{1, 2, 0}) {
+		t.Errorf("Expected the URL back after the cooldown, got %v", got)
+	}
+
+	// A success clears the failures
+	endpoints.failed(1, now, threshold, cooldown)
+	endpoints.succeeded(1)
+	endpoints.failed(1, now, threshold, cooldown)
+	if got := endpoints.candidates(now); !slices.Contains(got, 1) {
+		t.Errorf("Expected a single failure after a success not to quarantine, got %v", got)
+	}
+
+	// With every URL quarantined, all are tried
+	for i := range 3 {
+		endpoints.failed(i, now, 1, cooldown)
+	}
+	if got := endpoints.candidates(now); len(got) != 3 {
+		t.Errorf("Expected every URL to be tried, got %v", got)
+	}
+}
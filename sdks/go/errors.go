@@ -0,0 +1,216 @@
+package atpsdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrClientClosed is returned by client methods called after Close.
+	ErrClientClosed = errors.New("atpsdk: client is closed")
+	// ErrNotConnected is returned when a frame is sent without an open
+	// connection.
+	ErrNotConnected = errors.New("atpsdk: not connected")
+	// ErrConnectionFailed is wrapped by the error returned when the client
+	// cannot connect to the router, whichever transport is used.
+	ErrConnectionFailed = errors.New("atpsdk: connection to router failed")
+	// ErrDialTimeout is wrapped, along with ErrConnectionFailed, by the
+	// error returned when connecting takes longer than
+	// SDKConfig.DialTimeout or HandshakeTimeout.
+	ErrDialTimeout = errors.New("atpsdk: dialing the router timed out")
+	// ErrHandlerRegistered is returned when HandleCompletions is called while
+	// another adapter server is active on the client.
+	ErrHandlerRegistered = errors.New("atpsdk: completion handler already registered")
+	// ErrAdapterServerClosed is returned when using a closed AdapterServer.
+	ErrAdapterServerClosed = errors.New("atpsdk: adapter server is closed")
+	// ErrResponderClosed is returned when writing to a finished Responder.
+	ErrResponderClosed = errors.New("atpsdk: responder is finished")
+	// ErrTenantSuspended is matched by the *TenantSuspendedError returned
+	// for requests made while the router has suspended the client's tenant.
+	ErrTenantSuspended = errors.New("atpsdk: tenant is suspended")
+	// ErrBudgetExceeded is returned by Complete once the session has spent
+	// its configured budget.
+	ErrBudgetExceeded = errors.New("atpsdk: session budget exceeded")
+	// ErrInvalidTimeout is returned for a request made with a zero or
+	// negative WithTimeout or WithTTL.
+	ErrInvalidTimeout = errors.New("atpsdk: timeout must be positive")
+	// ErrInvalidQoS is returned for a frame or request with an unknown QoS
+	// class.
+	ErrInvalidQoS = errors.New("atpsdk: invalid QoS class")
+	// ErrInvalidMinQuality is returned for a request made with a
+	// WithMinQuality allowing no attempts.
+	ErrInvalidMinQuality = errors.New("atpsdk: minimum quality needs at least one attempt")
+	// ErrInvalidHedging is returned for a request made with a WithHedging
+	// that has no positive delay or a negative number of hedges.
+	ErrInvalidHedging = errors.New("atpsdk: invalid hedging policy")
+	// ErrStrictDecoding is matched by the *StrictDecodeError returned by
+	// DeserializeFrameStrict, and reported for incoming frames dropped with
+	// SDKConfig.StrictDecoding, when a frame has unknown or missing fields.
+	ErrStrictDecoding = errors.New("atpsdk: frame rejected by strict decoding")
+	// ErrUnknownFrameType is returned for a frame whose type is not in
+	// DefaultFrameTypes, and for sending or subscribing to one with
+	// SDKConfig.StrictFrameTypes.
+	ErrUnknownFrameType = errors.New("atpsdk: unknown frame type")
+	// ErrNotSupportedByTransport is returned for features that need a
+	// WebSocket connection, such as serving completions, when the client
+	// uses the HTTP transport.
+	ErrNotSupportedByTransport = errors.New("atpsdk: not supported by the HTTP transport")
+	// ErrSchemaValidation is matched by the *SchemaValidationError returned
+	// for frames whose payload does not satisfy SDKConfig.PayloadSchemas.
+	ErrSchemaValidation = errors.New("atpsdk: payload schema validation failed")
+	// ErrInvalidSignature is matched by the *SignatureError reported for
+	// incoming frames whose signature is missing or invalid.
+	ErrInvalidSignature = errors.New("atpsdk: invalid frame signature")
+	// ErrDecryptionFailed is matched by the *DecryptionError reported for
+	// incoming encrypted frames that cannot be decrypted.
+	ErrDecryptionFailed = errors.New("atpsdk: payload decryption failed")
+	// ErrInvalidTLSConfig is wrapped by the error returned when the client
+	// certificate, key or CA configured in SDKConfig cannot be loaded.
+	ErrInvalidTLSConfig = errors.New("atpsdk: invalid TLS configuration")
+	// ErrHeartbeatTimeout is wrapped by the error a connection is closed
+	// with after HeartbeatMissThreshold unacknowledged heartbeats.
+	ErrHeartbeatTimeout = errors.New("atpsdk: heartbeats not acknowledged")
+	// ErrWriteTimeout is wrapped by the error a connection is closed with
+	// when a write takes longer than SDKConfig.WriteTimeout.
+	ErrWriteTimeout = errors.New("atpsdk: write timed out")
+	// ErrReadTimeout is wrapped by the error a connection is closed with
+	// when nothing is read for SDKConfig.ReadTimeout.
+	ErrReadTimeout = errors.New("atpsdk: read timed out")
+	// ErrQueueFull is returned for frames sent while the outbound queue is
+	// full under the OverflowError policy.
+	ErrQueueFull = errors.New("atpsdk: outbound queue is full")
+	// ErrNacked is matched by the *NackError returned when the router
+	// rejects a frame with a nack.
+	ErrNacked = errors.New("atpsdk: frame rejected by router")
+	// ErrStreamClosed is returned by the methods of a closed Stream.
+	ErrStreamClosed = errors.New("atpsdk: stream is closed")
+	// ErrStreamOverflow ends a Stream whose received frames were not taken
+	// with Recv fast enough.
+	ErrStreamOverflow = errors.New("atpsdk: stream receive buffer overflowed")
+	// ErrReplayFailed is matched by the *ReplayError failing a request that
+	// was lost with the connection and could not be replayed.
+	ErrReplayFailed = errors.New("atpsdk: request could not be replayed")
+	// ErrStreamInterrupted is matched by the *StreamError failing a
+	// request whose stream could not be moved off a closing pool
+	// connection.
+	ErrStreamInterrupted = errors.New("atpsdk: stream interrupted")
+	// ErrCodecRequired is reported through OnAsyncError when the router
+	// sends a binary frame and no SDKConfig.Codec is configured to decode it.
+	ErrCodecRequired = errors.New("atpsdk: binary frame received but no codec is configured")
+	// ErrDiscoveryTimeout is returned by DiscoverAdapters when the router's
+	// answer is not complete in time.
+	ErrDiscoveryTimeout = errors.New("atpsdk: adapter discovery timed out")
+	// ErrTokenCallback is wrapped by the error Complete returns when its
+	// WithTokenCallback callback fails or outlasts
+	// SDKConfig.TokenCallbackTimeout.
+	ErrTokenCallback = errors.New("atpsdk: token callback failed")
+	// ErrPartialOutput is matched by the *PartialOutputError returned by
+	// CompleteTo when it fails after writing part of the response.
+	ErrPartialOutput = errors.New("atpsdk: response only partially written")
+	// ErrAdapterUnknown is returned by GetAdapterHealth for an adapter the
+	// router has no health on file for.
+	ErrAdapterUnknown = errors.New("atpsdk: adapter unknown to the router")
+	// ErrDryRunUnsupported is returned by EstimateCost when the router
+	// cannot estimate requests.
+	ErrDryRunUnsupported = errors.New("atpsdk: router does not support dry runs")
+	// ErrWindowExceeded is returned by CompletionRequest.ValidateAgainstWindow
+	// for a request too large for the flow-control window.
+	ErrWindowExceeded = errors.New("atpsdk: request exceeds the token window")
+	// ErrInvalidMeta is returned by MetaBuilder.Build for a value it does
+	// not accept, such as an unknown risk level.
+	ErrInvalidMeta = errors.New("atpsdk: invalid frame metadata")
+	// ErrProtocolVersionMismatch is matched by the *ProtocolVersionError
+	// returned when the router speaks none of the client's protocol
+	// versions.
+	ErrProtocolVersionMismatch = errors.New("atpsdk: protocol version mismatch")
+	// ErrSessionRejected is wrapped by the error Connect returns when the
+	// router rejects the session handshake.
+	ErrSessionRejected = errors.New("atpsdk: session rejected by router")
+	// ErrSessionHandshakeTimeout is wrapped by the error Connect returns
+	// when the router does not answer the session handshake in time.
+	ErrSessionHandshakeTimeout = errors.New("atpsdk: session handshake timed out")
+	// ErrInvalidConfig is wrapped by the error NewClient returns for an
+	// option given a value it does not accept.
+	ErrInvalidConfig = errors.New("atpsdk: invalid client configuration")
+	// ErrInvalidTimestamp is returned by FrameTime.Validate for a timestamp
+	// too far in the past or future to be in milliseconds.
+	ErrInvalidTimestamp = errors.New("atpsdk: implausible frame timestamp")
+	// ErrFrameTooLarge is matched by the *FrameTooLargeError returned for
+	// an outgoing frame over the size limit, and wraps the error a
+	// connection is closed with when the router sends one over
+	// SDKConfig.MaxFrameBytes.
+	ErrFrameTooLarge = errors.New("atpsdk: frame too large")
+)
+
+// Error codes reported by the router in error frames
+const (
+	ErrorCodeModelNotServed     = "MODEL_NOT_SERVED"
+	ErrorCodeAdapterUnavailable = "ADAPTER_UNAVAILABLE"
+	ErrorCodeAdapterError       = "ADAPTER_ERROR"
+	ErrorCodeAdapterPanic       = "ADAPTER_PANIC"
+	ErrorCodeAdapterOverloaded  = "ADAPTER_OVERLOADED"
+	ErrorCodeInvalidRequest     = "INVALID_REQUEST"
+	ErrorCodeRateLimited        = "RATE_LIMITED"
+	ErrorCodeTokenExpired       = "TOKEN_EXPIRED"
+	ErrorCodeWindowExceeded     = "WINDOW_EXCEEDED"
+)
+
+// ATPError is an error reported by the ATP Router in an error frame
+type ATPError struct {
+	Code    string
+	Message string
+	// Details holds the structured details the router attached, if any
+	Details interface{}
+	// RetryAfter is how long the router asked the client to wait before
+	// retrying, from retry_after_ms. Zero when unset.
+	RetryAfter time.Duration
+}
+
+func (e *ATPError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("ATP Router error: %s (%s)", e.Message, e.Code)
+	}
+	return fmt.Sprintf("ATP Router error: %s", e.Message)
+}
+
+// parseErrorFrame extracts an *ATPError from an error frame. The error is
+// read from the payload's "error" object, or from the payload itself when
+// it has a code or message. A payload of any other shape becomes the
+// message.
+func parseErrorFrame(frame *Frame) error {
+	fields, ok := frame.Payload["error"].(map[string]interface{})
+	if !ok && (frame.Payload["code"] != nil || frame.Payload["message"] != nil) {
+		fields, ok = frame.Payload, true
+	}
+	if !ok {
+		return &ATPError{Message: rawErrorPayload(frame)}
+	}
+
+	atpErr := &ATPError{
+		Code:       getString(fields, "code", ""),
+		Message:    getString(fields, "message", ""),
+		Details:    fields["details"],
+		RetryAfter: time.Duration(getFloat64(fields, "retry_after_ms", 0) * float64(time.Millisecond)),
+	}
+	if atpErr.Message == "" {
+		atpErr.Message = rawErrorPayload(frame)
+	}
+	return versionError(frame, atpErr)
+}
+
+// rawErrorPayload describes an error frame whose payload has no message
+func rawErrorPayload(frame *Frame) string {
+	if message, ok := frame.Payload["error"].(string); ok && message != "" {
+		return message
+	}
+	if len(frame.Payload) == 0 {
+		return "unknown error"
+	}
+	data, err := json.Marshal(frame.Payload)
+	if err != nil {
+		return "unknown error"
+	}
+	return string(data)
+}
@@ -0,0 +1,98 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// FlagDryRun marks a completion request the router should only estimate,
+// answering with a cost_estimate frame instead of running it
+const FlagDryRun = "dry_run"
+
+// FrameTypeCostEstimate is the router's answer to a dry-run request
+const FrameTypeCostEstimate = "cost_estimate"
+
+// ErrorCodeDryRunUnsupported is reported by routers that cannot estimate
+// requests
+const ErrorCodeDryRunUnsupported = "DRY_RUN_UNSUPPORTED"
+
+// CostEstimate is the router's estimate of a completion request, returned
+// by EstimateCost
+type CostEstimate struct {
+	TokensIn  int `json:"estimated_tokens_in"`
+	TokensOut int `json:"estimated_tokens_out"`
+	// MinCostMicros and MaxCostMicros bound the cost in USD micros
+	MinCostMicros int64 `json:"cost_min_usd_micros"`
+	MaxCostMicros int64 `json:"cost_max_usd_micros"`
+	// AdapterID and Model are the candidate the router would route to
+	AdapterID string `json:"adapter_id"`
+	Model     string `json:"model,omitempty"`
+}
+
+// EstimateCost asks the router what request would cost without running it,
+// by sending its completion frame flagged FlagDryRun. Routers that do not
+// support dry runs make it fail with an error matching
+// ErrDryRunUnsupported, so callers can fall back to counting tokens
+// locally, e.g. with EstimateTokens.
+func (c *ATPClient) EstimateCost(ctx context.Context, request CompletionRequest, opts ...RequestOption) (_ *CostEstimate, err error) {
+	if c.closed() {
+		return nil, ErrClientClosed
+	}
+	options := c.requestOptions(opts)
+	if options.err != nil {
+		return nil, options.err
+	}
+	correlationID := c.correlate(&options)
+	defer func() { err = correlateError(err, correlationID) }()
+	if err := c.applyQoS(&request, options); err != nil {
+		return nil, err
+	}
+	if options.tenant != "" {
+		ctx = ContextWithTenant(ctx, options.tenant)
+	}
+	if options.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+		defer cancel()
+	}
+	if !c.IsConnected() {
+		if err := c.Connect(); err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+
+	builder := c.builderFor(ctx)
+	streamID := c.newStreamID("estimate")
+	defer builder.ReleaseStream(streamID)
+	frame := builder.BuildCompletionFrame(streamID, request, options.meta...)
+	frame.Flags = append(frame.Flags, FlagDryRun)
+
+	pending := c.expectResponse(frame)
+	if err := c.sendRequest(ctx, frame); err != nil {
+		c.discardPending(pending, false)
+		return nil, fmt.Errorf("failed to send frame: %w", err)
+	}
+	response, err := c.waitForResponse(ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get response: %w", err)
+	}
+
+	switch response.Type {
+	case FrameTypeCostEstimate:
+	case FrameTypeError:
+		err := parseErrorFrame(response)
+		var atpErr *ATPError
+		if errors.As(err, &atpErr) && atpErr.Code == ErrorCodeDryRunUnsupported {
+			return nil, fmt.Errorf("%w: %s", ErrDryRunUnsupported, atpErr.Message)
+		}
+		return nil, err
+	default:
+		return nil, fmt.Errorf("unexpected %s frame in answer to a dry run", response.Type)
+	}
+	estimate, err := DecodePayload[CostEstimate](*response)
+	if err != nil {
+		return nil, err
+	}
+	return &estimate, nil
+}
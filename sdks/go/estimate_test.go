@@ -0,0 +1,46 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestEstimateCost(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		if !slices.Contains(f.Flags, FlagDryRun) {
+			t.Errorf("Expected a dry-run request, got flags %v", f.Flags)
+		}
+		if f.Payload["prompt"] == "unsupported" {
+			return []Frame{NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeDryRunUnsupported, "dry runs are disabled")}
+		}
+		return []Frame{{Type: FrameTypeCostEstimate, StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{
+			"estimated_tokens_in": 120, "estimated_tokens_out": 400,
+			"cost_min_usd_micros": 900, "cost_max_usd_micros": 1500,
+			"adapter_id": "edge-1", "model": "llama",
+		}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	estimate, err := client.EstimateCost(context.Background(), CompletionRequest{Prompt: "batch job"})
+	if err != nil {
+		t.Fatalf("EstimateCost failed: %v", err)
+	}
+	want := CostEstimate{TokensIn: 120, TokensOut: 400, MinCostMicros: 900, MaxCostMicros: 1500, AdapterID: "edge-1", Model: "llama"}
+	if *estimate != want {
+		t.Errorf("Unexpected estimate %+v", *estimate)
+	}
+
+	_, err = client.EstimateCost(context.Background(), CompletionRequest{Prompt: "unsupported"})
+	if !errors.Is(err, ErrDryRunUnsupported) {
+		t.Errorf("Expected ErrDryRunUnsupported, got %v", err)
+	}
+	if usage := client.Stats().Usage; usage.Requests != 0 || usage.CostUSD != 0 {
+		t.Errorf("Expected estimates not to count as usage, got %+v", usage)
+	}
+}
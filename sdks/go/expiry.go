@@ -0,0 +1,55 @@
+package atpsdk
+
+import (
+	"context"
+	"time"
+)
+
+// frameAge returns how long ago frame was sent at now, on the sender's
+// clock, and whether its TTL has run out. Frames without a timestamp or with
+// a zero TTL never expire.
+func frameAge(frame *Frame, now time.Time) (time.Duration, bool) {
+	if frame.Timestamp <= 0 {
+		return 0, false
+	}
+	age := now.Sub(frame.Time())
+	return age, frame.TTL > 0 && age > time.Duration(frame.TTL)*time.Second
+}
+
+// routerNow returns the current time on the router's clock, as measured by
+// ClockSkew
+func (c *ATPClient) routerNow() time.Time {
+	return c.config.Clock.Now().Add(c.ClockSkew())
+}
+
+// dropExpired reports whether the incoming frame must be dropped because
+// its TTL has run out, counting it and calling OnExpiredFrame if so
+func (c *ATPClient) dropExpired(frame *Frame) bool {
+	if c.config.KeepExpiredFrames {
+		return false
+	}
+	age, expired := frameAge(frame, c.routerNow())
+	if !expired {
+		return false
+	}
+	c.counters.expiredFrames.Add(1)
+	if c.config.OnExpiredFrame != nil {
+		c.config.OnExpiredFrame(*frame, age)
+	}
+	return true
+}
+
+// defaultMaxRequestTTL is SDKConfig.MaxRequestTTL when unset
+const defaultMaxRequestTTL = 5 * time.Minute
+
+// deadlineTTL sets the TTL of frame, a request, to the time left before the
+// deadline of ctx, rounded up to a whole second and at most MaxRequestTTL.
+// Requests without a deadline keep their TTL.
+func (c *ATPClient) deadlineTTL(ctx context.Context, frame *Frame) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	remaining := min(time.Until(deadline), c.config.MaxRequestTTL)
+	frame.TTL = max(int((remaining+time.Second-1)/time.Second), 1)
+}
@@ -0,0 +1,153 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExpiredFramesDropped(t *testing.T) {
+	router := newTestRouter(t, nil)
+	clock := newFakeClock()
+	expired := make(chan time.Duration, 1)
+	client := NewATPClient(SDKConfig{
+		WSURL:          router.URL(),
+		Clock:          clock,
+		OnExpiredFrame: func(_ Frame, age time.Duration) { expired <- age },
+	})
+	defer client.Close()
+	events, unsubscribe := client.Subscribe("test.event")
+	defer unsubscribe()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	stale := FrameTimeOf(clock.Now().Add(-10 * time.Second))
+	for _, frame := range []Frame{
+		{Type: "test.event", StreamID: "stale", Timestamp: stale, TTL: 5},
+		{Type: "test.event", StreamID: "no-ttl", Timestamp: stale},
+		{Type: "test.event", StreamID: "fresh", Timestamp: stale, TTL: 30},
+	} {
+		if err := router.Send(frame); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	for _, want := range []string{"no-ttl", "fresh"} {
+		select {
+		case frame := <-events:
+			if frame.StreamID != want {
+				t.Errorf("Expected frame %s, got %s", want, frame.StreamID)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Frame %s was not delivered", want)
+		}
+	}
+	select {
+	case age := <-expired:
+		if age != 10*time.Second {
+			t.Errorf("Expected a 10s age, got %v", age)
+		}
+	default:
+		t.Error("Expected OnExpiredFrame for the stale frame")
+	}
+	if n := client.Stats().Frames.Expired; n != 1 {
+		t.Errorf("Expected 1 expired frame, got %d", n)
+	}
+}
+
+func TestFrameAge(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	sent := FrameTimeOf(now.Add(-10 * time.Second))
+	client := NewATPClient(SDKConfig{Clock: newFakeClock(), KeepExpiredFrames: true})
+	defer client.Close()
+
+	tests := []struct {
+		name    string
+		frame   Frame
+		expired bool
+	}{
+		{"past ttl", Frame{Timestamp: sent, TTL: 5}, true},
+		{"within ttl", Frame{Timestamp: sent, TTL: 30}, false},
+		{"zero ttl", Frame{Timestamp: sent}, false},
+		{"no timestamp", Frame{TTL: 5}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, expired := frameAge(&tt.frame, now); expired != tt.expired {
+				t.Errorf("Expected expired=%v", tt.expired)
+			}
+		})
+	}
+
+	if client.dropExpired(&Frame{Timestamp: sent, TTL: 5}) {
+		t.Error("Expected KeepExpiredFrames to keep an expired frame")
+	}
+
+	// Ages are measured on the router's clock
+	clock := newFakeClock()
+	skewed := NewATPClient(SDKConfig{Clock: clock})
+	defer skewed.Close()
+	skewed.handleHeartbeatAck(&Frame{Type: FrameTypeHeartbeatAck, Timestamp: FrameTimeOf(clock.Now().Add(10 * time.Second))})
+	if !skewed.dropExpired(&Frame{Timestamp: FrameTimeOf(clock.Now()), TTL: 5}) {
+		t.Error("Expected the router's clock to expire the frame")
+	}
+}
+
+func TestRequestTTLFromDeadline(t *testing.T) {
+	ttls := make(chan int, 1)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeCompletionRequest {
+			return nil
+		}
+		ttls <- f.TTL
+		if f.Payload["prompt"] == "ignore" {
+			return nil
+		}
+		return []Frame{NewFrameBuilder("", "").BuildCompletionResponseFrame(f.StreamID, f.MsgSeq, CompletionResponse{Text: "ok"})}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), MaxRequestTTL: 10 * time.Second, Logger: &recordingLogger{}})
+	defer client.Close()
+
+	tests := []struct {
+		name     string
+		deadline time.Duration
+		opts     []RequestOption
+		want     int
+	}{
+		{"under a second rounds up", 300 * time.Millisecond, nil, 1},
+		{"partial seconds round up", 2500 * time.Millisecond, nil, 3},
+		{"bounded by MaxRequestTTL", time.Hour, nil, 10},
+		{"no deadline keeps the default", 0, nil, frameTypeInfo(FrameTypeCompletionRequest).TTL},
+		{"WithTimeout is a deadline", 0, []RequestOption{WithTimeout(4 * time.Second)}, 4},
+		{"WithTTL without a deadline", 0, []RequestOption{WithTTL(6 * time.Second)}, 6},
+		{"a deadline overrides WithTTL", 2 * time.Second, []RequestOption{WithTTL(6 * time.Second)}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+			if _, err := client.Complete(ctx, CompletionRequest{Prompt: "hi"}, tt.opts...); err != nil {
+				t.Fatalf("Complete failed: %v", err)
+			}
+			if ttl := <-ttls; ttl != tt.want {
+				t.Errorf("Expected TTL %d, got %d", tt.want, ttl)
+			}
+		})
+	}
+
+	// The TTL bounds the wait for a response
+	start := time.Now()
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "ignore"}, WithTTL(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("Expected the wait to end with the TTL, got %v after %v", err, time.Since(start))
+	}
+	if ttl := <-ttls; ttl != 1 {
+		t.Errorf("Expected TTL 1, got %d", ttl)
+	}
+}
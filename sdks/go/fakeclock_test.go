@@ -0,0 +1,121 @@
+package atpsdk
+
+import (
+	"sync"
+	"time"
+)
+
+// fakeClock is a manually advanced Clock for tests
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	at     time.Time
+	period time.Duration // non-zero for tickers
+	ch     chan time.Time
+	done   bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), ch: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	return w.ch
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &fakeWaiter{at: c.now.Add(d), period: d, ch: make(chan time.Time, 1)}
+	c.waiters = append(c.waiters, w)
+	return &fakeTicker{clock: c, w: w}
+}
+
+// Advance moves the clock forward, firing due timers and tickers in order.
+// Like time.Ticker, a ticker whose channel is full drops the tick.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	target := c.now.Add(d)
+	for {
+		var next *fakeWaiter
+		for _, w := range c.waiters {
+			if !w.done && !w.at.After(target) && (next == nil || w.at.Before(next.at)) {
+				next = w
+			}
+		}
+		if next == nil {
+			break
+		}
+		c.now = next.at
+		select {
+		case next.ch <- next.at:
+		default:
+		}
+		if next.period > 0 {
+			next.at = next.at.Add(next.period)
+		} else {
+			next.done = true
+		}
+	}
+	c.now = target
+
+	live := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.done {
+			live = append(live, w)
+		}
+	}
+	c.waiters = live
+}
+
+// Next returns how long until the earliest pending timer or ticker fires
+func (c *fakeClock) Next() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var next *fakeWaiter
+	for _, w := range c.waiters {
+		if !w.done && (next == nil || w.at.Before(next.at)) {
+			next = w
+		}
+	}
+	if next == nil {
+		return 0, false
+	}
+	return next.at.Sub(c.now), true
+}
+
+// Waiters returns the number of pending timers and tickers
+func (c *fakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+type fakeTicker struct {
+	clock *fakeClock
+	w     *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.ch }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.w.done = true
+}
@@ -0,0 +1,147 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// retryableFallbackCodes are the router error codes that move a request on
+// to the next model in its fallback chain.
+var retryableFallbackCodes = map[string]bool{
+	ErrorCodeModelNotServed:     true,
+	ErrorCodeAdapterUnavailable: true,
+}
+
+// isFallbackRetryable reports whether err should trigger the next fallback
+func isFallbackRetryable(err error) bool {
+	var atpErr *ATPError
+	return errors.As(err, &atpErr) && retryableFallbackCodes[atpErr.Code]
+}
+
+// completeWithFallbacks tries request against each model of chain in turn,
+// on streamID if set. A model named in the request itself is tried first.
+// All attempts share one deadline: the caller's, or DefaultTimeout from the
+// first attempt, and keep the request's idempotency key.
+func (c *ATPClient) completeWithFallbacks(ctx context.Context, streamID string, request CompletionRequest, chain []string) (*CompletionResponse, error) {
+	models := make([]string, 0, len(chain)+1)
+	if request.Model != "" {
+		models = append(models, request.Model)
+	}
+	for _, model := range chain {
+		if model != request.Model {
+			models = append(models, model)
+		}
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.DefaultTimeout)
+		defer cancel()
+	}
+
+	var attemptErrs []error
+	for depth, model := range models {
+		if err := ctx.Err(); err != nil {
+			attemptErrs = append(attemptErrs, err)
+			break
+		}
+
+		attempt := request
+		attempt.Model = model
+		response, err := c.complete(ctx, streamID, attempt)
+		if err == nil {
+			response.FallbackDepth = depth
+			response.FallbackErrors = attemptErrs
+			return response, nil
+		}
+
+		attemptErrs = append(attemptErrs, fmt.Errorf("model %s: %w", model, err))
+		if !isFallbackRetryable(err) {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("model fallback failed after %d attempt(s): %w", len(attemptErrs), errors.Join(attemptErrs...))
+}
+
+// RoutingPreference is one step of a WithFallbacks chain. Its set fields
+// replace those of the request for its attempt.
+type RoutingPreference struct {
+	Model              string
+	PreferredModel     string
+	PreferredAdapterID string
+	ExcludeAdapters    []string
+	// Timeout bounds the attempt, DefaultTimeout when unset and the
+	// caller's context has no deadline
+	Timeout time.Duration
+}
+
+// apply returns request with the preference's fields set
+func (p RoutingPreference) apply(request CompletionRequest) CompletionRequest {
+	if p.Model != "" {
+		request.Model = p.Model
+	}
+	if p.PreferredModel != "" {
+		request.PreferredModel = p.PreferredModel
+	}
+	if p.PreferredAdapterID != "" {
+		request.PreferredAdapterID = p.PreferredAdapterID
+	}
+	if p.ExcludeAdapters != nil {
+		request.ExcludeAdapters = p.ExcludeAdapters
+	}
+	return request
+}
+
+// completeRouted completes request with the preferences of WithFallbacks
+// when there are some, and with the model fallback chain otherwise
+func (c *ATPClient) completeRouted(ctx context.Context, request CompletionRequest, chain []string, preferences []RoutingPreference) (*CompletionResponse, error) {
+	if len(preferences) > 0 {
+		return c.completeWithPreferences(ctx, request, preferences)
+	}
+	return c.completeRequest(ctx, "", request, chain)
+}
+
+// completeWithPreferences tries request with each preference in turn while
+// attempts fail with a retryable error or time out. Attempts keep the
+// request's idempotency key.
+func (c *ATPClient) completeWithPreferences(ctx context.Context, request CompletionRequest, preferences []RoutingPreference) (*CompletionResponse, error) {
+	var attemptErrs []error
+	for depth, preference := range preferences {
+		if err := ctx.Err(); err != nil {
+			attemptErrs = append(attemptErrs, err)
+			break
+		}
+
+		response, err := c.completePreference(ctx, preference.apply(request), preference.Timeout)
+		if err == nil {
+			response.FallbackDepth = depth
+			response.FallbackErrors = attemptErrs
+			return response, nil
+		}
+
+		attemptErrs = append(attemptErrs, fmt.Errorf("preference %d: %w", depth, err))
+		timedOut := errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+		if !timedOut && !isFallbackRetryable(err) && !isRetryable(err) {
+			break
+		}
+	}
+
+	return nil, fmt.Errorf("routing fallback failed after %d attempt(s): %w", len(attemptErrs), errors.Join(attemptErrs...))
+}
+
+// completePreference makes one attempt of completeWithPreferences within
+// timeout
+func (c *ATPClient) completePreference(ctx context.Context, request CompletionRequest, timeout time.Duration) (*CompletionResponse, error) {
+	if _, ok := ctx.Deadline(); timeout <= 0 && !ok {
+		timeout = c.config.DefaultTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return c.complete(ctx, "", request)
+}
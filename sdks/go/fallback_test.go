@@ -0,0 +1,265 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// modelScriptedRouter answers completion requests per requested model:
+// models listed in failures get an error frame with the given code.
+func modelScriptedRouter(t *testing.T, failures map[string]string) (*testRouter, func() []string) {
+	var mu sync.Mutex
+	var requested []string
+
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		model, _ := f.Payload["model"].(string)
+		mu.Lock()
+		requested = append(requested, model)
+		mu.Unlock()
+
+		if code, ok := failures[model]; ok {
+			return []Frame{{
+				Type:     "error",
+				StreamID: f.StreamID,
+				MsgSeq:   f.MsgSeq,
+				Payload: map[string]interface{}{
+					"error": map[string]interface{}{"code": code, "message": model + " unavailable"},
+				},
+			}}
+		}
+		return []Frame{{
+			Type:     "completion_response",
+			StreamID: f.StreamID,
+			MsgSeq:   f.MsgSeq,
+			Payload:  map[string]interface{}{"text": "served by " + model, "model_used": model},
+		}}
+	})
+
+	return router, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requested...)
+	}
+}
+
+func TestModelFallbackOnModelNotServed(t *testing.T) {
+	router, requested := modelScriptedRouter(t, map[string]string{"llama3-70b": ErrorCodeModelNotServed})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Disconnect()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"},
+		WithModelFallbacks("llama3-70b", "llama3-8b", "gpt-fallback"))
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	if response.ModelUsed != "llama3-8b" {
+		t.Errorf("Expected model 'llama3-8b', got '%s'", response.ModelUsed)
+	}
+	if response.FallbackDepth != 1 {
+		t.Errorf("Expected FallbackDepth 1, got %d", response.FallbackDepth)
+	}
+	if len(response.FallbackErrors) != 1 {
+		t.Fatalf("Expected 1 fallback error, got %v", response.FallbackErrors)
+	}
+	var atpErr *ATPError
+	if !errors.As(response.FallbackErrors[0], &atpErr) || atpErr.Code != ErrorCodeModelNotServed {
+		t.Errorf("Expected MODEL_NOT_SERVED fallback error, got %v", response.FallbackErrors[0])
+	}
+	if got := requested(); len(got) != 2 || got[0] != "llama3-70b" || got[1] != "llama3-8b" {
+		t.Errorf("Expected models [llama3-70b llama3-8b] to be tried, got %v", got)
+	}
+}
+
+func TestModelFallbackKeepsIdempotencyKey(t *testing.T) {
+	keys := make(chan string, 2)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeCompletionRequest {
+			return nil
+		}
+		key, _ := f.Payload["idempotency_key"].(string)
+		keys <- key
+		if model, _ := f.Payload["model"].(string); model == "llama3-70b" {
+			return []Frame{NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeModelNotServed, "llama3-70b unavailable")}
+		}
+		return []Frame{NewFrameBuilder("", "").BuildCompletionResponseFrame(f.StreamID, f.MsgSeq, CompletionResponse{Text: "ok", ModelUsed: "llama3-8b"})}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi", IdempotencyKey: "key-1"},
+		WithModelFallbacks("llama3-70b", "llama3-8b"))
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	for i := range 2 {
+		if key := <-keys; key != "key-1" {
+			t.Errorf("Attempt %d: expected the idempotency key kept, got %q", i, key)
+		}
+	}
+}
+
+func TestModelFallbackStopsOnNonRetryableError(t *testing.T) {
+	router, requested := modelScriptedRouter(t, map[string]string{"llama3-70b": "INVALID_REQUEST"})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Disconnect()
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"},
+		WithModelFallbacks("llama3-70b", "llama3-8b"))
+
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != "INVALID_REQUEST" {
+		t.Fatalf("Expected INVALID_REQUEST error, got %v", err)
+	}
+	if got := requested(); len(got) != 1 {
+		t.Errorf("Expected no fallback after a non-retryable error, got %v", got)
+	}
+}
+
+func TestModelFallbackExhausted(t *testing.T) {
+	router, _ := modelScriptedRouter(t, map[string]string{
+		"a": ErrorCodeModelNotServed,
+		"b": ErrorCodeAdapterUnavailable,
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Disconnect()
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithModelFallbacks("a", "b"))
+	if err == nil {
+		t.Fatal("Expected an error when every model fails")
+	}
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) {
+		t.Errorf("Expected the joined error to expose the router errors, got %v", err)
+	}
+}
+
+func TestModelFallbackClientWideChain(t *testing.T) {
+	router, requested := modelScriptedRouter(t, map[string]string{"primary": ErrorCodeModelNotServed})
+	client := NewATPClient(SDKConfig{
+		WSURL:          router.URL(),
+		ModelFallbacks: map[string][]string{"completion": {"secondary"}},
+	})
+	defer client.Disconnect()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi", Model: "primary"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.FallbackDepth != 1 || response.ModelUsed != "secondary" {
+		t.Errorf("Expected secondary at depth 1, got %s at %d", response.ModelUsed, response.FallbackDepth)
+	}
+	if got := requested(); len(got) != 2 || got[0] != "primary" {
+		t.Errorf("Expected the request's own model to be tried first, got %v", got)
+	}
+}
+
+func TestModelFallbackChainPerTaskType(t *testing.T) {
+	router, requested := modelScriptedRouter(t, map[string]string{"primary": ErrorCodeModelNotServed})
+	client := NewATPClient(SDKConfig{
+		WSURL: router.URL(),
+		ModelFallbacks: map[string][]string{
+			"completion":  {"general"},
+			"code_review": {"reviewer"},
+		},
+	})
+	defer client.Disconnect()
+
+	codeReview := Meta{TaskType: "code_review"}
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi", Model: "primary"}, WithMeta(codeReview))
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.ModelUsed != "reviewer" || response.FallbackDepth != 1 {
+		t.Errorf("Expected the code_review chain, got %s at depth %d", response.ModelUsed, response.FallbackDepth)
+	}
+
+	// The task type may come from the client's default meta as well
+	clone := client.Clone(WithDefaultMeta(codeReview))
+	response, err = clone.Complete(context.Background(), CompletionRequest{Prompt: "hi", Model: "primary"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.ModelUsed != "reviewer" {
+		t.Errorf("Expected the code_review chain from DefaultMeta, got %s", response.ModelUsed)
+	}
+	if got := requested(); len(got) != 4 || got[1] != "reviewer" || got[3] != "reviewer" {
+		t.Errorf("Unexpected attempts %v", got)
+	}
+}
+
+func TestModelFallbackRespectsDeadline(t *testing.T) {
+	router, requested := modelScriptedRouter(t, map[string]string{"a": ErrorCodeModelNotServed})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Disconnect()
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	if _, err := client.Complete(ctx, CompletionRequest{Prompt: "hi"}, WithModelFallbacks("a", "b")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+	if got := requested(); len(got) != 0 {
+		t.Errorf("Expected no attempts past the deadline, got %v", got)
+	}
+}
+
+func TestFallbackPreferences(t *testing.T) {
+	requests := make(chan CompletionRequest, 4)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeCompletionRequest {
+			return nil
+		}
+		request, _ := DecodePayload[CompletionRequest](f)
+		requests <- request
+		switch request.Model {
+		case "slow":
+			return nil
+		case "gpt-4":
+			return []Frame{NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeAdapterUnavailable, "gpt-4 unavailable")}
+		}
+		return []Frame{NewFrameBuilder("", "").BuildCompletionResponseFrame(f.StreamID, f.MsgSeq, CompletionResponse{Text: "ok", ModelUsed: request.Model})}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Logger: &recordingLogger{}})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi", IdempotencyKey: "key-1"}, WithFallbacks(
+		RoutingPreference{Model: "gpt-4"},
+		RoutingPreference{Model: "slow", Timeout: 50 * time.Millisecond},
+		RoutingPreference{Model: "llama3-8b", PreferredAdapterID: "local"},
+	))
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.FallbackDepth != 2 || len(response.FallbackErrors) != 2 {
+		t.Errorf("Expected the third preference to serve after two failures, got depth %d and %v", response.FallbackDepth, response.FallbackErrors)
+	}
+	if !errors.Is(response.FallbackErrors[1], context.DeadlineExceeded) {
+		t.Errorf("Expected the second attempt to time out, got %v", response.FallbackErrors[1])
+	}
+	for i := range 3 {
+		request := <-requests
+		if request.IdempotencyKey != "key-1" {
+			t.Errorf("Attempt %d: expected the idempotency key kept, got %q", i, request.IdempotencyKey)
+		}
+		if i == 2 && (request.Model != "llama3-8b" || request.PreferredAdapterID != "local") {
+			t.Errorf("Expected the last preference applied, got %+v", request)
+		}
+	}
+
+	// All preferences failing reports every attempt
+	_, err = client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithFallbacks(
+		RoutingPreference{Model: "gpt-4"},
+		RoutingPreference{Model: "slow", Timeout: 50 * time.Millisecond},
+	))
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected both failures in the error, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// FrameTypeWindowUpdate is sent by the router to grant a flow-control
+// window, carried in the frame's Window field. Without a stream ID it
+// applies to the whole session, otherwise to that stream only.
+const FrameTypeWindowUpdate = "window_update"
+
+// WindowState is the flow-control window granted by the router and how much
+// of it is in use
+type WindowState struct {
+	// Window is the session window of the last window_update. A zero limit
+	// is not enforced, so before the first update nothing is.
+	Window Window `json:"window"`
+	// OpenStreams is the number of requests in flight, counted against
+	// Window.MaxParallel
+	OpenStreams int `json:"open_streams"`
+	// UsedTokens and UsedUSDMicros are spent since the last session
+	// window_update, counted against Window.MaxTokens and Window.MaxUSD
+	UsedTokens    int `json:"used_tokens"`
+	UsedUSDMicros int `json:"used_usd_micros"`
+	// Streams holds the per-stream windows granted to requests in flight
+	Streams map[string]Window `json:"streams,omitempty"`
+}
+
+// flowControl tracks the flow-control window and the requests counted
+// against it
+type flowControl struct {
+	mu      sync.Mutex
+	state   WindowState
+	waiting [numPriorities]int // requests waiting for the window, by priority
+	changed chan struct{}      // closed and replaced whenever capacity may free up
+}
+
+// handleWindowUpdate applies a window_update frame
+func (c *ATPClient) handleWindowUpdate(frame *Frame) {
+	f := &c.flow
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if frame.StreamID != "" {
+		// Windows of streams no longer in flight are of no use
+		if _, ok := f.state.Streams[frame.StreamID]; ok {
+			f.state.Streams[frame.StreamID] = frame.Window
+		}
+		return
+	}
+	f.state.Window = frame.Window
+	f.state.UsedTokens = 0
+	f.state.UsedUSDMicros = 0
+	f.notifyLocked()
+}
+
+// acquireWindow blocks until opening streamID fits the session window: fewer
+// than MaxParallel requests in flight and the token and USD allowances not
+// used up. Waiting requests of a higher priority (see framePriority) go
+// first. The caller must call releaseWindow once the request is done.
+func (c *ATPClient) acquireWindow(ctx context.Context, streamID string, priority int) error {
+	f := &c.flow
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	waiting := false
+	defer func() {
+		if waiting {
+			f.waiting[priority]--
+			// Capacity this request leaves may suit the next waiter
+			f.notifyLocked()
+		}
+	}()
+	for {
+		if f.fitsLocked() && !f.outrankedLocked(priority) {
+			f.state.OpenStreams++
+			if f.state.Streams == nil {
+				f.state.Streams = make(map[string]Window)
+			}
+			f.state.Streams[streamID] = Window{}
+			return nil
+		}
+		if !waiting {
+			waiting = true
+			f.waiting[priority]++
+		}
+		if f.changed == nil {
+			f.changed = make(chan struct{})
+		}
+		changed := f.changed
+		f.mu.Unlock()
+
+		var err error
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			err = fmt.Errorf("waiting for the flow-control window: %w", ctx.Err())
+		case <-c.ctx.Done():
+			err = ErrClientClosed
+		}
+		f.mu.Lock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// releaseWindow ends a request opened with acquireWindow, charging the
+// session window with its usage, which is nil if the request failed
+func (c *ATPClient) releaseWindow(streamID string, usage *CompletionResponse) {
+	f := &c.flow
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.state.OpenStreams--
+	delete(f.state.Streams, streamID)
+	if usage != nil {
+		f.state.UsedTokens += usage.TokensIn + usage.TokensOut
+		f.state.UsedUSDMicros += int(usage.CostUSD*1e6 + 0.5)
+	}
+	f.notifyLocked()
+}
+
+// fitsLocked reports whether one more request fits the session window. The
+// caller holds mu.
+func (f *flowControl) fitsLocked() bool {
+	w := f.state.Window
+	return (w.MaxParallel <= 0 || f.state.OpenStreams < w.MaxParallel) &&
+		(w.MaxTokens <= 0 || f.state.UsedTokens < w.MaxTokens) &&
+		(w.MaxUSD <= 0 || f.state.UsedUSDMicros < w.MaxUSD)
+}
+
+// outrankedLocked reports whether requests of a higher priority than
+// priority are waiting for the window. The caller holds mu.
+func (f *flowControl) outrankedLocked(priority int) bool {
+	for p := 0; p < priority; p++ {
+		if f.waiting[p] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyLocked wakes the requests waiting for the window. The caller holds
+// mu.
+func (f *flowControl) notifyLocked() {
+	if f.changed != nil {
+		close(f.changed)
+		f.changed = nil
+	}
+}
+
+// CurrentWindow returns the flow-control window granted by the router and
+// its current use, for debugging
+func (c *ATPClient) CurrentWindow() WindowState {
+	c.flow.mu.Lock()
+	defer c.flow.mu.Unlock()
+
+	state := c.flow.state
+	state.Streams = nil
+	if len(c.flow.state.Streams) > 0 {
+		state.Streams = maps.Clone(c.flow.state.Streams)
+	}
+	return state
+}
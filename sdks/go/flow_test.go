@@ -0,0 +1,128 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// heldRequestRouter hands every completion request to the test instead of
+// answering it
+func heldRequestRouter(t *testing.T) (*testRouter, <-chan Frame) {
+	requests := make(chan Frame, 8)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type == "completion_request" {
+			requests <- f
+		}
+		return nil
+	})
+	return router, requests
+}
+
+// answer completes a held request, reporting its token usage
+func answer(t *testing.T, router *testRouter, request Frame, tokens int) {
+	t.Helper()
+	err := router.Send(Frame{Type: "completion_response", StreamID: request.StreamID, MsgSeq: request.MsgSeq, Payload: map[string]interface{}{
+		"text":       "ok",
+		"tokens_in":  float64(tokens),
+		"tokens_out": float64(0),
+	}})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+}
+
+// grantWindow sends a window_update and waits for the client to apply it
+func grantWindow(t *testing.T, router *testRouter, client *ATPClient, streamID string, window Window) {
+	t.Helper()
+	if err := router.Send(Frame{Type: FrameTypeWindowUpdate, StreamID: streamID, Window: window}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		state := client.CurrentWindow()
+		if streamID == "" && state.Window == window || streamID != "" && state.Streams[streamID] == window {
+			return
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	t.Fatalf("Window update %+v for stream %q not applied", window, streamID)
+}
+
+func TestWindowMaxParallel(t *testing.T) {
+	router, requests := heldRequestRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	grantWindow(t, router, client, "", Window{MaxParallel: 1})
+
+	results := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+			results <- err
+		}()
+	}
+	first := <-requests
+	select {
+	case <-requests:
+		t.Fatal("A second stream was opened beyond max_parallel")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A per-stream window is tracked while the stream is in flight
+	grantWindow(t, router, client, first.StreamID, Window{MaxTokens: 100})
+	if state := client.CurrentWindow(); state.OpenStreams != 1 {
+		t.Errorf("Expected one open stream, got %+v", state)
+	}
+
+	answer(t, router, first, 1)
+	answer(t, router, <-requests, 1)
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("Complete failed: %v", err)
+		}
+	}
+	if state := client.CurrentWindow(); state.OpenStreams != 0 || len(state.Streams) != 0 || state.UsedTokens != 2 {
+		t.Errorf("Unexpected window state after the requests: %+v", state)
+	}
+}
+
+func TestWindowTokensExhausted(t *testing.T) {
+	router, requests := heldRequestRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	grantWindow(t, router, client, "", Window{MaxTokens: 10})
+
+	result := make(chan error, 1)
+	complete := func() {
+		_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+		result <- err
+	}
+	go complete()
+	answer(t, router, <-requests, 12)
+	if err := <-result; err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	// The spent window blocks new requests until the context gives up
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Complete(ctx, CompletionRequest{Prompt: "hi"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the request to wait for the window, got %v", err)
+	}
+
+	// or the router grants a new window
+	go complete()
+	grantWindow(t, router, client, "", Window{MaxTokens: 20})
+	answer(t, router, <-requests, 1)
+	if err := <-result; err != nil {
+		t.Errorf("Complete failed after the window update: %v", err)
+	}
+}
@@ -1,159 +1,595 @@
 package atpsdk
 
 import (
+	"container/list"
 	"encoding/json"
 	"fmt"
-	"time"
+	"sync"
 )
 
-// FrameBuilder handles construction of ATP protocol frames
+// Frame flags marking fragmented payloads
+const (
+	FlagFragment = "FRAG"
+	FlagLast     = "LAST"
+)
+
+// FrameBuilder handles construction of ATP protocol frames. It is safe for
+// concurrent use.
+//
+// The builder numbers the frames of each stream of its tenant. Call
+// ReleaseStream when a stream is done to free its counter, or cap the
+// counters kept with SetMaxStreams.
+//
+// Every Build method takes optional Meta values, whose set fields are merged
+// in order over the Meta the builder gives the frame type, such as the
+// tenant as EnvironmentID. See MetaBuilder. Frames opening or continuing a
+// stream, such as completion requests, carry the stream's TraceContext
+// unless one is supplied; the first one built for a stream sets it.
 type FrameBuilder struct {
 	sessionID string
 	tenantID  string
-	msgSeqCounters map[string]int
+	clock     Clock        // stamps the frames, see SetClock
+	counters  *seqCounters // shared with the builders of ForTenant
+}
+
+// seqCounters holds the msg_seq counters of the streams of every tenant
+type seqCounters struct {
+	mu         sync.Mutex
+	byStream   map[streamKey]*list.Element // of *streamSeq in recent
+	recent     list.List                   // most recently used stream first
+	maxStreams int
+}
+
+// streamKey identifies a stream of a tenant
+type streamKey struct {
+	tenantID string
+	streamID string
+}
+
+// streamSeq is the msg_seq counter of one stream
+type streamSeq struct {
+	key   streamKey
+	seq   int
+	trace *TraceContext // see streamTrace
 }
 
 // NewFrameBuilder creates a new frame builder
 func NewFrameBuilder(sessionID, tenantID string) *FrameBuilder {
 	return &FrameBuilder{
-		sessionID:      sessionID,
-		tenantID:       tenantID,
-		msgSeqCounters: make(map[string]int),
+		sessionID: sessionID,
+		tenantID:  tenantID,
+		clock:     realClock{},
+		counters:  &seqCounters{byStream: make(map[streamKey]*list.Element)},
+	}
+}
+
+// ForTenant returns a builder of frames for tenantID that shares fb's
+// counters. Streams are numbered per tenant, so two tenants may use the same
+// stream ID without interleaving their sequence numbers.
+func (fb *FrameBuilder) ForTenant(tenantID string) *FrameBuilder {
+	if tenantID == fb.tenantID {
+		return fb
+	}
+	return &FrameBuilder{sessionID: fb.sessionID, tenantID: tenantID, clock: fb.clock, counters: fb.counters}
+}
+
+// SetClock makes the builder stamp frames with the time of clock instead
+// of the system clock. Call it before building frames or deriving builders
+// with ForTenant.
+func (fb *FrameBuilder) SetClock(clock Clock) {
+	fb.clock = clock
+}
+
+// SetMaxStreams caps the number of streams whose msg_seq counters are kept,
+// across tenants. Beyond it the counter of the least recently used stream is
+// dropped, and that stream starts again from 1. Zero, the default, keeps
+// every counter until ReleaseStream.
+func (fb *FrameBuilder) SetMaxStreams(n int) {
+	s := fb.counters
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxStreams = n
+	s.evictLocked()
+}
+
+// ReleaseStream forgets the msg_seq counter of a stream that is done
+func (fb *FrameBuilder) ReleaseStream(streamID string) {
+	s := fb.counters
+	key := streamKey{fb.tenantID, streamID}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.byStream[key]; ok {
+		s.recent.Remove(e)
+		delete(s.byStream, key)
 	}
 }
 
 // getNextMsgSeq returns the next message sequence number for a stream
 func (fb *FrameBuilder) getNextMsgSeq(streamID string) int {
-	key := fmt.Sprintf("%s:%s", fb.sessionID, streamID)
-	fb.msgSeqCounters[key]++
-	return fb.msgSeqCounters[key]
+	s := fb.counters
+	key := streamKey{fb.tenantID, streamID}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.byStream[key]; ok {
+		s.recent.MoveToFront(e)
+		counter := e.Value.(*streamSeq)
+		counter.seq++
+		return counter.seq
+	}
+	s.byStream[key] = s.recent.PushFront(&streamSeq{key: key, seq: 1})
+	s.evictLocked()
+	return 1
+}
+
+// evictLocked drops the least recently used counters beyond maxStreams. The
+// caller holds mu.
+func (s *seqCounters) evictLocked() {
+	for s.maxStreams > 0 && s.recent.Len() > s.maxStreams {
+		oldest := s.recent.Back()
+		s.recent.Remove(oldest)
+		delete(s.byStream, oldest.Value.(*streamSeq).key)
+	}
 }
 
 // BuildCompletionFrame builds a completion request frame
-func (fb *FrameBuilder) BuildCompletionFrame(streamID string, request CompletionRequest) Frame {
+func (fb *FrameBuilder) BuildCompletionFrame(streamID string, request CompletionRequest, meta ...Meta) Frame {
 	msgSeq := fb.getNextMsgSeq(streamID)
 
-	return Frame{
-		Type:      "completion_request",
-		Timestamp: time.Now().UnixMilli(),
+	// Sized to hold a model and a correlation ID without growing
+	payload := make(map[string]interface{}, 8)
+	payload["prompt"] = request.Prompt
+	payload["max_tokens"] = request.MaxTokens
+	payload["temperature"] = request.Temperature
+	payload["top_p"] = request.TopP
+	payload["stop"] = request.Stop
+	if request.Model != "" {
+		payload["model"] = request.Model
+	}
+	if request.IdempotencyKey != "" {
+		payload["idempotency_key"] = request.IdempotencyKey
+	}
+	if request.PreferredModel != "" {
+		payload["preferred_model"] = request.PreferredModel
+	}
+	if request.PreferredAdapterID != "" {
+		payload["preferred_adapter_id"] = request.PreferredAdapterID
+	}
+	if len(request.ExcludeAdapters) > 0 {
+		payload["exclude_adapters"] = request.ExcludeAdapters
+	}
+	info := frameTypeInfo(FrameTypeCompletionRequest)
+	qos := request.QoS
+	if qos == "" {
+		qos = info.QoS
+	}
+
+	return correlated(Frame{
+		Type:      FrameTypeCompletionRequest,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
 		StreamID:  streamID,
 		MsgSeq:    msgSeq,
 		FragSeq:   0,
-		Flags:     []string{},
-		QoS:       "gold",
-		TTL:       8,
+		Flags:     info.Flags,
+		QoS:       qos,
+		TTL:       info.TTL,
 		Window: Window{
 			MaxParallel: 4,
 			MaxTokens:   50000,
 			MaxUSD:      1000000,
 		},
-		Meta: Meta{
-			TaskType:      "completion",
-			EnvironmentID: fb.tenantID,
-		},
-		Payload: map[string]interface{}{
-			"prompt":      request.Prompt,
-			"max_tokens":  request.MaxTokens,
-			"temperature": request.Temperature,
-			"top_p":       request.TopP,
-			"stop":        request.Stop,
-		},
-	}
+		Meta: fb.streamMeta(streamID, Meta{
+			TaskType:       "completion",
+			EnvironmentID:  fb.tenantID,
+			IdempotencyKey: request.IdempotencyKey,
+
+			PreferredModel:     request.PreferredModel,
+			PreferredAdapterID: request.PreferredAdapterID,
+			ExcludeAdapters:    request.ExcludeAdapters,
+		}, meta),
+		Payload: payload,
+	})
 }
 
 // BuildHeartbeatFrame builds a heartbeat frame
-func (fb *FrameBuilder) BuildHeartbeatFrame() Frame {
+func (fb *FrameBuilder) BuildHeartbeatFrame(meta ...Meta) Frame {
 	return Frame{
-		Type:      "heartbeat",
-		Timestamp: time.Now().UnixMilli(),
+		Type:      FrameTypeHeartbeat,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		Meta:      withMeta(Meta{}, meta),
 		Payload:   map[string]interface{}{},
 	}
 }
 
+// BuildHeartbeatFrameWithStats builds a heartbeat frame reporting the
+// client-side stats to the router
+func (fb *FrameBuilder) BuildHeartbeatFrameWithStats(stats HeartbeatStats, meta ...Meta) Frame {
+	frame := fb.BuildHeartbeatFrame(meta...)
+	frame.Payload = map[string]interface{}{
+		"pending_requests": stats.PendingRequests,
+		"frames_sent":      stats.FramesSent,
+		"frames_received":  stats.FramesReceived,
+		"reconnects":       stats.Reconnects,
+		"sdk_version":      stats.SDKVersion,
+	}
+	return frame
+}
+
 // BuildCapabilityFrame builds a capability advertisement frame
-func (fb *FrameBuilder) BuildCapabilityFrame(streamID string, capability CapabilityAdvertisement) Frame {
+func (fb *FrameBuilder) BuildCapabilityFrame(streamID string, capability CapabilityAdvertisement, meta ...Meta) Frame {
 	msgSeq := fb.getNextMsgSeq(streamID)
+	info := frameTypeInfo(FrameTypeCapability)
 
-	return Frame{
-		Type:      "adapter.capability",
-		Timestamp: time.Now().UnixMilli(),
+	return correlated(Frame{
+		Type:      FrameTypeCapability,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
 		StreamID:  streamID,
 		MsgSeq:    msgSeq,
 		FragSeq:   0,
-		Flags:     []string{"capability"},
-		QoS:       "bronze",
-		TTL:       30, // Longer TTL for capability frames
+		Flags:     info.Flags,
+		QoS:       info.QoS,
+		TTL:       info.TTL,
 		Window: Window{
 			MaxParallel: 1,
 			MaxTokens:   1000,
 			MaxUSD:      10000,
 		},
-		Meta: Meta{
+		Meta: fb.streamMeta(streamID, Meta{
 			EnvironmentID: fb.tenantID,
-		},
+		}, meta),
 		Payload: map[string]interface{}{
-			"type":                 "adapter.capability",
-			"adapter_id":           capability.AdapterID,
-			"adapter_type":         capability.AdapterType,
-			"capabilities":         capability.Capabilities,
-			"models":               capability.Models,
-			"max_tokens":           capability.MaxTokens,
-			"supported_languages":  capability.SupportedLanguages,
+			"type":                  FrameTypeCapability,
+			"adapter_id":            capability.AdapterID,
+			"adapter_type":          capability.AdapterType,
+			"capabilities":          capability.Capabilities,
+			"models":                capability.Models,
+			"max_tokens":            capability.MaxTokens,
+			"supported_languages":   capability.SupportedLanguages,
 			"cost_per_token_micros": capability.CostPerTokenMicros,
-			"health_endpoint":      capability.HealthEndpoint,
-			"version":              capability.Version,
-			"metadata":             capability.Metadata,
+			"health_endpoint":       capability.HealthEndpoint,
+			"version":               capability.Version,
+			"metadata":              capability.Metadata,
+		},
+	})
+}
+
+// BuildCapabilityUpdateFrame builds a frame changing the advertised
+// capabilities of an adapter by delta
+func (fb *FrameBuilder) BuildCapabilityUpdateFrame(streamID, adapterID string, delta CapabilityDelta, meta ...Meta) Frame {
+	msgSeq := fb.getNextMsgSeq(streamID)
+	info := frameTypeInfo(FrameTypeCapabilityUpdate)
+
+	payload := make(map[string]interface{}, 15) // every field and a correlation ID
+	payload["type"] = FrameTypeCapabilityUpdate
+	payload["adapter_id"] = adapterID
+	for key, items := range map[string][]string{
+		"add_models":                 delta.AddModels,
+		"remove_models":              delta.RemoveModels,
+		"add_capabilities":           delta.AddCapabilities,
+		"remove_capabilities":        delta.RemoveCapabilities,
+		"add_supported_languages":    delta.AddLanguages,
+		"remove_supported_languages": delta.RemoveLanguages,
+	} {
+		if len(items) > 0 {
+			payload[key] = items
+		}
+	}
+	if delta.AdapterType != nil {
+		payload["adapter_type"] = *delta.AdapterType
+	}
+	if delta.MaxTokens != nil {
+		payload["max_tokens"] = *delta.MaxTokens
+	}
+	if delta.CostPerTokenMicros != nil {
+		payload["cost_per_token_micros"] = *delta.CostPerTokenMicros
+	}
+	if delta.HealthEndpoint != nil {
+		payload["health_endpoint"] = *delta.HealthEndpoint
+	}
+	if delta.Version != nil {
+		payload["version"] = *delta.Version
+	}
+	if delta.Metadata != nil {
+		payload["metadata"] = delta.Metadata
+	}
+
+	return correlated(Frame{
+		Type:      FrameTypeCapabilityUpdate,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		Flags:     info.Flags,
+		QoS:       info.QoS,
+		TTL:       info.TTL,
+		Meta: fb.streamMeta(streamID, Meta{
+			EnvironmentID: fb.tenantID,
+		}, meta),
+		Payload: payload,
+	})
+}
+
+// BuildCapabilityWithdrawFrame builds a frame telling the router that an
+// adapter is going away and should no longer be routed requests
+func (fb *FrameBuilder) BuildCapabilityWithdrawFrame(streamID, adapterID string, meta ...Meta) Frame {
+	msgSeq := fb.getNextMsgSeq(streamID)
+	info := frameTypeInfo(FrameTypeCapabilityWithdraw)
+
+	return correlated(Frame{
+		Type:      FrameTypeCapabilityWithdraw,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		Flags:     info.Flags,
+		QoS:       info.QoS,
+		TTL:       info.TTL,
+		Meta: fb.streamMeta(streamID, Meta{
+			EnvironmentID: fb.tenantID,
+		}, meta),
+		Payload: map[string]interface{}{
+			"type":       FrameTypeCapabilityWithdraw,
+			"adapter_id": adapterID,
 		},
+	})
+}
+
+// BuildAdapterControlFrame builds an adapter.control frame pausing or
+// resuming adapterID, with action AdapterActionPause or AdapterActionResume.
+// reason is omitted when empty.
+func (fb *FrameBuilder) BuildAdapterControlFrame(streamID, adapterID, action, reason string, meta ...Meta) Frame {
+	msgSeq := fb.getNextMsgSeq(streamID)
+	info := frameTypeInfo(FrameTypeAdapterControl)
+
+	payload := map[string]interface{}{
+		"type":       FrameTypeAdapterControl,
+		"adapter_id": adapterID,
+		"action":     action,
+	}
+	if reason != "" {
+		payload["reason"] = reason
 	}
+	return correlated(Frame{
+		Type:      FrameTypeAdapterControl,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		Flags:     info.Flags,
+		QoS:       info.QoS,
+		TTL:       info.TTL,
+		Meta: fb.streamMeta(streamID, Meta{
+			EnvironmentID: fb.tenantID,
+		}, meta),
+		Payload: payload,
+	})
 }
 
 // BuildHealthFrame builds a health status frame
-func (fb *FrameBuilder) BuildHealthFrame(streamID string, health HealthStatus) Frame {
+func (fb *FrameBuilder) BuildHealthFrame(streamID string, health HealthStatus, meta ...Meta) Frame {
 	msgSeq := fb.getNextMsgSeq(streamID)
+	info := frameTypeInfo(FrameTypeHealth)
 
-	return Frame{
-		Type:      "adapter.health",
-		Timestamp: time.Now().UnixMilli(),
+	return correlated(Frame{
+		Type:      FrameTypeHealth,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
 		StreamID:  streamID,
 		MsgSeq:    msgSeq,
 		FragSeq:   0,
-		Flags:     []string{"health"},
-		QoS:       "bronze",
-		TTL:       60, // Health frames have longer TTL
+		Flags:     info.Flags,
+		QoS:       info.QoS,
+		TTL:       info.TTL,
 		Window: Window{
 			MaxParallel: 1,
 			MaxTokens:   1000,
 			MaxUSD:      10000,
 		},
-		Meta: Meta{},
+		Meta: fb.streamMeta(streamID, Meta{}, meta),
+		Payload: map[string]interface{}{
+			"type":                FrameTypeHealth,
+			"adapter_id":          health.AdapterID,
+			"status":              health.Status,
+			"p95_latency_ms":      health.P95LatencyMS,
+			"p50_latency_ms":      health.P50LatencyMS,
+			"p99_latency_ms":      health.P99LatencyMS,
+			"requests_per_second": health.RequestsPerSecond,
+			"error_rate":          health.ErrorRate,
+			"queue_depth":         health.QueueDepth,
+			"memory_usage_mb":     health.MemoryUsageMB,
+			"cpu_usage_percent":   health.CPUUsagePercent,
+			"uptime_seconds":      health.UptimeSeconds,
+			"version":             health.Version,
+			"last_health_check":   fb.clock.Now().Unix(),
+			"metadata":            health.Metadata,
+		},
+	})
+}
+
+// BuildTopicFrame builds a subscribe or unsubscribe frame registering
+// broadcast topic patterns with the router
+func (fb *FrameBuilder) BuildTopicFrame(frameType, streamID string, topics []string, meta ...Meta) Frame {
+	msgSeq := fb.getNextMsgSeq(streamID)
+
+	return Frame{
+		Type:      frameType,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		Meta:      fb.streamMeta(streamID, Meta{}, meta),
+		Payload: map[string]interface{}{
+			"topics": topics,
+		},
+	}
+}
+
+// BuildCancelFrame builds a frame asking the router to stop working on the
+// request identified by streamID and msgSeq
+func (fb *FrameBuilder) BuildCancelFrame(streamID string, msgSeq int, reason string, meta ...Meta) Frame {
+	return Frame{
+		Type:      FrameTypeCancel,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		Meta:      withMeta(Meta{}, meta),
 		Payload: map[string]interface{}{
-			"type":                 "adapter.health",
-			"adapter_id":           health.AdapterID,
-			"status":               health.Status,
-			"p95_latency_ms":       health.P95LatencyMS,
-			"p50_latency_ms":       health.P50LatencyMS,
-			"p99_latency_ms":       health.P99LatencyMS,
-			"requests_per_second":  health.RequestsPerSecond,
-			"error_rate":           health.ErrorRate,
-			"queue_depth":          health.QueueDepth,
-			"memory_usage_mb":      health.MemoryUsageMB,
-			"cpu_usage_percent":    health.CPUUsagePercent,
-			"uptime_seconds":       health.UptimeSeconds,
-			"version":              health.Version,
-			"last_health_check":    time.Now().Unix(),
-			"metadata":             health.Metadata,
+			"reason": reason,
+		},
+	}
+}
+
+// BuildReauthFrame builds a frame presenting new credentials over an
+// established connection. Empty credentials are left out.
+func (fb *FrameBuilder) BuildReauthFrame(streamID string, credentials Credentials, meta ...Meta) Frame {
+	payload := make(map[string]interface{}, 2)
+	if credentials.APIKey != "" {
+		payload["api_key"] = credentials.APIKey
+	}
+	if credentials.BearerToken != "" {
+		payload["bearer_token"] = credentials.BearerToken
+	}
+	return Frame{
+		Type:      FrameTypeReauth,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		Meta: withMeta(Meta{
+			EnvironmentID: fb.tenantID,
+		}, meta),
+		Payload: payload,
+	}
+}
+
+// BuildIntrospectionFrame builds the response to the introspect.request
+// identified by streamID and msgSeq
+func (fb *FrameBuilder) BuildIntrospectionFrame(streamID string, msgSeq int, introspection Introspection, meta ...Meta) Frame {
+	return Frame{
+		Type:      FrameTypeIntrospectResponse,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		Meta: withMeta(Meta{
+			EnvironmentID: fb.tenantID,
+		}, meta),
+		Payload: map[string]interface{}{
+			"introspection": introspection,
+		},
+	}
+}
+
+// BuildCompletionResponseFrame builds the response to the completion request
+// identified by streamID and msgSeq
+func (fb *FrameBuilder) BuildCompletionResponseFrame(streamID string, msgSeq int, response CompletionResponse, meta ...Meta) Frame {
+	frame := Frame{
+		Type:      FrameTypeCompletionResponse,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		FragSeq:   0,
+		Flags:     []string{},
+		Meta: withMeta(Meta{
+			EnvironmentID: fb.tenantID,
+		}, meta),
+		Payload: map[string]interface{}{
+			"text":          response.Text,
+			"model_used":    response.ModelUsed,
+			"tokens_in":     response.TokensIn,
+			"tokens_out":    response.TokensOut,
+			"cost_usd":      response.CostUSD,
+			"quality_score": response.QualityScore,
+			"finished":      response.Finished,
+		},
+	}
+	if response.AdapterID != "" {
+		frame.Payload["adapter_id"] = response.AdapterID
+	}
+	return frame
+}
+
+// BuildCompletionChunkFrame builds a partial completion response carrying a
+// text delta. Chunks share the request's stream ID and message sequence and
+// are ordered by fragSeq; the final chunk is a full completion response
+// flagged LAST.
+func (fb *FrameBuilder) BuildCompletionChunkFrame(streamID string, msgSeq, fragSeq int, text string, meta ...Meta) Frame {
+	return Frame{
+		Type:      FrameTypeCompletionResponse,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		FragSeq:   fragSeq,
+		Flags:     []string{FlagFragment},
+		Meta: withMeta(Meta{
+			EnvironmentID: fb.tenantID,
+		}, meta),
+		Payload: map[string]interface{}{
+			"text": text,
+		},
+	}
+}
+
+// BuildErrorFrame builds an error frame answering the request identified by
+// streamID and msgSeq
+func (fb *FrameBuilder) BuildErrorFrame(streamID string, msgSeq int, code, message string, meta ...Meta) Frame {
+	return Frame{
+		Type:      FrameTypeError,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    msgSeq,
+		FragSeq:   0,
+		Flags:     []string{},
+		Meta: withMeta(Meta{
+			EnvironmentID: fb.tenantID,
+		}, meta),
+		Payload: map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    code,
+				"message": message,
+			},
 		},
 	}
 }
 
 // SerializeFrame serializes a frame to JSON bytes
 func (fb *FrameBuilder) SerializeFrame(frame Frame) ([]byte, error) {
-	return json.Marshal(frame)
+	return marshalFrame(&frame)
 }
 
 // DeserializeFrame deserializes JSON bytes to a frame
 func (fb *FrameBuilder) DeserializeFrame(data []byte) (Frame, error) {
 	var frame Frame
 	err := json.Unmarshal(data, &frame)
+	normalizeVersion(&frame)
 	return frame, err
 }
+
+// ValidateFrame checks the fields of frame the SDK constrains: its type,
+// which must be in DefaultFrameTypes, and its QoS class, which must be empty
+// or known. The client validates every frame it sends, checking its type
+// only with SDKConfig.StrictFrameTypes.
+func ValidateFrame(frame Frame) error {
+	return validateFrame(frame, true)
+}
+
+// validateFrame is ValidateFrame, checking the frame's type only when
+// strictTypes is set
+func validateFrame(frame Frame, strictTypes bool) error {
+	if strictTypes {
+		if err := DefaultFrameTypes.validate(frame.Type); err != nil {
+			return err
+		}
+	}
+	if frame.QoS != "" {
+		if err := validateQoS(frame.QoS); err != nil {
+			return fmt.Errorf("invalid %s frame: %w", frame.Type, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package atpsdk
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// streamCounters returns how many msg_seq counters fb keeps
+func streamCounters(fb *FrameBuilder) int {
+	fb.counters.mu.Lock()
+	defer fb.counters.mu.Unlock()
+	return len(fb.counters.byStream)
+}
+
+func TestFrameBuilderReleaseStream(t *testing.T) {
+	fb := NewFrameBuilder("session", "tenant")
+	for i := 0; i < 1000000; i++ {
+		streamID := strconv.Itoa(i)
+		if seq := fb.BuildCompletionFrame(streamID, CompletionRequest{}).MsgSeq; seq != 1 {
+			t.Fatalf("Expected msg_seq 1 on a new stream, got %d", seq)
+		}
+		fb.ReleaseStream(streamID)
+	}
+	if n := streamCounters(fb); n != 0 {
+		t.Errorf("Expected released counters to be freed, %d left", n)
+	}
+}
+
+func TestFrameBuilderMaxStreams(t *testing.T) {
+	fb := NewFrameBuilder("session", "tenant")
+	fb.SetMaxStreams(100)
+	fb.getNextMsgSeq("long-lived")
+	for i := 0; i < 1000000; i++ {
+		fb.getNextMsgSeq(strconv.Itoa(i))
+		if i%50 == 0 {
+			// Recently used streams are kept
+			fb.getNextMsgSeq("long-lived")
+		}
+	}
+	if n := streamCounters(fb); n != 100 {
+		t.Errorf("Expected 100 counters, got %d", n)
+	}
+	if seq := fb.getNextMsgSeq("long-lived"); seq != 1000000/50+2 {
+		t.Errorf("Expected the long-lived stream to keep counting, got msg_seq %d", seq)
+	}
+	if seq := fb.getNextMsgSeq("0"); seq != 1 {
+		t.Errorf("Expected an evicted stream to start over, got msg_seq %d", seq)
+	}
+}
+
+func TestFrameBuilderForTenant(t *testing.T) {
+	fb := NewFrameBuilder("session", "tenant")
+	tenants := []*FrameBuilder{fb, fb.ForTenant("tenant-a"), fb.ForTenant("tenant-b")}
+
+	// Each tenant numbers the shared stream ID on its own
+	const frames = 1000
+	var wg sync.WaitGroup
+	for _, builder := range tenants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for want := 1; want <= frames; want++ {
+				frame := builder.BuildCompletionFrame("shared", CompletionRequest{})
+				if frame.MsgSeq != want || frame.Meta.EnvironmentID != builder.tenantID {
+					t.Errorf("Expected msg_seq %d for %s, got %d for %s", want, builder.tenantID, frame.MsgSeq, frame.Meta.EnvironmentID)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	tenants[1].ReleaseStream("shared")
+	if n := streamCounters(fb); n != 2 {
+		t.Errorf("Expected only tenant-a's counter released, %d left", n)
+	}
+}
+
+func TestClientReleasesStreams(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+	}
+	if err := client.ReportHealth(context.Background(), HealthStatus{AdapterID: "a", Status: "healthy"}); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+	if n := streamCounters(client.builder); n != 0 {
+		t.Errorf("Expected finished requests to release their counters, %d left", n)
+	}
+}
+
+func TestFrameBuilderClock(t *testing.T) {
+	clock := newFakeClock()
+	fb := NewFrameBuilder("s1", "acme")
+	fb.SetClock(clock)
+
+	want := FrameTimeOf(clock.Now())
+	if frame := fb.BuildCompletionFrame("stream-1", CompletionRequest{Prompt: "hi"}); frame.Timestamp != want {
+		t.Errorf("Expected the clock's timestamp %d, got %d", want, frame.Timestamp)
+	}
+	if frame := fb.ForTenant("globex").BuildHeartbeatFrame(); frame.Timestamp != want {
+		t.Errorf("Expected ForTenant to keep the clock, got %d", frame.Timestamp)
+	}
+}
+
+func TestFrameOmitsEmptyWindowAndMeta(t *testing.T) {
+	fb := NewFrameBuilder("s1", "acme")
+
+	for _, frame := range []Frame{fb.BuildHeartbeatFrame(), fb.BuildHeartbeatFrame(Meta{Languages: []string{}})} {
+		data, err := fb.SerializeFrame(frame)
+		if err != nil {
+			t.Fatalf("SerializeFrame failed: %v", err)
+		}
+		if strings.Contains(string(data), `"window"`) || strings.Contains(string(data), `"meta"`) {
+			t.Errorf("Expected a heartbeat without window or meta, got %s", data)
+		}
+	}
+
+	data, err := fb.SerializeFrame(fb.BuildCompletionFrame("stream-1", CompletionRequest{Prompt: "hi"}))
+	if err != nil {
+		t.Fatalf("SerializeFrame failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"window":{"max_parallel":4`) || !strings.Contains(string(data), `"meta":{`) {
+		t.Errorf("Expected a completion request with window and meta, got %s", data)
+	}
+
+	// Frames carrying empty ones are still read
+	frame, err := fb.DeserializeFrame([]byte(`{"type":"heartbeat","ts":1,"window":{"max_parallel":0,"max_tokens":0,"max_usd_micros":0},"meta":{},"payload":{}}`))
+	if err != nil || frame.Type != FrameTypeHeartbeat || frame.Window != (Window{}) || !frame.Meta.IsZero() {
+		t.Errorf("Expected the heartbeat decoded, got %+v, %v", frame, err)
+	}
+}
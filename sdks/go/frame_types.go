@@ -0,0 +1,174 @@
+package atpsdk
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Frame types of the core protocol. The frame types of optional features
+// are declared with them, e.g. FrameTypeAck.
+const (
+	FrameTypeCompletionRequest  = "completion_request"
+	FrameTypeCompletionResponse = "completion_response"
+	FrameTypeError              = "error"
+	FrameTypeHeartbeat          = "heartbeat"
+	FrameTypeCancel             = "cancel"
+	FrameTypeCapability         = "adapter.capability"
+	FrameTypeCapabilityUpdate   = "adapter.capability.update"
+	FrameTypeCapabilityWithdraw = "adapter.capability.withdraw"
+	FrameTypeHealth             = "adapter.health"
+)
+
+// FrameTypeInfo describes a frame type in a FrameTypeRegistry
+type FrameTypeInfo struct {
+	// Direction is "inbound", "outbound" or "both", seen from the client
+	Direction   string
+	Description string
+	// QoS, TTL and Flags are given by FrameBuilder to the frames of the
+	// type it builds
+	QoS   QoS
+	TTL   int
+	Flags []string
+	// ExpectsResponse is set for requests the router answers, with a
+	// response frame or an ack
+	ExpectsResponse bool
+	// Response is set for frames answering a request, which the client
+	// hands to the request's waiter
+	Response bool
+}
+
+// FrameTypeRegistry maps frame types to their FrameTypeInfo. It is safe for
+// concurrent use.
+type FrameTypeRegistry struct {
+	mu     sync.RWMutex
+	order  []string
+	types  map[string]FrameTypeInfo
+	custom map[string]bool
+}
+
+// NewFrameTypeRegistry returns a registry of the frame types the SDK sends
+// or handles
+func NewFrameTypeRegistry() *FrameTypeRegistry {
+	r := &FrameTypeRegistry{types: make(map[string]FrameTypeInfo), custom: make(map[string]bool)}
+	for _, builtin := range builtinFrameTypes {
+		r.order = append(r.order, builtin.frameType)
+		r.types[builtin.frameType] = builtin.info
+	}
+	return r
+}
+
+// DefaultFrameTypes is the registry used by FrameBuilder, ValidateFrame and
+// the client. Register the application's own frame types in it to send and
+// subscribe to them with SDKConfig.StrictFrameTypes.
+var DefaultFrameTypes = NewFrameTypeRegistry()
+
+// Register adds frameType to the registry, or replaces its info, e.g. to
+// change the TTL FrameBuilder gives its frames
+func (r *FrameTypeRegistry) Register(frameType string, info FrameTypeInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, known := r.types[frameType]; !known {
+		r.order = append(r.order, frameType)
+		r.custom[frameType] = true
+	}
+	info.Flags = slices.Clone(info.Flags)
+	r.types[frameType] = info
+}
+
+// Lookup returns the info of frameType and whether it is registered
+func (r *FrameTypeRegistry) Lookup(frameType string) (FrameTypeInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	info, ok := r.types[frameType]
+	info.Flags = slices.Clone(info.Flags)
+	return info, ok
+}
+
+// Types returns the registered frame types, the SDK's first, then the
+// others in the order they were registered
+func (r *FrameTypeRegistry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.order)
+}
+
+// validate returns an error matching ErrUnknownFrameType unless frameType
+// is registered
+func (r *FrameTypeRegistry) validate(frameType string) error {
+	if _, ok := r.Lookup(frameType); ok {
+		return nil
+	}
+	if frameType == "" {
+		return fmt.Errorf("%w: empty frame type", ErrUnknownFrameType)
+	}
+	return fmt.Errorf("%w %q: register it in DefaultFrameTypes first", ErrUnknownFrameType, frameType)
+}
+
+// isCustom reports whether frameType was registered by the application
+func (r *FrameTypeRegistry) isCustom(frameType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.custom[frameType]
+}
+
+// frameTypeInfo returns the info of frameType in DefaultFrameTypes
+func frameTypeInfo(frameType string) FrameTypeInfo {
+	info, _ := DefaultFrameTypes.Lookup(frameType)
+	return info
+}
+
+// isResponse reports whether frames of frameType answer a request
+func isResponse(frameType string) bool {
+	return frameTypeInfo(frameType).Response
+}
+
+// builtinFrameTypes are the frame types the SDK itself sends or handles
+var builtinFrameTypes = []struct {
+	frameType string
+	info      FrameTypeInfo
+}{
+	{FrameTypeCompletionRequest, FrameTypeInfo{Direction: "both", Description: "completion request, sent by clients and served by adapters",
+		QoS: QoSGold, TTL: 8, Flags: []string{}, ExpectsResponse: true}},
+	{FrameTypeCompletionResponse, FrameTypeInfo{Direction: "both", Description: "completion result", Response: true}},
+	{FrameTypeError, FrameTypeInfo{Direction: "both", Description: "error answering a request", Response: true}},
+	{FrameTypeHeartbeat, FrameTypeInfo{Direction: "outbound", Description: "connection keepalive"}},
+	{FrameTypeHeartbeatAck, FrameTypeInfo{Direction: "inbound", Description: "acknowledges a heartbeat"}},
+	{FrameTypeWindowUpdate, FrameTypeInfo{Direction: "inbound", Description: "grants a flow-control window"}},
+	{FrameTypeAck, FrameTypeInfo{Direction: "both", Description: "accepts a frame"}},
+	{FrameTypeNack, FrameTypeInfo{Direction: "both", Description: "rejects a frame, with a reason"}},
+	{FrameTypeStreamClose, FrameTypeInfo{Direction: "both", Description: "ends a stream"}},
+	{FrameTypeSessionHello, FrameTypeInfo{Direction: "outbound", Description: "opens the session, describing the client", ExpectsResponse: true}},
+	{FrameTypeSessionWelcome, FrameTypeInfo{Direction: "inbound", Description: "accepts the session, with the router's limits"}},
+	{FrameTypeSessionResume, FrameTypeInfo{Direction: "outbound", Description: "resumes the session after reconnecting", ExpectsResponse: true}},
+	{FrameTypeStreamResume, FrameTypeInfo{Direction: "outbound", Description: "moves a request in flight to another pool connection", ExpectsResponse: true}},
+	{FrameTypeCapability, FrameTypeInfo{Direction: "outbound", Description: "adapter capabilities",
+		QoS: QoSBronze, TTL: 30, Flags: []string{"capability"}, ExpectsResponse: true}},
+	{FrameTypeCapabilityUpdate, FrameTypeInfo{Direction: "outbound", Description: "adapter capability changes",
+		QoS: QoSBronze, TTL: 30, Flags: []string{"capability"}, ExpectsResponse: true}},
+	{FrameTypeCapabilityWithdraw, FrameTypeInfo{Direction: "outbound", Description: "adapter withdrawal",
+		QoS: QoSBronze, TTL: 30, Flags: []string{"capability"}, ExpectsResponse: true}},
+	{FrameTypeAdapterControl, FrameTypeInfo{Direction: "outbound", Description: "pauses or resumes routing to an adapter",
+		QoS: QoSBronze, TTL: 30, Flags: []string{"capability"}, ExpectsResponse: true}},
+	{FrameTypeHealth, FrameTypeInfo{Direction: "both", Description: "adapter health report",
+		QoS: QoSBronze, TTL: 60, Flags: []string{"health"}, ExpectsResponse: true}},
+	{FrameTypeHealthQuery, FrameTypeInfo{Direction: "outbound", Description: "asks for an adapter's latest health", ExpectsResponse: true}},
+	{FrameTypeCostEstimate, FrameTypeInfo{Direction: "inbound", Description: "estimate answering a dry-run request", Response: true}},
+	{FrameTypeUsageQuery, FrameTypeInfo{Direction: "outbound", Description: "asks for the usage recorded by the router", ExpectsResponse: true}},
+	{FrameTypeUsageReport, FrameTypeInfo{Direction: "inbound", Description: "a page of recorded usage"}},
+	{FrameTypeQuotaQuery, FrameTypeInfo{Direction: "outbound", Description: "asks for the tenant's remaining quota", ExpectsResponse: true}},
+	{FrameTypeQuotaStatus, FrameTypeInfo{Direction: "inbound", Description: "the tenant's remaining quota"}},
+	{FrameTypeQuotaWarning, FrameTypeInfo{Direction: "inbound", Description: "warns that the tenant's quota is running low"}},
+	{FrameTypeDiscoveryRequest, FrameTypeInfo{Direction: "outbound", Description: "asks for the registered adapters", ExpectsResponse: true}},
+	{FrameTypeDiscoveryResponse, FrameTypeInfo{Direction: "inbound", Description: "a page of registered adapters"}},
+	{FrameTypeCancel, FrameTypeInfo{Direction: "outbound", Description: "cancels an abandoned request"}},
+	{FrameTypeSubscribe, FrameTypeInfo{Direction: "outbound", Description: "subscribes to broadcast topics", ExpectsResponse: true}},
+	{FrameTypeUnsubscribe, FrameTypeInfo{Direction: "outbound", Description: "unsubscribes from broadcast topics", ExpectsResponse: true}},
+	{FrameTypeBroadcast, FrameTypeInfo{Direction: "inbound", Description: "message published on a topic"}},
+	{FrameTypeTenantSuspend, FrameTypeInfo{Direction: "inbound", Description: "suspends the client's tenant"}},
+	{FrameTypeTenantResume, FrameTypeInfo{Direction: "inbound", Description: "lifts a tenant suspension"}},
+	{FrameTypeIntrospectRequest, FrameTypeInfo{Direction: "inbound", Description: "asks the client to describe itself"}},
+	{FrameTypeIntrospectResponse, FrameTypeInfo{Direction: "outbound", Description: "the client's self-description"}},
+	{FrameTypeAuthExpiring, FrameTypeInfo{Direction: "inbound", Description: "announces that the API key is about to expire"}},
+	{FrameTypeReauth, FrameTypeInfo{Direction: "outbound", Description: "presents a new API key over the open connection", ExpectsResponse: true}},
+}
@@ -0,0 +1,87 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrameTypeRegistry(t *testing.T) {
+	registry := NewFrameTypeRegistry()
+	info, ok := registry.Lookup(FrameTypeHealth)
+	if !ok || info.QoS != QoSBronze || info.TTL != 60 || !info.ExpectsResponse {
+		t.Errorf("Unexpected info for %s: %+v", FrameTypeHealth, info)
+	}
+	if info, _ := registry.Lookup(FrameTypeCostEstimate); !info.Response {
+		t.Errorf("Expected %s to be a response", FrameTypeCostEstimate)
+	}
+
+	registry.Register("policy.update", FrameTypeInfo{Direction: "inbound", TTL: 5})
+	if info, ok := registry.Lookup("policy.update"); !ok || info.TTL != 5 || !registry.isCustom("policy.update") {
+		t.Errorf("Expected the registered type, got %+v", info)
+	}
+	if types := registry.Types(); types[0] != FrameTypeCompletionRequest || types[len(types)-1] != "policy.update" {
+		t.Errorf("Expected the SDK's types first, got %v", types)
+	}
+	if registry.isCustom(FrameTypeHealth) {
+		t.Error("Expected a built-in type not to be custom")
+	}
+
+	err := registry.validate("policy.updte")
+	if !errors.Is(err, ErrUnknownFrameType) || !strings.Contains(err.Error(), "DefaultFrameTypes") {
+		t.Errorf("Expected ErrUnknownFrameType with a hint, got %v", err)
+	}
+	if err := ValidateFrame(Frame{}); !errors.Is(err, ErrUnknownFrameType) {
+		t.Errorf("Expected an empty type to be rejected, got %v", err)
+	}
+}
+
+func TestStrictFrameTypes(t *testing.T) {
+	router := newTestRouter(t, nil)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), StrictFrameTypes: true})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	events, _ := client.Subscribe("test.evnt")
+	if _, open := <-events; open {
+		t.Error("Expected the channel of an unknown type to be closed")
+	}
+	broadcasts, unsubscribe := client.Subscribe(FrameTypeBroadcast)
+	defer unsubscribe()
+	select {
+	case <-broadcasts:
+		t.Error("Expected the channel of a known type to stay open")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := client.SendReliable(ctx, Frame{Type: "test.evnt", StreamID: "s1"}); !errors.Is(err, ErrUnknownFrameType) {
+		t.Errorf("Expected sending an unknown type to fail, got %v", err)
+	}
+
+	specs := client.Introspect().FrameTypes
+	if !slices.ContainsFunc(specs, func(s FrameTypeSpec) bool { return s.Type == FrameTypeHealth && s.Description != "" }) {
+		t.Errorf("Expected the introspection to list the registry, got %+v", specs)
+	}
+}
+
+func TestFrameBuilderDefaults(t *testing.T) {
+	fb := NewFrameBuilder("s1", "acme")
+	for _, frame := range []Frame{
+		fb.BuildCompletionFrame("stream-1", CompletionRequest{Prompt: "hi"}),
+		fb.BuildCapabilityFrame("stream-2", CapabilityAdvertisement{}),
+		fb.BuildCapabilityWithdrawFrame("stream-3", "a"),
+		fb.BuildHealthFrame("stream-4", HealthStatus{}),
+	} {
+		info, _ := DefaultFrameTypes.Lookup(frame.Type)
+		if frame.QoS != info.QoS || frame.TTL != info.TTL || !slices.Equal(frame.Flags, info.Flags) {
+			t.Errorf("Expected the %s frame to use the registry defaults %+v, got %+v", frame.Type, info, frame)
+		}
+	}
+}
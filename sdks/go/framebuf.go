@@ -0,0 +1,64 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBuffer is the capacity beyond which an encode buffer is dropped
+// rather than pooled, so one huge frame does not pin its memory
+const maxPooledBuffer = 64 << 10
+
+// frameEncoder is a reusable buffer with a JSON encoder writing to it
+type frameEncoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+}
+
+// frameEncoders pools the encoders of appendFrame
+var frameEncoders = sync.Pool{New: func() any {
+	e := new(frameEncoder)
+	e.enc = json.NewEncoder(&e.buf)
+	return e
+}}
+
+// AppendFrame appends the JSON encoding of frame, as SerializeFrame
+// returns it, to dst and returns the extended slice. Reusing dst across
+// frames avoids allocating a new slice for each. The result never shares
+// memory with the SDK's internal buffers.
+func AppendFrame(dst []byte, frame Frame) ([]byte, error) {
+	return appendFrame(dst, &frame)
+}
+
+// appendFrame is AppendFrame. A raw payload is spliced in unchanged, where
+// the encoder would compact and escape it.
+func appendFrame(dst []byte, frame *Frame) ([]byte, error) {
+	e := frameEncoders.Get().(*frameEncoder)
+	defer func() {
+		if e.buf.Cap() <= maxPooledBuffer {
+			e.buf.Reset()
+			frameEncoders.Put(e)
+		}
+	}()
+
+	if frame.Payload != nil || frame.RawPayload == nil {
+		if err := e.enc.Encode((*frameEnvelope)(frame)); err != nil {
+			return dst, err
+		}
+		// Encode ends the value with a newline
+		return append(dst, e.buf.Bytes()[:e.buf.Len()-1]...), nil
+	}
+	err := e.enc.Encode(struct {
+		*frameEnvelope
+		Payload *struct{} `json:"payload,omitempty"`
+	}{frameEnvelope: (*frameEnvelope)(frame)})
+	if err != nil {
+		return dst, err
+	}
+	// Drop the closing brace and newline to add the payload
+	dst = append(dst, e.buf.Bytes()[:e.buf.Len()-2]...)
+	dst = append(dst, `,"payload":`...)
+	dst = append(dst, frame.RawPayload...)
+	return append(dst, '}'), nil
+}
@@ -0,0 +1,76 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAppendFrame(t *testing.T) {
+	fb := NewFrameBuilder("s1", "t1")
+	frame := fb.BuildCompletionFrame("stream-1", CompletionRequest{Prompt: "<hello>", MaxTokens: 10})
+
+	want, err := fb.SerializeFrame(frame)
+	if err != nil {
+		t.Fatalf("SerializeFrame failed: %v", err)
+	}
+	got, err := AppendFrame([]byte("prefix:"), frame)
+	if err != nil {
+		t.Fatalf("AppendFrame failed: %v", err)
+	}
+	if string(got) != "prefix:"+string(want) {
+		t.Errorf("Expected the frame appended after the prefix, got %s", got)
+	}
+	if marshaled, _ := json.Marshal(frame); !bytes.Equal(marshaled, want) {
+		t.Errorf("Expected SerializeFrame to match json.Marshal:\n%s\n%s", want, marshaled)
+	}
+
+	// Reusing dst overwrites it in place
+	dst := got[:0]
+	again, _ := AppendFrame(dst, frame)
+	if &again[0] != &got[0] || !bytes.Equal(again, want) {
+		t.Error("Expected AppendFrame to reuse the capacity of dst")
+	}
+}
+
+func TestAppendFrameNeverAliasesPooledBuffers(t *testing.T) {
+	fb := NewFrameBuilder("s1", "t1")
+	first, _ := fb.SerializeFrame(fb.BuildCompletionFrame("a", CompletionRequest{Prompt: "first"}))
+	kept := bytes.Clone(first)
+
+	// A larger frame, then one over the pooling limit, then a raw one
+	_, _ = fb.SerializeFrame(fb.BuildCompletionFrame("b", CompletionRequest{Prompt: strings.Repeat("x", 4096)}))
+	_, _ = fb.SerializeFrame(fb.BuildCompletionFrame("c", CompletionRequest{Prompt: strings.Repeat("y", maxPooledBuffer)}))
+	_, _ = fb.SerializeFrame(Frame{Type: "test.event", RawPayload: json.RawMessage(`{"z": 1}`)})
+	if !bytes.Equal(first, kept) {
+		t.Fatalf("Expected an earlier result to be left alone, got %s", first)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var dst []byte
+			for j := 0; j < 200; j++ {
+				streamID := fmt.Sprintf("stream-%d-%d", i, j)
+				frame := fb.BuildCompletionFrame(streamID, CompletionRequest{Prompt: strings.Repeat("p", j*10)})
+				data, err := AppendFrame(dst[:0], frame)
+				if err != nil {
+					t.Errorf("AppendFrame failed: %v", err)
+					return
+				}
+				decoded, err := fb.DeserializeFrame(data)
+				if err != nil || decoded.StreamID != streamID || decoded.Payload["prompt"] != frame.Payload["prompt"] {
+					t.Errorf("Expected %s intact, got %s (%v)", streamID, data, err)
+					return
+				}
+				dst = data
+			}
+		}()
+	}
+	wg.Wait()
+}
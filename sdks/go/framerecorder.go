@@ -0,0 +1,369 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// RecordedFrame is one frame handed to a FrameRecorder
+type RecordedFrame struct {
+	Direction AuditDirection `json:"direction"`
+	Time      time.Time      `json:"time"`
+	// ConnectionID identifies the connection the frame went over, e.g.
+	// "conn-3" for the third connection the client opened, or "http" with
+	// the HTTP transport
+	ConnectionID string `json:"connection_id"`
+	Frame        Frame  `json:"frame"`
+	// Error is set, and Frame left empty, for a received message that could
+	// not be decoded
+	Error string `json:"error,omitempty"`
+}
+
+// FrameRecorder receives every frame the client writes to or reads from the
+// router, as it crosses the wire: heartbeats and handshakes included,
+// signed and encrypted as sent. It is called from the client's read and
+// write loops, so it must be safe for concurrent use and should not block.
+type FrameRecorder interface {
+	RecordFrame(record RecordedFrame)
+}
+
+// FrameRecorderFunc adapts a function to the FrameRecorder interface
+type FrameRecorderFunc func(record RecordedFrame)
+
+// RecordFrame calls f(record)
+func (f FrameRecorderFunc) RecordFrame(record RecordedFrame) {
+	f(record)
+}
+
+// recordingTransport is a Transport handing every message it carries to
+// the client's recorder
+type recordingTransport struct {
+	Transport
+	client *ATPClient
+	id     string
+}
+
+// recordTransport wraps conn, a new connection, to record its frames when
+// SDKConfig.Recorder is set
+func (c *ATPClient) recordTransport(conn Transport) Transport {
+	if c.config.Recorder == nil {
+		return conn
+	}
+	return &recordingTransport{Transport: conn, client: c, id: fmt.Sprintf("conn-%d", c.connSeq.Add(1))}
+}
+
+func (t *recordingTransport) Send(data []byte) error {
+	return t.send(data, false)
+}
+
+func (t *recordingTransport) SendBinary(data []byte) error {
+	return t.send(data, true)
+}
+
+func (t *recordingTransport) send(data []byte, binary bool) error {
+	if err := sendMessage(t.Transport, data, binary); err != nil {
+		return err
+	}
+	t.client.record(AuditOutbound, t.id, data, binary)
+	return nil
+}
+
+func (t *recordingTransport) Receive() ([]byte, error) {
+	data, _, err := t.ReceiveMessage()
+	return data, err
+}
+
+func (t *recordingTransport) ReceiveMessage() ([]byte, bool, error) {
+	data, binary, err := receiveMessage(t.Transport)
+	if err == nil {
+		t.client.record(AuditInbound, t.id, data, binary)
+	}
+	return data, binary, err
+}
+
+// record decodes a message sent or received over connection id and hands
+// it to the recorder. JSON payloads are kept as they were on the wire.
+func (c *ATPClient) record(direction AuditDirection, id string, data []byte, binary bool) {
+	record := RecordedFrame{Direction: direction, Time: c.config.Clock.Now(), ConnectionID: id}
+	var err error
+	switch {
+	case !binary:
+		record.Frame, err = c.builder.DeserializeFrameRaw(data)
+	case c.config.Codec != nil:
+		err = c.config.Codec.Unmarshal(data, &record.Frame)
+	default:
+		err = ErrCodecRequired
+	}
+	if err != nil {
+		record = RecordedFrame{Direction: direction, Time: record.Time, ConnectionID: id, Error: err.Error()}
+	}
+	c.config.Recorder.RecordFrame(record)
+}
+
+// recordFrame hands a frame exchanged over the HTTP transport to the
+// recorder
+func (c *ATPClient) recordFrame(direction AuditDirection, frame Frame) {
+	if c.config.Recorder != nil {
+		c.config.Recorder.RecordFrame(RecordedFrame{Direction: direction, Time: c.config.Clock.Now(), ConnectionID: "http", Frame: frame})
+	}
+}
+
+// defaultRecordingBytes is the default of FileRecorderOptions.MaxBytes
+const defaultRecordingBytes = 64 << 20
+
+// FileRecorderOptions configures a FileRecorder
+type FileRecorderOptions struct {
+	// MaxBytes is the size at which the file is rotated (default: 64 MiB)
+	MaxBytes int64
+	// MaxFiles is the number of rotated files kept next to the current one,
+	// named path.1 (the most recent) to path.MaxFiles. Zero keeps none.
+	MaxFiles int
+}
+
+// FileRecorder is a FrameRecorder writing records to a file as
+// newline-delimited JSON, for ReplayRecording or offline inspection.
+// Credentials (api_key, bearer_token, session_token and authorization
+// fields at any depth of a payload, and the whole payload of reauth frames)
+// are written as "[REDACTED]".
+type FileRecorder struct {
+	path    string
+	options FileRecorderOptions
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	err  error // first write error, returned by Close
+}
+
+// NewFileRecorder opens path for recording, appending to it if it exists
+func NewFileRecorder(path string, options FileRecorderOptions) (*FileRecorder, error) {
+	if options.MaxBytes <= 0 {
+		options.MaxBytes = defaultRecordingBytes
+	}
+	r := &FileRecorder{path: path, options: options}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the recording file for appending. The caller holds mu, or
+// owns r.
+func (r *FileRecorder) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+// RecordFrame appends record to the file, rotating it first when the
+// record would take it over MaxBytes. Write errors are returned by Close.
+func (r *FileRecorder) RecordFrame(record RecordedFrame) {
+	record.Frame = redactFrame(record.Frame)
+	line, err := json.Marshal(record)
+	if err != nil {
+		r.fail(fmt.Errorf("failed to encode %s frame: %w", record.Frame.Type, err))
+		return
+	}
+	line = append(line, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return
+	}
+	if r.size > 0 && r.size+int64(len(line)) > r.options.MaxBytes {
+		if err := r.rotate(); err != nil {
+			r.failLocked(err)
+			return
+		}
+	}
+	// Unbuffered, so records survive a crash, when they matter most
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	if err != nil {
+		r.failLocked(fmt.Errorf("failed to write recording: %w", err))
+	}
+}
+
+// rotate moves the current file to path.1, shifting older ones, and starts
+// a new one. The caller holds mu.
+func (r *FileRecorder) rotate() error {
+	if err := r.closeLocked(); err != nil {
+		return err
+	}
+	if r.options.MaxFiles > 0 {
+		for i := r.options.MaxFiles - 1; i > 0; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return fmt.Errorf("failed to rotate recording: %w", err)
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return fmt.Errorf("failed to rotate recording: %w", err)
+	}
+	return r.open()
+}
+
+// fail remembers the first error of the recorder
+func (r *FileRecorder) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failLocked(err)
+}
+
+func (r *FileRecorder) failLocked(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// closeLocked closes the current file. The caller holds mu.
+func (r *FileRecorder) closeLocked() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Close closes the file. It returns the first error met while recording,
+// if any. Records handed to a closed recorder are dropped.
+func (r *FileRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return errors.Join(r.err, r.closeLocked())
+}
+
+// redactedKeys are the payload fields FileRecorder redacts
+var redactedKeys = map[string]bool{
+	"api_key":       true,
+	"bearer_token":  true,
+	"session_token": true,
+	"authorization": true,
+}
+
+// redactFrame returns frame with its credentials replaced by "[REDACTED]",
+// leaving the maps of frame untouched
+func redactFrame(frame Frame) Frame {
+	if frame.Payload == nil && frame.RawPayload == nil {
+		return frame
+	}
+	if frame.Payload == nil {
+		// Only decode raw payloads that may hold a credential
+		mayHold := frame.Type == FrameTypeReauth
+		for key := range redactedKeys {
+			mayHold = mayHold || bytes.Contains(frame.RawPayload, []byte(`"`+key+`"`))
+		}
+		if !mayHold {
+			return frame
+		}
+		if _, err := frame.PayloadMap(); err != nil {
+			return frame
+		}
+	}
+	if frame.Type == FrameTypeReauth {
+		redacted := make(map[string]interface{}, len(frame.Payload))
+		for key := range frame.Payload {
+			redacted[key] = "[REDACTED]"
+		}
+		frame.Payload = redacted
+		return frame
+	}
+	if payload, ok := redactValue(frame.Payload).(map[string]interface{}); ok {
+		frame.Payload = payload
+	}
+	return frame
+}
+
+// redactValue returns a copy of value with the redactedKeys of every
+// object in it redacted
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, member := range v {
+			if redactedKeys[key] {
+				copied[key] = "[REDACTED]"
+			} else {
+				copied[key] = redactValue(member)
+			}
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = redactValue(item)
+		}
+		return copied
+	}
+	return value
+}
+
+// replaySkipped are the frame types ReplayRecording does not resend, as the
+// client sends its own
+var replaySkipped = map[string]bool{
+	FrameTypeHeartbeat:          true,
+	FrameTypeSessionHello:       true,
+	FrameTypeSessionResume:      true,
+	FrameTypeStreamResume:       true,
+	FrameTypeAck:                true,
+	FrameTypeReauth:             true,
+	FrameTypeIntrospectResponse: true,
+}
+
+// ReplayRecording resends the outbound frames recorded in the file at path,
+// as written by a FileRecorder, through client, in their recorded order, to
+// reproduce a session against another router. Frames the client sends on
+// its own, such as heartbeats and handshakes, are skipped. Frames are
+// resent as recorded, then pass through the client's interceptors and
+// signing like any other; redacted values stay redacted. client must be
+// connected.
+func ReplayRecording(path string, client *ATPClient) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	for n := 1; ; n++ {
+		var record struct {
+			Direction AuditDirection  `json:"direction"`
+			Frame     json.RawMessage `json:"frame"`
+		}
+		if err := decoder.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read record %d: %w", n, err)
+		}
+		if record.Direction != AuditOutbound || record.Frame == nil {
+			continue
+		}
+		frame, err := client.builder.DeserializeFrameRaw(record.Frame)
+		if err != nil {
+			return fmt.Errorf("failed to read record %d: %w", n, err)
+		}
+		if replaySkipped[frame.Type] {
+			continue
+		}
+		frame.Signature = ""
+		if err := client.sendFrame(frame); err != nil {
+			return fmt.Errorf("failed to replay record %d (%s frame): %w", n, frame.Type, err)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package atpsdk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collectingRecorder keeps every record it is handed
+type collectingRecorder struct {
+	mu      sync.Mutex
+	records []RecordedFrame
+}
+
+func (r *collectingRecorder) RecordFrame(record RecordedFrame) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+}
+
+func (r *collectingRecorder) find(direction AuditDirection, frameType string) (RecordedFrame, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, record := range r.records {
+		if record.Direction == direction && record.Frame.Type == frameType {
+			return record, true
+		}
+	}
+	return RecordedFrame{}, false
+}
+
+func TestRecorderSeesWireFrames(t *testing.T) {
+	for _, codec := range []FrameCodec{nil, GzipCodec{}} {
+		router := completionRouter(t, codec)
+		recorder := &collectingRecorder{}
+		client := NewATPClient(SDKConfig{WSURL: router.URL(), Codec: codec, Recorder: recorder})
+
+		if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "recorded"}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		sent, ok := recorder.find(AuditOutbound, FrameTypeCompletionRequest)
+		if !ok || sent.ConnectionID != "conn-1" || sent.Time.IsZero() {
+			t.Errorf("Expected the request recorded on conn-1, got %+v", sent)
+		}
+		if payload, _ := sent.Frame.PayloadMap(); payload["prompt"] != "recorded" {
+			t.Errorf("Expected the recorded payload, got %v", payload)
+		}
+		if received, ok := recorder.find(AuditInbound, FrameTypeCompletionResponse); !ok || received.ConnectionID != "conn-1" {
+			t.Errorf("Expected the response recorded on conn-1, got %+v", received)
+		}
+		client.Close()
+		router.Close()
+	}
+}
+
+func TestFileRecorderRedactsAndRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "frames.ndjson")
+	recorder, err := NewFileRecorder(path, FileRecorderOptions{MaxBytes: 1024, MaxFiles: 1})
+	if err != nil {
+		t.Fatalf("NewFileRecorder failed: %v", err)
+	}
+
+	fb := NewFrameBuilder("s1", "t1")
+	reauth := fb.BuildReauthFrame("s", Credentials{APIKey: "secret-key"})
+	hello := fb.BuildSessionHelloFrame("s", map[string]interface{}{"nested": map[string]interface{}{"session_token": "secret-token"}})
+	raw, _ := fb.DeserializeFrameRaw([]byte(`{"type":"event","ts":1,"payload":{"list":[{"api_key":"secret-raw"}]}}`))
+	for _, frame := range []Frame{reauth, hello, raw} {
+		recorder.RecordFrame(RecordedFrame{Direction: AuditOutbound, Time: time.Now(), ConnectionID: "conn-1", Frame: frame})
+	}
+	if hello.Payload["nested"].(map[string]interface{})["session_token"] != "secret-token" {
+		t.Error("Expected the recorded frame's payload left untouched")
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") || strings.Count(string(data), "[REDACTED]") != 3 {
+		t.Errorf("Expected every credential redacted, got %s", data)
+	}
+
+	for i := 0; i < 20; i++ {
+		recorder.RecordFrame(RecordedFrame{Direction: AuditInbound, Frame: fb.BuildHeartbeatFrame()})
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	for _, name := range []string{path, path + ".1"} {
+		if info, err := os.Stat(name); err != nil || info.Size() > 1024 {
+			t.Errorf("Expected %s within 1024 bytes, got %v (%v)", name, info, err)
+		}
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("Expected a single rotated file kept, got %v", err)
+	}
+}
+
+func TestReplayRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "frames.ndjson")
+	recorder, err := NewFileRecorder(path, FileRecorderOptions{})
+	if err != nil {
+		t.Fatalf("NewFileRecorder failed: %v", err)
+	}
+	router := completionRouter(t, nil)
+	defer router.Close()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Recorder: recorder, HeartbeatInterval: 10 * time.Millisecond})
+	for _, prompt := range []string{"first", "second"} {
+		if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: prompt}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+	}
+	time.Sleep(30 * time.Millisecond)
+	client.Close()
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var replayed []string
+	staging := newTestRouter(t, func(f Frame) []Frame {
+		mu.Lock()
+		defer mu.Unlock()
+		replayed = append(replayed, f.Type+":"+getString(f.Payload, "prompt", ""))
+		return nil
+	})
+	defer staging.Close()
+	replayer := NewATPClient(SDKConfig{WSURL: staging.URL(), HeartbeatInterval: time.Hour})
+	defer replayer.Close()
+	if err := replayer.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := ReplayRecording(path, replayer); err != nil {
+		t.Fatalf("ReplayRecording failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		got := strings.Join(replayed, ",")
+		mu.Unlock()
+		if got == "completion_request:first,completion_request:second" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected both requests replayed without heartbeats, got %s", got)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
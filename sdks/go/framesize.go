@@ -0,0 +1,44 @@
+package atpsdk
+
+import "fmt"
+
+// defaultMaxFrameBytes is the default of SDKConfig.MaxFrameBytes
+const defaultMaxFrameBytes = 16 << 20
+
+// FrameTooLargeError is returned for an outgoing frame whose encoded size
+// exceeds the frame size limit. It matches ErrFrameTooLarge.
+type FrameTooLargeError struct {
+	FrameType string
+	// Size is the encoded size of the frame, and Limit the largest size
+	// allowed, in bytes
+	Size  int
+	Limit int
+}
+
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("atpsdk: %s frame is %d bytes, over the %d byte limit; split long prompts with WithChunking or compress frames with a Codec such as GzipCodec",
+		e.FrameType, e.Size, e.Limit)
+}
+
+func (e *FrameTooLargeError) Is(target error) bool {
+	return target == ErrFrameTooLarge
+}
+
+// maxFrameBytes is the size limit of outgoing frames: the router's, from
+// its session welcome, else SDKConfig.MaxFrameBytes. It is zero when there
+// is no limit.
+func (c *ATPClient) maxFrameBytes() int {
+	if welcome := c.welcome.Load(); welcome != nil && welcome.MaxFrameBytes > 0 {
+		return welcome.MaxFrameBytes
+	}
+	return max(c.config.MaxFrameBytes, 0)
+}
+
+// checkFrameSize fails with a *FrameTooLargeError when frame, encoded as
+// data, is over the size limit
+func (c *ATPClient) checkFrameSize(frame *Frame, data []byte) error {
+	if limit := c.maxFrameBytes(); limit > 0 && len(data) > limit {
+		return &FrameTooLargeError{FrameType: frame.Type, Size: len(data), Limit: limit}
+	}
+	return nil
+}
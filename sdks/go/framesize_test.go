@@ -0,0 +1,71 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxFrameBytesOutgoing(t *testing.T) {
+	requests := make(chan Frame, 1)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type == FrameTypeCompletionRequest {
+			requests <- f
+		}
+		return nil
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), MaxFrameBytes: 1024})
+	defer client.Close()
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: strings.Repeat("x", 2000)})
+	var tooLarge *FrameTooLargeError
+	if !errors.Is(err, ErrFrameTooLarge) || !errors.As(err, &tooLarge) {
+		t.Fatalf("Expected ErrFrameTooLarge, got %v", err)
+	}
+	if tooLarge.Size <= 2000 || tooLarge.Limit != 1024 || tooLarge.FrameType != FrameTypeCompletionRequest {
+		t.Errorf("Unexpected error details %+v", tooLarge)
+	}
+	if !strings.Contains(err.Error(), "WithChunking") {
+		t.Errorf("Expected the error to hint at chunking, got %v", err)
+	}
+	select {
+	case <-requests:
+		t.Error("Expected the oversized frame not to be sent")
+	default:
+	}
+
+	// The router's limit from its welcome takes over
+	client.welcome.Store(&SessionWelcome{MaxFrameBytes: 4096})
+	if err := client.checkFrameSize(&Frame{}, make([]byte, 2048)); err != nil {
+		t.Errorf("Expected the welcome's limit to apply, got %v", err)
+	}
+	unlimited := NewATPClient(SDKConfig{MaxFrameBytes: -1})
+	defer unlimited.Close()
+	if err := unlimited.checkFrameSize(&Frame{}, make([]byte, 32<<20)); err != nil {
+		t.Errorf("Expected a negative MaxFrameBytes to remove the limit, got %v", err)
+	}
+}
+
+func TestMaxFrameBytesIncoming(t *testing.T) {
+	router := newTestRouter(t, nil)
+	disconnects := make(chan error, 1)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), MaxFrameBytes: 1024, OnDisconnect: func(err error) { disconnects <- err }})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	if err := router.Send(Frame{Type: "test.event", Payload: map[string]interface{}{"blob": strings.Repeat("x", 2000)}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case err := <-disconnects:
+		if !errors.Is(err, ErrFrameTooLarge) {
+			t.Errorf("Expected the connection to close with ErrFrameTooLarge, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the oversized frame to close the connection")
+	}
+}
@@ -0,0 +1,236 @@
+package atpsdk
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// FrameDecoder reads frames one at a time from a stream, such as captured
+// traffic or a chunked HTTP response, without loading all of it. Without a
+// codec the stream holds JSON frames, one per line or simply concatenated;
+// with one, each frame is encoded with the codec and preceded by its size
+// as a 4-byte big-endian integer. FrameEncoder writes both forms.
+//
+// A FrameDecoder is not safe for concurrent use.
+type FrameDecoder struct {
+	r        *bufio.Reader
+	codec    FrameCodec
+	maxBytes int
+	buf      []byte // JSON frame being read, reused across frames
+	err      error  // sticky read error
+}
+
+// NewFrameDecoder returns a decoder reading frames from r, encoded with
+// codec, or as JSON when codec is nil. Frames over 16 MiB are rejected
+// unless SetMaxFrameBytes says otherwise.
+func NewFrameDecoder(r io.Reader, codec FrameCodec) *FrameDecoder {
+	return &FrameDecoder{r: bufio.NewReader(r), codec: codec, maxBytes: defaultMaxFrameBytes}
+}
+
+// SetMaxFrameBytes sets the size of the largest frame Next accepts. Zero or
+// less removes the limit.
+func (d *FrameDecoder) SetMaxFrameBytes(n int) {
+	d.maxBytes = n
+}
+
+// Next returns the next frame of the stream, or io.EOF once the stream ends
+// between two frames. A stream ending within a frame fails with
+// io.ErrUnexpectedEOF. A frame over the size limit is skipped and fails
+// with an error matching ErrFrameTooLarge; a frame that cannot be decoded
+// fails too. Next may be called again after either to read the frames
+// that follow.
+func (d *FrameDecoder) Next() (Frame, error) {
+	if d.err != nil {
+		return Frame{}, d.err
+	}
+	var (
+		data []byte
+		err  error
+	)
+	if d.codec != nil {
+		data, err = d.readPrefixed()
+	} else {
+		data, err = d.readJSON()
+	}
+	if err != nil {
+		if !errors.Is(err, ErrFrameTooLarge) {
+			d.err = err
+		}
+		return Frame{}, err
+	}
+
+	var frame Frame
+	if d.codec != nil {
+		err = d.codec.Unmarshal(data, &frame)
+	} else {
+		err = json.Unmarshal(data, &frame)
+	}
+	if err != nil {
+		return Frame{}, fmt.Errorf("failed to decode frame: %w", err)
+	}
+	normalizeVersion(&frame)
+	return frame, nil
+}
+
+// tooLarge is the error of a frame of size bytes over the limit
+func (d *FrameDecoder) tooLarge(size int64) error {
+	return fmt.Errorf("%w: frame of %d bytes or more, over the %d byte limit", ErrFrameTooLarge, size, d.maxBytes)
+}
+
+// readPrefixed reads one length-prefixed frame
+func (d *FrameDecoder) readPrefixed() ([]byte, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(d.r, prefix[:]); err != nil {
+		return nil, err
+	}
+	size := int64(binary.BigEndian.Uint32(prefix[:]))
+	if d.maxBytes > 0 && size > int64(d.maxBytes) {
+		if _, err := io.CopyN(io.Discard, d.r, size); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return nil, d.tooLarge(size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return data, nil
+}
+
+// readJSON reads one JSON object, skipping the whitespace before it. It
+// tracks strings and nesting to find where the object ends, so frames need
+// not be newline-delimited.
+func (d *FrameDecoder) readJSON() ([]byte, error) {
+	first, err := d.skipSpace()
+	if err != nil {
+		return nil, err
+	}
+	if first != '{' {
+		return nil, fmt.Errorf("failed to decode frame: expected '{', found %q", first)
+	}
+
+	d.buf = append(d.buf[:0], first)
+	size, depth := int64(1), 1
+	inString, escaped := false, false
+	for depth > 0 {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		size++
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			escaped = c == '\\'
+			inString = c != '"'
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		}
+		// Past the limit, keep scanning without keeping the bytes, to
+		// resume after the frame
+		if d.maxBytes <= 0 || size <= int64(d.maxBytes) {
+			d.buf = append(d.buf, c)
+		}
+	}
+	if d.maxBytes > 0 && size > int64(d.maxBytes) {
+		return nil, d.tooLarge(size)
+	}
+	return d.buf, nil
+}
+
+// skipSpace returns the first byte that is not JSON whitespace
+func (d *FrameDecoder) skipSpace() (byte, error) {
+	for {
+		c, err := d.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return c, nil
+		}
+	}
+}
+
+// unexpectedEOF turns the end of the stream within a frame into
+// io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// FrameEncoder writes frames to a stream in the form FrameDecoder reads:
+// newline-delimited JSON without a codec, length-prefixed frames encoded
+// with the codec otherwise. It is not safe for concurrent use.
+type FrameEncoder struct {
+	w        io.Writer
+	codec    FrameCodec
+	maxBytes int
+	buf      []byte // frame being written, reused across frames
+}
+
+// NewFrameEncoder returns an encoder writing frames to w, encoded with
+// codec, or as JSON when codec is nil. Frames over 16 MiB are rejected
+// unless SetMaxFrameBytes says otherwise.
+func NewFrameEncoder(w io.Writer, codec FrameCodec) *FrameEncoder {
+	return &FrameEncoder{w: w, codec: codec, maxBytes: defaultMaxFrameBytes}
+}
+
+// SetMaxFrameBytes sets the size of the largest frame Encode writes. Zero
+// or less removes the limit.
+func (e *FrameEncoder) SetMaxFrameBytes(n int) {
+	e.maxBytes = n
+}
+
+// Encode writes frame to the stream. A frame over the size limit is not
+// written and fails with a *FrameTooLargeError.
+func (e *FrameEncoder) Encode(frame Frame) error {
+	if e.codec == nil {
+		data, err := appendFrame(e.buf[:0], &frame)
+		if err != nil {
+			return fmt.Errorf("failed to marshal frame: %w", err)
+		}
+		if err := e.checkSize(&frame, len(data)); err != nil {
+			return err
+		}
+		e.buf = append(data, '\n')
+		_, err = e.w.Write(e.buf)
+		return err
+	}
+
+	data, err := e.codec.Marshal(&frame)
+	if err != nil {
+		return fmt.Errorf("failed to encode frame: %w", err)
+	}
+	if err := e.checkSize(&frame, len(data)); err != nil {
+		return err
+	}
+	if uint64(len(data)) > math.MaxUint32 {
+		return fmt.Errorf("%w: %s frame of %d bytes does not fit its length prefix", ErrFrameTooLarge, frame.Type, len(data))
+	}
+	e.buf = binary.BigEndian.AppendUint32(e.buf[:0], uint32(len(data)))
+	e.buf = append(e.buf, data...)
+	_, err = e.w.Write(e.buf)
+	return err
+}
+
+// checkSize fails for a frame of size bytes over the limit
+func (e *FrameEncoder) checkSize(frame *Frame, size int) error {
+	if e.maxBytes > 0 && size > e.maxBytes {
+		return &FrameTooLargeError{FrameType: frame.Type, Size: size, Limit: e.maxBytes}
+	}
+	return nil
+}
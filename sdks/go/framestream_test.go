@@ -0,0 +1,110 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestFrameEncoderDecoderRoundTrip(t *testing.T) {
+	fb := NewFrameBuilder("s1", "t1")
+	frames := []Frame{
+		fb.BuildCompletionFrame("a", CompletionRequest{Prompt: `braces } { and "quotes" \ inside`}),
+		fb.BuildHeartbeatFrame(),
+		fb.BuildErrorFrame("a", 1, ErrorCodeRateLimited, "slow down"),
+	}
+	for _, codec := range []FrameCodec{nil, GzipCodec{}} {
+		var stream bytes.Buffer
+		encoder := NewFrameEncoder(&stream, codec)
+		for _, frame := range frames {
+			if err := encoder.Encode(frame); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+		}
+		if codec == nil && strings.Count(stream.String(), "\n") != len(frames) {
+			t.Errorf("Expected one line per frame, got %q", stream.String())
+		}
+
+		decoder := NewFrameDecoder(&stream, codec)
+		for i, want := range frames {
+			got, err := decoder.Next()
+			if err != nil {
+				t.Fatalf("Next %d failed: %v", i, err)
+			}
+			if got.Type != want.Type || got.StreamID != want.StreamID || got.Payload["prompt"] != want.Payload["prompt"] {
+				t.Errorf("Frame %d: expected %+v, got %+v", i, want, got)
+			}
+		}
+		if _, err := decoder.Next(); err != io.EOF {
+			t.Errorf("Expected io.EOF at the end, got %v", err)
+		}
+	}
+}
+
+func TestFrameDecoderConcatenatedJSON(t *testing.T) {
+	stream := ` {"type":"a","ts":1,"payload":{"s":"}{\\\""}}{"type":"b","ts":2}` + "\r\n\t" + `{"type":"c","ts":3,"payload":{"list":[{}]}}  `
+	decoder := NewFrameDecoder(strings.NewReader(stream), nil)
+	var types []string
+	for {
+		frame, err := decoder.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		types = append(types, frame.Type)
+	}
+	if strings.Join(types, ",") != "a,b,c" {
+		t.Errorf("Expected frames a, b and c, got %v", types)
+	}
+
+	truncated := NewFrameDecoder(strings.NewReader(`{"type":"a","ts":1}{"type":`), nil)
+	if _, err := truncated.Next(); err != nil {
+		t.Fatalf("Next failed: %v", err)
+	}
+	if _, err := truncated.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Expected io.ErrUnexpectedEOF for a truncated frame, got %v", err)
+	}
+}
+
+func TestFrameDecoderSkipsLargeFrames(t *testing.T) {
+	fb := NewFrameBuilder("s1", "t1")
+	// Random text, so that compression keeps it large
+	noise := make([]byte, 2048)
+	_, _ = rand.NewChaCha8([32]byte{}).Read(noise)
+	large := hex.EncodeToString(noise)
+
+	for _, codec := range []FrameCodec{nil, GzipCodec{}} {
+		var stream bytes.Buffer
+		encoder := NewFrameEncoder(&stream, codec)
+		for _, prompt := range []string{"small", large, "after"} {
+			if err := encoder.Encode(fb.BuildCompletionFrame("s", CompletionRequest{Prompt: prompt})); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+		}
+
+		decoder := NewFrameDecoder(&stream, codec)
+		decoder.SetMaxFrameBytes(2048)
+		if frame, err := decoder.Next(); err != nil || frame.Payload["prompt"] != "small" {
+			t.Fatalf("Expected the small frame, got %+v (%v)", frame, err)
+		}
+		if _, err := decoder.Next(); !errors.Is(err, ErrFrameTooLarge) {
+			t.Errorf("Expected ErrFrameTooLarge, got %v", err)
+		}
+		if frame, err := decoder.Next(); err != nil || frame.Payload["prompt"] != "after" {
+			t.Errorf("Expected the frame after the large one, got %+v (%v)", frame, err)
+		}
+	}
+
+	encoder := NewFrameEncoder(io.Discard, nil)
+	encoder.SetMaxFrameBytes(100)
+	var tooLarge *FrameTooLargeError
+	if err := encoder.Encode(fb.BuildCompletionFrame("s", CompletionRequest{Prompt: "p"})); !errors.As(err, &tooLarge) {
+		t.Errorf("Expected a *FrameTooLargeError, got %v", err)
+	}
+}
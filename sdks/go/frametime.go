@@ -0,0 +1,92 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FrameTime is the timestamp of a frame, in milliseconds since the Unix
+// epoch, zero when unset. It is written as a number, and read from either a
+// number or an RFC 3339 string, as some router builds send.
+type FrameTime int64
+
+// Plausible frame timestamps fall between these, so that one set in
+// seconds, or in microseconds, is caught
+var (
+	minFrameTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxFrameTime = time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// FrameTimeOf returns the FrameTime of t, truncated to the millisecond
+func FrameTimeOf(t time.Time) FrameTime {
+	return FrameTime(t.UnixMilli())
+}
+
+// Time returns t as a time.Time, or the zero time when t is unset
+func (t FrameTime) Time() time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(t))
+}
+
+// Validate fails with an error matching ErrInvalidTimestamp for a set
+// timestamp before the year 2000 or after the year 3000, most likely given
+// in the wrong unit
+func (t FrameTime) Validate() error {
+	if t == 0 {
+		return nil
+	}
+	if at := t.Time(); at.Before(minFrameTime) || !at.Before(maxFrameTime) {
+		return fmt.Errorf("%w: %d is %s; timestamps are in milliseconds", ErrInvalidTimestamp, int64(t), at.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// UnmarshalJSON reads a timestamp in milliseconds or an RFC 3339 string
+func (t *FrameTime) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		at, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return fmt.Errorf("invalid frame timestamp %q: %w", s, err)
+		}
+		*t = FrameTimeOf(at)
+		return nil
+	}
+	var ms int64
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return fmt.Errorf("invalid frame timestamp %s: %w", data, err)
+	}
+	*t = FrameTime(ms)
+	return nil
+}
+
+// Time returns the frame's timestamp, or the zero time when it is unset
+func (f *Frame) Time() time.Time {
+	return f.Timestamp.Time()
+}
+
+// SetTime sets the frame's timestamp to t, truncated to the millisecond
+func (f *Frame) SetTime(t time.Time) {
+	f.Timestamp = FrameTimeOf(t)
+}
+
+// checkTimestamp warns about a frame whose timestamp is implausible, see
+// FrameTime.Validate. An incoming frame's is then cleared, so that it is
+// used neither for its TTL nor for ClockSkew.
+func (c *ATPClient) checkTimestamp(direction AuditDirection, frame *Frame) {
+	err := frame.Timestamp.Validate()
+	if err == nil {
+		return
+	}
+	c.config.Logger.Printf("Warning: %s %s frame on stream %q: %v", direction, frame.Type, frame.StreamID, err)
+	if direction == AuditInbound {
+		frame.Timestamp = 0
+	}
+}
@@ -0,0 +1,80 @@
+package atpsdk
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrameTimeRoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 14, 15, 9, 26, 535_897_932, time.UTC)
+	var frame Frame
+	frame.SetTime(at)
+	if want := at.Truncate(time.Millisecond); !frame.Time().Equal(want) {
+		t.Errorf("Expected %v, got %v", want, frame.Time())
+	}
+
+	data, err := json.Marshal(frame)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"ts":1773500966535`) {
+		t.Errorf("Expected the timestamp in milliseconds, got %s", data)
+	}
+	var decoded Frame
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Timestamp != frame.Timestamp {
+		t.Errorf("Expected %d after a round trip, got %d", frame.Timestamp, decoded.Timestamp)
+	}
+	if !(&Frame{}).Time().IsZero() {
+		t.Error("Expected the zero time for an unset timestamp")
+	}
+}
+
+func TestFrameTimeUnmarshalRFC3339(t *testing.T) {
+	var frame Frame
+	if err := json.Unmarshal([]byte(`{"type":"heartbeat","ts":"2026-03-14T16:09:26.535+01:00","payload":{}}`), &frame); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if frame.Timestamp != 1773500966535 {
+		t.Errorf("Expected the string read in milliseconds, got %d", frame.Timestamp)
+	}
+	for _, data := range []string{`{"ts":"yesterday"}`, `{"ts":true}`} {
+		if err := json.Unmarshal([]byte(data), &frame); err == nil {
+			t.Errorf("Expected %s rejected", data)
+		}
+	}
+}
+
+func TestFrameTimeValidate(t *testing.T) {
+	for _, ts := range []FrameTime{0, FrameTimeOf(time.Now())} {
+		if err := ts.Validate(); err != nil {
+			t.Errorf("Expected %d valid, got %v", ts, err)
+		}
+	}
+	// Seconds, and microseconds, given for milliseconds
+	for _, ts := range []FrameTime{FrameTime(time.Now().Unix()), FrameTime(time.Now().UnixMicro())} {
+		if err := ts.Validate(); !errors.Is(err, ErrInvalidTimestamp) {
+			t.Errorf("Expected %d rejected with ErrInvalidTimestamp, got %v", ts, err)
+		}
+	}
+}
+
+func TestImplausibleIncomingTimestamp(t *testing.T) {
+	logger := &recordingLogger{}
+	client := NewATPClient(SDKConfig{Logger: logger})
+	defer client.Close()
+
+	frame := Frame{Type: FrameTypeHeartbeatAck, Timestamp: FrameTime(time.Now().Unix()), TTL: 5}
+	client.checkTimestamp(AuditInbound, &frame)
+	if frame.Timestamp != 0 || client.dropExpired(&frame) {
+		t.Errorf("Expected the timestamp ignored, got %d", frame.Timestamp)
+	}
+	if lines := logger.Lines(); len(lines) != 1 || !strings.Contains(lines[0], "implausible frame timestamp") {
+		t.Errorf("Expected a warning, got %q", lines)
+	}
+}
@@ -0,0 +1,458 @@
+// ATP frames over gRPC. The messages mirror atpsdk.Frame field for field.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        v5.28.3
+// source: frame.proto
+
+package atppb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Frame struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Ts            int64                  `protobuf:"varint,2,opt,name=ts,proto3" json:"ts,omitempty"`
+	StreamId      string                 `protobuf:"bytes,3,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	MsgSeq        int32                  `protobuf:"varint,4,opt,name=msg_seq,json=msgSeq,proto3" json:"msg_seq,omitempty"`
+	FragSeq       int32                  `protobuf:"varint,5,opt,name=frag_seq,json=fragSeq,proto3" json:"frag_seq,omitempty"`
+	Flags         []string               `protobuf:"bytes,6,rep,name=flags,proto3" json:"flags,omitempty"`
+	Qos           string                 `protobuf:"bytes,7,opt,name=qos,proto3" json:"qos,omitempty"`
+	Ttl           int32                  `protobuf:"varint,8,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Window        *Window                `protobuf:"bytes,9,opt,name=window,proto3" json:"window,omitempty"`
+	Meta          *Meta                  `protobuf:"bytes,10,opt,name=meta,proto3" json:"meta,omitempty"`
+	Payload       *structpb.Struct       `protobuf:"bytes,11,opt,name=payload,proto3" json:"payload,omitempty"`
+	Sig           string                 `protobuf:"bytes,12,opt,name=sig,proto3" json:"sig,omitempty"`
+	Version       string                 `protobuf:"bytes,13,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Frame) Reset() {
+	*x = Frame{}
+	mi := &file_frame_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Frame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Frame) ProtoMessage() {}
+
+func (x *Frame) ProtoReflect() protoreflect.Message {
+	mi := &file_frame_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Frame.ProtoReflect.Descriptor instead.
+func (*Frame) Descriptor() ([]byte, []int) {
+	return file_frame_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Frame) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Frame) GetTs() int64 {
+	if x != nil {
+		return x.Ts
+	}
+	return 0
+}
+
+func (x *Frame) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
+	}
+	return ""
+}
+
+func (x *Frame) GetMsgSeq() int32 {
+	if x != nil {
+		return x.MsgSeq
+	}
+	return 0
+}
+
+func (x *Frame) GetFragSeq() int32 {
+	if x != nil {
+		return x.FragSeq
+	}
+	return 0
+}
+
+func (x *Frame) GetFlags() []string {
+	if x != nil {
+		return x.Flags
+	}
+	return nil
+}
+
+func (x *Frame) GetQos() string {
+	if x != nil {
+		return x.Qos
+	}
+	return ""
+}
+
+func (x *Frame) GetTtl() int32 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *Frame) GetWindow() *Window {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+func (x *Frame) GetMeta() *Meta {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+func (x *Frame) GetPayload() *structpb.Struct {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Frame) GetSig() string {
+	if x != nil {
+		return x.Sig
+	}
+	return ""
+}
+
+func (x *Frame) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+type Window struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MaxParallel   int32                  `protobuf:"varint,1,opt,name=max_parallel,json=maxParallel,proto3" json:"max_parallel,omitempty"`
+	MaxTokens     int32                  `protobuf:"varint,2,opt,name=max_tokens,json=maxTokens,proto3" json:"max_tokens,omitempty"`
+	MaxUsdMicros  int32                  `protobuf:"varint,3,opt,name=max_usd_micros,json=maxUsdMicros,proto3" json:"max_usd_micros,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Window) Reset() {
+	*x = Window{}
+	mi := &file_frame_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Window) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Window) ProtoMessage() {}
+
+func (x *Window) ProtoReflect() protoreflect.Message {
+	mi := &file_frame_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Window.ProtoReflect.Descriptor instead.
+func (*Window) Descriptor() ([]byte, []int) {
+	return file_frame_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Window) GetMaxParallel() int32 {
+	if x != nil {
+		return x.MaxParallel
+	}
+	return 0
+}
+
+func (x *Window) GetMaxTokens() int32 {
+	if x != nil {
+		return x.MaxTokens
+	}
+	return 0
+}
+
+func (x *Window) GetMaxUsdMicros() int32 {
+	if x != nil {
+		return x.MaxUsdMicros
+	}
+	return 0
+}
+
+type Meta struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	TaskType           string                 `protobuf:"bytes,1,opt,name=task_type,json=taskType,proto3" json:"task_type,omitempty"`
+	Languages          []string               `protobuf:"bytes,2,rep,name=languages,proto3" json:"languages,omitempty"`
+	Risk               string                 `protobuf:"bytes,3,opt,name=risk,proto3" json:"risk,omitempty"`
+	DataScope          []string               `protobuf:"bytes,4,rep,name=data_scope,json=dataScope,proto3" json:"data_scope,omitempty"`
+	Trace              *structpb.Value        `protobuf:"bytes,5,opt,name=trace,proto3" json:"trace,omitempty"`
+	ToolPermissions    []string               `protobuf:"bytes,6,rep,name=tool_permissions,json=toolPermissions,proto3" json:"tool_permissions,omitempty"`
+	EnvironmentId      string                 `protobuf:"bytes,7,opt,name=environment_id,json=environmentId,proto3" json:"environment_id,omitempty"`
+	SecurityGroups     []string               `protobuf:"bytes,8,rep,name=security_groups,json=securityGroups,proto3" json:"security_groups,omitempty"`
+	IdempotencyKey     string                 `protobuf:"bytes,9,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	PreferredModel     string                 `protobuf:"bytes,10,opt,name=preferred_model,json=preferredModel,proto3" json:"preferred_model,omitempty"`
+	PreferredAdapterId string                 `protobuf:"bytes,11,opt,name=preferred_adapter_id,json=preferredAdapterId,proto3" json:"preferred_adapter_id,omitempty"`
+	ExcludeAdapters    []string               `protobuf:"bytes,12,rep,name=exclude_adapters,json=excludeAdapters,proto3" json:"exclude_adapters,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Meta) Reset() {
+	*x = Meta{}
+	mi := &file_frame_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Meta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Meta) ProtoMessage() {}
+
+func (x *Meta) ProtoReflect() protoreflect.Message {
+	mi := &file_frame_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Meta.ProtoReflect.Descriptor instead.
+func (*Meta) Descriptor() ([]byte, []int) {
+	return file_frame_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Meta) GetTaskType() string {
+	if x != nil {
+		return x.TaskType
+	}
+	return ""
+}
+
+func (x *Meta) GetLanguages() []string {
+	if x != nil {
+		return x.Languages
+	}
+	return nil
+}
+
+func (x *Meta) GetRisk() string {
+	if x != nil {
+		return x.Risk
+	}
+	return ""
+}
+
+func (x *Meta) GetDataScope() []string {
+	if x != nil {
+		return x.DataScope
+	}
+	return nil
+}
+
+func (x *Meta) GetTrace() *structpb.Value {
+	if x != nil {
+		return x.Trace
+	}
+	return nil
+}
+
+func (x *Meta) GetToolPermissions() []string {
+	if x != nil {
+		return x.ToolPermissions
+	}
+	return nil
+}
+
+func (x *Meta) GetEnvironmentId() string {
+	if x != nil {
+		return x.EnvironmentId
+	}
+	return ""
+}
+
+func (x *Meta) GetSecurityGroups() []string {
+	if x != nil {
+		return x.SecurityGroups
+	}
+	return nil
+}
+
+func (x *Meta) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *Meta) GetPreferredModel() string {
+	if x != nil {
+		return x.PreferredModel
+	}
+	return ""
+}
+
+func (x *Meta) GetPreferredAdapterId() string {
+	if x != nil {
+		return x.PreferredAdapterId
+	}
+	return ""
+}
+
+func (x *Meta) GetExcludeAdapters() []string {
+	if x != nil {
+		return x.ExcludeAdapters
+	}
+	return nil
+}
+
+var File_frame_proto protoreflect.FileDescriptor
+
+const file_frame_proto_rawDesc = "" +
+	"\n" +
+	"\vframe.proto\x12\x06atp.v1\x1a\x1cgoogle/protobuf/struct.proto\"\xdf\x02\n" +
+	"\x05Frame\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12\x0e\n" +
+	"\x02ts\x18\x02 \x01(\x03R\x02ts\x12\x1b\n" +
+	"\tstream_id\x18\x03 \x01(\tR\bstreamId\x12\x17\n" +
+	"\amsg_seq\x18\x04 \x01(\x05R\x06msgSeq\x12\x19\n" +
+	"\bfrag_seq\x18\x05 \x01(\x05R\afragSeq\x12\x14\n" +
+	"\x05flags\x18\x06 \x03(\tR\x05flags\x12\x10\n" +
+	"\x03qos\x18\a \x01(\tR\x03qos\x12\x10\n" +
+	"\x03ttl\x18\b \x01(\x05R\x03ttl\x12&\n" +
+	"\x06window\x18\t \x01(\v2\x0e.atp.v1.WindowR\x06window\x12 \n" +
+	"\x04meta\x18\n" +
+	" \x01(\v2\f.atp.v1.MetaR\x04meta\x121\n" +
+	"\apayload\x18\v \x01(\v2\x17.google.protobuf.StructR\apayload\x12\x10\n" +
+	"\x03sig\x18\f \x01(\tR\x03sig\x12\x18\n" +
+	"\aversion\x18\r \x01(\tR\aversion\"p\n" +
+	"\x06Window\x12!\n" +
+	"\fmax_parallel\x18\x01 \x01(\x05R\vmaxParallel\x12\x1d\n" +
+	"\n" +
+	"max_tokens\x18\x02 \x01(\x05R\tmaxTokens\x12$\n" +
+	"\x0emax_usd_micros\x18\x03 \x01(\x05R\fmaxUsdMicros\"\xcc\x03\n" +
+	"\x04Meta\x12\x1b\n" +
+	"\ttask_type\x18\x01 \x01(\tR\btaskType\x12\x1c\n" +
+	"\tlanguages\x18\x02 \x03(\tR\tlanguages\x12\x12\n" +
+	"\x04risk\x18\x03 \x01(\tR\x04risk\x12\x1d\n" +
+	"\n" +
+	"data_scope\x18\x04 \x03(\tR\tdataScope\x12,\n" +
+	"\x05trace\x18\x05 \x01(\v2\x16.google.protobuf.ValueR\x05trace\x12)\n" +
+	"\x10tool_permissions\x18\x06 \x03(\tR\x0ftoolPermissions\x12%\n" +
+	"\x0eenvironment_id\x18\a \x01(\tR\renvironmentId\x12'\n" +
+	"\x0fsecurity_groups\x18\b \x03(\tR\x0esecurityGroups\x12'\n" +
+	"\x0fidempotency_key\x18\t \x01(\tR\x0eidempotencyKey\x12'\n" +
+	"\x0fpreferred_model\x18\n" +
+	" \x01(\tR\x0epreferredModel\x120\n" +
+	"\x14preferred_adapter_id\x18\v \x01(\tR\x12preferredAdapterId\x12)\n" +
+	"\x10exclude_adapters\x18\f \x03(\tR\x0fexcludeAdapters2@\n" +
+	"\fFrameService\x120\n" +
+	"\fStreamFrames\x12\r.atp.v1.Frame\x1a\r.atp.v1.Frame(\x010\x01B7Z5github.com/atp-project/atp-go-sdk/grpctransport/atppbb\x06proto3"
+
+var (
+	file_frame_proto_rawDescOnce sync.Once
+	file_frame_proto_rawDescData []byte
+)
+
+func file_frame_proto_rawDescGZIP() []byte {
+	file_frame_proto_rawDescOnce.Do(func() {
+		file_frame_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_frame_proto_rawDesc), len(file_frame_proto_rawDesc)))
+	})
+	return file_frame_proto_rawDescData
+}
+
+var file_frame_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_frame_proto_goTypes = []any{
+	(*Frame)(nil),           // 0: atp.v1.Frame
+	(*Window)(nil),          // 1: atp.v1.Window
+	(*Meta)(nil),            // 2: atp.v1.Meta
+	(*structpb.Struct)(nil), // 3: google.protobuf.Struct
+	(*structpb.Value)(nil),  // 4: google.protobuf.Value
+}
+var file_frame_proto_depIdxs = []int32{
+	1, // 0: atp.v1.Frame.window:type_name -> atp.v1.Window
+	2, // 1: atp.v1.Frame.meta:type_name -> atp.v1.Meta
+	3, // 2: atp.v1.Frame.payload:type_name -> google.protobuf.Struct
+	4, // 3: atp.v1.Meta.trace:type_name -> google.protobuf.Value
+	0, // 4: atp.v1.FrameService.StreamFrames:input_type -> atp.v1.Frame
+	0, // 5: atp.v1.FrameService.StreamFrames:output_type -> atp.v1.Frame
+	5, // [5:6] is the sub-list for method output_type
+	4, // [4:5] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_frame_proto_init() }
+func file_frame_proto_init() {
+	if File_frame_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_frame_proto_rawDesc), len(file_frame_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_frame_proto_goTypes,
+		DependencyIndexes: file_frame_proto_depIdxs,
+		MessageInfos:      file_frame_proto_msgTypes,
+	}.Build()
+	File_frame_proto = out.File
+	file_frame_proto_goTypes = nil
+	file_frame_proto_depIdxs = nil
+}
@@ -0,0 +1,265 @@
+// Package grpctransport adds a gRPC transport to the ATP SDK. Importing it
+// registers the transport under Name:
+//
+//	import "github.com/atp-project/atp-go-sdk/grpctransport"
+//
+//	client := atpsdk.NewATPClient(atpsdk.SDKConfig{
+//		Transport:        grpctransport.Name,
+//		TransportAddress: "grpcs://router.internal:9443",
+//	})
+//
+// Frames travel over the bidirectional FrameService.StreamFrames RPC
+// defined in atppb/frame.proto, whose messages mirror atpsdk.Frame.
+package grpctransport
+
+//go:generate protoc --proto_path=atppb --go_out=atppb --go_opt=paths=source_relative --go-grpc_out=atppb --go-grpc_opt=paths=source_relative frame.proto
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+	"github.com/atp-project/atp-go-sdk/grpctransport/atppb"
+)
+
+// Name is the SDKConfig.Transport value selecting the gRPC transport
+const Name = "grpc"
+
+func init() {
+	atpsdk.RegisterTransport(Name, NewDialFunc())
+}
+
+// NewDialFunc returns a dial function for the gRPC transport. Addresses of
+// the form "grpcs://host:port" use TLS; "grpc://host:port" and bare
+// "host:port" addresses are unencrypted. opts are applied after these
+// defaults, so they may override the credentials. Register the result under
+// another name to use custom options:
+//
+//	atpsdk.RegisterTransport("grpc-mtls", grpctransport.NewDialFunc(grpc.WithTransportCredentials(creds)))
+func NewDialFunc(opts ...grpc.DialOption) atpsdk.DialFunc {
+	return func(ctx context.Context, target atpsdk.DialTarget) (atpsdk.Transport, error) {
+		address, creds := parseAddress(target.Address, target.TLSConfig)
+		options := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+		if target.MaxFrameBytes > 0 {
+			options = append(options, grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(target.MaxFrameBytes)))
+		}
+		conn, err := grpc.NewClient(address, append(options, opts...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		// The stream lives as long as the client, not just the dial
+		streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		md := metadata.Pairs("session_id", target.SessionID, "tenant_id", target.TenantID)
+		md.Set("protocol_versions", target.ProtocolVersions...)
+		if target.BearerToken != "" {
+			md.Set("authorization", "Bearer "+target.BearerToken)
+		} else if target.APIKey != "" {
+			md.Set("authorization", "Bearer "+target.APIKey)
+		}
+		streamCtx = metadata.NewOutgoingContext(streamCtx, md)
+
+		stream, err := atppb.NewFrameServiceClient(conn).StreamFrames(streamCtx)
+		if err != nil {
+			cancel()
+			_ = conn.Close()
+			return nil, fmt.Errorf("%w: %w", atpsdk.ErrConnectionFailed, err)
+		}
+		return &transport{conn: conn, stream: stream, cancel: cancel}, nil
+	}
+}
+
+// parseAddress splits the scheme off address and picks the matching
+// transport credentials. TLS connections use tlsConfig when it is set.
+func parseAddress(address string, tlsConfig *tls.Config) (string, credentials.TransportCredentials) {
+	if rest, ok := strings.CutPrefix(address, "grpcs://"); ok {
+		return rest, credentials.NewTLS(tlsConfig)
+	}
+	return strings.TrimPrefix(address, "grpc://"), insecure.NewCredentials()
+}
+
+// transport is an atpsdk.Transport over one StreamFrames call
+type transport struct {
+	conn      *grpc.ClientConn
+	stream    atppb.FrameService_StreamFramesClient
+	cancel    context.CancelFunc
+	closeOnce sync.Once
+}
+
+func (t *transport) Send(data []byte) error {
+	var frame atpsdk.Frame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return fmt.Errorf("invalid frame: %w", err)
+	}
+	message, err := ToProto(frame)
+	if err != nil {
+		return err
+	}
+	return mapError(t.stream.Send(message))
+}
+
+func (t *transport) Receive() ([]byte, error) {
+	message, err := t.stream.Recv()
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return json.Marshal(FromProto(message))
+}
+
+func (t *transport) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		t.cancel()
+		err = t.conn.Close()
+	})
+	return err
+}
+
+// mapError turns the errors of a broken or closed stream into
+// atpsdk.ErrNotConnected, as the WebSocket transport reports them
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: stream closed by router", atpsdk.ErrNotConnected)
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Canceled:
+		return fmt.Errorf("%w: %w", atpsdk.ErrNotConnected, err)
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return fmt.Errorf("%w: %w", atpsdk.ErrConnectionFailed, err)
+	}
+	return err
+}
+
+// ToProto converts a frame to its protobuf form. It fails if the payload or
+// trace holds values that have no JSON representation.
+func ToProto(frame atpsdk.Frame) (*atppb.Frame, error) {
+	message := &atppb.Frame{
+		Type:     frame.Type,
+		Ts:       int64(frame.Timestamp),
+		StreamId: frame.StreamID,
+		MsgSeq:   int32(frame.MsgSeq),
+		FragSeq:  int32(frame.FragSeq),
+		Flags:    frame.Flags,
+		Qos:      string(frame.QoS),
+		Ttl:      int32(frame.TTL),
+		Sig:      frame.Signature,
+		Version:  frame.Version,
+	}
+	if frame.Window != (atpsdk.Window{}) {
+		message.Window = &atppb.Window{
+			MaxParallel:  int32(frame.Window.MaxParallel),
+			MaxTokens:    int32(frame.Window.MaxTokens),
+			MaxUsdMicros: int32(frame.Window.MaxUSD),
+		}
+	}
+
+	meta := frame.Meta
+	message.Meta = &atppb.Meta{
+		TaskType:        meta.TaskType,
+		Languages:       meta.Languages,
+		Risk:            meta.Risk,
+		DataScope:       meta.DataScope,
+		ToolPermissions: meta.ToolPermissions,
+		EnvironmentId:   meta.EnvironmentID,
+		SecurityGroups:  meta.SecurityGroups,
+		IdempotencyKey:  meta.IdempotencyKey,
+
+		PreferredModel:     meta.PreferredModel,
+		PreferredAdapterId: meta.PreferredAdapterID,
+		ExcludeAdapters:    meta.ExcludeAdapters,
+	}
+	if meta.Trace != nil {
+		trace, err := structpb.NewValue(map[string]interface{}{
+			"traceparent": meta.Trace.TraceParent,
+			"tracestate":  meta.Trace.TraceState,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace: %w", err)
+		}
+		message.Meta.Trace = trace
+	}
+
+	if frame.Payload != nil {
+		payload, err := structpb.NewStruct(frame.Payload)
+		if err != nil {
+			// Typed values such as []string need the JSON round trip
+			// frames from the client have already been through
+			var normalized map[string]interface{}
+			data, jsonErr := json.Marshal(frame.Payload)
+			if jsonErr != nil || json.Unmarshal(data, &normalized) != nil {
+				return nil, fmt.Errorf("invalid payload: %w", err)
+			}
+			if payload, err = structpb.NewStruct(normalized); err != nil {
+				return nil, fmt.Errorf("invalid payload: %w", err)
+			}
+		}
+		message.Payload = payload
+	}
+	return message, nil
+}
+
+// FromProto converts a protobuf frame back to an atpsdk.Frame
+func FromProto(message *atppb.Frame) atpsdk.Frame {
+	frame := atpsdk.Frame{
+		Type:      message.GetType(),
+		Timestamp: atpsdk.FrameTime(message.GetTs()),
+		StreamID:  message.GetStreamId(),
+		MsgSeq:    int(message.GetMsgSeq()),
+		FragSeq:   int(message.GetFragSeq()),
+		Flags:     message.GetFlags(),
+		QoS:       atpsdk.QoS(message.GetQos()),
+		TTL:       int(message.GetTtl()),
+		Signature: message.GetSig(),
+		Version:   message.GetVersion(),
+	}
+	if window := message.GetWindow(); window != nil {
+		frame.Window = atpsdk.Window{
+			MaxParallel: int(window.GetMaxParallel()),
+			MaxTokens:   int(window.GetMaxTokens()),
+			MaxUSD:      int(window.GetMaxUsdMicros()),
+		}
+	}
+	if meta := message.GetMeta(); meta != nil {
+		frame.Meta = atpsdk.Meta{
+			TaskType:        meta.GetTaskType(),
+			Languages:       meta.GetLanguages(),
+			Risk:            meta.GetRisk(),
+			DataScope:       meta.GetDataScope(),
+			ToolPermissions: meta.GetToolPermissions(),
+			EnvironmentID:   meta.GetEnvironmentId(),
+			SecurityGroups:  meta.GetSecurityGroups(),
+			IdempotencyKey:  meta.GetIdempotencyKey(),
+
+			PreferredModel:     meta.GetPreferredModel(),
+			PreferredAdapterID: meta.GetPreferredAdapterId(),
+			ExcludeAdapters:    meta.GetExcludeAdapters(),
+		}
+		if fields := meta.GetTrace().GetStructValue().GetFields(); fields != nil {
+			frame.Meta.Trace = &atpsdk.TraceContext{
+				TraceParent: fields["traceparent"].GetStringValue(),
+				TraceState:  fields["tracestate"].GetStringValue(),
+			}
+		}
+	}
+	if payload := message.GetPayload(); payload != nil {
+		frame.Payload = payload.AsMap()
+	}
+	return frame
+}
@@ -0,0 +1,234 @@
+package grpctransport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	atpsdk "github.com/atp-project/atp-go-sdk"
+	"github.com/atp-project/atp-go-sdk/grpctransport/atppb"
+)
+
+// testRouter is an in-process gRPC FrameService answering completion
+// requests and counting heartbeats
+type testRouter struct {
+	atppb.UnimplementedFrameServiceServer
+
+	mu         sync.Mutex
+	heartbeats int
+	metadata   metadata.MD
+	streams    []context.CancelFunc
+}
+
+func (r *testRouter) StreamFrames(stream atppb.FrameService_StreamFramesServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if len(md.Get("authorization")) == 0 {
+		return status.Error(codes.Unauthenticated, "missing API key")
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	r.mu.Lock()
+	r.metadata = md
+	r.streams = append(r.streams, cancel)
+	r.mu.Unlock()
+
+	received := make(chan *atppb.Frame)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			message, err := stream.Recv()
+			if err != nil {
+				errs <- err
+				return
+			}
+			received <- message
+		}
+	}()
+
+	for {
+		var message *atppb.Frame
+		select {
+		case <-ctx.Done():
+			return status.Error(codes.Unavailable, "router going away")
+		case err := <-errs:
+			return err
+		case message = <-received:
+		}
+
+		frame := FromProto(message)
+		switch frame.Type {
+		case "heartbeat":
+			r.mu.Lock()
+			r.heartbeats++
+			r.mu.Unlock()
+		case "completion_request":
+			reply, err := ToProto(atpsdk.Frame{
+				Type:     "completion_response",
+				StreamID: frame.StreamID,
+				MsgSeq:   frame.MsgSeq,
+				Meta:     atpsdk.Meta{Trace: frame.Meta.Trace},
+				Payload: map[string]interface{}{
+					"text":       "echo: " + frame.Payload["prompt"].(string),
+					"model_used": "grpc-model",
+					"tokens_in":  3.0,
+				},
+			})
+			if err != nil {
+				return err
+			}
+			if err := stream.Send(reply); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// dropStreams ends every open stream, as a router restart would
+func (r *testRouter) dropStreams() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cancel := range r.streams {
+		cancel()
+	}
+}
+
+func startRouter(t *testing.T) (*testRouter, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	router := &testRouter{}
+	server := grpc.NewServer()
+	atppb.RegisterFrameServiceServer(server, router)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return router, "grpc://" + listener.Addr().String()
+}
+
+func waitForStream(t *testing.T, router *testRouter) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		router.mu.Lock()
+		open := len(router.streams)
+		router.mu.Unlock()
+		if open > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("No stream reached the router")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGRPCTransportComplete(t *testing.T) {
+	router, address := startRouter(t)
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{
+		Transport:         Name,
+		TransportAddress:  address,
+		APIKey:            "key",
+		SessionID:         "s1",
+		HeartbeatInterval: 10 * time.Millisecond,
+	})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), atpsdk.CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.Text != "echo: hi" || response.ModelUsed != "grpc-model" || response.TokensIn != 3 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if response.TraceParent == "" {
+		t.Error("Expected the request's traceparent to survive the round trip")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		router.mu.Lock()
+		heartbeats, md := router.heartbeats, router.metadata
+		router.mu.Unlock()
+		if heartbeats > 0 {
+			if got := md.Get("session_id"); len(got) != 1 || got[0] != "s1" {
+				t.Errorf("Expected session_id metadata, got %v", md)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("No heartbeat reached the router")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGRPCTransportErrors(t *testing.T) {
+	router, address := startRouter(t)
+
+	// Nothing listens on port 1
+	client := atpsdk.NewATPClient(atpsdk.SDKConfig{Transport: Name, TransportAddress: "127.0.0.1:1"})
+	if err := client.Connect(); !errors.Is(err, atpsdk.ErrConnectionFailed) {
+		t.Errorf("Expected ErrConnectionFailed, got %v", err)
+	}
+	client.Close()
+
+	lost := make(chan error, 1)
+	client = atpsdk.NewATPClient(atpsdk.SDKConfig{
+		Transport:        Name,
+		TransportAddress: address,
+		APIKey:           "key",
+		OnDisconnect:     func(err error) { lost <- err },
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	waitForStream(t, router)
+	router.dropStreams()
+	select {
+	case err := <-lost:
+		if !errors.Is(err, atpsdk.ErrNotConnected) {
+			t.Errorf("Expected the lost stream to map to ErrNotConnected, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Client did not notice the dropped stream")
+	}
+	if client.IsConnected() {
+		t.Error("Client still connected after the stream ended")
+	}
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	frame := atpsdk.NewFrameBuilder("s1", "t1").BuildCompletionFrame("stream_1", atpsdk.CompletionRequest{
+		Prompt:          "hi",
+		Stop:            []string{"\n"},
+		PreferredModel:  "llama",
+		ExcludeAdapters: []string{"edge-2"},
+	})
+	frame.Window = atpsdk.Window{MaxParallel: 2, MaxTokens: 100, MaxUSD: 5}
+	frame.Signature = "abc123"
+
+	message, err := ToProto(frame)
+	if err != nil {
+		t.Fatalf("ToProto failed: %v", err)
+	}
+	got := FromProto(message)
+	if got.Type != frame.Type || got.StreamID != frame.StreamID || got.MsgSeq != frame.MsgSeq ||
+		got.Timestamp != frame.Timestamp || got.Window != frame.Window || got.Meta.TaskType != frame.Meta.TaskType ||
+		got.Signature != frame.Signature || got.Version != atpsdk.ProtocolVersion || got.Meta.PreferredModel != "llama" || len(got.Meta.ExcludeAdapters) != 1 {
+		t.Errorf("Frame changed in the round trip:\n got %+v\nwant %+v", got, frame)
+	}
+	if got.Payload["prompt"] != "hi" || got.Payload["stop"].([]interface{})[0] != "\n" {
+		t.Errorf("Payload changed in the round trip: %v", got.Payload)
+	}
+}
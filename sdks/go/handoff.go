@@ -0,0 +1,178 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FrameTypeStreamResume moves a request in flight off a pool connection
+// that is being closed gracefully, e.g. by PoolMaxAge. It is sent on the
+// connection the request's stream moves to, naming the stream, the
+// request's msg_seq and the last frag_seq received of its response (-1
+// for none). The router acknowledges it and sends the rest of the
+// response on that connection, or rejects it with a nack or error frame.
+const FrameTypeStreamResume = "stream.resume"
+
+// StreamError fails a request whose stream could not be moved off a
+// closing pool connection. It matches ErrStreamInterrupted with errors.Is.
+type StreamError struct {
+	StreamID string
+	MsgSeq   int
+	// Resumable is false when the router rejected the resume, so the
+	// response is lost; it is true when the resume failed for a reason
+	// such as a timeout, and the router may still hold the stream.
+	Resumable bool
+	Err       error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("%v: stream %s could not be resumed: %v", ErrStreamInterrupted, e.StreamID, e.Err)
+}
+
+// Is reports whether target is ErrStreamInterrupted
+func (e *StreamError) Is(target error) bool {
+	return target == ErrStreamInterrupted
+}
+
+// Unwrap returns the resume's failure
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// Timings reports the delays the client added to a request
+type Timings struct {
+	// Handoffs counts the times the request moved to another pool
+	// connection while in flight
+	Handoffs int
+	// Handoff is the time spent moving it, from sending each
+	// stream.resume frame to its acknowledgment
+	Handoff time.Duration
+}
+
+// timings returns the Timings of pending's request
+func (p *pendingResponse) timings() Timings {
+	return Timings{Handoffs: int(p.handoffs.Load()), Handoff: time.Duration(p.handoffNanos.Load())}
+}
+
+// rotatePoolMember closes the member connection conn gracefully and puts a
+// new one in its place. New frames of its streams go to the next
+// connection on the ring right away. Once the frames already queued are
+// written, the requests in flight on conn are handed off, and conn is
+// closed when every handoff is settled.
+func (c *ATPClient) rotatePoolMember(m *poolMember, conn Transport) {
+	// Holding handlerMutex, no request can start on m unnoticed
+	c.handlerMutex.RLock()
+	var inFlight []*pendingResponse
+	for _, pending := range c.responseHandlers {
+		if pending.frameType != FrameTypeStreamResume && c.poolMemberFor(pending.streamID) == m {
+			inFlight = append(inFlight, pending)
+		}
+	}
+	m.mu.Lock()
+	current := m.conn == conn && !m.draining && !m.stopped
+	if current {
+		m.draining = true
+	}
+	out := m.out
+	m.mu.Unlock()
+	c.handlerMutex.RUnlock()
+	if !current {
+		return
+	}
+
+	c.closeQueues(out)
+	var wg sync.WaitGroup
+	for _, pending := range inFlight {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.handOff(pending)
+		}()
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	m.draining = false
+	if m.conn != conn {
+		// Lost or closed meanwhile
+		m.mu.Unlock()
+		return
+	}
+	m.conn = nil
+	close(m.done)
+	c.counters.poolConnections.Add(-1)
+	_ = conn.Close()
+	stopped := m.stopped
+	m.mu.Unlock()
+	if stopped || c.closed() {
+		return
+	}
+
+	next, err := c.dialRouter()
+	if err == nil && c.installPoolMember(m, next) {
+		return
+	}
+	if err != nil {
+		c.config.Logger.Printf("Warning: Failed to replace pool connection %d: %v", m.index, err)
+	}
+	c.redialPoolMember(m)
+}
+
+// handOff resumes pending's request, in flight on a closing pool
+// connection, on the connection its stream now maps to. The delay is
+// recorded in its Timings as the resume's ack is dispatched. A request that
+// cannot be resumed fails with a *StreamError.
+func (c *ATPClient) handOff(pending *pendingResponse) {
+	lastFragSeq := -1
+	if pending.chunks != nil {
+		lastFragSeq = pending.chunks.lastFragSeq()
+	}
+	frame := c.builder.BuildStreamResumeFrame(c.newStreamID("resume"), pending.streamID, pending.msgSeq, lastFragSeq, Meta{Trace: pending.trace})
+	ctx, cancel := context.WithTimeout(c.ctx, c.config.DefaultTimeout)
+	defer cancel()
+
+	resume := c.expectResponse(frame)
+	resume.resumed.Store(pending)
+	err := c.sendFrameOn(ctx, frame, pending.streamID, nil)
+	if err != nil {
+		c.discardPending(resume, false)
+	} else {
+		err = c.waitForAck(ctx, resume)
+	}
+	if err == nil {
+		return
+	}
+
+	c.handlerMutex.RLock()
+	_, waiting := c.responseHandlers[pending.requestID]
+	c.handlerMutex.RUnlock()
+	if !waiting {
+		// Answered before the resume failed
+		return
+	}
+	var atpErr *ATPError
+	rejected := errors.Is(err, ErrNacked) || errors.As(err, &atpErr)
+	c.config.Logger.Printf("Warning: Failed to resume stream %s on another pool connection%s: %v", pending.streamID, logCorrelation(pending.correlationID), err)
+	c.failPending(pending, &StreamError{StreamID: pending.streamID, MsgSeq: pending.msgSeq, Resumable: !rejected, Err: err})
+}
+
+// BuildStreamResumeFrame builds a frame, on a stream of its own, asking the
+// router to resume the response to the request msgSeq of resumedStreamID
+// after the fragment lastFragSeq
+func (fb *FrameBuilder) BuildStreamResumeFrame(streamID, resumedStreamID string, msgSeq, lastFragSeq int, meta ...Meta) Frame {
+	return Frame{
+		Type:      FrameTypeStreamResume,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		Meta:      withMeta(Meta{}, meta),
+		Payload: map[string]interface{}{
+			"stream_id":     resumedStreamID,
+			"msg_seq":       msgSeq,
+			"last_frag_seq": lastFragSeq,
+		},
+	}
+}
@@ -0,0 +1,212 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// handoffRouter streams the first two chunks of every completion on the
+// connection the request arrived on and leaves the rest to stream.resume,
+// which resume answers on the connection the resume arrived on
+func handoffRouter(t *testing.T, resume func(conn int, f Frame, streamID string, msgSeq, lastFragSeq int) []Frame) (*testRouter, <-chan int) {
+	fb := NewFrameBuilder("", "")
+	requests := make(chan int, 1)
+	router := newConnTestRouter(t, func(conn int, f Frame) []Frame {
+		switch f.Type {
+		case FrameTypeCompletionRequest:
+			requests <- conn
+			return []Frame{
+				fb.BuildCompletionChunkFrame(f.StreamID, f.MsgSeq, 0, "Hello"),
+				fb.BuildCompletionChunkFrame(f.StreamID, f.MsgSeq, 1, ", world"),
+			}
+		case FrameTypeStreamResume:
+			streamID, _ := f.Payload["stream_id"].(string)
+			msgSeq, _ := f.Payload["msg_seq"].(float64)
+			lastFragSeq, _ := f.Payload["last_frag_seq"].(float64)
+			return resume(conn, f, streamID, int(msgSeq), int(lastFragSeq))
+		}
+		return nil
+	})
+	return router, requests
+}
+
+// newHandoffClient connects a client with a pool of two connections whose
+// IDs, once pinned, put completion streams on the pool member
+func newHandoffClient(t *testing.T, router *testRouter, config SDKConfig) *ATPClient {
+	var pinned atomic.Pointer[string]
+	ids := sequentialIDs()
+	config.WSURL = router.URL()
+	config.PoolSize = 2
+	config.IDGenerator = IDGeneratorFunc(func() string {
+		if id := pinned.Load(); id != nil {
+			return *id
+		}
+		return ids.NewID()
+	})
+	client := NewATPClient(config)
+	t.Cleanup(func() { client.Close() })
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	waitForPoolConnections(t, client, 2)
+
+	for i := 0; ; i++ {
+		id := fmt.Sprintf("pinned-%d", i)
+		if client.poolMemberFor("completion_"+id) != nil {
+			pinned.Store(&id)
+			return client
+		}
+	}
+}
+
+// streamMidway starts a streamed completion on client and returns once its
+// first two chunks are delivered, with the channel its outcome is sent to
+func streamMidway(t *testing.T, client *ATPClient, chunks *[]CompletionChunk) <-chan error {
+	var mu sync.Mutex
+	delivered := make(chan struct{}, 4)
+	done := make(chan error, 1)
+	go func() {
+		response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithTokenCallback(func(chunk CompletionChunk) error {
+			mu.Lock()
+			*chunks = append(*chunks, chunk)
+			mu.Unlock()
+			delivered <- struct{}{}
+			return nil
+		}))
+		if err == nil && response.Text != "Hello, world, again!" {
+			err = fmt.Errorf("unexpected text %q", response.Text)
+		}
+		if err == nil && (response.Timings.Handoffs != 1 || response.Timings.Handoff <= 0) {
+			err = fmt.Errorf("expected one handoff in the timings, got %+v", response.Timings)
+		}
+		done <- err
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-delivered:
+		case err := <-done:
+			t.Fatalf("Complete ended early: %v", err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("The first chunks were not delivered")
+		}
+	}
+	return done
+}
+
+func TestRotatePoolMemberHandsOffStreams(t *testing.T) {
+	fb := NewFrameBuilder("", "")
+	resumes := make(chan int, 1)
+	router, requests := handoffRouter(t, func(conn int, f Frame, streamID string, msgSeq, lastFragSeq int) []Frame {
+		resumes <- lastFragSeq
+		final := fb.BuildCompletionResponseFrame(streamID, msgSeq, CompletionResponse{Text: "!", ModelUsed: "m", Finished: true})
+		final.FragSeq = 3
+		final.Flags = []string{FlagFragment, FlagLast}
+		return []Frame{
+			fb.BuildAckFrame(f.StreamID, f.MsgSeq),
+			// Resent from the old connection's point of view
+			fb.BuildCompletionChunkFrame(streamID, msgSeq, 1, ", world"),
+			fb.BuildCompletionChunkFrame(streamID, msgSeq, 2, ", again"),
+			final,
+		}
+	})
+	client := newHandoffClient(t, router, SDKConfig{})
+
+	var chunks []CompletionChunk
+	done := streamMidway(t, client, &chunks)
+	requestConn := <-requests
+
+	member := client.poolMemberFor("completion_" + client.config.IDGenerator.NewID())
+	member.mu.RLock()
+	conn := member.conn
+	member.mu.RUnlock()
+	client.rotatePoolMember(member, conn)
+
+	if err := <-done; err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if last := <-resumes; last != 1 {
+		t.Errorf("Expected stream.resume after frag_seq 1, got %d", last)
+	}
+	for i, chunk := range chunks {
+		if chunk.Index != i {
+			t.Fatalf("Chunks are not continuous: %+v", chunks)
+		}
+	}
+	if len(chunks) != 4 || !chunks[3].Final {
+		t.Errorf("Expected four chunks, the last one final, got %+v", chunks)
+	}
+
+	// The rotated connection is closed and replaced
+	router.WaitForConnections(t, 3)
+	waitForPoolConnections(t, client, 2)
+	deadline := time.Now().Add(2 * time.Second)
+	for router.ActiveConnections() != 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := router.ActiveConnections(); n != 2 {
+		t.Errorf("Expected router connection %d closed, %d connections open", requestConn, n)
+	}
+}
+
+func TestRotatePoolMemberRejectedResume(t *testing.T) {
+	fb := NewFrameBuilder("", "")
+	router, requests := handoffRouter(t, func(conn int, f Frame, streamID string, msgSeq, lastFragSeq int) []Frame {
+		return []Frame{fb.BuildNackFrame(f.StreamID, f.MsgSeq, "STREAM_UNKNOWN", "no such stream")}
+	})
+	client := newHandoffClient(t, router, SDKConfig{Logger: &recordingLogger{}})
+
+	var chunks []CompletionChunk
+	done := streamMidway(t, client, &chunks)
+	<-requests
+
+	member := client.poolMemberFor("completion_" + client.config.IDGenerator.NewID())
+	member.mu.RLock()
+	conn := member.conn
+	member.mu.RUnlock()
+	client.rotatePoolMember(member, conn)
+
+	err := <-done
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) || !errors.Is(err, ErrStreamInterrupted) || !errors.Is(err, ErrNacked) {
+		t.Fatalf("Expected a *StreamError wrapping the nack, got %v", err)
+	}
+	if streamErr.Resumable {
+		t.Error("A rejected resume must not be reported resumable")
+	}
+}
+
+func TestPoolMaxAgeRotatesConnections(t *testing.T) {
+	router := newTestRouter(t, nil)
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), PoolSize: 3, PoolMaxAge: time.Hour, Clock: clock, HeartbeatJitter: -1})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	waitForPoolConnections(t, client, 3)
+
+	// Both members are replaced, the primary connection is kept. The age
+	// timers start next to the connections, so the clock is advanced until
+	// they fire.
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		router.mu.Lock()
+		accepted := len(router.conns)
+		router.mu.Unlock()
+		if accepted >= 5 {
+			break
+		}
+		clock.Advance(time.Hour)
+		time.Sleep(5 * time.Millisecond)
+	}
+	router.WaitForConnections(t, 5)
+	waitForPoolConnections(t, client, 3)
+	if !client.IsConnected() {
+		t.Error("Rotating pool members must not disconnect the client")
+	}
+}
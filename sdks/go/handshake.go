@@ -0,0 +1,181 @@
+package atpsdk
+
+import (
+	"fmt"
+	"time"
+)
+
+// Frame types of the session handshake, exchanged first on each connection
+// with SDKConfig.SessionHandshake
+const (
+	FrameTypeSessionHello   = "session.hello"
+	FrameTypeSessionWelcome = "session.welcome"
+)
+
+// defaultSessionHandshakeTimeout is the default of
+// SDKConfig.SessionHandshakeTimeout
+const defaultSessionHandshakeTimeout = 10 * time.Second
+
+// SessionWelcome is the router's answer to the client's session.hello. Its
+// values override the corresponding SDKConfig defaults.
+type SessionWelcome struct {
+	// MaxFrameBytes is the largest frame the router accepts, or zero when
+	// it did not say
+	MaxFrameBytes int
+	// HeartbeatInterval, when positive, replaces SDKConfig.HeartbeatInterval
+	HeartbeatInterval time.Duration
+	// SessionToken identifies the session; it is sent in the hello of
+	// later connections so the router can resume it
+	SessionToken string
+	// ProtocolVersion is the version of the welcome frame
+	ProtocolVersion string
+}
+
+// handshake sends session.hello on conn, a new connection whose pumps are
+// not started yet, and reads its answer. The welcome is recorded on the
+// client; a rejection fails with an error matching ErrSessionRejected, and
+// no answer within SessionHandshakeTimeout with one matching
+// ErrSessionHandshakeTimeout.
+func (c *ATPClient) handshake(conn Transport) error {
+	hello := c.builder.BuildSessionHelloFrame(c.newStreamID("session"), c.helloPayload())
+	if err := c.prepareOutgoing(&hello); err != nil {
+		return err
+	}
+	data, binary, err := c.encodeFrame(&hello)
+	if err != nil {
+		return err
+	}
+	if err := sendMessage(conn, data, binary); err != nil {
+		return fmt.Errorf("failed to send %s: %w", FrameTypeSessionHello, err)
+	}
+	c.counters.framesSent.Add(1)
+	c.audit(AuditOutbound, hello, 0)
+
+	answer := make(chan handshakeAnswer, 1)
+	go func() { answer <- c.awaitWelcome(conn, &hello) }()
+	select {
+	case a := <-answer:
+		if a.err != nil {
+			return a.err
+		}
+		welcome := parseWelcome(a.frame)
+		c.welcome.Store(&welcome)
+		c.observeRouterTime(a.frame.Timestamp)
+		return nil
+	case <-c.config.Clock.After(c.config.SessionHandshakeTimeout):
+		// Closing conn ends the read awaitWelcome is blocked in
+		_ = conn.Close()
+		return fmt.Errorf("%w after %v", ErrSessionHandshakeTimeout, c.config.SessionHandshakeTimeout)
+	}
+}
+
+// handshakeAnswer is the frame answering a session.hello, or the error
+// reading it
+type handshakeAnswer struct {
+	frame *Frame
+	err   error
+}
+
+// awaitWelcome reads conn until the router answers hello. Other frames are
+// dropped, as the router must not send any before its welcome.
+func (c *ATPClient) awaitWelcome(conn Transport, hello *Frame) handshakeAnswer {
+	for {
+		data, binary, err := receiveMessage(conn)
+		if err != nil {
+			return handshakeAnswer{err: fmt.Errorf("waiting for %s: %w", FrameTypeSessionWelcome, err)}
+		}
+		c.counters.framesReceived.Add(1)
+		var frame Frame
+		data, err = c.decodeIncoming(data, binary, &frame)
+		if err != nil {
+			c.config.Logger.Printf("Warning: dropping incoming frame: %v", err)
+			continue
+		}
+		if err := c.verifyIncoming(data, &frame); err != nil {
+			c.rejectUnsigned(&frame, err)
+			continue
+		}
+		normalizeVersion(&frame)
+		c.observeProtocolVersion(&frame)
+		c.audit(AuditInbound, frame, 0)
+
+		switch {
+		case frame.Type == FrameTypeSessionWelcome:
+			return handshakeAnswer{frame: &frame}
+		case frame.Type == FrameTypeError && (frame.StreamID == hello.StreamID || frame.StreamID == ""):
+			return handshakeAnswer{err: fmt.Errorf("%w: %w", ErrSessionRejected, parseErrorFrame(&frame))}
+		default:
+			c.config.Logger.Printf("Warning: dropping %s frame received before %s", frame.Type, FrameTypeSessionWelcome)
+		}
+	}
+}
+
+// helloPayload describes the client to the router in its session.hello
+func (c *ATPClient) helloPayload() map[string]interface{} {
+	encoding, compression := "json", "none"
+	if c.usesCodec() {
+		encoding = "binary"
+		switch c.config.Codec.(type) {
+		case GzipCodec, *GzipCodec:
+			compression = "gzip"
+		}
+	}
+	payload := map[string]interface{}{
+		"sdk_version":        SDKVersion,
+		"protocol_version":   ProtocolVersion,
+		"supported_versions": SupportedProtocolVersions(),
+		"encoding":           encoding,
+		"compression":        compression,
+		"tenant_id":          c.config.TenantID,
+	}
+	if welcome := c.welcome.Load(); welcome != nil && welcome.SessionToken != "" {
+		payload["session_token"] = welcome.SessionToken
+	}
+	return payload
+}
+
+// parseWelcome reads the payload of a session.welcome frame
+func parseWelcome(frame *Frame) SessionWelcome {
+	return SessionWelcome{
+		MaxFrameBytes:     getInt(frame.Payload, "max_frame_bytes", 0),
+		HeartbeatInterval: time.Duration(getFloat64(frame.Payload, "heartbeat_interval_ms", 0) * float64(time.Millisecond)),
+		SessionToken:      getString(frame.Payload, "session_token", ""),
+		ProtocolVersion:   frame.Version,
+	}
+}
+
+// SessionWelcome returns the router's answer to the session handshake of
+// the latest connection, or nil without SDKConfig.SessionHandshake or
+// before connecting
+func (c *ATPClient) SessionWelcome() *SessionWelcome {
+	if welcome := c.welcome.Load(); welcome != nil {
+		copied := *welcome
+		return &copied
+	}
+	return nil
+}
+
+// heartbeatInterval is SDKConfig.HeartbeatInterval, unless the router's
+// welcome set another
+func (c *ATPClient) heartbeatInterval() time.Duration {
+	if welcome := c.welcome.Load(); welcome != nil && welcome.HeartbeatInterval > 0 {
+		return welcome.HeartbeatInterval
+	}
+	return c.config.HeartbeatInterval
+}
+
+// BuildSessionHelloFrame builds the session.hello frame opening a
+// connection's session handshake
+func (fb *FrameBuilder) BuildSessionHelloFrame(streamID string, payload map[string]interface{}, meta ...Meta) Frame {
+	return Frame{
+		Type:      FrameTypeSessionHello,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		MsgSeq:    1,
+		Meta: withMeta(Meta{
+			EnvironmentID: fb.tenantID,
+		}, meta),
+		Payload: payload,
+	}
+}
@@ -0,0 +1,86 @@
+package atpsdk
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSessionHandshake(t *testing.T) {
+	hellos := make(chan Frame, 2)
+	clock := newFakeClock()
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeSessionHello {
+			return nil
+		}
+		hellos <- f
+		return []Frame{{Type: FrameTypeSessionWelcome, StreamID: f.StreamID, MsgSeq: f.MsgSeq, Timestamp: FrameTimeOf(clock.Now().Add(2 * time.Second)), Payload: map[string]interface{}{
+			"max_frame_bytes": 1024, "heartbeat_interval_ms": 5000, "session_token": "tok-1",
+		}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), TenantID: "acme", SessionHandshake: true, Clock: clock})
+	defer client.Close()
+
+	if client.SessionWelcome() != nil {
+		t.Error("Expected no welcome before connecting")
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	hello := <-hellos
+	if hello.Payload["sdk_version"] != SDKVersion || hello.Payload["protocol_version"] != ProtocolVersion ||
+		hello.Payload["tenant_id"] != "acme" || hello.Payload["encoding"] != "json" || hello.Payload["session_token"] != nil {
+		t.Errorf("Unexpected hello payload %v", hello.Payload)
+	}
+	welcome := client.SessionWelcome()
+	if welcome == nil || welcome.MaxFrameBytes != 1024 || welcome.SessionToken != "tok-1" {
+		t.Fatalf("Expected the welcome to be recorded, got %+v", welcome)
+	}
+	if got := client.Introspect().Limits.HeartbeatIntervalMS; got != 5000 {
+		t.Errorf("Expected the welcome's heartbeat interval, got %dms", got)
+	}
+	if skew := client.ClockSkew(); skew != 2*time.Second {
+		t.Errorf("Expected the skew measured from the welcome, got %v", skew)
+	}
+
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if hello := <-hellos; hello.Payload["session_token"] != "tok-1" {
+		t.Errorf("Expected the session token in the next hello, got %v", hello.Payload)
+	}
+}
+
+func TestSessionHandshakeRejected(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeSessionHello {
+			return nil
+		}
+		return []Frame{{Type: FrameTypeError, StreamID: f.StreamID, MsgSeq: f.MsgSeq,
+			Payload: map[string]interface{}{"code": ErrorCodeInvalidRequest, "message": "tenant unknown"}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Transport: TransportAuto, SessionHandshake: true})
+	defer client.Close()
+
+	err := client.Connect()
+	var atpErr *ATPError
+	if !errors.Is(err, ErrSessionRejected) || !errors.As(err, &atpErr) || atpErr.Message != "tenant unknown" {
+		t.Fatalf("Expected the rejection, got %v", err)
+	}
+	if client.IsConnected() {
+		t.Error("Expected a rejected session not to fall back to HTTP")
+	}
+}
+
+func TestSessionHandshakeTimeout(t *testing.T) {
+	router := newTestRouter(t, nil)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), SessionHandshake: true, SessionHandshakeTimeout: 50 * time.Millisecond})
+	defer client.Close()
+
+	if err := client.Connect(); !errors.Is(err, ErrSessionHandshakeTimeout) {
+		t.Fatalf("Expected ErrSessionHandshakeTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package atpsdk
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+)
+
+// processStart approximates the process start time for uptime reporting
+var processStart = time.Now()
+
+// RuntimeHealthOption customizes a RuntimeHealthCollector
+type RuntimeHealthOption func(*runtimeHealthOptions)
+
+// runtimeHealthOptions holds the settings applied by RuntimeHealthOptions
+type runtimeHealthOptions struct {
+	server     *AdapterServer
+	decorators []func(*HealthStatus)
+}
+
+// WithAdapterServer includes the server's queue depth, request rate, error
+// rate and handler latency percentiles in collected health reports.
+func WithAdapterServer(server *AdapterServer) RuntimeHealthOption {
+	return func(o *runtimeHealthOptions) {
+		o.server = server
+	}
+}
+
+// WithHealthDecorator registers a function that adjusts every collected
+// HealthStatus, e.g. to add metadata or downgrade the status, before it is
+// returned. Decorators run in registration order.
+func WithHealthDecorator(decorate func(*HealthStatus)) RuntimeHealthOption {
+	return func(o *runtimeHealthOptions) {
+		o.decorators = append(o.decorators, decorate)
+	}
+}
+
+// RuntimeHealthCollector builds HealthStatus reports from Go runtime
+// statistics and, optionally, an AdapterServer's request statistics. Pass
+// its Collect method to StartHealthLoop.
+type RuntimeHealthCollector struct {
+	adapterID string
+	options   runtimeHealthOptions
+
+	mu         sync.Mutex
+	lastAt     time.Time
+	lastCPU    time.Duration
+	lastServed uint64
+	lastFailed uint64
+}
+
+// NewRuntimeHealthCollector creates a collector reporting for adapterID
+func NewRuntimeHealthCollector(adapterID string, opts ...RuntimeHealthOption) *RuntimeHealthCollector {
+	var options runtimeHealthOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	h := &RuntimeHealthCollector{adapterID: adapterID, options: options, lastAt: time.Now()}
+	h.lastCPU, _ = processCPUTime()
+	return h
+}
+
+// Collect samples the runtime and returns a ready-to-send HealthStatus.
+// Rates (CPU usage, requests per second, error rate) cover the time since
+// the previous call.
+func (h *RuntimeHealthCollector) Collect() HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(h.lastAt)
+	h.lastAt = now
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	health := HealthStatus{
+		AdapterID:       h.adapterID,
+		Status:          "healthy",
+		MemoryUsageMB:   ptr(float64(mem.Sys) / (1 << 20)),
+		UptimeSeconds:   ptr(int(now.Sub(processStart).Seconds())),
+		LastHealthCheck: ptr(float64(now.UnixNano()) / 1e9),
+		Metadata: map[string]interface{}{
+			"goroutines":    runtime.NumGoroutine(),
+			"heap_alloc_mb": float64(mem.HeapAlloc) / (1 << 20),
+			"num_gc":        mem.NumGC,
+		},
+	}
+
+	if cpu, ok := processCPUTime(); ok {
+		if elapsed > 0 {
+			health.CPUUsagePercent = ptr(float64(cpu-h.lastCPU) / float64(elapsed) * 100)
+		}
+		h.lastCPU = cpu
+	}
+
+	if server := h.options.server; server != nil {
+		stats := server.Stats()
+		health.QueueDepth = ptr(stats.Queued)
+		health.Metadata["in_flight"] = stats.InFlight
+
+		served, failed := stats.Served-h.lastServed, stats.Failed-h.lastFailed
+		h.lastServed, h.lastFailed = stats.Served, stats.Failed
+		if elapsed > 0 {
+			health.RequestsPerSecond = ptr(float64(served) / elapsed.Seconds())
+		}
+		if served > 0 {
+			health.ErrorRate = ptr(float64(failed) / float64(served) * 100)
+		}
+		if stats.LatencySamples > 0 {
+			health.P50LatencyMS = ptr(durationMS(stats.P50Latency))
+			health.P95LatencyMS = ptr(durationMS(stats.P95Latency))
+			health.P99LatencyMS = ptr(durationMS(stats.P99Latency))
+		}
+	}
+
+	for _, decorate := range h.options.decorators {
+		decorate(&health)
+	}
+	return health
+}
+
+// latencyWindowSize is the number of recent handler executions kept for
+// latency percentiles
+const latencyWindowSize = 1024
+
+// latencyWindow keeps the most recent latency observations in a ring
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) observe(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+// percentiles returns the p50, p95 and p99 of the window and the number of
+// samples they were computed from
+func (w *latencyWindow) percentiles() (p50, p95, p99 time.Duration, n int) {
+	w.mu.Lock()
+	sorted := slices.Clone(w.samples)
+	w.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return 0, 0, 0, 0
+	}
+	slices.Sort(sorted)
+	at := func(q float64) time.Duration {
+		return sorted[int(q*float64(len(sorted)-1)+0.5)]
+	}
+	return at(0.50), at(0.95), at(0.99), len(sorted)
+}
+
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
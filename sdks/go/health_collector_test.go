@@ -0,0 +1,146 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRuntimeHealthCollector(t *testing.T) {
+	collector := NewRuntimeHealthCollector("adapter-1", WithHealthDecorator(func(h *HealthStatus) {
+		h.Metadata["region"] = "eu-west-1"
+	}))
+	health := collector.Collect()
+
+	if health.AdapterID != "adapter-1" || health.Status != "healthy" {
+		t.Errorf("Unexpected identity: %+v", health)
+	}
+	if health.MemoryUsageMB == nil || *health.MemoryUsageMB <= 0 {
+		t.Error("Expected memory usage")
+	}
+	if health.UptimeSeconds == nil || health.LastHealthCheck == nil {
+		t.Error("Expected uptime and check time")
+	}
+	if n, _ := health.Metadata["goroutines"].(int); n <= 0 {
+		t.Errorf("Expected goroutine count, got %v", health.Metadata["goroutines"])
+	}
+	if health.Metadata["region"] != "eu-west-1" {
+		t.Error("Expected decorator metadata")
+	}
+	if health.QueueDepth != nil || health.P95LatencyMS != nil {
+		t.Error("Adapter fields should be unset without an adapter server")
+	}
+}
+
+func TestRuntimeHealthCollectorAdapterServer(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	client := connectedAdapterClient(t, router)
+
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		if req.Prompt == "fail" {
+			return CompletionResponse{}, errors.New("boom")
+		}
+		return CompletionResponse{Text: "ok"}, nil
+	}, AdapterServerConfig{})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	defer server.Close()
+
+	collector := NewRuntimeHealthCollector("adapter-1",
+		WithAdapterServer(server),
+		WithHealthDecorator(func(h *HealthStatus) {
+			if h.ErrorRate != nil && *h.ErrorRate > 10 {
+				h.Status = "degraded"
+			}
+		}))
+
+	for i, prompt := range []string{"a", "b", "c", "fail"} {
+		_ = router.Send(completionRequestFrame(fmt.Sprintf("s-%d", i), 1, prompt))
+		waitReply(t, replies)
+	}
+	// The reply is sent before the request is accounted for
+	deadline := time.Now().Add(2 * time.Second)
+	for server.Stats().Served < 4 && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	health := collector.Collect()
+	if health.QueueDepth == nil || *health.QueueDepth != 0 {
+		t.Errorf("Expected empty queue, got %v", health.QueueDepth)
+	}
+	if health.ErrorRate == nil || *health.ErrorRate != 25 {
+		t.Errorf("Expected 25%% error rate, got %v", health.ErrorRate)
+	}
+	if health.RequestsPerSecond == nil || *health.RequestsPerSecond <= 0 {
+		t.Errorf("Expected a request rate, got %v", health.RequestsPerSecond)
+	}
+	if health.P50LatencyMS == nil || health.P99LatencyMS == nil || *health.P99LatencyMS < *health.P50LatencyMS {
+		t.Errorf("Expected ordered latency percentiles, got %v %v", health.P50LatencyMS, health.P99LatencyMS)
+	}
+	if health.Status != "degraded" {
+		t.Errorf("Expected decorator to downgrade status, got %q", health.Status)
+	}
+
+	// Rates cover only the interval since the previous collection
+	if next := collector.Collect(); next.ErrorRate != nil || *next.RequestsPerSecond != 0 {
+		t.Errorf("Expected no new requests, got error rate %v rps %v", next.ErrorRate, *next.RequestsPerSecond)
+	}
+}
+
+func TestAdapterServerLatencyOnClock(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Logger: &recordingLogger{}, Clock: clock})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	router.WaitForConnections(t, 1)
+
+	server, err := client.HandleCompletions(func(ctx context.Context, req CompletionRequest, meta Meta) (CompletionResponse, error) {
+		ms, _ := strconv.Atoi(req.Prompt)
+		clock.Advance(time.Duration(ms) * time.Millisecond)
+		return CompletionResponse{Text: "ok"}, nil
+	}, AdapterServerConfig{})
+	if err != nil {
+		t.Fatalf("HandleCompletions failed: %v", err)
+	}
+	defer server.Close()
+
+	for i, prompt := range []string{"40", "10", "30", "20"} {
+		_ = router.Send(completionRequestFrame(fmt.Sprintf("s-%d", i), 1, prompt))
+		waitReply(t, replies)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for server.Stats().Served < 4 && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	health := NewRuntimeHealthCollector("adapter-1", WithAdapterServer(server)).Collect()
+	if health.P50LatencyMS == nil || *health.P50LatencyMS != 30 || *health.P99LatencyMS != 40 {
+		t.Errorf("Expected p50 30ms and p99 40ms on the client's clock, got %v %v", health.P50LatencyMS, health.P99LatencyMS)
+	}
+}
+
+func TestLatencyWindowPercentiles(t *testing.T) {
+	var w latencyWindow
+	for i := 1; i <= 100; i++ {
+		w.observe(time.Duration(i) * time.Millisecond)
+	}
+	p50, p95, p99, n := w.percentiles()
+	if n != 100 || p50 != 51*time.Millisecond || p95 != 95*time.Millisecond || p99 != 99*time.Millisecond {
+		t.Errorf("Unexpected percentiles p50=%v p95=%v p99=%v n=%d", p50, p95, p99, n)
+	}
+
+	// Old samples are evicted once the window is full
+	for i := 0; i < latencyWindowSize; i++ {
+		w.observe(time.Second)
+	}
+	if p50, _, _, n := w.percentiles(); n != latencyWindowSize || p50 != time.Second {
+		t.Errorf("Expected only recent samples, got p50=%v n=%d", p50, n)
+	}
+}
@@ -0,0 +1,54 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// FrameTypeHealthQuery asks the router for the health it last received from
+// an adapter, answered with an adapter.health frame
+const FrameTypeHealthQuery = "adapter.health.query"
+
+// ErrorCodeAdapterUnknown is reported by the router for an adapter it has
+// no health on file for
+const ErrorCodeAdapterUnknown = "ADAPTER_UNKNOWN"
+
+// GetAdapterHealth asks the router for the latest health report it has on
+// file for adapterID, e.g. to check an adapter before scheduling work to
+// it. An adapter the router does not know fails with an error matching
+// ErrAdapterUnknown. Like DiscoverAdapters, it needs a WebSocket
+// connection.
+func (c *ATPClient) GetAdapterHealth(ctx context.Context, adapterID string) (*HealthStatus, error) {
+	payload := map[string]interface{}{
+		"adapter_id": adapterID,
+		"tenant_id":  c.tenantOf(ctx),
+	}
+	var health *HealthStatus
+	err := c.queryPages(ctx, FrameTypeHealthQuery, payload, FrameTypeHealth, func(frame *Frame) error {
+		var err error
+		health, err = ParseHealthFrame(*frame)
+		return err
+	})
+	var atpErr *ATPError
+	if errors.As(err, &atpErr) && atpErr.Code == ErrorCodeAdapterUnknown {
+		return nil, fmt.Errorf("%w: %s: %s", ErrAdapterUnknown, adapterID, atpErr.Message)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return health, nil
+}
+
+// ParseHealthFrame reads the HealthStatus of an adapter.health frame, the
+// reverse of FrameBuilder.BuildHealthFrame
+func ParseHealthFrame(frame Frame) (*HealthStatus, error) {
+	if frame.Type != FrameTypeHealth {
+		return nil, fmt.Errorf("expected an %s frame, got %s", FrameTypeHealth, frame.Type)
+	}
+	health, err := DecodePayload[HealthStatus](frame)
+	if err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
@@ -0,0 +1,51 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGetAdapterHealth(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeHealthQuery {
+			return nil
+		}
+		if f.Payload["adapter_id"] != "edge-1" {
+			reply := NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeAdapterUnknown, "no such adapter")
+			return []Frame{reply}
+		}
+		queue, version := 3, "1.2.0"
+		reply := NewFrameBuilder("", "").BuildHealthFrame(f.StreamID, HealthStatus{
+			AdapterID:  "edge-1",
+			Status:     "degraded",
+			QueueDepth: &queue,
+			Version:    &version,
+			Metadata:   map[string]interface{}{"region": "eu"},
+		})
+		return []Frame{reply}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	health, err := client.GetAdapterHealth(context.Background(), "edge-1")
+	if err != nil {
+		t.Fatalf("GetAdapterHealth failed: %v", err)
+	}
+	if health.AdapterID != "edge-1" || health.Status != "degraded" || *health.QueueDepth != 3 || *health.Version != "1.2.0" {
+		t.Errorf("Unexpected health %+v", health)
+	}
+	if health.P95LatencyMS != nil || health.LastHealthCheck == nil || health.Metadata["region"] != "eu" {
+		t.Errorf("Expected unset fields nil and the rest decoded, got %+v", health)
+	}
+
+	if _, err := client.GetAdapterHealth(context.Background(), "edge-2"); !errors.Is(err, ErrAdapterUnknown) {
+		t.Errorf("Expected ErrAdapterUnknown, got %v", err)
+	}
+}
+
+func TestParseHealthFrame(t *testing.T) {
+	if _, err := ParseHealthFrame(Frame{Type: FrameTypeHeartbeat}); err == nil {
+		t.Error("Expected a heartbeat to be refused")
+	}
+}
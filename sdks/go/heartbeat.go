@@ -0,0 +1,162 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// FrameTypeHeartbeatAck is sent by the router for each heartbeat it
+// receives, echoing the heartbeat's stream ID and sequence number
+const FrameTypeHeartbeatAck = "heartbeat_ack"
+
+// defaultHeartbeatJitter is the default of SDKConfig.HeartbeatJitter
+const defaultHeartbeatJitter = 0.1
+
+// HeartbeatStats is the client-side view of the connection reported in
+// heartbeats when SDKConfig.HeartbeatStats is set
+type HeartbeatStats struct {
+	PendingRequests int64  `json:"pending_requests"`
+	FramesSent      uint64 `json:"frames_sent"`     // since the previous heartbeat
+	FramesReceived  uint64 `json:"frames_received"` // since the previous heartbeat
+	Reconnects      uint64 `json:"reconnects"`
+	SDKVersion      string `json:"sdk_version"`
+}
+
+// heartbeatMonitor numbers the heartbeats sent over one connection and
+// tracks the router's acknowledgments of them
+type heartbeatMonitor struct {
+	streamID string
+	template controlFrameTemplate
+	sent     atomic.Int64 // msg_seq of the last heartbeat sent
+	acked    atomic.Int64 // highest msg_seq acknowledged
+	misses   atomic.Int32 // consecutive heartbeats left unacknowledged
+}
+
+// newHeartbeatMonitor returns a monitor for heartbeats on streamID
+func newHeartbeatMonitor(builder *FrameBuilder, streamID string) *heartbeatMonitor {
+	frame := builder.BuildHeartbeatFrame()
+	frame.StreamID = streamID
+	return &heartbeatMonitor{streamID: streamID, template: newControlFrameTemplate(frame)}
+}
+
+// next returns the sequence number of the next heartbeat, and the number of
+// consecutive heartbeats missed so far, counting the previous one if it was
+// not acknowledged
+func (m *heartbeatMonitor) next() (seq int64, missed int32) {
+	previous := m.sent.Load()
+	missed = m.misses.Load()
+	if previous > 0 && m.acked.Load() < previous {
+		missed = m.misses.Add(1)
+	}
+	return m.sent.Add(1), missed
+}
+
+// ack records the acknowledgment of heartbeat seq
+func (m *heartbeatMonitor) ack(seq int64) {
+	for {
+		acked := m.acked.Load()
+		if seq <= acked || m.acked.CompareAndSwap(acked, seq) {
+			break
+		}
+	}
+	m.misses.Store(0)
+}
+
+// reset forgets the heartbeats sent over a previous connection
+func (m *heartbeatMonitor) reset() {
+	m.acked.Store(m.sent.Load())
+	m.misses.Store(0)
+}
+
+// stamp makes frame heartbeat seq of the monitored connection, for the
+// Frame based send path
+func (m *heartbeatMonitor) stamp(frame Frame, seq int64) Frame {
+	frame.StreamID = m.streamID
+	frame.MsgSeq = int(seq)
+	return frame
+}
+
+// heartbeatStats collects the stats reported in the next heartbeat from the
+// client's counters. It only loads and swaps atomics, so it never waits for
+// a sender.
+func (c *ATPClient) heartbeatStats() HeartbeatStats {
+	sent := c.counters.framesSent.Load()
+	received := c.counters.framesReceived.Load()
+	stats := HeartbeatStats{
+		PendingRequests: c.counters.pending.Load(),
+		FramesSent:      sent - c.counters.heartbeatSent.Swap(sent),
+		FramesReceived:  received - c.counters.heartbeatReceived.Swap(received),
+		SDKVersion:      SDKVersion,
+	}
+	if connects := c.counters.connects.Load(); connects > 1 {
+		stats.Reconnects = connects - 1
+	}
+	return stats
+}
+
+// heartbeatsFailed returns the error a connection is torn down with after
+// missed consecutive unacknowledged heartbeats, or nil while the connection
+// is considered healthy
+func (c *ATPClient) heartbeatsFailed(missed int32) error {
+	threshold := c.config.HeartbeatMissThreshold
+	if threshold <= 0 || int(missed) < threshold {
+		return nil
+	}
+	return fmt.Errorf("%w: %d consecutive heartbeats unacknowledged", ErrHeartbeatTimeout, missed)
+}
+
+// handleHeartbeatAck records a heartbeat_ack frame against the connection
+// whose heartbeat it acknowledges
+func (c *ATPClient) handleHeartbeatAck(frame *Frame) {
+	c.counters.lastHeartbeatAck.Store(c.config.Clock.Now().UnixNano())
+	c.observeRouterTime(frame.Timestamp)
+	if frame.StreamID == c.heartbeat.streamID {
+		c.heartbeat.ack(int64(frame.MsgSeq))
+		return
+	}
+	if c.pool == nil {
+		return
+	}
+	for _, m := range c.pool.members {
+		if frame.StreamID == m.heartbeat.streamID {
+			m.heartbeat.ack(int64(frame.MsgSeq))
+			return
+		}
+	}
+}
+
+// LastHeartbeatAck returns when the router last acknowledged a heartbeat on
+// any connection, or the zero time if it never did
+func (c *ATPClient) LastHeartbeatAck() time.Time {
+	if at := c.counters.lastHeartbeatAck.Load(); at > 0 {
+		return time.Unix(0, at)
+	}
+	return time.Time{}
+}
+
+// reconnect redials the primary connection in the background after it was
+// lost, up to MaxRetries times RetryDelay apart, when AutoReconnect is set.
+// It gives up when epoch, the one the connection belonged to, ends.
+func (c *ATPClient) reconnect(epoch context.Context) {
+	if !c.config.AutoReconnect || epoch.Err() != nil {
+		return
+	}
+	go func() {
+		for attempt := 1; attempt <= c.config.MaxRetries; attempt++ {
+			select {
+			case <-epoch.Done():
+				return
+			case <-c.config.Clock.After(jitter(c.config.RetryDelay, c.config.HeartbeatJitter)):
+			}
+
+			err := c.Connect()
+			if err == nil {
+				return
+			}
+			c.config.Logger.Printf("Warning: Failed to reconnect (attempt %d/%d): %v", attempt, c.config.MaxRetries, err)
+		}
+		c.reportAsyncError(fmt.Errorf("%w: gave up reconnecting after %d attempts", ErrConnectionFailed, c.config.MaxRetries))
+	}()
+}
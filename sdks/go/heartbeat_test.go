@@ -0,0 +1,293 @@
+package atpsdk
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// heartbeatRouter acknowledges the heartbeats on the connections ack
+// reports true for, and records every heartbeat per connection
+func heartbeatRouter(t *testing.T, ack func(conn int) bool) (*testRouter, func(conn int) []Frame) {
+	var mu sync.Mutex
+	heartbeats := make(map[int][]Frame)
+	router := newConnTestRouter(t, func(conn int, f Frame) []Frame {
+		if f.Type != "heartbeat" {
+			return nil
+		}
+		mu.Lock()
+		heartbeats[conn] = append(heartbeats[conn], f)
+		mu.Unlock()
+		if !ack(conn) {
+			return nil
+		}
+		return []Frame{{Type: FrameTypeHeartbeatAck, StreamID: f.StreamID, MsgSeq: f.MsgSeq}}
+	})
+	return router, func(conn int) []Frame {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Frame(nil), heartbeats[conn]...)
+	}
+}
+
+func TestHeartbeatAcks(t *testing.T) {
+	router, heartbeats := heartbeatRouter(t, func(int) bool { return true })
+	client := NewATPClient(SDKConfig{
+		WSURL:                  router.URL(),
+		SessionID:              "s1",
+		HeartbeatInterval:      5 * time.Millisecond,
+		HeartbeatMissThreshold: 2,
+	})
+	defer client.Close()
+	if !client.LastHeartbeatAck().IsZero() {
+		t.Error("Expected no heartbeat ack before connecting")
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(heartbeats(0)) < 10 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for i, f := range heartbeats(0) {
+		if f.StreamID != "heartbeat_s1" || f.MsgSeq != i+1 {
+			t.Fatalf("Heartbeat %d has stream %q and sequence %d", i, f.StreamID, f.MsgSeq)
+		}
+	}
+	if since := time.Since(client.LastHeartbeatAck()); since > time.Second {
+		t.Errorf("Expected a recent heartbeat ack, got one %v ago", since)
+	}
+	if !client.IsConnected() {
+		t.Error("Acknowledged heartbeats must keep the connection open")
+	}
+}
+
+func TestHeartbeatTimeoutReconnects(t *testing.T) {
+	// Only the second connection is acknowledged
+	router, _ := heartbeatRouter(t, func(conn int) bool { return conn > 0 })
+	disconnects := make(chan error, 1)
+	client := NewATPClient(SDKConfig{
+		WSURL:                  router.URL(),
+		HeartbeatInterval:      5 * time.Millisecond,
+		HeartbeatMissThreshold: 3,
+		AutoReconnect:          true,
+		MaxRetries:             3,
+		RetryDelay:             time.Millisecond,
+		OnDisconnect: func(err error) {
+			select {
+			case disconnects <- err:
+			default:
+			}
+		},
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	select {
+	case err := <-disconnects:
+		if !errors.Is(err, ErrHeartbeatTimeout) {
+			t.Fatalf("Expected ErrHeartbeatTimeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Unacknowledged heartbeats did not close the connection")
+	}
+
+	// Once reconnected, the acknowledged connection stays up
+	deadline := time.Now().Add(2 * time.Second)
+	for client.Stats().Connection.ConnectCount < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if client.Stats().Connection.ConnectCount < 2 {
+		t.Fatal("The client did not reconnect")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if !client.IsConnected() {
+		t.Error("Expected the reconnected client to stay connected")
+	}
+}
+
+func TestHeartbeatTimeoutDisabled(t *testing.T) {
+	router, heartbeats := heartbeatRouter(t, func(int) bool { return false })
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), HeartbeatInterval: 5 * time.Millisecond})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(heartbeats(0)) < 10 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !client.IsConnected() || !client.LastHeartbeatAck().IsZero() {
+		t.Error("Without HeartbeatMissThreshold missing acks must be ignored")
+	}
+}
+
+func TestPoolHeartbeatTimeout(t *testing.T) {
+	var dropMember atomic.Bool
+	router, heartbeats := heartbeatRouter(t, func(conn int) bool { return conn != 1 || !dropMember.Load() })
+	client := NewATPClient(SDKConfig{
+		WSURL:                  router.URL(),
+		SessionID:              "s1",
+		PoolSize:               2,
+		HeartbeatInterval:      5 * time.Millisecond,
+		HeartbeatMissThreshold: 3,
+		MaxRetries:             3,
+		RetryDelay:             time.Millisecond,
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	router.WaitForConnections(t, 2)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(heartbeats(1)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := heartbeats(1); len(got) == 0 || got[0].StreamID != "heartbeat_s1_1" {
+		t.Fatalf("Expected heartbeats on the pool connection's own stream, got %v", got)
+	}
+
+	// The pool connection is redialled on its own
+	dropMember.Store(true)
+	router.WaitForConnections(t, 3)
+	if !client.IsConnected() {
+		t.Error("A pool connection timing out must not close the primary connection")
+	}
+}
+
+func TestHeartbeatStats(t *testing.T) {
+	received := make(chan Frame, 4)
+	var custom atomic.Int32
+	router := newTestRouter(t, func(f Frame) []Frame {
+		switch f.Type {
+		case "heartbeat":
+			received <- f
+		case "custom":
+			custom.Add(1)
+		}
+		return nil
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), HeartbeatInterval: time.Hour, HeartbeatStats: true})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	heartbeat := func() map[string]interface{} {
+		t.Helper()
+		if err := client.sendHeartbeat(); err != nil {
+			t.Fatalf("sendHeartbeat failed: %v", err)
+		}
+		select {
+		case f := <-received:
+			return f.Payload
+		case <-time.After(2 * time.Second):
+			t.Fatal("Heartbeat not received")
+			return nil
+		}
+	}
+
+	client.counters.pending.Store(3)
+	for i := 0; i < 5; i++ {
+		if err := client.sendFrame(Frame{Type: "custom"}); err != nil {
+			t.Fatalf("sendFrame failed: %v", err)
+		}
+	}
+	waitForCount(t, &custom, 5)
+	want := map[string]interface{}{
+		"pending_requests": float64(3),
+		"frames_sent":      float64(5),
+		"frames_received":  float64(0),
+		"reconnects":       float64(0),
+		"sdk_version":      SDKVersion,
+	}
+	if got := heartbeat(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected heartbeat stats %v, want %v", got, want)
+	}
+
+	// Frame counts restart from each heartbeat, the heartbeat included
+	if got := heartbeat(); got["frames_sent"] != float64(1) {
+		t.Errorf("Expected the previous heartbeat as the only frame sent, got %v", got)
+	}
+}
+
+func TestHeartbeatJitter(t *testing.T) {
+	router, heartbeats := heartbeatRouter(t, func(int) bool { return true })
+	clock := newFakeClock()
+	const interval, fraction = 10 * time.Second, 0.2
+	client := NewATPClient(SDKConfig{
+		WSURL:             router.URL(),
+		Clock:             clock,
+		HeartbeatInterval: interval,
+		HeartbeatJitter:   fraction,
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	low := time.Duration(float64(interval) * (1 - fraction))
+	high := time.Duration(float64(interval) * (1 + fraction))
+	delays := make(map[time.Duration]bool)
+	for i := 1; i <= 10; i++ {
+		deadline := time.Now().Add(2 * time.Second)
+		next, ok := clock.Next()
+		for !ok && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+			next, ok = clock.Next()
+		}
+		if !ok {
+			t.Fatalf("Heartbeat %d was not scheduled", i)
+		}
+		if next < low || next > high {
+			t.Fatalf("Heartbeat %d scheduled %v ahead, outside [%v, %v]", i, next, low, high)
+		}
+		delays[next] = true
+
+		clock.Advance(next)
+		for len(heartbeats(0)) < i && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if n := len(heartbeats(0)); n != i {
+			t.Fatalf("Expected %d heartbeats, got %d", i, n)
+		}
+	}
+	if len(delays) < 2 {
+		t.Error("Expected the heartbeat delays to vary")
+	}
+}
+
+func TestJitterBounds(t *testing.T) {
+	for range 1000 {
+		if d := jitter(time.Second, 0.1); d < 900*time.Millisecond || d > 1100*time.Millisecond {
+			t.Fatalf("Jittered delay %v outside 1s ± 10%%", d)
+		}
+	}
+	if d := jitter(time.Second, -1); d != time.Second {
+		t.Errorf("Expected a negative fraction to disable jitter, got %v", d)
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{Clock: clock})
+	defer client.Close()
+
+	client.handleHeartbeatAck(&Frame{Type: FrameTypeHeartbeatAck, Timestamp: FrameTimeOf(clock.Now().Add(3 * time.Second))})
+	if skew := client.ClockSkew(); skew != 3*time.Second {
+		t.Errorf("Expected a 3s skew, got %v", skew)
+	}
+
+	// Acks without a timestamp keep the last measure
+	client.handleHeartbeatAck(&Frame{Type: FrameTypeHeartbeatAck})
+	if skew := client.ClockSkew(); skew != 3*time.Second {
+		t.Errorf("Expected the skew to be kept, got %v", skew)
+	}
+}
@@ -0,0 +1,136 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// FlagHedge marks a duplicate of a request sent by WithHedging
+const FlagHedge = "hedge"
+
+// hedgingPolicy is the setting of WithHedging
+type hedgingPolicy struct {
+	delay     time.Duration
+	maxHedges int
+}
+
+// hedgingKey is the context key of the WithHedging policy of a request
+type hedgingKey struct{}
+
+// hedgeAttemptKey is the context key of the *hedgeAttempt a completion
+// exchange belongs to
+type hedgeAttemptKey struct{}
+
+// hedgeAttempt is one of the concurrent attempts of a hedged request
+type hedgeAttempt struct {
+	duplicate bool        // a hedge rather than the original request
+	lost      atomic.Bool // another attempt won
+}
+
+// contextWithHedging returns ctx carrying policy for the request's
+// completion exchanges
+func contextWithHedging(ctx context.Context, policy *hedgingPolicy) context.Context {
+	if policy == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, hedgingKey{}, policy)
+}
+
+// hedgingFor returns the hedging policy of a completion exchange on
+// streamID under ctx, or nil when it is not to be hedged. Exchanges on a
+// shared stream, streamed to a token callback or already part of a hedged
+// request are not.
+func hedgingFor(ctx context.Context, streamID string) *hedgingPolicy {
+	policy, _ := ctx.Value(hedgingKey{}).(*hedgingPolicy)
+	if policy == nil || streamID != "" || ctx.Value(tokenCallbackKey{}) != nil || hedgeAttemptOf(ctx) != nil {
+		return nil
+	}
+	return policy
+}
+
+// hedgeAttemptOf returns the hedged attempt ctx belongs to, if any
+func hedgeAttemptOf(ctx context.Context) *hedgeAttempt {
+	attempt, _ := ctx.Value(hedgeAttemptKey{}).(*hedgeAttempt)
+	return attempt
+}
+
+// hedgeResult is the outcome of one hedged attempt
+type hedgeResult struct {
+	response *CompletionResponse
+	err      error
+}
+
+// completeHedged completes request, sending a duplicate of it each time
+// policy.delay passes without a response, up to policy.maxHedges of them.
+// The first response wins and the other attempts are canceled. Costs are
+// recorded for the winner only, unless a loser was answered and billed
+// too, in which case both are recorded with a hedge label.
+func (c *ATPClient) completeHedged(ctx context.Context, request CompletionRequest, policy hedgingPolicy) (*CompletionResponse, error) {
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, policy.maxHedges+1)
+	var attempts []*hedgeAttempt
+	launch := func() {
+		attempt := &hedgeAttempt{duplicate: len(attempts) > 0}
+		attempts = append(attempts, attempt)
+		attemptCtx := context.WithValue(hedgeCtx, hedgeAttemptKey{}, attempt)
+		go func() {
+			response, err := c.complete(attemptCtx, "", request)
+			results <- hedgeResult{response: response, err: err}
+		}()
+	}
+
+	launch()
+	running := 1
+	timer := c.config.Clock.After(policy.delay)
+	var winner *CompletionResponse
+	var errs []error
+	for winner == nil && running > 0 {
+		select {
+		case result := <-results:
+			running--
+			if result.err != nil {
+				errs = append(errs, result.err)
+				continue
+			}
+			winner = result.response
+		case <-timer:
+			timer = nil
+			if len(attempts) <= policy.maxHedges {
+				launch()
+				running++
+				timer = c.config.Clock.After(policy.delay)
+			}
+		}
+	}
+	if winner == nil {
+		if len(errs) == 1 {
+			return nil, errs[0]
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	// Stop the losers, and see whether any was answered all the same
+	for _, attempt := range attempts {
+		attempt.lost.Store(true)
+	}
+	cancel()
+	var billed []*CompletionResponse
+	for ; running > 0; running-- {
+		if result := <-results; result.err == nil && result.response.CostUSD > 0 {
+			billed = append(billed, result.response)
+		}
+	}
+	if len(billed) == 0 {
+		c.recordCompletion(winner, nil)
+		return winner, nil
+	}
+	c.recordCompletion(winner, map[string]string{"hedge": "winner"})
+	for _, response := range billed {
+		c.recordCompletion(response, map[string]string{"hedge": "loser"})
+	}
+	return winner, nil
+}
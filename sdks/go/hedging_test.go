@@ -0,0 +1,147 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// costMetrics records the hedge label of each cost increment
+type costMetrics struct {
+	noopMetrics
+	mu    sync.Mutex
+	costs []string
+}
+
+func (m *costMetrics) IncCounter(name string, delta float64, labels map[string]string) {
+	if name != MetricCostUSD {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.costs = append(m.costs, labels["hedge"])
+}
+
+func (m *costMetrics) Costs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.costs)
+}
+
+// hedgingRouter hands completion requests and cancels to the test, which
+// answers them with answer
+func hedgingRouter(t *testing.T) (*testRouter, chan Frame, chan Frame, func(Frame)) {
+	requests, cancels := make(chan Frame, 4), make(chan Frame, 4)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		switch f.Type {
+		case FrameTypeCompletionRequest:
+			requests <- f
+		case FrameTypeCancel:
+			cancels <- f
+		}
+		return nil
+	})
+	answer := func(request Frame) {
+		response := NewFrameBuilder("", "").BuildCompletionResponseFrame(request.StreamID, request.MsgSeq, CompletionResponse{Text: request.StreamID, CostUSD: 0.5})
+		if err := router.Send(response); err != nil {
+			t.Errorf("Failed to answer: %v", err)
+		}
+	}
+	return router, requests, cancels, answer
+}
+
+func TestHedgingSendsDuplicate(t *testing.T) {
+	router, requests, cancels, answer := hedgingRouter(t)
+	metrics := &costMetrics{}
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Metrics: metrics, Logger: &recordingLogger{}})
+	defer client.Close()
+
+	type outcome struct {
+		response *CompletionResponse
+		err      error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi", IdempotencyKey: "key-1"}, WithHedging(20*time.Millisecond, 1))
+		done <- outcome{response, err}
+	}()
+
+	original := receiveFrame(t, requests)
+	hedge := receiveFrame(t, requests)
+	if slices.Contains(original.Flags, FlagHedge) || !slices.Contains(hedge.Flags, FlagHedge) {
+		t.Errorf("Expected only the duplicate flagged, got %v and %v", original.Flags, hedge.Flags)
+	}
+	if hedge.StreamID == original.StreamID || hedge.Payload["idempotency_key"] != "key-1" {
+		t.Errorf("Expected the duplicate on a new stream with the same key, got %+v", hedge)
+	}
+
+	answer(hedge)
+	result := <-done
+	if result.err != nil || result.response.Text != hedge.StreamID {
+		t.Fatalf("Expected the duplicate's response, got %+v, %v", result.response, result.err)
+	}
+	if cancel := receiveFrame(t, cancels); cancel.StreamID != original.StreamID {
+		t.Errorf("Expected the original canceled, got a cancel for %s", cancel.StreamID)
+	}
+	if costs := metrics.Costs(); !slices.Equal(costs, []string{""}) {
+		t.Errorf("Expected only the winner's cost, unlabelled, got %q", costs)
+	}
+}
+
+func TestHedgingSimultaneousCompletion(t *testing.T) {
+	router, requests, _, answer := hedgingRouter(t)
+	metrics := &costMetrics{}
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Metrics: metrics, Logger: &recordingLogger{}})
+	defer client.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithHedging(20*time.Millisecond, 1))
+		done <- err
+	}()
+
+	// Both answers are on the wire before either is read
+	original, hedge := receiveFrame(t, requests), receiveFrame(t, requests)
+	answer(original)
+	answer(hedge)
+	if err := <-done; err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	costs := metrics.Costs()
+	slices.Sort(costs)
+	if !slices.Equal(costs, []string{"loser", "winner"}) && !slices.Equal(costs, []string{""}) {
+		t.Errorf("Expected both costs labelled, or the winner's alone, got %q", costs)
+	}
+	if got := client.Stats().Usage.CostUSD; got != 0.5*float64(len(costs)) {
+		t.Errorf("Expected the recorded costs in the stats, got %v for %q", got, costs)
+	}
+}
+
+func TestHedgingNotNeeded(t *testing.T) {
+	router, requests, _, answer := hedgingRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithHedging(time.Second, 2))
+		done <- err
+	}()
+	answer(receiveFrame(t, requests))
+	if err := <-done; err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	select {
+	case frame := <-requests:
+		t.Errorf("Expected no duplicate, got %+v", frame)
+	default:
+	}
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithHedging(0, 1))
+	if !errors.Is(err, ErrInvalidHedging) {
+		t.Errorf("Expected ErrInvalidHedging, got %v", err)
+	}
+}
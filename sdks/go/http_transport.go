@@ -0,0 +1,153 @@
+package atpsdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Transports selectable with SDKConfig.Transport
+const (
+	// TransportWebSocket keeps a WebSocket connection to the router. It is
+	// the default.
+	TransportWebSocket = "ws"
+	// TransportHTTP POSTs each request frame to the router and reads the
+	// response frame from the HTTP response body.
+	TransportHTTP = "http"
+	// TransportAuto uses a WebSocket connection, and falls back to HTTP if
+	// the WebSocket dial fails.
+	TransportAuto = "auto"
+)
+
+// defaultHTTPFramesPath is the router endpoint frames are POSTed to when
+// SDKConfig.HTTPFramesPath is unset
+const defaultHTTPFramesPath = "/v1/frames"
+
+// maxHTTPResponseBytes bounds the response body read from the router
+const maxHTTPResponseBytes = 16 << 20
+
+// usingHTTP reports whether requests go over the HTTP transport, either
+// because it was configured or because TransportAuto fell back to it
+func (c *ATPClient) usingHTTP() bool {
+	return c.config.Transport == TransportHTTP || c.httpTransport.Load()
+}
+
+// connectHTTP marks the client as connected over the HTTP transport. There
+// is no connection to hold open, so no read loop or heartbeats are started.
+// The caller holds connMutex.
+func (c *ATPClient) connectHTTP() {
+	c.httpTransport.Store(true)
+	c.connected = true
+	c.recordConnect()
+}
+
+// sendRequest sends a frame that expects a response registered with
+// expectResponse, its TTL set from the deadline of ctx. Over HTTP the
+// response arrives in the body of the POST, so ctx bounds the whole
+// exchange.
+func (c *ATPClient) sendRequest(ctx context.Context, frame Frame) error {
+	c.deadlineTTL(ctx, &frame)
+	if c.httpTransport.Load() {
+		return c.postFrame(ctx, frame)
+	}
+	c.trackReplay(frame)
+	return c.sendFrameContext(ctx, frame)
+}
+
+// sendRequestWritten is sendRequest returning once the frame is written to
+// the WebSocket connection, with the write's error
+func (c *ATPClient) sendRequestWritten(ctx context.Context, frame Frame) error {
+	c.deadlineTTL(ctx, &frame)
+	if c.httpTransport.Load() {
+		return c.postFrame(ctx, frame)
+	}
+	c.trackReplay(frame)
+	return c.sendFrameWritten(ctx, frame)
+}
+
+// postFrame POSTs frame to the router and hands the response frame in the
+// body to the same processing as frames read from a WebSocket
+func (c *ATPClient) postFrame(ctx context.Context, frame Frame) error {
+	if c.closed() {
+		return ErrClientClosed
+	}
+	if err := c.prepareOutgoing(&frame); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return fmt.Errorf("failed to marshal frame: %w", err)
+	}
+	if err := c.checkFrameSize(&frame, data); err != nil {
+		return err
+	}
+
+	endpoint, err := url.Parse(c.config.BaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid base URL: %w", err)
+	}
+	endpoint = endpoint.JoinPath(c.config.HTTPFramesPath)
+	query := endpoint.Query()
+	query.Set("session_id", c.config.SessionID)
+	tenantID := frame.Meta.EnvironmentID
+	if tenantID == "" {
+		tenantID = c.config.TenantID
+	}
+	query.Set("tenant_id", tenantID)
+	query.Set("protocol_versions", strings.Join(supportedProtocolVersions, ","))
+	endpoint.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.TokenSource != nil {
+		token, err := c.bearerToken()
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if apiKey := c.apiKey(); apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	c.rawSend(data, false)
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		c.counters.recordError(err)
+		return err
+	}
+	defer resp.Body.Close()
+	c.counters.framesSent.Add(1)
+	c.counters.sentByType.add(frame.Type)
+	c.config.Metrics.IncCounter(MetricFramesSent, 1, map[string]string{"frame_type": frame.Type})
+	c.audit(AuditOutbound, frame, 0)
+	c.recordFrame(AuditOutbound, frame)
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseBytes))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	c.rawReceive(body, false)
+
+	// Error statuses may still carry an error frame for the request
+	var reply Frame
+	if json.Unmarshal(body, &reply) == nil && reply.Type != "" {
+		c.recordFrame(AuditInbound, reply)
+		c.handleIncoming(body, false)
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("router returned HTTP %d", resp.StatusCode)
+		c.counters.recordError(err)
+		return err
+	}
+	return fmt.Errorf("router returned no response frame")
+}
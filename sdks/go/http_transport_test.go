@@ -0,0 +1,150 @@
+package atpsdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newHTTPRouter serves the HTTP transport endpoint, answering each POSTed
+// frame with the frame returned by reply
+func newHTTPRouter(t *testing.T, path string, reply func(r *http.Request, f Frame, w http.ResponseWriter)) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		var frame Frame
+		if err := json.NewDecoder(r.Body).Decode(&frame); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reply(r, frame, w)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeFrame(w http.ResponseWriter, status int, frame Frame) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(frame)
+}
+
+func TestHTTPTransportComplete(t *testing.T) {
+	var auth, session string
+	server := newHTTPRouter(t, "/v1/frames", func(r *http.Request, f Frame, w http.ResponseWriter) {
+		auth, session = r.Header.Get("Authorization"), r.URL.Query().Get("session_id")
+		writeFrame(w, http.StatusOK, Frame{
+			Type:     "completion_response",
+			StreamID: f.StreamID,
+			MsgSeq:   f.MsgSeq,
+			Payload:  map[string]interface{}{"text": "echo: " + getString(f.Payload, "prompt", ""), "cost_usd": 0.5},
+		})
+	})
+
+	client := NewATPClient(SDKConfig{BaseURL: server.URL, Transport: TransportHTTP, APIKey: "key", SessionID: "s1"})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.Text != "echo: hi" || response.CostUSD != 0.5 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if auth != "Bearer key" || session != "s1" {
+		t.Errorf("Unexpected credentials: Authorization %q, session_id %q", auth, session)
+	}
+
+	stats := client.Stats()
+	if stats.Connection.Transport != TransportHTTP || stats.Frames.Sent != 1 || stats.Frames.Received != 1 {
+		t.Errorf("Unexpected stats: %+v %+v", stats.Connection, stats.Frames)
+	}
+}
+
+func TestHTTPTransportErrors(t *testing.T) {
+	server := newHTTPRouter(t, "/custom/frames", func(r *http.Request, f Frame, w http.ResponseWriter) {
+		switch getString(f.Payload, "prompt", "") {
+		case "error frame":
+			writeFrame(w, http.StatusBadRequest, NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeInvalidRequest, "bad prompt"))
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+			writeFrame(w, http.StatusOK, Frame{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq})
+		default:
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}
+	})
+
+	client := NewATPClient(SDKConfig{BaseURL: server.URL, Transport: TransportHTTP, HTTPFramesPath: "/custom/frames"})
+	defer client.Close()
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "error frame"})
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeInvalidRequest {
+		t.Errorf("Expected the router's error frame, got %v", err)
+	}
+
+	_, err = client.Complete(context.Background(), CompletionRequest{Prompt: "plain"})
+	if err == nil || !strings.Contains(err.Error(), "HTTP 500") {
+		t.Errorf("Expected an HTTP status error, got %v", err)
+	}
+
+	_, err = client.Complete(context.Background(), CompletionRequest{Prompt: "slow"}, WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the request deadline to bound the POST, got %v", err)
+	}
+}
+
+func TestHTTPTransportAutoFallback(t *testing.T) {
+	server := newHTTPRouter(t, "/v1/frames", func(r *http.Request, f Frame, w http.ResponseWriter) {
+		writeFrame(w, http.StatusOK, Frame{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}})
+	})
+
+	// Nothing listens on the WebSocket URL
+	client := NewATPClient(SDKConfig{BaseURL: server.URL, WSURL: "ws://127.0.0.1:1", Transport: TransportAuto, Logger: &recordingLogger{}})
+	defer client.Close()
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if transport := client.Stats().Connection.Transport; transport != TransportHTTP {
+		t.Errorf("Expected to fall back to HTTP, using %q", transport)
+	}
+}
+
+func TestHTTPTransportUnsupportedFeatures(t *testing.T) {
+	client := NewATPClient(SDKConfig{Transport: TransportHTTP, Logger: &recordingLogger{}})
+	defer client.Close()
+
+	if _, err := client.HandleCompletions(func(context.Context, CompletionRequest, Meta) (CompletionResponse, error) {
+		return CompletionResponse{}, nil
+	}, AdapterServerConfig{}); !errors.Is(err, ErrNotSupportedByTransport) {
+		t.Errorf("Expected ErrNotSupportedByTransport from HandleCompletions, got %v", err)
+	}
+
+	frames, _ := client.Subscribe("broadcast")
+	if _, ok := <-frames; ok {
+		t.Error("Expected a closed subscription channel")
+	}
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if err := client.sendFrame(client.builder.BuildHeartbeatFrame()); !errors.Is(err, ErrNotSupportedByTransport) {
+		t.Errorf("Expected ErrNotSupportedByTransport for push frames, got %v", err)
+	}
+}
+
+func TestUnknownTransport(t *testing.T) {
+	client := NewATPClient(SDKConfig{Transport: "carrier-pigeon"})
+	defer client.Close()
+	if err := client.Connect(); err == nil || !strings.Contains(err.Error(), "unknown transport") {
+		t.Errorf("Expected an unknown transport error, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package atpsdk
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// idempotencyRouter records the idempotency keys of completion requests. It
+// rejects the first request with an expired token and reports later ones
+// as replayed.
+func idempotencyRouter(t *testing.T) (*testRouter, func() []string) {
+	var mu sync.Mutex
+	var keys []string
+	router := newTestRouter(t, func(f Frame) []Frame {
+		mu.Lock()
+		defer mu.Unlock()
+		switch f.Type {
+		case FrameTypeReauth:
+			return []Frame{{Type: FrameTypeAck, StreamID: f.StreamID, MsgSeq: f.MsgSeq}}
+		case "completion_request":
+			key := getString(f.Payload, "idempotency_key", "")
+			if f.Meta.IdempotencyKey != key {
+				t.Errorf("Payload key %q and meta key %q differ", key, f.Meta.IdempotencyKey)
+			}
+			keys = append(keys, key)
+			if len(keys) == 1 {
+				return []Frame{NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeTokenExpired, "token expired")}
+			}
+			return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok", "replayed": true}}}
+		}
+		return nil
+	})
+	return router, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), keys...)
+	}
+}
+
+func TestIdempotencyKeyReusedOnRetry(t *testing.T) {
+	router, keys := idempotencyRouter(t)
+	client := NewATPClient(SDKConfig{
+		WSURL:       router.URL(),
+		TokenSource: TokenSourceFunc(func() (*Token, error) { return &Token{AccessToken: "token"}, nil }),
+	})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if !response.Replayed {
+		t.Error("Expected the response to be marked as replayed")
+	}
+	got := keys()
+	if len(got) != 2 || !uuidPattern.MatchString(got[0]) || got[1] != got[0] {
+		t.Errorf("Expected one generated key sent twice, got %q", got)
+	}
+
+	// A key set by the caller is sent verbatim
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi", IdempotencyKey: "order-42"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if got := keys(); got[len(got)-1] != "order-42" {
+		t.Errorf("Expected the caller's key, got %q", got)
+	}
+}
+
+func TestIdempotencyKeyWithoutRetries(t *testing.T) {
+	router, keys := idempotencyRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), MaxRetries: -1})
+	defer client.Close()
+
+	_, _ = client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if got := keys(); len(got) != 1 || got[0] != "" {
+		t.Errorf("Expected no key with retries disabled, got %q", got)
+	}
+}
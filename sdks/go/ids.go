@@ -0,0 +1,44 @@
+package atpsdk
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// IDGenerator mints the unique parts of the session, stream and idempotency
+// IDs of a client. Implementations must be safe for concurrent use.
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc adapts a function to the IDGenerator interface, e.g. a
+// counter that makes IDs deterministic in tests
+type IDGeneratorFunc func() string
+
+// NewID returns f()
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// UUIDGenerator is the default IDGenerator, generating random version 4
+// UUIDs from crypto/rand
+type UUIDGenerator struct{}
+
+// NewID returns a new random UUID
+func (UUIDGenerator) NewID() string {
+	return newUUID()
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// newStreamID returns a new stream ID, prefixed with what the stream is for
+func (c *ATPClient) newStreamID(kind string) string {
+	return kind + "_" + c.config.IDGenerator.NewID()
+}
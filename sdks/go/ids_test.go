@@ -0,0 +1,62 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// sequentialIDs returns an IDGenerator minting id-1, id-2, ...
+func sequentialIDs() IDGenerator {
+	var n atomic.Int64
+	return IDGeneratorFunc(func() string { return fmt.Sprintf("id-%d", n.Add(1)) })
+}
+
+func TestDefaultIDsAreUUIDs(t *testing.T) {
+	client := NewATPClient(SDKConfig{})
+	session, ok := strings.CutPrefix(client.config.SessionID, "session_")
+	if !ok || !uuidPattern.MatchString(session) {
+		t.Errorf("Expected a UUID session ID, got %q", client.config.SessionID)
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		id := client.newStreamID("completion")
+		if seen[id] {
+			t.Fatalf("Duplicate stream ID %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestIDGenerator(t *testing.T) {
+	streams := make(chan Frame, 1)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		streams <- f
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), IDGenerator: sequentialIDs()})
+	defer client.Close()
+	if client.config.SessionID != "session_id-1" {
+		t.Errorf("Expected the generated session ID, got %q", client.config.SessionID)
+	}
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	request := <-streams
+	if request.StreamID != "completion_id-4" || request.Meta.IdempotencyKey != "id-3" || request.Meta.CorrelationID != "id-2" {
+		t.Errorf("Expected deterministic IDs, got stream %q, key %q and correlation ID %q", request.StreamID, request.Meta.IdempotencyKey, request.Meta.CorrelationID)
+	}
+
+	// A SessionID set by the caller is kept
+	client = NewATPClient(SDKConfig{SessionID: "my-session", IDGenerator: sequentialIDs()})
+	if client.config.SessionID != "my-session" {
+		t.Errorf("Expected the caller's session ID, got %q", client.config.SessionID)
+	}
+}
@@ -0,0 +1,30 @@
+package atpsdk
+
+import (
+	"fmt"
+	"maps"
+)
+
+// ClientVersionInterceptor returns a send interceptor that stamps every
+// outgoing frame with a "client_version" payload key. The payload is
+// copied first, as it may be a map owned by the caller.
+func ClientVersionInterceptor(version string) func(*Frame) error {
+	return func(frame *Frame) error {
+		payload := make(map[string]interface{}, len(frame.Payload)+1)
+		maps.Copy(payload, frame.Payload)
+		payload["client_version"] = version
+		frame.Payload = payload
+		return nil
+	}
+}
+
+// runInterceptors applies interceptors to frame in order, stopping at the
+// first error.
+func runInterceptors(interceptors []func(*Frame) error, frame *Frame) error {
+	for i, intercept := range interceptors {
+		if err := intercept(frame); err != nil {
+			return fmt.Errorf("interceptor %d rejected %s frame: %w", i, frame.Type, err)
+		}
+	}
+	return nil
+}
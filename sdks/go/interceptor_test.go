@@ -0,0 +1,190 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.lines...)
+}
+
+func TestClientVersionInterceptor(t *testing.T) {
+	frame := Frame{Type: "heartbeat"}
+	if err := ClientVersionInterceptor("1.2.3")(&frame); err != nil {
+		t.Fatalf("Interceptor returned error: %v", err)
+	}
+	if frame.Payload["client_version"] != "1.2.3" {
+		t.Errorf("Expected client_version '1.2.3', got '%v'", frame.Payload["client_version"])
+	}
+
+	// The caller's payload map is left untouched
+	payload := map[string]interface{}{"text": "hi"}
+	frame = Frame{Type: "stream_data", Payload: payload}
+	if err := ClientVersionInterceptor("1.2.3")(&frame); err != nil {
+		t.Fatalf("Interceptor returned error: %v", err)
+	}
+	if _, ok := payload["client_version"]; ok {
+		t.Error("Interceptor must not modify the caller's payload")
+	}
+	if frame.Payload["text"] != "hi" || frame.Payload["client_version"] != "1.2.3" {
+		t.Errorf("Unexpected payload %v", frame.Payload)
+	}
+}
+
+func TestSendInterceptorOrdering(t *testing.T) {
+	received := make(chan Frame, 1)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		received <- f
+		return nil
+	})
+
+	var order []string
+	client := NewATPClient(SDKConfig{
+		WSURL: router.URL(),
+		SendInterceptors: []func(*Frame) error{
+			func(f *Frame) error {
+				order = append(order, "first")
+				f.Payload["stamp"] = "first"
+				return nil
+			},
+			func(f *Frame) error {
+				order = append(order, "second")
+				f.Payload["stamp"] = f.Payload["stamp"].(string) + ",second"
+				return nil
+			},
+			ClientVersionInterceptor(SDKVersion),
+		},
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Disconnect()
+
+	fb := NewFrameBuilder("s", "t")
+	if err := client.sendFrame(fb.BuildHeartbeatFrame()); err != nil {
+		t.Fatalf("sendFrame failed: %v", err)
+	}
+
+	select {
+	case f := <-received:
+		if f.Payload["stamp"] != "first,second" {
+			t.Errorf("Expected stamp 'first,second', got '%v'", f.Payload["stamp"])
+		}
+		if f.Payload["client_version"] != SDKVersion {
+			t.Errorf("Expected client_version '%s', got '%v'", SDKVersion, f.Payload["client_version"])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Router did not receive the frame")
+	}
+	if strings.Join(order, ",") != "first,second" {
+		t.Errorf("Expected interceptors to run in order, got %v", order)
+	}
+}
+
+func TestSendInterceptorAbort(t *testing.T) {
+	received := make(chan Frame, 1)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		received <- f
+		return nil
+	})
+
+	errBlocked := errors.New("blocked")
+	laterCalled := false
+	client := NewATPClient(SDKConfig{
+		WSURL: router.URL(),
+		SendInterceptors: []func(*Frame) error{
+			func(*Frame) error { return errBlocked },
+			func(*Frame) error {
+				laterCalled = true
+				return nil
+			},
+		},
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Disconnect()
+
+	err := client.sendFrame(NewFrameBuilder("s", "t").BuildHeartbeatFrame())
+	if !errors.Is(err, errBlocked) {
+		t.Fatalf("Expected send to fail with interceptor error, got %v", err)
+	}
+	if laterCalled {
+		t.Error("Interceptors after the failing one should not run")
+	}
+
+	select {
+	case f := <-received:
+		t.Errorf("Aborted frame reached the router: %+v", f)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestReceiveInterceptorDrop(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		return []Frame{{
+			Type:     "completion_response",
+			StreamID: f.StreamID,
+			MsgSeq:   f.MsgSeq,
+			Payload:  map[string]interface{}{"text": "ok"},
+		}}
+	})
+
+	logger := &recordingLogger{}
+	seen := make(chan string, 10)
+	client := NewATPClient(SDKConfig{
+		WSURL:          router.URL(),
+		DefaultTimeout: 200 * time.Millisecond,
+		Logger:         logger,
+		ReceiveInterceptors: []func(*Frame) error{
+			func(f *Frame) error {
+				seen <- "first:" + f.Type
+				return nil
+			},
+			func(f *Frame) error {
+				return errors.New("experiment rejects responses")
+			},
+		},
+	})
+	defer client.Disconnect()
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if err == nil {
+		t.Fatal("Expected Complete to time out once the response is dropped")
+	}
+
+	select {
+	case got := <-seen:
+		if got != "first:completion_response" {
+			t.Errorf("Expected first interceptor to see the response, got %s", got)
+		}
+	default:
+		t.Error("First receive interceptor did not run")
+	}
+	lines := logger.Lines()
+	if len(lines) == 0 || !strings.Contains(lines[0], "experiment rejects responses") {
+		t.Errorf("Expected a logged warning for the dropped frame, got %v", lines)
+	}
+}
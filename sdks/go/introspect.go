@@ -0,0 +1,199 @@
+package atpsdk
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Frame types used by router-side tooling to ask the client to describe
+// itself
+const (
+	FrameTypeIntrospectRequest  = "introspect.request"
+	FrameTypeIntrospectResponse = "introspect.response"
+)
+
+// defaultIntrospectionInterval is the minimum time between two
+// introspection responses when IntrospectionConfig.MinInterval is unset
+const defaultIntrospectionInterval = 10 * time.Second
+
+// IntrospectionConfig controls how the client answers introspect.request
+// frames
+type IntrospectionConfig struct {
+	// Disabled makes the client ignore introspect.request frames.
+	Disabled bool
+	// MinInterval is the minimum time between two responses (default:
+	// 10s). Requests arriving sooner are answered with a RATE_LIMITED error.
+	MinInterval time.Duration
+}
+
+// Introspection is the client's self-description, sent in response to an
+// introspect.request frame. It never contains credentials.
+type Introspection struct {
+	SDKVersion      string              `json:"sdk_version"`
+	ProtocolVersion string              `json:"protocol_version"`
+	TenantID        string              `json:"tenant_id"`
+	Features        map[string]bool     `json:"features"`
+	FrameTypes      []FrameTypeSpec     `json:"frame_types"`
+	Limits          IntrospectionLimits `json:"limits"`
+	Stats           ClientStats         `json:"stats"`
+}
+
+// FrameTypeSpec describes a frame type the client sends or handles
+type FrameTypeSpec struct {
+	Type string `json:"type"`
+	// Direction is "inbound", "outbound" or "both", seen from the client
+	Direction   string `json:"direction"`
+	Description string `json:"description,omitempty"`
+	// Custom marks frame types the SDK has no built-in handling for but
+	// that the application registered or subscribed to
+	Custom      bool `json:"custom,omitempty"`
+	Subscribers int  `json:"subscribers,omitempty"`
+}
+
+// IntrospectionLimits lists the configured limits of the client
+type IntrospectionLimits struct {
+	DefaultTimeoutMS        int64   `json:"default_timeout_ms"`
+	MaxRetries              int     `json:"max_retries"`
+	RetryDelayMS            int64   `json:"retry_delay_ms"`
+	HeartbeatIntervalMS     int64   `json:"heartbeat_interval_ms"`
+	PoolSize                int     `json:"pool_size"`
+	SubscriptionBuffer      int     `json:"subscription_buffer"`
+	BudgetLimitUSD          float64 `json:"budget_limit_usd,omitempty"`
+	IntrospectionIntervalMS int64   `json:"introspection_interval_ms"`
+}
+
+// introspectionLimiter remembers when the last introspection response was
+// sent
+type introspectionLimiter struct {
+	lastAnswered atomic.Int64 // unix nanoseconds
+}
+
+// Introspect returns the description the client sends in response to an
+// introspect.request frame
+func (c *ATPClient) Introspect() Introspection {
+	stats := c.Stats()
+	stats.Endpoint.URL = redactURL(stats.Endpoint.URL)
+
+	return Introspection{
+		SDKVersion:      SDKVersion,
+		ProtocolVersion: ProtocolVersion,
+		TenantID:        c.config.TenantID,
+		Features: map[string]bool{
+			"compression":       false,
+			"signing":           c.verificationEnabled(),
+			"encryption":        c.config.PayloadCipher != nil,
+			"resume":            c.replayEnabled(),
+			"outbox":            c.outboxEnabled(),
+			"binary_frames":     c.usesCodec(),
+			"pooling":           c.pool != nil,
+			"http_transport":    c.usingHTTP(),
+			"cancel_on_timeout": c.config.CancelOnTimeout,
+			"budget":            c.budgetEnabled(),
+			"audit":             c.config.AuditSink != nil,
+			"recording":         c.config.Recorder != nil,
+			"payload_schemas":   len(c.config.PayloadSchemas) > 0,
+			"mtls":              c.tlsHasClientCert(),
+		},
+		FrameTypes: c.introspectFrameTypes(),
+		Limits: IntrospectionLimits{
+			DefaultTimeoutMS:        c.config.DefaultTimeout.Milliseconds(),
+			MaxRetries:              c.config.MaxRetries,
+			RetryDelayMS:            c.config.RetryDelay.Milliseconds(),
+			HeartbeatIntervalMS:     c.heartbeatInterval().Milliseconds(),
+			PoolSize:                max(c.config.PoolSize, 1),
+			SubscriptionBuffer:      c.config.SubscriptionBuffer,
+			BudgetLimitUSD:          c.config.Budget.LimitUSD,
+			IntrospectionIntervalMS: c.config.Introspection.MinInterval.Milliseconds(),
+		},
+		Stats: stats,
+	}
+}
+
+// introspectFrameTypes lists the frame types of DefaultFrameTypes, with
+// their subscriber counts, followed by any other subscribed frame type
+func (c *ATPClient) introspectFrameTypes() []FrameTypeSpec {
+	c.subMutex.RLock()
+	subscribers := make(map[string]int, len(c.subscriptions))
+	for frameType, subs := range c.subscriptions {
+		subscribers[frameType] = len(subs)
+	}
+	c.subMutex.RUnlock()
+
+	types := DefaultFrameTypes.Types()
+	specs := make([]FrameTypeSpec, 0, len(types)+len(subscribers))
+	for _, frameType := range types {
+		info, _ := DefaultFrameTypes.Lookup(frameType)
+		specs = append(specs, FrameTypeSpec{
+			Type:        frameType,
+			Direction:   info.Direction,
+			Description: info.Description,
+			Custom:      DefaultFrameTypes.isCustom(frameType),
+			Subscribers: subscribers[frameType],
+		})
+		delete(subscribers, frameType)
+	}
+	for _, frameType := range slices.Sorted(maps.Keys(subscribers)) {
+		specs = append(specs, FrameTypeSpec{
+			Type:        frameType,
+			Direction:   "inbound",
+			Custom:      true,
+			Subscribers: subscribers[frameType],
+		})
+	}
+	return specs
+}
+
+// handleIntrospectRequest answers an introspect.request frame unless
+// introspection is disabled. It is called from the read loop, so the
+// response is sent from its own goroutine.
+func (c *ATPClient) handleIntrospectRequest(frame *Frame) {
+	if c.config.Introspection.Disabled {
+		return
+	}
+
+	builder := c.newFrameBuilder(c.config.TenantID)
+	var reply Frame
+	if c.allowIntrospection() {
+		reply = builder.BuildIntrospectionFrame(frame.StreamID, frame.MsgSeq, c.Introspect())
+	} else {
+		reply = builder.BuildErrorFrame(frame.StreamID, frame.MsgSeq, ErrorCodeRateLimited,
+			fmt.Sprintf("introspection is limited to one response per %v", c.config.Introspection.MinInterval))
+	}
+
+	go func() {
+		if err := c.sendFrame(reply); err != nil {
+			c.reportAsyncError(fmt.Errorf("failed to answer introspection request: %w", err))
+		}
+	}()
+}
+
+// allowIntrospection reports whether a response may be sent now, and if so
+// records it as the last one
+func (c *ATPClient) allowIntrospection() bool {
+	now := c.config.Clock.Now().UnixNano()
+	for {
+		last := c.introspection.lastAnswered.Load()
+		if last != 0 && now-last < int64(c.config.Introspection.MinInterval) {
+			return false
+		}
+		if c.introspection.lastAnswered.CompareAndSwap(last, now) {
+			return true
+		}
+	}
+}
+
+// redactURL strips credentials and query parameters, which may carry API
+// keys, from a URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	u.User = nil
+	u.RawQuery = ""
+	return u.String()
+}
@@ -0,0 +1,203 @@
+package atpsdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// introspectionSchema maps the dotted path of every required field of an
+// introspection payload to its JSON type
+var introspectionSchema = map[string]string{
+	"sdk_version":                      "string",
+	"protocol_version":                 "string",
+	"tenant_id":                        "string",
+	"features":                         "object",
+	"features.compression":             "boolean",
+	"features.signing":                 "boolean",
+	"features.resume":                  "boolean",
+	"features.pooling":                 "boolean",
+	"frame_types":                      "array",
+	"limits":                           "object",
+	"limits.default_timeout_ms":        "number",
+	"limits.max_retries":               "number",
+	"limits.heartbeat_interval_ms":     "number",
+	"limits.pool_size":                 "number",
+	"limits.introspection_interval_ms": "number",
+	"stats":                            "object",
+	"stats.connection.connected":       "boolean",
+	"stats.frames.sent":                "number",
+	"stats.pending.count":              "number",
+	"stats.endpoint.url":               "string",
+}
+
+// validateSchema checks doc against a schema of dotted paths to JSON types
+func validateSchema(doc map[string]interface{}, schema map[string]string) []string {
+	var problems []string
+	for path, want := range schema {
+		var value interface{} = doc
+		for _, key := range strings.Split(path, ".") {
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = obj[key]
+		}
+
+		var got string
+		switch value.(type) {
+		case string:
+			got = "string"
+		case float64:
+			got = "number"
+		case bool:
+			got = "boolean"
+		case map[string]interface{}:
+			got = "object"
+		case []interface{}:
+			got = "array"
+		default:
+			got = "missing"
+		}
+		if got != want {
+			problems = append(problems, fmt.Sprintf("%s: want %s, got %s", path, want, got))
+		}
+	}
+	return problems
+}
+
+// introspectRouter passes the introspection responses and errors the client
+// sends to the test
+func introspectRouter(t *testing.T) (*testRouter, <-chan Frame) {
+	replies := make(chan Frame, 8)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type == FrameTypeIntrospectResponse || f.Type == "error" {
+			replies <- f
+		}
+		return nil
+	})
+	return router, replies
+}
+
+func introspect(t *testing.T, router *testRouter, streamID string) {
+	t.Helper()
+	if err := router.SendTo(0, Frame{Type: FrameTypeIntrospectRequest, StreamID: streamID, MsgSeq: 1}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+}
+
+func nextReply(t *testing.T, replies <-chan Frame) Frame {
+	t.Helper()
+	select {
+	case f := <-replies:
+		return f
+	case <-time.After(2 * time.Second):
+		t.Fatal("No reply to the introspection request")
+		return Frame{}
+	}
+}
+
+func TestIntrospectionResponse(t *testing.T) {
+	router, replies := introspectRouter(t)
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{
+		WSURL:    router.URL() + "?token=query-secret",
+		APIKey:   "sk-api-secret",
+		PoolSize: 2,
+		Clock:    clock,
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	_, unsubscribe := client.Subscribe("custom.event")
+	defer unsubscribe()
+	router.WaitForConnections(t, 2)
+
+	introspect(t, router, "introspect_1")
+	reply := nextReply(t, replies)
+	if reply.Type != FrameTypeIntrospectResponse || reply.StreamID != "introspect_1" || reply.MsgSeq != 1 {
+		t.Fatalf("Unexpected reply: %+v", reply)
+	}
+
+	payload, ok := reply.Payload["introspection"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Reply has no introspection payload: %v", reply.Payload)
+	}
+	for _, problem := range validateSchema(payload, introspectionSchema) {
+		t.Error(problem)
+	}
+	if payload["sdk_version"] != SDKVersion || payload["features"].(map[string]interface{})["pooling"] != true {
+		t.Errorf("Unexpected description: %v", payload)
+	}
+
+	var custom bool
+	for _, spec := range payload["frame_types"].([]interface{}) {
+		spec := spec.(map[string]interface{})
+		if spec["type"] == "custom.event" {
+			custom = spec["custom"] == true && spec["subscribers"] == 1.0
+		}
+	}
+	if !custom {
+		t.Errorf("Subscribed frame type missing from frame_types: %v", payload["frame_types"])
+	}
+
+	data, _ := json.Marshal(reply)
+	for _, secret := range []string{"sk-api-secret", "query-secret", client.config.SessionID} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Introspection response leaks %q", secret)
+		}
+	}
+}
+
+func TestIntrospectionRateLimit(t *testing.T) {
+	router, replies := introspectRouter(t)
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{
+		WSURL:         router.URL(),
+		Clock:         clock,
+		Introspection: IntrospectionConfig{MinInterval: time.Minute},
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	router.WaitForConnections(t, 1)
+
+	introspect(t, router, "first")
+	if reply := nextReply(t, replies); reply.Type != FrameTypeIntrospectResponse {
+		t.Fatalf("Expected an introspection response, got %+v", reply)
+	}
+
+	introspect(t, router, "second")
+	reply := nextReply(t, replies)
+	if err := parseErrorFrame(&reply).(*ATPError); reply.Type != "error" || reply.StreamID != "second" || err.Code != ErrorCodeRateLimited {
+		t.Fatalf("Expected a RATE_LIMITED error, got %+v", reply)
+	}
+
+	clock.Advance(time.Minute)
+	introspect(t, router, "third")
+	if reply := nextReply(t, replies); reply.Type != FrameTypeIntrospectResponse || reply.StreamID != "third" {
+		t.Fatalf("Expected an introspection response after the interval, got %+v", reply)
+	}
+}
+
+func TestIntrospectionDisabled(t *testing.T) {
+	router, replies := introspectRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Introspection: IntrospectionConfig{Disabled: true}})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	router.WaitForConnections(t, 1)
+
+	introspect(t, router, "ignored")
+	select {
+	case reply := <-replies:
+		t.Fatalf("Disabled introspection must not answer, got %+v", reply)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,15 @@
+package atpsdk
+
+import "fmt"
+
+// Logger is the logging interface used by the SDK. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// stdoutLogger is the default Logger, printing to standard output
+type stdoutLogger struct{}
+
+func (stdoutLogger) Printf(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
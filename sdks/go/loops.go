@@ -0,0 +1,237 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// loopHandle controls a background loop goroutine
+type loopHandle struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newLoopHandle(ctx context.Context) (context.Context, loopHandle) {
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, loopHandle{cancel: cancel, done: make(chan struct{})}
+}
+
+// Stop stops the loop and waits for it to exit
+func (h loopHandle) Stop() {
+	h.cancel()
+	<-h.done
+}
+
+// Done is closed when the loop has exited
+func (h loopHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// lastSuccess records the time of a loop's last successful send
+type lastSuccess struct {
+	ns atomic.Int64 // unix nanoseconds, zero until the first success
+}
+
+func (s *lastSuccess) store(t time.Time) {
+	s.ns.Store(t.UnixNano())
+}
+
+func (s *lastSuccess) load() time.Time {
+	ns := s.ns.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// CapabilityLoop periodically re-advertises an adapter's capabilities so
+// that the registration survives the router's capability TTL and restarts.
+type CapabilityLoop struct {
+	loopHandle
+	lastAdvertised lastSuccess
+}
+
+// StartCapabilityLoop advertises capability immediately, then again every
+// interval and right after every reconnect. The loop stops when ctx is
+// cancelled, when the client is closed, or when Stop is called. Failed
+// advertisements are logged and reported through OnAsyncError; the loop
+// keeps running.
+func (c *ATPClient) StartCapabilityLoop(ctx context.Context, capability CapabilityAdvertisement, interval time.Duration) (*CapabilityLoop, error) {
+	if c.closed() {
+		return nil, ErrClientClosed
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("capability loop interval must be positive, got %v", interval)
+	}
+
+	ctx, handle := newLoopHandle(ctx)
+	epoch := c.epoch()
+	loop := &CapabilityLoop{loopHandle: handle}
+	connects, unwatch := c.watchConnects()
+
+	go func() {
+		defer close(loop.done)
+		defer unwatch()
+		defer loop.cancel()
+
+		ticker := c.newTicker(interval)
+		defer ticker.Stop()
+
+		advertise := func() {
+			// Bound each attempt so an unacknowledged advertisement cannot
+			// hold up the next one
+			attemptCtx, attemptCancel := context.WithTimeout(ctx, min(interval, c.config.DefaultTimeout))
+			defer attemptCancel()
+			if err := c.AdvertiseCapabilities(attemptCtx, capability); err != nil {
+				if ctx.Err() == nil && !c.closed() {
+					c.config.Logger.Printf("Warning: Capability advertisement failed: %v", err)
+					c.reportAsyncError(fmt.Errorf("capability advertisement failed: %w", err))
+				}
+				return
+			}
+			loop.lastAdvertised.store(c.config.Clock.Now())
+
+			// Advertising may itself have reconnected; that connection is
+			// already covered
+			select {
+			case <-connects:
+			default:
+			}
+		}
+
+		advertise()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-epoch.Done():
+				return
+			case <-ticker.C():
+				advertise()
+			case <-connects:
+				advertise()
+			}
+		}
+	}()
+
+	return loop, nil
+}
+
+// LastAdvertised returns the time of the last successful advertisement, or
+// the zero time if none has succeeded yet.
+func (l *CapabilityLoop) LastAdvertised() time.Time {
+	return l.lastAdvertised.load()
+}
+
+// HealthLoop periodically reports adapter health to the router
+type HealthLoop struct {
+	loopHandle
+	lastReported lastSuccess
+}
+
+// StartHealthLoop calls collect on every tick of interval and sends the
+// result as a health report for adapterID, with status "paused" while
+// adapterID is paused with PauseAdapter. Reports are sent one at a time
+// from the loop goroutine, so ticks that arrive while a slow send is in
+// flight are dropped rather than queued. Failed sends are logged and
+// counted in MetricHealthReportFailures; the loop keeps running. It stops
+// when ctx is cancelled, when the client is disconnected or closed, or
+// when Stop is called.
+func (c *ATPClient) StartHealthLoop(ctx context.Context, adapterID string, interval time.Duration, collect func() HealthStatus) (*HealthLoop, error) {
+	if c.closed() {
+		return nil, ErrClientClosed
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("health loop interval must be positive, got %v", interval)
+	}
+
+	ctx, handle := newLoopHandle(ctx)
+	epoch := c.epoch()
+	loop := &HealthLoop{loopHandle: handle}
+
+	go func() {
+		defer close(loop.done)
+		defer loop.cancel()
+
+		ticker := c.newTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-epoch.Done():
+				return
+			case <-ticker.C():
+			}
+
+			health := collect()
+			health.AdapterID = adapterID
+			if c.IsAdapterPaused(adapterID) {
+				health.Status = HealthStatusPaused
+			}
+
+			attemptCtx, attemptCancel := context.WithTimeout(ctx, min(interval, c.config.DefaultTimeout))
+			err := c.ReportHealth(attemptCtx, health)
+			attemptCancel()
+			if err != nil {
+				if ctx.Err() != nil || c.closed() {
+					return
+				}
+				c.config.Logger.Printf("Warning: Health report failed: %v", err)
+				c.config.Metrics.IncCounter(MetricHealthReportFailures, 1, map[string]string{"adapter_id": adapterID})
+				continue
+			}
+			loop.lastReported.store(c.config.Clock.Now())
+		}
+	}()
+
+	return loop, nil
+}
+
+// LastReported returns the time of the last successful health report, or
+// the zero time if none has succeeded yet.
+func (l *HealthLoop) LastReported() time.Time {
+	return l.lastReported.load()
+}
+
+// connectWatchers tracks internal listeners notified on every new connection
+type connectWatchers struct {
+	mu       sync.Mutex
+	watchers map[chan struct{}]struct{}
+}
+
+// watchConnects returns a channel signalled after every successful Connect,
+// and a function that stops watching. Signals are coalesced.
+func (c *ATPClient) watchConnects() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	c.connects.mu.Lock()
+	if c.connects.watchers == nil {
+		c.connects.watchers = make(map[chan struct{}]struct{})
+	}
+	c.connects.watchers[ch] = struct{}{}
+	c.connects.mu.Unlock()
+
+	return ch, func() {
+		c.connects.mu.Lock()
+		delete(c.connects.watchers, ch)
+		c.connects.mu.Unlock()
+	}
+}
+
+// notifyConnected signals every connect watcher without blocking
+func (c *ATPClient) notifyConnected() {
+	c.connects.mu.Lock()
+	defer c.connects.mu.Unlock()
+
+	for ch := range c.connects.watchers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
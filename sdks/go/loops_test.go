@@ -0,0 +1,223 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ackingRouter acknowledges every capability frame and counts them
+func ackingRouter(t *testing.T, frameType string, count *atomic.Int32) *testRouter {
+	return newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != frameType {
+			return nil
+		}
+		count.Add(1)
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq}}
+	})
+}
+
+func waitForCount(t *testing.T, count *atomic.Int32, want int32) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for count.Load() < want && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+	if got := count.Load(); got != want {
+		t.Fatalf("Expected %d frames, got %d", want, got)
+	}
+}
+
+func waitForWaiters(t *testing.T, clock *fakeClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for clock.Waiters() < n && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+	if clock.Waiters() < n {
+		t.Fatalf("Expected %d pending timer(s), got %d", n, clock.Waiters())
+	}
+}
+
+func TestCapabilityLoop(t *testing.T) {
+	var adverts atomic.Int32
+	router := ackingRouter(t, "adapter.capability", &adverts)
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Clock: clock, HeartbeatJitter: -1, Logger: &recordingLogger{}})
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	loop, err := client.StartCapabilityLoop(ctx, CapabilityAdvertisement{AdapterID: "a1"}, 30*time.Second)
+	if err != nil {
+		t.Fatalf("StartCapabilityLoop failed: %v", err)
+	}
+
+	// Advertised immediately; the loop's ticker joins the heartbeat's
+	waitForCount(t, &adverts, 1)
+	waitForWaiters(t, clock, 2)
+	deadline := time.Now().Add(2 * time.Second)
+	for loop.LastAdvertised().IsZero() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !loop.LastAdvertised().Equal(clock.Now()) {
+		t.Errorf("Expected LastAdvertised %v, got %v", clock.Now(), loop.LastAdvertised())
+	}
+
+	// Re-advertised on every tick
+	clock.Advance(30 * time.Second)
+	waitForCount(t, &adverts, 2)
+	clock.Advance(30 * time.Second)
+	waitForCount(t, &adverts, 3)
+
+	// Re-advertised right after a reconnect, without waiting for a tick
+	router.DropConnections()
+	deadline = time.Now().Add(2 * time.Second)
+	for client.IsConnected() && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	waitForCount(t, &adverts, 4)
+
+	cancel()
+	select {
+	case <-loop.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Loop did not stop on context cancellation")
+	}
+	clock.Advance(time.Minute)
+	time.Sleep(20 * time.Millisecond)
+	if got := adverts.Load(); got != 4 {
+		t.Errorf("Expected no advertisements after stop, got %d", got)
+	}
+}
+
+func TestCapabilityLoopStopsOnClose(t *testing.T) {
+	var adverts atomic.Int32
+	router := ackingRouter(t, "adapter.capability", &adverts)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Clock: newFakeClock()})
+
+	loop, err := client.StartCapabilityLoop(context.Background(), CapabilityAdvertisement{AdapterID: "a1"}, time.Second)
+	if err != nil {
+		t.Fatalf("StartCapabilityLoop failed: %v", err)
+	}
+	waitForCount(t, &adverts, 1)
+
+	client.Close()
+	select {
+	case <-loop.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Loop did not stop when the client was closed")
+	}
+
+	if _, err := client.StartCapabilityLoop(context.Background(), CapabilityAdvertisement{}, time.Second); err != ErrClientClosed {
+		t.Errorf("Expected ErrClientClosed, got %v", err)
+	}
+}
+
+func TestHealthLoopReportsOncePerTick(t *testing.T) {
+	var reports atomic.Int32
+	router := ackingRouter(t, "adapter.health", &reports)
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Clock: clock, HeartbeatJitter: -1})
+	defer client.Close()
+
+	var collected atomic.Int32
+	loop, err := client.StartHealthLoop(context.Background(), "adapter-1", 10*time.Second, func() HealthStatus {
+		collected.Add(1)
+		return HealthStatus{Status: "healthy"}
+	})
+	if err != nil {
+		t.Fatalf("StartHealthLoop failed: %v", err)
+	}
+	waitForWaiters(t, clock, 1)
+
+	const ticks = 5
+	for i := int32(1); i <= ticks; i++ {
+		clock.Advance(10 * time.Second)
+		waitForCount(t, &reports, i)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if reports.Load() != ticks || collected.Load() != ticks {
+		t.Errorf("Expected exactly %d reports and collections, got %d and %d", ticks, reports.Load(), collected.Load())
+	}
+	if !loop.LastReported().Equal(clock.Now()) {
+		t.Errorf("Expected LastReported %v, got %v", clock.Now(), loop.LastReported())
+	}
+
+	client.Disconnect()
+	select {
+	case <-loop.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Loop did not stop on Disconnect")
+	}
+}
+
+func TestHealthLoopSurvivesSendFailures(t *testing.T) {
+	router := newTestRouter(t, nil)
+	clock := newFakeClock()
+	metrics := newCountingMetrics()
+	logger := &recordingLogger{}
+	client := NewATPClient(SDKConfig{
+		WSURL:           router.URL(),
+		Clock:           clock,
+		HeartbeatJitter: -1,
+		Metrics:         metrics,
+		Logger:          logger,
+		SendInterceptors: []func(*Frame) error{func(f *Frame) error {
+			if f.Type == "adapter.health" {
+				return errors.New("rejected")
+			}
+			return nil
+		}},
+	})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	loop, err := client.StartHealthLoop(ctx, "adapter-1", time.Second, func() HealthStatus {
+		return HealthStatus{Status: "degraded"}
+	})
+	if err != nil {
+		t.Fatalf("StartHealthLoop failed: %v", err)
+	}
+	// The heartbeat ticker and the loop's
+	waitForWaiters(t, clock, 2)
+
+	goroutines := runtime.NumGoroutine()
+	const ticks = 4
+	for i := 1; i <= ticks; i++ {
+		clock.Advance(time.Second)
+		deadline := time.Now().Add(2 * time.Second)
+		for metrics.Counter(MetricHealthReportFailures) < float64(i) && time.Now().Before(deadline) {
+			time.Sleep(2 * time.Millisecond)
+		}
+	}
+	if got := metrics.Counter(MetricHealthReportFailures); got != ticks {
+		t.Fatalf("Expected %d recorded failures, got %v", ticks, got)
+	}
+	if !loop.LastReported().IsZero() {
+		t.Error("Expected no successful report")
+	}
+	if !strings.Contains(strings.Join(logger.Lines(), "\n"), "Health report failed") {
+		t.Error("Expected failures to be logged")
+	}
+	if now := runtime.NumGoroutine(); now > goroutines {
+		t.Errorf("Goroutines grew from %d to %d across failed sends", goroutines, now)
+	}
+
+	cancel()
+	select {
+	case <-loop.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Loop did not stop on context cancellation")
+	}
+}
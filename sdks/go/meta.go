@@ -0,0 +1,189 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+)
+
+// Risk levels carried in Meta.Risk
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+// knownRisks are the values MetaBuilder accepts for Meta.Risk
+var knownRisks = map[string]bool{RiskLow: true, RiskMedium: true, RiskHigh: true}
+
+// MetaBuilder builds a Meta with chainable setters, checking values as
+// they are set. The first invalid value is reported by Build.
+//
+//	meta, err := client.NewMeta().
+//		WithTaskType("code_review").
+//		WithRisk(atpsdk.RiskMedium).
+//		WithDataScope("no_secrets").
+//		Build()
+type MetaBuilder struct {
+	defaults Meta
+	meta     Meta
+	err      error
+}
+
+// NewMetaBuilder returns a MetaBuilder without defaults
+func NewMetaBuilder() *MetaBuilder {
+	return &MetaBuilder{}
+}
+
+// NewMeta returns a MetaBuilder whose Meta is merged over the client's
+// defaults, such as the tenant as EnvironmentID
+func (c *ATPClient) NewMeta() *MetaBuilder {
+	return &MetaBuilder{defaults: Meta{EnvironmentID: c.config.TenantID}}
+}
+
+// WithTaskType sets the task type, e.g. "qa" or "code_review"
+func (b *MetaBuilder) WithTaskType(taskType string) *MetaBuilder {
+	if taskType == "" {
+		b.fail("empty task type")
+	}
+	b.meta.TaskType = taskType
+	return b
+}
+
+// WithRisk sets the risk level, one of RiskLow, RiskMedium and RiskHigh
+func (b *MetaBuilder) WithRisk(risk string) *MetaBuilder {
+	if !knownRisks[risk] {
+		b.fail("unknown risk %q", risk)
+	}
+	b.meta.Risk = risk
+	return b
+}
+
+// WithLanguages sets the languages of the task
+func (b *MetaBuilder) WithLanguages(languages ...string) *MetaBuilder {
+	b.meta.Languages = b.list("language", languages)
+	return b
+}
+
+// WithDataScope sets the data scope constraints, e.g. "no_secrets"
+func (b *MetaBuilder) WithDataScope(scopes ...string) *MetaBuilder {
+	b.meta.DataScope = b.list("data scope", scopes)
+	return b
+}
+
+// WithToolPermissions sets the tools the task may use
+func (b *MetaBuilder) WithToolPermissions(permissions ...string) *MetaBuilder {
+	b.meta.ToolPermissions = b.list("tool permission", permissions)
+	return b
+}
+
+// WithSecurityGroups sets the security groups of the task
+func (b *MetaBuilder) WithSecurityGroups(groups ...string) *MetaBuilder {
+	b.meta.SecurityGroups = b.list("security group", groups)
+	return b
+}
+
+// Build returns the Meta merged over the builder's defaults, or an error
+// matching ErrInvalidMeta for the first invalid value set
+func (b *MetaBuilder) Build() (Meta, error) {
+	if b.err != nil {
+		return Meta{}, b.err
+	}
+	return mergeMeta(b.defaults, b.meta), nil
+}
+
+// list copies values, failing the builder on an empty one
+func (b *MetaBuilder) list(what string, values []string) []string {
+	for _, value := range values {
+		if value == "" {
+			b.fail("empty %s", what)
+		}
+	}
+	return append([]string(nil), values...)
+}
+
+// fail records the first invalid value
+func (b *MetaBuilder) fail(format string, args ...interface{}) {
+	if b.err == nil {
+		b.err = fmt.Errorf("%w: %s", ErrInvalidMeta, fmt.Sprintf(format, args...))
+	}
+}
+
+// IsZero reports whether no field of m is set, empty lists counting as
+// unset. Frames omit such a Meta when serialized.
+func (m Meta) IsZero() bool {
+	return m.TaskType == "" && len(m.Languages) == 0 && m.Risk == "" && len(m.DataScope) == 0 &&
+		m.Trace == nil && len(m.ToolPermissions) == 0 && m.EnvironmentID == "" &&
+		len(m.SecurityGroups) == 0 && m.IdempotencyKey == "" && m.CorrelationID == "" &&
+		m.PreferredModel == "" && m.PreferredAdapterID == "" && len(m.ExcludeAdapters) == 0
+}
+
+// mergeMeta returns base with the fields set in over replacing its own
+func mergeMeta(base, over Meta) Meta {
+	if over.TaskType != "" {
+		base.TaskType = over.TaskType
+	}
+	if over.Languages != nil {
+		base.Languages = over.Languages
+	}
+	if over.Risk != "" {
+		base.Risk = over.Risk
+	}
+	if over.DataScope != nil {
+		base.DataScope = over.DataScope
+	}
+	if over.Trace != nil {
+		base.Trace = over.Trace
+	}
+	if over.ToolPermissions != nil {
+		base.ToolPermissions = over.ToolPermissions
+	}
+	if over.EnvironmentID != "" {
+		base.EnvironmentID = over.EnvironmentID
+	}
+	if over.SecurityGroups != nil {
+		base.SecurityGroups = over.SecurityGroups
+	}
+	if over.IdempotencyKey != "" {
+		base.IdempotencyKey = over.IdempotencyKey
+	}
+	if over.CorrelationID != "" {
+		base.CorrelationID = over.CorrelationID
+	}
+	if over.PreferredModel != "" {
+		base.PreferredModel = over.PreferredModel
+	}
+	if over.PreferredAdapterID != "" {
+		base.PreferredAdapterID = over.PreferredAdapterID
+	}
+	if over.ExcludeAdapters != nil {
+		base.ExcludeAdapters = over.ExcludeAdapters
+	}
+	return base
+}
+
+// withMeta returns base with every Meta of overrides merged over it, in
+// order
+func withMeta(base Meta, overrides []Meta) Meta {
+	for _, over := range overrides {
+		base = mergeMeta(base, over)
+	}
+	return base
+}
+
+// metaContextKey is the context key of the Meta set by WithMeta on a
+// completion request
+type metaContextKey struct{}
+
+// contextWithMeta returns ctx carrying meta for the frames of a request
+func contextWithMeta(ctx context.Context, meta []Meta) context.Context {
+	if len(meta) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, metaContextKey{}, meta)
+}
+
+// metaFromContext returns the Meta set with contextWithMeta
+func metaFromContext(ctx context.Context) []Meta {
+	meta, _ := ctx.Value(metaContextKey{}).([]Meta)
+	return meta
+}
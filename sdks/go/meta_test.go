@@ -0,0 +1,95 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMetaBuilder(t *testing.T) {
+	client := NewATPClient(SDKConfig{TenantID: "acme"})
+	defer client.Close()
+
+	meta, err := client.NewMeta().
+		WithTaskType("code_review").
+		WithRisk(RiskMedium).
+		WithLanguages("py", "ts").
+		WithDataScope("no_secrets").
+		WithToolPermissions("git.read").
+		WithSecurityGroups("eng").
+		Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	want := Meta{
+		TaskType:        "code_review",
+		Risk:            RiskMedium,
+		Languages:       []string{"py", "ts"},
+		DataScope:       []string{"no_secrets"},
+		ToolPermissions: []string{"git.read"},
+		SecurityGroups:  []string{"eng"},
+		EnvironmentID:   "acme",
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Errorf("Expected %+v, got %+v", want, meta)
+	}
+
+	for name, builder := range map[string]*MetaBuilder{
+		"unknown risk":     NewMetaBuilder().WithRisk("extreme"),
+		"empty task type":  NewMetaBuilder().WithTaskType(""),
+		"empty data scope": NewMetaBuilder().WithDataScope("no_secrets", ""),
+	} {
+		if _, err := builder.WithTaskType("qa").Build(); !errors.Is(err, ErrInvalidMeta) {
+			t.Errorf("%s: expected ErrInvalidMeta, got %v", name, err)
+		}
+	}
+}
+
+func TestFrameBuilderMeta(t *testing.T) {
+	fb := NewFrameBuilder("s1", "acme")
+	meta, err := NewMetaBuilder().WithTaskType("qa").WithRisk(RiskHigh).Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+
+	frame := fb.BuildCompletionFrame("stream-1", CompletionRequest{Prompt: "hi", IdempotencyKey: "k1"}, meta)
+	want := Meta{TaskType: "qa", Risk: RiskHigh, EnvironmentID: "acme", IdempotencyKey: "k1", Trace: frame.Meta.Trace}
+	if !reflect.DeepEqual(frame.Meta, want) {
+		t.Errorf("Expected the meta merged over the frame's own, got %+v", frame.Meta)
+	}
+	if frame := fb.BuildHealthFrame("stream-2", HealthStatus{}, meta); frame.Meta.Risk != RiskHigh {
+		t.Errorf("Expected the health frame to carry the meta, got %+v", frame.Meta)
+	}
+	if frame := fb.BuildCompletionFrame("stream-3", CompletionRequest{Prompt: "hi"}); frame.Meta.TaskType != "completion" {
+		t.Errorf("Expected the default task type without meta, got %+v", frame.Meta)
+	}
+}
+
+func TestWithMeta(t *testing.T) {
+	metas := make(chan Meta, 1)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		metas <- f.Meta
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), TenantID: "acme"})
+	defer client.Close()
+
+	meta, err := client.NewMeta().WithTaskType("qa").WithDataScope("no_secrets").Build()
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithMeta(meta), WithTenant("globex")); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	got := <-metas
+	if got.TaskType != "qa" || !reflect.DeepEqual(got.DataScope, []string{"no_secrets"}) {
+		t.Errorf("Expected the request meta to be sent, got %+v", got)
+	}
+	if got.EnvironmentID != "globex" {
+		t.Errorf("Expected the request's tenant as environment, got %q", got.EnvironmentID)
+	}
+}
@@ -0,0 +1,50 @@
+package atpsdk
+
+// Metric names reported through MetricsSink
+const (
+	// MetricSubscriptionDrops counts frames dropped because a subscriber's
+	// buffer was full. Labels: frame_type.
+	MetricSubscriptionDrops = "atp_subscription_dropped_frames_total"
+	// MetricHealthReportFailures counts health reports that could not be
+	// sent by a HealthLoop. Labels: adapter_id.
+	MetricHealthReportFailures = "atp_health_report_failures_total"
+	// MetricFramesSent counts frames written to the router. Labels:
+	// frame_type.
+	MetricFramesSent = "atp_frames_sent_total"
+	// MetricFramesReceived counts frames read from the router. Labels:
+	// frame_type.
+	MetricFramesReceived = "atp_frames_received_total"
+	// MetricDecodeFailures counts received messages dropped because they
+	// could not be decoded. Labels: reason, parse for messages that are
+	// not frames and strict for frames SDKConfig.StrictDecoding rejects.
+	MetricDecodeFailures = "atp_frame_decode_failures_total"
+	// MetricRequestDuration observes the seconds from sending a request to
+	// its outcome. Labels: frame_type, the request's, and outcome, one of
+	// ok, error, timeout, canceled, suspended or closed.
+	MetricRequestDuration = "atp_request_duration_seconds"
+	// MetricReconnects counts connections established after the first
+	MetricReconnects = "atp_reconnects_total"
+	// MetricPendingRequests is the gauge of requests waiting for a response
+	MetricPendingRequests = "atp_pending_requests"
+	// MetricCostUSD counts the cost reported in completion responses, in USD
+	MetricCostUSD = "atp_cost_usd_total"
+)
+
+// MetricsSink receives SDK metrics. Implementations must be safe for
+// concurrent use and should not block, as they are called from hot paths
+// such as the read loop.
+type MetricsSink interface {
+	// IncCounter adds delta to the named counter
+	IncCounter(name string, delta float64, labels map[string]string)
+	// SetGauge sets the named gauge to value
+	SetGauge(name string, value float64, labels map[string]string)
+	// ObserveHistogram records value in the named histogram
+	ObserveHistogram(name string, value float64, labels map[string]string)
+}
+
+// noopMetrics is the default MetricsSink, discarding everything
+type noopMetrics struct{}
+
+func (noopMetrics) IncCounter(string, float64, map[string]string)       {}
+func (noopMetrics) SetGauge(string, float64, map[string]string)         {}
+func (noopMetrics) ObserveHistogram(string, float64, map[string]string) {}
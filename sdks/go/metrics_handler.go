@@ -0,0 +1,149 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// maxMetricsResponseBytes caps the size of a MetricsHandler response
+const maxMetricsResponseBytes = 64 << 10
+
+// metricsSections maps each ?section= value to its part of a ClientStats
+var metricsSections = map[string]func(ClientStats) interface{}{
+	"connection": func(s ClientStats) interface{} { return s.Connection },
+	"frames":     func(s ClientStats) interface{} { return s.Frames },
+	"pending":    func(s ClientStats) interface{} { return s.Pending },
+	"endpoint":   func(s ClientStats) interface{} { return s.Endpoint },
+	"usage":      func(s ClientStats) interface{} { return s.Usage },
+	"tenant":     func(s ClientStats) interface{} { return s.Tenant },
+}
+
+// MetricsHandler returns a read-only http.Handler exposing the client's
+// Stats snapshot as JSON, suitable for mounting at /debug/atp. The
+// ?section= parameter restricts the output to one of connection, frames,
+// pending, endpoint, usage or tenant, and ?format=prometheus renders the Prometheus
+// text exposition format instead of JSON. The handler only reads atomic
+// counters, so it never waits on locks held by the send or receive paths.
+func (c *ATPClient) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeMetricsError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		section := r.URL.Query().Get("section")
+		if section != "" {
+			if _, ok := metricsSections[section]; !ok {
+				writeMetricsError(w, http.StatusBadRequest,
+					fmt.Sprintf("unknown section %q; valid sections are %s", section, strings.Join(metricsSectionNames(), ", ")))
+				return
+			}
+		}
+
+		stats := c.Stats()
+		var body []byte
+		contentType := "application/json"
+		if r.URL.Query().Get("format") == "prometheus" {
+			body = renderPrometheusStats(stats, section)
+			contentType = "text/plain; version=0.0.4"
+		} else {
+			var err error
+			body, err = c.renderJSONStats(stats, section)
+			if err != nil {
+				writeMetricsError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		if len(body) > maxMetricsResponseBytes {
+			writeMetricsError(w, http.StatusInternalServerError,
+				fmt.Sprintf("metrics response of %d bytes exceeds limit of %d", len(body), maxMetricsResponseBytes))
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(body)
+		}
+	})
+}
+
+// renderJSONStats renders the full snapshot, or one section of it
+func (c *ATPClient) renderJSONStats(stats ClientStats, section string) ([]byte, error) {
+	if section != "" {
+		return json.Marshal(map[string]interface{}{section: metricsSections[section](stats)})
+	}
+
+	doc := map[string]interface{}{
+		"sdk_version": SDKVersion,
+		"session_id":  c.config.SessionID,
+		"tenant_id":   c.config.TenantID,
+	}
+	for name, get := range metricsSections {
+		doc[name] = get(stats)
+	}
+	return json.Marshal(doc)
+}
+
+// renderPrometheusStats renders the snapshot in the Prometheus text format
+func renderPrometheusStats(stats ClientStats, section string) []byte {
+	var buf bytes.Buffer
+	metric := func(sec, name, kind string, value float64) {
+		if section != "" && section != sec {
+			return
+		}
+		fmt.Fprintf(&buf, "# TYPE %s %s\n%s %g\n", name, kind, name, value)
+	}
+	boolValue := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+
+	metric("connection", "atp_client_connected", "gauge", boolValue(stats.Connection.Connected))
+	metric("connection", "atp_client_connects_total", "counter", float64(stats.Connection.ConnectCount))
+	metric("connection", "atp_client_disconnects_total", "counter", float64(stats.Connection.DisconnectCount))
+	metric("connection", "atp_client_reconnects_total", "counter", float64(stats.Connection.ReconnectCount))
+	metric("connection", "atp_client_pool_connections", "gauge", float64(stats.Connection.PoolConnections))
+	metric("frames", "atp_client_frames_sent_total", "counter", float64(stats.Frames.Sent))
+	metric("frames", "atp_client_frames_received_total", "counter", float64(stats.Frames.Received))
+	metric("frames", "atp_client_signature_failures_total", "counter", float64(stats.Frames.SignatureFailures))
+	metric("frames", "atp_client_expired_frames_total", "counter", float64(stats.Frames.Expired))
+	metric("frames", "atp_client_malformed_frames_total", "counter", float64(stats.Frames.Malformed))
+	metric("frames", "atp_client_strict_decode_failures_total", "counter", float64(stats.Frames.StrictFailures))
+	metric("frames", "atp_client_outbound_queued", "gauge", float64(stats.Frames.Queued))
+	metric("pending", "atp_client_pending_requests", "gauge", float64(stats.Pending.Count))
+	metric("pending", "atp_client_orphaned_responses_total", "counter", float64(stats.Pending.Orphaned))
+	metric("endpoint", "atp_client_endpoint_healthy", "gauge", boolValue(stats.Endpoint.Healthy))
+	metric("usage", "atp_client_requests_total", "counter", float64(stats.Usage.Requests))
+	metric("usage", "atp_client_tokens_in_total", "counter", float64(stats.Usage.TokensIn))
+	metric("usage", "atp_client_tokens_out_total", "counter", float64(stats.Usage.TokensOut))
+	metric("usage", "atp_client_cost_usd_total", "counter", stats.Usage.CostUSD)
+	metric("tenant", "atp_client_tenant_suspended", "gauge", boolValue(stats.Tenant.Suspended))
+	return buf.Bytes()
+}
+
+// metricsSectionNames returns the valid ?section= values in sorted order
+func metricsSectionNames() []string {
+	names := make([]string, 0, len(metricsSections))
+	for name := range metricsSections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeMetricsError writes a JSON error body
+func writeMetricsError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
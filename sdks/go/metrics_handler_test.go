@@ -0,0 +1,168 @@
+package atpsdk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetricsHandlerJSON(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		return []Frame{{
+			Type:     "completion_response",
+			StreamID: f.StreamID,
+			MsgSeq:   f.MsgSeq,
+			Payload:  map[string]interface{}{"text": "ok", "tokens_in": 3, "tokens_out": 5, "cost_usd": 0.002},
+		}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), APIKey: "secret-key"})
+	defer client.Close()
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	client.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/atp", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %s", ct)
+	}
+	if strings.Contains(rec.Body.String(), "secret-key") {
+		t.Error("Metrics output must not contain the API key")
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	for _, key := range []string{"sdk_version", "session_id", "connection", "frames", "pending", "endpoint", "usage"} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("Missing key %q", key)
+		}
+	}
+
+	var usage UsageTotals
+	if err := json.Unmarshal(doc["usage"], &usage); err != nil {
+		t.Fatalf("Invalid usage section: %v", err)
+	}
+	if usage.Requests != 1 || usage.TokensIn != 3 || usage.TokensOut != 5 || usage.CostUSD != 0.002 {
+		t.Errorf("Unexpected usage totals: %+v", usage)
+	}
+
+	var connection ConnectionStats
+	if err := json.Unmarshal(doc["connection"], &connection); err != nil {
+		t.Fatalf("Invalid connection section: %v", err)
+	}
+	if !connection.Connected || connection.ConnectCount != 1 || connection.ReconnectCount != 0 {
+		t.Errorf("Unexpected connection stats: %+v", connection)
+	}
+
+	var frames FrameStats
+	if err := json.Unmarshal(doc["frames"], &frames); err != nil {
+		t.Fatalf("Invalid frames section: %v", err)
+	}
+	if frames.SentByType["completion_request"] != 1 || frames.ReceivedByType["completion_response"] != 1 {
+		t.Errorf("Unexpected frame counts by type: %+v", frames)
+	}
+}
+
+func TestMetricsHandlerSection(t *testing.T) {
+	client := NewATPClient(SDKConfig{})
+	handler := client.MetricsHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/atp?section=pending", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(doc) != 1 || doc["pending"] == nil {
+		t.Errorf("Expected only the pending section, got %v", doc)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/atp?section=bogus", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "usage") {
+		t.Errorf("Expected 400 listing valid sections, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/atp", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestMetricsHandlerPrometheus(t *testing.T) {
+	client := NewATPClient(SDKConfig{})
+	rec := httptest.NewRecorder()
+	client.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/atp?format=prometheus&section=usage", nil))
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "atp_client_requests_total 0") {
+		t.Errorf("Expected usage metrics, got:\n%s", body)
+	}
+	if strings.Contains(body, "atp_client_connected") {
+		t.Errorf("Section filter should exclude connection metrics, got:\n%s", body)
+	}
+}
+
+func TestMetricsHandlerUnderLoad(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				_, _ = client.Complete(ctx, CompletionRequest{Prompt: "load"})
+			}
+		}()
+	}
+
+	handler := client.MetricsHandler()
+	for i := 0; i < 50; i++ {
+		start := time.Now()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/atp", nil))
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("Metrics request took %v under load", elapsed)
+		}
+		if rec.Code != http.StatusOK || !json.Valid(rec.Body.Bytes()) {
+			t.Fatalf("Invalid response under load: %d %s", rec.Code, rec.Body.String())
+		}
+		if rec.Body.Len() > maxMetricsResponseBytes {
+			t.Errorf("Response exceeds size limit: %d bytes", rec.Body.Len())
+		}
+	}
+
+	cancel()
+	wg.Wait()
+}
@@ -0,0 +1,135 @@
+package atpsdk
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ModelCapability is the capability of the adapters ListModels lists
+const ModelCapability = "text-generation"
+
+// ModelInfo describes a model that can serve requests, as returned by
+// ListModels
+type ModelInfo struct {
+	Name      string
+	AdapterID string
+	// MaxTokens and CostPerTokenMicros are zero when the adapter does not
+	// advertise them
+	MaxTokens          int
+	CostPerTokenMicros int
+	SupportedLanguages []string
+}
+
+// modelCache holds the ListModels results of each tenant for
+// SDKConfig.ModelCacheTTL. Concurrent misses for a tenant share one
+// discovery request.
+type modelCache struct {
+	mu      sync.Mutex
+	entries map[string]*modelCacheEntry // by tenant
+}
+
+// modelCacheEntry is the result of one discovery for a tenant. ready is
+// closed once models and err are set.
+type modelCacheEntry struct {
+	ready     chan struct{}
+	models    []ModelInfo
+	err       error
+	fetchedAt time.Time
+}
+
+// ListModels returns the models the tenant of ctx can use right now for
+// text generation, sorted by name. They are discovered with
+// DiscoverAdapters, keeping for each model the cheapest adapter serving
+// it. With SDKConfig.ModelCacheTTL, results are reused for that long;
+// failures are not cached.
+func (c *ATPClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if c.config.ModelCacheTTL <= 0 {
+		return c.discoverModels(ctx)
+	}
+
+	tenantID := c.tenantOf(ctx)
+	c.models.mu.Lock()
+	entry := c.models.entries[tenantID]
+	if entry != nil {
+		select {
+		case <-entry.ready:
+			if entry.err != nil || c.config.Clock.Now().Sub(entry.fetchedAt) >= c.config.ModelCacheTTL {
+				entry = nil
+			}
+		default:
+		}
+	}
+	owner := entry == nil
+	if owner {
+		entry = &modelCacheEntry{ready: make(chan struct{})}
+		if c.models.entries == nil {
+			c.models.entries = make(map[string]*modelCacheEntry)
+		}
+		c.models.entries[tenantID] = entry
+	}
+	c.models.mu.Unlock()
+
+	if owner {
+		entry.models, entry.err = c.discoverModels(ctx)
+		entry.fetchedAt = c.config.Clock.Now()
+		close(entry.ready)
+		if entry.err != nil {
+			return nil, entry.err
+		}
+	}
+
+	select {
+	case <-entry.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return slices.Clone(entry.models), nil
+}
+
+// discoverModels lists the models of the text generation adapters
+// registered for the tenant of ctx
+func (c *ATPClient) discoverModels(ctx context.Context) ([]ModelInfo, error) {
+	adapters, err := c.DiscoverAdapters(ctx, DiscoveryFilter{Capability: ModelCapability})
+	if err != nil {
+		return nil, err
+	}
+	return flattenModels(adapters), nil
+}
+
+// flattenModels lists the models of adapters once each, with the cheapest
+// adapter serving them. Ties go to the adapter listed first.
+func flattenModels(adapters []CapabilityAdvertisement) []ModelInfo {
+	byName := make(map[string]ModelInfo)
+	for _, adapter := range adapters {
+		for _, model := range adapter.Models {
+			info := ModelInfo{
+				Name:               model,
+				AdapterID:          adapter.AdapterID,
+				SupportedLanguages: adapter.SupportedLanguages,
+			}
+			if adapter.MaxTokens != nil {
+				info.MaxTokens = *adapter.MaxTokens
+			}
+			if adapter.CostPerTokenMicros != nil {
+				info.CostPerTokenMicros = *adapter.CostPerTokenMicros
+			}
+			if existing, ok := byName[model]; ok && existing.CostPerTokenMicros <= info.CostPerTokenMicros {
+				continue
+			}
+			byName[model] = info
+		}
+	}
+
+	models := make([]ModelInfo, 0, len(byName))
+	for _, info := range byName {
+		models = append(models, info)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	return models
+}
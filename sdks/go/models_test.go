@@ -0,0 +1,74 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestListModels(t *testing.T) {
+	var discoveries atomic.Int32
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeDiscoveryRequest {
+			return nil
+		}
+		discoveries.Add(1)
+		if f.Payload["capability"] != ModelCapability {
+			t.Errorf("Expected a %s discovery, got %v", ModelCapability, f.Payload)
+		}
+		adapters := []interface{}{
+			map[string]interface{}{"adapter_id": "a1", "models": []string{"llama", "mistral"}, "max_tokens": 4096, "cost_per_token_micros": 5},
+			map[string]interface{}{"adapter_id": "a2", "models": []string{"llama", "qwen"}, "cost_per_token_micros": 3, "supported_languages": []string{"en"}},
+			map[string]interface{}{"adapter_id": "a3", "models": []string{"qwen"}, "cost_per_token_micros": 3},
+		}
+		return []Frame{{Type: FrameTypeDiscoveryResponse, StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"adapters": adapters}}}
+	})
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Clock: clock, ModelCacheTTL: time.Minute})
+	defer client.Close()
+	ctx := context.Background()
+
+	// Each model is listed once, with its cheapest adapter
+	models, err := client.ListModels(ctx)
+	if err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	want := "[{llama a2 0 3 [en]} {mistral a1 4096 5 []} {qwen a2 0 3 [en]}]"
+	if got := fmt.Sprint(models); got != want {
+		t.Errorf("Unexpected models:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Cached results are shared until the TTL runs out
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if models, err := client.ListModels(ctx); err != nil || len(models) != 3 {
+				t.Errorf("Expected the cached models, got %v, %v", models, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := discoveries.Load(); n != 1 {
+		t.Errorf("Expected one discovery while cached, got %d", n)
+	}
+	clock.Advance(time.Minute)
+	if _, err := client.ListModels(ctx); err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if n := discoveries.Load(); n != 2 {
+		t.Errorf("Expected an expired cache to be refreshed, got %d discoveries", n)
+	}
+
+	// Each tenant has its own cache
+	if _, err := client.ListModels(ContextWithTenant(ctx, "other")); err != nil {
+		t.Fatalf("ListModels failed: %v", err)
+	}
+	if n := discoveries.Load(); n != 3 {
+		t.Errorf("Expected another tenant to discover its models, got %d discoveries", n)
+	}
+}
@@ -0,0 +1,227 @@
+package atpsdk
+
+import (
+	"fmt"
+	"time"
+)
+
+// RequestOption customizes a single request
+type RequestOption func(*requestOptions)
+
+// requestOptions holds the per-request settings applied by RequestOptions
+type requestOptions struct {
+	modelFallbacks []string
+	timeout        time.Duration
+	fireAndForget  bool
+	waitForWrite   bool
+	chunking       ChunkStrategy
+	tenant         string
+	meta           []Meta
+	correlationID  string
+	qos            QoS
+	tokenCallback  func(CompletionChunk) error
+	flushInterval  time.Duration
+	minQuality     *qualityThreshold
+	fallbacks      []RoutingPreference
+	hedging        *hedgingPolicy
+	ttl            time.Duration
+	err            error
+}
+
+// newRequestOptions applies opts over the zero-value options
+func newRequestOptions(opts []RequestOption) requestOptions {
+	var options requestOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// requestOptions is newRequestOptions starting from the client's
+// SDKConfig.DefaultMeta
+func (c *ATPClient) requestOptions(opts []RequestOption) requestOptions {
+	if c.config.DefaultMeta.IsZero() {
+		return newRequestOptions(opts)
+	}
+	return newRequestOptions(append([]RequestOption{WithMeta(c.config.DefaultMeta)}, opts...))
+}
+
+// WithModelFallbacks sets the models to try, in order, when the router
+// reports that a model cannot serve the request. It overrides any chain
+// registered in SDKConfig.ModelFallbacks.
+func WithModelFallbacks(models ...string) RequestOption {
+	return func(o *requestOptions) {
+		o.modelFallbacks = models
+	}
+}
+
+// WithChunking splits a prompt too long for one request with strategy, and
+// sends the chunks as sequential requests on one stream. See ChunkStrategy.
+func WithChunking(strategy ChunkStrategy) RequestOption {
+	return func(o *requestOptions) {
+		o.chunking = strategy
+	}
+}
+
+// WithTenant makes the request act for tenantID instead of
+// SDKConfig.TenantID, for services sharing one client between tenants. It
+// takes precedence over ContextWithTenant. The client's configuration is
+// not changed.
+func WithTenant(tenantID string) RequestOption {
+	return func(o *requestOptions) {
+		o.tenant = tenantID
+	}
+}
+
+// WithMeta merges the fields set in meta over the Meta of the request's
+// frames, e.g. one built with ATPClient.NewMeta. Its EnvironmentID is
+// ignored: the request's tenant decides it, see WithTenant.
+func WithMeta(meta Meta) RequestOption {
+	return func(o *requestOptions) {
+		meta.EnvironmentID = ""
+		o.meta = append(o.meta, meta)
+	}
+}
+
+// WithCorrelationID sets the correlation ID of the request, instead of a
+// generated one, e.g. to reuse the ID of an incoming HTTP request. It is
+// sent in the Meta and payload of the request's frames and reported in its
+// errors, see CorrelationIDOf.
+func WithCorrelationID(id string) RequestOption {
+	return func(o *requestOptions) {
+		o.correlationID = id
+	}
+}
+
+// WithQoS sets the QoS class of the request's frames, overriding the
+// request's own and SDKConfig.DefaultQoS. An unknown class fails the
+// request with an error matching ErrInvalidQoS.
+func WithQoS(qos QoS) RequestOption {
+	return func(o *requestOptions) {
+		if err := validateQoS(qos); err != nil {
+			o.err = err
+			return
+		}
+		o.qos = qos
+	}
+}
+
+// WithTokenCallback makes Complete call callback with each chunk of a
+// streamed response, in order, the last one included, and return the
+// response with the text of all chunks once the last arrives. A response
+// that is not streamed is one final chunk. The callback holds up the read
+// loop of the connection, so each call is bounded by
+// SDKConfig.TokenCallbackTimeout. An error, or running out of time, ends
+// the request: a cancel frame is sent and Complete fails with an error
+// matching ErrTokenCallback.
+func WithTokenCallback(callback func(chunk CompletionChunk) error) RequestOption {
+	return func(o *requestOptions) {
+		o.tokenCallback = callback
+	}
+}
+
+// WithFallbacks makes Complete try the request with each of preferences in
+// turn, moving on to the next when the router fails an attempt with a
+// retryable error or the attempt times out. Every attempt keeps the
+// request's idempotency key. The response's FallbackDepth is the index of
+// the preference that served it. When all fail, the error joins the
+// failures of every attempt. It overrides WithModelFallbacks and
+// SDKConfig.ModelFallbacks.
+func WithFallbacks(preferences ...RoutingPreference) RequestOption {
+	return func(o *requestOptions) {
+		o.fallbacks = preferences
+	}
+}
+
+// WithTTL sets the TTL of a Complete request with neither a context
+// deadline nor WithTimeout, and bounds the wait for its response by it
+// instead of DefaultTimeout. Requests with a deadline take their TTL from
+// it, and ignore WithTTL. A ttl of zero or less fails the request with
+// ErrInvalidTimeout.
+func WithTTL(ttl time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		if ttl <= 0 {
+			o.err = fmt.Errorf("%w: TTL %v", ErrInvalidTimeout, ttl)
+			return
+		}
+		o.ttl = ttl
+	}
+}
+
+// WithHedging makes Complete send a duplicate of the request, on a new
+// stream, flagged FlagHedge and with the same idempotency key, when no
+// response arrived within delay, and again after each further delay, up to
+// maxHedges duplicates. The first response is returned and the other
+// attempts are canceled with cancel frames. Only the winner's cost is
+// recorded, unless a loser was answered and billed too: then both are, the
+// cost metric labelled "hedge" "winner" or "loser". Requests streamed to a
+// WithTokenCallback callback are not hedged. A negative maxHedges, or a
+// delay of zero or less, fails the request with ErrInvalidHedging.
+func WithHedging(delay time.Duration, maxHedges int) RequestOption {
+	return func(o *requestOptions) {
+		if delay <= 0 || maxHedges < 0 {
+			o.err = fmt.Errorf("%w: delay %v, %d hedges", ErrInvalidHedging, delay, maxHedges)
+			return
+		}
+		o.hedging = &hedgingPolicy{delay: delay, maxHedges: maxHedges}
+	}
+}
+
+// WithMinQuality makes Complete re-issue a request whose response scores
+// below score, excluding the adapter that produced it with
+// ExcludeAdapters, until a response meets score or maxAttempts attempts
+// were made. Complete returns the best-scoring response, with the history
+// of the attempts in QualityAttempts. It does not apply to requests split
+// with WithChunking. A maxAttempts under 1 fails the request with
+// ErrInvalidMinQuality.
+func WithMinQuality(score float64, maxAttempts int) RequestOption {
+	return func(o *requestOptions) {
+		if maxAttempts < 1 {
+			o.err = fmt.Errorf("%w: %d attempts", ErrInvalidMinQuality, maxAttempts)
+			return
+		}
+		o.minQuality = &qualityThreshold{score: score, maxAttempts: maxAttempts}
+	}
+}
+
+// WithFlushInterval makes CompleteTo flush its writer at most once per
+// interval instead of after every chunk. The end of the response is always
+// flushed.
+func WithFlushInterval(interval time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.flushInterval = interval
+	}
+}
+
+// FireAndForget makes AdvertiseCapabilities and ReportHealth return as soon
+// as the frame is sent instead of waiting for the router's ack. A missing
+// ack or a nack is then only logged. With SDKConfig.Outbox, a frame that
+// cannot be sent right away is queued in the outbox rather than failing.
+func FireAndForget() RequestOption {
+	return func(o *requestOptions) {
+		o.fireAndForget = true
+	}
+}
+
+// WaitForWrite makes a FireAndForget call return once the frame is written
+// to the connection, failing with the write's error, instead of once it is
+// queued for the connection's writer.
+func WaitForWrite() RequestOption {
+	return func(o *requestOptions) {
+		o.waitForWrite = true
+	}
+}
+
+// WithTimeout bounds the whole call, including any fallback attempts, to d.
+// Without it the caller's context deadline governs, and DefaultTimeout only
+// applies when the context has no deadline. When both are set the earlier
+// one wins. A zero or negative d makes the call fail with ErrInvalidTimeout.
+func WithTimeout(d time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		if d <= 0 {
+			o.err = fmt.Errorf("%w: %v", ErrInvalidTimeout, d)
+			return
+		}
+		o.timeout = d
+	}
+}
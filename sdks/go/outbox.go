@@ -0,0 +1,286 @@
+package atpsdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// OutboxEntry is a frame queued in an OutboxStore
+type OutboxEntry struct {
+	Frame    Frame     `json:"frame"`
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+// OutboxStore persists the frames queued while the client is disconnected.
+// Implementations must be safe for concurrent use.
+type OutboxStore interface {
+	// Append adds an entry after the others
+	Append(entry OutboxEntry) error
+	// Entries returns the queued entries, oldest first
+	Entries() ([]OutboxEntry, error)
+	// Trim removes the n oldest entries
+	Trim(n int) error
+}
+
+// OutboxConfig configures a durable outbox for the capability and health
+// frames sent with FireAndForget. While the client is disconnected, or
+// earlier frames are still queued, they are appended to Store, and they are
+// flushed in order once the client connects.
+type OutboxConfig struct {
+	// Store holds the queued frames. Nil, the default, disables the outbox.
+	Store OutboxStore
+	// MaxAge is how long a frame may stay queued before it is dropped
+	// rather than sent (default: 10m)
+	MaxAge time.Duration
+}
+
+// defaultOutboxMaxAge is the default OutboxConfig.MaxAge
+const defaultOutboxMaxAge = 10 * time.Minute
+
+// FileOutbox is an OutboxStore keeping its entries in a file, one JSON entry
+// per line, so that they survive a restart. Entries are appended to the file
+// and Trim rewrites it.
+type FileOutbox struct {
+	path string
+
+	mu      sync.Mutex
+	file    *os.File
+	entries []OutboxEntry
+}
+
+// NewFileOutbox opens the outbox file at path, creating it if needed, and
+// loads the entries queued in it. A line left incomplete by a crash is
+// dropped.
+func NewFileOutbox(path string) (*FileOutbox, error) {
+	o := &FileOutbox{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	damaged := false
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var entry OutboxEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			damaged = true
+			continue
+		}
+		o.entries = append(o.entries, entry)
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		damaged = true
+	}
+	if damaged {
+		if err := o.rewrite(o.entries); err != nil {
+			return nil, err
+		}
+	}
+
+	o.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open outbox: %w", err)
+	}
+	return o, nil
+}
+
+// Append writes entry to the end of the file
+func (o *FileOutbox) Append(entry OutboxEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return os.ErrClosed
+	}
+	if _, err := o.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync outbox: %w", err)
+	}
+	o.entries = append(o.entries, entry)
+	return nil
+}
+
+// Entries returns the queued entries, oldest first
+func (o *FileOutbox) Entries() ([]OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]OutboxEntry(nil), o.entries...), nil
+}
+
+// Trim removes the n oldest entries, rewriting the file
+func (o *FileOutbox) Trim(n int) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return os.ErrClosed
+	}
+	n = min(n, len(o.entries))
+	if n <= 0 {
+		return nil
+	}
+
+	remaining := o.entries[n:]
+	if err := o.rewrite(remaining); err != nil {
+		return err
+	}
+	// The rename replaced the file the handle was appending to
+	file, err := os.OpenFile(o.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen outbox: %w", err)
+	}
+	_ = o.file.Close()
+	o.file = file
+	o.entries = append([]OutboxEntry(nil), remaining...)
+	return nil
+}
+
+// Close closes the file. Entries stay in it for the next NewFileOutbox.
+func (o *FileOutbox) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
+
+// rewrite atomically replaces the file with entries
+func (o *FileOutbox) rewrite(entries []OutboxEntry) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to encode outbox entry: %w", err)
+		}
+	}
+
+	tmp := o.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite outbox: %w", err)
+	}
+	_, err = file.Write(buf.Bytes())
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, o.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to rewrite outbox: %w", err)
+	}
+	return nil
+}
+
+// outboxEnabled reports whether fire-and-forget frames go through the outbox
+func (c *ATPClient) outboxEnabled() bool {
+	return c.config.Outbox.Store != nil
+}
+
+// sendAdapterFrame sends a capability or health frame and waits for the
+// router's ack. With an outbox, a fire-and-forget frame that cannot be sent
+// now, or would overtake queued ones, is queued instead.
+func (c *ATPClient) sendAdapterFrame(ctx context.Context, frame Frame, options requestOptions, what string) error {
+	if !options.fireAndForget || !c.outboxEnabled() {
+		if !c.IsConnected() {
+			if err := c.Connect(); err != nil {
+				return fmt.Errorf("failed to connect: %w", err)
+			}
+		}
+		return c.sendAcknowledged(ctx, frame, options, what)
+	}
+
+	if !c.IsConnected() {
+		if err := c.Connect(); err != nil {
+			c.config.Logger.Printf("Warning: Queueing %s%s in the outbox: %v", what, logCorrelation(frame.Meta.CorrelationID), err)
+		}
+	}
+	if queued, err := c.config.Outbox.Store.Entries(); err == nil && len(queued) == 0 && c.IsConnected() {
+		err := c.sendAcknowledged(ctx, frame, options, what)
+		if !errors.Is(err, ErrNotConnected) {
+			return err
+		}
+	}
+
+	if err := c.config.Outbox.Store.Append(OutboxEntry{Frame: frame, QueuedAt: c.config.Clock.Now()}); err != nil {
+		return fmt.Errorf("failed to queue %s: %w", what, err)
+	}
+	if c.IsConnected() {
+		go c.flushOutbox()
+	}
+	return nil
+}
+
+// flushOutbox sends the queued frames in order, waiting for the router to
+// acknowledge each before removing it. Frames older than MaxAge are
+// dropped. It stops, keeping the rest queued, when a frame cannot be sent.
+func (c *ATPClient) flushOutbox() {
+	if !c.outboxEnabled() {
+		return
+	}
+	c.outboxMutex.Lock()
+	defer c.outboxMutex.Unlock()
+
+	store := c.config.Outbox.Store
+	for !c.closed() && c.IsConnected() {
+		entries, err := store.Entries()
+		if err != nil {
+			c.config.Logger.Printf("Warning: Failed to read the outbox: %v", err)
+			return
+		}
+		if len(entries) == 0 {
+			return
+		}
+
+		stale := 0
+		for stale < len(entries) && c.config.Clock.Now().Sub(entries[stale].QueuedAt) > c.config.Outbox.MaxAge {
+			stale++
+		}
+		if stale > 0 {
+			c.config.Logger.Printf("Warning: Dropping %d outbox frame(s) older than %v", stale, c.config.Outbox.MaxAge)
+			if err := store.Trim(stale); err != nil {
+				c.config.Logger.Printf("Warning: Failed to trim the outbox: %v", err)
+				return
+			}
+			continue
+		}
+
+		frame := entries[0].Frame
+		ctx, cancel := context.WithTimeout(c.ctx, c.config.DefaultTimeout)
+		err = c.sendAcknowledged(ctx, frame, requestOptions{}, frame.Type+" frame")
+		cancel()
+		var atpErr *ATPError
+		if err != nil && !errors.Is(err, ErrNacked) && !errors.As(err, &atpErr) {
+			c.config.Logger.Printf("Warning: Outbox flush stopped%s: %v", logCorrelation(frame.Meta.CorrelationID), err)
+			return
+		}
+		if err != nil {
+			c.config.Logger.Printf("Warning: Dropping rejected outbox frame%s: %v", logCorrelation(frame.Meta.CorrelationID), err)
+		}
+		if err := store.Trim(1); err != nil {
+			c.config.Logger.Printf("Warning: Failed to trim the outbox: %v", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openOutbox(t *testing.T, path string) *FileOutbox {
+	t.Helper()
+	outbox, err := NewFileOutbox(path)
+	if err != nil {
+		t.Fatalf("NewFileOutbox failed: %v", err)
+	}
+	t.Cleanup(func() { outbox.Close() })
+	return outbox
+}
+
+func outboxStreamIDs(t *testing.T, store OutboxStore) []string {
+	t.Helper()
+	entries, err := store.Entries()
+	if err != nil {
+		t.Fatalf("Entries failed: %v", err)
+	}
+	var ids []string
+	for _, entry := range entries {
+		ids = append(ids, entry.Frame.StreamID)
+	}
+	return ids
+}
+
+func TestFileOutboxSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	outbox := openOutbox(t, path)
+	for _, id := range []string{"a", "b", "c"} {
+		if err := outbox.Append(OutboxEntry{Frame: Frame{Type: "adapter.health", StreamID: id}, QueuedAt: time.Now()}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if err := outbox.Trim(1); err != nil {
+		t.Fatalf("Trim failed: %v", err)
+	}
+	if err := outbox.Append(OutboxEntry{Frame: Frame{Type: "adapter.health", StreamID: "d"}, QueuedAt: time.Now()}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	outbox.Close()
+
+	// A crash in the middle of an append leaves a partial line behind
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	file.WriteString(`{"frame":{"type":"adapter.he`)
+	file.Close()
+
+	reopened := openOutbox(t, path)
+	if got := fmt.Sprint(outboxStreamIDs(t, reopened)); got != "[b c d]" {
+		t.Errorf("Expected [b c d] after reopening, got %s", got)
+	}
+	if err := reopened.Append(OutboxEntry{Frame: Frame{StreamID: "e"}, QueuedAt: time.Now()}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	reopened.Close()
+	if got := fmt.Sprint(outboxStreamIDs(t, openOutbox(t, path))); got != "[b c d e]" {
+		t.Errorf("Expected the partial line dropped, got %s", got)
+	}
+}
+
+func TestFileOutboxConcurrentAppendTrim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	outbox := openOutbox(t, path)
+
+	const writers, perWriter = 4, 25
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := outbox.Append(OutboxEntry{Frame: Frame{StreamID: fmt.Sprintf("%d-%d", w, i)}}); err != nil {
+					t.Errorf("Append failed: %v", err)
+				}
+			}
+		}()
+	}
+	trimmed := 0
+	for trimmed < writers*perWriter/2 {
+		if entries, _ := outbox.Entries(); len(entries) > 0 {
+			if err := outbox.Trim(1); err != nil {
+				t.Fatalf("Trim failed: %v", err)
+			}
+			trimmed++
+		}
+	}
+	wg.Wait()
+
+	// Every append is either trimmed or still queued, in memory and on disk
+	remaining := outboxStreamIDs(t, outbox)
+	if len(remaining)+trimmed != writers*perWriter {
+		t.Errorf("Expected %d entries left, got %d", writers*perWriter-trimmed, len(remaining))
+	}
+	outbox.Close()
+	if got := outboxStreamIDs(t, openOutbox(t, path)); fmt.Sprint(got) != fmt.Sprint(remaining) {
+		t.Errorf("Reopened outbox differs:\n%v\nwant:\n%v", got, remaining)
+	}
+}
+
+func TestOutboxFlushAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	outbox := openOutbox(t, path)
+	stale := Frame{Type: "adapter.health", StreamID: "stale", Payload: map[string]interface{}{"status": "stale"}}
+	if err := outbox.Append(OutboxEntry{Frame: stale, QueuedAt: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+
+	// Reports made while the router is unreachable are queued
+	offline := NewATPClient(SDKConfig{WSURL: "ws://127.0.0.1:1", Outbox: OutboxConfig{Store: outbox}})
+	for _, status := range []string{"degraded", "healthy"} {
+		if err := offline.ReportHealth(context.Background(), HealthStatus{AdapterID: "edge-1", Status: status}, FireAndForget()); err != nil {
+			t.Fatalf("ReportHealth failed: %v", err)
+		}
+	}
+	offline.Close()
+	outbox.Close()
+
+	// After a restart they are sent in order once connected, and the stale
+	// one is dropped
+	received := make(chan string, 4)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "adapter.health" {
+			return nil
+		}
+		received <- getString(f.Payload, "status", "")
+		return []Frame{{Type: FrameTypeAck, StreamID: f.StreamID, MsgSeq: f.MsgSeq}}
+	})
+	reopened := openOutbox(t, path)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Outbox: OutboxConfig{Store: reopened, MaxAge: time.Minute}})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	for _, want := range []string{"degraded", "healthy"} {
+		select {
+		case status := <-received:
+			if status != want {
+				t.Errorf("Expected the %s report, got %s", want, status)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("The %s report was not flushed", want)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(outboxStreamIDs(t, reopened)) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if ids := outboxStreamIDs(t, reopened); len(ids) > 0 {
+		t.Errorf("Expected acknowledged frames removed from the outbox, got %v", ids)
+	}
+
+	// Once the outbox is empty, reports go straight to the router
+	if err := client.ReportHealth(context.Background(), HealthStatus{AdapterID: "edge-1", Status: "live"}, FireAndForget()); err != nil {
+		t.Fatalf("ReportHealth failed: %v", err)
+	}
+	if status := <-received; status != "live" {
+		t.Errorf("Expected the live report, got %s", status)
+	}
+	if ids := outboxStreamIDs(t, reopened); len(ids) > 0 {
+		t.Errorf("Expected a connected report to skip the outbox, got %v", ids)
+	}
+}
@@ -0,0 +1,371 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OverflowPolicy decides what sending a frame does when the connection's
+// outbound queue is full
+type OverflowPolicy string
+
+// Overflow policies selectable with SDKConfig.OverflowPolicy
+const (
+	// OverflowBlock waits for space in the queue, until the sender's context
+	// is done. It is the default.
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowError fails the send with ErrQueueFull.
+	OverflowError OverflowPolicy = "error"
+	// OverflowDropOldestHeartbeats evicts the oldest queued heartbeat to make
+	// room. A heartbeat finding no heartbeat to evict is dropped itself;
+	// other frames wait as with OverflowBlock.
+	OverflowDropOldestHeartbeats OverflowPolicy = "drop-oldest-heartbeats"
+)
+
+// defaultOutboundQueueSize is the capacity of each outbound queue when
+// SDKConfig.OutboundQueueSize is unset
+const defaultOutboundQueueSize = 1024
+
+// drainTimeout bounds how long closing a connection gracefully waits for
+// its writer to flush the frames already queued
+const drainTimeout = 5 * time.Second
+
+// outboundFrame is one frame waiting in an outbound queue. Heartbeats sent
+// from a control frame template are rendered by the writer, so queueing
+// them does not allocate.
+type outboundFrame struct {
+	data      []byte
+	binary    bool // data is codec-encoded, for a binary message
+	template  *controlFrameTemplate
+	seq       int64
+	heartbeat bool
+	frameType string // unset for heartbeats
+	priority  int    // see framePriority
+	// written, when set, receives the result of writing the frame, or
+	// ErrNotConnected when it is discarded unwritten. It is buffered.
+	written chan<- error
+
+	order    uint64 // position in the order frames were queued
+	queuedAt uint64 // frames written before this one was queued
+}
+
+// lane is a growable ring buffer of the queued frames of one priority
+type lane struct {
+	buf  []outboundFrame
+	head int
+	n    int
+}
+
+func (l *lane) at(i int) *outboundFrame {
+	return &l.buf[(l.head+i)%len(l.buf)]
+}
+
+// push appends f, growing the buffer up to limit frames
+func (l *lane) push(f outboundFrame, limit int) {
+	if l.n == len(l.buf) {
+		buf := make([]outboundFrame, min(max(8, 2*len(l.buf)), limit))
+		for i := 0; i < l.n; i++ {
+			buf[i] = *l.at(i)
+		}
+		l.buf, l.head = buf, 0
+	}
+	*l.at(l.n) = f
+	l.n++
+}
+
+// remove takes out the frame at index i, moving the older frames up by one
+func (l *lane) remove(i int) outboundFrame {
+	f := *l.at(i)
+	for j := i; j > 0; j-- {
+		*l.at(j) = *l.at(j - 1)
+	}
+	*l.at(0) = outboundFrame{}
+	l.head = (l.head + 1) % len(l.buf)
+	l.n--
+	return f
+}
+
+// outboundQueue is the bounded queue of frames waiting to be written to one
+// connection. Senders push frames and a single writer goroutine pops and
+// writes them, so slow writes never hold up request goroutines beyond the
+// queue's overflow policy.
+//
+// The writer takes gold frames before silver ones and silver before bronze,
+// first in first out within a class. A waiting frame rises one class for
+// every priorityAging frames written ahead of it, so bronze frames still go
+// out under a steady stream of gold ones.
+type outboundQueue struct {
+	policy   OverflowPolicy
+	capacity int
+	queued   *atomic.Int64 // frames queued across the client's connections
+
+	mu       sync.Mutex
+	lanes    [numPriorities]lane
+	n        int    // number of queued frames
+	pushed   uint64 // frames queued so far
+	popped   uint64 // frames written so far
+	closed   bool
+	draining bool          // no frames accepted, the writer stops once empty
+	drained  chan struct{} // closed with the queue once draining
+	space    chan struct{} // closed and replaced when frames leave the queue
+	ready    chan struct{} // signalled when frames are added or the queue closes
+}
+
+// newOutboundQueue returns an empty queue holding up to capacity frames
+func newOutboundQueue(capacity int, policy OverflowPolicy, queued *atomic.Int64) *outboundQueue {
+	return &outboundQueue{
+		policy:   policy,
+		capacity: capacity,
+		queued:   queued,
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+// push queues f, applying the overflow policy when the queue is full. It
+// fails with ErrNotConnected once the queue is closed or draining.
+func (q *outboundQueue) push(ctx context.Context, f outboundFrame) error {
+	q.mu.Lock()
+	for {
+		if q.closed || q.draining {
+			q.mu.Unlock()
+			return ErrNotConnected
+		}
+		if q.n < q.capacity {
+			break
+		}
+		switch q.policy {
+		case OverflowError:
+			q.mu.Unlock()
+			return ErrQueueFull
+		case OverflowDropOldestHeartbeats:
+			if q.dropHeartbeatLocked() {
+				continue
+			}
+			if f.heartbeat {
+				q.mu.Unlock()
+				return nil
+			}
+		}
+
+		if q.space == nil {
+			q.space = make(chan struct{})
+		}
+		space := q.space
+		q.mu.Unlock()
+		select {
+		case <-space:
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for the outbound queue: %w", ctx.Err())
+		}
+		q.mu.Lock()
+	}
+
+	f.order, f.queuedAt = q.pushed, q.popped
+	q.pushed++
+	q.lanes[f.priority].push(f, q.capacity)
+	q.n++
+	q.queued.Add(1)
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// pop blocks until a frame is queued and removes the most urgent one. It
+// reports false once the queue is closed, or draining and empty.
+func (q *outboundQueue) pop() (outboundFrame, bool) {
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return outboundFrame{}, false
+		}
+		if q.n > 0 {
+			f := q.lanes[q.nextLaneLocked()].remove(0)
+			q.popped++
+			q.n--
+			q.queued.Add(-1)
+			q.notifySpaceLocked()
+			q.mu.Unlock()
+			return f, true
+		}
+		if q.draining {
+			q.closeLocked()
+			q.mu.Unlock()
+			return outboundFrame{}, false
+		}
+		q.mu.Unlock()
+		<-q.ready
+	}
+}
+
+// nextLaneLocked returns the lane whose first frame is written next: the
+// one of the highest priority after aging, the oldest of equals. The queue
+// must not be empty. The caller holds mu.
+func (q *outboundQueue) nextLaneLocked() int {
+	next, best := -1, 0
+	for p := range q.lanes {
+		l := &q.lanes[p]
+		if l.n == 0 {
+			continue
+		}
+		f := l.at(0)
+		rank := p - int((q.popped-f.queuedAt)/priorityAging)
+		if next < 0 || rank < best || rank == best && f.order < q.lanes[next].at(0).order {
+			next, best = p, rank
+		}
+	}
+	return next
+}
+
+// dropHeartbeatLocked removes the oldest queued heartbeat, reporting false
+// when none is queued. The caller holds mu.
+func (q *outboundQueue) dropHeartbeatLocked() bool {
+	for p := range q.lanes {
+		l := &q.lanes[p]
+		for i := 0; i < l.n; i++ {
+			if l.at(i).heartbeat {
+				l.remove(i)
+				q.n--
+				q.queued.Add(-1)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// drain stops the queue accepting frames while its writer writes those
+// already queued. The returned channel is closed once the writer is done,
+// or the queue was closed otherwise.
+func (q *outboundQueue) drain() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.drained == nil {
+		q.drained = make(chan struct{})
+		if q.closed {
+			close(q.drained)
+		}
+	}
+	q.draining = true
+	q.notifySpaceLocked()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return q.drained
+}
+
+// close discards the queued frames and stops the writer. Blocked senders
+// fail with ErrNotConnected.
+func (q *outboundQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closeLocked()
+}
+
+// closeLocked is close with mu held
+func (q *outboundQueue) closeLocked() {
+	if q.closed {
+		return
+	}
+	q.closed = true
+	if q.drained != nil {
+		close(q.drained)
+	}
+	q.queued.Add(int64(-q.n))
+	for p := range q.lanes {
+		l := &q.lanes[p]
+		for i := 0; i < l.n; i++ {
+			if written := l.at(i).written; written != nil {
+				written <- ErrNotConnected
+			}
+		}
+		q.lanes[p] = lane{}
+	}
+	q.n = 0
+	q.notifySpaceLocked()
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// notifySpaceLocked wakes the senders waiting for space. The caller holds
+// mu.
+func (q *outboundQueue) notifySpaceLocked() {
+	if q.space != nil {
+		close(q.space)
+		q.space = nil
+	}
+}
+
+// closeQueues closes queues gracefully: their writers get up to
+// drainTimeout in all to write the frames already queued, and whatever is
+// left then is discarded.
+func (c *ATPClient) closeQueues(queues ...*outboundQueue) {
+	drained := make([]<-chan struct{}, len(queues))
+	for i, q := range queues {
+		drained[i] = q.drain()
+	}
+	timeout := c.config.Clock.After(drainTimeout)
+wait:
+	for _, done := range drained {
+		select {
+		case <-done:
+		case <-timeout:
+			break wait
+		}
+	}
+	for _, q := range queues {
+		q.close()
+	}
+}
+
+// newConnQueue returns the outbound queue of a new connection
+func (c *ATPClient) newConnQueue() *outboundQueue {
+	return newOutboundQueue(c.config.OutboundQueueSize, c.config.OverflowPolicy, &c.counters.outboundQueued)
+}
+
+// writeLoop is the single writer of conn: it writes the frames of q in
+// order until q is closed. A failed write closes q and reports the
+// connection as lost through lost.
+func (c *ATPClient) writeLoop(conn Transport, q *outboundQueue, lost func(error)) {
+	var buf []byte
+	for {
+		f, ok := q.pop()
+		if !ok {
+			return
+		}
+		data := f.data
+		if f.template != nil {
+			buf = f.template.appendTo(buf[:0], c.config.Clock.Now().UnixMilli(), f.seq)
+			data = buf
+		}
+		if err := sendMessage(conn, data, f.binary); err != nil {
+			err = fmt.Errorf("write failed: %w", err)
+			if f.written != nil {
+				f.written <- err
+			}
+			q.close()
+			lost(err)
+			return
+		}
+		if f.written != nil {
+			f.written <- nil
+		}
+		c.counters.framesSent.Add(1)
+		frameType := f.frameType
+		if f.heartbeat {
+			frameType = FrameTypeHeartbeat
+		}
+		c.counters.sentByType.add(frameType)
+		c.config.Metrics.IncCounter(MetricFramesSent, 1, map[string]string{"frame_type": frameType})
+	}
+}
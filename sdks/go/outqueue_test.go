@@ -0,0 +1,400 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stalledTransport is a Transport whose Send and Receive block until it is
+// closed, like a connection to a router that stopped reading
+type stalledTransport struct {
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (s *stalledTransport) Send(frame []byte) error {
+	<-s.closed
+	return errors.New("transport closed")
+}
+
+func (s *stalledTransport) Receive() ([]byte, error) {
+	<-s.closed
+	return nil, errors.New("transport closed")
+}
+
+func (s *stalledTransport) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+// newStalledClient returns a client connected over a stalledTransport
+func newStalledClient(t *testing.T, config SDKConfig) (*ATPClient, *stalledTransport) {
+	t.Helper()
+	client := NewATPClient(config)
+	t.Cleanup(func() { client.Close() })
+	conn := &stalledTransport{closed: make(chan struct{})}
+	client.conn = conn
+	client.out = client.newConnQueue()
+	client.connected = true
+	go client.writeLoop(conn, client.out, func(err error) { client.connectionLost(conn, err) })
+	return client, conn
+}
+
+// testFrames returns an outbound frame carrying each name, heartbeats when
+// heartbeat is set
+func testFrames(heartbeat bool, names ...string) []outboundFrame {
+	frames := make([]outboundFrame, len(names))
+	for i, name := range names {
+		frames[i] = outboundFrame{data: []byte(name), heartbeat: heartbeat}
+	}
+	return frames
+}
+
+func pushAll(t *testing.T, q *outboundQueue, frames ...outboundFrame) {
+	t.Helper()
+	for _, f := range frames {
+		if err := q.push(context.Background(), f); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+	}
+}
+
+func popAll(q *outboundQueue, n int) []string {
+	var names []string
+	for i := 0; i < n; i++ {
+		f, _ := q.pop()
+		names = append(names, string(f.data))
+	}
+	return names
+}
+
+func TestOverflowBlock(t *testing.T) {
+	var queued atomic.Int64
+	q := newOutboundQueue(2, OverflowBlock, &queued)
+	pushAll(t, q, testFrames(false, "a", "b")...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.push(ctx, outboundFrame{data: []byte("c")}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the push to wait for space, got %v", err)
+	}
+
+	pushed := make(chan error, 1)
+	go func() { pushed <- q.push(context.Background(), outboundFrame{data: []byte("c")}) }()
+	if got := popAll(q, 1); got[0] != "a" {
+		t.Fatalf("Expected a, got %v", got)
+	}
+	if err := <-pushed; err != nil {
+		t.Fatalf("Expected the push to proceed once space freed up, got %v", err)
+	}
+	if got := popAll(q, 2); !slices.Equal(got, []string{"b", "c"}) || queued.Load() != 0 {
+		t.Errorf("Unexpected queue order %v with %d queued", got, queued.Load())
+	}
+}
+
+func TestOverflowError(t *testing.T) {
+	var queued atomic.Int64
+	q := newOutboundQueue(2, OverflowError, &queued)
+	pushAll(t, q, testFrames(true, "a", "b")...)
+
+	if err := q.push(context.Background(), outboundFrame{data: []byte("c")}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Expected ErrQueueFull, got %v", err)
+	}
+	if queued.Load() != 2 {
+		t.Errorf("Expected 2 queued frames, got %d", queued.Load())
+	}
+}
+
+func TestOverflowDropOldestHeartbeats(t *testing.T) {
+	var queued atomic.Int64
+	q := newOutboundQueue(4, OverflowDropOldestHeartbeats, &queued)
+	pushAll(t, q, testFrames(false, "a")...)
+	pushAll(t, q, testFrames(true, "hb1")...)
+	pushAll(t, q, testFrames(false, "b")...)
+	pushAll(t, q, testFrames(true, "hb2")...)
+
+	// A full queue evicts its oldest heartbeat, keeping the order of the rest
+	pushAll(t, q, testFrames(false, "c", "d")...)
+	if got := popAll(q, 4); !slices.Equal(got, []string{"a", "b", "c", "d"}) {
+		t.Fatalf("Unexpected queue contents %v", got)
+	}
+
+	// Without heartbeats to evict, heartbeats are dropped and other frames
+	// wait for space
+	pushAll(t, q, testFrames(false, "e", "f", "g", "h")...)
+	pushAll(t, q, testFrames(true, "hb3")...)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.push(ctx, outboundFrame{data: []byte("i")}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the push to wait for space, got %v", err)
+	}
+	if got := popAll(q, 4); !slices.Equal(got, []string{"e", "f", "g", "h"}) || queued.Load() != 0 {
+		t.Errorf("Unexpected queue contents %v with %d queued", got, queued.Load())
+	}
+}
+
+func TestSendFrameWaitsForQueueSpace(t *testing.T) {
+	clock := newFakeClock()
+	client, _ := newStalledClient(t, SDKConfig{OutboundQueueSize: 1, Clock: clock})
+
+	// The writer holds one frame in Send and the queue holds the next one
+	for i := 0; i < 2; i++ {
+		if err := client.sendFrame(Frame{Type: "custom"}); err != nil {
+			t.Fatalf("sendFrame failed: %v", err)
+		}
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for client.counters.outboundQueued.Load() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := client.sendFrameContext(ctx, Frame{Type: "custom"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected sendFrameContext to give up with its context, got %v", err)
+	}
+
+	// A sender waiting for space fails as soon as Disconnect starts
+	// draining the queue, and Disconnect gives up on the stalled writer
+	// after drainTimeout
+	blocked := make(chan error, 1)
+	go func() { blocked <- client.sendFrame(Frame{Type: "custom"}) }()
+	time.Sleep(10 * time.Millisecond)
+	disconnected := make(chan struct{})
+	go func() {
+		client.Disconnect()
+		close(disconnected)
+	}()
+	select {
+	case err := <-blocked:
+		if !errors.Is(err, ErrNotConnected) {
+			t.Errorf("Expected the waiting sender to fail with ErrNotConnected, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("A sender waiting for space was not released by Disconnect")
+	}
+	deadline = time.Now().Add(2 * time.Second)
+	for done := false; !done; {
+		clock.Advance(drainTimeout)
+		select {
+		case <-disconnected:
+			done = true
+		case <-time.After(5 * time.Millisecond):
+			if time.Now().After(deadline) {
+				t.Fatal("Disconnect blocked behind a stalled writer")
+			}
+		}
+	}
+	if got := client.counters.outboundQueued.Load(); got != 0 {
+		t.Errorf("Expected closing the queue to discard its frames, %d left", got)
+	}
+}
+
+func TestOutboundQueueDrain(t *testing.T) {
+	var queued atomic.Int64
+	q := newOutboundQueue(8, OverflowBlock, &queued)
+	pushAll(t, q, testFrames(false, "a", "b")...)
+
+	drained := q.drain()
+	if err := q.push(context.Background(), outboundFrame{data: []byte("c")}); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected a draining queue to refuse frames, got %v", err)
+	}
+	select {
+	case <-drained:
+		t.Fatal("Queue reported drained with frames left")
+	default:
+	}
+
+	// The writer still gets the queued frames, then stops
+	if got := popAll(q, 2); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("Unexpected queue contents %v", got)
+	}
+	if _, ok := q.pop(); ok {
+		t.Error("Expected pop to stop the writer once the queue is drained")
+	}
+	select {
+	case <-drained:
+	default:
+		t.Error("Expected the queue to report drained")
+	}
+}
+
+func TestDisconnectFlushesQueuedFrames(t *testing.T) {
+	const frames = 200
+
+	var mu sync.Mutex
+	received := 0
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type == "custom" {
+			mu.Lock()
+			received++
+			mu.Unlock()
+		}
+		return nil
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	for i := 0; i < frames; i++ {
+		if err := client.sendFrame(Frame{Type: "custom", Payload: map[string]interface{}{"i": i}}); err != nil {
+			t.Fatalf("sendFrame failed: %v", err)
+		}
+	}
+	if err := client.Disconnect(); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := received
+		mu.Unlock()
+		if n == frames {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Router received %d of %d frames queued before Disconnect", n, frames)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWriteFailureDropsConnection(t *testing.T) {
+	disconnects := make(chan error, 1)
+	client, conn := newStalledClient(t, SDKConfig{OnDisconnect: func(err error) { disconnects <- err }})
+	if err := client.sendFrame(Frame{Type: "custom"}); err != nil {
+		t.Fatalf("sendFrame failed: %v", err)
+	}
+	conn.Close()
+
+	select {
+	case err := <-disconnects:
+		if err == nil {
+			t.Error("Expected the write error as disconnect cause")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("A failed write did not drop the connection")
+	}
+	if client.IsConnected() {
+		t.Error("Expected the client to be disconnected")
+	}
+}
+
+func TestWaitForWrite(t *testing.T) {
+	client, conn := newStalledClient(t, SDKConfig{})
+
+	// The first frame blocks the writer, so the second one stays queued
+	results := make(chan error, 2)
+	for range 2 {
+		go func() {
+			results <- client.ReportHealth(context.Background(), HealthStatus{AdapterID: "a1"}, FireAndForget(), WaitForWrite())
+		}()
+	}
+	select {
+	case err := <-results:
+		t.Fatalf("Expected the call to wait for the stalled write, got %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	conn.Close()
+	for range 2 {
+		select {
+		case err := <-results:
+			if err == nil {
+				t.Error("Expected the unwritten frames to fail")
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("The call kept waiting after the connection was lost")
+		}
+	}
+}
+
+// TestConcurrentSends sends from 100 goroutines at once for 5 seconds,
+// alongside heartbeats. Run with -race, it fails on any concurrent write to
+// the connection.
+func TestConcurrentSends(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+	var received atomic.Int64
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type == "stress" {
+			received.Add(1)
+		}
+		return nil
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), HeartbeatInterval: 10 * time.Millisecond})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	var sent atomic.Int64
+	stop := time.Now().Add(5 * time.Second)
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(stop) {
+				frame := Frame{Type: "stress", Payload: map[string]interface{}{"sender": i}}
+				var err error
+				if i%2 == 0 {
+					err = client.sendFrame(frame)
+				} else {
+					err = client.sendFrameWritten(context.Background(), frame)
+				}
+				if err != nil {
+					t.Errorf("Send failed: %v", err)
+					return
+				}
+				sent.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for received.Load() < sent.Load() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if received.Load() != sent.Load() || !client.IsConnected() {
+		t.Errorf("Router received %d of %d frames, connected: %v", received.Load(), sent.Load(), client.IsConnected())
+	}
+}
+
+// BenchmarkOutboundQueue measures how long queueing a frame takes with a
+// backlog of 10k frames in the queue. Queueing is O(1), so the latency does
+// not grow with the backlog.
+func BenchmarkOutboundQueue(b *testing.B) {
+	const backlog = 10000
+	var queued atomic.Int64
+	q := newOutboundQueue(backlog+1, OverflowBlock, &queued)
+	frame := outboundFrame{data: []byte(`{"type":"custom","ts":0,"payload":{}}`)}
+	for i := 0; i < backlog; i++ {
+		_ = q.push(context.Background(), frame)
+	}
+
+	latencies := make([]time.Duration, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		_ = q.push(context.Background(), frame)
+		latencies[i] = time.Since(start)
+		// The writer takes one frame, keeping the backlog at 10k
+		q.pop()
+	}
+	b.StopTimer()
+
+	slices.Sort(latencies)
+	b.ReportMetric(float64(latencies[len(latencies)/2].Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+}
@@ -0,0 +1,299 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// maxAbandonedRequests bounds how many abandoned requests are remembered
+// for orphan response detection
+const maxAbandonedRequests = 1024
+
+// PendingRequest describes a request waiting for its response
+type PendingRequest struct {
+	StreamID      string
+	MsgSeq        int
+	FrameType     string
+	CorrelationID string
+	Age           time.Duration
+}
+
+// OrphanResponse describes a response that arrived after its request was
+// abandoned, e.g. because it timed out
+type OrphanResponse struct {
+	StreamID string
+	MsgSeq   int
+	// FrameType is the type of the late response frame
+	FrameType string
+	// RequestType is the type of the abandoned request frame
+	RequestType string
+	// CorrelationID is the correlation ID of the abandoned request
+	CorrelationID string
+	// Age is the time between sending the request and the response arriving
+	Age time.Duration
+}
+
+// pendingResponse is a registered waiter for a response frame
+type pendingResponse struct {
+	requestID string
+	streamID  string
+	msgSeq    int
+	frameType string
+	trace     *TraceContext // of the request, for its cancel frame
+	// correlationID is the request's Meta.CorrelationID
+	correlationID string
+	ch            chan pendingResult
+	sentAt        time.Time
+	// unreplayable is set, under handlerMutex, when the request was dropped
+	// from the full replay buffer
+	unreplayable bool
+	// chunks, when set, receives the request's completion response chunks
+	// as they arrive; only the final one is handed to the waiter
+	chunks *chunkCollector
+	// handoffs and handoffNanos add up the request's warm handoffs, see
+	// handOff
+	handoffs     atomic.Int32
+	handoffNanos atomic.Int64
+	// resumed is the request a stream.resume moves, credited with the
+	// handoff when the resume is acknowledged
+	resumed atomic.Pointer[pendingResponse]
+}
+
+// pendingResult is the response frame handed to a waiter, or the error
+// that replaced it
+type pendingResult struct {
+	frame *Frame
+	err   error
+}
+
+// abandonedRequest is a request whose waiter gave up before the response
+// arrived
+type abandonedRequest struct {
+	frameType     string
+	correlationID string
+	sentAt        time.Time
+}
+
+// responseKey identifies the response to a request frame
+func responseKey(streamID string, msgSeq int) string {
+	return fmt.Sprintf("%s:%d", streamID, msgSeq)
+}
+
+// expectResponse registers a waiter for the response to frame. It is called
+// before the frame is sent so that a fast response cannot arrive before the
+// waiter exists. The waiter is released by waitForResponse, or by
+// discardPending if the frame could not be sent.
+func (c *ATPClient) expectResponse(frame Frame) *pendingResponse {
+	return c.expectChunkedResponse(frame, nil)
+}
+
+// expectChunkedResponse is expectResponse handing the chunks of a streamed
+// completion response to chunks, when not nil
+func (c *ATPClient) expectChunkedResponse(frame Frame, chunks *chunkCollector) *pendingResponse {
+	pending := &pendingResponse{
+		chunks:        chunks,
+		requestID:     responseKey(frame.StreamID, frame.MsgSeq),
+		streamID:      frame.StreamID,
+		msgSeq:        frame.MsgSeq,
+		frameType:     frame.Type,
+		trace:         frame.Meta.Trace,
+		correlationID: frame.Meta.CorrelationID,
+		ch:            make(chan pendingResult, 1),
+		sentAt:        c.config.Clock.Now(),
+	}
+
+	c.handlerMutex.Lock()
+	c.responseHandlers[pending.requestID] = pending
+	c.handlerMutex.Unlock()
+	c.config.Metrics.SetGauge(MetricPendingRequests, float64(c.counters.pending.Add(1)), nil)
+	return pending
+}
+
+// discardPending deregisters a waiter. An abandoned waiter is remembered so
+// that a late response to it is reported as an orphan.
+func (c *ATPClient) discardPending(pending *pendingResponse, abandoned bool) {
+	c.handlerMutex.Lock()
+	delete(c.responseHandlers, pending.requestID)
+	if abandoned {
+		if c.abandoned == nil {
+			c.abandoned = make(map[string]abandonedRequest)
+		}
+		if len(c.abandonedOrder) >= maxAbandonedRequests {
+			delete(c.abandoned, c.abandonedOrder[0])
+			c.abandonedOrder = c.abandonedOrder[1:]
+		}
+		c.abandoned[pending.requestID] = abandonedRequest{frameType: pending.frameType, correlationID: pending.correlationID, sentAt: pending.sentAt}
+		c.abandonedOrder = append(c.abandonedOrder, pending.requestID)
+	}
+	c.handlerMutex.Unlock()
+	c.config.Metrics.SetGauge(MetricPendingRequests, float64(c.counters.pending.Add(-1)), nil)
+	c.forgetReplay(pending.requestID)
+}
+
+// waitForResponse waits for the response registered with expectResponse
+// and deregisters the waiter. When the wait is abandoned because of a
+// timeout or the caller's context, a cancel frame is sent if
+// SDKConfig.CancelOnTimeout is set.
+func (c *ATPClient) waitForResponse(ctx context.Context, pending *pendingResponse) (*Frame, error) {
+	// Suspensions only concern requests for the client's own tenant
+	var suspended <-chan struct{}
+	if c.tenantOf(ctx) == c.config.TenantID {
+		suspended = c.tenantSuspendedSignal()
+	}
+
+	// DefaultTimeout only applies when the caller has not set a deadline
+	var timeout <-chan time.Time
+	if _, ok := ctx.Deadline(); !ok {
+		timeout = c.config.Clock.After(c.config.DefaultTimeout)
+	}
+
+	epoch := c.epoch()
+	var err error
+	outcome := "timeout"
+	select {
+	case result := <-pending.ch:
+		c.discardPending(pending, false)
+		outcome = "ok"
+		if result.err != nil || result.frame != nil && (result.frame.Type == FrameTypeError || result.frame.Type == FrameTypeNack) {
+			outcome = "error"
+		}
+		c.observeRequest(pending, outcome)
+		return result.frame, result.err
+	case <-ctx.Done():
+		err = ctx.Err()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			outcome = "canceled"
+		}
+	case <-epoch.Done():
+		// Disconnect or Close
+		c.discardPending(pending, false)
+		c.observeRequest(pending, "closed")
+		if c.closed() {
+			return nil, ErrClientClosed
+		}
+		return nil, ErrNotConnected
+	case <-suspended:
+		outcome = "suspended"
+		err = ErrTenantSuspended
+		if suspension := c.tenantSuspended(); suspension != nil {
+			err = suspension
+		}
+	case <-timeout:
+		err = fmt.Errorf("request timeout")
+	}
+
+	c.discardPending(pending, true)
+	c.observeRequest(pending, outcome)
+	if c.config.CancelOnTimeout {
+		c.cancelRequest(ctx, pending, err.Error())
+	}
+	return nil, err
+}
+
+// cancelRequest sends a cancel frame for pending's request, so the router
+// stops working on it
+func (c *ATPClient) cancelRequest(ctx context.Context, pending *pendingResponse, reason string) {
+	cancel := c.newFrameBuilder(c.tenantOf(ctx)).BuildCancelFrame(pending.streamID, pending.msgSeq, reason, Meta{Trace: pending.trace})
+	if err := c.sendFrame(cancel); err != nil {
+		c.config.Logger.Printf("Warning: Failed to cancel abandoned request %s%s: %v", pending.requestID, logCorrelation(pending.correlationID), err)
+	}
+}
+
+// observeRequest reports the duration and outcome of pending's request to
+// the metrics sink
+func (c *ATPClient) observeRequest(pending *pendingResponse, outcome string) {
+	c.config.Metrics.ObserveHistogram(MetricRequestDuration, c.config.Clock.Now().Sub(pending.sentAt).Seconds(),
+		map[string]string{"frame_type": pending.frameType, "outcome": outcome})
+}
+
+// dispatchResponse hands a response frame to its waiter and returns the
+// request latency. Responses to abandoned requests are reported through
+// OnOrphanResponse.
+func (c *ATPClient) dispatchResponse(frame *Frame) time.Duration {
+	return c.dispatchResult(frame, pendingResult{frame: frame})
+}
+
+// dispatchResult hands result to the waiter for the response frame, as
+// dispatchResponse does
+func (c *ATPClient) dispatchResult(frame *Frame, result pendingResult) time.Duration {
+	requestID := responseKey(frame.StreamID, frame.MsgSeq)
+
+	c.handlerMutex.Lock()
+	if handler, exists := c.responseHandlers[requestID]; exists {
+		c.handlerMutex.Unlock()
+		if handler.chunks != nil && frame.Type == FrameTypeCompletionResponse {
+			final, err := handler.chunks.deliver(frame)
+			if err != nil {
+				c.failPending(handler, err)
+			}
+			if err != nil || !final {
+				return c.config.Clock.Now().Sub(handler.sentAt)
+			}
+		}
+		if resumed := handler.resumed.Load(); resumed != nil && result.err == nil && frame.Type != FrameTypeError {
+			// Credited before the resumed response's next frame is read
+			resumed.handoffs.Add(1)
+			resumed.handoffNanos.Add(int64(c.config.Clock.Now().Sub(handler.sentAt)))
+		}
+		select {
+		case handler.ch <- result:
+		default:
+			// Duplicate response, the waiter already has one
+		}
+		return c.config.Clock.Now().Sub(handler.sentAt)
+	}
+	abandoned, wasAbandoned := c.abandoned[requestID]
+	if wasAbandoned {
+		delete(c.abandoned, requestID)
+		for i, id := range c.abandonedOrder {
+			if id == requestID {
+				c.abandonedOrder = append(c.abandonedOrder[:i:i], c.abandonedOrder[i+1:]...)
+				break
+			}
+		}
+	}
+	c.handlerMutex.Unlock()
+
+	if !wasAbandoned {
+		return 0
+	}
+	latency := c.config.Clock.Now().Sub(abandoned.sentAt)
+	c.counters.orphans.Add(1)
+	if c.config.OnOrphanResponse != nil {
+		c.config.OnOrphanResponse(OrphanResponse{
+			StreamID:      frame.StreamID,
+			MsgSeq:        frame.MsgSeq,
+			FrameType:     frame.Type,
+			RequestType:   abandoned.frameType,
+			CorrelationID: abandoned.correlationID,
+			Age:           latency,
+		})
+	}
+	return latency
+}
+
+// PendingRequests returns the requests currently waiting for a response,
+// oldest first
+func (c *ATPClient) PendingRequests() []PendingRequest {
+	now := c.config.Clock.Now()
+
+	c.handlerMutex.RLock()
+	requests := make([]PendingRequest, 0, len(c.responseHandlers))
+	for _, pending := range c.responseHandlers {
+		requests = append(requests, PendingRequest{
+			StreamID:      pending.streamID,
+			MsgSeq:        pending.msgSeq,
+			FrameType:     pending.frameType,
+			CorrelationID: pending.correlationID,
+			Age:           now.Sub(pending.sentAt),
+		})
+	}
+	c.handlerMutex.RUnlock()
+
+	sort.Slice(requests, func(i, j int) bool { return requests[i].Age > requests[j].Age })
+	return requests
+}
@@ -0,0 +1,144 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPendingRequestsAndOrphanResponses(t *testing.T) {
+	requests := make(chan Frame, 4)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type == "completion_request" || f.Type == "cancel" {
+			requests <- f
+		}
+		return nil
+	})
+
+	orphans := make(chan OrphanResponse, 1)
+	client := NewATPClient(SDKConfig{
+		WSURL:            router.URL(),
+		OnOrphanResponse: func(o OrphanResponse) { orphans <- o },
+	})
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Complete(ctx, CompletionRequest{Prompt: "slow"})
+		done <- err
+	}()
+
+	request := <-requests
+	pending := client.PendingRequests()
+	if len(pending) != 1 || pending[0].StreamID != request.StreamID || pending[0].MsgSeq != request.MsgSeq || pending[0].FrameType != "completion_request" ||
+		pending[0].CorrelationID == "" || pending[0].CorrelationID != request.Meta.CorrelationID {
+		t.Fatalf("Unexpected pending requests: %+v", pending)
+	}
+
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) || CorrelationIDOf(err) != request.Meta.CorrelationID {
+		t.Fatalf("Expected deadline exceeded for the request's correlation ID, got %v", err)
+	}
+	if pending := client.PendingRequests(); len(pending) != 0 {
+		t.Errorf("Expected no pending requests after timeout, got %+v", pending)
+	}
+
+	// The late response is reported instead of silently discarded
+	_ = router.Send(Frame{Type: "completion_response", StreamID: request.StreamID, MsgSeq: request.MsgSeq, Payload: map[string]interface{}{"text": "late"}})
+	select {
+	case orphan := <-orphans:
+		if orphan.StreamID != request.StreamID || orphan.RequestType != "completion_request" || orphan.FrameType != "completion_response" ||
+			orphan.CorrelationID != request.Meta.CorrelationID {
+			t.Errorf("Unexpected orphan: %+v", orphan)
+		}
+		if orphan.Age < 50*time.Millisecond {
+			t.Errorf("Expected orphan age to cover the timeout, got %v", orphan.Age)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Orphan response not reported")
+	}
+	if stats := client.Stats().Pending; stats.Count != 0 || stats.Orphaned != 1 {
+		t.Errorf("Unexpected pending stats: %+v", stats)
+	}
+
+	// Unsolicited responses are not orphans, and neither is a repeat
+	_ = router.Send(Frame{Type: "completion_response", StreamID: "unknown", MsgSeq: 1})
+	_ = router.Send(Frame{Type: "completion_response", StreamID: request.StreamID, MsgSeq: request.MsgSeq})
+	time.Sleep(20 * time.Millisecond)
+	if orphaned := client.Stats().Pending.Orphaned; orphaned != 1 {
+		t.Errorf("Expected a single orphan, got %d", orphaned)
+	}
+
+	// Without CancelOnTimeout no cancel frame is sent
+	select {
+	case f := <-requests:
+		t.Errorf("Unexpected frame %s", f.Type)
+	default:
+	}
+}
+
+func TestCancelOnTimeout(t *testing.T) {
+	frames := make(chan Frame, 4)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		frames <- f
+		return nil
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), DefaultTimeout: 50 * time.Millisecond, CancelOnTimeout: true})
+	defer client.Close()
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "slow"}); err == nil {
+		t.Fatal("Expected a timeout")
+	}
+
+	request := <-frames
+	select {
+	case cancel := <-frames:
+		if cancel.Type != "cancel" || cancel.StreamID != request.StreamID || cancel.MsgSeq != request.MsgSeq {
+			t.Errorf("Unexpected cancel frame: %+v", cancel)
+		}
+		if cancel.Payload["reason"] != "request timeout" {
+			t.Errorf("Unexpected cancel reason %v", cancel.Payload["reason"])
+		}
+		if cancel.Meta.Trace.TraceID() == "" || cancel.Meta.Trace.TraceID() != request.Meta.Trace.TraceID() {
+			t.Errorf("Expected the cancel to carry the request's trace, got %+v", cancel.Meta.Trace)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Cancel frame not sent")
+	}
+}
+
+func TestRequestTimeoutOnClock(t *testing.T) {
+	router := newTestRouter(t, nil)
+	clock := newFakeClock()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Clock: clock, HeartbeatJitter: -1, DefaultTimeout: 10 * time.Second})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	waitForWaiters(t, clock, 1) // the heartbeat ticker
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+		done <- err
+	}()
+	waitForWaiters(t, clock, 2)
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the request to wait for the clock, got %v", err)
+	default:
+	}
+
+	clock.Advance(10 * time.Second)
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "request timeout") {
+			t.Errorf("Expected a request timeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("The request did not time out on the clock")
+	}
+}
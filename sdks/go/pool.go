@@ -0,0 +1,353 @@
+package atpsdk
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// poolVirtualNodes is the number of points each pool connection owns on the
+// hash ring, which evens out the share of streams per connection
+const poolVirtualNodes = 64
+
+// connPool holds the extra connections of a pooled client (PoolSize > 1).
+// Connection 0 is the client's own primary connection; members[i] is
+// connection i+1.
+type connPool struct {
+	members []*poolMember
+	ring    []ringPoint // sorted by hash
+}
+
+// ringPoint is one virtual node of a pool connection on the hash ring
+type ringPoint struct {
+	hash uint32
+	conn int
+}
+
+// poolMember is one extra pooled connection. It has its own read loop and
+// heartbeat, and is redialled on its own when it fails.
+type poolMember struct {
+	index int
+
+	mu        sync.RWMutex // guards the fields below; taken after connMutex
+	conn      Transport
+	done      chan struct{} // closed when conn is torn down
+	redialing bool
+	stopped   bool
+	draining  bool           // conn is being rotated, see rotatePoolMember
+	out       *outboundQueue // of conn, drained by its writeLoop
+	heartbeat *heartbeatMonitor
+}
+
+// newConnPool builds the hash ring for size connections
+func newConnPool(size int) *connPool {
+	p := &connPool{
+		members: make([]*poolMember, size-1),
+		ring:    make([]ringPoint, 0, size*poolVirtualNodes),
+	}
+	for i := range p.members {
+		p.members[i] = &poolMember{index: i + 1}
+	}
+	for conn := 0; conn < size; conn++ {
+		for node := 0; node < poolVirtualNodes; node++ {
+			p.ring = append(p.ring, ringPoint{hash: hashKey(fmt.Sprintf("%d#%d", conn, node)), conn: conn})
+		}
+	}
+	slices.SortFunc(p.ring, func(a, b ringPoint) int { return cmp.Compare(a.hash, b.hash) })
+	return p
+}
+
+// hashKey hashes a stream ID or ring node name onto the ring
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// memberFor returns the pool member that owns key, or nil when the key
+// belongs to the primary connection. A member that is down hands its keys
+// to the next connection on the ring until it has been redialled.
+func (p *connPool) memberFor(key string) *poolMember {
+	h := hashKey(key)
+	start := sort.Search(len(p.ring), func(i int) bool { return p.ring[i].hash >= h })
+	for n := range p.ring {
+		point := p.ring[(start+n)%len(p.ring)]
+		if point.conn == 0 {
+			return nil
+		}
+		if m := p.members[point.conn-1]; m.up() {
+			return m
+		}
+	}
+	return nil
+}
+
+// up reports whether the member currently has a connection taking new
+// frames
+func (m *poolMember) up() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.conn != nil && !m.draining
+}
+
+// poolMemberFor returns the pool member that carries frames of streamID, or
+// nil when they go over the primary connection. Frames without a stream ID
+// always use the primary connection.
+func (c *ATPClient) poolMemberFor(streamID string) *poolMember {
+	if c.pool == nil || streamID == "" {
+		return nil
+	}
+	return c.pool.memberFor(streamID)
+}
+
+// connectPoolMembers dials every pool member that is down and not already
+// being redialled. It is called with connMutex held, after the primary
+// connection was established. A member that fails to dial is redialled in
+// the background; the client works with the connections it has meanwhile.
+func (c *ATPClient) connectPoolMembers() {
+	if c.pool == nil {
+		return
+	}
+	for _, m := range c.pool.members {
+		m.mu.Lock()
+		m.stopped = false
+		busy := m.conn != nil || m.redialing
+		m.mu.Unlock()
+		if busy {
+			continue
+		}
+
+		conn, err := c.dialRouter()
+		if err == nil && c.installPoolMember(m, conn) {
+			continue
+		}
+		if err != nil {
+			c.config.Logger.Printf("Warning: Failed to open pool connection %d: %v", m.index, err)
+		}
+		c.redialPoolMember(m)
+	}
+}
+
+// installPoolMember makes conn the member's connection and starts its read
+// loop and heartbeat. It reports false, closing conn, if the pool was
+// stopped while dialing.
+func (c *ATPClient) installPoolMember(m *poolMember, conn Transport) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stopped || c.closed() {
+		_ = conn.Close()
+		return false
+	}
+	m.conn = conn
+	m.out = c.newConnQueue()
+	m.done = make(chan struct{})
+	m.heartbeat.reset()
+	c.counters.poolConnections.Add(1)
+
+	out, done := m.out, m.done
+	c.startPump(func() { c.readPoolMember(m, conn) })
+	c.startPump(func() { c.writeLoop(conn, out, func(err error) { c.poolMemberLost(m, conn, err) }) })
+	c.startPump(func() { c.poolMemberHeartbeats(m, conn, done) })
+	if c.config.PoolMaxAge > 0 {
+		c.startPump(func() { c.expirePoolMember(m, conn, done) })
+	}
+	return true
+}
+
+// expirePoolMember rotates the member connection conn once it is
+// PoolMaxAge old, unless it is torn down first
+func (c *ATPClient) expirePoolMember(m *poolMember, conn Transport, done <-chan struct{}) {
+	select {
+	case <-c.ctx.Done():
+	case <-done:
+	case <-c.config.Clock.After(jitter(c.config.PoolMaxAge, c.config.HeartbeatJitter)):
+		// Not a pump: Close must not wait for the handoffs
+		go c.rotatePoolMember(m, conn)
+	}
+}
+
+// poolMemberLost tears down a member connection after a read error and
+// starts redialling it. It is a no-op if conn was already torn down.
+func (c *ATPClient) poolMemberLost(m *poolMember, conn Transport, cause error) {
+	m.mu.Lock()
+	if m.conn != conn {
+		m.mu.Unlock()
+		return
+	}
+	m.conn = nil
+	m.out.close()
+	close(m.done)
+	c.counters.poolConnections.Add(-1)
+	_ = conn.Close()
+	stopped := m.stopped
+	m.mu.Unlock()
+
+	c.counters.recordError(cause)
+	if stopped || c.closed() {
+		return
+	}
+	c.config.Logger.Printf("Warning: Pool connection %d lost: %v", m.index, cause)
+	c.redialPoolMember(m)
+}
+
+// redialPoolMember redials m in the background, up to MaxRetries times
+// RetryDelay apart. A member that stays down is dialled again by the next
+// Connect.
+func (c *ATPClient) redialPoolMember(m *poolMember) {
+	m.mu.Lock()
+	if m.redialing || m.stopped {
+		m.mu.Unlock()
+		return
+	}
+	m.redialing = true
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			m.redialing = false
+			m.mu.Unlock()
+		}()
+
+		for attempt := 1; attempt <= c.config.MaxRetries; attempt++ {
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-c.config.Clock.After(jitter(c.config.RetryDelay, c.config.HeartbeatJitter)):
+			}
+
+			conn, err := c.dialRouter()
+			if err != nil {
+				c.config.Logger.Printf("Warning: Failed to redial pool connection %d (attempt %d/%d): %v", m.index, attempt, c.config.MaxRetries, err)
+				continue
+			}
+			c.installPoolMember(m, conn)
+			return
+		}
+	}()
+}
+
+// closePoolMembers stops redialling and closes every pool connection once
+// its writer has flushed the frames already queued, or drainTimeout passed
+func (c *ATPClient) closePoolMembers() {
+	if c.pool == nil {
+		return
+	}
+	var queues []*outboundQueue
+	for _, m := range c.pool.members {
+		m.mu.Lock()
+		m.stopped = true
+		if m.conn != nil {
+			queues = append(queues, m.out)
+		}
+		m.mu.Unlock()
+	}
+	c.closeQueues(queues...)
+
+	for _, m := range c.pool.members {
+		m.mu.Lock()
+		if m.conn != nil {
+			_ = m.conn.Close()
+			m.conn = nil
+			close(m.done)
+			c.counters.poolConnections.Add(-1)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// readPoolMember reads frames from a member connection and processes them
+// exactly like frames from the primary connection
+func (c *ATPClient) readPoolMember(m *poolMember, conn Transport) {
+	for {
+		data, binary, err := receiveMessage(conn)
+		if err != nil {
+			c.poolMemberLost(m, conn, fmt.Errorf("read failed: %w", err))
+			return
+		}
+		c.handleIncoming(data, binary)
+	}
+}
+
+// poolMemberHeartbeats sends periodic heartbeats over one member connection
+// until it is torn down, and tears it down when the router stops
+// acknowledging them
+func (c *ATPClient) poolMemberHeartbeats(m *poolMember, conn Transport, done <-chan struct{}) {
+	ticker := c.newTicker(c.heartbeatInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C():
+			m.mu.RLock()
+			draining := m.draining
+			m.mu.RUnlock()
+			if draining {
+				// The connection is being rotated and takes no new frames
+				continue
+			}
+			err := c.sendPoolHeartbeat(m)
+			if errors.Is(err, ErrHeartbeatTimeout) {
+				c.poolMemberLost(m, conn, err)
+				return
+			}
+			if err != nil {
+				c.reportAsyncError(fmt.Errorf("heartbeat failed on pool connection %d: %w", m.index, err))
+			}
+		}
+	}
+}
+
+// sendPoolHeartbeat sends a heartbeat frame over a member connection. Like
+// sendHeartbeat, it only uses the preserialized template when no
+// interceptors or audit sink need a Frame value.
+func (c *ATPClient) sendPoolHeartbeat(m *poolMember) error {
+	seq, missed := m.heartbeat.next()
+	if err := c.heartbeatsFailed(missed); err != nil {
+		return err
+	}
+	if c.heartbeatTemplateUsable() {
+		return m.push(outboundFrame{template: &m.heartbeat.template, seq: seq, heartbeat: true})
+	}
+
+	frame := m.heartbeat.stamp(c.builder.BuildHeartbeatFrame(), seq)
+	if err := c.prepareOutgoing(&frame); err != nil {
+		return err
+	}
+	data, binary, err := c.encodeFrame(&frame)
+	if err != nil {
+		return err
+	}
+	if err := m.push(outboundFrame{data: data, binary: binary, heartbeat: true}); err != nil {
+		return err
+	}
+	c.audit(AuditOutbound, frame, 0)
+	return nil
+}
+
+// queue returns the outbound queue of the member connection, or nil while
+// it is down
+func (m *poolMember) queue() *outboundQueue {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.out
+}
+
+// push queues a heartbeat for the member connection
+func (m *poolMember) push(f outboundFrame) error {
+	queue := m.queue()
+	if queue == nil {
+		return ErrNotConnected
+	}
+	return queue.push(context.Background(), f)
+}
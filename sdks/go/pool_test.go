@@ -0,0 +1,192 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// poolIndex returns the pool connection that carries streamID, 0 being the
+// primary connection
+func poolIndex(c *ATPClient, streamID string) int {
+	if m := c.poolMemberFor(streamID); m != nil {
+		return m.index
+	}
+	return 0
+}
+
+func waitForPoolConnections(t *testing.T, c *ATPClient, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().Connection.PoolConnections != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d pool connections, have %d", n, c.Stats().Connection.PoolConnections)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPoolStreamAffinity(t *testing.T) {
+	const size = 4
+
+	var mu sync.Mutex
+	arrivals := make(map[string]int) // stream ID -> router connection
+	var router *testRouter
+	router = newConnTestRouter(t, func(conn int, f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		mu.Lock()
+		arrivals[f.StreamID] = conn
+		mu.Unlock()
+
+		// Answer on a different connection than the request used
+		reply := Frame{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}
+		if err := router.SendTo((conn+1)%size, reply); err != nil {
+			t.Errorf("SendTo failed: %v", err)
+		}
+		return nil
+	})
+
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), PoolSize: size})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	router.WaitForConnections(t, size)
+	waitForPoolConnections(t, client, size)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+				t.Errorf("Complete failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every router connection must carry the streams of exactly one pool
+	// connection
+	mu.Lock()
+	defer mu.Unlock()
+	routerToClient := make(map[int]int)
+	for streamID, conn := range arrivals {
+		want := poolIndex(client, streamID)
+		if mapped, ok := routerToClient[conn]; ok && mapped != want {
+			t.Fatalf("Stream %s arrived on router connection %d, which also carried streams of pool connection %d", streamID, conn, mapped)
+		}
+		routerToClient[conn] = want
+	}
+	if len(routerToClient) != size {
+		t.Errorf("Expected streams to spread over %d connections, got %v", size, routerToClient)
+	}
+}
+
+func TestPoolMemberReconnectsIndependently(t *testing.T) {
+	router := newTestRouter(t, nil)
+	clock := newFakeClock()
+
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), PoolSize: 3, Clock: clock, HeartbeatJitter: -1, Logger: &recordingLogger{}})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	router.WaitForConnections(t, 3)
+	waitForPoolConnections(t, client, 3)
+
+	member := client.pool.members[0]
+	member.mu.RLock()
+	_ = member.conn.Close()
+	member.mu.RUnlock()
+
+	waitForPoolConnections(t, client, 2)
+	if !client.IsConnected() {
+		t.Error("Losing a pool member must not disconnect the client")
+	}
+	if client.poolMemberFor("any") == member {
+		t.Error("Streams must not be assigned to a member that is down")
+	}
+
+	// The redial waits RetryDelay on the clock, next to the heartbeat tickers
+	deadline := time.Now().Add(2 * time.Second)
+	for router.ActiveConnections() < 3 && time.Now().Before(deadline) {
+		clock.Advance(time.Second)
+		time.Sleep(5 * time.Millisecond)
+	}
+	router.WaitForConnections(t, 4)
+	waitForPoolConnections(t, client, 3)
+}
+
+func TestPoolMemberHeartbeats(t *testing.T) {
+	const size = 3
+
+	var mu sync.Mutex
+	heartbeats := make(map[int]int)
+	router := newConnTestRouter(t, func(conn int, f Frame) []Frame {
+		if f.Type == "heartbeat" {
+			mu.Lock()
+			heartbeats[conn]++
+			mu.Unlock()
+		}
+		return nil
+	})
+
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), PoolSize: size, HeartbeatInterval: 10 * time.Millisecond})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		beating := len(heartbeats)
+		mu.Unlock()
+		if beating == size {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected heartbeats on %d connections, got %v", size, heartbeats)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPoolCloseClosesAllConnections(t *testing.T) {
+	router := newTestRouter(t, nil)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), PoolSize: 4})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	router.WaitForConnections(t, 4)
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for router.ActiveConnections() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d router connections still open after Close", router.ActiveConnections())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := client.Stats().Connection.PoolConnections; n != 0 {
+		t.Errorf("Expected no pool connections after Close, have %d", n)
+	}
+}
+
+func TestPoolFallsBackToPrimary(t *testing.T) {
+	pool := newConnPool(4)
+
+	// With every member down, all streams use the primary connection
+	for i := 0; i < 100; i++ {
+		if m := pool.memberFor(fmt.Sprintf("stream_%d", i)); m != nil {
+			t.Fatalf("Expected the primary connection, got member %d", m.index)
+		}
+	}
+}
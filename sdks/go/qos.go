@@ -0,0 +1,66 @@
+package atpsdk
+
+import (
+	"fmt"
+	"slices"
+)
+
+// QoS is the QoS class of a frame. It marshals as a plain string.
+type QoS string
+
+// QoS classes carried in Frame.QoS, from the most to the least urgent
+const (
+	QoSGold   QoS = "gold"
+	QoSSilver QoS = "silver"
+	QoSBronze QoS = "bronze"
+)
+
+// qosClasses are the known QoS classes, in order
+var qosClasses = []QoS{QoSGold, QoSSilver, QoSBronze}
+
+// Valid reports whether q is one of QoSGold, QoSSilver and QoSBronze
+func (q QoS) Valid() bool {
+	return slices.Contains(qosClasses, q)
+}
+
+// validateQoS returns an error matching ErrInvalidQoS, listing the known
+// classes, unless q is valid
+func validateQoS(q QoS) error {
+	if q.Valid() {
+		return nil
+	}
+	return fmt.Errorf("%w %q: expected one of %v", ErrInvalidQoS, q, qosClasses)
+}
+
+// applyQoS sets the QoS class of request: the one of WithQoS, else its
+// own, else SDKConfig.DefaultQoS. It fails for an unknown class.
+func (c *ATPClient) applyQoS(request *CompletionRequest, options requestOptions) error {
+	if options.qos != "" {
+		request.QoS = options.qos
+	}
+	if request.QoS == "" {
+		request.QoS = c.config.DefaultQoS
+	}
+	return validateQoS(request.QoS)
+}
+
+// numPriorities is the number of scheduling priorities, see framePriority
+const numPriorities = 3
+
+// priorityAging is how many frames may be written ahead of a queued frame
+// before it rises one priority
+const priorityAging = 16
+
+// framePriority ranks a QoS class for scheduling, 0 being the most urgent.
+// Frames without a class, such as heartbeats, rank with gold ones, and
+// unknown classes with bronze ones.
+func framePriority(qos QoS) int {
+	switch qos {
+	case "", QoSGold:
+		return 0
+	case QoSSilver:
+		return 1
+	default:
+		return 2
+	}
+}
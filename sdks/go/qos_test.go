@@ -0,0 +1,219 @@
+package atpsdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scriptedTransport is a Transport whose writes complete one at a time as
+// the test takes them from writes
+type scriptedTransport struct {
+	stalledTransport
+	writes chan []byte
+}
+
+func (s *scriptedTransport) Send(frame []byte) error {
+	select {
+	case s.writes <- frame:
+		return nil
+	case <-s.closed:
+		return errors.New("transport closed")
+	}
+}
+
+// nextStreamID takes the next write and returns its stream ID
+func (s *scriptedTransport) nextStreamID(t *testing.T) string {
+	t.Helper()
+	select {
+	case data := <-s.writes:
+		var frame Frame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+		return frame.StreamID
+	case <-time.After(2 * time.Second):
+		t.Fatal("No frame written")
+		return ""
+	}
+}
+
+func TestPrioritySendOrder(t *testing.T) {
+	client := NewATPClient(SDKConfig{})
+	defer client.Close()
+	conn := &scriptedTransport{stalledTransport{closed: make(chan struct{})}, make(chan []byte)}
+	client.conn = conn
+	client.out = client.newConnQueue()
+	client.connected = true
+	go client.writeLoop(conn, client.out, func(err error) { client.connectionLost(conn, err) })
+
+	send := func(streamID string, qos QoS) {
+		if err := client.sendFrame(Frame{Type: "custom", StreamID: streamID, QoS: qos}); err != nil {
+			t.Fatalf("sendFrame failed: %v", err)
+		}
+	}
+
+	// The writer takes the first batch frame and stalls writing it
+	send("bronze-0", QoSBronze)
+	deadline := time.Now().Add(2 * time.Second)
+	for client.counters.outboundQueued.Load() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	// An interactive request queued behind fifty batch frames goes out next
+	for i := 1; i <= 50; i++ {
+		send(fmt.Sprintf("bronze-%d", i), QoSBronze)
+	}
+	send("silver", QoSSilver)
+	send("gold", QoSGold)
+
+	var got []string
+	for i := 0; i < 53; i++ {
+		got = append(got, conn.nextStreamID(t))
+	}
+	want := []string{"bronze-0", "gold", "silver"}
+	for i := 1; i <= 50; i++ {
+		want = append(want, fmt.Sprintf("bronze-%d", i))
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Unexpected emission order:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestPriorityAging(t *testing.T) {
+	var queued atomic.Int64
+	q := newOutboundQueue(8, OverflowBlock, &queued)
+	gold := outboundFrame{data: []byte("gold")}
+	pushAll(t, q,
+		outboundFrame{data: []byte("bronze"), priority: framePriority(QoSBronze)},
+		outboundFrame{data: []byte("silver"), priority: framePriority(QoSSilver)},
+		gold,
+	)
+
+	// Under a steady stream of gold frames, the others rise one priority
+	// every priorityAging frames written
+	positions := map[string]int{}
+	for i := 0; len(positions) < 2 && i < 100; i++ {
+		f, _ := q.pop()
+		if name := string(f.data); name != "gold" {
+			positions[name] = i
+		}
+		pushAll(t, q, gold)
+	}
+	if positions["silver"] != priorityAging || positions["bronze"] != 2*priorityAging {
+		t.Errorf("Unexpected positions %v", positions)
+	}
+}
+
+// waitForWindowWaiters waits until n requests of priority wait for the
+// flow-control window
+func waitForWindowWaiters(t *testing.T, client *ATPClient, priority, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		client.flow.mu.Lock()
+		waiting := client.flow.waiting[priority]
+		client.flow.mu.Unlock()
+		if waiting == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d requests of priority %d waiting for the window", n, priority)
+}
+
+func TestPriorityWindowOrder(t *testing.T) {
+	router, requests := heldRequestRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	grantWindow(t, router, client, "", Window{MaxParallel: 1})
+
+	results := make(chan error, 3)
+	complete := func(qos QoS) {
+		_, err := client.Complete(context.Background(), CompletionRequest{Prompt: string(qos), QoS: qos})
+		results <- err
+	}
+	go complete(QoSBronze)
+	first := <-requests
+	go complete(QoSBronze)
+	waitForWindowWaiters(t, client, framePriority(QoSBronze), 1)
+	go complete(QoSGold)
+	waitForWindowWaiters(t, client, framePriority(QoSGold), 1)
+
+	// The gold request gets the stream the first one frees, though the
+	// bronze one has waited longer
+	answer(t, router, first, 1)
+	second := <-requests
+	if second.QoS != QoSGold {
+		t.Fatalf("Expected the gold request to go next, got %+v", second)
+	}
+	answer(t, router, second, 1)
+	answer(t, router, <-requests, 1)
+	for i := 0; i < 3; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("Complete failed: %v", err)
+		}
+	}
+}
+
+func TestQoSValidation(t *testing.T) {
+	if !QoSSilver.Valid() || QoS("glod").Valid() || QoS("").Valid() {
+		t.Error("Unexpected QoS validity")
+	}
+	err := ValidateFrame(Frame{Type: "completion_request", QoS: "glod"})
+	if !errors.Is(err, ErrInvalidQoS) || !strings.Contains(err.Error(), "[gold silver bronze]") {
+		t.Errorf("Expected an ErrInvalidQoS listing the classes, got %v", err)
+	}
+	if err := ValidateFrame(Frame{Type: "heartbeat"}); err != nil {
+		t.Errorf("Expected a frame without QoS to be valid, got %v", err)
+	}
+	if data, _ := json.Marshal(Frame{Type: "heartbeat", QoS: QoSBronze}); !strings.Contains(string(data), `"qos":"bronze"`) {
+		t.Errorf("Expected the QoS to marshal as a string, got %s", data)
+	}
+
+	client := NewATPClient(SDKConfig{})
+	defer client.Close()
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithQoS("glod")); !errors.Is(err, ErrInvalidQoS) {
+		t.Errorf("Expected WithQoS to reject an unknown class, got %v", err)
+	}
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi", QoS: "glod"}); !errors.Is(err, ErrInvalidQoS) {
+		t.Errorf("Expected Complete to reject an unknown class, got %v", err)
+	}
+}
+
+func TestDefaultQoS(t *testing.T) {
+	requests := make(chan Frame, 2)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		requests <- f
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), DefaultQoS: QoSSilver})
+	defer client.Close()
+
+	for _, tt := range []struct {
+		opts []RequestOption
+		want QoS
+	}{
+		{nil, QoSSilver},
+		{[]RequestOption{WithQoS(QoSBronze)}, QoSBronze},
+	} {
+		if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, tt.opts...); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		if request := <-requests; request.QoS != tt.want {
+			t.Errorf("Expected QoS %s, got %s", tt.want, request.QoS)
+		}
+	}
+}
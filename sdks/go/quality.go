@@ -0,0 +1,58 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// qualityThreshold is the setting of WithMinQuality
+type qualityThreshold struct {
+	score       float64
+	maxAttempts int
+}
+
+// QualityAttempt is one attempt of a request made with WithMinQuality
+type QualityAttempt struct {
+	ModelUsed    string
+	AdapterID    string
+	QualityScore float64
+	// Err is the failure of an attempt that got no response
+	Err error
+}
+
+// completeWithQuality completes request until a response scores at least
+// threshold.score, excluding the adapter of each response that does not,
+// for at most threshold.maxAttempts attempts. It returns the best response
+// received, with the history of the attempts.
+func (c *ATPClient) completeWithQuality(ctx context.Context, request CompletionRequest, chain []string, preferences []RoutingPreference, threshold qualityThreshold) (*CompletionResponse, error) {
+	var best *CompletionResponse
+	var attempts []QualityAttempt
+	for i := range threshold.maxAttempts {
+		attempt := request
+		if i > 0 && request.IdempotencyKey != "" {
+			// Another adapter makes another request, not a retry of the first
+			attempt.IdempotencyKey = fmt.Sprintf("%s/quality-%d", request.IdempotencyKey, i)
+		}
+		response, err := c.completeRouted(ctx, attempt, chain, preferences)
+		if err != nil {
+			if best == nil {
+				return nil, err
+			}
+			attempts = append(attempts, QualityAttempt{Err: err})
+			break
+		}
+		attempts = append(attempts, QualityAttempt{ModelUsed: response.ModelUsed, AdapterID: response.AdapterID, QualityScore: response.QualityScore})
+		if best == nil || response.QualityScore > best.QualityScore {
+			best = response
+		}
+		if response.QualityScore >= threshold.score {
+			break
+		}
+		if response.AdapterID != "" && !slices.Contains(request.ExcludeAdapters, response.AdapterID) {
+			request.ExcludeAdapters = append(slices.Clip(request.ExcludeAdapters), response.AdapterID)
+		}
+	}
+	best.QualityAttempts = attempts
+	return best, nil
+}
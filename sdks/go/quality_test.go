@@ -0,0 +1,73 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// qualityRouter answers completion requests from the first adapter of
+// scores not excluded by the request, with its score
+func qualityRouter(t *testing.T, adapters []string, scores map[string]float64) (*testRouter, chan []string) {
+	excluded := make(chan []string, len(adapters)+1)
+	fb := NewFrameBuilder("", "")
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeCompletionRequest {
+			return nil
+		}
+		request, _ := DecodePayload[CompletionRequest](f)
+		excluded <- request.ExcludeAdapters
+		for _, adapter := range adapters {
+			if !slices.Contains(request.ExcludeAdapters, adapter) {
+				return []Frame{fb.BuildCompletionResponseFrame(f.StreamID, f.MsgSeq, CompletionResponse{Text: adapter, ModelUsed: "m-" + adapter, AdapterID: adapter, QualityScore: scores[adapter]})}
+			}
+		}
+		return []Frame{fb.BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeAdapterUnavailable, "no adapter left")}
+	})
+	return router, excluded
+}
+
+func TestCompleteWithMinQuality(t *testing.T) {
+	router, excluded := qualityRouter(t, []string{"a1", "a2"}, map[string]float64{"a1": 0.3, "a2": 0.9})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithMinQuality(0.8, 3))
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.AdapterID != "a2" || response.QualityScore != 0.9 {
+		t.Errorf("Expected the response of a2, got %+v", response)
+	}
+	want := []QualityAttempt{{ModelUsed: "m-a1", AdapterID: "a1", QualityScore: 0.3}, {ModelUsed: "m-a2", AdapterID: "a2", QualityScore: 0.9}}
+	if !slices.Equal(response.QualityAttempts, want) {
+		t.Errorf("Expected attempts %+v, got %+v", want, response.QualityAttempts)
+	}
+	if first, second := <-excluded, <-excluded; len(first) != 0 || !slices.Equal(second, []string{"a1"}) {
+		t.Errorf("Expected a1 excluded from the retry only, got %v then %v", first, second)
+	}
+}
+
+func TestCompleteWithMinQualityKeepsBest(t *testing.T) {
+	router, _ := qualityRouter(t, []string{"a1", "a2"}, map[string]float64{"a1": 0.5, "a2": 0.2})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	// Every adapter falls short, and the router runs out of them
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithMinQuality(0.8, 5))
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.AdapterID != "a1" {
+		t.Errorf("Expected the best response, from a1, got %+v", response)
+	}
+	if len(response.QualityAttempts) != 3 || response.QualityAttempts[2].Err == nil {
+		t.Errorf("Expected two responses and a failure, got %+v", response.QualityAttempts)
+	}
+
+	_, err = client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithMinQuality(0.8, 0))
+	if !errors.Is(err, ErrInvalidMinQuality) {
+		t.Errorf("Expected ErrInvalidMinQuality, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// queryPages sends a query frame of frameType on a stream of its own and
+// hands each answer frame of responseType to page, until one that is not
+// flagged FRAG, or is flagged LAST. An error frame fails the query. Without
+// a deadline on ctx, DefaultTimeout bounds the wait.
+func (c *ATPClient) queryPages(ctx context.Context, frameType string, payload map[string]interface{}, responseType string, page func(*Frame) error) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.DefaultTimeout)
+		defer cancel()
+	}
+
+	stream, err := c.OpenStream(ctx, StreamOptions{
+		StreamID: c.newStreamID("query"),
+		Meta:     Meta{EnvironmentID: c.tenantOf(ctx)},
+	})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	if err := stream.Send(frameType, payload); err != nil {
+		return fmt.Errorf("failed to send %s: %w", frameType, err)
+	}
+	for {
+		frame, err := stream.Recv(ctx)
+		if err != nil {
+			return err
+		}
+		switch frame.Type {
+		case FrameTypeError:
+			return parseErrorFrame(frame)
+		case responseType:
+		default:
+			continue
+		}
+		if err := page(frame); err != nil {
+			return err
+		}
+		if !slices.Contains(frame.Flags, FlagFragment) || slices.Contains(frame.Flags, FlagLast) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package atpsdk
+
+import (
+	"context"
+	"time"
+)
+
+// Frame types of the quota exchange
+const (
+	FrameTypeQuotaQuery   = "quota.query"
+	FrameTypeQuotaStatus  = "quota.status"
+	FrameTypeQuotaWarning = "quota.warning"
+)
+
+// QuotaStatus is what remains of the tenant's quota on the router, returned
+// by GetQuota
+type QuotaStatus struct {
+	TenantID           string
+	RemainingRequests  int64
+	RemainingTokens    int64
+	RemainingUSDMicros int64
+	// ResetAt is when the quota is replenished; zero when the router did not
+	// say
+	ResetAt time.Time
+}
+
+// QuotaWarning is pushed by the router, unsolicited, when the tenant's
+// quota runs low. See SDKConfig.OnQuotaWarning.
+type QuotaWarning struct {
+	QuotaStatus
+	// Resource is the quota running low, such as "requests", "tokens" or
+	// "usd"
+	Resource string
+	Message  string
+}
+
+// quotaPayload is the payload of quota.status and quota.warning frames
+type quotaPayload struct {
+	TenantID           string  `json:"tenant_id"`
+	RemainingRequests  int64   `json:"remaining_requests"`
+	RemainingTokens    int64   `json:"remaining_tokens"`
+	RemainingUSDMicros int64   `json:"remaining_usd_micros"`
+	ResetAt            float64 `json:"reset_at"` // Unix seconds
+	Resource           string  `json:"resource"`
+	Message            string  `json:"message"`
+}
+
+func (p quotaPayload) status() QuotaStatus {
+	status := QuotaStatus{
+		TenantID:           p.TenantID,
+		RemainingRequests:  p.RemainingRequests,
+		RemainingTokens:    p.RemainingTokens,
+		RemainingUSDMicros: p.RemainingUSDMicros,
+	}
+	if p.ResetAt > 0 {
+		status.ResetAt = time.Unix(0, int64(p.ResetAt*1e9))
+	}
+	return status
+}
+
+// GetQuota asks the router what remains of the tenant's quota, to slow down
+// before requests are rejected for exhausting it. Like DiscoverAdapters, it
+// needs a WebSocket connection.
+func (c *ATPClient) GetQuota(ctx context.Context) (*QuotaStatus, error) {
+	payload := map[string]interface{}{"tenant_id": c.tenantOf(ctx)}
+	var status QuotaStatus
+	err := c.queryPages(ctx, FrameTypeQuotaQuery, payload, FrameTypeQuotaStatus, func(frame *Frame) error {
+		page, err := DecodePayload[quotaPayload](*frame)
+		status = page.status()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// handleQuotaWarning hands a quota.warning frame to SDKConfig.OnQuotaWarning
+func (c *ATPClient) handleQuotaWarning(frame *Frame) {
+	payload, err := DecodePayload[quotaPayload](*frame)
+	if err != nil {
+		c.config.Logger.Printf("Warning: Ignoring malformed quota warning: %v", err)
+		return
+	}
+	warning := QuotaWarning{QuotaStatus: payload.status(), Resource: payload.Resource, Message: payload.Message}
+	c.config.Logger.Printf("Warning: Quota of tenant %s running low: %s", warning.TenantID, warning.Message)
+	if c.config.OnQuotaWarning != nil {
+		c.config.OnQuotaWarning(warning)
+	}
+}
@@ -0,0 +1,75 @@
+package atpsdk
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetQuota(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeQuotaQuery {
+			return nil
+		}
+		return []Frame{{Type: FrameTypeQuotaStatus, StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{
+			"tenant_id":            f.Payload["tenant_id"],
+			"remaining_requests":   120,
+			"remaining_tokens":     50000,
+			"remaining_usd_micros": 2500000,
+			"reset_at":             1700003600.5,
+		}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), TenantID: "t1"})
+	defer client.Close()
+
+	quota, err := client.GetQuota(context.Background())
+	if err != nil {
+		t.Fatalf("GetQuota failed: %v", err)
+	}
+	want := QuotaStatus{TenantID: "t1", RemainingRequests: 120, RemainingTokens: 50000, RemainingUSDMicros: 2500000,
+		ResetAt: time.Unix(1700003600, 5e8)}
+	if !quota.ResetAt.Equal(want.ResetAt) {
+		t.Errorf("Expected reset at %v, got %v", want.ResetAt, quota.ResetAt)
+	}
+	quota.ResetAt = want.ResetAt
+	if *quota != want {
+		t.Errorf("Expected %+v, got %+v", want, *quota)
+	}
+}
+
+func TestQuotaWarning(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame { return nil })
+	warnings := make(chan QuotaWarning, 1)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Logger: &recordingLogger{}, OnQuotaWarning: func(warning QuotaWarning) {
+		warnings <- warning
+	}})
+	defer client.Close()
+	frames, unsubscribe := client.Subscribe(FrameTypeQuotaWarning)
+	defer unsubscribe()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	router.WaitForConnections(t, 1)
+
+	if err := router.Send(Frame{Type: FrameTypeQuotaWarning, Timestamp: 1, Payload: map[string]interface{}{
+		"tenant_id": "t1", "resource": "tokens", "remaining_tokens": 900, "message": "90% of tokens used",
+	}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case warning := <-warnings:
+		if warning.Resource != "tokens" || warning.RemainingTokens != 900 || warning.Message != "90% of tokens used" || !warning.ResetAt.IsZero() {
+			t.Errorf("Unexpected warning %+v", warning)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnQuotaWarning was not called")
+	}
+	select {
+	case frame := <-frames:
+		if frame.Payload["resource"] != "tokens" {
+			t.Errorf("Unexpected subscribed frame %+v", frame)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("The warning was not delivered to subscribers")
+	}
+}
@@ -0,0 +1,76 @@
+package atpsdk
+
+import (
+	"bytes"
+
+	"github.com/gorilla/websocket"
+)
+
+// rawHookTransport is a Transport handing every message it carries to
+// SDKConfig.OnRawSend and OnRawReceive
+type rawHookTransport struct {
+	Transport
+	client *ATPClient
+}
+
+// hookTransport wraps conn, a new connection, to pass its messages to the
+// raw hooks when either is set
+func (c *ATPClient) hookTransport(conn Transport) Transport {
+	if c.config.OnRawSend == nil && c.config.OnRawReceive == nil {
+		return conn
+	}
+	return &rawHookTransport{Transport: conn, client: c}
+}
+
+func (t *rawHookTransport) Send(data []byte) error {
+	t.client.rawSend(data, false)
+	return t.Transport.Send(data)
+}
+
+func (t *rawHookTransport) SendBinary(data []byte) error {
+	t.client.rawSend(data, true)
+	return sendMessage(t.Transport, data, true)
+}
+
+func (t *rawHookTransport) Receive() ([]byte, error) {
+	data, _, err := t.ReceiveMessage()
+	return data, err
+}
+
+func (t *rawHookTransport) ReceiveMessage() ([]byte, bool, error) {
+	data, binary, err := receiveMessage(t.Transport)
+	if err == nil {
+		t.client.rawReceive(data, binary)
+	}
+	return data, binary, err
+}
+
+// rawSend hands a message about to be written to SDKConfig.OnRawSend
+func (c *ATPClient) rawSend(data []byte, binary bool) {
+	if c.config.OnRawSend != nil {
+		c.config.OnRawSend(rawMessageType(binary), c.rawData(data))
+	}
+}
+
+// rawReceive hands a message just read to SDKConfig.OnRawReceive
+func (c *ATPClient) rawReceive(data []byte, binary bool) {
+	if c.config.OnRawReceive != nil {
+		c.config.OnRawReceive(rawMessageType(binary), c.rawData(data))
+	}
+}
+
+// rawData returns data, or a copy of it when SDKConfig.CopyRawData is set
+func (c *ATPClient) rawData(data []byte) []byte {
+	if c.config.CopyRawData {
+		return bytes.Clone(data)
+	}
+	return data
+}
+
+// rawMessageType is the WebSocket message type of a text or binary message
+func rawMessageType(binary bool) int {
+	if binary {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
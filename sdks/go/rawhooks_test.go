@@ -0,0 +1,71 @@
+package atpsdk
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRawHooksSeeWireBytes(t *testing.T) {
+	router := completionRouter(t, GzipCodec{})
+	defer router.Close()
+
+	var (
+		mu       sync.Mutex
+		sent     [][]byte
+		received []int
+	)
+	recorder := &collectingRecorder{}
+	client := NewATPClient(SDKConfig{
+		WSURL:       router.URL(),
+		Codec:       GzipCodec{},
+		Recorder:    recorder,
+		CopyRawData: true,
+		OnRawSend: func(msgType int, data []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			if msgType == websocket.BinaryMessage {
+				sent = append(sent, data)
+			}
+		},
+		OnRawReceive: func(msgType int, data []byte) {
+			mu.Lock()
+			defer mu.Unlock()
+			received = append(received, msgType)
+		},
+	})
+	defer client.Close()
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "raw"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var found bool
+	for _, data := range sent {
+		var frame Frame
+		if err := (GzipCodec{}).Unmarshal(data, &frame); err == nil && frame.Type == FrameTypeCompletionRequest {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected OnRawSend to see the encoded request among %d binary messages", len(sent))
+	}
+	if len(received) == 0 || received[len(received)-1] != websocket.BinaryMessage {
+		t.Errorf("Expected OnRawReceive to see binary messages, got types %v", received)
+	}
+	if _, ok := recorder.find(AuditInbound, FrameTypeCompletionResponse); !ok {
+		t.Error("Expected the recorder to still see the response")
+	}
+}
+
+func TestRawHooksUnsetLeaveTransport(t *testing.T) {
+	client := NewATPClient(SDKConfig{})
+	conn := &recordingTransport{}
+	if client.hookTransport(conn) != Transport(conn) {
+		t.Error("Expected no wrapper without raw hooks")
+	}
+}
@@ -0,0 +1,65 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// frameEnvelope is Frame without its JSON methods
+type frameEnvelope Frame
+
+// MarshalJSON encodes the frame, with RawPayload as its payload when
+// Payload is nil
+func (f Frame) MarshalJSON() ([]byte, error) {
+	return marshalFrame(&f)
+}
+
+// marshalFrame encodes frame into a new slice
+func marshalFrame(frame *Frame) ([]byte, error) {
+	return appendFrame(nil, frame)
+}
+
+// PayloadMap returns the payload of frame, decoding RawPayload into Payload
+// first when only the raw payload is set
+func (f *Frame) PayloadMap() (map[string]interface{}, error) {
+	if f.Payload != nil || f.RawPayload == nil {
+		return f.Payload, nil
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(f.RawPayload, &payload); err != nil {
+		return nil, fmt.Errorf("failed to decode %s payload: %w", f.Type, err)
+	}
+	f.Payload = payload
+	return payload, nil
+}
+
+// DeserializeFrameRaw is DeserializeFrame keeping the payload undecoded in
+// RawPayload, for code that only reads or forwards the envelope. Payload
+// is left nil until PayloadMap decodes it, and SerializeFrame writes the
+// raw payload back byte for byte.
+func (fb *FrameBuilder) DeserializeFrameRaw(data []byte) (Frame, error) {
+	var frame Frame
+	err := json.Unmarshal(data, &struct {
+		*frameEnvelope
+		Payload *json.RawMessage `json:"payload"`
+	}{frameEnvelope: (*frameEnvelope)(&frame), Payload: &frame.RawPayload})
+	if bytes.Equal(frame.RawPayload, []byte("null")) {
+		frame.RawPayload = nil
+	}
+	normalizeVersion(&frame)
+	return frame, err
+}
+
+// materializePayload decodes the raw payload of an outgoing frame when the
+// client has to read or rewrite it before sending
+func (c *ATPClient) materializePayload(frame *Frame) error {
+	if frame.RawPayload == nil || frame.Payload != nil {
+		return nil
+	}
+	if len(c.config.SendInterceptors) == 0 && !c.hasPayloadSchema(frame.Type) && !c.encrypts(frame.Type) {
+		return nil
+	}
+	_, err := frame.PayloadMap()
+	return err
+}
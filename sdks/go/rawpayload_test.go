@@ -0,0 +1,95 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRawPayloadPassThrough(t *testing.T) {
+	fb := NewFrameBuilder("s1", "t1")
+	payload := `{"zeta": 1, "alpha": "<b>&</b>",  "nested": {"b": [1, 2], "a": null}}`
+	data := []byte(`{"type":"test.event","ts":1700000000000,"stream_id":"s-1","payload":` + payload + `}`)
+
+	frame, err := fb.DeserializeFrameRaw(data)
+	if err != nil {
+		t.Fatalf("DeserializeFrameRaw failed: %v", err)
+	}
+	if frame.Payload != nil || string(frame.RawPayload) != payload || frame.StreamID != "s-1" {
+		t.Fatalf("Expected the envelope decoded and the payload kept raw, got %+v", frame)
+	}
+
+	frame.TTL = 5
+	out, err := fb.SerializeFrame(frame)
+	if err != nil {
+		t.Fatalf("SerializeFrame failed: %v", err)
+	}
+	if !bytes.Contains(out, []byte(`"payload":`+payload+"}")) || !bytes.Contains(out, []byte(`"ttl":5`)) {
+		t.Errorf("Expected the payload byte for byte under the new envelope, got %s", out)
+	}
+	if marshaled, err := json.Marshal(frame); err != nil || !json.Valid(marshaled) || !strings.Contains(string(marshaled), `"zeta":1`) {
+		t.Errorf("Expected json.Marshal to include the raw payload, got %s (%v)", marshaled, err)
+	}
+
+	decoded, err := frame.PayloadMap()
+	if err != nil || decoded["alpha"] != "<b>&</b>" || frame.Payload == nil {
+		t.Errorf("Expected PayloadMap to decode the payload, got %v (%v)", decoded, err)
+	}
+	frame.Payload["zeta"] = 2.0
+	if out, _ := fb.SerializeFrame(frame); !bytes.Contains(out, []byte(`"zeta":2`)) {
+		t.Errorf("Expected the decoded payload to win once set, got %s", out)
+	}
+
+	empty, err := fb.DeserializeFrameRaw([]byte(`{"type":"heartbeat","ts":1,"payload":null}`))
+	if err != nil || empty.RawPayload != nil || empty.Version != LegacyProtocolVersion {
+		t.Errorf("Expected a null payload to stay unset, got %+v (%v)", empty, err)
+	}
+}
+
+func TestRawPayloadMaterializedForInterceptors(t *testing.T) {
+	client := NewATPClient(SDKConfig{SendInterceptors: []func(*Frame) error{func(f *Frame) error {
+		f.Payload["seen"] = true
+		return nil
+	}}})
+	defer client.Close()
+
+	frame := Frame{Type: "test.event", RawPayload: json.RawMessage(`{"a":1}`)}
+	if err := client.prepareOutgoing(&frame); err != nil {
+		t.Fatalf("prepareOutgoing failed: %v", err)
+	}
+	if frame.Payload["a"] != 1.0 || frame.Payload["seen"] != true {
+		t.Errorf("Expected the interceptor to see the decoded payload, got %v", frame.Payload)
+	}
+}
+
+// BenchmarkFrameForwarding reads a frame, changes its envelope and writes it
+// back, as a relay does. The raw mode does not decode the payload.
+func BenchmarkFrameForwarding(b *testing.B) {
+	fb := NewFrameBuilder("bench-session", "bench-tenant")
+	for _, size := range []int{1 << 10, 64 << 10} {
+		items := make([]string, size/16)
+		for i := range items {
+			items[i] = fmt.Sprintf(`"k%06d":"v"`, i)
+		}
+		data := []byte(`{"type":"completion_response","ts":1,"stream_id":"s","payload":{` + strings.Join(items, ",") + `}}`)
+
+		b.Run(fmt.Sprintf("decoded/%dKiB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				frame, _ := fb.DeserializeFrame(data)
+				frame.TTL = 5
+				_, _ = fb.SerializeFrame(frame)
+			}
+		})
+		b.Run(fmt.Sprintf("raw/%dKiB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				frame, _ := fb.DeserializeFrameRaw(data)
+				frame.TTL = 5
+				_, _ = fb.SerializeFrame(frame)
+			}
+		})
+	}
+}
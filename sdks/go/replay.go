@@ -0,0 +1,252 @@
+package atpsdk
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FlagReplay marks a frame sent again after reconnecting, so the router can
+// drop it if it had already processed the original
+const FlagReplay = "replay"
+
+// FrameTypeSessionResume asks the router to resume the session after
+// reconnecting, before unanswered requests are replayed. The router answers
+// with an ack, or a nack or error frame to reject the resume.
+const FrameTypeSessionResume = "session_resume"
+
+// ReplayConfig configures replaying requests that were sent but not
+// answered when the connection was lost. Once the client has reconnected
+// and the router has accepted a session_resume, the requests are sent again
+// in order, flagged with FlagReplay.
+type ReplayConfig struct {
+	// MaxFrames caps the unanswered requests kept for replay. Zero, the
+	// default, disables replay.
+	MaxFrames int
+	// MaxAge is how long after it was first sent a request may still be
+	// replayed (default: DefaultTimeout)
+	MaxAge time.Duration
+}
+
+// Reasons a request could not be replayed, reported in ReplayError.Reason
+const (
+	ReplayReasonOverflow      = "replay buffer full"
+	ReplayReasonExpired       = "expired"
+	ReplayReasonResumeFailed  = "session resume rejected"
+	ReplayReasonResendFailure = "resend failed"
+)
+
+// ReplayError fails a request that was lost with the connection and could
+// not be replayed. It matches ErrReplayFailed with errors.Is.
+type ReplayError struct {
+	StreamID string
+	MsgSeq   int
+	Reason   string
+	Err      error // the underlying failure, if any
+}
+
+func (e *ReplayError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v: %s: %v", ErrReplayFailed, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("%v: %s", ErrReplayFailed, e.Reason)
+}
+
+// Is reports whether target is ErrReplayFailed
+func (e *ReplayError) Is(target error) bool {
+	return target == ErrReplayFailed
+}
+
+// Unwrap returns the underlying failure
+func (e *ReplayError) Unwrap() error {
+	return e.Err
+}
+
+// replayEntry is an unanswered request kept for replay
+type replayEntry struct {
+	key    string
+	frame  Frame
+	sentAt time.Time
+	conn   uint64 // connection generation it was last sent on
+}
+
+// replayBuffer holds the unanswered requests sent over the primary
+// connection, oldest first
+type replayBuffer struct {
+	mu      sync.Mutex
+	entries list.List                // of *replayEntry
+	byKey   map[string]*list.Element // by response key
+}
+
+// replayEnabled reports whether unanswered requests are replayed
+func (c *ATPClient) replayEnabled() bool {
+	return c.config.Replay.MaxFrames > 0
+}
+
+// trackReplay keeps a request about to be sent over the primary connection
+// for replay until its waiter is released. A full buffer drops its oldest
+// request, which fails if the connection is lost before it is answered.
+func (c *ATPClient) trackReplay(frame Frame) {
+	if !c.replayEnabled() || c.poolMemberFor(frame.StreamID) != nil {
+		return
+	}
+	key := responseKey(frame.StreamID, frame.MsgSeq)
+	entry := &replayEntry{key: key, frame: frame, sentAt: c.config.Clock.Now(), conn: c.counters.connects.Load()}
+
+	b := &c.replay
+	b.mu.Lock()
+	if b.byKey == nil {
+		b.byKey = make(map[string]*list.Element)
+	}
+	b.byKey[key] = b.entries.PushBack(entry)
+	var evicted []string
+	for b.entries.Len() > c.config.Replay.MaxFrames {
+		oldest := b.entries.Remove(b.entries.Front()).(*replayEntry)
+		delete(b.byKey, oldest.key)
+		evicted = append(evicted, oldest.key)
+	}
+	b.mu.Unlock()
+
+	if len(evicted) == 0 {
+		return
+	}
+	c.handlerMutex.Lock()
+	for _, key := range evicted {
+		if pending, ok := c.responseHandlers[key]; ok {
+			pending.unreplayable = true
+		}
+	}
+	c.handlerMutex.Unlock()
+}
+
+// forgetReplay drops the request of a released waiter from the buffer
+func (c *ATPClient) forgetReplay(key string) {
+	if !c.replayEnabled() {
+		return
+	}
+	b := &c.replay
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if e, ok := b.byKey[key]; ok {
+		b.entries.Remove(e)
+		delete(b.byKey, key)
+	}
+}
+
+// failUnreplayable fails the requests dropped from the full buffer once the
+// connection they were sent on is lost
+func (c *ATPClient) failUnreplayable() {
+	if !c.replayEnabled() {
+		return
+	}
+	c.handlerMutex.RLock()
+	var lost []*pendingResponse
+	for _, pending := range c.responseHandlers {
+		if pending.unreplayable {
+			lost = append(lost, pending)
+		}
+	}
+	c.handlerMutex.RUnlock()
+
+	for _, pending := range lost {
+		c.failPending(pending, &ReplayError{StreamID: pending.streamID, MsgSeq: pending.msgSeq, Reason: ReplayReasonOverflow})
+	}
+}
+
+// replayUnanswered resumes the session on a new connection and sends the
+// requests left unanswered by the previous one again, in order. Requests
+// that cannot be replayed fail with a *ReplayError.
+func (c *ATPClient) replayUnanswered() {
+	if !c.replayEnabled() || c.usingHTTP() {
+		return
+	}
+	current := c.counters.connects.Load()
+	b := &c.replay
+	b.mu.Lock()
+	var entries []*replayEntry
+	for e := b.entries.Front(); e != nil; e = e.Next() {
+		if entry := e.Value.(*replayEntry); entry.conn < current {
+			entries = append(entries, entry)
+		}
+	}
+	b.mu.Unlock()
+	if len(entries) == 0 {
+		return
+	}
+
+	if err := c.resumeSession(); err != nil {
+		c.config.Logger.Printf("Warning: Session resume failed, failing %d unanswered request(s): %v", len(entries), err)
+		for _, entry := range entries {
+			c.failReplay(entry, ReplayReasonResumeFailed, err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if c.config.Clock.Now().Sub(entry.sentAt) > c.config.Replay.MaxAge {
+			c.failReplay(entry, ReplayReasonExpired, nil)
+			continue
+		}
+		frame := entry.frame
+		frame.Flags = append(slices.Clone(frame.Flags), FlagReplay)
+		b.mu.Lock()
+		entry.conn = current
+		b.mu.Unlock()
+		if err := c.sendPrimaryFrame(frame); err != nil {
+			c.failReplay(entry, ReplayReasonResendFailure, err)
+		}
+	}
+}
+
+// resumeSession asks the router to resume the session on the new
+// connection and waits for its acknowledgment
+func (c *ATPClient) resumeSession() error {
+	frame := c.builder.BuildSessionResumeFrame(c.newStreamID("resume"), c.config.SessionID)
+	ctx, cancel := context.WithTimeout(c.ctx, c.config.DefaultTimeout)
+	defer cancel()
+
+	pending := c.expectResponse(frame)
+	if err := c.sendPrimaryFrame(frame); err != nil {
+		c.discardPending(pending, false)
+		return fmt.Errorf("failed to send session_resume frame: %w", err)
+	}
+	return c.waitForAck(ctx, pending)
+}
+
+// failReplay fails the waiter of a request that cannot be replayed
+func (c *ATPClient) failReplay(entry *replayEntry, reason string, err error) {
+	c.forgetReplay(entry.key)
+	c.handlerMutex.RLock()
+	pending := c.responseHandlers[entry.key]
+	c.handlerMutex.RUnlock()
+	if pending != nil {
+		c.failPending(pending, &ReplayError{StreamID: entry.frame.StreamID, MsgSeq: entry.frame.MsgSeq, Reason: reason, Err: err})
+	}
+}
+
+// failPending hands err to a waiter in place of its response
+func (c *ATPClient) failPending(pending *pendingResponse, err error) {
+	select {
+	case pending.ch <- pendingResult{err: err}:
+	default:
+		// The waiter already has its response
+	}
+}
+
+// BuildSessionResumeFrame builds a frame asking the router to resume
+// sessionID on a new connection
+func (fb *FrameBuilder) BuildSessionResumeFrame(streamID, sessionID string, meta ...Meta) Frame {
+	return Frame{
+		Type:      FrameTypeSessionResume,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		Meta:      withMeta(Meta{}, meta),
+		Payload: map[string]interface{}{
+			"session_id": sessionID,
+		},
+	}
+}
@@ -0,0 +1,154 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// replayRouter leaves completion requests unanswered, handing them to the
+// test, until they are replayed, when it answers them and hands them to the
+// test again. Session resumes are answered with the frame returned by
+// resume.
+func replayRouter(t *testing.T, resume func(Frame) Frame) (*testRouter, <-chan Frame, <-chan Frame) {
+	lost := make(chan Frame, 8)
+	replayed := make(chan Frame, 8)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		switch f.Type {
+		case FrameTypeSessionResume:
+			return []Frame{resume(f)}
+		case "completion_request":
+			if !slices.Contains(f.Flags, FlagReplay) {
+				lost <- f
+				return nil
+			}
+			replayed <- f
+			return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}}
+		}
+		return nil
+	})
+	return router, lost, replayed
+}
+
+func replayClient(router *testRouter, maxFrames int) *ATPClient {
+	return NewATPClient(SDKConfig{
+		WSURL:         router.URL(),
+		AutoReconnect: true,
+		RetryDelay:    time.Millisecond,
+		Replay:        ReplayConfig{MaxFrames: maxFrames},
+	})
+}
+
+func completeAsync(client *ATPClient, prompt string) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.Complete(context.Background(), CompletionRequest{Prompt: prompt})
+		result <- err
+	}()
+	return result
+}
+
+func receiveFrame(t *testing.T, frames <-chan Frame) Frame {
+	t.Helper()
+	select {
+	case f := <-frames:
+		return f
+	case <-time.After(2 * time.Second):
+		t.Fatal("No frame received")
+		return Frame{}
+	}
+}
+
+func TestReplayAfterReconnect(t *testing.T) {
+	router, lost, replayed := replayRouter(t, func(f Frame) Frame {
+		if f.Payload["session_id"] == "" {
+			t.Errorf("Expected a session ID in %+v", f)
+		}
+		return NewFrameBuilder("", "").BuildAckFrame(f.StreamID, f.MsgSeq)
+	})
+	client := replayClient(router, 8)
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	first := completeAsync(client, "first")
+	original := receiveFrame(t, lost)
+	second := completeAsync(client, "second")
+	receiveFrame(t, lost)
+	router.DropConnections()
+
+	// Both requests are sent again in order, flagged, and answered
+	for _, want := range []string{"first", "second"} {
+		f := receiveFrame(t, replayed)
+		if f.Payload["prompt"] != want {
+			t.Errorf("Expected the %s request replayed, got %+v", want, f)
+		}
+		if want == "first" && (f.StreamID != original.StreamID || f.MsgSeq != original.MsgSeq) {
+			t.Errorf("Replay changed the request's identity: %+v, was %+v", f, original)
+		}
+	}
+	for _, result := range []<-chan error{first, second} {
+		if err := <-result; err != nil {
+			t.Errorf("Complete failed: %v", err)
+		}
+	}
+	if n := client.replay.entries.Len(); n != 0 {
+		t.Errorf("Expected answered requests to leave the buffer, %d left", n)
+	}
+}
+
+func TestReplayResumeRejected(t *testing.T) {
+	router, lost, _ := replayRouter(t, func(f Frame) Frame {
+		return NewFrameBuilder("", "").BuildNackFrame(f.StreamID, f.MsgSeq, "SESSION_UNKNOWN", "no such session")
+	})
+	client := replayClient(router, 8)
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	result := completeAsync(client, "hi")
+	receiveFrame(t, lost)
+	router.DropConnections()
+
+	var replayErr *ReplayError
+	err := <-result
+	if !errors.Is(err, ErrReplayFailed) || !errors.As(err, &replayErr) || replayErr.Reason != ReplayReasonResumeFailed {
+		t.Fatalf("Expected a rejected resume to fail the request, got %v", err)
+	}
+	if !errors.Is(err, ErrNacked) {
+		t.Errorf("Expected the nack as the cause, got %v", err)
+	}
+}
+
+func TestReplayBufferOverflow(t *testing.T) {
+	router, lost, replayed := replayRouter(t, func(f Frame) Frame {
+		return NewFrameBuilder("", "").BuildAckFrame(f.StreamID, f.MsgSeq)
+	})
+	client := replayClient(router, 1)
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	// The second request pushes the first out of the one-frame buffer
+	first := completeAsync(client, "first")
+	receiveFrame(t, lost)
+	second := completeAsync(client, "second")
+	receiveFrame(t, lost)
+	router.DropConnections()
+
+	var replayErr *ReplayError
+	if err := <-first; !errors.As(err, &replayErr) || replayErr.Reason != ReplayReasonOverflow {
+		t.Errorf("Expected the evicted request to fail, got %v", err)
+	}
+	if f := receiveFrame(t, replayed); f.Payload["prompt"] != "second" {
+		t.Errorf("Expected only the second request replayed, got %+v", f)
+	}
+	if err := <-second; err != nil {
+		t.Errorf("Complete failed: %v", err)
+	}
+}
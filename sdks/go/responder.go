@@ -0,0 +1,237 @@
+package atpsdk
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StreamingCompletionHandler serves a completion request by streaming
+// generated text through a Responder. Returning without calling Finish
+// finishes the response; returning an error sends an error frame.
+type StreamingCompletionHandler func(ctx context.Context, req CompletionRequest, meta Meta, r *Responder) error
+
+// ChunkingConfig controls how a Responder coalesces generated text into
+// response frames. Buffered text is flushed when it reaches MaxBytes or
+// MaxTokens, or MaxLatency after the first buffered byte, whichever comes
+// first. Under back pressure the thresholds grow by up to MaxScale times.
+type ChunkingConfig struct {
+	MaxBytes   int           // default: 512
+	MaxTokens  int           // default: 16
+	MaxLatency time.Duration // default: 25ms
+	MaxScale   int           // default: 8
+	// SlowDrain is the flush duration above which the consumer is treated
+	// as slow (default: MaxLatency).
+	SlowDrain time.Duration
+	// EgressPressure is the number of frames waiting in outbound queues
+	// above which the connection is treated as congested (default: 4).
+	EgressPressure int
+}
+
+// withDefaults fills in unset fields
+func (c ChunkingConfig) withDefaults() ChunkingConfig {
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = 512
+	}
+	if c.MaxTokens <= 0 {
+		c.MaxTokens = 16
+	}
+	if c.MaxLatency <= 0 {
+		c.MaxLatency = 25 * time.Millisecond
+	}
+	if c.MaxScale <= 0 {
+		c.MaxScale = 8
+	}
+	if c.SlowDrain <= 0 {
+		c.SlowDrain = c.MaxLatency
+	}
+	if c.EgressPressure <= 0 {
+		c.EgressPressure = 4
+	}
+	return c
+}
+
+// ResponderStats describes the chunks a Responder has sent
+type ResponderStats struct {
+	Chunks        uint64  `json:"chunks"`
+	Bytes         uint64  `json:"bytes"`
+	AvgChunkBytes float64 `json:"avg_chunk_bytes"`
+	// Scale is the current threshold multiplier (1 when unpressured)
+	Scale int `json:"scale"`
+}
+
+// Responder streams a completion response back to the router as FRAG
+// frames on the request's stream, coalescing generated text into chunks.
+// The final chunk carries the LAST flag and the response metadata. A
+// Responder is owned by the handler goroutine and must not be shared.
+type Responder struct {
+	guard    ownerGuard
+	clock    Clock
+	config   ChunkingConfig
+	builder  *FrameBuilder
+	send     func(Frame) error
+	pressure func() int64
+	streamID string
+	msgSeq   int
+	trace    *TraceContext // of the request, echoed in every chunk
+	done     chan struct{}
+
+	mu       sync.Mutex
+	buf      strings.Builder
+	tokens   int
+	fragSeq  int
+	scale    int
+	gen      uint64
+	finished bool
+	sendErr  error
+	chunks   uint64
+	bytes    uint64
+}
+
+// newResponder creates a Responder answering the request frame
+func newResponder(request Frame, builder *FrameBuilder, clock Clock, config ChunkingConfig, debugChecks bool,
+	send func(Frame) error, pressure func() int64) *Responder {
+	return &Responder{
+		guard:    newOwnerGuard(debugChecks, "Responder"),
+		clock:    clock,
+		config:   config.withDefaults(),
+		builder:  builder,
+		send:     send,
+		pressure: pressure,
+		streamID: request.StreamID,
+		msgSeq:   request.MsgSeq,
+		trace:    request.Meta.Trace,
+		done:     make(chan struct{}),
+		scale:    1,
+	}
+}
+
+// Write buffers one generated token (or any text delta), flushing a chunk
+// when a size threshold is reached. It returns the error of a failed
+// earlier flush, or ErrResponderClosed after Finish.
+func (r *Responder) Write(token string) error {
+	r.guard.check("Write")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.finished {
+		return ErrResponderClosed
+	}
+	if r.sendErr != nil {
+		return r.sendErr
+	}
+
+	if r.buf.Len() == 0 {
+		r.armLatencyFlush()
+	}
+	r.buf.WriteString(token)
+	r.tokens++
+
+	if r.buf.Len() >= r.config.MaxBytes*r.scale || r.tokens >= r.config.MaxTokens*r.scale {
+		r.flushLocked()
+	}
+	return r.sendErr
+}
+
+// Finish immediately sends any buffered text together with the final
+// response metadata. final.Text is ignored; the streamed text is the
+// response text.
+func (r *Responder) Finish(final CompletionResponse) error {
+	r.guard.check("Finish")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.finished {
+		return ErrResponderClosed
+	}
+	r.finished = true
+	close(r.done)
+	if r.sendErr != nil {
+		return r.sendErr
+	}
+
+	final.Text = r.buf.String()
+	final.Finished = true
+	frame := r.builder.BuildCompletionResponseFrame(r.streamID, r.msgSeq, final, Meta{Trace: r.trace})
+	frame.FragSeq = r.fragSeq
+	frame.Flags = []string{FlagFragment, FlagLast}
+	r.sendLocked(frame, len(final.Text))
+	return r.sendErr
+}
+
+// Stats returns the chunking statistics of this response stream
+func (r *Responder) Stats() ResponderStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := ResponderStats{Chunks: r.chunks, Bytes: r.bytes, Scale: r.scale}
+	if r.chunks > 0 {
+		stats.AvgChunkBytes = float64(r.bytes) / float64(r.chunks)
+	}
+	return stats
+}
+
+// isFinished reports whether Finish has been called
+func (r *Responder) isFinished() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.finished
+}
+
+// armLatencyFlush schedules a flush MaxLatency (scaled) after the first
+// byte is buffered. Flushing for any other reason invalidates it.
+func (r *Responder) armLatencyFlush() {
+	gen := r.gen
+	timer := r.clock.After(r.config.MaxLatency * time.Duration(r.scale))
+	go func() {
+		select {
+		case <-timer:
+		case <-r.done:
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.gen == gen && !r.finished && r.buf.Len() > 0 {
+			r.flushLocked()
+		}
+	}()
+}
+
+// flushLocked sends the buffered text as a FRAG chunk
+func (r *Responder) flushLocked() {
+	text := r.buf.String()
+	frame := r.builder.BuildCompletionChunkFrame(r.streamID, r.msgSeq, r.fragSeq, text, Meta{Trace: r.trace})
+	r.sendLocked(frame, len(text))
+}
+
+// sendLocked sends a chunk, resets the buffer and adapts the thresholds to
+// how long the send took and how congested the connection is
+func (r *Responder) sendLocked(frame Frame, size int) {
+	start := r.clock.Now()
+	err := r.send(frame)
+	drain := r.clock.Now().Sub(start)
+
+	r.buf.Reset()
+	r.tokens = 0
+	r.gen++
+	r.fragSeq++
+	if err != nil {
+		r.sendErr = err
+		return
+	}
+	r.chunks++
+	r.bytes += uint64(size)
+
+	congested := r.pressure != nil && r.pressure() >= int64(r.config.EgressPressure)
+	switch {
+	case drain > r.config.SlowDrain || congested:
+		if r.scale < r.config.MaxScale {
+			r.scale = min(r.scale*2, r.config.MaxScale)
+		}
+	case r.scale > 1:
+		r.scale /= 2
+	}
+}
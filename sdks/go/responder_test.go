@@ -0,0 +1,224 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sentChunk struct {
+	at    time.Time
+	frame Frame
+}
+
+// chunkRecorder is a Responder send function recording chunks, optionally
+// simulating a slow consumer by advancing the fake clock on every send
+type chunkRecorder struct {
+	clock *fakeClock
+	drain time.Duration
+
+	mu     sync.Mutex
+	chunks []sentChunk
+	sent   chan struct{}
+}
+
+func newChunkRecorder(clock *fakeClock, drain time.Duration) *chunkRecorder {
+	return &chunkRecorder{clock: clock, drain: drain, sent: make(chan struct{}, 1000)}
+}
+
+func (r *chunkRecorder) send(f Frame) error {
+	at := r.clock.Now()
+	if r.drain > 0 {
+		r.clock.Advance(r.drain)
+	}
+	r.mu.Lock()
+	r.chunks = append(r.chunks, sentChunk{at: at, frame: f})
+	r.mu.Unlock()
+	r.sent <- struct{}{}
+	return nil
+}
+
+func (r *chunkRecorder) Chunks() []sentChunk {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]sentChunk(nil), r.chunks...)
+}
+
+func testResponder(clock *fakeClock, send func(Frame) error, debug bool) *Responder {
+	request := Frame{StreamID: "stream-1", MsgSeq: 3}
+	return newResponder(request, NewFrameBuilder("s", "t"), clock, ChunkingConfig{}, debug, send, nil)
+}
+
+func TestResponderSlowDrainGrowsChunks(t *testing.T) {
+	run := func(drain time.Duration) ResponderStats {
+		clock := newFakeClock()
+		rec := newChunkRecorder(clock, drain)
+		r := testResponder(clock, rec.send, false)
+		for i := 0; i < 400; i++ {
+			if err := r.Write("tok "); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+		}
+		if err := r.Finish(CompletionResponse{}); err != nil {
+			t.Fatalf("Finish failed: %v", err)
+		}
+		return r.Stats()
+	}
+
+	fast := run(0)
+	slow := run(100 * time.Millisecond)
+
+	// 16 tokens (64 bytes) per chunk, plus an empty final chunk
+	if fast.AvgChunkBytes < 60 || fast.AvgChunkBytes > 64 {
+		t.Errorf("Expected fast drain to flush about every 16 tokens, got avg %v", fast.AvgChunkBytes)
+	}
+	if slow.AvgChunkBytes < 4*fast.AvgChunkBytes {
+		t.Errorf("Expected slow drain to produce much larger chunks: fast avg %v, slow avg %v", fast.AvgChunkBytes, slow.AvgChunkBytes)
+	}
+	if slow.Scale != 8 {
+		t.Errorf("Expected slow drain to reach the maximum scale, got %d", slow.Scale)
+	}
+	if fast.Bytes != 1600 || slow.Bytes != 1600 {
+		t.Errorf("Expected all 1600 bytes to be sent, got fast %d slow %d", fast.Bytes, slow.Bytes)
+	}
+}
+
+func TestResponderInteractiveFlushLatency(t *testing.T) {
+	clock := newFakeClock()
+	rec := newChunkRecorder(clock, 0)
+	r := testResponder(clock, rec.send, false)
+
+	// One token every 10ms: far below the size thresholds, so every flush
+	// is driven by the latency timer
+	var bufferStart time.Time
+	for i := 0; i < 50; i++ {
+		if bufferStart.IsZero() {
+			bufferStart = clock.Now()
+		}
+		if err := r.Write("x"); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		clock.Advance(10 * time.Millisecond)
+
+		if clock.Now().Sub(bufferStart) >= 25*time.Millisecond {
+			select {
+			case <-rec.sent:
+			case <-time.After(time.Second):
+				t.Fatal("Latency flush did not happen")
+			}
+			bufferStart = time.Time{}
+		}
+	}
+	if err := r.Finish(CompletionResponse{}); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	chunks := rec.Chunks()
+	if len(chunks) < 10 {
+		t.Fatalf("Expected many small chunks, got %d", len(chunks))
+	}
+	start := time.Unix(1700000000, 0)
+	for _, c := range chunks {
+		tokens := len(c.frame.Payload["text"].(string))
+		// The first token in this chunk was written tokens*10ms before the
+		// last one, which was written just before the flush
+		firstWrite := c.at.Add(-time.Duration(tokens) * 10 * time.Millisecond)
+		if firstWrite.Before(start) {
+			firstWrite = start
+		}
+		if latency := c.at.Sub(firstWrite); latency >= 50*time.Millisecond {
+			t.Errorf("Chunk flushed %v after its first byte", latency)
+		}
+	}
+}
+
+func TestResponderFinish(t *testing.T) {
+	clock := newFakeClock()
+	rec := newChunkRecorder(clock, 0)
+	r := testResponder(clock, rec.send, false)
+
+	_ = r.Write("hello ")
+	if len(rec.Chunks()) != 0 {
+		t.Fatal("A single small token should stay buffered")
+	}
+	if err := r.Finish(CompletionResponse{ModelUsed: "m", TokensOut: 2, Text: "ignored"}); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+
+	chunks := rec.Chunks()
+	if len(chunks) != 1 {
+		t.Fatalf("Expected the final chunk to flush immediately, got %d chunks", len(chunks))
+	}
+	final := chunks[0].frame
+	if final.Payload["text"] != "hello " || final.Payload["model_used"] != "m" || final.Payload["finished"] != true {
+		t.Errorf("Unexpected final payload: %v", final.Payload)
+	}
+	if strings.Join(final.Flags, ",") != "FRAG,LAST" || final.StreamID != "stream-1" || final.MsgSeq != 3 {
+		t.Errorf("Unexpected final envelope: %+v", final)
+	}
+
+	if err := r.Write("more"); !errors.Is(err, ErrResponderClosed) {
+		t.Errorf("Expected ErrResponderClosed after Finish, got %v", err)
+	}
+	if err := r.Finish(CompletionResponse{}); !errors.Is(err, ErrResponderClosed) {
+		t.Errorf("Expected ErrResponderClosed for a second Finish, got %v", err)
+	}
+}
+
+func TestResponderDebugOwnership(t *testing.T) {
+	clock := newFakeClock()
+	r := testResponder(clock, newChunkRecorder(clock, 0).send, true)
+	_ = r.Write("a")
+
+	done := make(chan interface{})
+	go func() {
+		defer func() { done <- recover() }()
+		_ = r.Write("b")
+	}()
+	if msg, _ := (<-done).(string); !strings.Contains(msg, "Responder.Write") {
+		t.Errorf("Expected a Responder ownership panic, got %q", msg)
+	}
+}
+
+func TestHandleStreamingCompletions(t *testing.T) {
+	router, replies := adapterTestRouter(t)
+	client := connectedAdapterClient(t, router)
+
+	server, err := client.HandleStreamingCompletions(func(ctx context.Context, req CompletionRequest, meta Meta, r *Responder) error {
+		for _, word := range strings.Fields(req.Prompt) {
+			if err := r.Write(word + " "); err != nil {
+				return err
+			}
+		}
+		return r.Finish(CompletionResponse{ModelUsed: "streamer"})
+	}, AdapterServerConfig{Chunking: ChunkingConfig{MaxTokens: 2}})
+	if err != nil {
+		t.Fatalf("HandleStreamingCompletions failed: %v", err)
+	}
+	defer server.Close()
+
+	if err := router.Send(completionRequestFrame("stream-9", 1, "one two three four five")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var text strings.Builder
+	for fragSeq := 0; ; fragSeq++ {
+		reply := waitReply(t, replies)
+		if reply.StreamID != "stream-9" || reply.FragSeq != fragSeq {
+			t.Fatalf("Expected stream-9 frag %d, got %s frag %d", fragSeq, reply.StreamID, reply.FragSeq)
+		}
+		text.WriteString(reply.Payload["text"].(string))
+		if len(reply.Flags) == 2 && reply.Flags[1] == FlagLast {
+			if reply.Payload["model_used"] != "streamer" {
+				t.Errorf("Expected final metadata, got %v", reply.Payload)
+			}
+			break
+		}
+	}
+	if text.String() != "one two three four five " {
+		t.Errorf("Unexpected reassembled text %q", text.String())
+	}
+}
@@ -0,0 +1,46 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// retryableCodes are the router error codes of transient failures, after
+// which Complete retries the same request
+var retryableCodes = map[string]bool{
+	ErrorCodeRateLimited:       true,
+	ErrorCodeAdapterOverloaded: true,
+}
+
+// isRetryable reports whether err is a transient router failure
+func isRetryable(err error) bool {
+	var atpErr *ATPError
+	return errors.As(err, &atpErr) && retryableCodes[atpErr.Code]
+}
+
+// retryDelay is how long to wait before retrying after err: RetryDelay, or
+// longer if the router asked for it with retry_after_ms
+func (c *ATPClient) retryDelay(err error) time.Duration {
+	delay := c.config.RetryDelay
+	var atpErr *ATPError
+	if errors.As(err, &atpErr) {
+		delay = max(delay, atpErr.RetryAfter)
+	}
+	return delay
+}
+
+// awaitRetry waits out the retry delay after err. It reports false, without
+// waiting, when ctx would expire first.
+func (c *ATPClient) awaitRetry(ctx context.Context, err error) bool {
+	delay := c.retryDelay(err)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+	select {
+	case <-c.config.Clock.After(delay):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,121 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseErrorFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+		want    ATPError
+	}{
+		{
+			name: "structured",
+			payload: map[string]interface{}{"error": map[string]interface{}{
+				"code": ErrorCodeRateLimited, "message": "slow down", "details": map[string]interface{}{"limit": 10.0}, "retry_after_ms": 1500.0,
+			}},
+			want: ATPError{Code: ErrorCodeRateLimited, Message: "slow down", Details: map[string]interface{}{"limit": 10.0}, RetryAfter: 1500 * time.Millisecond},
+		},
+		{
+			name:    "flat",
+			payload: map[string]interface{}{"code": ErrorCodeInvalidRequest, "message": "bad prompt"},
+			want:    ATPError{Code: ErrorCodeInvalidRequest, Message: "bad prompt"},
+		},
+		{
+			name:    "string",
+			payload: map[string]interface{}{"error": "adapter crashed"},
+			want:    ATPError{Message: "adapter crashed"},
+		},
+		{
+			name:    "unknown shape",
+			payload: map[string]interface{}{"reason": []interface{}{"a", "b"}},
+			want:    ATPError{Message: `{"reason":["a","b"]}`},
+		},
+		{
+			name: "empty",
+			want: ATPError{Message: "unknown error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var atpErr *ATPError
+			if !errors.As(parseErrorFrame(&Frame{Type: "error", Payload: tt.payload}), &atpErr) {
+				t.Fatal("Expected an *ATPError")
+			}
+			if atpErr.Code != tt.want.Code || atpErr.Message != tt.want.Message || atpErr.RetryAfter != tt.want.RetryAfter {
+				t.Errorf("Got %+v, want %+v", *atpErr, tt.want)
+			}
+			if (atpErr.Details == nil) != (tt.want.Details == nil) {
+				t.Errorf("Got details %v, want %v", atpErr.Details, tt.want.Details)
+			}
+		})
+	}
+}
+
+// rateLimitRouter rejects the first completion request with RATE_LIMITED
+// and retryAfter, answers later ones, and records when each arrived
+func rateLimitRouter(t *testing.T, retryAfter time.Duration) (*testRouter, func() []time.Time) {
+	var mu sync.Mutex
+	var arrivals []time.Time
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		arrivals = append(arrivals, time.Now())
+		if len(arrivals) == 1 {
+			reply := NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeRateLimited, "slow down")
+			reply.Payload["error"].(map[string]interface{})["retry_after_ms"] = retryAfter.Milliseconds()
+			return []Frame{reply}
+		}
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}}
+	})
+	return router, func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), arrivals...)
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	router, arrivals := rateLimitRouter(t, 100*time.Millisecond)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), RetryDelay: time.Millisecond})
+	defer client.Close()
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	got := arrivals()
+	if len(got) != 2 {
+		t.Fatalf("Expected one retry, got %d requests", len(got))
+	}
+	if gap := got[1].Sub(got[0]); gap < 100*time.Millisecond {
+		t.Errorf("Expected the retry to wait retry_after_ms, waited %v", gap)
+	}
+}
+
+func TestRetryAfterPastDeadline(t *testing.T) {
+	router, arrivals := rateLimitRouter(t, time.Minute)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), RetryDelay: time.Millisecond})
+	defer client.Close()
+
+	// A retry the deadline does not leave room for fails right away
+	start := time.Now()
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithTimeout(5*time.Second))
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeRateLimited || atpErr.RetryAfter != time.Minute {
+		t.Fatalf("Expected the RATE_LIMITED error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected no wait, took %v", elapsed)
+	}
+	if n := len(arrivals()); n != 1 {
+		t.Errorf("Expected no retry, got %d requests", n)
+	}
+}
@@ -0,0 +1,385 @@
+package atpsdk
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//go:embed schemas/*.json
+var defaultSchemaFiles embed.FS
+
+// DefaultPayloadSchemas returns the built-in payload schemas, keyed by frame
+// type. They match the frames FrameBuilder produces for completion_request,
+// adapter.capability and adapter.health. The map is a copy and may be
+// extended before it is used as SDKConfig.PayloadSchemas.
+func DefaultPayloadSchemas() map[string]string {
+	entries, err := defaultSchemaFiles.ReadDir("schemas")
+	if err != nil {
+		panic(fmt.Sprintf("atpsdk: cannot read embedded schemas: %v", err))
+	}
+	schemas := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := defaultSchemaFiles.ReadFile(path.Join("schemas", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("atpsdk: cannot read embedded schema %s: %v", entry.Name(), err))
+		}
+		schemas[strings.TrimSuffix(entry.Name(), ".json")] = string(data)
+	}
+	return schemas
+}
+
+// SchemaFailure is one payload value that does not satisfy its schema
+type SchemaFailure struct {
+	// Path locates the value, e.g. "payload.models[1]"
+	Path    string
+	Message string
+}
+
+func (f SchemaFailure) String() string {
+	return f.Path + ": " + f.Message
+}
+
+// SchemaValidationError is returned when a frame payload does not satisfy
+// the schema registered for its type in SDKConfig.PayloadSchemas. It
+// matches ErrSchemaValidation with errors.Is.
+type SchemaValidationError struct {
+	FrameType string
+	Direction AuditDirection
+	// Failures lists every failed path, ordered by path
+	Failures []SchemaFailure
+}
+
+func (e *SchemaValidationError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		failures[i] = failure.String()
+	}
+	return fmt.Sprintf("%v: %s %s frame: %s", ErrSchemaValidation, e.Direction, e.FrameType, strings.Join(failures, "; "))
+}
+
+// Is reports whether target is ErrSchemaValidation
+func (e *SchemaValidationError) Is(target error) bool {
+	return target == ErrSchemaValidation
+}
+
+// payloadSchema is a compiled JSON Schema. The supported keywords are type,
+// enum, const, properties, required, additionalProperties, items, minimum,
+// maximum, exclusiveMinimum, exclusiveMaximum, minLength, maxLength,
+// minItems, maxItems and pattern; other keywords are ignored.
+type payloadSchema struct {
+	Type                 schemaTypes               `json:"type"`
+	Enum                 []interface{}             `json:"enum"`
+	Const                *interface{}              `json:"const"`
+	Properties           map[string]*payloadSchema `json:"properties"`
+	Required             []string                  `json:"required"`
+	AdditionalProperties *additionalProperties     `json:"additionalProperties"`
+	Items                *payloadSchema            `json:"items"`
+	Minimum              *float64                  `json:"minimum"`
+	Maximum              *float64                  `json:"maximum"`
+	ExclusiveMinimum     *float64                  `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64                  `json:"exclusiveMaximum"`
+	MinLength            *int                      `json:"minLength"`
+	MaxLength            *int                      `json:"maxLength"`
+	MinItems             *int                      `json:"minItems"`
+	MaxItems             *int                      `json:"maxItems"`
+	Pattern              string                    `json:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+// schemaTypes is the type keyword, which is a name or a list of names
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = schemaTypes{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = names
+	return nil
+}
+
+// additionalProperties is the additionalProperties keyword, which is a
+// boolean or a schema
+type additionalProperties struct {
+	forbidden bool
+	schema    *payloadSchema
+}
+
+func (a *additionalProperties) UnmarshalJSON(data []byte) error {
+	var allowed bool
+	if err := json.Unmarshal(data, &allowed); err == nil {
+		a.forbidden = !allowed
+		return nil
+	}
+	return json.Unmarshal(data, &a.schema)
+}
+
+// compilePayloadSchema parses a JSON Schema document
+func compilePayloadSchema(document string) (*payloadSchema, error) {
+	var schema payloadSchema
+	if err := json.Unmarshal([]byte(document), &schema); err != nil {
+		return nil, err
+	}
+	if err := schema.compilePatterns(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (s *payloadSchema) compilePatterns() error {
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+	children := []*payloadSchema{s.Items}
+	for _, property := range s.Properties {
+		children = append(children, property)
+	}
+	if s.AdditionalProperties != nil {
+		children = append(children, s.AdditionalProperties.schema)
+	}
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		if err := child.compilePatterns(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate appends a failure for every part of value, located at path,
+// that does not satisfy the schema. value holds decoded JSON.
+func (s *payloadSchema) validate(value interface{}, path string, failures *[]SchemaFailure) {
+	fail := func(format string, args ...interface{}) {
+		*failures = append(*failures, SchemaFailure{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.Type) > 0 && !s.Type.matches(value) {
+		fail("expected %s, got %s", strings.Join(s.Type, " or "), jsonType(value))
+		return
+	}
+	if s.Const != nil && !reflect.DeepEqual(value, *s.Const) {
+		fail("must be %s", jsonText(*s.Const))
+	}
+	if len(s.Enum) > 0 && !containsValue(s.Enum, value) {
+		fail("must be one of %s", jsonText(s.Enum))
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+		if s.ExclusiveMinimum != nil && v <= *s.ExclusiveMinimum {
+			fail("must be > %v", *s.ExclusiveMinimum)
+		}
+		if s.ExclusiveMaximum != nil && v >= *s.ExclusiveMaximum {
+			fail("must be < %v", *s.ExclusiveMaximum)
+		}
+	case string:
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match %q", s.Pattern)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), failures)
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range s.Required {
+			if _, ok := v[key]; !ok {
+				*failures = append(*failures, SchemaFailure{Path: path + "." + key, Message: "is required"})
+			}
+		}
+		for key, item := range v {
+			if property, ok := s.Properties[key]; ok {
+				property.validate(item, path+"."+key, failures)
+				continue
+			}
+			if extra := s.AdditionalProperties; extra != nil {
+				if extra.forbidden {
+					*failures = append(*failures, SchemaFailure{Path: path + "." + key, Message: "is not allowed"})
+				} else if extra.schema != nil {
+					extra.schema.validate(item, path+"."+key, failures)
+				}
+			}
+		}
+	}
+}
+
+func (t schemaTypes) matches(value interface{}) bool {
+	actual := jsonType(value)
+	for _, name := range t {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON Schema type of a decoded JSON value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonText(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// compilePayloadSchemas compiles SDKConfig.PayloadSchemas. A schema that
+// fails to compile is logged and every frame of its type is then rejected,
+// so a broken schema cannot silently disable validation.
+func (c *ATPClient) compilePayloadSchemas() {
+	if len(c.config.PayloadSchemas) == 0 {
+		return
+	}
+	c.schemas = make(map[string]*payloadSchema, len(c.config.PayloadSchemas))
+	c.schemaErrors = make(map[string]error)
+	for frameType, document := range c.config.PayloadSchemas {
+		schema, err := compilePayloadSchema(document)
+		if err != nil {
+			err = fmt.Errorf("invalid payload schema for %s frames: %w", frameType, err)
+			c.config.Logger.Printf("Warning: %v", err)
+			c.schemaErrors[frameType] = err
+			continue
+		}
+		c.schemas[frameType] = schema
+	}
+}
+
+// hasPayloadSchema reports whether frames of frameType are validated
+func (c *ATPClient) hasPayloadSchema(frameType string) bool {
+	return c.schemas[frameType] != nil || c.schemaErrors[frameType] != nil
+}
+
+// validatePayload checks the payload of frame against the schema registered
+// for its type. Frames of other types always pass.
+func (c *ATPClient) validatePayload(direction AuditDirection, frame *Frame) error {
+	if err := c.schemaErrors[frame.Type]; err != nil {
+		return err
+	}
+	schema := c.schemas[frame.Type]
+	if schema == nil {
+		return nil
+	}
+
+	// Validate the payload as it appears on the wire
+	data, err := json.Marshal(frame.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	var payload interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	var failures []SchemaFailure
+	schema.validate(payload, "payload", &failures)
+	if len(failures) == 0 {
+		return nil
+	}
+	sort.SliceStable(failures, func(i, j int) bool { return failures[i].Path < failures[j].Path })
+	return &SchemaValidationError{FrameType: frame.Type, Direction: direction, Failures: failures}
+}
+
+// prepareOutgoing runs the send interceptors on frame, validates the
+// resulting frame and payload, then encrypts and signs the frame
+func (c *ATPClient) prepareOutgoing(frame *Frame) error {
+	if err := c.materializePayload(frame); err != nil {
+		return err
+	}
+	if err := runInterceptors(c.config.SendInterceptors, frame); err != nil {
+		return err
+	}
+	c.checkTimestamp(AuditOutbound, frame)
+	if err := validateFrame(*frame, c.config.StrictFrameTypes); err != nil {
+		return err
+	}
+	if err := c.validatePayload(AuditOutbound, frame); err != nil {
+		return err
+	}
+	if err := c.encryptOutgoing(frame); err != nil {
+		return err
+	}
+	return c.signOutgoing(frame)
+}
+
+// rejectIncoming drops an incoming frame that failed validation. A waiter
+// for the frame gets err instead of the response; other frames are
+// reported through OnAsyncError.
+func (c *ATPClient) rejectIncoming(frame *Frame, err error) {
+	c.counters.recordError(err)
+	if isResponse(frame.Type) {
+		c.handlerMutex.RLock()
+		_, waiting := c.responseHandlers[responseKey(frame.StreamID, frame.MsgSeq)]
+		c.handlerMutex.RUnlock()
+		if waiting {
+			c.dispatchResult(frame, pendingResult{err: err})
+			return
+		}
+	}
+	c.config.Logger.Printf("Warning: dropping incoming frame: %v", err)
+	c.reportAsyncError(err)
+}
@@ -0,0 +1,168 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// schemaFailures returns the failures of a *SchemaValidationError as
+// "path: message" strings
+func schemaFailures(t *testing.T, err error) []string {
+	t.Helper()
+	var validationErr *SchemaValidationError
+	if !errors.As(err, &validationErr) || !errors.Is(err, ErrSchemaValidation) {
+		t.Fatalf("Expected a *SchemaValidationError, got %v", err)
+	}
+	var failures []string
+	for _, failure := range validationErr.Failures {
+		failures = append(failures, failure.String())
+	}
+	return failures
+}
+
+func TestDefaultSchemasMatchFrameBuilder(t *testing.T) {
+	client := NewATPClient(SDKConfig{PayloadSchemas: DefaultPayloadSchemas()})
+	fb := NewFrameBuilder("session", "tenant")
+
+	maxTokens, cost := 4096, 3
+	p95, errorRate, queueDepth := 120.0, 0.02, 4
+	frames := []Frame{
+		fb.BuildCompletionFrame("s1", CompletionRequest{Prompt: "hi"}),
+		fb.BuildCompletionFrame("s2", CompletionRequest{Prompt: "hi", Model: "m", MaxTokens: 16, Temperature: 0.7, TopP: 0.9, Stop: []string{"\n"}}),
+		fb.BuildCapabilityFrame("s3", CapabilityAdvertisement{AdapterID: "a", AdapterType: "ollama"}),
+		fb.BuildCapabilityFrame("s4", CapabilityAdvertisement{
+			AdapterID:          "a",
+			AdapterType:        "ollama",
+			Capabilities:       []string{"text-generation"},
+			Models:             []string{"llama2:7b"},
+			MaxTokens:          &maxTokens,
+			SupportedLanguages: []string{"en"},
+			CostPerTokenMicros: &cost,
+			Metadata:           map[string]interface{}{"region": "eu"},
+		}),
+		fb.BuildHealthFrame("s5", HealthStatus{AdapterID: "a", Status: "healthy"}),
+		fb.BuildHealthFrame("s6", HealthStatus{AdapterID: "a", Status: "degraded", P95LatencyMS: &p95, ErrorRate: &errorRate, QueueDepth: &queueDepth}),
+	}
+	for _, frame := range frames {
+		if err := client.validatePayload(AuditOutbound, &frame); err != nil {
+			t.Errorf("FrameBuilder %s frame %s fails its default schema: %v", frame.Type, frame.StreamID, err)
+		}
+	}
+	if len(DefaultPayloadSchemas()) != 3 {
+		t.Errorf("Expected 3 default schemas, got %v", DefaultPayloadSchemas())
+	}
+}
+
+func TestSchemaValidationRejectsOutgoingFrames(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}}
+	})
+	client := NewATPClient(SDKConfig{
+		WSURL:          router.URL(),
+		PayloadSchemas: DefaultPayloadSchemas(),
+		SendInterceptors: []func(*Frame) error{func(f *Frame) error {
+			if f.Type == "completion_request" && f.Payload["prompt"] == "break" {
+				f.Payload["max_tokens"] = "many"
+				f.Payload["stop"] = []interface{}{"\n", 3}
+				delete(f.Payload, "temperature")
+			}
+			return nil
+		}},
+	})
+	defer client.Close()
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "fine"}); err != nil {
+		t.Fatalf("Valid request rejected: %v", err)
+	}
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "break"})
+	got := strings.Join(schemaFailures(t, err), "\n")
+	want := strings.Join([]string{
+		"payload.max_tokens: expected integer, got string",
+		"payload.stop[1]: expected string, got integer",
+		"payload.temperature: is required",
+	}, "\n")
+	if got != want {
+		t.Errorf("Unexpected failures:\n%s\nwant:\n%s", got, want)
+	}
+
+	errorRate := 1.5
+	err = client.ReportHealth(context.Background(), HealthStatus{Status: "healthy", ErrorRate: &errorRate})
+	got = strings.Join(schemaFailures(t, err), "\n")
+	want = "payload.adapter_id: must be at least 1 characters\npayload.error_rate: must be <= 1"
+	if got != want {
+		t.Errorf("Unexpected failures:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSchemaValidationRejectsIncomingFrames(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": 42}}}
+	})
+	asyncErrors := make(chan error, 1)
+	client := NewATPClient(SDKConfig{
+		WSURL: router.URL(),
+		PayloadSchemas: map[string]string{
+			"completion_response": `{"type": "object", "required": ["text"], "properties": {"text": {"type": "string"}}}`,
+			"custom.event":        `{"type": "object", "properties": {"level": {"enum": ["info", "warn"]}}, "additionalProperties": false}`,
+		},
+		Logger:       &recordingLogger{},
+		OnAsyncError: func(err error) { asyncErrors <- err },
+	})
+	defer client.Close()
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if failures := schemaFailures(t, err); len(failures) != 1 || failures[0] != "payload.text: expected string, got integer" {
+		t.Errorf("Unexpected failures: %v", failures)
+	}
+	var validationErr *SchemaValidationError
+	errors.As(err, &validationErr)
+	if validationErr.Direction != AuditInbound || validationErr.FrameType != "completion_response" {
+		t.Errorf("Unexpected error detail: %+v", validationErr)
+	}
+
+	events, unsubscribe := client.Subscribe("custom.event")
+	defer unsubscribe()
+	router.Send(Frame{Type: "custom.event", Payload: map[string]interface{}{"level": "debug", "extra": true}})
+	select {
+	case err := <-asyncErrors:
+		got := strings.Join(schemaFailures(t, err), "\n")
+		if got != "payload.extra: is not allowed\npayload.level: must be one of [\"info\",\"warn\"]" {
+			t.Errorf("Unexpected failures:\n%s", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Invalid incoming frame not reported")
+	}
+	select {
+	case f := <-events:
+		t.Errorf("Invalid frame delivered to subscriber: %+v", f)
+	default:
+	}
+}
+
+func TestInvalidSchemaRejectsItsFrameType(t *testing.T) {
+	logger := &recordingLogger{}
+	client := NewATPClient(SDKConfig{
+		PayloadSchemas: map[string]string{
+			"heartbeat":     `{"type": "object", "properties": {"name": {"pattern": "("}}}`,
+			"adapter.ready": `{"type": 7}`,
+		},
+		Logger: logger,
+	})
+	if len(logger.Lines()) != 2 || !strings.HasPrefix(logger.Lines()[0], "Warning: invalid payload schema") {
+		t.Errorf("Expected warnings for both schemas, got %v", logger.Lines())
+	}
+	if client.heartbeatTemplateUsable() {
+		t.Error("Heartbeats with a schema must use the Frame based send path")
+	}
+	frame := Frame{Type: "adapter.ready", Payload: map[string]interface{}{}}
+	if err := client.validatePayload(AuditOutbound, &frame); err == nil || !strings.Contains(err.Error(), "adapter.ready") {
+		t.Errorf("Expected the broken schema to reject its frames, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// signingRouter answers completion requests with frames signed under
+// replyKey, passed through tamper, and records whether every frame it
+// received carried a valid signature under clientKey
+func signingRouter(t *testing.T, clientKey, replyKey []byte, tamper func(*Frame)) (*testRouter, func() (signed, heartbeats int, bad []string)) {
+	var mu sync.Mutex
+	var signed, heartbeats int
+	var bad []string
+
+	router := newTestRouter(t, func(f Frame) []Frame {
+		expected, err := SignFrame(f, clientKey)
+		mu.Lock()
+		if err != nil || f.Signature != expected {
+			bad = append(bad, f.Type)
+		} else {
+			signed++
+			if f.Type == "heartbeat" {
+				heartbeats++
+			}
+		}
+		mu.Unlock()
+
+		if f.Type != "completion_request" {
+			return nil
+		}
+		reply := Frame{
+			Type:     "completion_response",
+			StreamID: f.StreamID,
+			MsgSeq:   f.MsgSeq,
+			Payload:  map[string]interface{}{"text": "signed reply", "tokens_in": 3, "cost_usd": 0.0001},
+		}
+		reply.Signature, _ = SignFrame(reply, replyKey)
+		if tamper != nil {
+			tamper(&reply)
+		}
+		return []Frame{reply}
+	})
+	return router, func() (int, int, []string) {
+		mu.Lock()
+		defer mu.Unlock()
+		return signed, heartbeats, append([]string(nil), bad...)
+	}
+}
+
+func TestSigningRoundTrip(t *testing.T) {
+	key := []byte("current-key")
+	router, received := signingRouter(t, key, key, nil)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), SigningKey: key, HeartbeatInterval: 10 * time.Millisecond})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi", Stop: []string{"\n"}})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.Text != "signed reply" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		signed, heartbeats, bad := received()
+		if len(bad) > 0 {
+			t.Fatalf("Router received frames without a valid signature: %v", bad)
+		}
+		if signed > 1 && heartbeats > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("No signed heartbeat reached the router")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if client.Introspect().Features["signing"] != true {
+		t.Error("Introspection does not report signing")
+	}
+}
+
+func TestSigningRejectsTamperedFrames(t *testing.T) {
+	key := []byte("current-key")
+	tests := []struct {
+		name    string
+		tamper  func(*Frame)
+		missing bool
+	}{
+		{name: "tampered payload", tamper: func(f *Frame) { f.Payload["cost_usd"] = 0 }},
+		{name: "tampered header", tamper: func(f *Frame) { f.Meta.TaskType = "other" }},
+		{name: "unsigned", tamper: func(f *Frame) { f.Signature = "" }, missing: true},
+		{name: "malformed signature", tamper: func(f *Frame) { f.Signature = "not-hex" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := signingRouter(t, key, key, tt.tamper)
+			failures := make(chan error, 1)
+			client := NewATPClient(SDKConfig{
+				WSURL:              router.URL(),
+				SigningKey:         key,
+				Logger:             &recordingLogger{},
+				OnSignatureFailure: func(_ Frame, err error) { failures <- err },
+			})
+			defer client.Close()
+
+			_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+			var sigErr *SignatureError
+			if !errors.Is(err, ErrInvalidSignature) || !errors.As(err, &sigErr) || sigErr.Missing != tt.missing {
+				t.Fatalf("Expected a signature error (missing=%v), got %v", tt.missing, err)
+			}
+			select {
+			case <-failures:
+			default:
+				t.Error("OnSignatureFailure was not called")
+			}
+			if got := client.Stats().Frames.SignatureFailures; got != 1 {
+				t.Errorf("Expected 1 signature failure, got %d", got)
+			}
+		})
+	}
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	previous, current := []byte("previous-key"), []byte("current-key")
+
+	// The router still signs with the previous key
+	router, _ := signingRouter(t, current, previous, nil)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), SigningKey: current, VerificationKeys: [][]byte{previous}})
+	defer client.Close()
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Frame signed with a verification key rejected: %v", err)
+	}
+
+	// Once the previous key is retired its signatures are rejected
+	router, _ = signingRouter(t, current, previous, nil)
+	client = NewATPClient(SDKConfig{WSURL: router.URL(), SigningKey: current, Logger: &recordingLogger{}})
+	defer client.Close()
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Expected a retired key to be rejected, got %v", err)
+	}
+}
+
+func TestCanonicalFrameBytes(t *testing.T) {
+	type usage struct {
+		TokensOut int `json:"tokens_out"`
+		TokensIn  int `json:"tokens_in"`
+	}
+	structured := Frame{Type: "x", Timestamp: 1, Payload: map[string]interface{}{"usage": usage{TokensOut: 2, TokensIn: 1}}}
+	decoded := Frame{Type: "x", Timestamp: 1, Payload: map[string]interface{}{"usage": map[string]interface{}{"tokens_in": 1.0, "tokens_out": 2.0}}, Signature: "ignored"}
+
+	a, err := CanonicalFrameBytes(structured)
+	if err != nil {
+		t.Fatalf("CanonicalFrameBytes failed: %v", err)
+	}
+	b, _ := CanonicalFrameBytes(decoded)
+	if string(a) != string(b) {
+		t.Errorf("Equivalent frames canonicalize differently:\n%s\n%s", a, b)
+	}
+}
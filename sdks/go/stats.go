@@ -0,0 +1,274 @@
+package atpsdk
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClientStats is a point-in-time snapshot of client internals. Every field
+// is gathered from atomically maintained counters, so taking a snapshot is
+// cheap and never waits on the connection or handler locks.
+type ClientStats struct {
+	Connection ConnectionStats `json:"connection"`
+	Frames     FrameStats      `json:"frames"`
+	Pending    PendingStats    `json:"pending"`
+	Endpoint   EndpointHealth  `json:"endpoint"`
+	Usage      UsageTotals     `json:"usage"`
+	Tenant     TenantStats     `json:"tenant"`
+}
+
+// ConnectionStats describes the connection state
+type ConnectionStats struct {
+	Connected       bool      `json:"connected"`
+	ConnectedAt     time.Time `json:"connected_at,omitempty"`
+	ConnectCount    uint64    `json:"connect_count"`
+	DisconnectCount uint64    `json:"disconnect_count"`
+	// ReconnectCount counts the connections established after the first
+	ReconnectCount uint64 `json:"reconnect_count"`
+	// LastHeartbeatAck is when the router last acknowledged a heartbeat
+	LastHeartbeatAck time.Time `json:"last_heartbeat_ack,omitempty"`
+	// PoolConnections is the number of open connections, including the
+	// primary one, when PoolSize > 1
+	PoolConnections int `json:"pool_connections,omitempty"`
+	// Transport is TransportWebSocket or TransportHTTP
+	Transport string `json:"transport"`
+}
+
+// FrameStats counts frames sent and received
+type FrameStats struct {
+	Sent     uint64 `json:"sent"`
+	Received uint64 `json:"received"`
+	// SentByType and ReceivedByType break Sent and Received down by frame
+	// type. Received frames that cannot be decoded have no type and are
+	// only counted in Received.
+	SentByType     map[string]uint64 `json:"sent_by_type,omitempty"`
+	ReceivedByType map[string]uint64 `json:"received_by_type,omitempty"`
+	// Queued is the number of frames waiting in the outbound queues
+	Queued int64 `json:"queued"`
+	// SignatureFailures counts incoming frames dropped because their
+	// signature was missing or invalid
+	SignatureFailures uint64 `json:"signature_failures"`
+	// Expired counts incoming frames dropped because their TTL had run out
+	Expired uint64 `json:"expired"`
+	// Malformed counts received messages dropped because they could not
+	// be parsed as frames
+	Malformed uint64 `json:"malformed"`
+	// StrictFailures counts incoming frames dropped by
+	// SDKConfig.StrictDecoding
+	StrictFailures uint64 `json:"strict_failures"`
+}
+
+// PendingStats describes requests waiting for a response
+type PendingStats struct {
+	Count int64 `json:"count"`
+	// Orphaned counts responses that arrived after their request was
+	// abandoned
+	Orphaned uint64 `json:"orphaned"`
+}
+
+// EndpointHealth describes the router endpoint the client dials
+type EndpointHealth struct {
+	URL         string    `json:"url"`
+	Healthy     bool      `json:"healthy"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+}
+
+// UsageTotals accumulates usage reported in completion responses
+type UsageTotals struct {
+	Requests  uint64  `json:"requests"`
+	TokensIn  uint64  `json:"tokens_in"`
+	TokensOut uint64  `json:"tokens_out"`
+	CostUSD   float64 `json:"cost_usd"`
+}
+
+// TenantStats describes the client's tenant and any router-imposed
+// suspension of it
+type TenantStats struct {
+	ID              string    `json:"id"`
+	Suspended       bool      `json:"suspended"`
+	SuspendedReason string    `json:"suspended_reason,omitempty"`
+	SuspendedUntil  time.Time `json:"suspended_until,omitempty"`
+}
+
+// endpointError is the last connection error recorded for the endpoint
+type endpointError struct {
+	message string
+	at      time.Time
+}
+
+// clientCounters holds the atomically maintained state behind ClientStats
+type clientCounters struct {
+	clock             Clock // timestamps connects and errors
+	connected         atomic.Bool
+	connectedAt       atomic.Int64 // unix nanoseconds
+	connects          atomic.Uint64
+	disconnects       atomic.Uint64
+	framesSent        atomic.Uint64
+	framesReceived    atomic.Uint64
+	sentByType        frameTypeCounts
+	receivedByType    frameTypeCounts
+	pending           atomic.Int64
+	orphans           atomic.Uint64
+	signatureFailures atomic.Uint64
+	expiredFrames     atomic.Uint64
+	malformedFrames   atomic.Uint64
+	strictFailures    atomic.Uint64
+	lastHeartbeatAck  atomic.Int64  // unix nanoseconds
+	clockSkew         atomic.Int64  // router clock minus ours, nanoseconds
+	heartbeatSent     atomic.Uint64 // framesSent when the last heartbeat stats were taken
+	heartbeatReceived atomic.Uint64 // framesReceived when the last heartbeat stats were taken
+	outboundQueued    atomic.Int64  // frames waiting in outbound queues
+	poolConnections   atomic.Int64  // open pool connections besides the primary
+	lastError         atomic.Pointer[endpointError]
+}
+
+// frameTypeCounts counts frames by type
+type frameTypeCounts struct {
+	counts sync.Map // frame type to *atomic.Uint64
+}
+
+// add counts a frame of type frameType
+func (f *frameTypeCounts) add(frameType string) {
+	count, ok := f.counts.Load(frameType)
+	if !ok {
+		count, _ = f.counts.LoadOrStore(frameType, new(atomic.Uint64))
+	}
+	count.(*atomic.Uint64).Add(1)
+}
+
+// snapshot returns the counts, or nil when no frame was counted
+func (f *frameTypeCounts) snapshot() map[string]uint64 {
+	var counts map[string]uint64
+	f.counts.Range(func(key, value any) bool {
+		if counts == nil {
+			counts = make(map[string]uint64)
+		}
+		counts[key.(string)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+	return counts
+}
+
+// recordConnect marks the connection as established
+func (s *clientCounters) recordConnect() {
+	s.connectedAt.Store(s.clock.Now().UnixNano())
+	s.connects.Add(1)
+	s.connected.Store(true)
+}
+
+// recordConnect marks the connection as established, reporting reconnects
+// to the metrics sink
+func (c *ATPClient) recordConnect() {
+	c.counters.recordConnect()
+	if c.counters.connects.Load() > 1 {
+		c.config.Metrics.IncCounter(MetricReconnects, 1, nil)
+	}
+}
+
+// recordDisconnect marks the connection as closed, remembering cause if the
+// connection failed
+func (s *clientCounters) recordDisconnect(cause error) {
+	s.connected.Store(false)
+	s.disconnects.Add(1)
+	if cause != nil {
+		s.recordError(cause)
+	}
+}
+
+// recordError remembers err as the endpoint's last error
+func (s *clientCounters) recordError(err error) {
+	s.lastError.Store(&endpointError{message: err.Error(), at: s.clock.Now()})
+}
+
+// usageCounters holds the atomically maintained state behind UsageTotals,
+// kept by each clone of a client apart
+type usageCounters struct {
+	requests   atomic.Uint64
+	tokensIn   atomic.Uint64
+	tokensOut  atomic.Uint64
+	costMicros atomic.Uint64
+}
+
+// recordUsage accumulates the usage of a completed request
+func (s *usageCounters) recordUsage(response *CompletionResponse) {
+	s.requests.Add(1)
+	if response.TokensIn > 0 {
+		s.tokensIn.Add(uint64(response.TokensIn))
+	}
+	if response.TokensOut > 0 {
+		s.tokensOut.Add(uint64(response.TokensOut))
+	}
+	if response.CostUSD > 0 {
+		s.costMicros.Add(uint64(response.CostUSD*1e6 + 0.5))
+	}
+}
+
+// Stats returns a snapshot of the client's internal state. Usage counts
+// the requests of the client itself, not those of its clones.
+func (c *ATPClient) Stats() ClientStats {
+	s := &c.counters
+	stats := ClientStats{
+		Connection: ConnectionStats{
+			Connected:       s.connected.Load(),
+			ConnectCount:    s.connects.Load(),
+			DisconnectCount: s.disconnects.Load(),
+			Transport:       TransportWebSocket,
+		},
+		Frames: FrameStats{
+			Sent:              s.framesSent.Load(),
+			Received:          s.framesReceived.Load(),
+			SentByType:        s.sentByType.snapshot(),
+			ReceivedByType:    s.receivedByType.snapshot(),
+			Queued:            s.outboundQueued.Load(),
+			SignatureFailures: s.signatureFailures.Load(),
+			Expired:           s.expiredFrames.Load(),
+			Malformed:         s.malformedFrames.Load(),
+			StrictFailures:    s.strictFailures.Load(),
+		},
+		Pending: PendingStats{
+			Count:    s.pending.Load(),
+			Orphaned: s.orphans.Load(),
+		},
+		Endpoint: EndpointHealth{
+			URL:     c.endpoints.current(),
+			Healthy: s.connected.Load(),
+		},
+		Usage: UsageTotals{
+			Requests:  c.usage.requests.Load(),
+			TokensIn:  c.usage.tokensIn.Load(),
+			TokensOut: c.usage.tokensOut.Load(),
+			CostUSD:   float64(c.usage.costMicros.Load()) / 1e6,
+		},
+		Tenant: TenantStats{
+			ID: c.config.TenantID,
+		},
+	}
+	if c.usingHTTP() {
+		stats.Connection.Transport = TransportHTTP
+	}
+	if c.pool != nil {
+		stats.Connection.PoolConnections = int(s.poolConnections.Load())
+		if stats.Connection.Connected {
+			stats.Connection.PoolConnections++
+		}
+	}
+	if connects := stats.Connection.ConnectCount; connects > 1 {
+		stats.Connection.ReconnectCount = connects - 1
+	}
+	if at := s.connectedAt.Load(); at != 0 {
+		stats.Connection.ConnectedAt = time.Unix(0, at)
+	}
+	stats.Connection.LastHeartbeatAck = c.LastHeartbeatAck()
+	if last := s.lastError.Load(); last != nil {
+		stats.Endpoint.LastError = last.message
+		stats.Endpoint.LastErrorAt = last.at
+	}
+	if suspension := c.tenantSuspended(); suspension != nil {
+		stats.Tenant.Suspended = true
+		stats.Tenant.SuspendedReason = suspension.Reason
+		stats.Tenant.SuspendedUntil = suspension.Until
+	}
+	return stats
+}
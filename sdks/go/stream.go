@@ -0,0 +1,295 @@
+package atpsdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// FrameTypeStreamClose ends a stream. Stream.Close sends it, and a stream
+// receiving it from the router ends with io.EOF.
+const FrameTypeStreamClose = "stream_close"
+
+// StreamOptions configures a stream opened with OpenStream
+type StreamOptions struct {
+	// StreamID is the stream's ID, generated when empty
+	StreamID string
+	// QoS is the QoS class of the frames sent on the stream
+	QoS QoS
+	// Meta is sent with every frame. EnvironmentID defaults to the client's
+	// TenantID, and Trace to a new TraceContext.
+	Meta Meta
+	// RecvBuffer is how many received frames are held until Recv takes
+	// them (default: SDKConfig.SubscriptionBuffer). A stream whose buffer
+	// overflows ends with ErrStreamOverflow.
+	RecvBuffer int
+}
+
+// Stream is a long-lived exchange of frames with the router on one stream
+// ID, opened with OpenStream. Send numbers frames with the stream's own
+// msg_seq, so a Stream is owned by one sending goroutine. Recv and Close are
+// safe to call from any goroutine: concurrent Recv calls each take a
+// different frame, in arrival order, and Close ends any Recv in progress
+// with ErrStreamClosed.
+type Stream struct {
+	guard  ownerGuard
+	client *ATPClient
+	id     string
+	qos    QoS
+	meta   Meta
+	frames chan *Frame
+	done   chan struct{} // closed when the stream ends
+
+	mu  sync.Mutex
+	err error // why the stream ended
+}
+
+// OpenStream opens a stream for exchanging frames with the router. Frames
+// arriving on the stream's ID are held for Recv until the stream is closed.
+// The router cannot push frames over the HTTP transport, so there it fails
+// with ErrNotSupportedByTransport.
+func (c *ATPClient) OpenStream(ctx context.Context, opts StreamOptions) (*Stream, error) {
+	if c.closed() {
+		return nil, ErrClientClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if opts.QoS != "" {
+		if err := validateQoS(opts.QoS); err != nil {
+			return nil, err
+		}
+	}
+	if !c.IsConnected() {
+		if err := c.Connect(); err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+	if c.usingHTTP() {
+		return nil, fmt.Errorf("OpenStream: %w", ErrNotSupportedByTransport)
+	}
+
+	if opts.StreamID == "" {
+		opts.StreamID = c.newStreamID("stream")
+	}
+	if opts.Meta.EnvironmentID == "" {
+		opts.Meta.EnvironmentID = c.config.TenantID
+	}
+	if opts.Meta.Trace == nil {
+		opts.Meta.Trace = NewTraceContext()
+	}
+	if opts.RecvBuffer <= 0 {
+		opts.RecvBuffer = c.config.SubscriptionBuffer
+	}
+	s := &Stream{
+		guard:  newOwnerGuard(c.config.DebugChecks, "Stream"),
+		client: c,
+		id:     opts.StreamID,
+		qos:    opts.QoS,
+		meta:   opts.Meta,
+		frames: make(chan *Frame, opts.RecvBuffer),
+		done:   make(chan struct{}),
+	}
+
+	c.handlerMutex.Lock()
+	defer c.handlerMutex.Unlock()
+	if c.closed() {
+		return nil, ErrClientClosed
+	}
+	if _, exists := c.streams[s.id]; exists {
+		return nil, fmt.Errorf("stream %q is already open", s.id)
+	}
+	if c.streams == nil {
+		c.streams = make(map[string]*Stream)
+	}
+	c.streams[s.id] = s
+	return s, nil
+}
+
+// ID returns the stream ID
+func (s *Stream) ID() string {
+	return s.id
+}
+
+// Send sends a frame of frameType on the stream with the next msg_seq.
+// payload is a map or any value that encodes to a JSON object. It fails with
+// ErrStreamClosed once the stream has ended.
+func (s *Stream) Send(frameType string, payload any) error {
+	s.guard.check("Send")
+	if err := s.ended(); err != nil {
+		return ErrStreamClosed
+	}
+
+	fields, err := payloadFields(payload)
+	if err != nil {
+		return err
+	}
+	return s.client.sendFrame(Frame{
+		Type:      frameType,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(s.client.config.Clock.Now()),
+		StreamID:  s.id,
+		MsgSeq:    s.client.builder.getNextMsgSeq(s.id),
+		QoS:       s.qos,
+		Meta:      s.meta,
+		Payload:   fields,
+	})
+}
+
+// Recv returns the next frame received on the stream, waiting until one
+// arrives, ctx is done or the stream ends. After Close it returns
+// ErrStreamClosed. A stream ended otherwise first returns the frames it had
+// received, then io.EOF if the router closed it, ErrStreamOverflow if
+// frames arrived faster than they were received, or ErrClientClosed.
+func (s *Stream) Recv(ctx context.Context) (*Frame, error) {
+	select {
+	case frame := <-s.frames:
+		if s.ended() != ErrStreamClosed {
+			return frame, nil
+		}
+	default:
+	}
+
+	select {
+	case frame := <-s.frames:
+		return frame, nil
+	case <-s.done:
+		err := s.ended()
+		if err != ErrStreamClosed {
+			select {
+			case frame := <-s.frames:
+				return frame, nil
+			default:
+			}
+		}
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close ends the stream, deregisters it and tells the router with a
+// stream_close frame. Closing an ended stream does nothing.
+func (s *Stream) Close() error {
+	if !s.end(ErrStreamClosed) {
+		return nil
+	}
+	s.client.removeStream(s)
+
+	frame := s.client.builder.BuildStreamCloseFrame(s.id)
+	frame.QoS, frame.Meta = s.qos, s.meta
+	if err := s.client.sendFrame(frame); err != nil {
+		return fmt.Errorf("failed to send stream_close frame: %w", err)
+	}
+	return nil
+}
+
+// BuildStreamCloseFrame builds the stream_close frame ending streamID
+func (fb *FrameBuilder) BuildStreamCloseFrame(streamID string, meta ...Meta) Frame {
+	return Frame{
+		Type:      FrameTypeStreamClose,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(fb.clock.Now()),
+		StreamID:  streamID,
+		Meta:      withMeta(Meta{}, meta),
+		Payload:   map[string]interface{}{},
+	}
+}
+
+// end ends the stream with err, reporting false if it had already ended
+func (s *Stream) end(err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false
+	}
+	s.err = err
+	close(s.done)
+	return true
+}
+
+// ended returns why the stream ended, or nil while it is open
+func (s *Stream) ended() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// deliver hands a frame received on the stream to Recv. A full buffer ends
+// the stream rather than blocking the read loop.
+func (s *Stream) deliver(frame *Frame) {
+	select {
+	case s.frames <- frame:
+	default:
+		if s.end(ErrStreamOverflow) {
+			s.client.removeStream(s)
+			s.client.config.Logger.Printf("Warning: Stream %s dropped: %v", s.id, ErrStreamOverflow)
+		}
+	}
+}
+
+// dispatchStream hands a frame to the open stream with its stream ID, if
+// any. A stream_close frame from the router ends the stream.
+func (c *ATPClient) dispatchStream(frame *Frame) {
+	if frame.StreamID == "" {
+		return
+	}
+	c.handlerMutex.RLock()
+	s := c.streams[frame.StreamID]
+	c.handlerMutex.RUnlock()
+	if s == nil {
+		return
+	}
+
+	if frame.Type == FrameTypeStreamClose {
+		if s.end(io.EOF) {
+			c.removeStream(s)
+		}
+		return
+	}
+	received := *frame
+	s.deliver(&received)
+}
+
+// removeStream deregisters s and releases its msg_seq counter
+func (c *ATPClient) removeStream(s *Stream) {
+	c.handlerMutex.Lock()
+	defer c.handlerMutex.Unlock()
+	if c.streams[s.id] == s {
+		delete(c.streams, s.id)
+		c.builder.ReleaseStream(s.id)
+	}
+}
+
+// closeStreams ends every open stream with ErrClientClosed
+func (c *ATPClient) closeStreams() {
+	c.handlerMutex.Lock()
+	streams := c.streams
+	c.streams = nil
+	c.handlerMutex.Unlock()
+
+	for _, s := range streams {
+		s.end(ErrClientClosed)
+	}
+}
+
+// payloadFields returns payload as frame payload fields
+func payloadFields(payload any) (map[string]interface{}, error) {
+	switch p := payload.(type) {
+	case nil:
+		return map[string]interface{}{}, nil
+	case map[string]interface{}:
+		return p, nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload: %w", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("payload must encode to a JSON object: %w", err)
+	}
+	return fields, nil
+}
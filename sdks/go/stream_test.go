@@ -0,0 +1,212 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// streamRouter answers every chat.message frame with a chat.reply echoing
+// its text, and hands the stream_close frames it receives to the test
+func streamRouter(t *testing.T) (*testRouter, <-chan Frame) {
+	closes := make(chan Frame, 4)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		switch f.Type {
+		case "chat.message":
+			return []Frame{{Type: "chat.reply", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": f.Payload["text"]}}}
+		case FrameTypeStreamClose:
+			closes <- f
+		}
+		return nil
+	})
+	return router, closes
+}
+
+func openTestStream(t *testing.T, client *ATPClient, opts StreamOptions) *Stream {
+	t.Helper()
+	stream, err := client.OpenStream(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	return stream
+}
+
+func TestStreamSendRecv(t *testing.T) {
+	router, closes := streamRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	stream := openTestStream(t, client, StreamOptions{StreamID: "chat-1"})
+
+	// One stream carries several exchanges, numbered by its own msg_seq
+	for i, text := range []string{"hello", "again"} {
+		if err := stream.Send("chat.message", struct {
+			Text string `json:"text"`
+		}{text}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		reply, err := stream.Recv(context.Background())
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if reply.Type != "chat.reply" || reply.StreamID != "chat-1" || reply.MsgSeq != i+1 || reply.Payload["text"] != text {
+			t.Errorf("Unexpected reply %+v", reply)
+		}
+	}
+
+	if _, err := client.OpenStream(context.Background(), StreamOptions{StreamID: "chat-1"}); err == nil {
+		t.Error("Expected opening a stream ID twice to fail")
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case f := <-closes:
+		if f.StreamID != "chat-1" {
+			t.Errorf("Unexpected stream_close frame %+v", f)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("No stream_close frame sent")
+	}
+
+	// The ID is free again once the stream is closed
+	openTestStream(t, client, StreamOptions{StreamID: "chat-1"}).Close()
+}
+
+func TestStreamConcurrentRecv(t *testing.T) {
+	router, _ := streamRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	stream := openTestStream(t, client, StreamOptions{StreamID: "events"})
+	defer stream.Close()
+
+	const frames, receivers = 40, 4
+	var mu sync.Mutex
+	seen := map[int]int{}
+	var wg sync.WaitGroup
+	for r := 0; r < receivers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+				frame, err := stream.Recv(ctx)
+				cancel()
+				if err != nil {
+					return
+				}
+				mu.Lock()
+				seen[frame.MsgSeq]++
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 1; i <= frames; i++ {
+		if err := router.Send(Frame{Type: "event", StreamID: "events", MsgSeq: i}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	wg.Wait()
+
+	// Each frame is taken by exactly one receiver
+	for i := 1; i <= frames; i++ {
+		if seen[i] != 1 {
+			t.Errorf("Frame %d received %d times", i, seen[i])
+		}
+	}
+}
+
+func TestStreamCloseWhileReceiving(t *testing.T) {
+	router, _ := streamRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	stream := openTestStream(t, client, StreamOptions{})
+
+	received := make(chan error, 1)
+	go func() {
+		_, err := stream.Recv(context.Background())
+		received <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case err := <-received:
+		if !errors.Is(err, ErrStreamClosed) {
+			t.Errorf("Expected ErrStreamClosed from the pending Recv, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close did not end the pending Recv")
+	}
+
+	if err := stream.Send("chat.message", nil); !errors.Is(err, ErrStreamClosed) {
+		t.Errorf("Expected ErrStreamClosed from Send, got %v", err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Errorf("Expected closing twice to do nothing, got %v", err)
+	}
+}
+
+func TestStreamEnded(t *testing.T) {
+	router, _ := streamRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	stream := openTestStream(t, client, StreamOptions{StreamID: "remote"})
+	overflowing := openTestStream(t, client, StreamOptions{StreamID: "slow", RecvBuffer: 1})
+	closing := openTestStream(t, client, StreamOptions{StreamID: "local"})
+
+	// The router closing the stream ends it after the frames before
+	for _, frame := range []Frame{
+		{Type: "event", StreamID: "remote", MsgSeq: 1},
+		{Type: FrameTypeStreamClose, StreamID: "remote"},
+		{Type: "event", StreamID: "slow", MsgSeq: 1},
+		{Type: "event", StreamID: "slow", MsgSeq: 2},
+	} {
+		if err := router.Send(frame); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	select {
+	case <-overflowing.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("The slow stream did not overflow")
+	}
+	for want, s := range map[error]*Stream{io.EOF: stream, ErrStreamOverflow: overflowing} {
+		if frame, err := s.Recv(context.Background()); err != nil || frame.MsgSeq != 1 {
+			t.Fatalf("Expected the first frame of %s, got %+v, %v", s.ID(), frame, err)
+		}
+		if _, err := s.Recv(context.Background()); !errors.Is(err, want) {
+			t.Errorf("Expected %v from %s, got %v", want, s.ID(), err)
+		}
+	}
+
+	client.Close()
+	if _, err := closing.Recv(context.Background()); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed after Close, got %v", err)
+	}
+	if _, err := client.OpenStream(context.Background(), StreamOptions{}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed from OpenStream, got %v", err)
+	}
+}
+
+func TestStreamSendFromOtherGoroutine(t *testing.T) {
+	router, _ := streamRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), DebugChecks: true})
+	defer client.Close()
+	stream := openTestStream(t, client, StreamOptions{})
+	if err := stream.Send("chat.message", map[string]interface{}{"text": "hi"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		_ = stream.Send("chat.message", nil)
+	}()
+	if msg := fmt.Sprint(<-panicked); msg == "<nil>" {
+		t.Error("Expected Send from another goroutine to panic in debug mode")
+	}
+}
@@ -0,0 +1,73 @@
+package atpsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// StrictDecodeError is matched by ErrStrictDecoding. It describes a frame
+// that is valid JSON but breaks the envelope the SDK expects.
+type StrictDecodeError struct {
+	// Field is the offending field, or "" when the problem is not a field
+	Field string
+	// Reason is what is wrong, e.g. "unknown field" or "missing required
+	// field"
+	Reason string
+}
+
+func (e *StrictDecodeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%v: %s", ErrStrictDecoding, e.Reason)
+	}
+	return fmt.Sprintf("%v: %s %q", ErrStrictDecoding, e.Reason, e.Field)
+}
+
+// Is reports whether target is ErrStrictDecoding
+func (e *StrictDecodeError) Is(target error) bool {
+	return target == ErrStrictDecoding
+}
+
+// DeserializeFrameStrict is DeserializeFrame rejecting frames with fields
+// Frame does not have, at any level but the payload, or without a type or
+// timestamp. Such frames fail with a *StrictDecodeError naming the field;
+// data that is not a JSON frame at all fails as with DeserializeFrame.
+func (fb *FrameBuilder) DeserializeFrameStrict(data []byte) (Frame, error) {
+	var frame Frame
+	err := decodeFrameStrict(data, &frame)
+	normalizeVersion(&frame)
+	return frame, err
+}
+
+// decodeFrameStrict decodes data into frame as DeserializeFrameStrict does
+func decodeFrameStrict(data []byte, frame *Frame) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(frame); err != nil {
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			if unquoted, err := strconv.Unquote(field); err == nil {
+				field = unquoted
+			}
+			return &StrictDecodeError{Field: field, Reason: "unknown field"}
+		}
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return &StrictDecodeError{Reason: "data after the frame"}
+	}
+	return checkRequiredFields(frame)
+}
+
+// checkRequiredFields fails for a frame without a type or timestamp
+func checkRequiredFields(frame *Frame) error {
+	switch {
+	case frame.Type == "":
+		return &StrictDecodeError{Field: "type", Reason: "missing required field"}
+	case frame.Timestamp == 0:
+		return &StrictDecodeError{Field: "ts", Reason: "missing required field"}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package atpsdk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDeserializeFrameStrict(t *testing.T) {
+	fb := NewFrameBuilder("s1", "t1")
+	tests := []struct {
+		name  string
+		data  string
+		field string // "" for no StrictDecodeError
+		ok    bool
+	}{
+		{name: "valid", data: `{"type":"event","ts":1,"payload":{"anything":1}}`, ok: true},
+		{name: "unknown field", data: `{"type":"event","ts":1,"priority":3}`, field: "priority"},
+		{name: "unknown meta field", data: `{"type":"event","ts":1,"meta":{"region":"eu"}}`, field: "region"},
+		{name: "missing type", data: `{"ts":1}`, field: "type"},
+		{name: "missing ts", data: `{"type":"event"}`, field: "ts"},
+		{name: "trailing data", data: `{"type":"event","ts":1} {}`},
+		{name: "not json", data: `{"type":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fb.DeserializeFrameStrict([]byte(tt.data))
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("Expected the frame to be accepted, got %v", err)
+				}
+				return
+			}
+			var strictErr *StrictDecodeError
+			isStrict := errors.As(err, &strictErr)
+			switch {
+			case err == nil:
+				t.Fatal("Expected the frame to be rejected")
+			case tt.name == "not json":
+				if isStrict || errors.Is(err, ErrStrictDecoding) {
+					t.Errorf("Expected a plain parse error, got %v", err)
+				}
+			case !isStrict || strictErr.Field != tt.field || !errors.Is(err, ErrStrictDecoding):
+				t.Errorf("Expected a strict error naming %q, got %v", tt.field, err)
+			}
+		})
+	}
+
+	// The lenient decoder accepts what the strict one rejects
+	if _, err := fb.DeserializeFrame([]byte(`{"type":"event","priority":3}`)); err != nil {
+		t.Errorf("Expected DeserializeFrame to ignore unknown fields, got %v", err)
+	}
+}
+
+func TestStrictDecodingCountsFailures(t *testing.T) {
+	router := newTestRouter(t, nil)
+	defer router.Close()
+	metrics := newCountingMetrics()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), StrictDecoding: true, Metrics: metrics})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	frames, unsubscribe := client.Subscribe("event")
+	defer unsubscribe()
+	for _, message := range []string{
+		`{"type":"event","ts":1,"stream_id":"drift","priority":3}`,
+		`{"type":"event","stream_id":"no-ts"}`,
+		`not a frame`,
+		`{"type":"event","ts":1,"stream_id":"ok"}`,
+	} {
+		if err := router.SendRaw(websocket.TextMessage, []byte(message)); err != nil {
+			t.Fatalf("SendRaw failed: %v", err)
+		}
+	}
+	select {
+	case f := <-frames:
+		if f.StreamID != "ok" {
+			t.Errorf("Expected only the valid frame delivered, got %+v", f)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Valid frame not delivered")
+	}
+
+	stats := client.Stats().Frames
+	if stats.StrictFailures != 2 || stats.Malformed != 1 {
+		t.Errorf("Expected 2 strict failures and 1 malformed frame, got %d and %d", stats.StrictFailures, stats.Malformed)
+	}
+	if got := metrics.Counter(MetricDecodeFailures); got != 3 {
+		t.Errorf("Expected 3 decode failures reported, got %v", got)
+	}
+}
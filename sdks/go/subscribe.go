@@ -0,0 +1,137 @@
+package atpsdk
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// defaultSubscriptionBuffer is the per-subscriber channel capacity used when
+// SDKConfig.SubscriptionBuffer is unset
+const defaultSubscriptionBuffer = 64
+
+// subscription is a single Subscribe registration
+type subscription struct {
+	frameType string
+	ch        chan *Frame
+	dropped   atomic.Uint64
+	once      sync.Once
+}
+
+// Subscribe returns a channel receiving a copy of every incoming frame of
+// the given type, and a function that unsubscribes and closes the channel.
+// Each subscriber has its own bounded buffer; frames arriving while it is
+// full are dropped and counted in MetricSubscriptionDrops rather than
+// blocking the read loop. The channel is also closed when the client is
+// closed. Over the HTTP transport the router cannot push frames, so the
+// channel is returned closed, as it is for a type missing from
+// DefaultFrameTypes with SDKConfig.StrictFrameTypes.
+func (c *ATPClient) Subscribe(frameType string) (<-chan *Frame, func()) {
+	sub := &subscription{
+		frameType: frameType,
+		ch:        make(chan *Frame, c.config.SubscriptionBuffer),
+	}
+
+	if c.config.StrictFrameTypes {
+		if err := DefaultFrameTypes.validate(frameType); err != nil {
+			c.config.Logger.Printf("Warning: Subscribe(%q): %v", frameType, err)
+			close(sub.ch)
+			return sub.ch, func() {}
+		}
+	}
+	if c.usingHTTP() {
+		c.config.Logger.Printf("Warning: Subscribe(%q): %v", frameType, ErrNotSupportedByTransport)
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+
+	c.subMutex.Lock()
+	if c.closed() {
+		c.subMutex.Unlock()
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	c.subscriptions[frameType] = append(c.subscriptions[frameType], sub)
+	c.subMutex.Unlock()
+
+	return sub.ch, func() { c.unsubscribe(sub) }
+}
+
+// unsubscribe removes sub and closes its channel. It is idempotent.
+func (c *ATPClient) unsubscribe(sub *subscription) {
+	c.subMutex.Lock()
+	defer c.subMutex.Unlock()
+
+	subs := c.subscriptions[sub.frameType]
+	for i, s := range subs {
+		if s == sub {
+			c.subscriptions[sub.frameType] = append(subs[:i:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(c.subscriptions[sub.frameType]) == 0 {
+		delete(c.subscriptions, sub.frameType)
+	}
+	sub.once.Do(func() { close(sub.ch) })
+}
+
+// closeSubscriptions closes every subscriber channel
+func (c *ATPClient) closeSubscriptions() {
+	c.subMutex.Lock()
+	defer c.subMutex.Unlock()
+
+	for frameType, subs := range c.subscriptions {
+		for _, sub := range subs {
+			sub.once.Do(func() { close(sub.ch) })
+		}
+		delete(c.subscriptions, frameType)
+	}
+}
+
+// dispatchSubscribers delivers a copy of frame to every subscriber of its
+// type without blocking
+func (c *ATPClient) dispatchSubscribers(frame *Frame) {
+	c.subMutex.RLock()
+	defer c.subMutex.RUnlock()
+
+	for _, sub := range c.subscriptions[frame.Type] {
+		select {
+		case sub.ch <- cloneFrame(frame):
+		default:
+			sub.dropped.Add(1)
+			c.config.Metrics.IncCounter(MetricSubscriptionDrops, 1, map[string]string{"frame_type": frame.Type})
+		}
+	}
+}
+
+// cloneFrame returns a deep copy of frame so subscribers cannot observe each
+// other's modifications
+func cloneFrame(frame *Frame) *Frame {
+	clone := *frame
+	if frame.Flags != nil {
+		clone.Flags = append([]string(nil), frame.Flags...)
+	}
+	if frame.Payload != nil {
+		clone.Payload = cloneValue(frame.Payload).(map[string]interface{})
+	}
+	return &clone
+}
+
+// cloneValue deep-copies the maps and slices produced by encoding/json
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = cloneValue(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = cloneValue(val)
+		}
+		return s
+	default:
+		return v
+	}
+}
@@ -0,0 +1,161 @@
+package atpsdk
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type countingMetrics struct {
+	noopMetrics
+	mu       sync.Mutex
+	counters map[string]float64
+}
+
+func newCountingMetrics() *countingMetrics {
+	return &countingMetrics{counters: make(map[string]float64)}
+}
+
+func (m *countingMetrics) IncCounter(name string, delta float64, _ map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name] += delta
+}
+
+func (m *countingMetrics) Counter(name string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+// pushRouter returns a router that answers every "push" request frame with
+// the frames stored in its payload's "count" of policy.update frames.
+func pushRouter(t *testing.T) *testRouter {
+	return newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "push" {
+			return nil
+		}
+		count := getInt(f.Payload, "count", 1)
+		frames := make([]Frame, 0, count+1)
+		for i := 0; i < count; i++ {
+			frames = append(frames, Frame{
+				Type:    "policy.update",
+				MsgSeq:  i + 1,
+				Payload: map[string]interface{}{"rule": map[string]interface{}{"id": float64(i)}},
+			})
+		}
+		frames = append(frames, Frame{Type: "budget.warning", Payload: map[string]interface{}{}})
+		return frames
+	})
+}
+
+func TestSubscribeMultipleSubscribers(t *testing.T) {
+	router := pushRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	first, unsubFirst := client.Subscribe("policy.update")
+	defer unsubFirst()
+	second, unsubSecond := client.Subscribe("policy.update")
+	defer unsubSecond()
+	budget, unsubBudget := client.Subscribe("budget.warning")
+	defer unsubBudget()
+
+	if err := client.sendFrame(Frame{Type: "push", Payload: map[string]interface{}{"count": 1}}); err != nil {
+		t.Fatalf("sendFrame failed: %v", err)
+	}
+
+	var got []*Frame
+	for _, ch := range []<-chan *Frame{first, second, budget} {
+		select {
+		case f := <-ch:
+			got = append(got, f)
+		case <-time.After(time.Second):
+			t.Fatal("Subscriber did not receive a frame")
+		}
+	}
+
+	if got[0].Type != "policy.update" || got[1].Type != "policy.update" || got[2].Type != "budget.warning" {
+		t.Errorf("Unexpected frame types: %s, %s, %s", got[0].Type, got[1].Type, got[2].Type)
+	}
+	if got[0] == got[1] {
+		t.Fatal("Subscribers should receive distinct copies")
+	}
+	got[0].Payload["rule"].(map[string]interface{})["id"] = "mutated"
+	if got[1].Payload["rule"].(map[string]interface{})["id"] == "mutated" {
+		t.Error("Mutating one subscriber's frame should not affect another's")
+	}
+}
+
+func TestUnsubscribeClosesChannel(t *testing.T) {
+	client := NewATPClient(SDKConfig{})
+	ch, unsubscribe := client.Subscribe("policy.update")
+	unsubscribe()
+	unsubscribe() // idempotent
+
+	if _, ok := <-ch; ok {
+		t.Error("Expected channel to be closed after unsubscribe")
+	}
+	if len(client.subscriptions) != 0 {
+		t.Errorf("Expected no subscriptions left, got %d", len(client.subscriptions))
+	}
+
+	// Dispatch after unsubscribe must not panic on the closed channel
+	client.dispatchSubscribers(&Frame{Type: "policy.update"})
+}
+
+func TestSlowSubscriberDropsFrames(t *testing.T) {
+	router := pushRouter(t)
+	metrics := newCountingMetrics()
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), Metrics: metrics, SubscriptionBuffer: 2})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	slow, unsubSlow := client.Subscribe("policy.update")
+	defer unsubSlow()
+	budget, unsubBudget := client.Subscribe("budget.warning")
+	defer unsubBudget()
+
+	if err := client.sendFrame(Frame{Type: "push", Payload: map[string]interface{}{"count": 10}}); err != nil {
+		t.Fatalf("sendFrame failed: %v", err)
+	}
+
+	// The read loop keeps going past the full subscriber
+	select {
+	case <-budget:
+	case <-time.After(time.Second):
+		t.Fatal("Read loop was blocked by a slow subscriber")
+	}
+
+	if len(slow) != 2 {
+		t.Errorf("Expected slow subscriber buffer to hold 2 frames, got %d", len(slow))
+	}
+	if dropped := metrics.Counter(MetricSubscriptionDrops); dropped != 8 {
+		t.Errorf("Expected 8 dropped frames, got %v", dropped)
+	}
+}
+
+func TestCloseClosesSubscriptions(t *testing.T) {
+	client := NewATPClient(SDKConfig{})
+	ch, _ := client.Subscribe("policy.update")
+	client.Close()
+
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Error("Expected channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not close the subscription channel")
+	}
+
+	late, _ := client.Subscribe("policy.update")
+	if _, ok := <-late; ok {
+		t.Error("Subscribe on a closed client should return a closed channel")
+	}
+}
@@ -0,0 +1,176 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Control frame types used by the router to suspend and resume a tenant
+const (
+	FrameTypeTenantSuspend = "tenant.suspend"
+	FrameTypeTenantResume  = "tenant.resume"
+)
+
+// TenantSuspendedError is returned for requests made while the client's
+// tenant is suspended by the router. It matches ErrTenantSuspended with
+// errors.Is.
+type TenantSuspendedError struct {
+	TenantID string
+	Reason   string
+	// Until is when the suspension expires. Zero means it lasts until the
+	// router sends a tenant.resume frame.
+	Until time.Time
+}
+
+func (e *TenantSuspendedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("%v: %s", ErrTenantSuspended, e.TenantID)
+	}
+	return fmt.Sprintf("%v: %s: %s", ErrTenantSuspended, e.TenantID, e.Reason)
+}
+
+// Is reports whether target is ErrTenantSuspended
+func (e *TenantSuspendedError) Is(target error) bool {
+	return target == ErrTenantSuspended
+}
+
+// tenantSuspension holds the suspension state of the client's tenant. The
+// current suspension is read lock-free by request paths and Stats.
+type tenantSuspension struct {
+	current atomic.Pointer[TenantSuspendedError]
+
+	mu         sync.Mutex
+	generation uint64
+	suspended  chan struct{} // closed when a suspension starts
+}
+
+// tenantContextKey is the context key of the tenant set by ContextWithTenant
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a context making the requests made with it act
+// for tenantID instead of SDKConfig.TenantID. The tenant is sent as the
+// frames' environment ID; the client itself is left unchanged. WithTenant
+// overrides it.
+func ContextWithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// tenantOf returns the tenant a request made with ctx acts for
+func (c *ATPClient) tenantOf(ctx context.Context) string {
+	if tenantID, _ := ctx.Value(tenantContextKey{}).(string); tenantID != "" {
+		return tenantID
+	}
+	return c.config.TenantID
+}
+
+// builderFor returns the frame builder for the tenant of ctx. Builders of
+// other tenants share the client's msg_seq counters, namespaced by tenant.
+func (c *ATPClient) builderFor(ctx context.Context) *FrameBuilder {
+	return c.builder.ForTenant(c.tenantOf(ctx))
+}
+
+// newFrameBuilder returns a builder of frames for tenantID with counters of
+// its own, on the client's clock
+func (c *ATPClient) newFrameBuilder(tenantID string) *FrameBuilder {
+	builder := NewFrameBuilder(c.config.SessionID, tenantID)
+	builder.SetClock(c.config.Clock)
+	return builder
+}
+
+// tenantSuspended returns the active suspension of the client's tenant, or
+// nil. A suspension past its expiry is treated as lifted even before the
+// expiry timer has fired.
+func (c *ATPClient) tenantSuspended() *TenantSuspendedError {
+	s := c.suspension.current.Load()
+	if s == nil || (!s.Until.IsZero() && !c.config.Clock.Now().Before(s.Until)) {
+		return nil
+	}
+	return s
+}
+
+// tenantSuspendedSignal returns a channel that is closed when the client's
+// tenant is next suspended
+func (c *ATPClient) tenantSuspendedSignal() <-chan struct{} {
+	c.suspension.mu.Lock()
+	defer c.suspension.mu.Unlock()
+	if c.suspension.suspended == nil {
+		c.suspension.suspended = make(chan struct{})
+	}
+	return c.suspension.suspended
+}
+
+// handleTenantControl applies a tenant.suspend or tenant.resume frame.
+// Frames for other tenants are ignored.
+func (c *ATPClient) handleTenantControl(frame *Frame) {
+	tenantID := getString(frame.Payload, "tenant_id", "")
+	if tenantID != c.config.TenantID {
+		return
+	}
+
+	switch frame.Type {
+	case FrameTypeTenantSuspend:
+		suspension := &TenantSuspendedError{
+			TenantID: tenantID,
+			Reason:   getString(frame.Payload, "reason", ""),
+		}
+		if expiresAt := getFloat64(frame.Payload, "expires_at", 0); expiresAt > 0 {
+			suspension.Until = time.Unix(0, int64(expiresAt*1e9))
+		}
+		c.suspendTenant(suspension)
+	case FrameTypeTenantResume:
+		c.suspension.mu.Lock()
+		generation := c.suspension.generation
+		c.suspension.mu.Unlock()
+		c.resumeTenant(generation)
+	}
+}
+
+// suspendTenant records suspension, fails in-flight requests and schedules
+// the automatic resumption
+func (c *ATPClient) suspendTenant(suspension *TenantSuspendedError) {
+	c.suspension.mu.Lock()
+	c.suspension.generation++
+	generation := c.suspension.generation
+	c.suspension.current.Store(suspension)
+	if c.suspension.suspended != nil {
+		close(c.suspension.suspended)
+	}
+	c.suspension.suspended = make(chan struct{})
+	c.suspension.mu.Unlock()
+
+	c.config.Logger.Printf("Warning: Tenant %s suspended by router: %s", suspension.TenantID, suspension.Reason)
+	if c.config.OnTenantSuspended != nil {
+		c.config.OnTenantSuspended(suspension)
+	}
+
+	if !suspension.Until.IsZero() {
+		expiry := c.config.Clock.After(suspension.Until.Sub(c.config.Clock.Now()))
+		go func() {
+			select {
+			case <-expiry:
+				c.resumeTenant(generation)
+			case <-c.ctx.Done():
+			}
+		}()
+	}
+}
+
+// resumeTenant lifts the suspension if it is still the one identified by
+// generation, so a stale expiry cannot lift a newer suspension
+func (c *ATPClient) resumeTenant(generation uint64) {
+	c.suspension.mu.Lock()
+	suspension := c.suspension.current.Load()
+	if suspension == nil || generation != c.suspension.generation {
+		c.suspension.mu.Unlock()
+		return
+	}
+	c.suspension.current.Store(nil)
+	c.suspension.mu.Unlock()
+
+	if c.config.OnTenantResumed != nil {
+		c.config.OnTenantResumed(suspension.TenantID)
+	}
+}
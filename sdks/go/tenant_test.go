@@ -0,0 +1,206 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tenantRouter answers completion requests, except prompts of "hang"
+func tenantRouter(t *testing.T) *testRouter {
+	return newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" || f.Payload["prompt"] == "hang" {
+			return nil
+		}
+		return []Frame{{
+			Type:     "completion_response",
+			StreamID: f.StreamID,
+			MsgSeq:   f.MsgSeq,
+			Payload:  map[string]interface{}{"text": "ok"},
+		}}
+	})
+}
+
+func waitForSuspended(t *testing.T, client *ATPClient, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for client.Stats().Tenant.Suspended != want && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+	if client.Stats().Tenant.Suspended != want {
+		t.Fatalf("Expected suspended=%v", want)
+	}
+}
+
+func TestTenantSuspendIsolationAndExpiry(t *testing.T) {
+	router := tenantRouter(t)
+	clock := newFakeClock()
+
+	suspendedHook := make(chan *TenantSuspendedError, 1)
+	resumedHook := make(chan string, 1)
+	clientA := NewATPClient(SDKConfig{
+		WSURL:             router.URL(),
+		TenantID:          "tenant-a",
+		Clock:             clock,
+		Logger:            &recordingLogger{},
+		OnTenantSuspended: func(s *TenantSuspendedError) { suspendedHook <- s },
+		OnTenantResumed:   func(tenantID string) { resumedHook <- tenantID },
+	})
+	defer clientA.Close()
+	clientB := NewATPClient(SDKConfig{WSURL: router.URL(), TenantID: "tenant-b", Clock: clock})
+	defer clientB.Close()
+
+	ctx := context.Background()
+	for _, client := range []*ATPClient{clientA, clientB} {
+		if _, err := client.Complete(ctx, CompletionRequest{Prompt: "hi"}); err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+	}
+
+	// An in-flight request is cancelled by the suspension
+	inFlight := make(chan error, 1)
+	go func() {
+		_, err := clientA.Complete(ctx, CompletionRequest{Prompt: "hang"})
+		inFlight <- err
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for clientA.Stats().Pending.Count == 0 && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	expiresAt := clock.Now().Add(time.Minute)
+	if err := router.Send(Frame{Type: FrameTypeTenantSuspend, Payload: map[string]interface{}{
+		"tenant_id":  "tenant-a",
+		"reason":     "incident 42",
+		"expires_at": float64(expiresAt.Unix()),
+	}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	select {
+	case err := <-inFlight:
+		var suspended *TenantSuspendedError
+		if !errors.Is(err, ErrTenantSuspended) || !errors.As(err, &suspended) || suspended.Reason != "incident 42" {
+			t.Errorf("Expected in-flight request to fail with the suspension, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("In-flight request was not cancelled")
+	}
+	if s := <-suspendedHook; s.TenantID != "tenant-a" || !s.Until.Equal(expiresAt) {
+		t.Errorf("Unexpected suspension hook: %+v", s)
+	}
+
+	// New requests fail immediately for the suspended tenant only
+	if _, err := clientA.Complete(ctx, CompletionRequest{Prompt: "hi"}); !errors.Is(err, ErrTenantSuspended) {
+		t.Errorf("Expected ErrTenantSuspended, got %v", err)
+	}
+	if _, err := clientB.Complete(ctx, CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Errorf("Other tenant should be unaffected, got %v", err)
+	}
+	stats := clientA.Stats().Tenant
+	if !stats.Suspended || stats.SuspendedReason != "incident 42" || !stats.SuspendedUntil.Equal(expiresAt) {
+		t.Errorf("Unexpected tenant stats: %+v", stats)
+	}
+	if clientB.Stats().Tenant.Suspended {
+		t.Error("Other tenant reported as suspended")
+	}
+
+	// The suspension lifts automatically at expiry
+	clock.Advance(time.Minute)
+	select {
+	case tenantID := <-resumedHook:
+		if tenantID != "tenant-a" {
+			t.Errorf("Unexpected resumed tenant %q", tenantID)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Suspension did not expire")
+	}
+	if _, err := clientA.Complete(ctx, CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Errorf("Expected requests to resume, got %v", err)
+	}
+}
+
+func TestTenantResumeFrame(t *testing.T) {
+	router := tenantRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), TenantID: "tenant-a", Logger: &recordingLogger{}})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	router.WaitForConnections(t, 1)
+
+	_ = router.Send(Frame{Type: FrameTypeTenantSuspend, Payload: map[string]interface{}{"tenant_id": "tenant-a"}})
+	waitForSuspended(t, client, true)
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); !errors.Is(err, ErrTenantSuspended) {
+		t.Errorf("Expected ErrTenantSuspended, got %v", err)
+	}
+
+	// Resume frames for other tenants are ignored
+	_ = router.Send(Frame{Type: FrameTypeTenantResume, Payload: map[string]interface{}{"tenant_id": "tenant-b"}})
+	_ = router.Send(Frame{Type: FrameTypeTenantResume, Payload: map[string]interface{}{"tenant_id": "tenant-a"}})
+	waitForSuspended(t, client, false)
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Errorf("Expected requests to resume, got %v", err)
+	}
+}
+
+func TestPerRequestTenant(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{
+			"text": fmt.Sprintf("%s/%d", f.Meta.EnvironmentID, f.MsgSeq),
+		}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), TenantID: "home"})
+	defer client.Close()
+
+	// Concurrent requests act for their own tenants, set by option or by
+	// context
+	tenants := []string{"tenant-a", "tenant-b", "tenant-c", "home"}
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		tenant := tenants[i%len(tenants)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.Background()
+			var opts []RequestOption
+			switch {
+			case i%len(tenants) == len(tenants)-1:
+			case i%2 == 0:
+				opts = append(opts, WithTenant(tenant))
+			default:
+				ctx = ContextWithTenant(ctx, tenant)
+			}
+			response, err := client.Complete(ctx, CompletionRequest{Prompt: "hi"}, opts...)
+			if err != nil {
+				t.Errorf("Complete failed: %v", err)
+				return
+			}
+			if want := tenant + "/1"; response.Text != want {
+				t.Errorf("Expected %s, got %s", want, response.Text)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// WithTenant wins over the context
+	response, err := client.Complete(ContextWithTenant(context.Background(), "tenant-a"), CompletionRequest{Prompt: "hi"}, WithTenant("tenant-b"))
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.Text != "tenant-b/1" {
+		t.Errorf("Expected the option's tenant, got %s", response.Text)
+	}
+	if client.config.TenantID != "home" {
+		t.Errorf("Expected the client's tenant unchanged, got %s", client.config.TenantID)
+	}
+	if n := streamCounters(client.builder); n != 0 {
+		t.Errorf("Expected every tenant's counters released, %d left", n)
+	}
+}
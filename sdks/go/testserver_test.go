@@ -0,0 +1,232 @@
+package atpsdk
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testRouter is a minimal in-process WebSocket server standing in for the
+// ATP Router in tests. Each accepted connection is served by onFrame, which
+// returns the frames to send back.
+type testRouter struct {
+	server  *httptest.Server
+	onFrame func(conn int, frame Frame) []Frame
+	codec   FrameCodec // decodes binary messages and encodes frames sent, if set
+
+	mu      sync.Mutex
+	conns   []*testRouterConn
+	queries []url.Values  // dial query of each accepted connection
+	headers []http.Header // dial headers of each accepted connection
+	active  int
+	binary  int // binary messages received
+}
+
+// testRouterConn serializes writes to one accepted connection
+type testRouterConn struct {
+	conn  *websocket.Conn
+	codec FrameCodec
+	wmu   sync.Mutex
+}
+
+func (c *testRouterConn) write(frame Frame) error {
+	if c.codec == nil {
+		c.wmu.Lock()
+		defer c.wmu.Unlock()
+		return c.conn.WriteJSON(frame)
+	}
+	data, err := c.codec.Marshal(&frame)
+	if err != nil {
+		return err
+	}
+	return c.writeMessage(websocket.BinaryMessage, data)
+}
+
+func (c *testRouterConn) writeMessage(messageType int, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.conn.WriteMessage(messageType, data)
+}
+
+func newTestRouter(t *testing.T, onFrame func(Frame) []Frame) *testRouter {
+	t.Helper()
+	if onFrame == nil {
+		return newConnTestRouter(t, nil)
+	}
+	return newConnTestRouter(t, func(_ int, frame Frame) []Frame { return onFrame(frame) })
+}
+
+// newConnTestRouter is newTestRouter for handlers that need to know which
+// connection a frame arrived on. Connections are numbered in accept order.
+func newConnTestRouter(t *testing.T, onFrame func(conn int, frame Frame) []Frame) *testRouter {
+	t.Helper()
+	return newCodecTestRouter(t, nil, onFrame)
+}
+
+// newCodecTestRouter is newConnTestRouter for a router exchanging binary
+// frames encoded with codec
+func newCodecTestRouter(t *testing.T, codec FrameCodec, onFrame func(conn int, frame Frame) []Frame) *testRouter {
+	t.Helper()
+
+	r := &testRouter{onFrame: onFrame, codec: codec}
+	upgrader := websocket.Upgrader{}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		rc := &testRouterConn{conn: conn, codec: r.codec}
+		r.mu.Lock()
+		index := len(r.conns)
+		r.conns = append(r.conns, rc)
+		r.queries = append(r.queries, req.URL.Query())
+		r.headers = append(r.headers, req.Header.Clone())
+		r.active++
+		r.mu.Unlock()
+		defer func() {
+			r.mu.Lock()
+			r.active--
+			r.mu.Unlock()
+		}()
+
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var frame Frame
+			if messageType == websocket.BinaryMessage {
+				r.mu.Lock()
+				r.binary++
+				r.mu.Unlock()
+				if r.codec == nil || r.codec.Unmarshal(data, &frame) != nil {
+					continue
+				}
+			} else if err := json.Unmarshal(data, &frame); err != nil {
+				continue
+			}
+			if r.onFrame == nil {
+				continue
+			}
+			for _, reply := range r.onFrame(index, frame) {
+				if err := rc.write(reply); err != nil {
+					return
+				}
+			}
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// URL returns the ws:// URL of the router.
+func (r *testRouter) URL() string {
+	return "ws" + strings.TrimPrefix(r.server.URL, "http")
+}
+
+// Send pushes an unsolicited frame to every accepted connection.
+func (r *testRouter) Send(frame Frame) error {
+	r.mu.Lock()
+	conns := append([]*testRouterConn(nil), r.conns...)
+	r.mu.Unlock()
+
+	for _, rc := range conns {
+		if err := rc.write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendRaw pushes a raw message of messageType to every accepted connection.
+func (r *testRouter) SendRaw(messageType int, data []byte) error {
+	r.mu.Lock()
+	conns := append([]*testRouterConn(nil), r.conns...)
+	r.mu.Unlock()
+
+	for _, rc := range conns {
+		if err := rc.writeMessage(messageType, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BinaryMessages returns how many binary messages the router received.
+func (r *testRouter) BinaryMessages() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.binary
+}
+
+// WaitForConnections blocks until at least n connections were accepted.
+func (r *testRouter) WaitForConnections(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		r.mu.Lock()
+		count := len(r.conns)
+		r.mu.Unlock()
+		if count >= n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Router did not accept %d connection(s)", n)
+}
+
+// SendTo pushes a frame to the connection with the given accept index.
+func (r *testRouter) SendTo(conn int, frame Frame) error {
+	r.mu.Lock()
+	rc := r.conns[conn]
+	r.mu.Unlock()
+	return rc.write(frame)
+}
+
+// DialQuery returns the query parameters the connection with the given
+// accept index was dialled with.
+func (r *testRouter) DialQuery(conn int) url.Values {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.queries[conn]
+}
+
+// DialHeader returns the HTTP headers the connection with the given accept
+// index was dialled with.
+func (r *testRouter) DialHeader(conn int) http.Header {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.headers[conn]
+}
+
+// ActiveConnections returns the number of connections still open.
+func (r *testRouter) ActiveConnections() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.active
+}
+
+// DropConnections forcibly closes every accepted connection.
+func (r *testRouter) DropConnections() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rc := range r.conns {
+		_ = rc.conn.Close()
+	}
+	r.conns = nil
+	r.queries = nil
+	r.headers = nil
+}
+
+// Close drops all connections and shuts the server down.
+func (r *testRouter) Close() {
+	r.DropConnections()
+	r.server.Close()
+}
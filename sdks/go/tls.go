@@ -0,0 +1,220 @@
+package atpsdk
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsIdentity is the client certificate and CA configured with the
+// SDKConfig TLS fields. Certificates loaded from files are reloaded when
+// the files change, so rotated certificates are used from the next dial on.
+type tlsIdentity struct {
+	base *tls.Config // SDKConfig.TLSConfig, never modified
+
+	certFile, keyFile, caFile string
+	certPEM, keyPEM, caPEM    []byte
+
+	mu      sync.Mutex
+	config  *tls.Config
+	modTime map[string]time.Time // of each file when last loaded
+}
+
+// tlsConfigured reports whether any TLS option is set
+func tlsConfigured(config *SDKConfig) bool {
+	return config.TLSConfig != nil || config.ClientCertFile != "" || config.ClientKeyFile != "" || config.CAFile != "" ||
+		len(config.ClientCertPEM) > 0 || len(config.ClientKeyPEM) > 0 || len(config.CAPEM) > 0
+}
+
+// setupTLS loads the TLS identity from the configuration. An identity that
+// cannot be loaded is logged, and makes every dial fail with the same
+// error, so a broken certificate is reported before the first connection.
+func (c *ATPClient) setupTLS() {
+	if !tlsConfigured(&c.config) {
+		return
+	}
+	identity := &tlsIdentity{
+		base:     c.config.TLSConfig,
+		certFile: c.config.ClientCertFile,
+		keyFile:  c.config.ClientKeyFile,
+		caFile:   c.config.CAFile,
+		certPEM:  c.config.ClientCertPEM,
+		keyPEM:   c.config.ClientKeyPEM,
+		caPEM:    c.config.CAPEM,
+	}
+	if _, err := identity.current(); err != nil {
+		c.tlsErr = err
+		c.config.Logger.Printf("Warning: %v", err)
+		return
+	}
+	c.tls = identity
+}
+
+// tlsConfig returns the TLS configuration for the next dial, or nil when no
+// TLS option is set
+func (c *ATPClient) tlsConfig() (*tls.Config, error) {
+	if c.tlsErr != nil {
+		return nil, c.tlsErr
+	}
+	if c.tls == nil {
+		return nil, nil
+	}
+	return c.tls.current()
+}
+
+// current returns the TLS configuration, reloading it first if one of its
+// files changed. If the reload fails, e.g. because a certificate is being
+// rewritten, the previous configuration stays in use.
+func (t *tlsIdentity) current() (*tls.Config, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	modTime, statErr := t.fileModTimes()
+	if t.config != nil && (statErr != nil || !t.changed(modTime)) {
+		return t.config, nil
+	}
+
+	config, err := t.load()
+	if err != nil {
+		if t.config != nil {
+			return t.config, nil
+		}
+		return nil, err
+	}
+	t.config, t.modTime = config, modTime
+	return config, nil
+}
+
+// fileModTimes stats the configured files
+func (t *tlsIdentity) fileModTimes() (map[string]time.Time, error) {
+	modTime := make(map[string]time.Time)
+	for _, file := range []string{t.certFile, t.keyFile, t.caFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTime[file] = info.ModTime()
+	}
+	return modTime, nil
+}
+
+// changed reports whether a file was modified since it was last loaded
+func (t *tlsIdentity) changed(modTime map[string]time.Time) bool {
+	for file, at := range modTime {
+		if !at.Equal(t.modTime[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+// load builds a TLS configuration from the identity's files and PEM blocks
+func (t *tlsIdentity) load() (*tls.Config, error) {
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidTLSConfig, fmt.Sprintf(format, args...))
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.base != nil {
+		config = t.base.Clone()
+	}
+
+	certPEM, err := pemSource("client certificate", t.certFile, t.certPEM)
+	if err != nil {
+		return nil, fail("%v", err)
+	}
+	keyPEM, err := pemSource("client key", t.keyFile, t.keyPEM)
+	if err != nil {
+		return nil, fail("%v", err)
+	}
+	switch {
+	case certPEM != nil && keyPEM != nil:
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fail("invalid client certificate or key: %v", err)
+		}
+		if now := time.Now(); now.After(cert.Leaf.NotAfter) {
+			return nil, fail("client certificate %q expired at %s", cert.Leaf.Subject.CommonName, cert.Leaf.NotAfter.Format(time.RFC3339))
+		} else if now.Before(cert.Leaf.NotBefore) {
+			return nil, fail("client certificate %q is not valid before %s", cert.Leaf.Subject.CommonName, cert.Leaf.NotBefore.Format(time.RFC3339))
+		}
+		config.Certificates = []tls.Certificate{cert}
+	case certPEM != nil:
+		return nil, fail("client certificate set without a client key")
+	case keyPEM != nil:
+		return nil, fail("client key set without a client certificate")
+	}
+
+	caPEM, err := pemSource("CA", t.caFile, t.caPEM)
+	if err != nil {
+		return nil, fail("%v", err)
+	}
+	if caPEM != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fail("no certificates found in CA %s", describePEMSource(t.caFile))
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// pemSource returns the PEM data of a TLS option given either as a file or
+// in memory
+func pemSource(name, file string, data []byte) ([]byte, error) {
+	switch {
+	case file != "" && len(data) > 0:
+		return nil, fmt.Errorf("%s set both as a file and as PEM", name)
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		return data, nil
+	case len(data) > 0:
+		return data, nil
+	}
+	return nil, nil
+}
+
+// describePEMSource names a PEM source in error messages
+func describePEMSource(file string) string {
+	if file != "" {
+		return fmt.Sprintf("file %s", file)
+	}
+	return "PEM"
+}
+
+// tlsHasClientCert reports whether the client presents a certificate
+func (c *ATPClient) tlsHasClientCert() bool {
+	if c.tls == nil {
+		return false
+	}
+	config, err := c.tls.current()
+	return err == nil && len(config.Certificates) > 0
+}
+
+// httpTLSTransport returns an HTTP transport dialling TLS connections with
+// the configuration current at each dial, so that HTTP requests pick up
+// rotated certificates like WebSocket connections do
+func (c *ATPClient) httpTLSTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		config, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		dialer := &tls.Dialer{Config: config}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return transport
+}
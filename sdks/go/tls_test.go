@@ -0,0 +1,277 @@
+package atpsdk
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testCA is a self-signed certificate authority issuing test certificates
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key for commonName, valid from
+// notBefore for an hour
+func (ca *testCA) issue(t *testing.T, commonName string, notBefore time.Time) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("CreateCertificate failed: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// mtlsRouter is a WebSocket router over TLS that requires a client
+// certificate. It answers completion requests with the common name of the
+// client certificate the connection presented.
+type mtlsRouter struct {
+	server *httptest.Server
+	mu     sync.Mutex
+	conns  []*websocket.Conn
+}
+
+func newMTLSRouter(t *testing.T, ca *testCA) *mtlsRouter {
+	t.Helper()
+	router := &mtlsRouter{}
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		router.mu.Lock()
+		router.conns = append(router.conns, conn)
+		router.mu.Unlock()
+		peer := r.TLS.PeerCertificates[0].Subject.CommonName
+		for {
+			var frame Frame
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+			if frame.Type == "completion_request" {
+				_ = conn.WriteJSON(Frame{Type: "completion_response", StreamID: frame.StreamID, MsgSeq: frame.MsgSeq, Payload: map[string]interface{}{"text": peer}})
+			}
+		}
+	}))
+
+	certPEM, keyPEM := ca.issue(t, "router", time.Now().Add(-time.Minute))
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("X509KeyPair failed: %v", err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	// Refused handshakes are expected
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	router.server = server
+	t.Cleanup(func() {
+		router.DropConnections()
+		server.Close()
+	})
+	return router
+}
+
+// URL returns the wss:// URL of the router
+func (r *mtlsRouter) URL() string {
+	return "wss" + strings.TrimPrefix(r.server.URL, "https")
+}
+
+// DropConnections closes every accepted connection
+func (r *mtlsRouter) DropConnections() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, conn := range r.conns {
+		_ = conn.Close()
+	}
+	r.conns = nil
+}
+
+func TestMutualTLSHandshake(t *testing.T) {
+	ca := newTestCA(t)
+	router := newMTLSRouter(t, ca)
+	certPEM, keyPEM := ca.issue(t, "tenant-a", time.Now().Add(-time.Minute))
+
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), ClientCertPEM: certPEM, ClientKeyPEM: keyPEM, CAPEM: ca.certPEM})
+	defer client.Close()
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.Text != "tenant-a" {
+		t.Errorf("Router saw client certificate %q", response.Text)
+	}
+	if !client.Introspect().Features["mtls"] {
+		t.Error("Introspection does not report mutual TLS")
+	}
+
+	// Without a client certificate the router refuses the handshake
+	client = NewATPClient(SDKConfig{WSURL: router.URL(), CAPEM: ca.certPEM})
+	defer client.Close()
+	if err := client.Connect(); !errors.Is(err, ErrConnectionFailed) {
+		t.Errorf("Expected the handshake to fail without a client certificate, got %v", err)
+	}
+}
+
+func TestMutualTLSCertificateRotation(t *testing.T) {
+	ca := newTestCA(t)
+	router := newMTLSRouter(t, ca)
+	dir := t.TempDir()
+	certFile, keyFile, caFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem"), filepath.Join(dir, "ca.pem")
+	writeIdentity := func(commonName string, modTime time.Time) {
+		certPEM, keyPEM := ca.issue(t, commonName, time.Now().Add(-time.Minute))
+		for file, data := range map[string][]byte{certFile: certPEM, keyFile: keyPEM, caFile: ca.certPEM} {
+			if err := os.WriteFile(file, data, 0o600); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+			// Distinct modification times, whatever the file system's
+			// timestamp resolution
+			_ = os.Chtimes(file, modTime, modTime)
+		}
+	}
+	writeIdentity("cert-1", time.Now().Add(-time.Hour))
+
+	disconnects := make(chan error, 2)
+	client := NewATPClient(SDKConfig{
+		WSURL:          router.URL(),
+		ClientCertFile: certFile,
+		ClientKeyFile:  keyFile,
+		CAFile:         caFile,
+		OnDisconnect:   func(err error) { disconnects <- err },
+	})
+	defer client.Close()
+	complete := func() string {
+		t.Helper()
+		response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+		if err != nil {
+			t.Fatalf("Complete failed: %v", err)
+		}
+		return response.Text
+	}
+	if got := complete(); got != "cert-1" {
+		t.Fatalf("Expected cert-1, got %q", got)
+	}
+
+	// The rotated certificate is used once the connection is redialled
+	writeIdentity("cert-2", time.Now())
+	if got := complete(); got != "cert-1" {
+		t.Errorf("The open connection must keep its certificate, got %q", got)
+	}
+	router.DropConnections()
+	<-disconnects
+	if got := complete(); got != "cert-2" {
+		t.Errorf("Expected the rotated certificate after reconnecting, got %q", got)
+	}
+
+	// A half-written rotation keeps the previous certificate in use
+	if err := os.WriteFile(keyFile, []byte("truncated"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if config, err := client.tlsConfig(); err != nil || config.Certificates[0].Leaf.Subject.CommonName != "cert-2" {
+		t.Errorf("Expected cert-2 to stay in use, got %v", err)
+	}
+}
+
+func TestMutualTLSLoadFailures(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, _ := ca.issue(t, "client", time.Now().Add(-time.Minute))
+	expiredPEM, expiredKeyPEM := ca.issue(t, "old", time.Now().Add(-2*time.Hour))
+	_, otherKeyPEM := ca.issue(t, "other", time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name    string
+		config  SDKConfig
+		message string
+	}{
+		{"missing file", SDKConfig{ClientCertFile: "/nonexistent/client.pem", ClientKeyFile: "/nonexistent/key.pem"}, "/nonexistent/client.pem"},
+		{"key mismatch", SDKConfig{ClientCertPEM: certPEM, ClientKeyPEM: otherKeyPEM}, "invalid client certificate or key"},
+		{"expired", SDKConfig{ClientCertPEM: expiredPEM, ClientKeyPEM: expiredKeyPEM}, `client certificate "old" expired at`},
+		{"certificate without key", SDKConfig{ClientCertPEM: certPEM}, "client certificate set without a client key"},
+		{"bad CA", SDKConfig{CAPEM: []byte("not PEM")}, "no certificates found in CA PEM"},
+		{"file and PEM", SDKConfig{CAFile: "ca.pem", CAPEM: ca.certPEM}, "CA set both as a file and as PEM"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			tt.config.Logger = logger
+			tt.config.Transport = TransportHTTP
+			client := NewATPClient(tt.config)
+			defer client.Close()
+
+			if len(logger.Lines()) != 1 {
+				t.Errorf("Expected NewATPClient to log the error, got %v", logger.Lines())
+			}
+			err := client.Connect()
+			if !errors.Is(err, ErrInvalidTLSConfig) || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Expected an ErrInvalidTLSConfig error mentioning %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package atpsdk
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before its expiry a token is refreshed.
+// Tokens living less than twice as long are refreshed halfway through.
+const tokenRefreshMargin = time.Minute
+
+// Token is an access token obtained from a TokenSource. It carries the
+// fields of golang.org/x/oauth2.Token the client needs.
+type Token struct {
+	AccessToken string
+	// Expiry is when the token expires. Zero means it does not.
+	Expiry time.Time
+}
+
+// TokenSource supplies the bearer tokens the client authenticates with. It
+// follows golang.org/x/oauth2.TokenSource semantics: Token returns a valid
+// token, possibly a cached one, and must be safe for concurrent use. An
+// oauth2.TokenSource is adapted with TokenSourceFunc:
+//
+//	atpsdk.TokenSourceFunc(func() (*atpsdk.Token, error) {
+//		t, err := src.Token()
+//		if err != nil {
+//			return nil, err
+//		}
+//		return &atpsdk.Token{AccessToken: t.AccessToken, Expiry: t.Expiry}, nil
+//	})
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// TokenSourceFunc adapts a function to the TokenSource interface
+type TokenSourceFunc func() (*Token, error)
+
+// Token calls f
+func (f TokenSourceFunc) Token() (*Token, error) {
+	return f()
+}
+
+// tokenState caches the current token of SDKConfig.TokenSource
+type tokenState struct {
+	mu    sync.Mutex
+	token *Token
+	// generation is bumped on every refresh, so that the refresh scheduled
+	// for a replaced token does nothing
+	generation uint64
+}
+
+// bearerToken returns the cached access token, fetching a new one when
+// there is none or it has expired
+func (c *ATPClient) bearerToken() (string, error) {
+	c.tokens.mu.Lock()
+	defer c.tokens.mu.Unlock()
+
+	token := c.tokens.token
+	if token != nil && (token.Expiry.IsZero() || c.config.Clock.Now().Before(token.Expiry)) {
+		return token.AccessToken, nil
+	}
+	token, err := c.fetchTokenLocked()
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// refreshToken fetches a new token regardless of the cached one
+func (c *ATPClient) refreshToken() (*Token, error) {
+	c.tokens.mu.Lock()
+	defer c.tokens.mu.Unlock()
+	return c.fetchTokenLocked()
+}
+
+// fetchTokenLocked asks the token source for a token, caches it and
+// schedules its refresh. The caller holds tokens.mu.
+func (c *ATPClient) fetchTokenLocked() (*Token, error) {
+	token, err := c.config.TokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("token source failed: %w", err)
+	}
+	if token == nil || token.AccessToken == "" {
+		return nil, errors.New("token source returned an empty token")
+	}
+	c.tokens.token = token
+	c.tokens.generation++
+	c.scheduleTokenRefresh(c.tokens.generation, token.Expiry)
+	return token, nil
+}
+
+// scheduleTokenRefresh refreshes the token shortly before expiry and
+// reauthenticates the connection with it, unless the token was replaced in
+// the meantime
+func (c *ATPClient) scheduleTokenRefresh(generation uint64, expiry time.Time) {
+	if expiry.IsZero() {
+		return
+	}
+	remaining := expiry.Sub(c.config.Clock.Now())
+	if remaining <= 0 {
+		return
+	}
+	wait := remaining - tokenRefreshMargin
+	if wait < remaining/2 {
+		wait = remaining / 2
+	}
+	refresh := c.config.Clock.After(wait)
+
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-refresh:
+		}
+		c.tokens.mu.Lock()
+		current := c.tokens.generation == generation
+		c.tokens.mu.Unlock()
+		if !current {
+			return
+		}
+		if _, err := c.refreshToken(); err != nil {
+			c.reportAsyncError(err)
+			return
+		}
+		c.reauthenticateAsync()
+	}()
+}
+
+// tokenExpired reports whether err is the router rejecting an expired
+// bearer token
+func (c *ATPClient) tokenExpired(err error) bool {
+	var atpErr *ATPError
+	return c.config.TokenSource != nil && errors.As(err, &atpErr) && atpErr.Code == ErrorCodeTokenExpired
+}
+
+// refreshExpiredToken forces a token refresh after the router reported the
+// token as expired, and reauthenticates the connection before returning
+func (c *ATPClient) refreshExpiredToken() error {
+	if _, err := c.refreshToken(); err != nil {
+		return err
+	}
+	if !c.IsConnected() || c.httpTransport.Load() {
+		return nil
+	}
+	return c.reauthenticate()
+}
@@ -0,0 +1,157 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingTokenSource hands out token-1, token-2, ... each valid for
+// lifetime on clock
+type countingTokenSource struct {
+	clock    Clock
+	lifetime time.Duration
+	calls    atomic.Int32
+}
+
+func (s *countingTokenSource) Token() (*Token, error) {
+	n := s.calls.Add(1)
+	return &Token{AccessToken: fmt.Sprintf("token-%d", n), Expiry: s.clock.Now().Add(s.lifetime)}, nil
+}
+
+// tokenRouter acknowledges reauth frames and answers completion requests
+// with a TOKEN_EXPIRED error until the connection has been reauthenticated
+// with a token in valid. It returns the bearer tokens presented in reauth
+// frames.
+func tokenRouter(t *testing.T, valid ...string) (*testRouter, func() []string) {
+	var mu sync.Mutex
+	var tokens []string
+	current := ""
+
+	router := newTestRouter(t, func(f Frame) []Frame {
+		mu.Lock()
+		defer mu.Unlock()
+		switch f.Type {
+		case FrameTypeReauth:
+			current = getString(f.Payload, "bearer_token", "")
+			tokens = append(tokens, current)
+			return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"status": "ack"}}}
+		case "completion_request":
+			for _, token := range valid {
+				if current == token {
+					return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}}}
+				}
+			}
+			return []Frame{NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeTokenExpired, "token expired")}
+		}
+		return nil
+	})
+	return router, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), tokens...)
+	}
+}
+
+func TestTokenSourceDialAndRefresh(t *testing.T) {
+	clock := newFakeClock()
+	source := &countingTokenSource{clock: clock, lifetime: 10 * time.Minute}
+	router, tokens := tokenRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), TokenSource: source, Clock: clock})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if got := router.DialHeader(0).Get("Authorization"); got != "Bearer token-1" {
+		t.Errorf("Expected the token as bearer header, got %q", got)
+	}
+
+	// The token is refreshed a minute before it expires
+	clock.Advance(8 * time.Minute)
+	if source.calls.Load() != 1 {
+		t.Fatal("Token refreshed too early")
+	}
+	clock.Advance(time.Minute)
+	if got := waitForReauths(t, tokens, 1); got[0] != "token-2" {
+		t.Errorf("Expected a reauth with token-2, got %v", got)
+	}
+
+	// An auth.expiring frame forces a refresh
+	if err := router.Send(Frame{Type: FrameTypeAuthExpiring}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got := waitForReauths(t, tokens, 2); got[1] != "token-3" {
+		t.Errorf("Expected a reauth with token-3, got %v", got)
+	}
+	if client.Stats().Connection.ConnectCount != 1 || router.ActiveConnections() != 1 {
+		t.Error("Token refreshes must not reconnect")
+	}
+}
+
+func TestExpiredTokenRetriedOnce(t *testing.T) {
+	clock := newFakeClock()
+	source := &countingTokenSource{clock: clock, lifetime: time.Hour}
+	router, _ := tokenRouter(t, "token-2")
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), TokenSource: source, Clock: clock})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Expected the request to succeed after a refresh, got %v", err)
+	}
+	if response.Text != "ok" || source.calls.Load() != 2 {
+		t.Errorf("Unexpected response %+v after %d token fetches", response, source.calls.Load())
+	}
+
+	// A token the router keeps rejecting is only refreshed once per request
+	router, tokens := tokenRouter(t)
+	client = NewATPClient(SDKConfig{WSURL: router.URL(), TokenSource: &countingTokenSource{clock: clock, lifetime: time.Hour}, Clock: clock})
+	defer client.Close()
+	_, err = client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeTokenExpired {
+		t.Fatalf("Expected the TOKEN_EXPIRED error to bubble up, got %v", err)
+	}
+	if got := tokens(); len(got) != 1 {
+		t.Errorf("Expected a single reauthentication, got %v", got)
+	}
+}
+
+func TestTokenSourceHTTPTransport(t *testing.T) {
+	var authorization atomic.Value
+	server := newHTTPRouter(t, "/v1/frames", func(r *http.Request, f Frame, w http.ResponseWriter) {
+		authorization.Store(r.Header.Get("Authorization"))
+		writeFrame(w, http.StatusOK, Frame{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{"text": "ok"}})
+	})
+
+	client := NewATPClient(SDKConfig{
+		BaseURL:     server.URL,
+		Transport:   TransportHTTP,
+		APIKey:      "static-key",
+		TokenSource: TokenSourceFunc(func() (*Token, error) { return &Token{AccessToken: "oidc-token"}, nil }),
+	})
+	defer client.Close()
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if got := authorization.Load(); got != "Bearer oidc-token" {
+		t.Errorf("Expected the token to take the Authorization header, got %v", got)
+	}
+}
+
+func TestTokenSourceFailure(t *testing.T) {
+	router, _ := tokenRouter(t)
+	client := NewATPClient(SDKConfig{
+		WSURL:       router.URL(),
+		TokenSource: TokenSourceFunc(func() (*Token, error) { return nil, errors.New("issuer unreachable") }),
+	})
+	defer client.Close()
+	if err := client.Connect(); !errors.Is(err, ErrConnectionFailed) {
+		t.Fatalf("Expected ErrConnectionFailed, got %v", err)
+	}
+}
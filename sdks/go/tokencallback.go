@@ -0,0 +1,113 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTokenCallbackTimeout is SDKConfig.TokenCallbackTimeout when unset
+const defaultTokenCallbackTimeout = time.Second
+
+// CompletionChunk is one partial response handed to a WithTokenCallback
+// callback
+type CompletionChunk struct {
+	// Text is the text generated since the previous chunk
+	Text string
+	// Index is the position of the chunk in the response, from 0
+	Index int
+	// Final is set on the last chunk, which ends the response
+	Final bool
+}
+
+// tokenCallbackKey is the context key of the callback set with
+// WithTokenCallback
+type tokenCallbackKey struct{}
+
+// contextWithTokenCallback returns ctx carrying callback for the responses
+// of a request
+func contextWithTokenCallback(ctx context.Context, callback func(CompletionChunk) error) context.Context {
+	if callback == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tokenCallbackKey{}, callback)
+}
+
+// chunkCollector hands the chunks of a streamed completion response to a
+// token callback and keeps their text. While the response is handed off
+// between pool connections, both may deliver chunks; those already
+// delivered are skipped.
+type chunkCollector struct {
+	callback func(CompletionChunk) error
+	timeout  time.Duration
+	clock    Clock
+
+	mu      sync.Mutex
+	text    strings.Builder // of the chunks before the final one
+	ended   bool            // the final chunk was delivered, or a callback failed
+	fragSeq int             // of the next fragment expected
+}
+
+// newChunkCollector returns the collector for the token callback of ctx,
+// or nil when there is none
+func (c *ATPClient) newChunkCollector(ctx context.Context) *chunkCollector {
+	callback, _ := ctx.Value(tokenCallbackKey{}).(func(CompletionChunk) error)
+	if callback == nil {
+		return nil
+	}
+	return &chunkCollector{callback: callback, timeout: c.config.TokenCallbackTimeout, clock: c.config.Clock}
+}
+
+// deliver hands a completion_response frame to the callback. It reports
+// whether the frame ends the response, and fails with an error matching
+// ErrTokenCallback when the callback fails or takes longer than the
+// timeout.
+func (cc *chunkCollector) deliver(frame *Frame) (bool, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	fragment := slices.Contains(frame.Flags, FlagFragment)
+	if fragment && frame.FragSeq < cc.fragSeq {
+		// Sent again after a handoff
+		return false, nil
+	}
+	final := !fragment || slices.Contains(frame.Flags, FlagLast)
+	if cc.ended {
+		return final, nil
+	}
+	chunk := CompletionChunk{Text: getString(frame.Payload, "text", ""), Index: frame.FragSeq, Final: final}
+
+	// The read loop waits for the callback, but only so long
+	done := make(chan error, 1)
+	go func() { done <- cc.callback(chunk) }()
+	var err error
+	select {
+	case err = <-done:
+		if err != nil {
+			err = fmt.Errorf("%w: %w", ErrTokenCallback, err)
+		}
+	case <-cc.clock.After(cc.timeout):
+		err = fmt.Errorf("%w: chunk %d not handled within %v", ErrTokenCallback, chunk.Index, cc.timeout)
+	}
+	if err != nil {
+		cc.ended = true
+		return final, err
+	}
+	cc.fragSeq = frame.FragSeq + 1
+	if final {
+		cc.ended = true
+	} else {
+		cc.text.WriteString(chunk.Text)
+	}
+	return final, nil
+}
+
+// lastFragSeq returns the frag_seq of the last fragment delivered, or -1
+// when none was
+func (cc *chunkCollector) lastFragSeq() int {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.fragSeq - 1
+}
@@ -0,0 +1,89 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// streamingRouter answers completion requests with three chunks, the last
+// one a full response, and forwards cancel frames to the returned channel
+func streamingRouter(t *testing.T) (*testRouter, chan Frame) {
+	cancels := make(chan Frame, 1)
+	fb := NewFrameBuilder("", "")
+	router := newTestRouter(t, func(f Frame) []Frame {
+		switch f.Type {
+		case FrameTypeCancel:
+			cancels <- f
+		case FrameTypeCompletionRequest:
+			final := fb.BuildCompletionResponseFrame(f.StreamID, f.MsgSeq, CompletionResponse{Text: "!", ModelUsed: "m", Finished: true})
+			final.FragSeq = 2
+			final.Flags = []string{FlagFragment, FlagLast}
+			return []Frame{
+				fb.BuildCompletionChunkFrame(f.StreamID, f.MsgSeq, 0, "Hello"),
+				fb.BuildCompletionChunkFrame(f.StreamID, f.MsgSeq, 1, ", world"),
+				final,
+			}
+		}
+		return nil
+	})
+	return router, cancels
+}
+
+func TestCompleteWithTokenCallback(t *testing.T) {
+	router, _ := streamingRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	var chunks []CompletionChunk
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithTokenCallback(func(chunk CompletionChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if response.Text != "Hello, world!" || response.ModelUsed != "m" {
+		t.Errorf("Expected the aggregated response, got %+v", response)
+	}
+	want := []CompletionChunk{{Text: "Hello", Index: 0}, {Text: ", world", Index: 1}, {Text: "!", Index: 2, Final: true}}
+	if len(chunks) != len(want) {
+		t.Fatalf("Expected %d chunks, got %+v", len(want), chunks)
+	}
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Errorf("Chunk %d: expected %+v, got %+v", i, want[i], chunks[i])
+		}
+	}
+}
+
+func TestTokenCallbackAbortsStream(t *testing.T) {
+	router, cancels := streamingRouter(t)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), TokenCallbackTimeout: 50 * time.Millisecond, Logger: &recordingLogger{}})
+	defer client.Close()
+
+	stop := errors.New("client went away")
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithTokenCallback(func(chunk CompletionChunk) error {
+		return stop
+	}))
+	if !errors.Is(err, ErrTokenCallback) || !errors.Is(err, stop) {
+		t.Errorf("Expected the callback's error, got %v", err)
+	}
+	select {
+	case <-cancels:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a cancel frame")
+	}
+
+	// A callback outlasting the timeout is abandoned
+	release := make(chan struct{})
+	defer close(release)
+	_, err = client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}, WithTokenCallback(func(chunk CompletionChunk) error {
+		<-release
+		return nil
+	}))
+	if !errors.Is(err, ErrTokenCallback) {
+		t.Errorf("Expected a callback timeout, got %v", err)
+	}
+}
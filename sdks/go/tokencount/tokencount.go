@@ -0,0 +1,122 @@
+// Package tokencount estimates how many tokens a text takes for a model,
+// before it is sent to the router:
+//
+//	n := tokencount.Count(prompt, "llama3-70b")
+//
+// Without a registered Tokenizer the count is a heuristic: four bytes per
+// token, scaled by a per-model factor. Register an exact tokenizer for the
+// models that need one:
+//
+//	tokencount.Register("gpt-4", tiktokenCounter)
+package tokencount
+
+import (
+	"math"
+	"strings"
+	"sync"
+)
+
+// Tokenizer counts the tokens of a text for the models it is registered
+// for. Implementations must be safe for concurrent use.
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// TokenizerFunc adapts a function to the Tokenizer interface
+type TokenizerFunc func(text string) int
+
+// CountTokens calls f(text)
+func (f TokenizerFunc) CountTokens(text string) int {
+	return f(text)
+}
+
+// BytesPerToken is the average token length the heuristic assumes
+const BytesPerToken = 4
+
+// registry maps model name prefixes to tokenizers and heuristic factors.
+// The longest matching prefix wins.
+var registry = struct {
+	sync.RWMutex
+	tokenizers map[string]Tokenizer
+	factors    map[string]float64
+}{
+	tokenizers: map[string]Tokenizer{},
+	factors: map[string]float64{
+		// Vocabularies that split text into more, shorter tokens than the
+		// four-bytes rule of thumb
+		"llama2":  1.15,
+		"mistral": 1.1,
+		"gemma":   1.05,
+		// Larger vocabularies
+		"llama3": 0.95,
+		"gpt-4o": 0.9,
+	},
+}
+
+// EstimateTokens estimates the tokens of text for model with the heuristic:
+// its length in bytes divided by BytesPerToken, scaled by the factor set
+// for the model with SetFactor (default: 1), rounded up. Multi-byte UTF-8
+// text counts each byte, which suits scripts whose characters take a token
+// or more each.
+func EstimateTokens(text, model string) int {
+	if text == "" {
+		return 0
+	}
+	registry.RLock()
+	factor, ok := lookup(registry.factors, model)
+	registry.RUnlock()
+	if !ok {
+		factor = 1
+	}
+	return int(math.Ceil(float64(len(text)) / BytesPerToken * factor))
+}
+
+// Count counts the tokens of text for model, with the Tokenizer registered
+// for it if any, and EstimateTokens otherwise
+func Count(text, model string) int {
+	registry.RLock()
+	tokenizer, ok := lookup(registry.tokenizers, model)
+	registry.RUnlock()
+	if ok {
+		return tokenizer.CountTokens(text)
+	}
+	return EstimateTokens(text, model)
+}
+
+// Register makes Count use tokenizer for the models whose name starts with
+// prefix. A nil tokenizer removes the registration.
+func Register(prefix string, tokenizer Tokenizer) {
+	registry.Lock()
+	defer registry.Unlock()
+	if tokenizer == nil {
+		delete(registry.tokenizers, prefix)
+		return
+	}
+	registry.tokenizers[prefix] = tokenizer
+}
+
+// SetFactor sets the factor EstimateTokens scales its estimate by for the
+// models whose name starts with prefix. A factor of zero or less removes
+// it.
+func SetFactor(prefix string, factor float64) {
+	registry.Lock()
+	defer registry.Unlock()
+	if factor <= 0 {
+		delete(registry.factors, prefix)
+		return
+	}
+	registry.factors[prefix] = factor
+}
+
+// lookup returns the entry of entries with the longest prefix of model.
+// The caller holds the registry lock.
+func lookup[T any](entries map[string]T, model string) (T, bool) {
+	var best T
+	bestLen := -1
+	for prefix, entry := range entries {
+		if len(prefix) > bestLen && strings.HasPrefix(model, prefix) {
+			best, bestLen = entry, len(prefix)
+		}
+	}
+	return best, bestLen >= 0
+}
@@ -0,0 +1,65 @@
+package tokencount
+
+import "testing"
+
+func TestEstimateTokensCountsBytes(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"hello world!", 3},
+		// é takes two bytes, so "héllo" is six bytes
+		{"héllo", 2},
+		// Each CJK character takes three bytes
+		{"你好世界", 3},
+		// 😀 takes four bytes
+		{"😀😀", 2},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text, ""); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateTokensModelFactors(t *testing.T) {
+	text := "0123456789012345678901234567890123456789" // 40 bytes: 10 tokens
+	if got := EstimateTokens(text, "unknown-model"); got != 10 {
+		t.Errorf("Expected 10 tokens for an unknown model, got %d", got)
+	}
+	if got := EstimateTokens(text, "llama2-13b"); got != 12 {
+		t.Errorf("Expected 12 tokens for llama2, got %d", got)
+	}
+	if got := EstimateTokens(text, "gpt-4o-mini"); got != 9 {
+		t.Errorf("Expected 9 tokens for gpt-4o, got %d", got)
+	}
+
+	// The longest prefix wins
+	SetFactor("llama2-13b", 2)
+	defer SetFactor("llama2-13b", 0)
+	if got := EstimateTokens(text, "llama2-13b-chat"); got != 20 {
+		t.Errorf("Expected the longer prefix's factor, got %d tokens", got)
+	}
+	if got := EstimateTokens(text, "llama2-7b"); got != 12 {
+		t.Errorf("Expected the llama2 factor, got %d tokens", got)
+	}
+}
+
+func TestCountUsesRegisteredTokenizer(t *testing.T) {
+	runes := TokenizerFunc(func(text string) int { return len([]rune(text)) })
+	Register("exact-", runes)
+
+	if got := Count("你好世界", "exact-model"); got != 4 {
+		t.Errorf("Expected the tokenizer's count of 4, got %d", got)
+	}
+	if got := Count("你好世界", "other-model"); got != 3 {
+		t.Errorf("Expected the estimate of 3 for an unregistered model, got %d", got)
+	}
+
+	Register("exact-", nil)
+	if got := Count("你好世界", "exact-model"); got != 3 {
+		t.Errorf("Expected the estimate after unregistering, got %d", got)
+	}
+}
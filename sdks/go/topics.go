@@ -0,0 +1,306 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Frame types used for topic broadcasts and their server-side registration
+const (
+	FrameTypeBroadcast   = "broadcast"
+	FrameTypeSubscribe   = "subscribe"
+	FrameTypeUnsubscribe = "unsubscribe"
+)
+
+// BroadcastMessage is a message published by the router on a topic
+type BroadcastMessage struct {
+	Topic string
+	// Payload is the broadcast frame's payload without the topic field
+	Payload map[string]interface{}
+	// Timestamp is when the router sent the message
+	Timestamp time.Time
+}
+
+// topicRegistrationState records whether the router accepts subscribe
+// frames on the current connection
+type topicRegistrationState int
+
+const (
+	topicRegistrationUnknown topicRegistrationState = iota
+	topicRegistrationSupported
+	topicRegistrationUnsupported
+)
+
+// topicRegistry tracks the topic patterns subscribed on a client and their
+// registration with the router
+type topicRegistry struct {
+	mu         sync.Mutex
+	patterns   map[string]int // pattern -> number of subscribers
+	registered map[string]bool
+	state      topicRegistrationState
+	connection uint64 // connect count the registrations belong to
+	changed    chan struct{}
+	start      sync.Once
+}
+
+// SubscribeTopic returns a channel receiving the broadcast messages whose
+// topic matches pattern, and a function that unsubscribes and closes the
+// channel. Patterns are dot-separated; "*" matches exactly one segment and
+// "**" matches any number of segments, so "models.*" matches
+// "models.deprecated" and "models.**" also matches "models.gpt-4.deprecated".
+//
+// When the router accepts "subscribe" frames the patterns are registered
+// with it, and re-registered after every reconnect; otherwise messages are
+// filtered locally. The channel is closed when ctx is cancelled, on
+// unsubscribe, or when the client is closed. Messages are dropped and
+// counted in MetricSubscriptionDrops when the subscriber falls behind.
+func (c *ATPClient) SubscribeTopic(ctx context.Context, pattern string) (<-chan BroadcastMessage, func()) {
+	out := make(chan BroadcastMessage)
+	frames, unsubscribe := c.Subscribe(FrameTypeBroadcast)
+	if c.closed() {
+		unsubscribe()
+		close(out)
+		return out, func() {}
+	}
+
+	c.topics.start.Do(func() { go c.syncTopicsLoop() })
+	c.retainTopic(pattern)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() { close(stop) })
+		<-done
+	}
+
+	go func() {
+		defer close(done)
+		defer close(out)
+		defer c.releaseTopic(pattern)
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case frame, ok := <-frames:
+				if !ok {
+					return
+				}
+				msg := broadcastMessage(frame)
+				if !topicMatches(pattern, msg.Topic) {
+					continue
+				}
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+					return
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
+
+	return out, cancel
+}
+
+// broadcastMessage converts a broadcast frame into a BroadcastMessage
+func broadcastMessage(frame *Frame) BroadcastMessage {
+	msg := BroadcastMessage{
+		Topic:   getString(frame.Payload, "topic", ""),
+		Payload: make(map[string]interface{}, len(frame.Payload)),
+	}
+	for k, v := range frame.Payload {
+		if k != "topic" {
+			msg.Payload[k] = v
+		}
+	}
+	if frame.Timestamp != 0 {
+		msg.Timestamp = frame.Time()
+	}
+	return msg
+}
+
+// topicMatches reports whether topic matches pattern
+func topicMatches(pattern, topic string) bool {
+	return matchSegments(strings.Split(pattern, "."), strings.Split(topic, "."))
+}
+
+func matchSegments(pattern, topic []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "**":
+			for i := 0; i <= len(topic); i++ {
+				if matchSegments(pattern[1:], topic[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(topic) == 0 {
+				return false
+			}
+		default:
+			if len(topic) == 0 || topic[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, topic = pattern[1:], topic[1:]
+	}
+	return len(topic) == 0
+}
+
+// retainTopic adds a subscriber for pattern
+func (c *ATPClient) retainTopic(pattern string) {
+	c.topics.mu.Lock()
+	if c.topics.patterns == nil {
+		c.topics.patterns = make(map[string]int)
+	}
+	c.topics.patterns[pattern]++
+	c.topics.mu.Unlock()
+	c.topicsChanged()
+}
+
+// releaseTopic removes a subscriber for pattern
+func (c *ATPClient) releaseTopic(pattern string) {
+	c.topics.mu.Lock()
+	c.topics.patterns[pattern]--
+	if c.topics.patterns[pattern] <= 0 {
+		delete(c.topics.patterns, pattern)
+	}
+	c.topics.mu.Unlock()
+	c.topicsChanged()
+}
+
+// topicsChanged wakes the registration loop without blocking
+func (c *ATPClient) topicsChanged() {
+	c.topics.mu.Lock()
+	if c.topics.changed == nil {
+		c.topics.changed = make(chan struct{}, 1)
+	}
+	changed := c.topics.changed
+	c.topics.mu.Unlock()
+
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
+
+// syncTopicsLoop keeps the router's topic registrations in line with the
+// subscribed patterns until the client is closed
+func (c *ATPClient) syncTopicsLoop() {
+	connects, unwatch := c.watchConnects()
+	defer unwatch()
+
+	c.topics.mu.Lock()
+	if c.topics.changed == nil {
+		c.topics.changed = make(chan struct{}, 1)
+	}
+	changed := c.topics.changed
+	c.topics.mu.Unlock()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-connects:
+		case <-changed:
+		}
+		c.syncTopics()
+	}
+}
+
+// syncTopics registers new patterns with the router and unregisters
+// patterns without subscribers, detecting on first use whether the router
+// accepts registrations at all
+func (c *ATPClient) syncTopics() {
+	if !c.IsConnected() {
+		return
+	}
+
+	c.topics.mu.Lock()
+	if connection := c.counters.connects.Load(); connection != c.topics.connection {
+		// A new connection has no registrations, and may be to a router
+		// with different capabilities
+		c.topics.connection = connection
+		c.topics.registered = nil
+		c.topics.state = topicRegistrationUnknown
+	}
+	if c.topics.state == topicRegistrationUnsupported {
+		c.topics.mu.Unlock()
+		return
+	}
+	if c.topics.registered == nil {
+		c.topics.registered = make(map[string]bool)
+	}
+	var add, remove []string
+	for pattern := range c.topics.patterns {
+		if !c.topics.registered[pattern] {
+			add = append(add, pattern)
+		}
+	}
+	for pattern := range c.topics.registered {
+		if _, ok := c.topics.patterns[pattern]; !ok {
+			remove = append(remove, pattern)
+		}
+	}
+	c.topics.mu.Unlock()
+	sort.Strings(add)
+	sort.Strings(remove)
+
+	if len(add) > 0 {
+		c.registerTopics(FrameTypeSubscribe, add, true)
+	}
+	if len(remove) > 0 {
+		c.registerTopics(FrameTypeUnsubscribe, remove, false)
+	}
+}
+
+// registerTopics sends a subscribe or unsubscribe frame and records the
+// outcome. An error reply means the router filters nothing server-side, so
+// the client falls back to local filtering for the rest of the connection.
+func (c *ATPClient) registerTopics(frameType string, topics []string, subscribed bool) {
+	streamID := c.newStreamID("topics")
+	frame := c.newFrameBuilder(c.config.TenantID).BuildTopicFrame(frameType, streamID, topics)
+
+	pending := c.expectResponse(frame)
+	if err := c.sendFrame(frame); err != nil {
+		// Retried on the next change or reconnect
+		c.discardPending(pending, false)
+		return
+	}
+	reply, err := c.waitForResponse(c.ctx, pending)
+	if err == nil && reply.Type == FrameTypeError {
+		err = parseErrorFrame(reply)
+	}
+
+	c.topics.mu.Lock()
+	defer c.topics.mu.Unlock()
+
+	var atpErr *ATPError
+	switch {
+	case err == nil:
+		c.topics.state = topicRegistrationSupported
+		for _, topic := range topics {
+			if subscribed {
+				c.topics.registered[topic] = true
+			} else {
+				delete(c.topics.registered, topic)
+			}
+		}
+	case errors.As(err, &atpErr) && c.topics.state == topicRegistrationUnknown:
+		c.topics.state = topicRegistrationUnsupported
+	case c.topics.state == topicRegistrationUnknown && !c.closed():
+		c.config.Logger.Printf("Warning: Topic registration not acknowledged, filtering locally: %v", err)
+		c.topics.state = topicRegistrationUnsupported
+	}
+}
@@ -0,0 +1,195 @@
+package atpsdk
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTopicMatches(t *testing.T) {
+	cases := []struct {
+		pattern, topic string
+		want           bool
+	}{
+		{"models.deprecated", "models.deprecated", true},
+		{"models.deprecated", "models.added", false},
+		{"models.*", "models.deprecated", true},
+		{"models.*", "models", false},
+		{"models.*", "models.gpt-4.deprecated", false},
+		{"models.**", "models.gpt-4.deprecated", true},
+		{"models.**", "models", true},
+		{"*.deprecated", "models.deprecated", true},
+		{"**.deprecated", "fleet.models.deprecated", true},
+		{"**", "anything.at.all", true},
+		{"fleet.*", "models.announce", false},
+	}
+	for _, tc := range cases {
+		if got := topicMatches(tc.pattern, tc.topic); got != tc.want {
+			t.Errorf("topicMatches(%q, %q) = %v, want %v", tc.pattern, tc.topic, got, tc.want)
+		}
+	}
+}
+
+// topicRouter acknowledges subscribe and unsubscribe frames when supported,
+// and rejects them like an unknown frame type otherwise
+type topicRouter struct {
+	*testRouter
+	mu     sync.Mutex
+	frames []Frame
+}
+
+func newTopicRouter(t *testing.T, supported bool) *topicRouter {
+	r := &topicRouter{}
+	r.testRouter = newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeSubscribe && f.Type != FrameTypeUnsubscribe {
+			return nil
+		}
+		r.mu.Lock()
+		r.frames = append(r.frames, f)
+		r.mu.Unlock()
+		if !supported {
+			return []Frame{NewFrameBuilder("", "").BuildErrorFrame(f.StreamID, f.MsgSeq, ErrorCodeInvalidRequest, "unknown frame type")}
+		}
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Payload: map[string]interface{}{}}}
+	})
+	return r
+}
+
+// Registrations returns the subscribe and unsubscribe frames received
+func (r *topicRouter) Registrations() []Frame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Frame(nil), r.frames...)
+}
+
+func (r *topicRouter) waitForRegistrations(t *testing.T, n int) []Frame {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(r.Registrations()) < n && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+	frames := r.Registrations()
+	if len(frames) < n {
+		t.Fatalf("Expected %d registration frame(s), got %d", n, len(frames))
+	}
+	return frames
+}
+
+func broadcast(topic string, ts FrameTime) Frame {
+	return Frame{Type: FrameTypeBroadcast, Timestamp: ts, Payload: map[string]interface{}{"topic": topic, "model": "gpt-3"}}
+}
+
+func TestSubscribeTopicLocalFiltering(t *testing.T) {
+	router := newTopicRouter(t, false)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+
+	messages, unsubscribe := client.SubscribeTopic(context.Background(), "models.*")
+	defer unsubscribe()
+	router.waitForRegistrations(t, 1)
+
+	_ = router.Send(broadcast("fleet.announce", 1))
+	_ = router.Send(broadcast("models.deprecated", 1700000000123))
+
+	select {
+	case msg := <-messages:
+		if msg.Topic != "models.deprecated" || msg.Payload["model"] != "gpt-3" || msg.Payload["topic"] != nil {
+			t.Errorf("Unexpected message: %+v", msg)
+		}
+		if !msg.Timestamp.Equal(time.UnixMilli(1700000000123)) {
+			t.Errorf("Unexpected timestamp %v", msg.Timestamp)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Matching broadcast not delivered")
+	}
+	select {
+	case msg := <-messages:
+		t.Errorf("Unexpected extra message %+v", msg)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// The rejected registration is not retried on the same connection
+	unsubscribe()
+	time.Sleep(20 * time.Millisecond)
+	if frames := router.Registrations(); len(frames) != 1 {
+		t.Errorf("Expected a single registration attempt, got %d", len(frames))
+	}
+}
+
+func TestSubscribeTopicResubscribesAfterReconnect(t *testing.T) {
+	router := newTopicRouter(t, true)
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	messages, unsubscribe := client.SubscribeTopic(context.Background(), "models.**")
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	frames := router.waitForRegistrations(t, 1)
+	if topics, _ := frames[0].Payload["topics"].([]interface{}); frames[0].Type != FrameTypeSubscribe || len(topics) != 1 || topics[0] != "models.**" {
+		t.Errorf("Unexpected subscribe frame: %+v", frames[0])
+	}
+
+	router.DropConnections()
+	deadline := time.Now().Add(2 * time.Second)
+	for client.IsConnected() && time.Now().Before(deadline) {
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	frames = router.waitForRegistrations(t, 2)
+	if frames[1].Type != FrameTypeSubscribe {
+		t.Errorf("Expected re-subscription after reconnect, got %s", frames[1].Type)
+	}
+
+	router.WaitForConnections(t, 1)
+	_ = router.Send(broadcast("models.gpt-4.deprecated", 0))
+	select {
+	case msg := <-messages:
+		if msg.Topic != "models.gpt-4.deprecated" {
+			t.Errorf("Unexpected topic %q", msg.Topic)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Broadcast not delivered after reconnect")
+	}
+
+	// Unsubscribing closes the channel, unregisters the topic and removes
+	// the underlying frame subscription
+	unsubscribe()
+	if _, ok := <-messages; ok {
+		t.Error("Expected channel to be closed after unsubscribe")
+	}
+	frames = router.waitForRegistrations(t, 3)
+	if frames[2].Type != FrameTypeUnsubscribe {
+		t.Errorf("Expected an unsubscribe frame, got %s", frames[2].Type)
+	}
+	client.subMutex.RLock()
+	remaining := len(client.subscriptions[FrameTypeBroadcast])
+	client.subMutex.RUnlock()
+	if remaining != 0 {
+		t.Errorf("Expected no broadcast subscriptions left, got %d", remaining)
+	}
+}
+
+func TestSubscribeTopicContextCancel(t *testing.T) {
+	client := NewATPClient(SDKConfig{})
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages, _ := client.SubscribeTopic(ctx, "fleet.*")
+	cancel()
+
+	select {
+	case _, ok := <-messages:
+		if ok {
+			t.Error("Expected channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Context cancellation did not close the channel")
+	}
+}
@@ -0,0 +1,112 @@
+package atpsdk
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand/v2"
+	"strings"
+)
+
+// TraceContext is the W3C trace context carried in Meta.Trace. It lets the
+// logs of the client, the router and the adapters be joined on the trace
+// ID of a request.
+type TraceContext struct {
+	TraceParent string `json:"traceparent,omitempty"`
+	TraceState  string `json:"tracestate,omitempty"`
+}
+
+// NewTraceContext returns a TraceContext starting a new, sampled trace with
+// random trace and span IDs
+func NewTraceContext() *TraceContext {
+	var ids [24]byte
+	binary.BigEndian.PutUint64(ids[0:], nonZero())
+	binary.BigEndian.PutUint64(ids[8:], rand.Uint64())
+	binary.BigEndian.PutUint64(ids[16:], nonZero())
+
+	traceParent := make([]byte, 0, 55)
+	traceParent = append(traceParent, "00-"...)
+	traceParent = hex.AppendEncode(traceParent, ids[:16])
+	traceParent = append(traceParent, '-')
+	traceParent = hex.AppendEncode(traceParent, ids[16:])
+	traceParent = append(traceParent, "-01"...)
+	return &TraceContext{TraceParent: string(traceParent)}
+}
+
+// TraceID returns the trace ID of TraceParent, or "" when it is not a
+// traceparent
+func (t *TraceContext) TraceID() string {
+	if t == nil {
+		return ""
+	}
+	parts := strings.Split(t.TraceParent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	return parts[1]
+}
+
+// traceParent returns TraceParent, or "" for a nil t
+func (t *TraceContext) traceParent() string {
+	if t == nil {
+		return ""
+	}
+	return t.TraceParent
+}
+
+// nonZero returns a random non-zero integer, as trace and span IDs must not
+// be all zeros
+func nonZero() uint64 {
+	for {
+		if n := rand.Uint64(); n != 0 {
+			return n
+		}
+	}
+}
+
+// streamTrace returns the trace of the frames of streamID: supplied when
+// set, else the one of the stream's earlier frames, else a new one. The
+// first trace of a stream is kept with its msg_seq counter, so it is
+// forgotten with ReleaseStream.
+func (fb *FrameBuilder) streamTrace(streamID string, supplied *TraceContext) *TraceContext {
+	s := fb.counters
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.byStream[streamKey{fb.tenantID, streamID}]
+	if !ok {
+		if supplied != nil {
+			return supplied
+		}
+		return NewTraceContext()
+	}
+	counter := e.Value.(*streamSeq)
+	if counter.trace == nil {
+		counter.trace = supplied
+		if counter.trace == nil {
+			counter.trace = NewTraceContext()
+		}
+	}
+	if supplied != nil {
+		return supplied
+	}
+	return counter.trace
+}
+
+// streamMeta is withMeta for a frame opening or continuing streamID, with
+// the stream's trace filled in unless the overrides carry one
+func (fb *FrameBuilder) streamMeta(streamID string, base Meta, overrides []Meta) Meta {
+	meta := withMeta(base, overrides)
+	meta.Trace = fb.streamTrace(streamID, meta.Trace)
+	return meta
+}
+
+// ensureTrace returns meta with a new trace appended unless one of them
+// carries a trace already, so that every attempt of a request shares it
+func ensureTrace(meta []Meta) []Meta {
+	for _, m := range meta {
+		if m.Trace != nil {
+			return meta
+		}
+	}
+	return append(meta[:len(meta):len(meta)], Meta{Trace: NewTraceContext()})
+}
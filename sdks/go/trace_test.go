@@ -0,0 +1,76 @@
+package atpsdk
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+var traceParentPattern = regexp.MustCompile(`^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`)
+
+func TestStreamTrace(t *testing.T) {
+	fb := NewFrameBuilder("s1", "acme")
+
+	first := fb.BuildCompletionFrame("stream-1", CompletionRequest{Prompt: "a"})
+	if !traceParentPattern.MatchString(first.Meta.Trace.TraceParent) {
+		t.Fatalf("Expected a generated traceparent, got %+v", first.Meta.Trace)
+	}
+	if again := fb.BuildCompletionFrame("stream-1", CompletionRequest{Prompt: "b"}); again.Meta.Trace.TraceParent != first.Meta.Trace.TraceParent {
+		t.Errorf("Expected the stream's frames to share a trace, got %s and %s", first.Meta.Trace.TraceParent, again.Meta.Trace.TraceParent)
+	}
+	if other := fb.BuildCompletionFrame("stream-2", CompletionRequest{Prompt: "c"}); other.Meta.Trace.TraceID() == first.Meta.Trace.TraceID() {
+		t.Error("Expected another stream to start another trace")
+	}
+
+	// A supplied trace is used and kept for the stream's later frames
+	supplied := &TraceContext{TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", TraceState: "vendor=1"}
+	fb.BuildHealthFrame("stream-3", HealthStatus{}, Meta{Trace: supplied})
+	if frame := fb.BuildHealthFrame("stream-3", HealthStatus{}); *frame.Meta.Trace != *supplied {
+		t.Errorf("Expected the supplied trace, got %+v", frame.Meta.Trace)
+	}
+	if id := supplied.TraceID(); id != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Unexpected trace ID %q", id)
+	}
+}
+
+func TestCompletionTrace(t *testing.T) {
+	var mu sync.Mutex
+	var requests []Frame
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != "completion_request" {
+			return nil
+		}
+		mu.Lock()
+		requests = append(requests, f)
+		attempt := len(requests)
+		mu.Unlock()
+		if attempt == 1 {
+			return []Frame{{Type: "error", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Meta: Meta{Trace: f.Meta.Trace},
+				Payload: map[string]interface{}{"error": map[string]interface{}{"code": ErrorCodeAdapterOverloaded, "message": "busy"}}}}
+		}
+		return []Frame{{Type: "completion_response", StreamID: f.StreamID, MsgSeq: f.MsgSeq, Meta: Meta{Trace: f.Meta.Trace},
+			Payload: map[string]interface{}{"text": "ok"}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), RetryDelay: time.Millisecond})
+	defer client.Close()
+
+	response, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("Expected a retry, got %d requests", len(requests))
+	}
+	traceID := requests[0].Meta.Trace.TraceID()
+	if traceID == "" || requests[1].Meta.Trace.TraceID() != traceID {
+		t.Errorf("Expected the retry to share the trace, got %+v and %+v", requests[0].Meta.Trace, requests[1].Meta.Trace)
+	}
+	if response.TraceParent != requests[1].Meta.Trace.TraceParent {
+		t.Errorf("Expected the response's traceparent %q, got %q", requests[1].Meta.Trace.TraceParent, response.TraceParent)
+	}
+}
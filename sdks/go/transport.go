@@ -0,0 +1,282 @@
+package atpsdk
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Transport is one bidirectional connection to the router carrying
+// serialized frames. Everything above it (frame building, interceptors,
+// response matching, heartbeats) is shared by all transports.
+//
+// The client calls Send from one goroutine at a time and Receive from a
+// single read loop; Close may be called concurrently with both and must
+// unblock them.
+type Transport interface {
+	// Send delivers one JSON-encoded frame to the router.
+	Send(frame []byte) error
+	// Receive blocks until the next JSON-encoded frame arrives.
+	Receive() ([]byte, error)
+	// Close tears the connection down.
+	Close() error
+}
+
+// DialTarget describes the router a transport connects to
+type DialTarget struct {
+	// Address is SDKConfig.TransportAddress, or WSURL when that is unset
+	Address   string
+	SessionID string
+	TenantID  string
+	APIKey    string
+	// BearerToken is the access token from SDKConfig.TokenSource, if any
+	BearerToken string
+	// TLSConfig is the TLS configuration built from the SDKConfig TLS
+	// options, or nil when none is set
+	TLSConfig *tls.Config
+	// WriteTimeout and ReadTimeout are SDKConfig.WriteTimeout and
+	// ReadTimeout. Transports honoring them should fail timed out
+	// operations with errors matching ErrWriteTimeout and ErrReadTimeout.
+	WriteTimeout time.Duration
+	ReadTimeout  time.Duration
+	// DialTimeout and HandshakeTimeout are SDKConfig.DialTimeout and
+	// HandshakeTimeout
+	DialTimeout      time.Duration
+	HandshakeTimeout time.Duration
+	// ProtocolVersions are the protocol versions the client speaks,
+	// preferred first, offered to the router when connecting
+	ProtocolVersions []string
+	// MaxFrameBytes is SDKConfig.MaxFrameBytes, or zero for no limit.
+	// Transports honoring it should fail reads of larger frames with an
+	// error matching ErrFrameTooLarge.
+	MaxFrameBytes int
+}
+
+// defaultDialTimeout is the default of SDKConfig.DialTimeout and
+// HandshakeTimeout
+const defaultDialTimeout = 10 * time.Second
+
+// DialFunc opens a Transport to target
+type DialFunc func(ctx context.Context, target DialTarget) (Transport, error)
+
+var (
+	transportsMu sync.RWMutex
+	transports   = make(map[string]DialFunc)
+)
+
+// RegisterTransport makes a transport selectable by name with
+// SDKConfig.Transport. Transport packages call it from init, like
+// database/sql drivers. It panics if name is already registered.
+func RegisterTransport(name string, dial DialFunc) {
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	switch name {
+	case TransportWebSocket, TransportHTTP, TransportAuto:
+		panic(fmt.Sprintf("atpsdk: transport %q is built in", name))
+	}
+	if _, exists := transports[name]; exists {
+		panic(fmt.Sprintf("atpsdk: transport %q registered twice", name))
+	}
+	transports[name] = dial
+}
+
+// registeredTransport returns the dial function registered under name
+func registeredTransport(name string) (DialFunc, bool) {
+	transportsMu.RLock()
+	defer transportsMu.RUnlock()
+	dial, ok := transports[name]
+	return dial, ok
+}
+
+// wsTransport is the WebSocket Transport
+type wsTransport struct {
+	conn          *websocket.Conn
+	writeTimeout  time.Duration
+	readTimeout   time.Duration
+	maxFrameBytes int
+}
+
+// dialWebSocket opens a WebSocket connection to target
+func dialWebSocket(ctx context.Context, target DialTarget) (Transport, error) {
+	// Parse WebSocket URL
+	wsURL, err := url.Parse(target.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WebSocket URL: %w", err)
+	}
+
+	// Add query parameters
+	query := wsURL.Query()
+	query.Set("session_id", target.SessionID)
+	query.Set("tenant_id", target.TenantID)
+	query.Set("protocol_versions", strings.Join(target.ProtocolVersions, ","))
+	if target.APIKey != "" {
+		query.Set("api_key", target.APIKey)
+	}
+	wsURL.RawQuery = query.Encode()
+
+	var header http.Header
+	if target.BearerToken != "" {
+		header = http.Header{"Authorization": {"Bearer " + target.BearerToken}}
+	}
+
+	dialer := *websocket.DefaultDialer
+	dialer.TLSClientConfig = target.TLSConfig
+	if target.DialTimeout > 0 {
+		dialer.NetDialContext = (&net.Dialer{Timeout: target.DialTimeout}).DialContext
+	}
+	if target.HandshakeTimeout > 0 {
+		dialer.HandshakeTimeout = target.HandshakeTimeout
+	}
+
+	// Connect to WebSocket
+	conn, _, err := dialer.DialContext(ctx, wsURL.String(), header)
+	if err != nil {
+		return nil, err
+	}
+	if target.MaxFrameBytes > 0 {
+		conn.SetReadLimit(int64(target.MaxFrameBytes))
+	}
+	return &wsTransport{conn: conn, writeTimeout: target.WriteTimeout, readTimeout: target.ReadTimeout, maxFrameBytes: target.MaxFrameBytes}, nil
+}
+
+func (t *wsTransport) Send(frame []byte) error {
+	return t.write(websocket.TextMessage, frame)
+}
+
+func (t *wsTransport) SendBinary(frame []byte) error {
+	return t.write(websocket.BinaryMessage, frame)
+}
+
+func (t *wsTransport) Receive() ([]byte, error) {
+	data, _, err := t.ReceiveMessage()
+	return data, err
+}
+
+func (t *wsTransport) ReceiveMessage() ([]byte, bool, error) {
+	if t.readTimeout > 0 {
+		if err := t.conn.SetReadDeadline(time.Now().Add(t.readTimeout)); err != nil {
+			return nil, false, err
+		}
+	}
+	messageType, data, err := t.conn.ReadMessage()
+	if isTimeout(err) {
+		return nil, false, fmt.Errorf("%w after %v: %w", ErrReadTimeout, t.readTimeout, err)
+	}
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return nil, false, fmt.Errorf("%w: incoming frame over %d bytes: %w", ErrFrameTooLarge, t.maxFrameBytes, err)
+	}
+	return data, messageType == websocket.BinaryMessage, err
+}
+
+// write sends one message within the write timeout
+func (t *wsTransport) write(messageType int, frame []byte) error {
+	if t.writeTimeout > 0 {
+		if err := t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout)); err != nil {
+			return err
+		}
+	}
+	err := t.conn.WriteMessage(messageType, frame)
+	if isTimeout(err) {
+		return fmt.Errorf("%w after %v: %w", ErrWriteTimeout, t.writeTimeout, err)
+	}
+	return err
+}
+
+// isTimeout reports whether err is a network deadline error
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func (t *wsTransport) Close() error {
+	return t.conn.Close()
+}
+
+// dialRouter opens a new connection to the active router URL over the
+// configured transport. Failures wrap ErrConnectionFailed whichever
+// transport is used.
+func (c *ATPClient) dialRouter() (Transport, error) {
+	return c.dialURL(c.endpoints.current())
+}
+
+// dialURL is dialRouter dialling wsURL
+func (c *ATPClient) dialURL(wsURL string) (Transport, error) {
+	dial, target := dialWebSocket, DialTarget{
+		Address:   wsURL,
+		SessionID: c.config.SessionID,
+		TenantID:  c.config.TenantID,
+
+		WriteTimeout:     c.config.WriteTimeout,
+		ReadTimeout:      c.config.ReadTimeout,
+		DialTimeout:      c.config.DialTimeout,
+		HandshakeTimeout: c.config.HandshakeTimeout,
+		ProtocolVersions: SupportedProtocolVersions(),
+		MaxFrameBytes:    max(c.config.MaxFrameBytes, 0),
+	}
+	switch c.config.Transport {
+	case TransportWebSocket, TransportAuto:
+	default:
+		registered, ok := registeredTransport(c.config.Transport)
+		if !ok {
+			return nil, fmt.Errorf("unknown transport %q", c.config.Transport)
+		}
+		dial = registered
+		if c.config.TransportAddress != "" {
+			target.Address = c.config.TransportAddress
+		}
+	}
+
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		c.counters.recordError(err)
+		return nil, fmt.Errorf("%w: %w", ErrConnectionFailed, err)
+	}
+	target.TLSConfig = tlsConfig
+
+	if c.config.APIKeyProvider != nil {
+		if err := c.fetchAPIKey(); err != nil {
+			c.counters.recordError(err)
+			return nil, fmt.Errorf("%w: %w", ErrConnectionFailed, err)
+		}
+	}
+	target.APIKey = c.apiKey()
+	if c.config.TokenSource != nil {
+		token, err := c.bearerToken()
+		if err != nil {
+			c.counters.recordError(err)
+			return nil, fmt.Errorf("%w: %w", ErrConnectionFailed, err)
+		}
+		target.BearerToken = token
+	}
+
+	transport, err := dial(c.ctx, target)
+	if err != nil {
+		c.counters.recordError(err)
+		if isTimeout(err) && !errors.Is(err, ErrDialTimeout) {
+			err = fmt.Errorf("%w: %w", ErrDialTimeout, err)
+		}
+		if errors.Is(err, ErrConnectionFailed) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrConnectionFailed, err)
+	}
+	transport = c.hookTransport(c.recordTransport(transport))
+	if c.config.SessionHandshake {
+		if err := c.handshake(transport); err != nil {
+			_ = transport.Close()
+			c.counters.recordError(err)
+			return nil, fmt.Errorf("%w: %w", ErrConnectionFailed, err)
+		}
+	}
+	return transport, nil
+}
@@ -0,0 +1,160 @@
+package atpsdk
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// silentRouter accepts WebSocket connections and then neither reads nor
+// writes until the test ends
+func silentRouter(t *testing.T) string {
+	done := make(chan struct{})
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		<-done
+	}))
+	t.Cleanup(func() {
+		close(done)
+		server.Close()
+	})
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	// A router that accepts TCP connections but never answers the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	defer func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	}()
+
+	client := NewATPClient(SDKConfig{WSURL: "ws://" + listener.Addr().String(), HandshakeTimeout: 100 * time.Millisecond})
+	defer client.Close()
+
+	start := time.Now()
+	err = client.Connect()
+	if !errors.Is(err, ErrDialTimeout) || !errors.Is(err, ErrConnectionFailed) {
+		t.Fatalf("Expected ErrDialTimeout and ErrConnectionFailed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Connect took %v despite the handshake timeout", elapsed)
+	}
+}
+
+func TestDialRejectionIsNotTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewATPClient(SDKConfig{WSURL: "ws" + strings.TrimPrefix(server.URL, "http")})
+	defer client.Close()
+
+	err := client.Connect()
+	if !errors.Is(err, ErrConnectionFailed) || errors.Is(err, ErrDialTimeout) {
+		t.Fatalf("Expected a connection failure that is not a timeout, got %v", err)
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	disconnects := make(chan error, 1)
+	client := NewATPClient(SDKConfig{
+		WSURL:        silentRouter(t),
+		WriteTimeout: 100 * time.Millisecond,
+		OnDisconnect: func(err error) { disconnects <- err },
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	// Frames larger than the socket buffers block the writer once the
+	// router stops reading
+	payload := map[string]interface{}{"data": strings.Repeat("x", 1<<20)}
+	go func() {
+		for client.IsConnected() {
+			if err := client.sendFrame(Frame{Type: "bulk", Payload: payload}); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case err := <-disconnects:
+		if !errors.Is(err, ErrWriteTimeout) {
+			t.Fatalf("Expected ErrWriteTimeout, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The blocked write did not time out")
+	}
+
+	// Disconnect is not held up by the failed writer
+	disconnected := make(chan struct{})
+	go func() {
+		client.Disconnect()
+		close(disconnected)
+	}()
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("Disconnect blocked after a write timeout")
+	}
+}
+
+func TestReadTimeout(t *testing.T) {
+	disconnects := make(chan error, 2)
+	client := NewATPClient(SDKConfig{
+		WSURL:         silentRouter(t),
+		ReadTimeout:   100 * time.Millisecond,
+		AutoReconnect: true,
+		RetryDelay:    10 * time.Millisecond,
+		OnDisconnect:  func(err error) { disconnects <- err },
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer client.Close()
+
+	select {
+	case err := <-disconnects:
+		if !errors.Is(err, ErrReadTimeout) {
+			t.Fatalf("Expected ErrReadTimeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("The silent connection did not time out")
+	}
+
+	// The timed out connection is redialled
+	deadline := time.Now().Add(2 * time.Second)
+	for client.Stats().Connection.ConnectCount < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if client.Stats().Connection.ConnectCount < 2 {
+		t.Fatal("The client did not reconnect after a read timeout")
+	}
+}
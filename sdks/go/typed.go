@@ -0,0 +1,138 @@
+package atpsdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TypedFrame is a Frame whose payload is a Go value instead of a map. Frame
+// remains the wire type: TypedFrame marshals to the same JSON, and
+// ToFrame converts it for the APIs that take a Frame.
+type TypedFrame[T any] struct {
+	Type      string    `json:"type"`
+	Version   string    `json:"version,omitempty"`
+	Timestamp FrameTime `json:"ts"`
+	StreamID  string    `json:"stream_id,omitempty"`
+	MsgSeq    int       `json:"msg_seq,omitempty"`
+	FragSeq   int       `json:"frag_seq,omitempty"`
+	Flags     []string  `json:"flags,omitempty"`
+	QoS       QoS       `json:"qos,omitempty"`
+	TTL       int       `json:"ttl,omitempty"`
+	Window    Window    `json:"window,omitzero"`
+	Meta      Meta      `json:"meta,omitzero"`
+	Payload   T         `json:"payload"`
+	Signature string    `json:"sig,omitempty"`
+}
+
+// BuildTypedFrame builds a frame of frameType on streamID carrying payload.
+// Unlike FrameBuilder, it stamps the frame with the system clock; set
+// Timestamp, e.g. to FrameTimeOf(clock.Now()), to use another.
+func BuildTypedFrame[T any](frameType, streamID string, payload T) TypedFrame[T] {
+	return TypedFrame[T]{
+		Type:      frameType,
+		Version:   ProtocolVersion,
+		Timestamp: FrameTimeOf(time.Now()),
+		StreamID:  streamID,
+		Payload:   payload,
+	}
+}
+
+// ToFrame converts f to a Frame, encoding the payload through its JSON
+// form. It fails if the payload does not encode to a JSON object.
+func (f TypedFrame[T]) ToFrame() (Frame, error) {
+	data, err := json.Marshal(f.Payload)
+	if err != nil {
+		return Frame{}, fmt.Errorf("failed to marshal %s payload: %w", f.Type, err)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return Frame{}, fmt.Errorf("%s payload is not a JSON object: %w", f.Type, err)
+	}
+	return Frame{
+		Type:      f.Type,
+		Version:   f.Version,
+		Timestamp: f.Timestamp,
+		StreamID:  f.StreamID,
+		MsgSeq:    f.MsgSeq,
+		FragSeq:   f.FragSeq,
+		Flags:     f.Flags,
+		QoS:       f.QoS,
+		TTL:       f.TTL,
+		Window:    f.Window,
+		Meta:      f.Meta,
+		Payload:   payload,
+		Signature: f.Signature,
+	}, nil
+}
+
+// PayloadDecodeError is returned by DecodePayload when a payload does not
+// fit the requested type
+type PayloadDecodeError struct {
+	FrameType string
+	// Field is the dotted path of the mismatched field, e.g. "metadata.region".
+	// It is empty when the payload as a whole could not be decoded.
+	Field string
+	Err   error
+}
+
+func (e *PayloadDecodeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("atpsdk: cannot decode %s payload: %v", e.FrameType, e.Err)
+	}
+	return fmt.Sprintf("atpsdk: cannot decode %s payload field %s: %v", e.FrameType, e.Field, e.Err)
+}
+
+func (e *PayloadDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodePayload decodes the payload of f into a T through its JSON form,
+// so any struct with JSON tags, such as CompletionResponse,
+// CapabilityAdvertisement or HealthStatus, can be filled in one call.
+// Unknown payload fields are ignored; fields of the wrong type fail with a
+// *PayloadDecodeError naming the field.
+func DecodePayload[T any](f Frame) (T, error) {
+	var value T
+	data, err := json.Marshal(f.Payload)
+	if err != nil {
+		return value, &PayloadDecodeError{FrameType: f.Type, Err: err}
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return value, &PayloadDecodeError{
+				FrameType: f.Type,
+				Field:     typeErr.Field,
+				Err:       fmt.Errorf("expected %s, got %s", typeErr.Type, typeErr.Value),
+			}
+		}
+		return value, &PayloadDecodeError{FrameType: f.Type, Err: err}
+	}
+	return value, nil
+}
+
+// DecodeFrame converts f to a TypedFrame, decoding its payload with
+// DecodePayload
+func DecodeFrame[T any](f Frame) (TypedFrame[T], error) {
+	payload, err := DecodePayload[T](f)
+	if err != nil {
+		return TypedFrame[T]{}, err
+	}
+	return TypedFrame[T]{
+		Type:      f.Type,
+		Version:   f.Version,
+		Timestamp: f.Timestamp,
+		StreamID:  f.StreamID,
+		MsgSeq:    f.MsgSeq,
+		FragSeq:   f.FragSeq,
+		Flags:     f.Flags,
+		QoS:       f.QoS,
+		TTL:       f.TTL,
+		Window:    f.Window,
+		Meta:      f.Meta,
+		Payload:   payload,
+		Signature: f.Signature,
+	}, nil
+}
@@ -0,0 +1,114 @@
+package atpsdk
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTypedFrameMarshalsLikeFrame(t *testing.T) {
+	maxTokens := 2048
+	capability := CapabilityAdvertisement{
+		AdapterID:    "adapter-1",
+		AdapterType:  "ollama",
+		Capabilities: []string{"text-generation"},
+		Models:       []string{"llama2:7b"},
+		MaxTokens:    &maxTokens,
+	}
+	typed := BuildTypedFrame("adapter.capability", "stream_1", capability)
+	typed.MsgSeq = 3
+
+	frame, err := typed.ToFrame()
+	if err != nil {
+		t.Fatalf("ToFrame failed: %v", err)
+	}
+	if frame.Type != "adapter.capability" || frame.StreamID != "stream_1" || frame.MsgSeq != 3 || frame.Payload["max_tokens"] != 2048.0 {
+		t.Errorf("Unexpected frame: %+v", frame)
+	}
+
+	// Compare the decoded JSON, as map keys are serialized in sorted order
+	typedJSON, _ := json.Marshal(typed)
+	frameJSON, _ := json.Marshal(frame)
+	var typedWire, frameWire interface{}
+	_ = json.Unmarshal(typedJSON, &typedWire)
+	_ = json.Unmarshal(frameJSON, &frameWire)
+	if !reflect.DeepEqual(typedWire, frameWire) {
+		t.Errorf("TypedFrame and Frame serialize differently:\n%s\n%s", typedJSON, frameJSON)
+	}
+
+	decoded, err := DecodeFrame[CapabilityAdvertisement](frame)
+	if err != nil {
+		t.Fatalf("DecodeFrame failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, typed) {
+		t.Errorf("Round trip changed the frame:\n got %+v\nwant %+v", decoded, typed)
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	fb := NewFrameBuilder("session", "tenant")
+	p95 := 120.5
+	health, err := DecodePayload[HealthStatus](fb.BuildHealthFrame("s1", HealthStatus{AdapterID: "a", Status: "healthy", P95LatencyMS: &p95}))
+	if err != nil {
+		t.Fatalf("DecodePayload failed: %v", err)
+	}
+	if health.AdapterID != "a" || health.Status != "healthy" || health.P95LatencyMS == nil || *health.P95LatencyMS != 120.5 {
+		t.Errorf("Unexpected health status: %+v", health)
+	}
+
+	response, err := DecodePayload[CompletionResponse](Frame{
+		Type:    "completion_response",
+		Payload: map[string]interface{}{"text": "hi", "tokens_in": 3.0, "cost_usd": 0.25, "extra": "ignored"},
+	})
+	if err != nil || response.Text != "hi" || response.TokensIn != 3 || response.CostUSD != 0.25 {
+		t.Errorf("Unexpected response %+v (err %v)", response, err)
+	}
+}
+
+func TestDecodePayloadMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+		field   string
+		message string
+	}{
+		{
+			name:    "string for int",
+			payload: map[string]interface{}{"tokens_in": "three"},
+			field:   "tokens_in",
+			message: "atpsdk: cannot decode completion_response payload field tokens_in: expected int, got string",
+		},
+		{
+			name:    "fractional int",
+			payload: map[string]interface{}{"tokens_out": 1.5},
+			field:   "tokens_out",
+			message: "atpsdk: cannot decode completion_response payload field tokens_out: expected int, got number 1.5",
+		},
+		{
+			name:    "object for string",
+			payload: map[string]interface{}{"text": map[string]interface{}{"a": 1}},
+			field:   "text",
+			message: "atpsdk: cannot decode completion_response payload field text: expected string, got object",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodePayload[CompletionResponse](Frame{Type: "completion_response", Payload: tt.payload})
+			var decodeErr *PayloadDecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("Expected a *PayloadDecodeError, got %v", err)
+			}
+			if decodeErr.Field != tt.field || err.Error() != tt.message {
+				t.Errorf("Unexpected error %q (field %q)", err, decodeErr.Field)
+			}
+		})
+	}
+
+	client := NewATPClient(SDKConfig{})
+	_, err := client.parseCompletionResponse(&Frame{Type: "completion_response", Payload: map[string]interface{}{"cost_usd": "free"}})
+	var decodeErr *PayloadDecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.Field != "cost_usd" {
+		t.Errorf("Expected Complete responses to report mismatched fields, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package atpsdk
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Frame types of the usage report exchange
+const (
+	FrameTypeUsageQuery  = "usage.query"
+	FrameTypeUsageReport = "usage.report"
+)
+
+// UsageScope selects the usage reported by GetUsage. The zero value is the
+// client's session over all time.
+type UsageScope struct {
+	// StreamID, when set, limits the report to one stream of the session
+	StreamID string
+	// Tenant reports the usage of the whole tenant instead of the session
+	Tenant bool
+	// Since and Until bound the time range; zero leaves it open
+	Since time.Time
+	Until time.Time
+}
+
+// UsageReport is the usage recorded by the router, returned by GetUsage
+type UsageReport struct {
+	Requests   int64 `json:"requests"`
+	TokensIn   int64 `json:"tokens_in"`
+	TokensOut  int64 `json:"tokens_out"`
+	CostMicros int64 `json:"cost_usd_micros"`
+	// Models breaks the totals down per model, in the router's order
+	Models []ModelUsage `json:"models"`
+}
+
+// ModelUsage is the usage of one model in a UsageReport
+type ModelUsage struct {
+	Model      string `json:"model"`
+	Requests   int64  `json:"requests"`
+	TokensIn   int64  `json:"tokens_in"`
+	TokensOut  int64  `json:"tokens_out"`
+	CostMicros int64  `json:"cost_usd_micros"`
+}
+
+// GetUsage asks the router for the usage it recorded in scope, to reconcile
+// it with the client's own Stats. A large report may arrive as several
+// usage.report frames flagged FRAG, the last one also LAST; they are merged
+// into one report. Like DiscoverAdapters, it needs a WebSocket connection.
+func (c *ATPClient) GetUsage(ctx context.Context, scope UsageScope) (*UsageReport, error) {
+	payload := map[string]interface{}{
+		"scope":      "session",
+		"session_id": c.config.SessionID,
+	}
+	if scope.StreamID != "" {
+		payload["scope"] = "stream"
+		payload["stream_id"] = scope.StreamID
+	}
+	if scope.Tenant {
+		payload = map[string]interface{}{"scope": "tenant"}
+	}
+	payload["tenant_id"] = c.tenantOf(ctx)
+	if !scope.Since.IsZero() {
+		payload["since"] = scope.Since.UnixMilli()
+	}
+	if !scope.Until.IsZero() {
+		payload["until"] = scope.Until.UnixMilli()
+	}
+
+	report := &UsageReport{}
+	err := c.queryPages(ctx, FrameTypeUsageQuery, payload, FrameTypeUsageReport, func(frame *Frame) error {
+		page, err := DecodePayload[UsageReport](*frame)
+		if err != nil {
+			return err
+		}
+		report.merge(page)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// merge adds the totals of page to r, merging the entries of the same model
+func (r *UsageReport) merge(page UsageReport) {
+	r.Requests += page.Requests
+	r.TokensIn += page.TokensIn
+	r.TokensOut += page.TokensOut
+	r.CostMicros += page.CostMicros
+
+	for _, usage := range page.Models {
+		i := slices.IndexFunc(r.Models, func(m ModelUsage) bool { return m.Model == usage.Model })
+		if i < 0 {
+			r.Models = append(r.Models, usage)
+			continue
+		}
+		existing := &r.Models[i]
+		existing.Requests += usage.Requests
+		existing.TokensIn += usage.TokensIn
+		existing.TokensOut += usage.TokensOut
+		existing.CostMicros += usage.CostMicros
+	}
+}
@@ -0,0 +1,55 @@
+package atpsdk
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGetUsage(t *testing.T) {
+	queries := make(chan map[string]interface{}, 4)
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeUsageQuery {
+			return nil
+		}
+		queries <- f.Payload
+		page := func(flags []string, requests int, models ...interface{}) Frame {
+			return Frame{Type: FrameTypeUsageReport, StreamID: f.StreamID, MsgSeq: f.MsgSeq, Flags: flags, Payload: map[string]interface{}{
+				"requests": requests, "tokens_in": 10 * requests, "tokens_out": 20 * requests, "cost_usd_micros": 100 * requests, "models": models,
+			}}
+		}
+		model := func(name string, requests int) interface{} {
+			return map[string]interface{}{"model": name, "requests": requests, "tokens_in": 10 * requests, "tokens_out": 20 * requests, "cost_usd_micros": 100 * requests}
+		}
+		return []Frame{
+			page([]string{FlagFragment}, 3, model("llama", 2), model("qwen", 1)),
+			page([]string{FlagFragment, FlagLast}, 2, model("llama", 1), model("mistral", 1)),
+		}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), SessionID: "s1", TenantID: "t1"})
+	defer client.Close()
+
+	// The pages are merged into one report
+	report, err := client.GetUsage(context.Background(), UsageScope{})
+	if err != nil {
+		t.Fatalf("GetUsage failed: %v", err)
+	}
+	if report.Requests != 5 || report.TokensIn != 50 || report.TokensOut != 100 || report.CostMicros != 500 {
+		t.Errorf("Unexpected totals %+v", report)
+	}
+	if got := fmt.Sprint(report.Models); got != "[{llama 3 30 60 300} {qwen 1 10 20 100} {mistral 1 10 20 100}]" {
+		t.Errorf("Unexpected per-model usage %s", got)
+	}
+	if q := <-queries; q["scope"] != "session" || q["session_id"] != "s1" || q["tenant_id"] != "t1" {
+		t.Errorf("Unexpected session query %v", q)
+	}
+
+	since := time.UnixMilli(1700000000000)
+	if _, err := client.GetUsage(context.Background(), UsageScope{Tenant: true, Since: since}); err != nil {
+		t.Fatalf("GetUsage failed: %v", err)
+	}
+	if q := <-queries; q["scope"] != "tenant" || q["session_id"] != nil || q["since"] != float64(since.UnixMilli()) || q["until"] != nil {
+		t.Errorf("Unexpected tenant query %v", q)
+	}
+}
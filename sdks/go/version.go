@@ -0,0 +1,123 @@
+package atpsdk
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ProtocolVersion is the ATP frame protocol version this SDK speaks. The
+// frame builder stamps it on every frame.
+const ProtocolVersion = "atp/1.1"
+
+// LegacyProtocolVersion is the version of frames without one, sent by
+// routers that predate versioning
+const LegacyProtocolVersion = "atp/1.0"
+
+// ErrorCodeUnsupportedProtocolVersion is reported by routers that speak
+// none of the client's protocol versions
+const ErrorCodeUnsupportedProtocolVersion = "UNSUPPORTED_PROTOCOL_VERSION"
+
+// supportedProtocolVersions are the versions the client offers when
+// connecting, preferred first
+var supportedProtocolVersions = []string{ProtocolVersion, LegacyProtocolVersion}
+
+// SupportedProtocolVersions returns the protocol versions the SDK can
+// speak, preferred first
+func SupportedProtocolVersions() []string {
+	return slices.Clone(supportedProtocolVersions)
+}
+
+// ProtocolVersionError is returned when the router rejects the client's
+// protocol versions. It matches ErrProtocolVersionMismatch and unwraps to
+// the router's *ATPError.
+type ProtocolVersionError struct {
+	// ClientVersions are the versions the client offered
+	ClientVersions []string
+	// RouterVersions are the versions the router speaks, when it said
+	RouterVersions []string
+	Err            *ATPError
+}
+
+func (e *ProtocolVersionError) Error() string {
+	router := "an unknown version"
+	if len(e.RouterVersions) > 0 {
+		router = strings.Join(e.RouterVersions, ", ")
+	}
+	return fmt.Sprintf("%v: client speaks %s, router speaks %s", ErrProtocolVersionMismatch,
+		strings.Join(e.ClientVersions, ", "), router)
+}
+
+func (e *ProtocolVersionError) Is(target error) bool {
+	return target == ErrProtocolVersionMismatch
+}
+
+func (e *ProtocolVersionError) Unwrap() error {
+	return e.Err
+}
+
+// versionError returns the *ProtocolVersionError for atpErr, read from
+// frame, or atpErr itself when it is about something else. The router's
+// versions are read from the details' supported_versions, else from the
+// frame's own version.
+func versionError(frame *Frame, atpErr *ATPError) error {
+	if atpErr.Code != ErrorCodeUnsupportedProtocolVersion {
+		return atpErr
+	}
+	var routerVersions []string
+	if details, ok := atpErr.Details.(map[string]interface{}); ok {
+		versions, _ := details["supported_versions"].([]interface{})
+		for _, version := range versions {
+			if s, ok := version.(string); ok {
+				routerVersions = append(routerVersions, s)
+			}
+		}
+	}
+	if len(routerVersions) == 0 && frame.Version != "" {
+		routerVersions = []string{frame.Version}
+	}
+	return &ProtocolVersionError{
+		ClientVersions: SupportedProtocolVersions(),
+		RouterVersions: routerVersions,
+		Err:            atpErr,
+	}
+}
+
+// normalizeVersion gives a frame received without a version the legacy
+// version
+func normalizeVersion(frame *Frame) {
+	if frame.Version == "" {
+		frame.Version = LegacyProtocolVersion
+	}
+}
+
+// observeProtocolVersion records the version of a frame received from the
+// router as the negotiated one
+func (c *ATPClient) observeProtocolVersion(frame *Frame) {
+	if current := c.routerVersion.Load(); current == nil || *current != frame.Version {
+		version := frame.Version
+		c.routerVersion.Store(&version)
+	}
+}
+
+// NegotiatedProtocolVersion returns the protocol version the router speaks
+// to the client, read from the frames it sends, or "" before it has sent
+// any
+func (c *ATPClient) NegotiatedProtocolVersion() string {
+	if version := c.routerVersion.Load(); version != nil {
+		return *version
+	}
+	return ""
+}
+
+// reportVersionMismatch reports a version rejection that answers no
+// request, such as one sent when the router accepts the connection,
+// through OnAsyncError
+func (c *ATPClient) reportVersionMismatch(frame *Frame) {
+	err := parseErrorFrame(frame)
+	if errors.Is(err, ErrProtocolVersionMismatch) {
+		c.config.Logger.Printf("Error: %v", err)
+		c.reportAsyncError(err)
+	}
+}
@@ -0,0 +1,83 @@
+package atpsdk
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestProtocolVersionNegotiation(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeCompletionRequest {
+			return nil
+		}
+		if f.Version != ProtocolVersion {
+			t.Errorf("Expected the request to carry %s, got %q", ProtocolVersion, f.Version)
+		}
+		return []Frame{{Type: FrameTypeCompletionResponse, Version: "atp/1.0", StreamID: f.StreamID, MsgSeq: f.MsgSeq,
+			Payload: map[string]interface{}{"text": "ok"}}}
+	})
+	client := NewATPClient(SDKConfig{WSURL: router.URL()})
+	defer client.Close()
+
+	if _, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if got := router.DialQuery(0).Get("protocol_versions"); got != "atp/1.1,atp/1.0" {
+		t.Errorf("Expected the supported versions to be offered, got %q", got)
+	}
+	if got := client.NegotiatedProtocolVersion(); got != "atp/1.0" {
+		t.Errorf("Expected the router's version to be recorded, got %q", got)
+	}
+
+	frame, err := NewFrameBuilder("s1", "t1").DeserializeFrame([]byte(`{"type":"heartbeat_ack","ts":1,"payload":{}}`))
+	if err != nil || frame.Version != LegacyProtocolVersion {
+		t.Errorf("Expected a frame without a version to be %s, got %q (%v)", LegacyProtocolVersion, frame.Version, err)
+	}
+}
+
+func TestProtocolVersionMismatch(t *testing.T) {
+	router := newTestRouter(t, func(f Frame) []Frame {
+		if f.Type != FrameTypeCompletionRequest {
+			return nil
+		}
+		return []Frame{{Type: FrameTypeError, Version: "atp/2.0", StreamID: f.StreamID, MsgSeq: f.MsgSeq,
+			Payload: map[string]interface{}{"error": map[string]interface{}{
+				"code": ErrorCodeUnsupportedProtocolVersion, "message": "unsupported version",
+				"details": map[string]interface{}{"supported_versions": []interface{}{"atp/2.0", "atp/2.1"}},
+			}}}}
+	})
+	asyncErrs := make(chan error, 1)
+	client := NewATPClient(SDKConfig{WSURL: router.URL(), OnAsyncError: func(err error) { asyncErrs <- err }})
+	defer client.Close()
+
+	_, err := client.Complete(context.Background(), CompletionRequest{Prompt: "hi"})
+	var versionErr *ProtocolVersionError
+	if !errors.Is(err, ErrProtocolVersionMismatch) || !errors.As(err, &versionErr) {
+		t.Fatalf("Expected ErrProtocolVersionMismatch, got %v", err)
+	}
+	if !slices.Equal(versionErr.RouterVersions, []string{"atp/2.0", "atp/2.1"}) ||
+		!slices.Equal(versionErr.ClientVersions, SupportedProtocolVersions()) {
+		t.Errorf("Expected both sides' versions, got %v", versionErr)
+	}
+	var atpErr *ATPError
+	if !errors.As(err, &atpErr) || atpErr.Code != ErrorCodeUnsupportedProtocolVersion {
+		t.Errorf("Expected the router's error to be wrapped, got %v", err)
+	}
+
+	// A rejection answering no request is reported asynchronously
+	if err := router.Send(Frame{Type: FrameTypeError, Version: "atp/2.0",
+		Payload: map[string]interface{}{"code": ErrorCodeUnsupportedProtocolVersion, "message": "unsupported version"}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case err := <-asyncErrs:
+		if !errors.As(err, &versionErr) || !slices.Equal(versionErr.RouterVersions, []string{"atp/2.0"}) {
+			t.Errorf("Expected the frame's version as the router's, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the rejection to be reported through OnAsyncError")
+	}
+}
@@ -0,0 +1,29 @@
+package atpsdk
+
+import (
+	"fmt"
+
+	"github.com/atp-project/atp-go-sdk/tokencount"
+)
+
+// ValidateAgainstWindow fails with an error matching ErrWindowExceeded when
+// the prompt's tokens plus MaxTokens exceed w.MaxTokens, so an oversized
+// request fails before it is sent. tokenizer counts the prompt's tokens;
+// when nil, tokencount.Count does for the request's Model. A window without
+// MaxTokens accepts any request.
+func (r CompletionRequest) ValidateAgainstWindow(w Window, tokenizer tokencount.Tokenizer) error {
+	if w.MaxTokens <= 0 {
+		return nil
+	}
+	var promptTokens int
+	if tokenizer != nil {
+		promptTokens = tokenizer.CountTokens(r.Prompt)
+	} else {
+		promptTokens = tokencount.Count(r.Prompt, r.Model)
+	}
+	if promptTokens+r.MaxTokens > w.MaxTokens {
+		return fmt.Errorf("%w: prompt of %d tokens plus max_tokens %d over a window of %d",
+			ErrWindowExceeded, promptTokens, r.MaxTokens, w.MaxTokens)
+	}
+	return nil
+}
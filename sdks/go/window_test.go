@@ -0,0 +1,31 @@
+package atpsdk
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/atp-project/atp-go-sdk/tokencount"
+)
+
+func TestValidateAgainstWindow(t *testing.T) {
+	// 40 bytes: 10 estimated tokens
+	request := CompletionRequest{Prompt: strings.Repeat("abcd", 10), MaxTokens: 90}
+
+	if err := request.ValidateAgainstWindow(Window{MaxTokens: 100}, nil); err != nil {
+		t.Errorf("Expected a request filling the window to pass, got %v", err)
+	}
+	if err := request.ValidateAgainstWindow(Window{}, nil); err != nil {
+		t.Errorf("Expected a window without MaxTokens to accept the request, got %v", err)
+	}
+	err := request.ValidateAgainstWindow(Window{MaxTokens: 99}, nil)
+	if !errors.Is(err, ErrWindowExceeded) {
+		t.Errorf("Expected ErrWindowExceeded, got %v", err)
+	}
+
+	// A tokenizer replaces the estimate
+	perByte := tokencount.TokenizerFunc(func(text string) int { return len(text) })
+	if err := request.ValidateAgainstWindow(Window{MaxTokens: 100}, perByte); !errors.Is(err, ErrWindowExceeded) {
+		t.Errorf("Expected the tokenizer's count to exceed the window, got %v", err)
+	}
+}